@@ -0,0 +1,48 @@
+package config
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultAIJobsChannel is the LISTEN/NOTIFY channel used when AI_JOBS_CHANNEL is unset
+const DefaultAIJobsChannel = "ai_jobs_channel"
+
+// channelNamePattern keeps the channel a plain Postgres identifier - it is embedded in
+// the trigger DDL, and trigger/function names derived from it must stay under 63 chars
+var channelNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,47}$`)
+
+// AIJobsChannel returns the NOTIFY channel for new AI jobs (AI_JOBS_CHANNEL, default ai_jobs_channel)
+func AIJobsChannel() string {
+	channel := strings.ToLower(strings.TrimSpace(os.Getenv("AI_JOBS_CHANNEL")))
+	if channel == "" {
+		return DefaultAIJobsChannel
+	}
+	if !channelNamePattern.MatchString(channel) {
+		log.Printf("⚠️  Warning: Invalid AI_JOBS_CHANNEL=%q (use [a-z0-9_], max 48 chars), using default %s", channel, DefaultAIJobsChannel)
+		return DefaultAIJobsChannel
+	}
+	return channel
+}
+
+// AIJobsTriggerNames returns the trigger function and trigger names for a channel.
+// The default channel keeps the original names; other channels get their own so
+// deployments sharing a database don't replace each other's trigger.
+func AIJobsTriggerNames(channel string) (functionName, triggerName string) {
+	if channel == DefaultAIJobsChannel {
+		return "notify_ai_job_insert", "ai_jobs_insert_trigger"
+	}
+	return "notify_" + channel + "_insert", channel + "_insert_trigger"
+}
+
+// AIPollInterval returns the fallback polling interval of the AI worker (AI_POLL_INTERVAL_MS, default 2000)
+func AIPollInterval() time.Duration {
+	ms := GetEnvInt("AI_POLL_INTERVAL_MS", 2000)
+	if ms <= 0 {
+		ms = 2000
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAIJobsChannel(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"", DefaultAIJobsChannel},
+		{"tenant_a_jobs", "tenant_a_jobs"},
+		{"  Tenant_B_Jobs ", "tenant_b_jobs"},
+		{"jobs'; DROP TABLE ai_jobs;--", DefaultAIJobsChannel},
+		{"1jobs", DefaultAIJobsChannel},
+		{"a_very_long_channel_name_that_exceeds_the_identifier_limit", DefaultAIJobsChannel},
+	}
+	for _, tt := range tests {
+		t.Setenv("AI_JOBS_CHANNEL", tt.env)
+		if got := AIJobsChannel(); got != tt.want {
+			t.Errorf("AI_JOBS_CHANNEL=%q: got %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestAIJobsTriggerNames(t *testing.T) {
+	fn, trigger := AIJobsTriggerNames(DefaultAIJobsChannel)
+	if fn != "notify_ai_job_insert" || trigger != "ai_jobs_insert_trigger" {
+		t.Errorf("default channel must keep existing names, got %s / %s", fn, trigger)
+	}
+
+	fn, trigger = AIJobsTriggerNames("tenant_a_jobs")
+	if fn != "notify_tenant_a_jobs_insert" || trigger != "tenant_a_jobs_insert_trigger" {
+		t.Errorf("custom channel names = %s / %s", fn, trigger)
+	}
+}
+
+func TestAIPollInterval(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":     2 * time.Second,
+		"500":  500 * time.Millisecond,
+		"0":    2 * time.Second,
+		"-100": 2 * time.Second,
+		"abc":  2 * time.Second,
+	}
+	for env, want := range tests {
+		t.Setenv("AI_POLL_INTERVAL_MS", env)
+		if got := AIPollInterval(); got != want {
+			t.Errorf("AI_POLL_INTERVAL_MS=%q: got %v, want %v", env, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GetEnvInt reads an integer env var, returning fallback when unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️  Warning: Invalid %s=%q, using default %d", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetEnvBool reads a boolean env var ("true"/"1"/"yes"/"on"), returning fallback when unset or invalid
+func GetEnvBool(key string, fallback bool) bool {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	switch value {
+	case "":
+		return fallback
+	case "true", "1", "yes", "on":
+		return true
+	case "false", "0", "no", "off":
+		return false
+	default:
+		log.Printf("⚠️  Warning: Invalid %s=%q, using default %v", key, value, fallback)
+		return fallback
+	}
+}
+
+// GetEnvString reads a string env var (trimmed), returning fallback when unset or blank
+func GetEnvString(key, fallback string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,17 @@
+package config
+
+// InternalAPIKey returns the key this service sends as x-api-key (INTERNAL_API_KEY)
+func InternalAPIKey() string {
+	return GetEnvString("INTERNAL_API_KEY", "")
+}
+
+// PreviousInternalAPIKey returns the key being rotated out (INTERNAL_API_KEY_PREVIOUS); it is
+// still accepted, and used when the transactional API hasn't picked up the new key yet.
+// Empty when unset or equal to the current key.
+func PreviousInternalAPIKey() string {
+	previous := GetEnvString("INTERNAL_API_KEY_PREVIOUS", "")
+	if previous == InternalAPIKey() {
+		return ""
+	}
+	return previous
+}
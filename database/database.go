@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"genfity-wa-support/config"
 	"genfity-wa-support/models"
 
 	"gorm.io/driver/postgres"
@@ -46,6 +48,9 @@ func initPrimaryDatabase() {
 
 	log.Println("Primary database connected successfully")
 
+	// Tune connection pool (DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME_MINUTES)
+	configurePool(DB, "DB", "primary", 10, 5)
+
 	// Auto migrate all primary tables (create if not exist)
 	if err := autoMigratePrimaryTables(); err != nil {
 		log.Fatal("Failed to migrate primary database:", err)
@@ -79,10 +84,48 @@ func initTransactionalDatabase() {
 
 	log.Println("Transactional database connected successfully")
 
+	// Tune connection pool (TRANSACTIONAL_DB_MAX_OPEN_CONNS, ...)
+	configurePool(TransactionalDB, "TRANSACTIONAL_DB", "transactional", 5, 2)
+
 	// Check if required tables exist (read-only gateway)
 	checkRequiredTables()
 }
 
+// configurePool applies sql.DB pool limits from env using the given prefix.
+// Cloud Postgres caps connections aggressively, so the defaults are deliberately low.
+func configurePool(db *gorm.DB, envPrefix, name string, defaultMaxOpen, defaultMaxIdle int) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("⚠️  Warning: Could not configure %s connection pool: %v", name, err)
+		return
+	}
+
+	maxOpen := config.GetEnvInt(envPrefix+"_MAX_OPEN_CONNS", defaultMaxOpen)
+	maxIdle := config.GetEnvInt(envPrefix+"_MAX_IDLE_CONNS", defaultMaxIdle)
+	lifetimeMinutes := config.GetEnvInt(envPrefix+"_CONN_MAX_LIFETIME_MINUTES", 30)
+
+	// 0 means unlimited for sql.DB, which defeats the point of capping connections
+	if maxOpen <= 0 {
+		maxOpen = defaultMaxOpen
+	}
+	if maxIdle < 0 {
+		maxIdle = 0
+	}
+	if maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	if lifetimeMinutes < 0 {
+		lifetimeMinutes = 0
+	}
+
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(time.Duration(lifetimeMinutes) * time.Minute)
+
+	log.Printf("✓ %s DB pool: max_open=%d, max_idle=%d, conn_max_lifetime=%dm",
+		name, maxOpen, maxIdle, lifetimeMinutes)
+}
+
 // checkRequiredTables verifies that all required tables exist
 func checkRequiredTables() {
 	requiredTables := []string{
@@ -100,6 +143,20 @@ func checkRequiredTables() {
 		}
 	}
 
+	// Column names are camelCase in Prisma - a tag typo only shows up as a failing query later
+	if missing, err := VerifyModelColumns(TransactionalDB, &models.WhatsappSession{}); err != nil {
+		log.Printf("Warning: Could not verify WhatsAppSession columns: %v", err)
+	} else if required, optional := SplitOptionalColumns(missing, models.WhatsappSessionOptionalColumns); len(required) > 0 || len(optional) > 0 {
+		if len(required) > 0 {
+			log.Printf("Warning: WhatsAppSession is missing columns %v (model tags out of sync with Prisma schema)", required)
+		}
+		if len(optional) > 0 {
+			log.Printf("Warning: WhatsAppSession is missing optional columns %v (features off until the Prisma schema is migrated)", optional)
+		}
+	} else {
+		log.Printf("✓ WhatsAppSession columns match model")
+	}
+
 	log.Println("Database table check completed")
 }
 
@@ -123,8 +180,17 @@ func autoMigratePrimaryTables() error {
 		{"message_send_logs", &models.MessageSendLog{}},
 		{"ai_jobs", &models.AIJob{}},
 		{"ai_job_attempts", &models.AIJobAttempt{}},
-		{"chat_rooms", &models.ChatRoom{}},       // Chat room list for UI
-		{"chat_messages", &models.ChatMessage{}}, // Permanent chat history
+		{"chat_rooms", &models.ChatRoom{}},                        // Chat room list for UI
+		{"chat_messages", &models.ChatMessage{}},                  // Permanent chat history
+		{"raw_webhooks", &models.RawWebhook{}},                    // Raw webhook payloads (AI_STORE_RAW_WEBHOOKS)
+		{"ai_prompt_debug", &models.AIPromptDebug{}},              // Full prompts per job (DEBUG_DUMP_PROMPT)
+		{"ai_context_decisions", &models.AIContextDecision{}},     // Context builder decisions per job (DEBUG_CONTEXT_DECISIONS)
+		{"contact_opt_outs", &models.ContactOptOut{}},             // Contacts that replied STOP / BERHENTI
+		{"ai_reply_approvals", &models.AIReplyApproval{}},         // AI replies awaiting human approval (AI_REPLY_APPROVAL)
+		{"contact_reply_counters", &models.ContactReplyCounter{}}, // AI replies per contact per day (daily cap)
+		{"contact_greetings", &models.ContactGreeting{}},          // Contacts that got the bot's first-contact greeting
+		{"media_archives", &models.MediaArchive{}},                // Incoming media kept for the agent UI (MEDIA_STORE_BACKEND)
+		{"contact_handoffs", &models.ContactHandoff{}},            // Conversations handed off to a human agent
 
 		// Semua data session, user settings, dan subscription ada di Transactional DB
 		// Support DB untuk:
@@ -132,6 +198,15 @@ func autoMigratePrimaryTables() error {
 		// 2. Job queue (ai_jobs, ai_job_attempts)
 		// 3. Message send log (message_send_logs)
 		// 4. Permanent chat history (chat_rooms, chat_messages) - untuk UI
+		// 5. Raw webhook payloads for debug / replay (raw_webhooks)
+		// 6. Full LLM prompts per job for troubleshooting (ai_prompt_debug)
+		// 7. Opt-outs per session + contact (contact_opt_outs)
+		// 8. AI replies held for human approval (ai_reply_approvals)
+		// 9. Daily AI reply count per session + contact (contact_reply_counters)
+		// 10. First-contact greetings already sent (contact_greetings)
+		// 11. Archived incoming media files (media_archives)
+		// 12. Conversations taken over by a human agent (contact_handoffs)
+		// 13. Context builder decisions per job for troubleshooting (ai_context_decisions)
 	}
 
 	migratedCount := 0
@@ -170,8 +245,11 @@ func autoMigratePrimaryTables() error {
 }
 
 // createNotifyTrigger creates Postgres NOTIFY trigger for AI jobs queue
+// Channel comes from AI_JOBS_CHANNEL; function/trigger names are derived from it
 func createNotifyTrigger() error {
-	log.Println("Creating NOTIFY trigger for AI jobs queue...")
+	channel := config.AIJobsChannel()
+	functionName, triggerName := config.AIJobsTriggerNames(channel)
+	log.Printf("Creating NOTIFY trigger for AI jobs queue (channel: %s)...", channel)
 
 	// Set statement timeout to prevent hanging
 	err := DB.Exec(`SET statement_timeout = '10s'`).Error
@@ -179,16 +257,16 @@ func createNotifyTrigger() error {
 		log.Printf("⚠️  Warning: Could not set statement timeout: %v", err)
 	}
 
-	// Create function for NOTIFY
-	err = DB.Exec(`
-		CREATE OR REPLACE FUNCTION notify_ai_job_insert()
+	// Create function for NOTIFY (names are validated identifiers, safe to format in)
+	err = DB.Exec(fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %s()
 		RETURNS TRIGGER AS $$
 		BEGIN
-			PERFORM pg_notify('ai_jobs_channel', 'new');
+			PERFORM pg_notify('%s', 'new');
 			RETURN NEW;
 		END;
 		$$ LANGUAGE plpgsql;
-	`).Error
+	`, functionName, channel)).Error
 	if err != nil {
 		log.Printf("⚠️  Warning: Failed to create notify function (might not have permission): %v", err)
 		log.Println("⚠️  NOTIFY trigger skipped - worker will use polling only")
@@ -198,20 +276,20 @@ func createNotifyTrigger() error {
 	}
 
 	// Drop existing trigger if exists
-	err = DB.Exec(`
-		DROP TRIGGER IF EXISTS ai_jobs_insert_trigger ON ai_jobs;
-	`).Error
+	err = DB.Exec(fmt.Sprintf(`
+		DROP TRIGGER IF EXISTS %s ON ai_jobs;
+	`, triggerName)).Error
 	if err != nil {
 		log.Printf("⚠️  Warning: Failed to drop existing trigger: %v", err)
 	}
 
 	// Create trigger
-	err = DB.Exec(`
-		CREATE TRIGGER ai_jobs_insert_trigger
+	err = DB.Exec(fmt.Sprintf(`
+		CREATE TRIGGER %s
 		AFTER INSERT ON ai_jobs
 		FOR EACH ROW
-		EXECUTE FUNCTION notify_ai_job_insert();
-	`).Error
+		EXECUTE FUNCTION %s();
+	`, triggerName, functionName)).Error
 	if err != nil {
 		log.Printf("⚠️  Warning: Failed to create trigger (might not have permission): %v", err)
 		log.Println("⚠️  NOTIFY trigger skipped - worker will use polling only")
@@ -223,6 +301,6 @@ func createNotifyTrigger() error {
 	// Reset timeout
 	DB.Exec(`RESET statement_timeout`)
 
-	log.Println("✓ NOTIFY trigger created successfully for ai_jobs_channel")
+	log.Printf("✓ NOTIFY trigger %s created successfully for %s", triggerName, channel)
 	return nil
 }
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ModelColumns returns the column names a GORM model maps to (as written in its tags)
+func ModelColumns(db *gorm.DB, model interface{}) ([]string, error) {
+	namer := schema.Namer(schema.NamingStrategy{})
+	if db != nil {
+		namer = db.NamingStrategy
+	}
+	s, err := schema.Parse(model, &sync.Map{}, namer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+
+	columns := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.DBName != "" {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns, nil
+}
+
+// VerifyModelColumns checks that every column of model exists in its table with the exact
+// (case-sensitive) name - Prisma uses camelCase, so "userid" vs "userId" is a real mismatch.
+// Returns the missing columns, sorted.
+func VerifyModelColumns(db *gorm.DB, model interface{}) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+
+	expected, err := ModelColumns(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	columnTypes, err := db.Migrator().ColumnTypes(model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table columns: %w", err)
+	}
+	return missingFrom(columnTypes, expected), nil
+}
+
+// SplitOptionalColumns separates columns into required ones and those listed in optional
+func SplitOptionalColumns(columns, optional []string) (required, optionalFound []string) {
+	isOptional := make(map[string]bool, len(optional))
+	for _, column := range optional {
+		isOptional[column] = true
+	}
+	for _, column := range columns {
+		if isOptional[column] {
+			optionalFound = append(optionalFound, column)
+		} else {
+			required = append(required, column)
+		}
+	}
+	return required, optionalFound
+}
+
+// MissingColumns returns which of columns (exact, case-sensitive names) table doesn't have, sorted
+func MissingColumns(db *gorm.DB, table string, columns []string) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not initialized")
+	}
+	columnTypes, err := db.Migrator().ColumnTypes(table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	return missingFrom(columnTypes, columns), nil
+}
+
+func missingFrom(columnTypes []gorm.ColumnType, expected []string) []string {
+	actual := make(map[string]bool, len(columnTypes))
+	for _, ct := range columnTypes {
+		actual[ct.Name()] = true
+	}
+
+	var missing []string
+	for _, column := range expected {
+		if !actual[column] {
+			missing = append(missing, column)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// prismaWhatsAppSessionDDL mirrors the Prisma migration for WhatsAppSession (quoted camelCase columns)
+const prismaWhatsAppSessionDDL = `CREATE TABLE "WhatsAppSession" (
+	"id" VARCHAR(30) NOT NULL,
+	"sessionId" TEXT NOT NULL,
+	"sessionName" TEXT NOT NULL,
+	"token" TEXT NOT NULL,
+	"userId" TEXT,
+	"webhook" TEXT,
+	"events" TEXT,
+	"expiration" INTEGER NOT NULL DEFAULT 0,
+	"connected" BOOLEAN NOT NULL DEFAULT false,
+	"loggedIn" BOOLEAN NOT NULL DEFAULT false,
+	"jid" TEXT,
+	"qrcode" TEXT,
+	"status" TEXT NOT NULL DEFAULT 'disconnected',
+	"message" TEXT,
+	"autoReadMessages" BOOLEAN NOT NULL DEFAULT false,
+	"typingIndicator" BOOLEAN NOT NULL DEFAULT false,
+	"isSystemSession" BOOLEAN NOT NULL DEFAULT false,
+	"waServerUrl" TEXT,
+	"forwardWebhookUrl" TEXT,
+	"forwardWebhookSecret" TEXT,
+	"createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"updatedAt" TIMESTAMP(3) NOT NULL,
+	CONSTRAINT "WhatsAppSession_pkey" PRIMARY KEY ("id")
+)`
+
+func TestWhatsappSessionColumnsMatchPrisma(t *testing.T) {
+	columns, err := ModelColumns(nil, &models.WhatsappSession{})
+	if err != nil {
+		t.Fatalf("ModelColumns: %v", err)
+	}
+	sort.Strings(columns)
+
+	expected := []string{
+		"autoReadMessages", "connected", "createdAt", "events", "expiration", "forwardWebhookSecret", "forwardWebhookUrl", "id", "isSystemSession",
+		"jid", "loggedIn", "message", "qrcode", "sessionId", "sessionName", "status", "token",
+		"typingIndicator", "updatedAt", "userId", "waServerUrl", "webhook",
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Fatalf("columns = %v, want %v", columns, expected)
+	}
+
+	for _, column := range []string{
+		models.WhatsappSessionColID, models.WhatsappSessionColSessionID, models.WhatsappSessionColToken,
+		models.WhatsappSessionColUserID, models.WhatsappSessionColConnected, models.WhatsappSessionColJID,
+		models.WhatsappSessionColAutoReadMessages, models.WhatsappSessionColTypingIndicator,
+		models.WhatsappSessionColUpdatedAt,
+	} {
+		if i := sort.SearchStrings(expected, column); i == len(expected) || expected[i] != column {
+			t.Errorf("column constant %q is not a WhatsAppSession column", column)
+		}
+	}
+}
+
+// setupPrismaSchemaDB connects to TEST_DATABASE_DSN with a throwaway schema on the search_path
+// (skips the test when unset), so the Prisma-style table doesn't touch real data
+func setupPrismaSchemaDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set - skipping database test")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	schemaName := fmt.Sprintf("verify_cols_%d", time.Now().UnixNano())
+	if err := admin.Exec(fmt.Sprintf(`CREATE SCHEMA "%s"`, schemaName)).Error; err != nil {
+		t.Fatalf("failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(fmt.Sprintf(`DROP SCHEMA "%s" CASCADE`, schemaName)) })
+
+	scoped := dsn + " search_path=" + schemaName
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		scoped = dsn + sep + "search_path=" + schemaName
+	}
+	db, err := gorm.Open(postgres.Open(scoped), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test schema: %v", err)
+	}
+	return db
+}
+
+func TestVerifyModelColumnsAgainstPrismaSchema(t *testing.T) {
+	db := setupPrismaSchemaDB(t)
+	if err := db.Exec(prismaWhatsAppSessionDDL).Error; err != nil {
+		t.Fatalf("failed to create WhatsAppSession: %v", err)
+	}
+
+	missing, err := VerifyModelColumns(db, &models.WhatsappSession{})
+	if err != nil {
+		t.Fatalf("VerifyModelColumns: %v", err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("missing columns = %v, want none", missing)
+	}
+
+	userID := "user-1"
+	now := time.Now()
+	sessions := []models.WhatsappSession{
+		{ID: "s1", SessionID: "sid-1", SessionName: "one", Token: "tok-1", UserID: &userID, Connected: true, UpdatedAt: now.Add(-time.Hour)},
+		{ID: "s2", SessionID: "sid-2", SessionName: "two", Token: "tok-2", UserID: &userID, Connected: true, UpdatedAt: now},
+		{ID: "s3", SessionID: "sid-3", SessionName: "three", Token: "tok-3", UserID: &userID, Connected: false, UpdatedAt: now},
+	}
+	if err := db.Create(&sessions).Error; err != nil {
+		t.Fatalf("failed to insert sessions: %v", err)
+	}
+
+	// Same query shapes as the session resolver, gateway limit check and campaign sender
+	var byToken models.WhatsappSession
+	if err := db.Where(map[string]interface{}{models.WhatsappSessionColToken: "tok-1"}).First(&byToken).Error; err != nil {
+		t.Fatalf("lookup by token: %v", err)
+	}
+	if byToken.ID != "s1" {
+		t.Errorf("lookup by token = %s, want s1", byToken.ID)
+	}
+
+	var connected int64
+	if err := db.Model(&models.WhatsappSession{}).
+		Where(map[string]interface{}{models.WhatsappSessionColUserID: userID, models.WhatsappSessionColConnected: true}).
+		Count(&connected).Error; err != nil {
+		t.Fatalf("count connected: %v", err)
+	}
+	if connected != 2 {
+		t.Errorf("connected sessions = %d, want 2", connected)
+	}
+
+	var latest models.WhatsappSession
+	if err := db.Where(map[string]interface{}{models.WhatsappSessionColUserID: userID, models.WhatsappSessionColConnected: true}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: models.WhatsappSessionColUpdatedAt}, Desc: true}).
+		First(&latest).Error; err != nil {
+		t.Fatalf("latest connected session: %v", err)
+	}
+	if latest.ID != "s2" {
+		t.Errorf("latest connected session = %s, want s2", latest.ID)
+	}
+}
+
+func TestVerifyModelColumnsReportsMismatch(t *testing.T) {
+	db := setupPrismaSchemaDB(t)
+	// Lowercased columns (unquoted DDL) are exactly the mismatch the check exists for
+	ddl := strings.ReplaceAll(prismaWhatsAppSessionDDL, `"userId"`, `userid`)
+	ddl = strings.ReplaceAll(ddl, `"updatedAt"`, `updatedat`)
+	if err := db.Exec(ddl).Error; err != nil {
+		t.Fatalf("failed to create WhatsAppSession: %v", err)
+	}
+
+	missing, err := VerifyModelColumns(db, &models.WhatsappSession{})
+	if err != nil {
+		t.Fatalf("VerifyModelColumns: %v", err)
+	}
+	if !reflect.DeepEqual(missing, []string{"updatedAt", "userId"}) {
+		t.Errorf("missing = %v, want [updatedAt userId]", missing)
+	}
+}
+
+func TestWhatsappSessionOptionalColumnsNotRequired(t *testing.T) {
+	columns, err := ModelColumns(nil, &models.WhatsappSession{})
+	if err != nil {
+		t.Fatalf("ModelColumns: %v", err)
+	}
+	required, optional := SplitOptionalColumns(columns, models.WhatsappSessionOptionalColumns)
+	if len(optional) != len(models.WhatsappSessionOptionalColumns) {
+		t.Errorf("optional columns %v are not all model columns (found %v)", models.WhatsappSessionOptionalColumns, optional)
+	}
+	for _, column := range models.WhatsappSessionOptionalColumns {
+		for _, r := range required {
+			if r == column {
+				t.Errorf("optional column %q is required", column)
+			}
+		}
+	}
+}
+
+func TestVerifyModelColumnsBeforeOptionalMigration(t *testing.T) {
+	db := setupPrismaSchemaDB(t)
+	// A Prisma schema from before the optional columns were added
+	ddl := prismaWhatsAppSessionDDL
+	for _, column := range models.WhatsappSessionOptionalColumns {
+		ddl = strings.Replace(ddl, fmt.Sprintf("\t%q TEXT,\n", column), "", 1)
+	}
+	if err := db.Exec(ddl).Error; err != nil {
+		t.Fatalf("failed to create WhatsAppSession: %v", err)
+	}
+
+	missing, err := VerifyModelColumns(db, &models.WhatsappSession{})
+	if err != nil {
+		t.Fatalf("VerifyModelColumns: %v", err)
+	}
+	required, optional := SplitOptionalColumns(missing, models.WhatsappSessionOptionalColumns)
+	if len(required) != 0 || len(optional) != len(models.WhatsappSessionOptionalColumns) {
+		t.Fatalf("missing required = %v, optional = %v; want only the optional ones", required, optional)
+	}
+
+	// Session lookups keep working: the optional fields are just empty
+	if err := db.Exec(`INSERT INTO "WhatsAppSession" ("id","sessionId","sessionName","token","updatedAt") VALUES ('s1','sid-1','one','tok-1',NOW())`).Error; err != nil {
+		t.Fatalf("failed to insert session: %v", err)
+	}
+	var session models.WhatsappSession
+	if err := db.Where(map[string]interface{}{models.WhatsappSessionColToken: "tok-1"}).First(&session).Error; err != nil {
+		t.Fatalf("lookup by token: %v", err)
+	}
+	if session.WAServerURL != nil || session.ForwardWebhookURL != nil || session.ForwardWebhookSecret != nil {
+		t.Errorf("optional fields set without their columns: %+v", session)
+	}
+}
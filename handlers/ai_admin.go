@@ -0,0 +1,725 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"genfity-wa-support/models"
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulateConversationRequest is the payload for the bot persona test harness
+type SimulateConversationRequest struct {
+	SessionToken string   `json:"session_token"`
+	Turns        []string `json:"turns" binding:"required,min=1"`
+	MaxMessages  int      `json:"max_messages"`
+}
+
+// SimulateBotConversation replays scripted user turns against the current bot config
+// POST /admin/bot/:userId/simulate
+func SimulateBotConversation(c *gin.Context) {
+	userID := c.Param("userId")
+
+	var req SimulateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	if req.MaxMessages <= 0 {
+		req.MaxMessages = 10
+	}
+
+	botSettings, aiProvider, ok := loadBotForAdmin(c, userID, req.SessionToken)
+	if !ok {
+		return
+	}
+
+	log.Printf("🧪 [Simulator] Running %d scripted turns for user %s", len(req.Turns), userID)
+	result := services.SimulateConversation(aiProvider, botSettings, req.Turns, req.MaxMessages)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Simulation completed",
+		"data":    result,
+	})
+}
+
+// PreviewBotRequest is the payload for a single dry-run reply
+type PreviewBotRequest struct {
+	SessionToken  string `json:"session_token"`
+	Message       string `json:"message" binding:"required"`
+	QuotedMessage string `json:"quoted_message"` // optional: preview a reply-quote
+}
+
+// PreviewBotReply runs the bot's prompt + knowledge base against a sample message without sending anything
+// POST /admin/bot/:userId/preview
+//
+// Note: the preview has no conversation history (nothing is read from ai_chat_messages),
+// so a live reply to the same message can differ when earlier messages change the context.
+func PreviewBotReply(c *gin.Context) {
+	userID := c.Param("userId")
+
+	var req PreviewBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	botSettings, aiProvider, ok := loadBotForAdmin(c, userID, req.SessionToken)
+	if !ok {
+		return
+	}
+
+	result, err := services.PreviewBotReply(aiProvider, botSettings, req.Message, req.QuotedMessage)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"code":    502,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Preview generated",
+		"data":    result,
+	})
+}
+
+// loadBotForAdmin fetches bot settings and an AI provider, writing an error response on failure
+func loadBotForAdmin(c *gin.Context, userID, sessionToken string) (*services.BotSettings, services.AIProvider, bool) {
+	provider, err := services.GetDataProvider()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": "Failed to get data provider: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	botSettings, err := provider.GetBotSettings(userID, sessionToken)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"success": false,
+			"message": "Failed to fetch bot settings: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	aiProvider, err := services.GetAIProvider()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": "Failed to initialize AI provider: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	// Simulate with the bot's own key / model when it has them, like the worker
+	llm := services.ResolveBotLLM(botSettings, services.BotLLM{Provider: aiProvider})
+	return botSettings, llm.Provider, true
+}
+
+// GetMetrics returns runtime metrics of the AI pipeline (queue depth, backpressure state, LISTEN health)
+// GET /admin/metrics
+func GetMetrics(c *gin.Context) {
+	stats, err := services.CheckQueueDepth()
+	if err != nil {
+		log.Printf("⚠️  Failed to refresh queue depth: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Metrics retrieved",
+		"data": gin.H{
+			"queue":            stats,
+			"listener":         services.GetListenerHealth(),
+			"circuit_breakers": services.ListCircuitBreakers(),
+		},
+	})
+}
+
+// ListCircuitBreakers returns the state of all registered circuit breakers
+// GET /admin/circuit
+func ListCircuitBreakers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Circuit breakers retrieved",
+		"data":    services.ListCircuitBreakers(),
+	})
+}
+
+// ResetCircuitBreaker force-closes a circuit breaker (e.g. provider recovered before cooldown ended)
+// POST /admin/circuit/:name/reset
+func ResetCircuitBreaker(c *gin.Context) {
+	name := c.Param("name")
+	cb, ok := services.GetCircuitBreaker(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"success": false,
+			"message": "Circuit breaker not found: " + name,
+		})
+		return
+	}
+
+	cb.Reset()
+	log.Printf("🔧 [Admin] Circuit breaker %s reset", name)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Circuit breaker reset",
+		"data":    cb.State(),
+	})
+}
+
+// InvalidateSessionCache drops cached ResolveSession results after a bot toggle / subscription change
+// DELETE /admin/session-cache?token=<sessionToken> (no token = clear all)
+func InvalidateSessionCache(c *gin.Context) {
+	token := c.Query("token")
+	removed := services.InvalidateSessionCache(token)
+	log.Printf("🔧 [Admin] Session cache invalidated (token=%q, removed=%d)", token, removed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Session cache invalidated",
+		"data":    gin.H{"removed": removed},
+	})
+}
+
+// PingWAServer checks that the gateway can reach and authenticate with the WA server
+// GET /admin/wa/ping?token=<sessionToken> (no token = admin check with WA_ADMIN_TOKEN)
+func PingWAServer(c *gin.Context) {
+	result := services.PingWAServer(c.Query("token"))
+	if !result.OK() {
+		log.Printf("🔧 [Admin] WA server ping failed (%s check): %s", result.Check, result.Error)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    http.StatusServiceUnavailable,
+			"success": false,
+			"message": result.Error,
+			"data":    result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "WA server reachable",
+		"data":    result,
+	})
+}
+
+// defaultOptOutListLimit caps GET /admin/opt-outs when no limit is given
+const defaultOptOutListLimit = 100
+
+// ListOptOuts returns recorded opt-outs, newest first
+// GET /admin/opt-outs?token=<sessionToken>&contact=<phone>&limit=
+func ListOptOuts(c *gin.Context) {
+	limit, ok := queryLimit(c, defaultOptOutListLimit)
+	if !ok {
+		return
+	}
+
+	optOuts, err := services.ListOptOuts(c.Query("token"), c.Query("contact"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Opt-outs retrieved",
+		"data": gin.H{
+			"count":    len(optOuts),
+			"opt_outs": optOuts,
+		},
+	})
+}
+
+// queryLimit reads ?limit= (fallback when absent), answering 400 for anything but a positive integer
+func queryLimit(c *gin.Context, fallback int) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return fallback, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "limit must be a positive integer",
+		})
+		return 0, false
+	}
+	return limit, true
+}
+
+// defaultDryRunListLimit caps GET /admin/dry-run/sends when no limit is given
+const defaultDryRunListLimit = 100
+
+// ListDryRunSends returns the WhatsApp calls recorded instead of sent while DRY_RUN=true, newest first
+// GET /admin/dry-run/sends?token=<sessionToken>&limit=
+func ListDryRunSends(c *gin.Context) {
+	limit, ok := queryLimit(c, defaultDryRunListLimit)
+	if !ok {
+		return
+	}
+
+	sends, err := services.ListDryRunSends(c.Query("token"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Dry-run sends retrieved",
+		"data": gin.H{
+			"dry_run": services.DryRun(),
+			"count":   len(sends),
+			"sends":   sends,
+		},
+	})
+}
+
+// defaultUsageDays is the range of GET /admin/usage without from/to; maxUsageDays caps any range
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 366
+)
+
+// GetUsageSummary returns AI usage (messages, tokens, latency, error rate, estimated cost) per day
+// GET /admin/usage?userId=&from=&to= (no userId = all users; default range = last 30 days)
+func GetUsageSummary(c *gin.Context) {
+	from, to, err := parseUsageRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "Invalid range: " + err.Error(),
+		})
+		return
+	}
+	respondUsageSummary(c, c.Query("userId"), from, to)
+}
+
+// parseUsageRange parses from/to like the list endpoints (RFC3339 or YYYY-MM-DD, "to" inclusive)
+// and fills in the default range
+func parseUsageRange(rawFrom, rawTo string) (time.Time, time.Time, error) {
+	from, err := parseListTime(rawFrom, false)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+	}
+	to, err := parseListTime(rawTo, true)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+	}
+
+	if to == nil {
+		now := time.Now().UTC()
+		to = &now
+	}
+	if from == nil {
+		start := to.AddDate(0, 0, -defaultUsageDays)
+		from = &start
+	}
+	switch {
+	case !from.Before(*to):
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	case to.Sub(*from) > maxUsageDays*24*time.Hour:
+		return time.Time{}, time.Time{}, fmt.Errorf("range is longer than %d days", maxUsageDays)
+	}
+	return *from, *to, nil
+}
+
+func respondUsageSummary(c *gin.Context, userID string, from, to time.Time) {
+	provider, err := services.GetDataProvider()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	reporter, ok := provider.(services.UsageSummaryProvider)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    501,
+			"success": false,
+			"message": "Usage summary is not available in this data access mode",
+		})
+		return
+	}
+
+	summary, err := reporter.GetUsageSummary(userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"code":    502,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Usage summary retrieved",
+		"data":    summary,
+	})
+}
+
+// ClearOptOuts removes opt-outs so the contact gets AI replies / campaigns again (e.g. they opted back in)
+// DELETE /admin/opt-outs?token=<sessionToken>&contact=<phone> (no contact = whole session)
+func ClearOptOuts(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "token is required",
+		})
+		return
+	}
+
+	contact := c.Query("contact")
+	removed, err := services.ClearOptOuts(token, contact)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	log.Printf("🔧 [Admin] Opt-outs cleared (token=%q, contact=%q, removed=%d)", token, contact, removed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Opt-outs cleared",
+		"data":    gin.H{"removed": removed},
+	})
+}
+
+// defaultHandoffListLimit caps GET /admin/handoffs when no limit is given
+const defaultHandoffListLimit = 100
+
+// ListHandoffs returns conversations handed off to a human agent, newest first
+// GET /admin/handoffs?token=<sessionToken>&limit=
+func ListHandoffs(c *gin.Context) {
+	limit, ok := queryLimit(c, defaultHandoffListLimit)
+	if !ok {
+		return
+	}
+
+	handoffs, err := services.ListHandoffs(c.Query("token"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Handoffs retrieved",
+		"data": gin.H{
+			"count":    len(handoffs),
+			"handoffs": handoffs,
+		},
+	})
+}
+
+// EndHandoff returns conversations to the bot, so it answers the contact again
+// DELETE /admin/handoffs?token=<sessionToken>&contact=<phone> (no contact = whole session)
+func EndHandoff(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "token is required",
+		})
+		return
+	}
+
+	contact := c.Query("contact")
+	returned, err := services.EndHandoff(token, contact)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	log.Printf("🔧 [Admin] Handoff ended (token=%q, contact=%q, returned=%d)", token, contact, returned)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Conversations returned to the bot",
+		"data":    gin.H{"returned": returned},
+	})
+}
+
+// GetBotContactFilter returns the allow/deny list of the user's active bot
+// GET /admin/bot/:userId/contact-filter
+func GetBotContactFilter(c *gin.Context) {
+	filter, err := services.GetBotContactFilter(c.Param("userId"))
+	if err != nil {
+		respondContactFilterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Contact filter retrieved",
+		"data":    filter,
+	})
+}
+
+// UpdateBotContactFilter replaces the allow/deny list of the user's active bot
+// PUT /admin/bot/:userId/contact-filter  {"mode": "allowlist"|"denylist"|"", "allowed": [...], "blocked": [...]}
+func UpdateBotContactFilter(c *gin.Context) {
+	var req services.ContactFilter
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	if !services.ValidContactFilterMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "mode must be allowlist, denylist or empty",
+		})
+		return
+	}
+
+	userID := c.Param("userId")
+	filter, err := services.SetBotContactFilter(userID, req)
+	if err != nil {
+		respondContactFilterError(c, err)
+		return
+	}
+	log.Printf("🔧 [Admin] Contact filter of %s set (mode=%q, allowed=%d, blocked=%d)",
+		userID, filter.Mode, len(filter.Allowed), len(filter.Blocked))
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Contact filter updated",
+		"data":    filter,
+	})
+}
+
+// respondContactFilterError maps contact filter failures: no active bot = 404
+func respondContactFilterError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrBotNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+// defaultApprovalListLimit caps GET /admin/approvals when no limit is given
+const defaultApprovalListLimit = 100
+
+// ApproveReplyRequest optionally replaces the drafted reply before it is sent
+type ApproveReplyRequest struct {
+	Reply string `json:"reply"`
+}
+
+// RejectReplyRequest - regenerate queues the job again so the bot drafts a new reply
+type RejectReplyRequest struct {
+	Reason     string `json:"reason"`
+	Regenerate bool   `json:"regenerate"`
+}
+
+// ListReplyApprovals returns AI replies held for review (AI_REPLY_APPROVAL), oldest first
+// GET /admin/approvals?token=<sessionToken>&status=pending_approval&limit=
+func ListReplyApprovals(c *gin.Context) {
+	limit := defaultApprovalListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"success": false,
+				"message": "limit must be a positive integer",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	status := c.DefaultQuery("status", models.ReplyApprovalPending)
+	approvals, err := services.ListReplyApprovals(c.Query("token"), status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Reply approvals retrieved",
+		"data": gin.H{
+			"count":     len(approvals),
+			"approvals": approvals,
+		},
+	})
+}
+
+// ApproveReply sends a held AI reply, optionally edited by the reviewer
+// POST /admin/approvals/:id/approve  {"reply": "<edited text>"} (body optional)
+func ApproveReply(c *gin.Context) {
+	id, ok := approvalIDParam(c)
+	if !ok {
+		return
+	}
+	var req ApproveReplyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"success": false,
+				"message": "Invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	approval, err := services.ApproveReply(id, req.Reply)
+	if err != nil {
+		respondApprovalError(c, approval, err)
+		return
+	}
+	log.Printf("🔧 [Admin] Reply #%d approved and sent", id)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Reply approved and sent",
+		"data":    approval,
+	})
+}
+
+// RejectReply discards a held AI reply, optionally regenerating it
+// POST /admin/approvals/:id/reject  {"reason": "...", "regenerate": true} (body optional)
+func RejectReply(c *gin.Context) {
+	id, ok := approvalIDParam(c)
+	if !ok {
+		return
+	}
+	var req RejectReplyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"success": false,
+				"message": "Invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	approval, err := services.RejectReply(id, req.Reason, req.Regenerate)
+	if err != nil {
+		respondApprovalError(c, approval, err)
+		return
+	}
+	log.Printf("🔧 [Admin] Reply #%d rejected (regenerate=%v)", id, req.Regenerate)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Reply rejected",
+		"data":    approval,
+	})
+}
+
+// approvalIDParam parses :id, answering 400 when it isn't a positive integer
+func approvalIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "id must be a positive integer",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondApprovalError maps approve/reject failures: already handled = 409, send failure = 502
+func respondApprovalError(c *gin.Context, approval *models.AIReplyApproval, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrApprovalNotPending):
+		status = http.StatusConflict
+	case approval != nil && approval.Status == models.ReplyApprovalFailed:
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"success": false,
+		"message": err.Error(),
+		"data":    approval,
+	})
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCircuitRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/circuit", ListCircuitBreakers)
+	router.POST("/admin/circuit/:name/reset", ResetCircuitBreaker)
+	return router
+}
+
+func TestResetCircuitBreakerEndpoint(t *testing.T) {
+	cb := services.NewCircuitBreaker("test_admin_reset", 1, time.Hour)
+	_ = cb.Call(func() error { return errors.New("provider down") })
+	if !cb.IsOpen() {
+		t.Fatalf("setup: breaker should be open")
+	}
+
+	router := newCircuitRouter()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/circuit", nil))
+	var list struct {
+		Data []services.CircuitBreakerState `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid list response: %v", err)
+	}
+	found := false
+	for _, s := range list.Data {
+		if s.Name == "test_admin_reset" {
+			found = s.IsOpen
+		}
+	}
+	if !found {
+		t.Errorf("GET /admin/circuit should list the open breaker: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/circuit/test_admin_reset/reset", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset status = %d", rec.Code)
+	}
+	if cb.IsOpen() {
+		t.Errorf("breaker still open after reset")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/circuit/unknown/reset", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown breaker reset = %d, want 404", rec.Code)
+	}
+}
+
+func TestInvalidateSessionCacheEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/admin/session-cache", InvalidateSessionCache)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/session-cache?token=unknown-token", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Removed int `json:"removed"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Data.Removed != 0 {
+		t.Errorf("removed = %d, want 0 for an uncached token", resp.Data.Removed)
+	}
+}
+
+func TestOptOutWebhookAndAdminEndpoints(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.DB, &models.ContactOptOut{})
+	t.Setenv("AI_OPT_OUT_KEYWORDS", "")
+	t.Setenv("AI_OPT_OUT_CONFIRM_REPLY", "false")
+	t.Setenv("AI_STORE_RAW_WEBHOOKS", "false")
+
+	sessionTok := fmt.Sprintf("test-optout-%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.ContactOptOut{}) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhook/ai", HandleAIWebhook)
+	router.GET("/admin/opt-outs", ListOptOuts)
+	router.DELETE("/admin/opt-outs", ClearOptOuts)
+
+	send := func(id, text string) string {
+		payload := fmt.Sprintf(`{"instanceName":%q,"event":{"Info":{"ID":%q,"Sender":"6281200000001@s.whatsapp.net","Chat":"6281200000001@s.whatsapp.net","Type":"text","Timestamp":%q},"Message":{"conversation":%q}}}`,
+			sessionTok, id, time.Now().Format(time.RFC3339), text)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook/ai", bytes.NewReader([]byte(payload))))
+		var resp map[string]interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return fmt.Sprint(resp["message"])
+	}
+
+	if got := send("OPT1", "Berhenti!"); got != "Contact opted out" {
+		t.Fatalf("STOP message result = %q", got)
+	}
+	// Later messages from the contact never reach session resolution / the AI queue
+	if got := send("OPT2", "halo, mau tanya harga"); got != "Contact opted out" {
+		t.Errorf("message after opt-out result = %q, want short-circuit", got)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/opt-outs?token="+sessionTok, nil))
+	var list struct {
+		Data struct {
+			Count   int                    `json:"count"`
+			OptOuts []models.ContactOptOut `json:"opt_outs"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || list.Data.Count != 1 {
+		t.Fatalf("list = %s", rec.Body.String())
+	}
+	if got := list.Data.OptOuts[0]; got.Contact != "6281200000001" || got.Keyword != "berhenti" {
+		t.Errorf("opt-out = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/opt-outs", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("clear without token = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/opt-outs?token="+sessionTok+"&contact=6281200000001", nil))
+	if rec.Code != http.StatusOK || services.IsOptedOut(sessionTok, "6281200000001") {
+		t.Errorf("clear = %d %s, contact still opted out: %v", rec.Code, rec.Body.String(), services.IsOptedOut(sessionTok, "6281200000001"))
+	}
+}
+
+func TestUpdateBotContactFilterRejectsUnknownMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/admin/bot/:userId/contact-filter", UpdateBotContactFilter)
+
+	body := bytes.NewBufferString(`{"mode":"whitelist","allowed":["6281200000001"]}`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/bot/user-1/contact-filter", body))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode = %d %s, want 400", rec.Code, rec.Body.String())
+	}
+}
+
+func pingWAServer(t *testing.T, query string) (int, services.WAPingResult) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/wa/ping", PingWAServer)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/wa/ping"+query, nil))
+	var resp struct {
+		Data services.WAPingResult `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v (%s)", err, rec.Body.String())
+	}
+	return rec.Code, resp.Data
+}
+
+func newMockWAServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/admin/users" && r.Header.Get("Authorization") == "admin-secret":
+			fmt.Fprint(w, `{"code":200,"data":[]}`)
+		case r.URL.Path == "/session/status" && r.Header.Get("token") == "session-ok":
+			fmt.Fprint(w, `{"code":200,"data":{"Connected":true}}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"code":401,"error":"unauthorized"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPingWAServerReachable(t *testing.T) {
+	server := newMockWAServer(t)
+	t.Setenv("WA_SERVER_URL", server.URL)
+	t.Setenv("WA_ADMIN_TOKEN", "admin-secret")
+
+	code, result := pingWAServer(t, "")
+	if code != http.StatusOK || !result.Reachable || !result.Authenticated || result.Check != "admin" {
+		t.Errorf("admin ping = %d %+v", code, result)
+	}
+
+	code, result = pingWAServer(t, "?token=session-ok")
+	if code != http.StatusOK || !result.Authenticated || result.Check != "session" {
+		t.Errorf("session ping = %d %+v", code, result)
+	}
+}
+
+func TestPingWAServerUnauthorized(t *testing.T) {
+	server := newMockWAServer(t)
+	t.Setenv("WA_SERVER_URL", server.URL)
+	t.Setenv("WA_ADMIN_TOKEN", "wrong-secret")
+
+	code, result := pingWAServer(t, "")
+	if code != http.StatusServiceUnavailable || !result.Reachable || result.Authenticated || result.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthorized ping = %d %+v", code, result)
+	}
+	if result.Error == "" {
+		t.Error("unauthorized ping should report the auth error")
+	}
+}
+
+func TestPingWAServerUnreachable(t *testing.T) {
+	server := newMockWAServer(t)
+	server.Close() // nothing listens on this address any more
+	t.Setenv("WA_SERVER_URL", server.URL)
+	t.Setenv("WA_ADMIN_TOKEN", "admin-secret")
+
+	code, result := pingWAServer(t, "")
+	if code != http.StatusServiceUnavailable || result.Reachable || result.Error == "" {
+		t.Errorf("unreachable ping = %d %+v", code, result)
+	}
+
+	t.Setenv("WA_SERVER_URL", "")
+	code, result = pingWAServer(t, "")
+	if code != http.StatusServiceUnavailable || result.Configured {
+		t.Errorf("unconfigured ping = %d %+v", code, result)
+	}
+}
+
+func TestGetUsageSummaryRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/usage", GetUsageSummary)
+
+	for _, query := range []string{"?from=yesterday", "?from=2026-03-10&to=2026-03-01", "?from=2024-01-01&to=2026-01-01"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/usage"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", query, rec.Code)
+		}
+	}
+
+	from, to, err := parseUsageRange("2026-03-01", "2026-03-31")
+	if err != nil || !to.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) || to.Sub(from) != 31*24*time.Hour {
+		t.Errorf("parseUsageRange = %v .. %v, %v", from, to, err)
+	}
+	if from, to, err := parseUsageRange("", ""); err != nil || to.Sub(from) != defaultUsageDays*24*time.Hour {
+		t.Errorf("default range = %v .. %v, %v", from, to, err)
+	}
+}
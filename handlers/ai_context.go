@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultContextWindow matches the message window the AI worker builds context with
+const defaultContextWindow = 10
+
+// GetAIContext returns the ai_chat_messages window the bot would use for a contact
+// GET /ai/context?sessionToken=&contact=&limit=
+func GetAIContext(c *gin.Context) {
+	sessionToken, contact, ok := resolveContextRequest(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultContextWindow
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"success": false,
+				"message": "limit must be a positive integer",
+			})
+			return
+		}
+		limit = parsed
+	}
+	if limit > services.MaxMessagesPerContact {
+		limit = services.MaxMessagesPerContact // nothing older is kept anyway
+	}
+
+	messages, err := services.GetChatHistoryForAI(sessionToken, contact, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "AI context retrieved",
+		"data": gin.H{
+			"contact":  contact,
+			"count":    len(messages),
+			"messages": messages,
+		},
+	})
+}
+
+// ClearAIContext purges the ai_chat_messages of a contact so the bot forgets the conversation
+// DELETE /ai/context?sessionToken=&contact=
+func ClearAIContext(c *gin.Context) {
+	sessionToken, contact, ok := resolveContextRequest(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := services.ClearAIChatContext(sessionToken, contact)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "AI context cleared",
+		"data": gin.H{
+			"contact": contact,
+			"deleted": deleted,
+		},
+	})
+}
+
+// resolveContextRequest validates the query and checks the caller owns the session.
+// The session comes from the authenticated token; sessionToken, if given, must match it.
+func resolveContextRequest(c *gin.Context) (string, string, bool) {
+	authToken := c.GetString("session_token")
+	sessionToken := c.Query("sessionToken")
+	if sessionToken == "" {
+		sessionToken = authToken
+	}
+	if sessionToken == "" || sessionToken != authToken {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"success": false,
+			"message": "Session does not belong to this token",
+		})
+		return "", "", false
+	}
+
+	contact := normalizeContactJID(c.Query("contact"))
+	if contact == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "contact is required",
+		})
+		return "", "", false
+	}
+
+	return sessionToken, contact, true
+}
+
+// normalizeContactJID accepts a bare phone number or a JID and returns the JID stored in ai_chat_messages
+func normalizeContactJID(contact string) string {
+	contact = strings.TrimSpace(contact)
+	if contact == "" || strings.Contains(contact, "@") {
+		return contact
+	}
+	return strings.TrimPrefix(contact, "+") + "@s.whatsapp.net"
+}
+
+// GetArchivedMedia returns the archived media file of a message received by the session
+// GET /ai/media/:messageId (MEDIA_STORE_BACKEND)
+func GetArchivedMedia(c *gin.Context) {
+	sessionToken := c.GetString("session_token")
+	messageID := c.Param("messageId")
+
+	archive, data, err := services.GetArchivedMedia(sessionToken, messageID)
+	if errors.Is(err, services.ErrMediaNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"success": false,
+			"message": "No archived media for this message",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": path.Base(archive.StorageKey)}))
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, archive.ContentType, data)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newContextRouter mounts the context endpoints behind a stub that plays SessionMiddleware
+func newContextRouter(authToken string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("session_token", authToken)
+		c.Next()
+	})
+	router.GET("/ai/context", GetAIContext)
+	router.DELETE("/ai/context", ClearAIContext)
+	return router
+}
+
+func TestAIContextRejectsForeignSession(t *testing.T) {
+	router := newContextRouter("token-owner")
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/ai/context?sessionToken=token-other&contact=628123", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s with foreign sessionToken = %d, want 403", method, rec.Code)
+		}
+	}
+}
+
+func TestAIContextRequiresContact(t *testing.T) {
+	router := newContextRouter("token-owner")
+
+	req := httptest.NewRequest(http.MethodGet, "/ai/context?sessionToken=token-owner", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing contact = %d, want 400", rec.Code)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["success"] != false {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestAIContextRejectsInvalidLimit(t *testing.T) {
+	router := newContextRouter("token-owner")
+
+	req := httptest.NewRequest(http.MethodGet, "/ai/context?contact=628123&limit=abc", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit = %d, want 400", rec.Code)
+	}
+}
+
+func TestNormalizeContactJID(t *testing.T) {
+	tests := map[string]string{
+		"6281234567890":                 "6281234567890@s.whatsapp.net",
+		"+6281234567890":                "6281234567890@s.whatsapp.net",
+		" 6281234567890@s.whatsapp.net": "6281234567890@s.whatsapp.net",
+		"120363000000000000@g.us":       "120363000000000000@g.us",
+		"":                              "",
+	}
+	for in, want := range tests {
+		if got := normalizeContactJID(in); got != want {
+			t.Errorf("normalizeContactJID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
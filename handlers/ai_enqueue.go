@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"genfity-wa-support/logger"
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enqueueAIReplyRequest is the body of POST /admin/ai/enqueue
+type enqueueAIReplyRequest struct {
+	SessionToken string `json:"sessionToken" binding:"required"`
+	ContactJID   string `json:"contactJID" binding:"required"` // JID or phone number
+	Message      string `json:"message" binding:"required"`
+}
+
+// EnqueueAIReply makes the bot answer a contact on demand, e.g. again after a knowledge base fix.
+// POST /admin/ai/enqueue {"sessionToken","contactJID","message"}
+// The message is stored in ai_chat_messages as if the contact had sent it and an AI job is queued
+// like the webhook does, so the normal pipeline sends the reply. It is not added to the permanent
+// chat history - the contact never actually wrote it.
+func EnqueueAIReply(c *gin.Context) {
+	var req enqueueAIReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "sessionToken, contactJID and message are required",
+		})
+		return
+	}
+
+	phone, err := services.ValidatePhone(req.ContactJID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	contactJID := normalizeContactJID(phone)
+
+	sessionInfo, err := services.ResolveSession(req.SessionToken)
+	if errors.Is(err, services.ErrSessionUserMismatch) {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"success": false,
+			"message": "Session not found: " + err.Error(),
+		})
+		return
+	}
+	if !sessionInfo.BotActive || !sessionInfo.SubscriptionActive {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"success": false,
+			"message": "Bot or subscription inactive for this session - the job would not be answered",
+		})
+		return
+	}
+
+	now := time.Now()
+	messageID := fmt.Sprintf("manual-%d", now.UnixNano())
+	if err := services.SaveIncomingMessageToAIChat(req.SessionToken, messageID, contactJID, contactJID, req.Message, "", now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	contact := services.NormalizePhone(contactJID)
+	aiJob, err := enqueueAIJob(req.SessionToken, messageID, sessionInfo.UserID, contact, req.Message,
+		logger.RequestIDFrom(c.Request.Context()), services.JobPriority(sessionInfo.PackageName, req.Message), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": "Failed to enqueue job: " + err.Error(),
+		})
+		return
+	}
+
+	log.Printf("🛠️  [Admin] Manual AI reply queued for %s on session %s (job #%d)", contact, req.SessionToken, aiJob.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "AI reply queued",
+		"data": gin.H{
+			"message_id": messageID,
+			"job_id":     aiJob.ID,
+		},
+	})
+}
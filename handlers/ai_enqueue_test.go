@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEnqueueAIReplyValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/ai/enqueue", EnqueueAIReply)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing message", `{"sessionToken":"s1","contactJID":"6281234567890@s.whatsapp.net"}`, "are required"},
+		{"missing contact", `{"sessionToken":"s1","message":"halo"}`, "are required"},
+		{"invalid contact", `{"sessionToken":"s1","contactJID":"not-a-number","message":"halo"}`, "invalid phone number"},
+		{"local number", `{"sessionToken":"s1","contactJID":"081234567890","message":"halo"}`, "invalid phone number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/ai/enqueue", strings.NewReader(tt.body)))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400 (%s)", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body = %s, want it to mention %q", rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
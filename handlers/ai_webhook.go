@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"genfity-wa-support/database"
+	"genfity-wa-support/logger"
 	"genfity-wa-support/models"
 	"genfity-wa-support/services"
 
@@ -16,6 +19,7 @@ import (
 // WebhookPayload struktur dari WA Service
 type WebhookPayload struct {
 	InstanceName string `json:"instanceName"`
+	Type         string `json:"type"` // "Message" (or empty) | Connected | Disconnected | LoggedOut
 	Event        struct {
 		Info struct {
 			ID        string    `json:"ID"`
@@ -25,58 +29,105 @@ type WebhookPayload struct {
 			PushName  string    `json:"PushName"`
 			Timestamp time.Time `json:"Timestamp"`
 			IsFromMe  bool      `json:"IsFromMe"`
+			MediaType string    `json:"MediaType"` // image | video | audio | document | sticker (Type = "media")
 		} `json:"Info"`
 		Message struct {
 			ExtendedTextMessage struct {
-				Text string `json:"text"`
+				Text        string             `json:"text"`
+				ContextInfo webhookContextInfo `json:"contextInfo"`
 			} `json:"extendedTextMessage"`
-			Conversation string `json:"conversation"`
+			Conversation    string `json:"conversation"`
+			ReactionMessage struct {
+				Key struct {
+					ID        string `json:"ID"`
+					RemoteJID string `json:"remoteJID"`
+					FromMe    bool   `json:"fromMe"`
+				} `json:"key"`
+				Text string `json:"text"` // emoji, kosong = reaksi dihapus
+			} `json:"reactionMessage"`
+			ImageMessage    webhookMediaMessage `json:"imageMessage"`
+			VideoMessage    webhookMediaMessage `json:"videoMessage"`
+			DocumentMessage webhookMediaMessage `json:"documentMessage"`
 		} `json:"Message"`
 	} `json:"event"`
 }
 
-// cleanJID removes device suffix from WhatsApp JID
-// Example: "6281233784490:24@s.whatsapp.net" → "6281233784490@s.whatsapp.net"
-func cleanJID(jid string) string {
-	// Remove device suffix (:24, :26, etc)
-	if strings.Contains(jid, ":") {
-		parts := strings.Split(jid, ":")
-		if len(parts) >= 2 {
-			// Get phone number part and domain part
-			phonePart := parts[0]
-			domainPart := parts[len(parts)-1] // Get last part (e.g., "24@s.whatsapp.net")
-
-			// Extract domain (everything after @)
-			if strings.Contains(domainPart, "@") {
-				domain := domainPart[strings.Index(domainPart, "@"):]
-				return phonePart + domain
-			}
-		}
+// webhookContextInfo holds reply (quoted message) info of an extended text message.
+// Only string fields are parsed so unexpected shapes of other fields don't break binding.
+type webhookContextInfo struct {
+	StanzaID      string `json:"stanzaID"`
+	Participant   string `json:"participant"`
+	QuotedMessage struct {
+		Conversation        string `json:"conversation"`
+		ExtendedTextMessage struct {
+			Text string `json:"text"`
+		} `json:"extendedTextMessage"`
+	} `json:"quotedMessage"`
+}
+
+// webhookMediaMessage holds the caption of an image/video/document message
+type webhookMediaMessage struct {
+	Caption string `json:"caption"`
+}
+
+// quotedText returns the text of the quoted message, if any
+func (ci webhookContextInfo) quotedText() string {
+	if ci.QuotedMessage.ExtendedTextMessage.Text != "" {
+		return ci.QuotedMessage.ExtendedTextMessage.Text
 	}
-	return jid
+	return ci.QuotedMessage.Conversation
 }
 
+// resolveWebhookSession maps the webhook's session token to its user and bot (stubbed in tests)
+var resolveWebhookSession = services.ResolveSession
+
+// webhookReplayKey marks a gin context as an admin replay of a stored payload
+const webhookReplayKey = "webhook_replay"
+
 // HandleAIWebhook processes incoming WhatsApp messages for AI bot
 func HandleAIWebhook(c *gin.Context) {
+	rawBody, err := c.GetRawData()
+	if err != nil {
+		log.Printf("Failed to read webhook body: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
 	var payload WebhookPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := json.Unmarshal(rawBody, &payload); err != nil {
 		log.Printf("Invalid webhook payload: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
 		return
 	}
 
+	// Connection events update the session state; they carry no message
+	if services.IsSessionEvent(payload.Type) {
+		handleSessionEvent(c, payload.InstanceName, payload.Type)
+		return
+	}
+
+	// Replays (admin) re-run a stored payload: don't store it again and skip the age filter
+	isReplay := c.GetBool(webhookReplayKey)
+
+	// Optional raw payload storage for debugging / replay (AI_STORE_RAW_WEBHOOKS)
+	if !isReplay && services.ShouldStoreRawWebhooks() {
+		if err := services.SaveRawWebhook(payload.Event.Info.ID, payload.InstanceName, rawBody); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+
 	// 1. Extract message data
 	sessionToken := payload.InstanceName
 	messageID := payload.Event.Info.ID
-	from := cleanJID(payload.Event.Info.Sender) // Clean device suffix
+	from := services.NormalizeJID(payload.Event.Info.Sender) // Clean device suffix
 	to := payload.Event.Info.Chat
 	msgType := payload.Event.Info.Type
 	pushName := payload.Event.Info.PushName
 	timestamp := payload.Event.Info.Timestamp
 	fromMe := payload.Event.Info.IsFromMe
 
-	log.Printf("📨 Webhook received: session=%s, from=%s, type=%s, fromMe=%v",
-		sessionToken, from, msgType, fromMe)
+	reqLog := logger.FromContext(c.Request.Context()).With("session", sessionToken, "message_id", messageID)
+	reqLog.Info("webhook received", "from", from, "type", msgType, "from_me", fromMe)
 
 	// Skip pesan dari diri sendiri
 	if fromMe {
@@ -92,10 +143,41 @@ func HandleAIWebhook(c *gin.Context) {
 		body = payload.Event.Message.Conversation
 	}
 
-	// Only process text messages for now
-	if msgType != "text" || strings.TrimSpace(body) == "" {
-		log.Printf("Non-text message ignored: type=%s", msgType)
-		c.JSON(http.StatusOK, gin.H{"message": "Non-text message ignored"})
+	// Reply/reaction context
+	quotedID := payload.Event.Message.ExtendedTextMessage.ContextInfo.StanzaID
+	quotedBody := payload.Event.Message.ExtendedTextMessage.ContextInfo.quotedText()
+
+	reaction := payload.Event.Message.ReactionMessage
+	if reaction.Key.ID != "" {
+		if strings.TrimSpace(reaction.Text) == "" {
+			c.JSON(http.StatusOK, gin.H{"message": "Reaction removal ignored"})
+			return
+		}
+		msgType = "reaction"
+		body = reaction.Text
+		quotedID = reaction.Key.ID
+		quotedBody = services.GetAIChatMessageBody(sessionToken, reaction.Key.ID)
+		if quotedBody == "" && reaction.Key.FromMe {
+			// Older bot replies were stored with generated IDs - assume the latest one we sent
+			if last, err := services.GetLastOutgoingMessage(sessionToken, from); err == nil && last != nil {
+				quotedBody = last.Body
+			}
+		}
+	}
+
+	// Media: route by media type, caption (if any) becomes the body
+	if msgType == "media" {
+		if mediaType := strings.ToLower(payload.Event.Info.MediaType); mediaType != "" {
+			msgType = mediaType
+		}
+		body = firstNonEmpty(
+			payload.Event.Message.ImageMessage.Caption,
+			payload.Event.Message.VideoMessage.Caption,
+			payload.Event.Message.DocumentMessage.Caption,
+		)
+	} else if strings.TrimSpace(body) == "" {
+		log.Printf("Empty message ignored: type=%s", msgType)
+		c.JSON(http.StatusOK, gin.H{"message": "Empty message ignored"})
 		return
 	}
 
@@ -104,14 +186,19 @@ func HandleAIWebhook(c *gin.Context) {
 	// 1b. Filter old messages (prevent history replay)
 	// Only process messages from last 5 minutes
 	messageAge := time.Since(timestamp)
-	if messageAge > 5*time.Minute {
+	if messageAge > 5*time.Minute && !isReplay {
 		log.Printf("⏭️  Skipped old message: age=%v, messageID=%s", messageAge, messageID)
 		c.JSON(http.StatusOK, gin.H{"message": "Old message ignored"})
 		return
 	}
 
 	// 2. Resolve session → user (call Transactional API)
-	sessionInfo, err := services.ResolveSession(sessionToken)
+	sessionInfo, err := resolveWebhookSession(sessionToken)
+	if errors.Is(err, services.ErrSessionUserMismatch) {
+		log.Printf("🚨 Skipping message %s: %v", messageID, err)
+		c.JSON(http.StatusOK, gin.H{"message": "Session/user mismatch"})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to resolve session %s: %v", sessionToken, err)
 		c.JSON(http.StatusOK, gin.H{"message": "Session not found", "error": err.Error()})
@@ -121,6 +208,22 @@ func HandleAIWebhook(c *gin.Context) {
 	log.Printf("✓ Session resolved: userID=%s, botActive=%v, subscriptionActive=%v",
 		sessionInfo.UserID, sessionInfo.BotActive, sessionInfo.SubscriptionActive)
 
+	// 2b. Customer's own copy of the message (forward URL), whether or not the bot answers it: before
+	// every AI-specific short-circuit (inactive bot, opt-out, routing, handoff, ...)
+	if sessionInfo.SubscriptionActive && !isReplay {
+		services.ForwardIncomingMessage(sessionInfo, services.ForwardedMessage{
+			MessageID:       messageID,
+			From:            services.NormalizePhone(from),
+			Chat:            to,
+			PushName:        pushName,
+			Type:            msgType,
+			Body:            body,
+			QuotedMessageID: quotedID,
+			QuotedBody:      quotedBody,
+			Timestamp:       timestamp,
+		})
+	}
+
 	// 3. Guard: Bot active & subscription active
 	if !sessionInfo.BotActive {
 		log.Printf("Bot inactive for session %s", sessionToken)
@@ -134,10 +237,40 @@ func HandleAIWebhook(c *gin.Context) {
 		return
 	}
 
+	// 3b. Size guard: a pasted wall of text blows the context budget (MAX_INCOMING_CHARS)
+	if limited, overLimit := services.LimitIncomingBody(body); overLimit {
+		log.Printf("✂️  Message %s over MAX_INCOMING_CHARS (%d chars, mode=%s)",
+			messageID, len([]rune(body)), services.IncomingOverflowMode())
+		if services.ShouldReplyIncomingTooLong() {
+			go func() {
+				if err := services.SendFallbackReply(sessionToken, to, from, services.IncomingTooLongMessage()); err != nil {
+					log.Printf("⚠️  Failed to send message-too-long reply: %v", err)
+				}
+			}()
+		}
+		if services.IncomingOverflowMode() == services.IncomingOverflowReject {
+			c.JSON(http.StatusOK, gin.H{"message": "Message too long"})
+			return
+		}
+		body = limited
+	}
+
 	// 4. Save incoming message (idempotency via unique messageID)
 	// Also triggers auto-cleanup (keep last 20 messages per contact)
-	phoneNumber := strings.Split(from, "@")[0] // Extract phone number without @s.whatsapp.net
-	if err := services.SaveIncomingMessageToAIChat(sessionToken, messageID, from, to, body, pushName, timestamp); err != nil {
+	phoneNumber := services.NormalizePhone(from) // Phone number without @s.whatsapp.net
+	incoming := models.AIChatMessage{
+		MessageID:       messageID,
+		SessionTok:      sessionToken,
+		From:            from,
+		To:              to,
+		MsgType:         msgType,
+		Body:            body,
+		PushName:        pushName,
+		Timestamp:       timestamp,
+		QuotedMessageID: quotedID,
+		QuotedBody:      quotedBody,
+	}
+	if err := services.SaveIncomingAIChatMessage(&incoming); err != nil {
 		// Check if duplicate
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "UNIQUE constraint") {
 			log.Printf("Duplicate message %s - skipped", messageID)
@@ -151,39 +284,306 @@ func HandleAIWebhook(c *gin.Context) {
 
 	log.Printf("✓ Message saved to ai_chat_messages (contact: %s)", phoneNumber)
 
-	// 4b. Save to permanent chat history (ChatRoom + ChatMessage)
+	// 4a. Save to permanent chat history (ChatRoom + ChatMessage) and archive media, whatever the
+	// route below: "ignore" means no AI reply, not hidden from the agent
+	historyBody := body
+	if historyBody == "" {
+		historyBody = "[" + msgType + "]"
+	}
+	// Failed writes are retried in the background (AI_HISTORY_RETRY_*)
 	go func() {
-		if err := services.SaveToChatHistory(sessionToken, from, to, body, pushName, timestamp, false); err != nil {
+		historyType := "text"
+		if msgType == "reaction" {
+			historyType = msgType
+		}
+		var onSaved func(*models.ChatMessage)
+		if services.IsMediaMessageType(msgType) {
+			historyType = msgType
+			// Keep the file for the agent UI (MEDIA_STORE_BACKEND), linked from the chat message's media_data
+			if services.MediaStoreEnabled() {
+				onSaved = func(chatMessage *models.ChatMessage) {
+					if err := services.ArchiveIncomingMedia(sessionToken, messageID, msgType, chatMessage); err != nil {
+						log.Printf("⚠️  %v", err)
+					}
+				}
+			}
+		}
+		if err := services.SaveToChatHistoryWithRetry(sessionToken, from, to, historyBody, pushName, historyType, timestamp, false, onSaved); err != nil {
 			log.Printf("⚠️  Failed to save to chat history: %v", err)
 		}
 	}()
 
-	// 5. Enqueue AI job
-	db := database.GetDB()
+	// 4b. Opt-out: "STOP" / "BERHENTI" suppresses AI replies and bulk campaigns to this contact.
+	// The message itself (and later ones) stay in the inbox and are still forwarded - only the
+	// AI reply is skipped.
+	if keyword, ok := services.MatchOptOutKeyword(body); ok {
+		if err := services.RecordOptOut(sessionToken, from, keyword, messageID); err != nil {
+			log.Printf("⚠️  %v", err)
+		} else {
+			log.Printf("🚫 Contact %s opted out of session %s (keyword %q)", from, sessionToken, keyword)
+		}
+		if services.ShouldConfirmOptOut() {
+			go func() {
+				if err := services.SendFallbackReply(sessionToken, to, from, services.OptOutConfirmMessage()); err != nil {
+					log.Printf("⚠️  Failed to send opt-out confirmation: %v", err)
+				}
+			}()
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Contact opted out"})
+		return
+	}
+	if services.IsOptedOut(sessionToken, from) {
+		log.Printf("⏭️  Message from opted-out contact %s stored - no AI reply", from)
+		c.JSON(http.StatusOK, gin.H{"message": "Contact opted out"})
+		return
+	}
+
+	// 4c. Route by message type (per-bot messageTypeHandling, see services.ResolveMessageRoute)
+	botSettings := loadBotSettingsForRouting(sessionInfo.UserID, sessionToken)
+	route := services.ResolveMessageRoute(botSettings, msgType)
+
+	if route == services.RouteIgnore {
+		// Stored for context and history only (e.g. media, reactions unless AI_REPLY_TO_REACTIONS)
+		log.Printf("⏭️  %s message stored (no AI reply)", msgType)
+		c.JSON(http.StatusOK, gin.H{"message": "Message stored", "route": route})
+		return
+	}
+
+	// 4d. Contact filter: numbers outside the bot's allowlist (or on its denylist) are kept in
+	// history but get no AI or fallback reply
+	if !services.IsContactPermitted(botSettings, from) {
+		log.Printf("🚫 Contact %s not permitted by bot contact filter - no AI reply", phoneNumber)
+		c.JSON(http.StatusOK, gin.H{"message": "Contact not allowed", "route": "contact_filter"})
+		return
+	}
+
+	// 4e. Automated senders (WhatsApp system messages, OTP / notification services): stored, never answered
+	if reason, automated := services.MatchAutomatedSender(from, to, body); automated {
+		log.Printf("🤖 Message %s from %s looks automated (%s) - no AI reply", messageID, phoneNumber, reason)
+		c.JSON(http.StatusOK, gin.H{"message": "Automated sender ignored", "route": "automated"})
+		return
+	}
+
+	// 4f. Loop guard: the contact echoing our last reply back is another bot's auto-responder
+	if services.IsEchoOfLastReply(sessionToken, from, body) {
+		log.Printf("🔁 Message %s from %s repeats our last reply - suspected bot loop, no AI reply", messageID, phoneNumber)
+		c.JSON(http.StatusOK, gin.H{"message": "Loop suspected", "route": "loop_guard"})
+		return
+	}
+
+	// 4g. Human handoff: a conversation an agent took over gets no AI reply until it is returned to
+	// the bot (DELETE /admin/handoffs). The customer typing a handoff keyword ("agent", "manusia")
+	// starts it.
+	if services.IsInHandoff(sessionToken, from) {
+		log.Printf("🙋 Message %s from %s left for the human agent (handoff)", messageID, phoneNumber)
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation handed off", "route": "handoff"})
+		return
+	}
+	if keyword, ok := services.MatchHandoffKeyword(body); ok {
+		started, err := services.StartHandoff(sessionToken, from, services.HandoffReasonKeyword+":"+keyword, messageID)
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+		if started {
+			go func() {
+				if err := services.SendFallbackReply(sessionToken, to, from, services.HandoffMessage()); err != nil {
+					log.Printf("⚠️  Failed to send handoff reply: %v", err)
+				}
+				services.NotifyHandoff(botSettings, sessionToken, from, pushName, services.HandoffReasonKeyword, historyBody)
+			}()
+		}
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Conversation handed off", "route": "handoff"})
+			return
+		}
+	}
+
+	switch route {
+	case services.RouteHandoff:
+		// Same handoff state as the keyword: listed for agents, no AI reply until returned to the bot
+		reason := services.HandoffReasonMessageType + ":" + msgType
+		started, err := services.StartHandoff(sessionToken, from, reason, messageID)
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+			c.JSON(http.StatusOK, gin.H{"message": "Handoff failed, no AI reply", "route": route})
+			return
+		}
+		log.Printf("🙋 %s message from %s handed off to a human", msgType, phoneNumber)
+		if started {
+			go services.NotifyHandoff(botSettings, sessionToken, from, pushName, reason, historyBody)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation handed off", "route": route})
+		return
+	case services.RouteFallback:
+		go func(fallbackText string) {
+			if err := services.SendFallbackReply(sessionToken, to, from, fallbackText); err != nil {
+				log.Printf("⚠️  Fallback reply for %s message failed: %v", msgType, err)
+			}
+		}(botSettings.FallbackText)
+		c.JSON(http.StatusOK, gin.H{"message": "Fallback reply sent", "route": route})
+		return
+	}
+
+	// 4h. Business hours: outside the bot's schedule send the after-hours message, no LLM call
+	if !services.IsWithinBusinessHours(botSettings, time.Now()) {
+		log.Printf("🌙 Message %s from %s outside business hours - no AI reply", messageID, phoneNumber)
+		go func() {
+			afterHours := services.AfterHoursMessage(botSettings)
+			// One after-hours notice per contact per dedupe window, not one per message
+			if services.IsDuplicateReply(sessionToken, from, afterHours) {
+				return
+			}
+			if err := services.SendFallbackReply(sessionToken, to, from, afterHours); err != nil {
+				log.Printf("⚠️  Failed to send after-hours reply: %v", err)
+			}
+		}()
+		c.JSON(http.StatusOK, gin.H{"message": "After hours", "route": "after_hours"})
+		return
+	}
+
+	// 4i. Backpressure: reply with a busy message instead of growing an overloaded queue
+	if services.IsQueueOverloaded() {
+		stats := services.GetQueueStats()
+		log.Printf("🚨 Queue overloaded (%d pending > %d) - not enqueuing message %s", stats.Pending, stats.Threshold, messageID)
+		go func() {
+			busyMessage := services.QueueBusyMessage()
+			// One busy notice per contact per dedupe window, not one per message
+			if services.IsDuplicateReply(sessionToken, from, busyMessage) {
+				return
+			}
+			if err := services.SendFallbackReply(sessionToken, to, from, busyMessage); err != nil {
+				log.Printf("⚠️  Failed to send busy reply: %v", err)
+			}
+		}()
+		c.JSON(http.StatusOK, gin.H{"message": "Queue overloaded", "pending": stats.Pending})
+		return
+	}
+
+	// 5. Enqueue AI job (higher subscription tier or urgent keywords = lower priority number = processed first)
+	priority := services.JobPriority(sessionInfo.PackageName, body)
+
+	// 5a. Debounce (AI_DEBOUNCE_MS): a message arriving while the contact's job is still waiting
+	// is merged into it, so "hi" / "I want" / "a website" / "how much?" get one reply
+	var nextRunAt *time.Time
+	if debounce := services.DebounceWindow(); debounce > 0 {
+		mergedJob, merged, err := services.MergeIntoPendingJob(sessionToken, phoneNumber, messageID, body, priority, debounce)
+		if err != nil {
+			log.Printf("⚠️  %v - enqueuing message %s on its own", err, messageID)
+		} else if merged {
+			reqLog.Info("message merged into pending job", "job_id", mergedJob.ID,
+				"job_request_id", mergedJob.RequestID, "debounce", debounce.String())
+			c.JSON(http.StatusOK, gin.H{
+				"status":     "merged",
+				"message_id": messageID,
+				"job_id":     mergedJob.ID,
+			})
+			return
+		}
+		runAt := time.Now().Add(debounce)
+		nextRunAt = &runAt
+	}
+
+	// 5b. Daily reply cap per contact (per-bot maxDailyRepliesPerContact): a loop that slips past
+	// the echo check still stops once the contact has had a day's worth of replies
+	if count, allowed, err := services.ReserveDailyReply(sessionToken, from, services.DailyReplyCap(botSettings)); err != nil {
+		log.Printf("⚠️  %v", err)
+	} else if !allowed {
+		log.Printf("🛑 Message %s from %s over the daily reply cap (%d today) - no AI reply", messageID, phoneNumber, count)
+		c.JSON(http.StatusOK, gin.H{"message": "Daily reply cap reached", "route": "reply_cap"})
+		return
+	}
+
+	// 5c. The job is billed to this user - make sure the session still maps to them
+	if err := services.VerifySessionOwner(sessionToken, sessionInfo.UserID); err != nil {
+		log.Printf("🚨 Not enqueuing message %s: %v", messageID, err)
+		c.JSON(http.StatusOK, gin.H{"message": "Session/user mismatch"})
+		return
+	}
+
+	// 5d. First contact: a brand-new contact gets the bot's greeting. Only claimed here - the job
+	// sends it right before the AI reply (which then continues from it), so the webhook isn't held
+	// up by the send and after-hours / capped messages don't get a greeting on top
+	if greeting := services.GreetingMessage(botSettings); greeting != "" && services.ClaimFirstContactGreeting(sessionToken, from, messageID, greeting) {
+		log.Printf("👋 First message from %s - greeting queued with the AI job", phoneNumber)
+	}
+
+	aiJob, err := enqueueAIJob(sessionToken, messageID, sessionInfo.UserID, phoneNumber, body,
+		logger.RequestIDFrom(c.Request.Context()), priority, nextRunAt)
+	if err != nil {
+		log.Printf("Failed to enqueue AI job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue job"})
+		return
+	}
+
+	reqLog.Info("ai job queued", "job_id", aiJob.ID, "priority", aiJob.Priority)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "queued",
+		"message_id": messageID,
+		"job_id":     aiJob.ID,
+	})
+}
+
+// enqueueAIJob creates the pending job that answers a saved incoming message. The NOTIFY
+// trigger on ai_jobs wakes the worker automatically.
+func enqueueAIJob(sessionToken, messageID, userID, contact, body, requestID string, priority int, nextRunAt *time.Time) (*models.AIJob, error) {
 	aiJob := models.AIJob{
 		Status:     "pending",
-		Priority:   5,
+		Priority:   priority,
 		SessionTok: sessionToken,
 		MessageID:  messageID,
-		UserID:     sessionInfo.UserID,
+		UserID:     userID,
+		Contact:    contact,
+		RequestID:  requestID,
 		InputJSON:  body,
 		Attempts:   0,
+		NextRunAt:  nextRunAt,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
+	if err := database.GetDB().Create(&aiJob).Error; err != nil {
+		return nil, err
+	}
+	return &aiJob, nil
+}
 
-	if err := db.Create(&aiJob).Error; err != nil {
-		log.Printf("Failed to enqueue AI job: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue job"})
+// handleSessionEvent applies a Connected / Disconnected / LoggedOut event to the session row
+func handleSessionEvent(c *gin.Context, sessionToken, eventType string) {
+	log.Printf("🔌 Session event %s for session %s", eventType, sessionToken)
+	if err := services.ApplySessionEvent(sessionToken, eventType); err != nil {
+		log.Printf("⚠️  Failed to apply session event %s: %v", eventType, err)
+		if errors.Is(err, services.ErrUnknownSession) {
+			c.JSON(http.StatusOK, gin.H{"message": "Session not found", "event": eventType})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session state"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session state updated", "event": eventType})
+}
 
-	// NOTIFY trigger will fire automatically via PostgreSQL trigger
-	log.Printf("✅ Job #%d queued for AI processing (message: %s)", aiJob.ID, messageID)
+// loadBotSettingsForRouting fetches bot settings for message-type routing (nil = defaults on error;
+// a var so tests can stub it)
+var loadBotSettingsForRouting = func(userID, sessionToken string) *services.BotSettings {
+	provider, err := services.GetDataProvider()
+	if err != nil {
+		log.Printf("⚠️  Failed to get data provider for routing: %v", err)
+		return nil
+	}
+	botSettings, err := services.GetBotSettingsWithFallback(provider, userID, sessionToken)
+	if err != nil {
+		log.Printf("⚠️  Failed to load bot settings for routing, using defaults: %v", err)
+		return nil
+	}
+	return botSettings
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":     "queued",
-		"message_id": messageID,
-		"job_id":     aiJob.ID,
-	})
+// firstNonEmpty returns the first non-blank value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
 }
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubWebhookSession makes the webhook resolve every session token to an active bot
+func stubWebhookSession(t *testing.T, info *services.SessionInfo) {
+	t.Helper()
+	previous := resolveWebhookSession
+	t.Cleanup(func() { resolveWebhookSession = previous })
+	resolveWebhookSession = func(sessionToken string) (*services.SessionInfo, error) {
+		return info, nil
+	}
+}
+
+func postAIWebhook(payload string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhook/ai", HandleAIWebhook)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook/ai", bytes.NewBufferString(payload)))
+	return rec
+}
+
+func TestIncomingMediaArchivedWithDefaultRouting(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.DB, &models.AIChatMessage{}, &models.ChatRoom{}, &models.ChatMessage{}, &models.MediaArchive{})
+	stubWebhookSession(t, &services.SessionInfo{UserID: "u-media", BotActive: true, SubscriptionActive: true})
+
+	png := []byte("\x89PNG\r\n\x1a\n fake image bytes")
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}))
+	defer waServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	t.Setenv("MEDIA_STORE_BACKEND", "local")
+	t.Setenv("MEDIA_LOCAL_DIR", t.TempDir())
+
+	sessionTok := fmt.Sprintf("test_media_%d", time.Now().UnixNano())
+	messageID := fmt.Sprintf("TESTIMG%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.MediaArchive{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+
+	// Media routes to "ignore" by default: no AI reply, but the file is still archived
+	if route := services.ResolveMessageRoute(nil, "image"); route != services.RouteIgnore {
+		t.Fatalf("default image route = %q, test assumes %q", route, services.RouteIgnore)
+	}
+	rec := postAIWebhook(fmt.Sprintf(`{"instanceName":%q,"event":{"Info":{"ID":%q,"Sender":"6281200000007@s.whatsapp.net","Chat":"6281200000007@s.whatsapp.net","Type":"media","MediaType":"image","Timestamp":%q},"Message":{"imageMessage":{"caption":"bukti transfer"}}}}`,
+		sessionTok, messageID, time.Now().Format(time.RFC3339)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("webhook status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// History and archive are written in the background
+	var archive models.MediaArchive
+	deadline := time.Now().Add(5 * time.Second)
+	for db.Where("session_tok = ? AND message_id = ?", sessionTok, messageID).First(&archive).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("no media_archives row for the incoming image")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if archive.MediaType != "image" || archive.ContentType != "image/png" || archive.ChatMessageID == 0 {
+		t.Errorf("archive = %+v", archive)
+	}
+
+	var chatMessage models.ChatMessage
+	if err := db.First(&chatMessage, archive.ChatMessageID).Error; err != nil {
+		t.Fatalf("archived media not linked to a chat message: %v", err)
+	}
+	if chatMessage.MessageType != "image" || chatMessage.Content != "bukti transfer" {
+		t.Errorf("chat message type=%q content=%q", chatMessage.MessageType, chatMessage.Content)
+	}
+}
+
+func TestMessageTypeHandoffRoute(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.DB, &models.AIChatMessage{}, &models.ChatRoom{}, &models.ChatMessage{}, &models.ContactHandoff{})
+	stubWebhookSession(t, &services.SessionInfo{UserID: "u-handoff", BotActive: true, SubscriptionActive: true})
+	previous := loadBotSettingsForRouting
+	t.Cleanup(func() { loadBotSettingsForRouting = previous })
+	loadBotSettingsForRouting = func(userID, sessionToken string) *services.BotSettings {
+		return &services.BotSettings{MessageTypeHandling: map[string]string{"image": services.RouteHandoff}}
+	}
+
+	sessionTok := fmt.Sprintf("test_handoff_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.ContactHandoff{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+
+	rec := postAIWebhook(fmt.Sprintf(`{"instanceName":%q,"event":{"Info":{"ID":"%s_img","Sender":"6281200000008@s.whatsapp.net","Chat":"6281200000008@s.whatsapp.net","Type":"media","MediaType":"image","Timestamp":%q}}}`,
+		sessionTok, sessionTok, time.Now().Format(time.RFC3339)))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"route":"handoff"`)) {
+		t.Fatalf("webhook = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The image hands the conversation off like the keyword does: agents see it, the bot stays quiet
+	var handoff models.ContactHandoff
+	if err := db.Where("session_tok = ?", sessionTok).First(&handoff).Error; err != nil {
+		t.Fatalf("no handoff recorded: %v", err)
+	}
+	if handoff.Contact != "6281200000008" || handoff.Reason != services.HandoffReasonMessageType+":image" {
+		t.Errorf("handoff = %+v", handoff)
+	}
+	if !services.IsInHandoff(sessionTok, "6281200000008@s.whatsapp.net") {
+		t.Error("conversation not in handoff after a handoff-routed image")
+	}
+}
+
+func TestOptOutKeepsMessagesInInbox(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.DB, &models.AIChatMessage{}, &models.ChatRoom{}, &models.ChatMessage{}, &models.ContactOptOut{}, &models.AIJob{})
+	t.Setenv("AI_OPT_OUT_CONFIRM_REPLY", "false")
+	sessionTok := fmt.Sprintf("test_optout_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.ContactOptOut{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIJob{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+	message := func(id, text string) string {
+		return fmt.Sprintf(`{"instanceName":%q,"event":{"Info":{"ID":"%s_%s","Sender":"6281200000009@s.whatsapp.net","Chat":"6281200000009@s.whatsapp.net","Type":"text","Timestamp":%q},"Message":{"conversation":%q}}}`,
+			sessionTok, sessionTok, id, time.Now().Format(time.RFC3339), text)
+	}
+
+	// An inactive bot doesn't act on the keyword
+	stubWebhookSession(t, &services.SessionInfo{UserID: "u-optout", BotActive: false, SubscriptionActive: true})
+	postAIWebhook(message("inactive", "STOP"))
+	if services.IsOptedOut(sessionTok, "6281200000009@s.whatsapp.net") {
+		t.Fatal("opt-out recorded for a session whose bot is inactive")
+	}
+
+	stubWebhookSession(t, &services.SessionInfo{UserID: "u-optout", BotActive: true, SubscriptionActive: true})
+	for _, m := range []struct{ id, text string }{{"stop", "STOP"}, {"later", "halo, masih buka?"}} {
+		rec := postAIWebhook(message(m.id, m.text))
+		if !bytes.Contains(rec.Body.Bytes(), []byte("Contact opted out")) {
+			t.Fatalf("%s: webhook = %d %s", m.id, rec.Code, rec.Body.String())
+		}
+	}
+	if !services.IsOptedOut(sessionTok, "6281200000009@s.whatsapp.net") {
+		t.Fatal("STOP did not opt the contact out")
+	}
+
+	// Both messages reach the agent inbox, neither gets a job
+	var count int64
+	db.Model(&models.AIChatMessage{}).Where("session_tok = ? AND message_id IN ?", sessionTok,
+		[]string{sessionTok + "_stop", sessionTok + "_later"}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d of 2 messages stored in ai_chat_messages", count)
+	}
+	// The chat history is written in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		db.Model(&models.ChatMessage{}).Where("user_token = ?", sessionTok).Count(&count)
+		if count == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of 2 messages in the chat history", count)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	db.Model(&models.AIJob{}).Where("session_tok = ?", sessionTok).Count(&count)
+	if count != 0 {
+		t.Errorf("%d AI jobs enqueued for an opted-out contact", count)
+	}
+}
+
+func TestOptedOutMessagesStillForwarded(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.DB, &models.AIChatMessage{}, &models.ChatRoom{}, &models.ChatMessage{}, &models.ContactOptOut{})
+	t.Setenv("AI_OPT_OUT_CONFIRM_REPLY", "false")
+	sessionTok := fmt.Sprintf("test_forward_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.ContactOptOut{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+
+	forwarded := make(chan string, 2)
+	crm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Get(services.ForwardHeaderMessageID)
+	}))
+	defer crm.Close()
+	stubWebhookSession(t, &services.SessionInfo{UserID: "u-forward", BotActive: true, SubscriptionActive: true, ForwardURL: crm.URL})
+
+	// The STOP itself and a message after it both reach the customer's webhook
+	for _, m := range []struct{ id, text string }{{sessionTok + "_stop", "STOP"}, {sessionTok + "_later", "halo"}} {
+		postAIWebhook(fmt.Sprintf(`{"instanceName":%q,"event":{"Info":{"ID":%q,"Sender":"6281200000010@s.whatsapp.net","Chat":"6281200000010@s.whatsapp.net","Type":"text","Timestamp":%q},"Message":{"conversation":%q}}}`,
+			sessionTok, m.id, time.Now().Format(time.RFC3339), m.text))
+		select {
+		case got := <-forwarded:
+			if got != m.id {
+				t.Errorf("forwarded %q, want %q", got, m.id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %s was not forwarded", m.id)
+		}
+	}
+}
@@ -9,13 +9,13 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"genfity-wa-support/database"
 	"genfity-wa-support/models"
+	"genfity-wa-support/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -104,8 +104,24 @@ func GetCampaigns(c *gin.Context) {
 		return
 	}
 
+	q, err := parseListQuery(c, string(models.CampaignStatusActive), string(models.CampaignStatusInactive),
+		string(models.CampaignStatusArchived))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.CampaignListResponse{
+			Code:    400,
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	query := q.filter(database.TransactionalDB.Model(&models.Campaign{}).Where("user_id = ?", userID))
+	var total int64
 	var campaigns []models.Campaign
-	if err := database.TransactionalDB.Where("user_id = ?", userID).Find(&campaigns).Error; err != nil {
+	if err = query.Count(&total).Error; err == nil {
+		err = q.page(query).Find(&campaigns).Error
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.CampaignListResponse{
 			Code:    500,
 			Success: false,
@@ -114,9 +130,10 @@ func GetCampaigns(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.CampaignListResponse{
-		Code:    200,
-		Success: true,
-		Data:    campaigns,
+		Code:       200,
+		Success:    true,
+		Data:       campaigns,
+		Pagination: q.pagination(total),
 	})
 }
 
@@ -323,6 +340,28 @@ func CreateBulkCampaign(c *gin.Context) {
 		return
 	}
 
+	// A message template replaces the campaign text (body, or caption of an image campaign)
+	if req.MessageTemplate != "" {
+		if campaign.Type == models.CampaignTypeImage {
+			campaign.Caption = req.MessageTemplate
+		} else {
+			campaign.MessageBody = req.MessageTemplate
+		}
+	}
+
+	// Personalized campaigns (template or variables given): every placeholder needs a value for
+	// every recipient before anything is sent. Plain campaigns keep braces in their text as is.
+	templated := req.MessageTemplate != "" || req.Variables != nil
+	template := campaignTemplateText(campaign.Type, campaign.MessageBody, campaign.Caption)
+	if missing := missingCampaignVariables(template, req.Phone, req.Variables); templated && len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, models.BulkCampaignResponse{
+			Code:    400,
+			Success: false,
+			Message: fmt.Sprintf("Missing template variables for %d recipient(s): %s", len(missing), describeMissingVariables(missing)),
+		})
+		return
+	}
+
 	// Parse scheduling with timezone
 	scheduledAt, timezone, err := parseSendSyncWithTimezone(req.SendSync, req.Timezone)
 	if err != nil {
@@ -342,6 +381,19 @@ func CreateBulkCampaign(c *gin.Context) {
 		status = models.BulkCampaignStatusScheduled // Scheduled for later
 	}
 
+	// Immediate campaigns need a connected session and active subscription now;
+	// scheduled ones are checked again when they run
+	if status == models.BulkCampaignStatusPending {
+		if _, err := resolveCampaignSession(userID.(string)); err != nil {
+			c.JSON(http.StatusForbidden, models.BulkCampaignResponse{
+				Code:    403,
+				Success: false,
+				Message: fmt.Sprintf("Cannot send campaign: %v", err),
+			})
+			return
+		}
+	}
+
 	// Create bulk campaign with copied campaign data
 	bulkCampaign := models.BulkCampaign{
 		UserID:      userID.(string),
@@ -379,6 +431,12 @@ func CreateBulkCampaign(c *gin.Context) {
 			Phone:          phone,
 			Status:         models.BulkCampaignItemStatusPending,
 		}
+		if templated {
+			item.Variables = make(models.JSONB, len(req.Variables[phone]))
+			for name, value := range req.Variables[phone] {
+				item.Variables[name] = value
+			}
+		}
 		if err := tx.Create(&item).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, models.BulkCampaignResponse{
@@ -448,60 +506,62 @@ func AddContacts(c *gin.Context) {
 		return
 	}
 
-	var addedContacts []models.WhatsAppContact
-	var addedCount, updatedCount int
+	var savedContacts []models.WhatsAppContact
+	var counts contactSaveCounts
+	var invalidPhones []string
+	seen := make(map[string]bool)
 
-	// Process each contact
+	// Process each contact, keyed on the normalized number
 	for _, contactData := range req.Contacts {
-		// Check if contact already exists
-		var existingContact models.WhatsAppContact
-		err := database.TransactionalDB.Where("user_id = ? AND phone = ?", userID, contactData.Phone).First(&existingContact).Error
+		phone := normalizeContactPhone(contactData.Phone)
+		if phone == "" {
+			counts.SkippedInvalid++
+			invalidPhones = append(invalidPhones, contactData.Phone)
+			continue
+		}
+		if seen[phone] {
+			counts.SkippedDuplicate++
+			continue
+		}
+		seen[phone] = true
 
+		fullName := contactData.FullName
+		contact, created, err := saveContact(database.TransactionalDB, userID.(string), phone, models.WhatsAppContact{
+			FullName: fullName,
+			Source:   "manual",
+		}, func(existing *models.WhatsAppContact) {
+			// Contact exists, update full name
+			existing.FullName = fullName
+		})
 		if err != nil {
-			// Contact doesn't exist, create new
-			newContact := models.WhatsAppContact{
-				UserID:   userID.(string),
-				Phone:    contactData.Phone,
-				FullName: contactData.FullName,
-				Source:   "manual",
-			}
-
-			if err := database.TransactionalDB.Create(&newContact).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"code":    500,
-					"success": false,
-					"message": fmt.Sprintf("Failed to create contact %s: %v", contactData.Phone, err),
-				})
-				return
-			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 
-			addedContacts = append(addedContacts, newContact)
-			addedCount++
+		savedContacts = append(savedContacts, contact)
+		if created {
+			counts.Added++
 		} else {
-			// Contact exists, update full name
-			existingContact.FullName = contactData.FullName
-			if err := database.TransactionalDB.Save(&existingContact).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"code":    500,
-					"success": false,
-					"message": fmt.Sprintf("Failed to update contact %s: %v", contactData.Phone, err),
-				})
-				return
-			}
-
-			addedContacts = append(addedContacts, existingContact)
-			updatedCount++
+			counts.Updated++
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"success": true,
-		"message": fmt.Sprintf("Processed %d contacts (%d added, %d updated)", len(req.Contacts), addedCount, updatedCount),
+		"message": fmt.Sprintf("Processed %d contacts (%d added, %d updated, %d duplicates skipped, %d invalid)",
+			len(req.Contacts), counts.Added, counts.Updated, counts.SkippedDuplicate, counts.SkippedInvalid),
 		"data": gin.H{
-			"added_count":   addedCount,
-			"updated_count": updatedCount,
-			"contacts":      addedContacts,
+			"added_count":             counts.Added,
+			"updated_count":           counts.Updated,
+			"skipped_duplicate_count": counts.SkippedDuplicate,
+			"skipped_invalid_count":   counts.SkippedInvalid,
+			"invalid_phones":          invalidPhones,
+			"contacts":                savedContacts,
 		},
 	})
 }
@@ -517,8 +577,25 @@ func GetBulkCampaigns(c *gin.Context) {
 		return
 	}
 
+	q, err := parseListQuery(c, string(models.BulkCampaignStatusPending), string(models.BulkCampaignStatusScheduled),
+		string(models.BulkCampaignStatusProcessing), string(models.BulkCampaignStatusCompleted),
+		string(models.BulkCampaignStatusFailed))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BulkCampaignListResponse{
+			Code:    400,
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	query := q.filter(database.TransactionalDB.Model(&models.BulkCampaign{}).Where("user_id = ?", userID))
+	var total int64
 	var bulkCampaigns []models.BulkCampaign
-	if err := database.TransactionalDB.Where("user_id = ?", userID).Preload("Campaign").Find(&bulkCampaigns).Error; err != nil {
+	if err = query.Count(&total).Error; err == nil {
+		err = q.page(query).Preload("Campaign").Find(&bulkCampaigns).Error
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.BulkCampaignListResponse{
 			Code:    500,
 			Success: false,
@@ -527,9 +604,10 @@ func GetBulkCampaigns(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.BulkCampaignListResponse{
-		Code:    200,
-		Success: true,
-		Data:    bulkCampaigns,
+		Code:       200,
+		Success:    true,
+		Data:       bulkCampaigns,
+		Pagination: q.pagination(total),
 	})
 }
 
@@ -637,85 +715,129 @@ func DeleteBulkCampaign(c *gin.Context) {
 	})
 }
 
-// Helper function to process bulk campaign (placeholder)
+// processBulkCampaign sends a claimed campaign's outstanding items at the per-session rate.
+// Safe to call repeatedly: it only runs when it wins the claim, and it only sends items that are
+// pending or failed with attempts left, so a resumed campaign continues where it stopped.
 func processBulkCampaign(bulkCampaignID uint) {
+	claimed, err := claimBulkCampaign(bulkCampaignID, time.Now().Add(-bulkCampaignStaleAfter()))
+	if err != nil {
+		log.Printf("[BULK_CAMPAIGN] Error claiming campaign %d: %v", bulkCampaignID, err)
+		return
+	}
+	if !claimed {
+		log.Printf("[BULK_CAMPAIGN] Campaign %d already processed or running, skipping", bulkCampaignID)
+		return
+	}
+
 	log.Printf("[BULK_CAMPAIGN] Starting processing for campaign ID: %d", bulkCampaignID)
 
 	// Get bulk campaign details
 	var bulkCampaign models.BulkCampaign
-	if err := database.TransactionalDB.Preload("Items").First(&bulkCampaign, bulkCampaignID).Error; err != nil {
+	if err := database.TransactionalDB.First(&bulkCampaign, bulkCampaignID).Error; err != nil {
 		log.Printf("[BULK_CAMPAIGN] Error fetching campaign %d: %v", bulkCampaignID, err)
 		return
 	}
 
-	// Update status to processing
-	if err := database.TransactionalDB.Model(&bulkCampaign).Update("status", models.BulkCampaignStatusProcessing).Error; err != nil {
-		log.Printf("[BULK_CAMPAIGN] Error updating status to processing for campaign %d: %v", bulkCampaignID, err)
-		return
-	}
-
-	now := time.Now()
-	updates := map[string]interface{}{
-		"processed_at": &now,
-	}
-	if err := database.TransactionalDB.Model(&bulkCampaign).Updates(updates).Error; err != nil {
-		log.Printf("[BULK_CAMPAIGN] Error updating processed_at for campaign %d: %v", bulkCampaignID, err)
+	// Same session/subscription rules as the gateway
+	whatsappSession, err := resolveCampaignSession(bulkCampaign.UserID)
+	if err != nil {
+		log.Printf("[BULK_CAMPAIGN] Campaign %d cannot be sent for user %s: %v", bulkCampaignID, bulkCampaign.UserID, err)
+		markCampaignFailed(bulkCampaignID, err.Error())
 		return
 	}
 
-	// Get WhatsApp session for this user
-	var whatsappSession models.WhatsappSession
-	if err := database.TransactionalDB.Where("\"userId\" = ? AND \"connected\" = ?", bulkCampaign.UserID, true).
-		Order("\"updatedAt\" DESC").First(&whatsappSession).Error; err != nil {
-		log.Printf("[BULK_CAMPAIGN] No active WhatsApp session found for user %s: %v", bulkCampaign.UserID, err)
-		markCampaignFailed(bulkCampaignID, "No active WhatsApp session found")
-		return
-	}
+	// WA server hosting the campaign's session
+	whatsappServerURL := services.WAServerURLForSession(whatsappSession.Token)
+
+	maxAttempts := bulkCampaignMaxAttempts()
+	for {
+		// Pending items first, then failed ones with attempts left
+		var items []models.BulkCampaignItem
+		if err := database.TransactionalDB.
+			Where("bulk_campaign_id = ? AND (status = ? OR (status = ? AND attempts < ?))",
+				bulkCampaignID, models.BulkCampaignItemStatusPending, models.BulkCampaignItemStatusFailed, maxAttempts).
+			Order("attempts ASC, id ASC").
+			Limit(100).
+			Find(&items).Error; err != nil {
+			log.Printf("[BULK_CAMPAIGN] Error fetching items for campaign %d: %v", bulkCampaignID, err)
+			return // stays processing - the cron resumes it once stale
+		}
+		if len(items) == 0 {
+			break
+		}
 
-	// Get WhatsApp server URL
-	whatsappServerURL := os.Getenv("WHATSAPP_SERVER_URL")
-	if whatsappServerURL == "" {
-		whatsappServerURL = "https://wa.genfity.com"
-	}
+		for _, item := range items {
+			// Recipients who replied STOP / BERHENTI are never sent to
+			if services.IsOptedOut(whatsappSession.Token, item.Phone) {
+				log.Printf("[BULK_CAMPAIGN] Skipping opted-out recipient %s (campaign %d)", item.Phone, bulkCampaignID)
+				if err := database.TransactionalDB.Model(&item).Updates(map[string]interface{}{
+					"status":        models.BulkCampaignItemStatusOptedOut,
+					"error_message": "Recipient opted out",
+				}).Error; err != nil {
+					log.Printf("[BULK_CAMPAIGN] Error updating item %d: %v", item.ID, err)
+					return
+				}
+				continue
+			}
 
-	// Process each item
-	sentCount := 0
-	failedCount := 0
+			// Fill the recipient's template variables; a gap can't be fixed by retrying
+			itemCampaign, resolvedMessage, err := personalizeCampaign(bulkCampaign, item)
+			if err != nil {
+				log.Printf("[BULK_CAMPAIGN] Cannot send to %s (campaign %d): %v", item.Phone, bulkCampaignID, err)
+				if err := database.TransactionalDB.Model(&item).Updates(map[string]interface{}{
+					"status":        models.BulkCampaignItemStatusFailed,
+					"attempts":      maxAttempts,
+					"error_message": err.Error(),
+				}).Error; err != nil {
+					log.Printf("[BULK_CAMPAIGN] Error updating item %d: %v", item.ID, err)
+					return
+				}
+				refreshBulkCampaignCounts(bulkCampaignID)
+				continue
+			}
 
-	for i, item := range bulkCampaign.Items {
-		// Add delay between requests to avoid overwhelming the server (except for first request)
-		if i > 0 {
-			time.Sleep(2 * time.Second)
-		}
+			waitCampaignSlot(whatsappSession.Token)
 
-		// Send message to WhatsApp server with retry
-		success, messageID, errorMsg := sendWhatsAppMessageWithRetry(whatsappServerURL, whatsappSession.Token, item.Phone, bulkCampaign)
+			// Count the attempt before sending, so a crash mid-send can't retry past the cap
+			attempt := item.Attempts + 1
+			if item.Status == models.BulkCampaignItemStatusPending && item.Attempts > 0 {
+				log.Printf("[BULK_CAMPAIGN] Resuming interrupted send to %s (campaign %d)", item.Phone, bulkCampaignID)
+			}
+			if err := database.TransactionalDB.Model(&item).Updates(map[string]interface{}{
+				"attempts":         attempt,
+				"resolved_message": resolvedMessage,
+			}).Error; err != nil {
+				log.Printf("[BULK_CAMPAIGN] Error updating attempts for item %d: %v", item.ID, err)
+				return
+			}
 
-		// Update item status
-		itemUpdates := map[string]interface{}{
-			"status": models.BulkCampaignItemStatusSent,
-		}
+			success, messageID, errorMsg := sendCampaignMessage(whatsappServerURL, whatsappSession.Token, item.Phone, itemCampaign)
 
-		if success {
-			itemUpdates["message_id"] = messageID
-			itemUpdates["sent_at"] = &now
-			sentCount++
-			// Track message stats for successful send
-			trackCampaignMessageStats(*whatsappSession.UserID, whatsappSession.Token, true, string(bulkCampaign.Type))
-		} else {
-			itemUpdates["status"] = models.BulkCampaignItemStatusFailed
-			itemUpdates["error_message"] = errorMsg
-			failedCount++
-			log.Printf("[BULK_CAMPAIGN] Failed to send message to %s: %s", item.Phone, errorMsg)
-			// Track message stats for failed send
-			trackCampaignMessageStats(*whatsappSession.UserID, whatsappSession.Token, false, string(bulkCampaign.Type))
-		}
+			itemUpdates := map[string]interface{}{}
+			if success {
+				sentAt := time.Now()
+				itemUpdates["status"] = models.BulkCampaignItemStatusSent
+				itemUpdates["message_id"] = messageID
+				itemUpdates["error_message"] = ""
+				itemUpdates["sent_at"] = &sentAt
+			} else {
+				itemUpdates["status"] = models.BulkCampaignItemStatusFailed
+				itemUpdates["error_message"] = errorMsg
+				log.Printf("[BULK_CAMPAIGN] Failed to send message to %s (attempt %d/%d): %s", item.Phone, attempt, maxAttempts, errorMsg)
+			}
+			if whatsappSession.UserID != nil {
+				trackCampaignMessageStats(*whatsappSession.UserID, whatsappSession.Token, success, string(bulkCampaign.Type))
+			}
 
-		if err := database.TransactionalDB.Model(&item).Updates(itemUpdates).Error; err != nil {
-			log.Printf("[BULK_CAMPAIGN] Error updating item %d: %v", item.ID, err)
+			if err := database.TransactionalDB.Model(&item).Updates(itemUpdates).Error; err != nil {
+				log.Printf("[BULK_CAMPAIGN] Error updating item %d: %v", item.ID, err)
+			}
+			refreshBulkCampaignCounts(bulkCampaignID)
 		}
 	}
 
+	sentCount, failedCount := refreshBulkCampaignCounts(bulkCampaignID)
+
 	// Update final campaign status
 	finalStatus := models.BulkCampaignStatusCompleted
 	if sentCount == 0 && failedCount > 0 {
@@ -725,8 +847,6 @@ func processBulkCampaign(bulkCampaignID uint) {
 	completedAt := time.Now()
 	finalUpdates := map[string]interface{}{
 		"status":       finalStatus,
-		"sent_count":   sentCount,
-		"failed_count": failedCount,
 		"completed_at": &completedAt,
 	}
 
@@ -814,6 +934,17 @@ func sendWhatsAppMessage(serverURL, sessionToken, phone string, campaign models.
 		return false, "", fmt.Sprintf("Unsupported message type: %s", campaign.Type)
 	}
 
+	if services.DryRun() {
+		// Image payloads carry the whole base64 file - keep the recorded body short
+		body := services.TruncateRunes(string(jsonData), 500)
+		return true, services.RecordDryRunSend(sessionToken, phone, "chat/send/"+string(campaign.Type), body), ""
+	}
+
+	// Campaigns call the WA server directly - share the session's send pacing with the gateway
+	if err := services.WaitSendSlot(sessionToken); err != nil {
+		return false, "", fmt.Sprintf("Send paced out: %v", err)
+	}
+
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return false, "", fmt.Sprintf("Failed to create request: %v", err)
@@ -824,8 +955,7 @@ func sendWhatsAppMessage(serverURL, sessionToken, phone string, campaign models.
 	req.Header.Set("token", sessionToken)
 
 	// Send request with increased timeout for bulk operations
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := services.DoHTTP(req, 60*time.Second)
 	if err != nil {
 		// Check for specific timeout errors
 		if strings.Contains(err.Error(), "context deadline exceeded") {
@@ -881,8 +1011,9 @@ func sendWhatsAppMessage(serverURL, sessionToken, phone string, campaign models.
 func markCampaignFailed(bulkCampaignID uint, reason string) {
 	now := time.Now()
 	updates := map[string]interface{}{
-		"status":       models.BulkCampaignStatusFailed,
-		"completed_at": &now,
+		"status":        models.BulkCampaignStatusFailed,
+		"error_message": reason,
+		"completed_at":  &now,
 	}
 
 	if err := database.TransactionalDB.Model(&models.BulkCampaign{}).Where("id = ?", bulkCampaignID).Updates(updates).Error; err != nil {
@@ -890,15 +1021,21 @@ func markCampaignFailed(bulkCampaignID uint, reason string) {
 	}
 }
 
-// BulkCampaignCronJob processes scheduled bulk campaigns (called by cron every minute)
+// BulkCampaignCronJob processes scheduled bulk campaigns (called by cron every minute).
+// Also resumes campaigns whose run died: processing or never-started pending ones without progress
+// for BULK_CAMPAIGN_STALE_MINUTES. processBulkCampaign's claim keeps overlapping runs from double-sending.
 func BulkCampaignCronJob(c *gin.Context) {
 	db := database.GetTransactionalDB()
 
 	// Find scheduled campaigns that are ready to be sent
 	var scheduledCampaigns []models.BulkCampaign
 	now := time.Now()
+	staleBefore := now.Add(-bulkCampaignStaleAfter())
 
-	err := db.Where("status = ? AND scheduled_at <= ?", models.BulkCampaignStatusScheduled, now).Find(&scheduledCampaigns).Error
+	err := db.Where("(status = ? AND scheduled_at <= ?) OR (status IN ? AND updated_at < ?)",
+		models.BulkCampaignStatusScheduled, now,
+		[]models.BulkCampaignStatus{models.BulkCampaignStatusPending, models.BulkCampaignStatusProcessing}, staleBefore).
+		Find(&scheduledCampaigns).Error
 	if err != nil {
 		log.Printf("[CRON_JOB] Error fetching scheduled campaigns: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -1077,13 +1214,8 @@ func updateMessageTypeCounter(stats *models.WhatsAppMessageStats, messageType st
 // downloadAndEncodeImageForCampaign downloads an image from URL and returns base64 encoded data URI
 // This function is specific for campaign processing and includes WhatsApp format validation
 func downloadAndEncodeImageForCampaign(imageURL string) (string, error) {
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	// Download the image
-	resp, err := client.Get(imageURL)
+	resp, err := services.NewHTTPClient(30 * time.Second).Get(imageURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to download image: %v", err)
 	}
@@ -1094,9 +1226,9 @@ func downloadAndEncodeImageForCampaign(imageURL string) (string, error) {
 	}
 
 	// Read the image data
-	imageData, err := io.ReadAll(resp.Body)
+	imageData, err := readImageDownload(resp, imageMaxDownloadBytes())
 	if err != nil {
-		return "", fmt.Errorf("failed to read image data: %v", err)
+		return "", err
 	}
 
 	// Get MIME type from content type header or detect from bytes
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// Defaults for bulk campaign sending (override via .env)
+const (
+	defaultBulkCampaignRatePerMinute = 20 // per WhatsApp session
+	defaultBulkCampaignJitterPercent = 30
+	defaultBulkCampaignMaxAttempts   = 3
+	defaultBulkCampaignStaleMinutes  = 10
+)
+
+// bulkCampaignRatePerMinute returns BULK_CAMPAIGN_RATE_PER_MINUTE (messages/minute per session)
+func bulkCampaignRatePerMinute() int {
+	rate := config.GetEnvInt("BULK_CAMPAIGN_RATE_PER_MINUTE", defaultBulkCampaignRatePerMinute)
+	if rate <= 0 {
+		return defaultBulkCampaignRatePerMinute
+	}
+	return rate
+}
+
+// bulkCampaignJitterPercent returns BULK_CAMPAIGN_JITTER_PERCENT (extra random delay, 0-100% of the interval)
+func bulkCampaignJitterPercent() int {
+	jitter := config.GetEnvInt("BULK_CAMPAIGN_JITTER_PERCENT", defaultBulkCampaignJitterPercent)
+	if jitter < 0 {
+		return 0
+	}
+	if jitter > 100 {
+		return 100
+	}
+	return jitter
+}
+
+// bulkCampaignMaxAttempts returns BULK_CAMPAIGN_MAX_ATTEMPTS (send attempts per recipient, across resumes)
+func bulkCampaignMaxAttempts() int {
+	attempts := config.GetEnvInt("BULK_CAMPAIGN_MAX_ATTEMPTS", defaultBulkCampaignMaxAttempts)
+	if attempts < 1 {
+		return 1
+	}
+	return attempts
+}
+
+// bulkCampaignStaleAfter returns BULK_CAMPAIGN_STALE_MINUTES: a processing campaign without progress
+// for this long is considered crashed and resumed by the cron
+func bulkCampaignStaleAfter() time.Duration {
+	minutes := config.GetEnvInt("BULK_CAMPAIGN_STALE_MINUTES", defaultBulkCampaignStaleMinutes)
+	if minutes < 1 {
+		minutes = defaultBulkCampaignStaleMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// campaignSendDelay returns the gap before the next message: the rate interval plus up to jitterPercent more,
+// so the configured rate is never exceeded and sends don't look machine-regular
+func campaignSendDelay(ratePerMinute, jitterPercent int, rnd func() float64) time.Duration {
+	interval := time.Minute / time.Duration(ratePerMinute)
+	if jitterPercent <= 0 {
+		return interval
+	}
+	extra := time.Duration(float64(interval) * float64(jitterPercent) / 100 * rnd())
+	return interval + extra
+}
+
+// sessionPacer spaces out campaign sends per WhatsApp session token.
+// Shared by all campaigns, so two campaigns on one number don't double its rate.
+type sessionPacer struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+var campaignPacer = &sessionPacer{next: make(map[string]time.Time)}
+
+// reserve books the next send slot for token and returns how long to wait for it
+func (p *sessionPacer) reserve(token string, now time.Time, delay time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Drop idle sessions so the map only holds sessions that are sending
+	for t, next := range p.next {
+		if next.Before(now) {
+			delete(p.next, t)
+		}
+	}
+
+	slot := now
+	if next, ok := p.next[token]; ok && next.After(now) {
+		slot = next
+	}
+	p.next[token] = slot.Add(delay)
+	return slot.Sub(now)
+}
+
+// waitCampaignSlot blocks until the session may send its next campaign message
+func waitCampaignSlot(token string) {
+	delay := campaignSendDelay(bulkCampaignRatePerMinute(), bulkCampaignJitterPercent(), rand.Float64)
+	if wait := campaignPacer.reserve(token, time.Now(), delay); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// resolveCampaignSession finds the user's connected session and applies the gateway's
+// token/subscription validation. A var so tests can stub it.
+var resolveCampaignSession = func(userID string) (*models.WhatsappSession, error) {
+	var session models.WhatsappSession
+	if err := database.TransactionalDB.
+		Where(map[string]interface{}{
+			models.WhatsappSessionColUserID:    userID,
+			models.WhatsappSessionColConnected: true,
+		}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: models.WhatsappSessionColUpdatedAt}, Desc: true}).
+		First(&session).Error; err != nil {
+		return nil, fmt.Errorf("no active WhatsApp session found")
+	}
+
+	if _, err := validateTokenAndSubscription(session.Token, "/chat/send/text"); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// sendCampaignMessage sends one campaign message (a var so tests can stub the gateway)
+var sendCampaignMessage = sendWhatsAppMessageWithRetry
+
+// claimBulkCampaign atomically moves a campaign to processing. Only pending/scheduled campaigns,
+// or processing ones without progress since staleBefore (crashed run), can be claimed - so a
+// duplicate execute or overlapping cron run never sends the same campaign twice.
+func claimBulkCampaign(bulkCampaignID uint, staleBefore time.Time) (bool, error) {
+	now := time.Now()
+	result := database.TransactionalDB.Model(&models.BulkCampaign{}).
+		Where("id = ? AND (status IN ? OR (status = ? AND updated_at < ?))",
+			bulkCampaignID,
+			[]models.BulkCampaignStatus{models.BulkCampaignStatusPending, models.BulkCampaignStatusScheduled},
+			models.BulkCampaignStatusProcessing, staleBefore).
+		Updates(map[string]interface{}{
+			"status":     models.BulkCampaignStatusProcessing,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	// First run only - a resumed campaign keeps its original processed_at
+	database.TransactionalDB.Model(&models.BulkCampaign{}).
+		Where("id = ? AND processed_at IS NULL", bulkCampaignID).
+		Update("processed_at", now)
+	return true, nil
+}
+
+// refreshBulkCampaignCounts recounts sent/failed items and bumps updated_at (the resume heartbeat)
+func refreshBulkCampaignCounts(bulkCampaignID uint) (sent, failed int64) {
+	db := database.TransactionalDB
+	db.Model(&models.BulkCampaignItem{}).
+		Where("bulk_campaign_id = ? AND status = ?", bulkCampaignID, models.BulkCampaignItemStatusSent).
+		Count(&sent)
+	db.Model(&models.BulkCampaignItem{}).
+		Where("bulk_campaign_id = ? AND status = ?", bulkCampaignID, models.BulkCampaignItemStatusFailed).
+		Count(&failed)
+
+	db.Model(&models.BulkCampaign{}).Where("id = ?", bulkCampaignID).Updates(map[string]interface{}{
+		"sent_count":   sent,
+		"failed_count": failed,
+		"updated_at":   time.Now(),
+	})
+	return sent, failed
+}
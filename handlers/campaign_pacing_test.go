@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestCampaignSendDelay(t *testing.T) {
+	// 20/min = one every 3s; jitter only ever adds, so the rate is never exceeded
+	if got := campaignSendDelay(20, 0, func() float64 { return 0.9 }); got != 3*time.Second {
+		t.Errorf("no jitter: delay = %v, want 3s", got)
+	}
+	if got := campaignSendDelay(20, 30, func() float64 { return 0 }); got != 3*time.Second {
+		t.Errorf("zero jitter draw: delay = %v, want 3s", got)
+	}
+	if got := campaignSendDelay(20, 30, func() float64 { return 1 }); got != 3900*time.Millisecond {
+		t.Errorf("max jitter draw: delay = %v, want 3.9s", got)
+	}
+}
+
+func TestSessionPacerSpacesSendsPerSession(t *testing.T) {
+	p := &sessionPacer{next: make(map[string]time.Time)}
+	now := time.Now()
+	delay := 3 * time.Second
+
+	if wait := p.reserve("tok-a", now, delay); wait != 0 {
+		t.Errorf("first send waits %v, want 0", wait)
+	}
+	if wait := p.reserve("tok-a", now, delay); wait != delay {
+		t.Errorf("second send waits %v, want %v", wait, delay)
+	}
+	if wait := p.reserve("tok-a", now, delay); wait != 2*delay {
+		t.Errorf("third send waits %v, want %v", wait, 2*delay)
+	}
+	// Another session isn't held up by tok-a
+	if wait := p.reserve("tok-b", now, delay); wait != 0 {
+		t.Errorf("other session waits %v, want 0", wait)
+	}
+
+	// Once the slots have passed the session is idle again and dropped from the map
+	later := now.Add(time.Minute)
+	if wait := p.reserve("tok-b", later, delay); wait != 0 {
+		t.Errorf("idle session waits %v, want 0", wait)
+	}
+	if _, ok := p.next["tok-a"]; ok {
+		t.Errorf("idle session tok-a still tracked")
+	}
+}
+
+func TestProcessBulkCampaignResumesAndCapsRetries(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.TransactionalDB, &models.BulkCampaign{}, &models.BulkCampaignItem{})
+	t.Setenv("BULK_CAMPAIGN_RATE_PER_MINUTE", "6000")
+	t.Setenv("BULK_CAMPAIGN_JITTER_PERCENT", "0")
+	t.Setenv("BULK_CAMPAIGN_MAX_ATTEMPTS", "2")
+
+	token := fmt.Sprintf("campaign-test-%d", time.Now().UnixNano())
+	previousResolve, previousSend := resolveCampaignSession, sendCampaignMessage
+	t.Cleanup(func() { resolveCampaignSession, sendCampaignMessage = previousResolve, previousSend })
+	resolveCampaignSession = func(userID string) (*models.WhatsappSession, error) {
+		return &models.WhatsappSession{Token: token}, nil
+	}
+
+	sends := make(map[string]int)
+	sendCampaignMessage = func(serverURL, sessionToken, phone string, campaign models.BulkCampaign) (bool, string, string) {
+		sends[phone]++
+		if phone == "620000000003" {
+			return false, "", "gateway down"
+		}
+		return true, "MSG-" + phone, ""
+	}
+
+	// A run crashed after sending item 1: campaign still processing, last progress long ago
+	campaign := models.BulkCampaign{UserID: token, Name: "resume", Type: models.CampaignTypeText,
+		MessageBody: "halo", Status: models.BulkCampaignStatusProcessing, TotalCount: 3}
+	if err := db.Create(&campaign).Error; err != nil {
+		t.Fatalf("failed to create campaign: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Where("bulk_campaign_id = ?", campaign.ID).Delete(&models.BulkCampaignItem{})
+		db.Unscoped().Delete(&models.BulkCampaign{}, campaign.ID)
+	})
+	items := []models.BulkCampaignItem{
+		{BulkCampaignID: campaign.ID, Phone: "620000000001", Status: models.BulkCampaignItemStatusSent, Attempts: 1},
+		{BulkCampaignID: campaign.ID, Phone: "620000000002", Status: models.BulkCampaignItemStatusPending},
+		{BulkCampaignID: campaign.ID, Phone: "620000000003", Status: models.BulkCampaignItemStatusPending},
+	}
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatalf("failed to create items: %v", err)
+	}
+
+	// Fresh progress: another run owns it, nothing is sent
+	processBulkCampaign(campaign.ID)
+	if len(sends) != 0 {
+		t.Fatalf("running campaign was processed again: %v", sends)
+	}
+
+	db.Model(&models.BulkCampaign{}).Where("id = ?", campaign.ID).
+		UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+	processBulkCampaign(campaign.ID)
+
+	if sends["620000000001"] != 0 {
+		t.Errorf("already-sent recipient was sent again")
+	}
+	if sends["620000000002"] != 1 {
+		t.Errorf("pending recipient sent %d times, want 1", sends["620000000002"])
+	}
+	if sends["620000000003"] != 2 {
+		t.Errorf("failing recipient sent %d times, want 2 (BULK_CAMPAIGN_MAX_ATTEMPTS)", sends["620000000003"])
+	}
+
+	var result models.BulkCampaign
+	db.First(&result, campaign.ID)
+	if result.Status != models.BulkCampaignStatusCompleted || result.SentCount != 2 || result.FailedCount != 1 {
+		t.Errorf("campaign = status %s sent %d failed %d, want completed 2/1", result.Status, result.SentCount, result.FailedCount)
+	}
+
+	// Completed campaigns can't be claimed again
+	processBulkCampaign(campaign.ID)
+	if sends["620000000002"] != 1 {
+		t.Errorf("completed campaign was processed again")
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"genfity-wa-support/models"
+)
+
+// campaignPlaceholderPattern matches {name} placeholders in campaign messages
+var campaignPlaceholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// errMissingTemplateVariable - a recipient has no value for a placeholder of the campaign message
+var errMissingTemplateVariable = errors.New("missing template variable")
+
+// campaignPlaceholders returns the distinct placeholder names of a template, in order of appearance
+func campaignPlaceholders(template string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range campaignPlaceholderPattern.FindAllStringSubmatch(template, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// renderCampaignTemplate fills the placeholders of template from vars. Every missing variable is
+// named in the error (wrapping errMissingTemplateVariable); an empty value counts as set.
+func renderCampaignTemplate(template string, vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range campaignPlaceholders(template) {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", errMissingTemplateVariable, strings.Join(missing, ", "))
+	}
+
+	return campaignPlaceholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return vars[placeholder[1:len(placeholder)-1]]
+	}), nil
+}
+
+// campaignTemplateText returns the personalizable text of a campaign: the body of a text
+// campaign, the caption of an image campaign
+func campaignTemplateText(campaignType models.CampaignType, messageBody, caption string) string {
+	if campaignType == models.CampaignTypeImage {
+		return caption
+	}
+	return messageBody
+}
+
+// missingCampaignVariables checks every recipient before a campaign starts and returns, per phone,
+// the placeholders it has no value for (nil when all are complete)
+func missingCampaignVariables(template string, phones []string, variables map[string]map[string]string) map[string][]string {
+	placeholders := campaignPlaceholders(template)
+	if len(placeholders) == 0 {
+		return nil
+	}
+
+	missing := make(map[string][]string)
+	for _, phone := range phones {
+		for _, name := range placeholders {
+			if _, ok := variables[phone][name]; !ok {
+				missing[phone] = append(missing[phone], name)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return missing
+}
+
+// describeMissingVariables formats missingCampaignVariables for an error message (first 10 recipients)
+func describeMissingVariables(missing map[string][]string) string {
+	phones := make([]string, 0, len(missing))
+	for phone := range missing {
+		phones = append(phones, phone)
+	}
+	sort.Strings(phones)
+
+	const maxListed = 10
+	var parts []string
+	for i, phone := range phones {
+		if i == maxListed {
+			parts = append(parts, fmt.Sprintf("and %d more recipient(s)", len(phones)-maxListed))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", phone, strings.Join(missing[phone], ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// itemTemplateVariables converts the stored variables of a campaign item back to strings
+func itemTemplateVariables(item models.BulkCampaignItem) map[string]string {
+	vars := make(map[string]string, len(item.Variables))
+	for name, value := range item.Variables {
+		if s, ok := value.(string); ok {
+			vars[name] = s
+		} else if value != nil {
+			vars[name] = fmt.Sprint(value)
+		}
+	}
+	return vars
+}
+
+// personalizeCampaign returns the campaign with its message resolved for one recipient, plus the
+// resolved text. Items without stored variables (plain campaigns) get the text unchanged.
+func personalizeCampaign(campaign models.BulkCampaign, item models.BulkCampaignItem) (models.BulkCampaign, string, error) {
+	text := campaignTemplateText(campaign.Type, campaign.MessageBody, campaign.Caption)
+	if item.Variables == nil {
+		return campaign, text, nil
+	}
+
+	resolved, err := renderCampaignTemplate(text, itemTemplateVariables(item))
+	if err != nil {
+		return campaign, "", err
+	}
+	if campaign.Type == models.CampaignTypeImage {
+		campaign.Caption = resolved
+	} else {
+		campaign.MessageBody = resolved
+	}
+	return campaign, resolved, nil
+}
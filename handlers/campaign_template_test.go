@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"genfity-wa-support/models"
+)
+
+func TestRenderCampaignTemplate(t *testing.T) {
+	got, err := renderCampaignTemplate("Hi {name}, your order {id} is ready. Thanks {name}!", map[string]string{"name": "Budi", "id": "INV-7"})
+	if err != nil || got != "Hi Budi, your order INV-7 is ready. Thanks Budi!" {
+		t.Errorf("render = %q, %v", got, err)
+	}
+
+	// Braces that aren't placeholders stay as they are
+	if got, err := renderCampaignTemplate("Promo {50%} {}", nil); err != nil || got != "Promo {50%} {}" {
+		t.Errorf("non-placeholder braces = %q, %v", got, err)
+	}
+
+	_, err = renderCampaignTemplate("Hi {name}, order {id}", map[string]string{"name": "Budi"})
+	if !errors.Is(err, errMissingTemplateVariable) || !strings.Contains(err.Error(), "id") {
+		t.Errorf("missing variable error = %v", err)
+	}
+}
+
+func TestMissingCampaignVariables(t *testing.T) {
+	variables := map[string]map[string]string{
+		"6281200000001": {"name": "Budi", "id": "INV-1"},
+		"6281200000002": {"name": "Sari"},
+	}
+	phones := []string{"6281200000001", "6281200000002", "6281200000003"}
+
+	missing := missingCampaignVariables("Hi {name}, order {id}", phones, variables)
+	if len(missing) != 2 || strings.Join(missing["6281200000002"], ",") != "id" || strings.Join(missing["6281200000003"], ",") != "name,id" {
+		t.Errorf("missing = %v", missing)
+	}
+	if got := describeMissingVariables(missing); got != "6281200000002: id; 6281200000003: name, id" {
+		t.Errorf("describe = %q", got)
+	}
+	if missing := missingCampaignVariables("Halo semua", phones, nil); missing != nil {
+		t.Errorf("template without placeholders reported %v", missing)
+	}
+}
+
+func TestPersonalizeCampaign(t *testing.T) {
+	text := models.BulkCampaign{Type: models.CampaignTypeText, MessageBody: "Hi {name}"}
+	campaign, resolved, err := personalizeCampaign(text, models.BulkCampaignItem{Variables: models.JSONB{"name": "Budi"}})
+	if err != nil || resolved != "Hi Budi" || campaign.MessageBody != "Hi Budi" {
+		t.Errorf("text campaign = %q / %q, %v", campaign.MessageBody, resolved, err)
+	}
+
+	image := models.BulkCampaign{Type: models.CampaignTypeImage, ImageURL: "https://example.com/a.png", Caption: "Untuk {name}"}
+	if campaign, _, err := personalizeCampaign(image, models.BulkCampaignItem{Variables: models.JSONB{"name": "Sari"}}); err != nil || campaign.Caption != "Untuk Sari" {
+		t.Errorf("image caption = %q, %v", campaign.Caption, err)
+	}
+
+	// Plain campaigns (no stored variables) are sent unchanged, braces included
+	if campaign, resolved, err := personalizeCampaign(text, models.BulkCampaignItem{}); err != nil || campaign.MessageBody != "Hi {name}" || resolved != "Hi {name}" {
+		t.Errorf("plain campaign = %q / %q, %v", campaign.MessageBody, resolved, err)
+	}
+
+	if _, _, err := personalizeCampaign(text, models.BulkCampaignItem{Variables: models.JSONB{}}); !errors.Is(err, errMissingTemplateVariable) {
+		t.Errorf("missing variable at send time: err = %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListChatRooms returns the session's chat rooms; archived rooms are left out unless asked for
+// GET /ai/rooms?archived=active|archived|all&limit=&offset=
+func ListChatRooms(c *gin.Context) {
+	filter := c.DefaultQuery("archived", services.ChatRoomFilterActive)
+	switch filter {
+	case services.ChatRoomFilterActive, services.ChatRoomFilterArchived, services.ChatRoomFilterAll:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "archived must be one of active, archived, all",
+		})
+		return
+	}
+
+	limit, okLimit := queryNonNegativeInt(c, "limit")
+	offset, okOffset := queryNonNegativeInt(c, "offset")
+	if !okLimit || !okOffset {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "limit and offset must be non-negative integers",
+		})
+		return
+	}
+
+	rooms, total, err := services.ListChatRooms(c.GetString("session_token"), filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Chat rooms retrieved",
+		"data": gin.H{
+			"filter": filter,
+			"total":  total,
+			"count":  len(rooms),
+			"rooms":  rooms,
+		},
+	})
+}
+
+// ArchiveChatRoom hides a room from the default list without deleting its messages
+// POST /ai/rooms/:id/archive
+func ArchiveChatRoom(c *gin.Context) {
+	setChatRoomArchived(c, true)
+}
+
+// UnarchiveChatRoom brings an archived room back to the default list
+// POST /ai/rooms/:id/unarchive
+func UnarchiveChatRoom(c *gin.Context) {
+	setChatRoomArchived(c, false)
+}
+
+func setChatRoomArchived(c *gin.Context, archived bool) {
+	roomID, ok := chatRoomIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.SetChatRoomArchived(c.GetString("session_token"), roomID, archived); err != nil {
+		respondChatRoomError(c, err)
+		return
+	}
+
+	message := "Chat room unarchived"
+	if archived {
+		message = "Chat room archived"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"id":          roomID,
+			"is_archived": archived,
+		},
+	})
+}
+
+// DeleteChatRoom deletes a room together with its chat_messages
+// DELETE /ai/rooms/:id
+func DeleteChatRoom(c *gin.Context) {
+	roomID, ok := chatRoomIDParam(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := services.DeleteChatRoom(c.GetString("session_token"), roomID)
+	if err != nil {
+		respondChatRoomError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Chat room deleted",
+		"data": gin.H{
+			"id":               roomID,
+			"deleted_messages": deleted,
+		},
+	})
+}
+
+func chatRoomIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "invalid chat room id",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondChatRoomError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrChatRoomNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+// queryNonNegativeInt parses an optional integer query param (0 when absent)
+func queryNonNegativeInt(c *gin.Context, key string) (int, bool) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, true
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
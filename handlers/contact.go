@@ -6,30 +6,57 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 
 	"genfity-wa-support/database"
 	"genfity-wa-support/models"
+	"genfity-wa-support/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// extractPhoneNumber extracts clean phone number from WhatsApp format
-func extractPhoneNumber(phoneNumber string) string {
-	// Remove @s.whatsapp.net suffix
-	if strings.HasSuffix(phoneNumber, "@s.whatsapp.net") {
-		return strings.TrimSuffix(phoneNumber, "@s.whatsapp.net")
-	}
+// contactSaveCounts is the outcome of a contact sync / manual add
+type contactSaveCounts struct {
+	Added            int `json:"added"`
+	Updated          int `json:"updated"`
+	SkippedDuplicate int `json:"skipped_duplicate"` // same number more than once in the request
+	SkippedInvalid   int `json:"skipped_invalid"`   // not a phone number (@lid, group, no country code, ...)
+}
 
-	// Skip @lid format (channel/business accounts) as they're not regular phone numbers
-	if strings.HasSuffix(phoneNumber, "@lid") {
+// normalizeContactPhone returns the number a contact is stored and deduplicated under, so
+// "+62 812-3456-7890", "6281234567890" and "6281234567890:3@s.whatsapp.net" are one contact.
+// Returns "" for values that aren't a personal phone number.
+func normalizeContactPhone(phone string) string {
+	normalized, err := services.ValidatePhone(phone)
+	if err != nil || strings.Contains(normalized, "@") {
 		return ""
 	}
+	return normalized
+}
 
-	// Return as is if no suffix found
-	return phoneNumber
+// saveContact upserts the user's contact for an already normalized phone: a new row is created
+// from contact, an existing one is passed to update and saved. Reports whether it was created.
+func saveContact(db *gorm.DB, userID, phone string, contact models.WhatsAppContact, update func(*models.WhatsAppContact)) (models.WhatsAppContact, bool, error) {
+	var existing models.WhatsAppContact
+	err := db.Where("user_id = ? AND phone = ?", userID, phone).Order("id ASC").Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		contact.UserID = userID
+		contact.Phone = phone
+		if err := db.Create(&contact).Error; err != nil {
+			return contact, false, fmt.Errorf("failed to create contact %s: %w", phone, err)
+		}
+		return contact, true, nil
+	}
+	if err != nil {
+		return existing, false, fmt.Errorf("failed to look up contact %s: %w", phone, err)
+	}
+
+	update(&existing)
+	if err := db.Save(&existing).Error; err != nil {
+		return existing, false, fmt.Errorf("failed to update contact %s: %w", phone, err)
+	}
+	return existing, false, nil
 }
 
 // BulkContactSync syncs contacts from external WhatsApp server and stores them in database
@@ -57,10 +84,7 @@ func BulkContactSync(c *gin.Context) {
 	}
 
 	// Get base URL for external WhatsApp server
-	baseURL := os.Getenv("WHATSAPP_SERVER_URL")
-	if baseURL == "" {
-		baseURL = "https://wa.genfity.com"
-	}
+	baseURL := services.WAServerURLForSession(whatsappToken)
 
 	// Make request to external WhatsApp server
 	url := fmt.Sprintf("%s/user/contacts", baseURL)
@@ -79,8 +103,7 @@ func BulkContactSync(c *gin.Context) {
 	req.Header.Set("token", whatsappToken)
 
 	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := services.DoHTTP(req, proxyTimeout("/user/contacts"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -123,70 +146,62 @@ func BulkContactSync(c *gin.Context) {
 		return
 	}
 
-	// Store contacts in transactional database
+	// Store contacts in transactional database, one row per normalized number
 	db := database.GetTransactionalDB()
-	var savedContacts []models.WhatsAppContact
+	var counts contactSaveCounts
+	seen := make(map[string]bool)
 
 	fmt.Printf("Processing %d contacts from sync response\n", len(syncResponse.Data))
 
 	for phoneNumber, contactData := range syncResponse.Data {
-		// Extract clean phone number (remove @s.whatsapp.net or @lid)
-		cleanPhone := extractPhoneNumber(phoneNumber)
+		// @lid and group JIDs are not phone numbers
+		cleanPhone := normalizeContactPhone(phoneNumber)
 		if cleanPhone == "" {
 			fmt.Printf("Skipping invalid phone: %s\n", phoneNumber)
+			counts.SkippedInvalid++
 			continue
 		}
-
-		// fmt.Printf("Processing contact: %s -> %s (%s)\n", phoneNumber, cleanPhone, contactData.FullName)
-
-		// Check if contact already exists for this user and phone
-		var existingContact models.WhatsAppContact
-		err := db.Where("user_id = ? AND phone = ?", userID, cleanPhone).Take(&existingContact).Error
-
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Contact doesn't exist, create new one
-			newContact := models.WhatsAppContact{
-				UserID:   userID.(string),
-				Phone:    cleanPhone,
-				Name:     contactData.FirstName,
-				FullName: contactData.FullName,
-				PushName: contactData.PushName,
-				Business: contactData.BusinessName != "",
-				Source:   "sync",
-			}
-
-			if err := db.Create(&newContact).Error; err != nil {
-				fmt.Printf("Failed to create contact %s: %v\n", cleanPhone, err)
-				continue // Skip this contact if failed to save
-			}
-			// fmt.Printf("Created new contact: %s\n", cleanPhone)
-			savedContacts = append(savedContacts, newContact)
-		} else if err != nil {
-			// Other database error, skip this contact
-			fmt.Printf("Database error for contact %s: %v\n", cleanPhone, err)
+		// The same number under another JID form (device suffix, c.us) in this sync
+		if seen[cleanPhone] {
+			counts.SkippedDuplicate++
 			continue
-		} else {
+		}
+		seen[cleanPhone] = true
+
+		_, created, err := saveContact(db, userID.(string), cleanPhone, models.WhatsAppContact{
+			Name:     contactData.FirstName,
+			FullName: contactData.FullName,
+			PushName: contactData.PushName,
+			Business: contactData.BusinessName != "",
+			Source:   "sync",
+		}, func(existing *models.WhatsAppContact) {
 			// Contact exists, replace with new data (sesuai requirement)
-			existingContact.Name = contactData.FirstName
-			existingContact.FullName = contactData.FullName
-			existingContact.PushName = contactData.PushName
-			existingContact.Business = contactData.BusinessName != ""
-			existingContact.Source = "sync" // Update source to sync
-
-			if err := db.Save(&existingContact).Error; err != nil {
-				fmt.Printf("Failed to update contact %s: %v\n", cleanPhone, err)
-				continue // Skip this contact if failed to update
-			}
-			// fmt.Printf("Replaced existing contact: %s\n", cleanPhone)
-			savedContacts = append(savedContacts, existingContact)
+			existing.Name = contactData.FirstName
+			existing.FullName = contactData.FullName
+			existing.PushName = contactData.PushName
+			existing.Business = contactData.BusinessName != ""
+			existing.Source = "sync" // Update source to sync
+		})
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue // Skip this contact if failed to save
+		}
+		if created {
+			counts.Added++
+		} else {
+			counts.Updated++
 		}
-	} // Return original response from external API along with storage status
+	}
+
+	// Return original response from external API along with storage status
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"success": true,
-		"message": fmt.Sprintf("Successfully synced %d contacts", len(savedContacts)),
-		"data":    syncResponse.Data,
-		"stored":  len(savedContacts),
+		"message": fmt.Sprintf("Successfully synced %d contacts (%d added, %d updated, %d duplicates skipped)",
+			counts.Added+counts.Updated, counts.Added, counts.Updated, counts.SkippedDuplicate),
+		"data":   syncResponse.Data,
+		"stored": counts.Added + counts.Updated,
+		"counts": counts,
 	})
 }
 
@@ -273,9 +288,17 @@ func BulkDeleteContacts(c *gin.Context) {
 	// Get database connection
 	db := database.GetTransactionalDB()
 
+	// Contacts are stored under the normalized number; raw values still match older rows
+	phones := append([]string{}, req.Phone...)
+	for _, phone := range req.Phone {
+		if normalized := normalizeContactPhone(phone); normalized != "" && normalized != phone {
+			phones = append(phones, normalized)
+		}
+	}
+
 	// Count contacts before deletion for reporting
 	var totalContacts int64
-	db.Model(&models.WhatsAppContact{}).Where("user_id = ? AND phone IN ?", userID, req.Phone).Count(&totalContacts)
+	db.Model(&models.WhatsAppContact{}).Where("user_id = ? AND phone IN ?", userID, phones).Count(&totalContacts)
 
 	if totalContacts == 0 {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -287,7 +310,7 @@ func BulkDeleteContacts(c *gin.Context) {
 	}
 
 	// Delete contacts
-	result := db.Where("user_id = ? AND phone IN ?", userID, req.Phone).Delete(&models.WhatsAppContact{})
+	result := db.Where("user_id = ? AND phone IN ?", userID, phones).Delete(&models.WhatsAppContact{})
 
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeContactPhone(t *testing.T) {
+	for input, want := range map[string]string{
+		"6281234567890":                  "6281234567890",
+		"+62 812-3456-7890":              "6281234567890",
+		"6281234567890:3@s.whatsapp.net": "6281234567890",
+		"6281234567890@s.whatsapp.net":   "6281234567890",
+		"120363000000000000@g.us":        "",
+		"123456789012345@lid":            "",
+		"081234567890":                   "", // no country code
+		"not-a-number":                   "",
+	} {
+		if got := normalizeContactPhone(input); got != want {
+			t.Errorf("normalizeContactPhone(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestAddContactsDeduplicates(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.TransactionalDB, &models.WhatsAppContact{})
+	userID := fmt.Sprintf("contact-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Unscoped().Where("user_id = ?", userID).Delete(&models.WhatsAppContact{}) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/bulk/contact/add", func(c *gin.Context) { c.Set("user_id", userID) }, AddContacts)
+	add := func(body string) map[string]int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/bulk/contact/add", strings.NewReader(body)))
+		var resp struct {
+			Data map[string]json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("add contacts: %d %s", rec.Code, rec.Body.String())
+		}
+		counts := make(map[string]int)
+		for _, key := range []string{"added_count", "updated_count", "skipped_duplicate_count", "skipped_invalid_count"} {
+			var n int
+			_ = json.Unmarshal(resp.Data[key], &n)
+			counts[key] = n
+		}
+		return counts
+	}
+
+	payload := `{"contacts":[
+		{"phone":"6281200000001","full_name":"Budi"},
+		{"phone":"+62 812-0000-0001","full_name":"Budi (again)"},
+		{"phone":"6281200000002","full_name":"Sari"},
+		{"phone":"0812000000","full_name":"Local"}]}`
+	got := add(payload)
+	if got["added_count"] != 2 || got["updated_count"] != 0 || got["skipped_duplicate_count"] != 1 || got["skipped_invalid_count"] != 1 {
+		t.Errorf("first add counts = %v", got)
+	}
+
+	// Re-adding is idempotent: same rows updated, nothing new
+	got = add(payload)
+	if got["added_count"] != 0 || got["updated_count"] != 2 {
+		t.Errorf("second add counts = %v", got)
+	}
+	var count int64
+	db.Model(&models.WhatsAppContact{}).Where("user_id = ?", userID).Count(&count)
+	if count != 2 {
+		t.Errorf("stored %d contacts, want 2", count)
+	}
+}
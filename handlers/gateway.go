@@ -4,12 +4,12 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
@@ -29,6 +29,19 @@ type SendImageRequest struct {
 	Caption string `json:"Caption"`
 }
 
+// waResponseBodyKey is the gin context key the proxies store the WA server response body under
+const waResponseBodyKey = "wa_response_body"
+
+// proxiedSendResult is the real outcome of a proxied send: the WA server can answer 200 with
+// {"success":false,...}, so the body stored by the proxy decides, with the status as fallback
+func proxiedSendResult(c *gin.Context, statusCode int) services.WASendResult {
+	var body []byte
+	if v, ok := c.Get(waResponseBodyKey); ok {
+		body, _ = v.([]byte)
+	}
+	return services.ParseWASendResponse(statusCode, body)
+}
+
 // Global endpoints that don't require token validation
 var globalEndpoints = []string{
 	"/webhook/events", // WhatsApp calls this endpoint
@@ -90,10 +103,7 @@ func getMimeTypeFromBytes(data []byte) string {
 func downloadAndEncodeImage(imageURL string) (string, error) {
 	log.Printf("DEBUG: Downloading image from URL: %s", imageURL)
 
-	// Create HTTP client with timeout
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	resp, err := client.Get(imageURL)
+	resp, err := services.NewHTTPClient(imageDownloadTimeout()).Get(imageURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to download image: %v", err)
 	}
@@ -103,10 +113,10 @@ func downloadAndEncodeImage(imageURL string) (string, error) {
 		return "", fmt.Errorf("failed to download image: HTTP %d", resp.StatusCode)
 	}
 
-	// Read image data
-	imageData, err := io.ReadAll(resp.Body)
+	// Read image data (capped - the URL is user-supplied)
+	imageData, err := readImageDownload(resp, imageMaxDownloadBytes())
 	if err != nil {
-		return "", fmt.Errorf("failed to read image data: %v", err)
+		return "", err
 	}
 
 	// Detect MIME type
@@ -179,16 +189,27 @@ func WhatsAppGateway(c *gin.Context) {
 	// Global endpoints that don't require token validation
 	if isGlobalEndpoint(actualPath) {
 		log.Printf("DEBUG: Global endpoint detected, bypassing token validation")
+		if services.DryRun() {
+			respondDryRun(c, actualPath)
+			return
+		}
 		proxyToWAServer(c, actualPath)
 		return
 	}
 
+	// Unknown endpoints / methods never reach the WA server (GATEWAY_ROUTE_MODE). Admin and global
+	// endpoints above are passed through as they are.
+	if !checkGatewayRoute(c, actualPath) {
+		return
+	}
+
 	// For non-admin routes, validate token and subscription
 	token := getTokenFromRequest(c)
 	if token == "" {
 		log.Printf("DEBUG: No token provided")
 		c.JSON(http.StatusUnauthorized, models.GatewayResponse{
 			Status:  http.StatusUnauthorized,
+			Code:    models.GatewayCodeTokenRequired,
 			Message: "Token required",
 		})
 		return
@@ -202,6 +223,7 @@ func WhatsAppGateway(c *gin.Context) {
 		log.Printf("Validation failed: %v", err)
 		c.JSON(http.StatusForbidden, models.GatewayResponse{
 			Status:  http.StatusForbidden,
+			Code:    gatewayErrorCode(err),
 			Message: err.Error(),
 		})
 		return
@@ -212,18 +234,89 @@ func WhatsAppGateway(c *gin.Context) {
 		if err := checkSessionLimits(userID); err != nil {
 			c.JSON(http.StatusForbidden, models.GatewayResponse{
 				Status:  http.StatusForbidden,
+				Code:    gatewayErrorCode(err),
 				Message: err.Error(),
 			})
 			return
 		}
 	}
 
+	// DRY_RUN: validated and recorded, never forwarded (and not counted in the message stats)
+	if services.DryRun() {
+		respondDryRun(c, actualPath)
+		return
+	}
+
+	// QR polling while connecting: answered from a short cache, or "already connected"
+	if actualPath == "/session/qr" && method == "GET" {
+		serveSessionQR(c, token)
+		return
+	}
+
+	// Pace sends per session so a burst of AI replies / agent sends doesn't get the number flagged
+	if isMessageEndpoint(actualPath) && method == "POST" {
+		if err := services.WaitSendSlot(token); err != nil {
+			log.Printf("🚦 Send refused for session %s: %v", token, err)
+			c.JSON(http.StatusTooManyRequests, models.GatewayResponse{
+				Status:  http.StatusTooManyRequests,
+				Code:    models.GatewayCodeSendRateLimited,
+				Message: "Too many messages for this session, try again shortly",
+			})
+			return
+		}
+	}
+
 	// Proxy to WhatsApp server with special handling for image endpoints
 	statusCode := proxyToWAServerWithProcessing(c, actualPath)
 
-	// Track message stats based on success/failure
-	if isMessageEndpoint(actualPath) && method == "POST" {
-		go trackMessageStats(userID, token, actualPath, c, statusCode >= 200 && statusCode < 300)
+	// Track message stats based on the real outcome (edits / revokes are not new messages)
+	if isMessageEndpoint(actualPath) && !isMessageChangeEndpoint(actualPath) && method == "POST" {
+		result := proxiedSendResult(c, statusCode)
+		if !result.OK && statusCode >= 200 && statusCode < 300 {
+			log.Printf("⚠️  WA server answered %d but the send failed: %s", statusCode, result.Error)
+		}
+		go trackMessageStats(userID, token, actualPath, c, result.OK)
+	}
+}
+
+// sessionConnected reports WhatsAppSession.connected for a token (a var so tests can stub the DB)
+var sessionConnected = func(token string) (bool, error) {
+	var session models.WhatsappSession
+	err := database.TransactionalDB.Select(models.WhatsappSessionColConnected).
+		Where(map[string]interface{}{models.WhatsappSessionColToken: token}).
+		First(&session).Error
+	return session.Connected, err
+}
+
+// serveSessionQR answers GET /wa/session/qr. A connected session gets 409 SESSION_CONNECTED
+// instead of a pointless QR request; otherwise the WA server's QR is cached for
+// WA_QR_CACHE_TTL_MS (well under the code's lifetime) so polling clients share one fetch.
+func serveSessionQR(c *gin.Context, token string) {
+	connected, err := sessionConnected(token)
+	if err != nil {
+		log.Printf("⚠️  QR: could not check whether session %s is connected: %v", token, err)
+	}
+	if connected {
+		services.InvalidateQRCache(token)
+		c.JSON(http.StatusConflict, models.GatewayResponse{
+			Status:  http.StatusConflict,
+			Code:    models.GatewayCodeSessionConnected,
+			Message: "Session already connected, no QR code needed",
+		})
+		return
+	}
+
+	if body, contentType, ok := services.GetCachedQR(token); ok {
+		c.Header("X-Cache", "HIT")
+		c.Data(http.StatusOK, contentType, body)
+		return
+	}
+
+	if statusCode := proxyToWAServer(c, "/session/qr"); statusCode == http.StatusOK {
+		if v, ok := c.Get(waResponseBodyKey); ok {
+			body, _ := v.([]byte)
+			services.StoreQR(token, c.Writer.Header().Get("Content-Type"), body)
+		}
 	}
 }
 
@@ -248,20 +341,61 @@ func getTokenFromRequest(c *gin.Context) string {
 	return ""
 }
 
-// validateTokenAndSubscription validates token and checks subscription status
+// gatewayError is a validation failure carrying its GatewayResponse code
+type gatewayError struct {
+	code    string
+	message string
+}
+
+func (e *gatewayError) Error() string { return e.message }
+
+func newGatewayError(code, format string, args ...interface{}) error {
+	return &gatewayError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// gatewayErrorCode returns the GatewayResponse code for err (INTERNAL_ERROR when it has none)
+func gatewayErrorCode(err error) string {
+	var gwErr *gatewayError
+	if errors.As(err, &gwErr) {
+		return gwErr.code
+	}
+	return models.GatewayCodeInternal
+}
+
+// validateTokenAndSubscription validates token and checks subscription status. When the
+// transactional DB is unreachable, a recent successful validation may be used instead
+// (GATEWAY_DEGRADED_VALIDATION_SECONDS, off by default).
 func validateTokenAndSubscription(token, path string) (string, error) {
+	userID, subscriptionTo, err := checkTokenAndSubscription(token)
+	if err == nil {
+		rememberValidation(token, userID, subscriptionTo)
+		return userID, nil
+	}
+	if gatewayErrorCode(err) != models.GatewayCodeInternal {
+		forgetValidation(token)
+		return "", err
+	}
+	if userID, ok := degradedValidation(token, err); ok {
+		return userID, nil
+	}
+	return "", err
+}
+
+// checkTokenAndSubscription looks the token's session and active subscription up in the
+// transactional DB, returning the user and the subscription's expiry (a var so tests can stub the DB)
+var checkTokenAndSubscription = func(token string) (string, time.Time, error) {
 	// Find session by token in WhatsAppSession table
 	var session models.WhatsappSession
 	if err := database.TransactionalDB.Where("token = ?", token).First(&session).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return "", fmt.Errorf("invalid token")
+			return "", time.Time{}, newGatewayError(models.GatewayCodeTokenInvalid, "invalid token")
 		}
-		return "", fmt.Errorf("database error: %v", err)
+		return "", time.Time{}, newGatewayError(models.GatewayCodeInternal, "database error: %v", err)
 	}
 
 	// Check if session has associated user
 	if session.UserID == nil {
-		return "", fmt.Errorf("session not associated with any user")
+		return "", time.Time{}, newGatewayError(models.GatewayCodeSessionNoUser, "session not associated with any user")
 	}
 
 	// Get user's active subscription from ServicesWhatsappCustomers
@@ -271,19 +405,21 @@ func validateTokenAndSubscription(token, path string) (string, error) {
 		First(&subscription).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return "", fmt.Errorf("no active subscription found")
+			return "", time.Time{}, newGatewayError(models.GatewayCodeSubscriptionNotFound, "no active subscription found")
 		}
-		return "", fmt.Errorf("subscription check failed: %v", err)
+		return "", time.Time{}, newGatewayError(models.GatewayCodeInternal, "subscription check failed: %v", err)
 	}
 
 	// Check if subscription is expired and auto-update status
 	if time.Now().After(subscription.ExpiredAt) {
 		subscription.Status = "expired"
 		database.TransactionalDB.Save(&subscription)
-		return "", fmt.Errorf("subscription expired on %s", subscription.ExpiredAt.Format("2006-01-02"))
+		// AI webhook must not keep answering from a cached "subscription active"
+		services.InvalidateSessionCache(token)
+		return "", time.Time{}, newGatewayError(models.GatewayCodeSubscriptionExpired, "subscription expired on %s", subscription.ExpiredAt.Format("2006-01-02"))
 	}
 
-	return *session.UserID, nil
+	return *session.UserID, subscription.ExpiredAt, nil
 }
 
 // checkSessionLimits validates session limits for connect requests
@@ -294,25 +430,28 @@ func checkSessionLimits(userID string) error {
 		Where("\"customerId\" = ? AND status = ?", userID, "active").
 		First(&subscription).Error
 	if err != nil {
-		return fmt.Errorf("no active subscription found")
+		return newGatewayError(models.GatewayCodeSubscriptionNotFound, "no active subscription found")
 	}
 
 	// Get package info
 	var packageInfo models.WhatsappApiPackage
 	err = database.TransactionalDB.Where("id = ?", subscription.PackageID).First(&packageInfo).Error
 	if err != nil {
-		return fmt.Errorf("package not found")
+		return newGatewayError(models.GatewayCodePackageNotFound, "package not found")
 	}
 
 	// Count current active sessions for this user
 	var currentSessions int64
 	database.TransactionalDB.Model(&models.WhatsappSession{}).
-		Where("\"userId\" = ? AND connected = ?", userID, true).
+		Where(map[string]interface{}{
+			models.WhatsappSessionColUserID:    userID,
+			models.WhatsappSessionColConnected: true,
+		}).
 		Count(&currentSessions)
 
 	// Check if adding new session would exceed limit
 	if int(currentSessions) >= packageInfo.MaxSession {
-		return fmt.Errorf("session limit exceeded. Maximum allowed: %d, current: %d",
+		return newGatewayError(models.GatewayCodeSessionLimit, "session limit exceeded. Maximum allowed: %d, current: %d",
 			packageInfo.MaxSession, currentSessions)
 	}
 
@@ -321,8 +460,8 @@ func checkSessionLimits(userID string) error {
 
 // proxyToWAServerWithProcessing forwards the request to WhatsApp server with optional body processing
 func proxyToWAServerWithProcessing(c *gin.Context, targetPath string) int {
-	// Handle typing indicator and auto-read before sending message
-	if isMessageEndpoint(targetPath) && c.Request.Method == "POST" {
+	// Handle typing indicator and auto-read before sending message (not for edits / revokes)
+	if isMessageEndpoint(targetPath) && !isMessageChangeEndpoint(targetPath) && c.Request.Method == "POST" {
 		token := getTokenFromRequest(c)
 		if token != "" {
 			// Handle typing indicator (regular chat only, not AI)
@@ -346,10 +485,15 @@ func proxyToWAServerWithProcessing(c *gin.Context, targetPath string) int {
 		token := getTokenFromRequest(c)
 		if token != "" {
 			// Stop typing indicator (regular chat only)
-			go handleTypingIndicatorAfterSend(token, c)
-
-			// Save outgoing message to DB
-			go handleSaveOutgoingMessage(token, c, statusCode)
+			if !isMessageChangeEndpoint(targetPath) {
+				go handleTypingIndicatorAfterSend(token, c)
+			}
+
+			// Save outgoing message to DB (edit / revoke update the original row) - not when the
+			// WA server answered 200 but reported the send as failed
+			if proxiedSendResult(c, statusCode).OK {
+				go handleSaveOutgoingMessage(token, c, statusCode)
+			}
 		}
 	}
 
@@ -358,23 +502,30 @@ func proxyToWAServerWithProcessing(c *gin.Context, targetPath string) int {
 
 // proxyImageRequest handles image endpoint with URL to base64 conversion
 func proxyImageRequest(c *gin.Context, targetPath string) int {
-	waServerURL := os.Getenv("WA_SERVER_URL")
+	waServerURL := services.WAServerURLForSession(c.GetHeader("token"))
 	if waServerURL == "" {
 		c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 			Status:  http.StatusInternalServerError,
+			Code:    models.GatewayCodeWAServerUnavailable,
 			Message: "WhatsApp server URL not configured",
 		})
 		return http.StatusInternalServerError
 	}
 
-	// Read request body
+	// Read request body (capped, so a huge upload can't exhaust memory)
 	var bodyBytes []byte
 	if c.Request.Body != nil {
+		maxBody := imageMaxBodyBytes()
 		var err error
-		bodyBytes, err = io.ReadAll(c.Request.Body)
+		bodyBytes, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return respondImageTooLarge(c, fmt.Sprintf("Request body too large (max %d bytes)", maxBody))
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 				Status:  http.StatusInternalServerError,
+				Code:    models.GatewayCodeInternal,
 				Message: "Failed to read request body",
 			})
 			return http.StatusInternalServerError
@@ -384,9 +535,13 @@ func proxyImageRequest(c *gin.Context, targetPath string) int {
 
 	// Process image request (convert URL to base64 if needed)
 	processedBody, err := processImageRequest(bodyBytes)
+	if errors.Is(err, errImageTooLarge) {
+		return respondImageTooLarge(c, fmt.Sprintf("Image too large (max %d bytes)", imageMaxDownloadBytes()))
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.GatewayResponse{
 			Status:  http.StatusBadRequest,
+			Code:    models.GatewayCodeInvalidRequest,
 			Message: fmt.Sprintf("Failed to process image: %v", err),
 		})
 		return http.StatusBadRequest
@@ -402,10 +557,12 @@ func proxyImageRequest(c *gin.Context, targetPath string) int {
 	log.Printf("DEBUG: Request method: %s", c.Request.Method)
 	log.Printf("DEBUG: Original body length: %d, Processed body length: %d", len(bodyBytes), len(processedBody))
 
-	req, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewBuffer(processedBody))
+	// Bound to the client's request: a caller that gave up doesn't keep a WA server call running
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, targetURL, bytes.NewBuffer(processedBody))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 			Status:  http.StatusInternalServerError,
+			Code:    models.GatewayCodeInternal,
 			Message: "Failed to create request",
 		})
 		return http.StatusInternalServerError
@@ -424,14 +581,10 @@ func proxyImageRequest(c *gin.Context, targetPath string) int {
 	}
 
 	// Execute request to WA server
-	client := &http.Client{Timeout: 60 * time.Second} // Longer timeout for image processing
-	resp, err := client.Do(req)
+	timeout := imageProxyTimeout() // Longer timeout for image processing
+	resp, err := services.DoHTTP(req, timeout)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, models.GatewayResponse{
-			Status:  http.StatusBadGateway,
-			Message: "Failed to reach WhatsApp server",
-		})
-		return http.StatusBadGateway
+		return respondWAServerError(c, err, timeout)
 	}
 	defer resp.Body.Close()
 
@@ -440,6 +593,7 @@ func proxyImageRequest(c *gin.Context, targetPath string) int {
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 			Status:  http.StatusInternalServerError,
+			Code:    models.GatewayCodeWAServerUnavailable,
 			Message: "Failed to read response",
 		})
 		return http.StatusInternalServerError
@@ -454,16 +608,86 @@ func proxyImageRequest(c *gin.Context, targetPath string) int {
 
 	// Return response with same status code and body as WA server
 	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+	c.Set(waResponseBodyKey, responseBody)
 
 	return resp.StatusCode
 }
 
+// respondImageTooLarge answers an image request over the body or download limit
+func respondImageTooLarge(c *gin.Context, message string) int {
+	c.JSON(http.StatusRequestEntityTooLarge, models.GatewayResponse{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    models.GatewayCodePayloadTooLarge,
+		Message: message,
+	})
+	return http.StatusRequestEntityTooLarge
+}
+
+// respondTransformError answers a message request transformMessageRequest rejected
+func respondTransformError(c *gin.Context, err error) int {
+	log.Printf("⚠️  Failed to transform request: %v", err)
+	message := "Invalid request format"
+	if errors.Is(err, services.ErrInvalidPhone) || errors.Is(err, errInvalidMessageField) {
+		message = err.Error()
+	}
+	c.JSON(http.StatusBadRequest, models.GatewayResponse{
+		Status:  http.StatusBadRequest,
+		Code:    models.GatewayCodeInvalidRequest,
+		Message: message,
+	})
+	return http.StatusBadRequest
+}
+
+// respondDryRun answers a gateway call without contacting the WA server (DRY_RUN=true). Sends are
+// validated like real ones and recorded (see services.RecordDryRunSend); anything else gets an
+// empty success response.
+func respondDryRun(c *gin.Context, targetPath string) {
+	var bodyBytes []byte
+	if c.Request.Body != nil {
+		bodyBytes, _ = io.ReadAll(c.Request.Body)
+	}
+	if !isMessageEndpoint(targetPath) || c.Request.Method != "POST" {
+		log.Printf("🧪 [DRY_RUN] %s %s not forwarded to the WA server", c.Request.Method, targetPath)
+		c.JSON(http.StatusOK, gin.H{"code": 200, "success": true, "data": gin.H{"dryRun": true}})
+		return
+	}
+
+	// The image endpoint takes the WA server format as is (see proxyImageRequest)
+	body := bodyBytes
+	if targetPath != "/chat/send/image" {
+		transformed, err := transformMessageRequest(bodyBytes, targetPath)
+		if err != nil {
+			respondTransformError(c, err)
+			return
+		}
+		body = transformed
+	}
+	var waFormat struct {
+		Phone string `json:"Phone"`
+	}
+	_ = json.Unmarshal(body, &waFormat)
+
+	// Base64 media would flood the send log - the start of the payload is enough
+	messageID := services.RecordDryRunSend(getTokenFromRequest(c), waFormat.Phone, strings.TrimPrefix(targetPath, "/"),
+		services.TruncateRunes(string(body), 500))
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"data": gin.H{
+			"Id":        messageID,
+			"Details":   "Sent (dry run)",
+			"Timestamp": time.Now().Unix(),
+		},
+	})
+}
+
 // proxyToWAServer forwards the request to WhatsApp server without modification
 func proxyToWAServer(c *gin.Context, targetPath string) int {
-	waServerURL := os.Getenv("WA_SERVER_URL")
+	waServerURL := services.WAServerURLForSession(c.GetHeader("token"))
 	if waServerURL == "" {
 		c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 			Status:  http.StatusInternalServerError,
+			Code:    models.GatewayCodeWAServerUnavailable,
 			Message: "WhatsApp server URL not configured",
 		})
 		return http.StatusInternalServerError
@@ -480,18 +704,13 @@ func proxyToWAServer(c *gin.Context, targetPath string) int {
 	if isMessageEndpoint(targetPath) {
 		transformedBody, err := transformMessageRequest(bodyBytes, targetPath)
 		if err != nil {
-			log.Printf("⚠️  Failed to transform request: %v", err)
-			c.JSON(http.StatusBadRequest, models.GatewayResponse{
-				Status:  http.StatusBadRequest,
-				Message: "Invalid request format",
-			})
-			return http.StatusBadRequest
+			return respondTransformError(c, err)
 		}
 		bodyBytes = transformedBody
 	}
 
 	// Create new request to WA server using the stripped path
-	targetURL := waServerURL + targetPath
+	targetURL := waServerURL + waServerPath(targetPath)
 	if c.Request.URL.RawQuery != "" {
 		targetURL += "?" + c.Request.URL.RawQuery
 	}
@@ -500,10 +719,12 @@ func proxyToWAServer(c *gin.Context, targetPath string) int {
 	log.Printf("DEBUG: Request method: %s", c.Request.Method)
 	log.Printf("DEBUG: Transformed body: %s", string(bodyBytes))
 
-	req, err := http.NewRequest(c.Request.Method, targetURL, bytes.NewBuffer(bodyBytes))
+	// Bound to the client's request: a caller that gave up doesn't keep a WA server call running
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, targetURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 			Status:  http.StatusInternalServerError,
+			Code:    models.GatewayCodeInternal,
 			Message: "Failed to create request",
 		})
 		return http.StatusInternalServerError
@@ -516,15 +737,11 @@ func proxyToWAServer(c *gin.Context, targetPath string) int {
 		}
 	}
 
-	// Execute request to WA server
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	// Execute request to WA server (timeout per endpoint class, see proxyTimeout)
+	timeout := proxyTimeout(targetPath)
+	resp, err := services.DoHTTP(req, timeout)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, models.GatewayResponse{
-			Status:  http.StatusBadGateway,
-			Message: "Failed to reach WhatsApp server",
-		})
-		return http.StatusBadGateway
+		return respondWAServerError(c, err, timeout)
 	}
 	defer resp.Body.Close()
 
@@ -533,6 +750,7 @@ func proxyToWAServer(c *gin.Context, targetPath string) int {
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.GatewayResponse{
 			Status:  http.StatusInternalServerError,
+			Code:    models.GatewayCodeWAServerUnavailable,
 			Message: "Failed to read response",
 		})
 		return http.StatusInternalServerError
@@ -547,6 +765,7 @@ func proxyToWAServer(c *gin.Context, targetPath string) int {
 
 	// Return response with same status code and body as WA server
 	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+	c.Set(waResponseBodyKey, responseBody)
 
 	return resp.StatusCode
 }
@@ -564,6 +783,7 @@ func isMessageEndpoint(path string) bool {
 		"/chat/send/contact",
 		"/chat/send/template",
 		"/chat/send/edit",
+		"/chat/send/revoke",
 		"/chat/send/poll",
 	}
 
@@ -575,6 +795,24 @@ func isMessageEndpoint(path string) bool {
 	return false
 }
 
+// isMessageChangeEndpoint - edit / revoke change an already sent message instead of sending a new one
+func isMessageChangeEndpoint(path string) bool {
+	return path == "/chat/send/edit" || path == "/chat/send/revoke"
+}
+
+// waServerPaths maps gateway paths to a different WA server path (everything else is proxied as is)
+var waServerPaths = map[string]string{
+	"/chat/send/revoke": "/chat/delete", // WA server deletes (revokes for everyone) with /chat/delete
+}
+
+// waServerPath returns the WA server path for a gateway path
+func waServerPath(path string) string {
+	if mapped, ok := waServerPaths[path]; ok {
+		return mapped
+	}
+	return path
+}
+
 // extractMessageTypeFromPath extracts message type from the API path
 func extractMessageTypeFromPath(path string) string {
 	// Remove /wa prefix if present
@@ -606,9 +844,12 @@ func transformMessageRequest(bodyBytes []byte, targetPath string) ([]byte, error
 	// Convert to WA server format based on endpoint
 	waFormat := make(map[string]interface{})
 
-	// Common field: Phone (from "to" field, strip @s.whatsapp.net suffix)
+	// Common field: Phone (from "to" field, normalized to digits / group JID)
 	if to, ok := ourFormat["to"].(string); ok {
-		phone := strings.TrimSuffix(to, "@s.whatsapp.net")
+		phone, err := services.ValidatePhone(to)
+		if err != nil {
+			return nil, err
+		}
 		waFormat["Phone"] = phone
 	} else {
 		return nil, fmt.Errorf("missing 'to' field")
@@ -623,6 +864,9 @@ func transformMessageRequest(bodyBytes []byte, targetPath string) ([]byte, error
 		} else {
 			return nil, fmt.Errorf("missing 'text' field")
 		}
+		if err := transformExpiration(ourFormat, waFormat); err != nil {
+			return nil, err
+		}
 	case "image", "video", "document", "audio", "sticker":
 		// For media: {"Phone": "...", "Body": "caption", "FileName": "..."}
 		if caption, ok := ourFormat["caption"].(string); ok {
@@ -634,6 +878,9 @@ func transformMessageRequest(bodyBytes []byte, targetPath string) ([]byte, error
 		if fileURL, ok := ourFormat["fileUrl"].(string); ok {
 			waFormat["FileURL"] = fileURL
 		}
+		if err := transformExpiration(ourFormat, waFormat); err != nil {
+			return nil, err
+		}
 	case "location":
 		// {"Phone": "...", "Latitude": ..., "Longitude": ...}
 		if lat, ok := ourFormat["latitude"]; ok {
@@ -645,6 +892,30 @@ func transformMessageRequest(bodyBytes []byte, targetPath string) ([]byte, error
 		if name, ok := ourFormat["name"].(string); ok {
 			waFormat["Name"] = name
 		}
+	case "edit":
+		// {"Phone": "...", "Id": "<original message ID>", "Body": "new text"}
+		messageID, _ := ourFormat["messageId"].(string)
+		text, _ := ourFormat["text"].(string)
+		if messageID == "" || text == "" {
+			return nil, fmt.Errorf("edit needs 'messageId' and 'text'")
+		}
+		waFormat["Id"] = messageID
+		waFormat["Body"] = text
+	case "revoke":
+		// {"Phone": "...", "Id": "<original message ID>"}
+		messageID, _ := ourFormat["messageId"].(string)
+		if messageID == "" {
+			return nil, fmt.Errorf("revoke needs 'messageId'")
+		}
+		waFormat["Id"] = messageID
+	case "poll":
+		if err := transformPoll(ourFormat, waFormat); err != nil {
+			return nil, err
+		}
+	case "template":
+		if err := transformTemplate(ourFormat, waFormat); err != nil {
+			return nil, err
+		}
 	case "contact":
 		// {"Phone": "...", "ContactName": "...", "ContactPhone": "..."}
 		if name, ok := ourFormat["contactName"].(string); ok {
@@ -659,6 +930,115 @@ func transformMessageRequest(bodyBytes []byte, targetPath string) ([]byte, error
 	return json.Marshal(waFormat)
 }
 
+// errInvalidMessageField marks a missing / malformed field of a poll or template request;
+// the message is returned to the client as is
+var errInvalidMessageField = errors.New("invalid message request")
+
+// maxPollOptions is WhatsApp's limit on poll answers
+const maxPollOptions = 12
+
+// transformPoll: {"name": "...", "options": ["a", "b"], "selectableCount": 1}
+// -> {"Phone": "...", "Name": "...", "Options": [...], "SelectableCount": n}
+func transformPoll(ourFormat, waFormat map[string]interface{}) error {
+	name, _ := ourFormat["name"].(string)
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: poll needs a 'name'", errInvalidMessageField)
+	}
+	options, err := stringList(ourFormat["options"])
+	if err != nil {
+		return fmt.Errorf("%w: poll 'options' %v", errInvalidMessageField, err)
+	}
+	if len(options) < 2 || len(options) > maxPollOptions {
+		return fmt.Errorf("%w: poll needs 2-%d 'options'", errInvalidMessageField, maxPollOptions)
+	}
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		if seen[option] {
+			return fmt.Errorf("%w: poll option %q is listed twice", errInvalidMessageField, option)
+		}
+		seen[option] = true
+	}
+
+	selectable := 1 // single choice unless asked otherwise
+	if raw, ok := ourFormat["selectableCount"]; ok {
+		count, isNumber := raw.(float64)
+		if !isNumber || count != float64(int(count)) || count < 0 || int(count) > len(options) {
+			return fmt.Errorf("%w: poll 'selectableCount' must be 0-%d (0 = any number)", errInvalidMessageField, len(options))
+		}
+		selectable = int(count)
+	}
+
+	waFormat["Name"] = name
+	waFormat["Options"] = options
+	waFormat["SelectableCount"] = selectable
+	return nil
+}
+
+// disappearingTimers are WhatsApp's disappearing message durations in seconds (24 hours, 7 days, 90 days)
+var disappearingTimers = map[int64]bool{86400: true, 604800: true, 7776000: true}
+
+// transformExpiration: optional {"expiration": 86400} (seconds) -> {"Expiration": 86400}, sending the
+// message as a disappearing message. Absent or 0 = a normal message.
+func transformExpiration(ourFormat, waFormat map[string]interface{}) error {
+	raw, ok := ourFormat["expiration"]
+	if !ok || raw == nil {
+		return nil
+	}
+	seconds, ok := raw.(float64)
+	if !ok || seconds != float64(int64(seconds)) {
+		return fmt.Errorf("%w: 'expiration' must be a number of seconds", errInvalidMessageField)
+	}
+	if seconds == 0 {
+		return nil
+	}
+	if !disappearingTimers[int64(seconds)] {
+		return fmt.Errorf("%w: 'expiration' must be 86400 (24 hours), 604800 (7 days) or 7776000 (90 days), got %v",
+			errInvalidMessageField, int64(seconds))
+	}
+	waFormat["Expiration"] = int64(seconds)
+	return nil
+}
+
+// transformTemplate: {"templateName": "...", "language": "id", "params": ["Budi", "12 Mei"]}
+// -> {"Phone": "...", "Name": "...", "Language": "...", "Params": [...]}
+func transformTemplate(ourFormat, waFormat map[string]interface{}) error {
+	name, _ := ourFormat["templateName"].(string)
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: template needs a 'templateName'", errInvalidMessageField)
+	}
+	params := []string{}
+	if raw, ok := ourFormat["params"]; ok {
+		var err error
+		if params, err = stringList(raw); err != nil {
+			return fmt.Errorf("%w: template 'params' %v", errInvalidMessageField, err)
+		}
+	}
+
+	waFormat["Name"] = name
+	waFormat["Params"] = params
+	if language, ok := ourFormat["language"].(string); ok && language != "" {
+		waFormat["Language"] = language
+	}
+	return nil
+}
+
+// stringList converts a decoded JSON array of non-empty strings
+func stringList(raw interface{}) ([]string, error) {
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return nil, fmt.Errorf("must only contain non-empty strings")
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
 func trackMessageStats(userID, token, path string, c *gin.Context, success bool) {
 	// Extract message type from path
 	messageType := extractMessageTypeFromPath(path)
@@ -754,8 +1134,8 @@ func handleTypingIndicatorBeforeSend(sessionToken string, c *gin.Context) {
 		return // No recipient, skip
 	}
 
-	// Clean phone number (remove @s.whatsapp.net suffix)
-	phoneNumber := strings.TrimSuffix(toField, "@s.whatsapp.net")
+	// Clean phone number (strip JID server / device suffix)
+	phoneNumber := services.NormalizePhone(toField)
 
 	// 4. Set typing state to "composing"
 	if err := services.SetTypingState(sessionToken, phoneNumber, "composing"); err != nil {
@@ -797,7 +1177,7 @@ func handleTypingIndicatorAfterSend(sessionToken string, c *gin.Context) {
 		return
 	}
 
-	phoneNumber := strings.TrimSuffix(toField, "@s.whatsapp.net")
+	phoneNumber := services.NormalizePhone(toField)
 
 	// 3. Set typing state to "stop"
 	if err := services.SetTypingState(sessionToken, phoneNumber, "stop"); err != nil {
@@ -842,38 +1222,9 @@ func handleAutoReadBeforeSend(sessionToken string, c *gin.Context) {
 		return
 	}
 
-	// Clean phone number (remove @s.whatsapp.net suffix)
-	phoneNumber := strings.TrimSuffix(toField, "@s.whatsapp.net")
-
-	// 4. Get unread incoming messages for this contact
-	unreadMessages, err := services.GetUnreadIncomingMessages(sessionToken, toField)
-	if err != nil {
-		log.Printf("⚠️  Failed to get unread messages: %v", err)
-		return
-	}
-
-	if len(unreadMessages) == 0 {
-		return // No unread messages, nothing to do
-	}
-
-	// 5. Extract message IDs
-	messageIDs := make([]string, len(unreadMessages))
-	for i, msg := range unreadMessages {
-		messageIDs[i] = msg.MessageID
-	}
-
-	log.Printf("📖 Auto-reading %d unread messages for contact %s", len(messageIDs), phoneNumber)
-
-	// 6. Call WA Server to mark messages as read
-	if err := services.MarkMessagesAsRead(sessionToken, messageIDs, phoneNumber); err != nil {
-		log.Printf("⚠️  Failed to mark messages as read via WA Server: %v", err)
-		// Continue even if markread fails
-	}
-
-	// 7. Update database to mark messages as read
-	if err := services.MarkMessagesAsReadInDB(messageIDs); err != nil {
-		log.Printf("⚠️  Failed to mark messages as read in DB: %v", err)
-	}
+	// 4. Mark the contact's unread messages as read: one markread call and one DB update, shared
+	// with concurrent triggers for the same chat (other sends, the AI worker)
+	services.MarkChatRead(sessionToken, toField)
 }
 
 // handleSaveOutgoingMessage saves outgoing message to database after successful send
@@ -891,6 +1242,23 @@ func handleSaveOutgoingMessage(sessionToken string, c *gin.Context, statusCode i
 		return
 	}
 
+	// Edit / revoke change the original message instead of adding one
+	switch extractMessageTypeFromPath(c.Request.URL.Path) {
+	case "edit":
+		messageID, _ := reqData["messageId"].(string)
+		text, _ := reqData["text"].(string)
+		if err := services.ApplyOutgoingEdit(sessionToken, messageID, text); err != nil {
+			log.Printf("⚠️  Failed to save edited message: %v", err)
+		}
+		return
+	case "revoke":
+		messageID, _ := reqData["messageId"].(string)
+		if err := services.ApplyOutgoingRevoke(sessionToken, messageID); err != nil {
+			log.Printf("⚠️  Failed to save revoked message: %v", err)
+		}
+		return
+	}
+
 	// Extract fields
 	to, _ := reqData["to"].(string)
 	var body string
@@ -922,7 +1290,7 @@ func handleSaveOutgoingMessage(sessionToken string, c *gin.Context, statusCode i
 	messageID := fmt.Sprintf("%s_%d", sessionToken, time.Now().UnixNano())
 
 	// Save to database with cleanup
-	phoneNumber := strings.TrimSuffix(to, "@s.whatsapp.net")
+	phoneNumber := services.NormalizePhone(to)
 	if err := services.SaveOutgoingMessageToAIChat(sessionToken, messageID, from, to, body, time.Now()); err != nil {
 		log.Printf("⚠️  Failed to save outgoing message: %v", err)
 		return
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gateway route modes (GATEWAY_ROUTE_MODE)
+const (
+	GatewayRouteModeStrict      = "strict"      // only known endpoints and methods are proxied (default)
+	GatewayRouteModePassthrough = "passthrough" // everything under /wa is proxied (new WA server endpoints before the map knows them)
+)
+
+func gatewayRouteMode() string {
+	if strings.EqualFold(config.GetEnvString("GATEWAY_ROUTE_MODE", ""), GatewayRouteModePassthrough) {
+		return GatewayRouteModePassthrough
+	}
+	return GatewayRouteModeStrict
+}
+
+// gatewayEndpoints are the WA server endpoints the gateway forwards (paths without /wa) with
+// their allowed methods. /admin and the globalEndpoints (/health, ...) are not checked - they
+// are always passed through.
+var gatewayEndpoints = map[string][]string{
+	// Session
+	"/session/connect":     {http.MethodPost},
+	"/session/disconnect":  {http.MethodPost},
+	"/session/logout":      {http.MethodPost},
+	"/session/status":      {http.MethodGet},
+	"/session/qr":          {http.MethodGet},
+	"/session/pairphone":   {http.MethodPost},
+	"/session/history":     {http.MethodPost},
+	"/session/proxy":       {http.MethodPost},
+	"/session/s3/config":   {http.MethodGet, http.MethodPost, http.MethodDelete},
+	"/session/s3/test":     {http.MethodPost},
+	"/session/hmac/config": {http.MethodGet, http.MethodPost, http.MethodDelete},
+
+	// Webhook
+	"/webhook": {http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+
+	// Chat
+	"/chat/send/text":        {http.MethodPost},
+	"/chat/send/image":       {http.MethodPost},
+	"/chat/send/audio":       {http.MethodPost},
+	"/chat/send/document":    {http.MethodPost},
+	"/chat/send/video":       {http.MethodPost},
+	"/chat/send/sticker":     {http.MethodPost},
+	"/chat/send/location":    {http.MethodPost},
+	"/chat/send/contact":     {http.MethodPost},
+	"/chat/send/template":    {http.MethodPost},
+	"/chat/send/buttons":     {http.MethodPost},
+	"/chat/send/list":        {http.MethodPost},
+	"/chat/send/poll":        {http.MethodPost},
+	"/chat/send/edit":        {http.MethodPost},
+	"/chat/send/revoke":      {http.MethodPost},
+	"/chat/delete":           {http.MethodPost},
+	"/chat/react":            {http.MethodPost},
+	"/chat/markread":         {http.MethodPost},
+	"/chat/presence":         {http.MethodPost},
+	"/chat/archive":          {http.MethodPost},
+	"/chat/history":          {http.MethodGet},
+	"/chat/downloadimage":    {http.MethodPost},
+	"/chat/downloadvideo":    {http.MethodPost},
+	"/chat/downloadaudio":    {http.MethodPost},
+	"/chat/downloaddocument": {http.MethodPost},
+	"/chat/downloadmedia":    {http.MethodPost},
+
+	// User
+	"/user/info":     {http.MethodPost},
+	"/user/check":    {http.MethodPost},
+	"/user/avatar":   {http.MethodPost},
+	"/user/presence": {http.MethodPost},
+	"/user/contacts": {http.MethodGet},
+
+	// Group
+	"/group/create":             {http.MethodPost},
+	"/group/list":               {http.MethodGet},
+	"/group/info":               {http.MethodGet},
+	"/group/invitelink":         {http.MethodGet},
+	"/group/inviteinfo":         {http.MethodPost},
+	"/group/join":               {http.MethodPost},
+	"/group/leave":              {http.MethodPost},
+	"/group/photo":              {http.MethodPost},
+	"/group/photo/remove":       {http.MethodPost},
+	"/group/name":               {http.MethodPost},
+	"/group/topic":              {http.MethodPost},
+	"/group/announce":           {http.MethodPost},
+	"/group/locked":             {http.MethodPost},
+	"/group/ephemeral":          {http.MethodPost},
+	"/group/updateparticipants": {http.MethodPost},
+
+	// Newsletter
+	"/newsletter/list": {http.MethodGet},
+}
+
+// gatewayEndpointPrefixes are endpoints with a path parameter (prefix match)
+var gatewayEndpointPrefixes = map[string][]string{
+	"/user/lid/": {http.MethodGet},
+}
+
+// gatewayEndpointMethods returns the allowed methods of a gateway path (nil when unknown)
+func gatewayEndpointMethods(path string) []string {
+	if methods, ok := gatewayEndpoints[path]; ok {
+		return methods
+	}
+	for prefix, methods := range gatewayEndpointPrefixes {
+		if strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+			return methods
+		}
+	}
+	return nil
+}
+
+// checkGatewayRoute answers 404 ROUTE_NOT_FOUND for paths the WA server doesn't have and 405
+// METHOD_NOT_ALLOWED (with an Allow header) for a wrong method, instead of proxying them.
+// Reports whether the request may go on; always true in passthrough mode.
+func checkGatewayRoute(c *gin.Context, path string) bool {
+	if gatewayRouteMode() == GatewayRouteModePassthrough {
+		return true
+	}
+
+	methods := gatewayEndpointMethods(path)
+	if methods == nil {
+		c.JSON(http.StatusNotFound, models.GatewayResponse{
+			Status:  http.StatusNotFound,
+			Code:    models.GatewayCodeRouteNotFound,
+			Message: "Unknown endpoint: " + path,
+		})
+		return false
+	}
+	for _, method := range methods {
+		if c.Request.Method == method {
+			return true
+		}
+	}
+
+	c.Header("Allow", strings.Join(methods, ", "))
+	c.JSON(http.StatusMethodNotAllowed, models.GatewayResponse{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    models.GatewayCodeMethodNotAllowed,
+		Message: c.Request.Method + " not allowed for " + path + " (allowed: " + strings.Join(methods, ", ") + ")",
+	})
+	return false
+}
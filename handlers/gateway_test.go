@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGatewayTokenRequiredCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/wa/*path", WhatsAppGateway)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/wa/chat/send/text", nil))
+
+	var resp models.GatewayResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if rec.Code != http.StatusUnauthorized || resp.Code != models.GatewayCodeTokenRequired {
+		t.Errorf("got %d %q, want 401 %s", rec.Code, resp.Code, models.GatewayCodeTokenRequired)
+	}
+}
+
+func TestGatewayErrorCode(t *testing.T) {
+	err := newGatewayError(models.GatewayCodeSessionLimit, "session limit exceeded. Maximum allowed: %d, current: %d", 1, 1)
+	if got := gatewayErrorCode(err); got != models.GatewayCodeSessionLimit {
+		t.Errorf("code = %q, want %s", got, models.GatewayCodeSessionLimit)
+	}
+	if err.Error() != "session limit exceeded. Maximum allowed: 1, current: 1" {
+		t.Errorf("message = %q, the human message must be kept", err.Error())
+	}
+	if got := gatewayErrorCode(fmt.Errorf("wrapped: %w", err)); got != models.GatewayCodeSessionLimit {
+		t.Errorf("wrapped code = %q, want %s", got, models.GatewayCodeSessionLimit)
+	}
+	if got := gatewayErrorCode(fmt.Errorf("boom")); got != models.GatewayCodeInternal {
+		t.Errorf("plain error code = %q, want %s", got, models.GatewayCodeInternal)
+	}
+}
+
+func TestValidateTokenAndSubscriptionCodes(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.TransactionalDB, &models.WhatsappSession{}, &models.ServicesWhatsappCustomers{})
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano()%1e12)
+	userActive, userExpired := "u-act-"+suffix, "u-exp-"+suffix
+	sessions := []models.WhatsappSession{
+		{ID: "s-act-" + suffix, SessionID: "sid-act-" + suffix, Token: "tok-act-" + suffix, UserID: &userActive},
+		{ID: "s-exp-" + suffix, SessionID: "sid-exp-" + suffix, Token: "tok-exp-" + suffix, UserID: &userExpired},
+		{ID: "s-none-" + suffix, SessionID: "sid-none-" + suffix, Token: "tok-none-" + suffix},
+	}
+	subscriptions := []models.ServicesWhatsappCustomers{
+		{ID: "c-act-" + suffix, CustomerID: userActive, PackageID: "p", Status: "active", ExpiredAt: time.Now().Add(24 * time.Hour)},
+		{ID: "c-exp-" + suffix, CustomerID: userExpired, PackageID: "p", Status: "active", ExpiredAt: time.Now().Add(-24 * time.Hour)},
+	}
+	if err := db.Create(&sessions).Error; err != nil {
+		t.Fatalf("failed to create sessions: %v", err)
+	}
+	if err := db.Create(&subscriptions).Error; err != nil {
+		t.Fatalf("failed to create subscriptions: %v", err)
+	}
+	t.Cleanup(func() {
+		database.TransactionalDB.Delete(&sessions)
+		database.TransactionalDB.Delete(&subscriptions)
+	})
+
+	cases := map[string]string{
+		"tok-unknown-" + suffix: models.GatewayCodeTokenInvalid,
+		"tok-none-" + suffix:    models.GatewayCodeSessionNoUser,
+		"tok-exp-" + suffix:     models.GatewayCodeSubscriptionExpired,
+	}
+	for token, want := range cases {
+		_, err := validateTokenAndSubscription(token, "/chat/send/text")
+		if got := gatewayErrorCode(err); err == nil || got != want {
+			t.Errorf("token %s: err=%v code=%q, want %s", token, err, got, want)
+		}
+	}
+	if userID, err := validateTokenAndSubscription("tok-act-"+suffix, "/chat/send/text"); err != nil || userID != userActive {
+		t.Errorf("active token: user=%q err=%v", userID, err)
+	}
+}
+
+func TestTransformMessageRequestEditRevoke(t *testing.T) {
+	edit, err := transformMessageRequest([]byte(`{"to":"6281200000001@s.whatsapp.net","messageId":"3EB0ABC","text":"Harga terbaru Rp150.000"}`), "/chat/send/edit")
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if string(edit) != `{"Body":"Harga terbaru Rp150.000","Id":"3EB0ABC","Phone":"6281200000001"}` {
+		t.Errorf("edit = %s", edit)
+	}
+
+	revoke, err := transformMessageRequest([]byte(`{"to":"6281200000001","messageId":"3EB0ABC"}`), "/chat/send/revoke")
+	if err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if string(revoke) != `{"Id":"3EB0ABC","Phone":"6281200000001"}` {
+		t.Errorf("revoke = %s", revoke)
+	}
+
+	if _, err := transformMessageRequest([]byte(`{"to":"6281200000001","text":"tanpa id"}`), "/chat/send/edit"); err == nil {
+		t.Errorf("edit without messageId accepted")
+	}
+	if _, err := transformMessageRequest([]byte(`{"to":"6281200000001"}`), "/chat/send/revoke"); err == nil {
+		t.Errorf("revoke without messageId accepted")
+	}
+
+	if _, err := transformMessageRequest([]byte(`{"to":"0812abc","text":"halo"}`), "/chat/send/text"); !errors.Is(err, services.ErrInvalidPhone) {
+		t.Errorf("invalid recipient: err = %v, want ErrInvalidPhone", err)
+	}
+
+	if got := waServerPath("/chat/send/revoke"); got != "/chat/delete" {
+		t.Errorf("revoke is proxied to %s, want /chat/delete", got)
+	}
+	if got := waServerPath("/chat/send/edit"); got != "/chat/send/edit" {
+		t.Errorf("edit is proxied to %s, want /chat/send/edit", got)
+	}
+}
+
+func TestTransformMessageRequestExpiration(t *testing.T) {
+	text, err := transformMessageRequest([]byte(`{"to":"6281200000001","text":"Kode OTP 1234","expiration":86400}`), "/chat/send/text")
+	if err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	if string(text) != `{"Body":"Kode OTP 1234","Expiration":86400,"Phone":"6281200000001"}` {
+		t.Errorf("text = %s", text)
+	}
+
+	image, err := transformMessageRequest([]byte(`{"to":"6281200000001","caption":"Promo","expiration":604800}`), "/chat/send/image")
+	if err != nil {
+		t.Fatalf("image: %v", err)
+	}
+	if !strings.Contains(string(image), `"Expiration":604800`) {
+		t.Errorf("image = %s", image)
+	}
+
+	// No flag (or 0) keeps the request as before
+	for _, body := range []string{`{"to":"6281200000001","text":"halo"}`, `{"to":"6281200000001","text":"halo","expiration":0}`} {
+		plain, err := transformMessageRequest([]byte(body), "/chat/send/text")
+		if err != nil || string(plain) != `{"Body":"halo","Phone":"6281200000001"}` {
+			t.Errorf("%s -> %s, %v", body, plain, err)
+		}
+	}
+
+	for _, body := range []string{
+		`{"to":"6281200000001","text":"halo","expiration":3600}`,
+		`{"to":"6281200000001","text":"halo","expiration":86400.5}`,
+		`{"to":"6281200000001","text":"halo","expiration":"24h"}`,
+	} {
+		if _, err := transformMessageRequest([]byte(body), "/chat/send/text"); !errors.Is(err, errInvalidMessageField) {
+			t.Errorf("%s: err = %v, want errInvalidMessageField", body, err)
+		}
+	}
+}
+
+func TestTransformMessageRequestPollTemplate(t *testing.T) {
+	poll, err := transformMessageRequest([]byte(`{"to":"6281200000001","name":"Jadwal servis?","options":["Senin","Selasa"]}`), "/chat/send/poll")
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if string(poll) != `{"Name":"Jadwal servis?","Options":["Senin","Selasa"],"Phone":"6281200000001","SelectableCount":1}` {
+		t.Errorf("poll = %s", poll)
+	}
+
+	template, err := transformMessageRequest([]byte(`{"to":"6281200000001","templateName":"order_update","language":"id","params":["Budi","12 Mei"]}`), "/chat/send/template")
+	if err != nil {
+		t.Fatalf("template: %v", err)
+	}
+	if string(template) != `{"Language":"id","Name":"order_update","Params":["Budi","12 Mei"],"Phone":"6281200000001"}` {
+		t.Errorf("template = %s", template)
+	}
+
+	invalid := []struct {
+		name, body, path string
+	}{
+		{"poll without name", `{"to":"6281200000001","options":["a","b"]}`, "/chat/send/poll"},
+		{"poll without options", `{"to":"6281200000001","name":"q"}`, "/chat/send/poll"},
+		{"poll with one option", `{"to":"6281200000001","name":"q","options":["a"]}`, "/chat/send/poll"},
+		{"poll with duplicate options", `{"to":"6281200000001","name":"q","options":["a","a"]}`, "/chat/send/poll"},
+		{"poll with empty option", `{"to":"6281200000001","name":"q","options":["a",""]}`, "/chat/send/poll"},
+		{"poll selectableCount too high", `{"to":"6281200000001","name":"q","options":["a","b"],"selectableCount":3}`, "/chat/send/poll"},
+		{"poll selectableCount not a number", `{"to":"6281200000001","name":"q","options":["a","b"],"selectableCount":"1"}`, "/chat/send/poll"},
+		{"template without name", `{"to":"6281200000001","params":["a"]}`, "/chat/send/template"},
+		{"template with non-string params", `{"to":"6281200000001","templateName":"t","params":[1]}`, "/chat/send/template"},
+		{"template params not an array", `{"to":"6281200000001","templateName":"t","params":"a"}`, "/chat/send/template"},
+	}
+	for _, tc := range invalid {
+		if _, err := transformMessageRequest([]byte(tc.body), tc.path); !errors.Is(err, errInvalidMessageField) {
+			t.Errorf("%s: err = %v, want errInvalidMessageField", tc.name, err)
+		}
+	}
+}
+
+func TestRespondDryRunValidatesWithoutForwarding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run reached the WA server: %s %s", r.Method, r.URL.Path)
+	}))
+	defer waServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	t.Setenv("DRY_RUN", "true")
+
+	router := gin.New()
+	router.Any("/wa/*path", func(c *gin.Context) { respondDryRun(c, c.Param("path")) })
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, "/wa"+path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/chat/send/text", `{"to":"6281200000001","text":"halo"}`)
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			ID string `json:"Id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if rec.Code != http.StatusOK || !resp.Success || !strings.HasPrefix(resp.Data.ID, "dryrun_") {
+		t.Errorf("send: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Invalid sends are rejected exactly like real ones
+	if rec := send(http.MethodPost, "/chat/send/text", `{"to":"0812abc","text":"halo"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid recipient: status %d, want 400", rec.Code)
+	}
+
+	if rec := send(http.MethodGet, "/session/status", ""); rec.Code != http.StatusOK {
+		t.Errorf("non-send call: status %d, want 200", rec.Code)
+	}
+}
+
+func TestProxyImageRequestEnforcesSizeLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	forwarded := 0
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code":200,"success":true}`))
+	}))
+	defer waServer.Close()
+	image := append([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}, make([]byte, 2000)...)
+	imageServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(image)
+	}))
+	defer imageServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	t.Setenv("GATEWAY_IMAGE_MAX_BODY_BYTES", "1024")
+	t.Setenv("GATEWAY_IMAGE_MAX_DOWNLOAD_BYTES", "1024")
+
+	router := gin.New()
+	router.POST("/wa/chat/send/image", func(c *gin.Context) { proxyImageRequest(c, "/chat/send/image") })
+	send := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/wa/chat/send/image", strings.NewReader(body)))
+		var resp models.GatewayResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Code
+	}
+
+	// Body over the limit
+	huge := `{"Phone":"6281200000001","Image":"data:image/png;base64,` + strings.Repeat("A", 2048) + `"}`
+	if status, code := send(huge); status != http.StatusRequestEntityTooLarge || code != models.GatewayCodePayloadTooLarge {
+		t.Errorf("huge body: %d %q, want 413 %s", status, code, models.GatewayCodePayloadTooLarge)
+	}
+
+	// Remote image over the download limit
+	if status, code := send(`{"Phone":"6281200000001","Image":"` + imageServer.URL + `/big.png"}`); status != http.StatusRequestEntityTooLarge || code != models.GatewayCodePayloadTooLarge {
+		t.Errorf("big download: %d %q, want 413 %s", status, code, models.GatewayCodePayloadTooLarge)
+	}
+	if forwarded != 0 {
+		t.Fatalf("oversized requests reached the WA server %d time(s)", forwarded)
+	}
+
+	// Within both limits the (converted) request is forwarded
+	t.Setenv("GATEWAY_IMAGE_MAX_DOWNLOAD_BYTES", "4096")
+	t.Setenv("GATEWAY_IMAGE_MAX_BODY_BYTES", "4096")
+	if status, _ := send(`{"Phone":"6281200000001","Image":"` + imageServer.URL + `/ok.png"}`); status != http.StatusOK || forwarded != 1 {
+		t.Errorf("small image: status %d, forwarded %d, want 200 and 1", status, forwarded)
+	}
+}
+
+func TestProxiedSendResultReadsWAServerBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	waBody := `{"code":200,"success":true,"data":{"Id":"3EB0OK"}}`
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(waBody))
+	}))
+	defer waServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+
+	var result services.WASendResult
+	router := gin.New()
+	router.POST("/wa/chat/send/text", func(c *gin.Context) {
+		result = proxiedSendResult(c, proxyToWAServer(c, "/chat/send/text"))
+	})
+	send := func() int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/wa/chat/send/text",
+			strings.NewReader(`{"to":"6281200000001","text":"halo"}`)))
+		return rec.Code
+	}
+
+	if status := send(); status != http.StatusOK || !result.OK || result.MessageID != "3EB0OK" {
+		t.Errorf("successful send: %d %+v", status, result)
+	}
+
+	// 200 from the WA server, but the body says the send failed: the client still gets the WA
+	// server's answer, stats see a failure
+	waBody = `{"code":500,"success":false,"error":"not on whatsapp"}`
+	if status := send(); status != http.StatusOK || result.OK || result.Error != "not on whatsapp" {
+		t.Errorf("failed send: %d %+v", status, result)
+	}
+}
+
+func TestServeSessionQRCachesAndDetectsConnected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fetches := 0
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"code":200,"success":true,"data":{"QRCode":"data:image/png;base64,QR%d"}}`, fetches)
+	}))
+	defer waServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	t.Setenv("WA_QR_CACHE_TTL_MS", "60000")
+	t.Cleanup(func() { services.InvalidateQRCache("qr-token") })
+
+	connected := false
+	orig := sessionConnected
+	sessionConnected = func(token string) (bool, error) { return connected, nil }
+	defer func() { sessionConnected = orig }()
+
+	router := gin.New()
+	router.GET("/wa/session/qr", func(c *gin.Context) { serveSessionQR(c, "qr-token") })
+	poll := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wa/session/qr", nil))
+		return rec
+	}
+
+	first, second := poll(), poll()
+	if first.Code != http.StatusOK || second.Code != http.StatusOK || fetches != 1 {
+		t.Fatalf("two polls: %d / %d with %d fetches, want 200 / 200 with 1", first.Code, second.Code, fetches)
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("second poll should be the cached QR: %s (X-Cache %q)", second.Body.String(), second.Header().Get("X-Cache"))
+	}
+
+	// A connection event drops the cached code
+	services.InvalidateQRCache("qr-token")
+	if rec := poll(); !strings.Contains(rec.Body.String(), "QR2") || fetches != 2 {
+		t.Errorf("after invalidation: %s with %d fetches, want a fresh QR", rec.Body.String(), fetches)
+	}
+
+	connected = true
+	rec := poll()
+	var resp models.GatewayResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusConflict || resp.Code != models.GatewayCodeSessionConnected || fetches != 2 {
+		t.Errorf("connected session: %d %q with %d fetches, want 409 %s and no fetch", rec.Code, resp.Code, fetches, models.GatewayCodeSessionConnected)
+	}
+}
+
+func TestGatewayRouteValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/wa/*path", WhatsAppGateway)
+
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodPost, "/wa/chat/send/unknown", http.StatusNotFound, models.GatewayCodeRouteNotFound},
+		{http.MethodGet, "/wa/chat/send/text", http.StatusMethodNotAllowed, models.GatewayCodeMethodNotAllowed},
+		{http.MethodPost, "/wa/user/lid/", http.StatusNotFound, models.GatewayCodeRouteNotFound},
+		// Known endpoints go on to token validation
+		{http.MethodPost, "/wa/chat/send/text", http.StatusUnauthorized, models.GatewayCodeTokenRequired},
+		{http.MethodGet, "/wa/user/lid/6281234567890", http.StatusUnauthorized, models.GatewayCodeTokenRequired},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		var resp models.GatewayResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != tt.status || resp.Code != tt.code {
+			t.Errorf("%s %s: %d %q, want %d %s", tt.method, tt.path, rec.Code, resp.Code, tt.status, tt.code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/wa/session/qr", nil))
+	if allow := rec.Header().Get("Allow"); allow != http.MethodGet {
+		t.Errorf("Allow = %q, want GET", allow)
+	}
+
+	// Global endpoints are proxied without a token and without the route check
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer waServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wa/health", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ok"`) {
+		t.Errorf("strict mode /wa/health: %d %s, want the WA server's 200", rec.Code, rec.Body.String())
+	}
+
+	// Passthrough mode leaves unknown endpoints to the WA server (here: token validation first)
+	t.Setenv("GATEWAY_ROUTE_MODE", "passthrough")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/wa/chat/send/unknown", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("passthrough: status %d, want 401 (not rejected as unknown)", rec.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for the /chat/send/image proxy (override via .env)
+const (
+	defaultImageMaxBodyBytes       = 25 << 20 // a 16MB image as base64 plus JSON
+	defaultImageMaxDownloadBytes   = 16 << 20 // WhatsApp's own image limit
+	defaultImageProxyTimeoutSecs   = 60
+	defaultImageDownloadTimeoutSec = 30
+)
+
+// errImageTooLarge - the request body or a downloaded image is over its configured limit (413)
+var errImageTooLarge = errors.New("image too large")
+
+// imageMaxBodyBytes returns GATEWAY_IMAGE_MAX_BODY_BYTES (request body limit of the image endpoint)
+func imageMaxBodyBytes() int64 {
+	limit := config.GetEnvInt("GATEWAY_IMAGE_MAX_BODY_BYTES", defaultImageMaxBodyBytes)
+	if limit <= 0 {
+		return defaultImageMaxBodyBytes
+	}
+	return int64(limit)
+}
+
+// imageMaxDownloadBytes returns GATEWAY_IMAGE_MAX_DOWNLOAD_BYTES (limit for images fetched from a URL)
+func imageMaxDownloadBytes() int64 {
+	limit := config.GetEnvInt("GATEWAY_IMAGE_MAX_DOWNLOAD_BYTES", defaultImageMaxDownloadBytes)
+	if limit <= 0 {
+		return defaultImageMaxDownloadBytes
+	}
+	return int64(limit)
+}
+
+// imageProxyTimeout returns GATEWAY_IMAGE_TIMEOUT_SECONDS (WA server call of the image endpoint)
+func imageProxyTimeout() time.Duration {
+	secs := config.GetEnvInt("GATEWAY_IMAGE_TIMEOUT_SECONDS", defaultImageProxyTimeoutSecs)
+	if secs <= 0 {
+		secs = defaultImageProxyTimeoutSecs
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// imageDownloadTimeout returns GATEWAY_IMAGE_DOWNLOAD_TIMEOUT_SECONDS (fetching an image URL)
+func imageDownloadTimeout() time.Duration {
+	secs := config.GetEnvInt("GATEWAY_IMAGE_DOWNLOAD_TIMEOUT_SECONDS", defaultImageDownloadTimeoutSec)
+	if secs <= 0 {
+		secs = defaultImageDownloadTimeoutSec
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// readImageDownload reads a downloaded image, failing with errImageTooLarge once it passes maxBytes.
+// A Content-Length over the limit is refused before reading anything.
+func readImageDownload(resp *http.Response, maxBytes int64) ([]byte, error) {
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", errImageTooLarge, resp.ContentLength, maxBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %v", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", errImageTooLarge, maxBytes)
+	}
+	return data, nil
+}
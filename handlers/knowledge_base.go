@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListKnowledgeDocuments lists the user's knowledge base documents
+// GET /bot/kb?includeInactive=true
+func ListKnowledgeDocuments(c *gin.Context) {
+	userID, ok := knowledgeUserID(c)
+	if !ok {
+		return
+	}
+
+	docs, err := services.ListKnowledgeDocuments(userID, c.Query("includeInactive") == "true")
+	if err != nil {
+		respondKnowledgeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Documents retrieved",
+		"data": gin.H{
+			"count":     len(docs),
+			"documents": docs,
+			"kinds":     services.KnowledgeKinds,
+		},
+	})
+}
+
+// CreateKnowledgeDocument adds a document and binds it to the user's active bot
+// POST /bot/kb {"title","kind","content"}
+func CreateKnowledgeDocument(c *gin.Context) {
+	userID, ok := knowledgeUserID(c)
+	if !ok {
+		return
+	}
+	var req services.KnowledgeDocumentInput
+	if !bindKnowledgeRequest(c, &req) {
+		return
+	}
+
+	doc, bound, err := services.CreateKnowledgeDocument(userID, req)
+	if err != nil {
+		respondKnowledgeError(c, err)
+		return
+	}
+	log.Printf("📚 Knowledge document %s created for %s (kind=%s, bound=%v)", doc.ID, userID, doc.Kind, bound)
+
+	message := "Document created"
+	if !bound {
+		message = "Document created (no active bot to bind it to yet)"
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"document": doc,
+			"bound":    bound,
+		},
+	})
+}
+
+// UpdateKnowledgeDocument edits title / kind / content / isActive of a document
+// PUT /bot/kb/:id
+func UpdateKnowledgeDocument(c *gin.Context) {
+	userID, ok := knowledgeUserID(c)
+	if !ok {
+		return
+	}
+	var req services.KnowledgeDocumentInput
+	if !bindKnowledgeRequest(c, &req) {
+		return
+	}
+
+	doc, err := services.UpdateKnowledgeDocument(userID, c.Param("id"), req)
+	if err != nil {
+		respondKnowledgeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Document updated",
+		"data":    doc,
+	})
+}
+
+// DeleteKnowledgeDocument deactivates a document (kept for history, no longer used by any bot)
+// DELETE /bot/kb/:id
+func DeleteKnowledgeDocument(c *gin.Context) {
+	userID, ok := knowledgeUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeactivateKnowledgeDocument(userID, c.Param("id")); err != nil {
+		respondKnowledgeError(c, err)
+		return
+	}
+	log.Printf("📚 Knowledge document %s deactivated by %s", c.Param("id"), userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Document deactivated",
+	})
+}
+
+// knowledgeUserID returns the JWT user (JWTMiddleware sets user_id)
+func knowledgeUserID(c *gin.Context) (string, bool) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"success": false,
+			"message": "User ID not found",
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+func bindKnowledgeRequest(c *gin.Context, req *services.KnowledgeDocumentInput) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"success": false,
+			"message": "Invalid request: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// respondKnowledgeError maps knowledge base failures: validation = 400, unknown document = 404
+func respondKnowledgeError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidDocument):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrDocumentNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"success": false,
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateKnowledgeDocumentValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/bot/kb", func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("user_id", user)
+		}
+		CreateKnowledgeDocument(c)
+	})
+
+	tests := []struct {
+		name string
+		user string
+		body string
+		want int
+	}{
+		{name: "no JWT user", body: `{"title":"Harga","content":"Rp100.000"}`, want: http.StatusUnauthorized},
+		{name: "malformed JSON", user: "user-1", body: `{"title":`, want: http.StatusBadRequest},
+		{name: "unknown kind", user: "user-1", body: `{"title":"Harga","kind":"blog","content":"Rp100.000"}`, want: http.StatusBadRequest},
+		{name: "missing content", user: "user-1", body: `{"title":"Harga"}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/bot/kb", bytes.NewBufferString(tt.body))
+			req.Header.Set("X-Test-User", tt.user)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d %s, want %d", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Campaign list paging defaults (?page=&limit=)
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// listQuery is a parsed ?page=&limit=&status=&from=&to= query of a list endpoint
+type listQuery struct {
+	Page   int
+	Limit  int
+	Status string
+	From   *time.Time
+	To     *time.Time // exclusive
+}
+
+// parseListQuery reads paging and filters. from/to accept RFC3339 or YYYY-MM-DD (a date "to"
+// includes that whole day); status must be one of allowedStatuses.
+func parseListQuery(c *gin.Context, allowedStatuses ...string) (listQuery, error) {
+	q := listQuery{Page: 1, Limit: defaultListLimit}
+
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page <= 0 {
+			return q, fmt.Errorf("page must be a positive integer")
+		}
+		q.Page = page
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		if limit > maxListLimit {
+			limit = maxListLimit
+		}
+		q.Limit = limit
+	}
+
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		valid := false
+		for _, allowed := range allowedStatuses {
+			if status == allowed {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return q, fmt.Errorf("status must be one of: %s", strings.Join(allowedStatuses, ", "))
+		}
+		q.Status = status
+	}
+
+	var err error
+	if q.From, err = parseListTime(c.Query("from"), false); err != nil {
+		return q, fmt.Errorf("invalid from: %w", err)
+	}
+	if q.To, err = parseListTime(c.Query("to"), true); err != nil {
+		return q, fmt.Errorf("invalid to: %w", err)
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return q, fmt.Errorf("from must be before to")
+	}
+	return q, nil
+}
+
+// parseListTime parses RFC3339 or YYYY-MM-DD; endOfDay moves a plain date to the next midnight
+func parseListTime(raw string, endOfDay bool) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, fmt.Errorf("use RFC3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// filter applies status and created_at range to query (paging is applied by page). The result is
+// a new session, so it can run both the Count and the Find.
+func (q listQuery) filter(query *gorm.DB) *gorm.DB {
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.From != nil {
+		query = query.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("created_at < ?", *q.To)
+	}
+	return query.Session(&gorm.Session{})
+}
+
+// page applies newest-first ordering, offset and limit
+func (q listQuery) page(query *gorm.DB) *gorm.DB {
+	return query.Order("created_at DESC, id DESC").Offset((q.Page - 1) * q.Limit).Limit(q.Limit)
+}
+
+// pagination describes the returned page for a total row count
+func (q listQuery) pagination(total int64) *models.Pagination {
+	return &models.Pagination{
+		Page:       q.Page,
+		Limit:      q.Limit,
+		Total:      total,
+		TotalPages: int((total + int64(q.Limit) - 1) / int64(q.Limit)),
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func listQueryFor(target string) (listQuery, error) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	return parseListQuery(c, "pending", "completed")
+}
+
+func TestParseListQuery(t *testing.T) {
+	q, err := listQueryFor("/x")
+	if err != nil || q.Page != 1 || q.Limit != defaultListLimit || q.Status != "" || q.From != nil || q.To != nil {
+		t.Errorf("defaults = %+v, %v", q, err)
+	}
+
+	q, err = listQueryFor("/x?page=3&limit=1000&status=completed&from=2026-01-01&to=2026-01-31")
+	if err != nil {
+		t.Fatalf("parseListQuery: %v", err)
+	}
+	if q.Page != 3 || q.Limit != maxListLimit || q.Status != "completed" {
+		t.Errorf("q = %+v", q)
+	}
+	if !q.From.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !q.To.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("range = %v .. %v, want Jan 1 .. Feb 1 (whole day of the 31st)", q.From, q.To)
+	}
+	if p := q.pagination(401); p.TotalPages != 3 || p.Total != 401 {
+		t.Errorf("pagination = %+v", p)
+	}
+
+	for _, bad := range []string{
+		"/x?page=0", "/x?limit=-1", "/x?limit=abc", "/x?status=archived",
+		"/x?from=yesterday", "/x?from=2026-02-01&to=2026-01-01",
+	} {
+		if _, err := listQueryFor(bad); err == nil {
+			t.Errorf("%s should be rejected", bad)
+		}
+	}
+}
+
+func TestGetBulkCampaignsPaginatesAndFilters(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.TransactionalDB, &models.Campaign{}, &models.BulkCampaign{}, &models.BulkCampaignItem{})
+
+	userID := fmt.Sprintf("test_user_%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Unscoped().Where("user_id = ?", userID).Delete(&models.BulkCampaign{}) })
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		status := models.BulkCampaignStatusCompleted
+		if i%2 == 1 {
+			status = models.BulkCampaignStatusFailed
+		}
+		bc := models.BulkCampaign{UserID: userID, Name: fmt.Sprintf("bc-%d", i), Type: models.CampaignTypeText,
+			Status: status, CreatedAt: base.AddDate(0, 0, i)}
+		if err := db.Create(&bc).Error; err != nil {
+			t.Fatalf("failed to seed bulk campaign: %v", err)
+		}
+	}
+	// Someone else's campaign must never show up
+	other := models.BulkCampaign{UserID: userID + "_other", Name: "other", Type: models.CampaignTypeText, Status: models.BulkCampaignStatusCompleted}
+	db.Create(&other)
+	t.Cleanup(func() { db.Unscoped().Delete(&other) })
+
+	router := gin.New()
+	router.GET("/bulk", func(c *gin.Context) { c.Set("user_id", userID); GetBulkCampaigns(c) })
+	list := func(query string) models.BulkCampaignListResponse {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bulk"+query, nil))
+		var resp models.BulkCampaignListResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", query, rec.Code, rec.Body.String())
+		}
+		return resp
+	}
+
+	resp := list("?limit=2&page=2")
+	if resp.Pagination.Total != 5 || resp.Pagination.TotalPages != 3 || len(resp.Data) != 2 || resp.Data[0].Name != "bc-2" {
+		t.Errorf("page 2 = %+v, names %v", resp.Pagination, bulkCampaignNames(resp.Data))
+	}
+
+	resp = list("?status=completed&from=2026-03-02&to=2026-03-05")
+	if resp.Pagination.Total != 1 || len(resp.Data) != 1 || resp.Data[0].Name != "bc-2" {
+		t.Errorf("filtered = %+v, names %v", resp.Pagination, bulkCampaignNames(resp.Data))
+	}
+}
+
+func bulkCampaignNames(campaigns []models.BulkCampaign) []string {
+	names := make([]string, len(campaigns))
+	for i, bc := range campaigns {
+		names[i] = bc.Name
+	}
+	return names
+}
@@ -36,8 +36,9 @@ func SessionMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set session_id in context for use in handlers
+		// Set session_id and token in context for use in handlers
 		c.Set("session_id", session.SessionID)
+		c.Set("session_token", token)
 		c.Next()
 	}
 }
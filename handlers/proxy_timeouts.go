@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Endpoint classes with their own WA server timeout (GATEWAY_TIMEOUT_<CLASS>_SECONDS)
+const (
+	proxyClassText  = "text"  // message sends without media: text, location, contact, poll, edit, ...
+	proxyClassMedia = "media" // media sends and downloads (except /chat/send/image, see GATEWAY_IMAGE_TIMEOUT_SECONDS)
+	proxyClassAdmin = "admin" // /admin/* (user management on the WA server)
+	proxyClassQuery = "query" // everything else: session status, groups, user info, ...
+)
+
+// defaultProxyTimeoutSecs is the timeout of every class unless configured (the former flat 30s)
+const defaultProxyTimeoutSecs = 30
+
+// mediaSendEndpoints upload a file to WhatsApp before answering
+var mediaSendEndpoints = map[string]bool{
+	"/chat/send/image":    true,
+	"/chat/send/audio":    true,
+	"/chat/send/document": true,
+	"/chat/send/video":    true,
+	"/chat/send/sticker":  true,
+}
+
+// proxyEndpointClass returns the timeout class of a gateway path
+func proxyEndpointClass(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/admin"):
+		return proxyClassAdmin
+	case mediaSendEndpoints[path] || strings.HasPrefix(path, "/chat/download"):
+		return proxyClassMedia
+	case isMessageEndpoint(path):
+		return proxyClassText
+	default:
+		return proxyClassQuery
+	}
+}
+
+// proxyTimeout returns GATEWAY_TIMEOUT_TEXT_SECONDS / _MEDIA_ / _ADMIN_ / _QUERY_ for the path's class
+func proxyTimeout(path string) time.Duration {
+	key := fmt.Sprintf("GATEWAY_TIMEOUT_%s_SECONDS", strings.ToUpper(proxyEndpointClass(path)))
+	secs := config.GetEnvInt(key, defaultProxyTimeoutSecs)
+	if secs <= 0 {
+		secs = defaultProxyTimeoutSecs
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// isTimeout reports whether a WA server call failed because its timeout passed
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// respondWAServerError answers a failed WA server call: 504 WA_SERVER_TIMEOUT when it did not answer
+// within timeout, 502 WA_SERVER_UNAVAILABLE otherwise. Returns the status sent.
+func respondWAServerError(c *gin.Context, err error, timeout time.Duration) int {
+	if isTimeout(err) {
+		c.JSON(http.StatusGatewayTimeout, models.GatewayResponse{
+			Status:  http.StatusGatewayTimeout,
+			Code:    models.GatewayCodeWAServerTimeout,
+			Message: fmt.Sprintf("WhatsApp server did not answer within %s", timeout),
+		})
+		return http.StatusGatewayTimeout
+	}
+	c.JSON(http.StatusBadGateway, models.GatewayResponse{
+		Status:  http.StatusBadGateway,
+		Code:    models.GatewayCodeWAServerUnavailable,
+		Message: "Failed to reach WhatsApp server",
+	})
+	return http.StatusBadGateway
+}
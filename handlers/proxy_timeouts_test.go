@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProxyEndpointClass(t *testing.T) {
+	tests := map[string]string{
+		"/chat/send/text":       proxyClassText,
+		"/chat/send/poll":       proxyClassText,
+		"/chat/send/revoke":     proxyClassText,
+		"/chat/send/document":   proxyClassMedia,
+		"/chat/send/video":      proxyClassMedia,
+		"/chat/downloadimage":   proxyClassMedia,
+		"/admin/users":          proxyClassAdmin,
+		"/session/status":       proxyClassQuery,
+		"/group/info":           proxyClassQuery,
+		"/user/check":           proxyClassQuery,
+		"/chat/presence":        proxyClassQuery,
+		"/session/qr":           proxyClassQuery,
+		"/chat/send/unknownnew": proxyClassQuery,
+	}
+	for path, want := range tests {
+		if got := proxyEndpointClass(path); got != want {
+			t.Errorf("proxyEndpointClass(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestProxyTimeout(t *testing.T) {
+	if got := proxyTimeout("/chat/send/text"); got != 30*time.Second {
+		t.Errorf("default = %v, want 30s", got)
+	}
+	t.Setenv("GATEWAY_TIMEOUT_MEDIA_SECONDS", "120")
+	t.Setenv("GATEWAY_TIMEOUT_QUERY_SECONDS", "-1")
+	if got := proxyTimeout("/chat/send/document"); got != 120*time.Second {
+		t.Errorf("media = %v, want 120s", got)
+	}
+	if got := proxyTimeout("/chat/send/text"); got != 30*time.Second {
+		t.Errorf("text must not follow the media setting, got %v", got)
+	}
+	if got := proxyTimeout("/group/info"); got != 30*time.Second {
+		t.Errorf("invalid query timeout = %v, want the 30s default", got)
+	}
+}
+
+func TestProxyToWAServerTimeoutReturns504(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer waServer.Close()
+	defer close(release)
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	t.Setenv("GATEWAY_TIMEOUT_QUERY_SECONDS", "1")
+
+	router := gin.New()
+	router.GET("/wa/group/info", func(c *gin.Context) { proxyToWAServer(c, "/group/info") })
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wa/group/info", nil))
+
+	var resp models.GatewayResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusGatewayTimeout || resp.Code != models.GatewayCodeWAServerTimeout {
+		t.Errorf("stuck WA server: %d %q, want 504 %s", rec.Code, resp.Code, models.GatewayCodeWAServerTimeout)
+	}
+}
+
+func TestRespondWAServerErrorUnreachable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	if status := respondWAServerError(c, errors.New("connection refused"), time.Second); status != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", status)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// setupSessionEventTestDB points database.TransactionalDB at TEST_DATABASE_DSN with one session row
+func setupSessionEventTestDB(t *testing.T, connected, loggedIn bool) (*gorm.DB, string) {
+	t.Helper()
+	db := setupHandlerTestDB(t, &database.TransactionalDB, &models.WhatsappSession{})
+
+	suffix := time.Now().UnixNano()
+	token := fmt.Sprintf("test_token_%d", suffix)
+	session := models.WhatsappSession{
+		ID:        fmt.Sprintf("s%d", suffix%1e12),
+		SessionID: fmt.Sprintf("test_session_%d", suffix),
+		Token:     token,
+		Connected: connected,
+		LoggedIn:  loggedIn,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("failed to seed session: %v", err)
+	}
+	t.Cleanup(func() { db.Where("token = ?", token).Delete(&models.WhatsappSession{}) })
+	return db, token
+}
+
+func postSessionEvent(token, eventType string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhook/ai", HandleAIWebhook)
+
+	body := fmt.Sprintf(`{"instanceName":%q,"type":%q,"event":{}}`, token, eventType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook/ai", bytes.NewBufferString(body)))
+	return rec
+}
+
+func TestSessionEventUpdatesSessionState(t *testing.T) {
+	t.Setenv("ALERT_WEBHOOK_URL", "")
+
+	tests := []struct {
+		event                   string
+		startConnected, startIn bool
+		wantConnected, wantIn   bool
+		wantStatus              string
+	}{
+		{services.SessionEventConnected, false, false, true, true, "connected"},
+		{services.SessionEventDisconnected, true, true, false, true, "disconnected"},
+		{services.SessionEventLoggedOut, true, true, false, false, "logged_out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			db, token := setupSessionEventTestDB(t, tt.startConnected, tt.startIn)
+
+			rec := postSessionEvent(token, tt.event)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var session models.WhatsappSession
+			if err := db.Where("token = ?", token).First(&session).Error; err != nil {
+				t.Fatalf("failed to reload session: %v", err)
+			}
+			if session.Connected != tt.wantConnected || session.LoggedIn != tt.wantIn || session.Status != tt.wantStatus {
+				t.Errorf("session = connected:%v loggedIn:%v status:%q, want %v/%v/%q",
+					session.Connected, session.LoggedIn, session.Status, tt.wantConnected, tt.wantIn, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSessionEventUnknownSession(t *testing.T) {
+	setupSessionEventTestDB(t, false, false)
+
+	rec := postSessionEvent("no_such_token", services.SessionEventDisconnected)
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte("Session not found")) {
+		t.Errorf("unknown session = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"os"
 	"time"
 
+	"genfity-wa-support/database"
+	"genfity-wa-support/services"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -40,3 +43,48 @@ func HealthCheck(c *gin.Context) {
 		"mode":    "ai-bot",
 	})
 }
+
+// DeepHealthCheck checks dependencies (primary DB, data provider) and reports AI queue depth and
+// the job LISTEN connection. Returns 503 when the primary DB is unreachable; an overloaded queue
+// or a LISTEN connection down past its threshold is reported as "degraded"
+func DeepHealthCheck(c *gin.Context) {
+	status := "healthy"
+	httpStatus := http.StatusOK
+	checks := gin.H{}
+
+	if sqlDB, err := database.GetDB().DB(); err != nil || sqlDB.Ping() != nil {
+		checks["database"] = "down"
+		status = "unhealthy"
+		httpStatus = http.StatusServiceUnavailable
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if provider, err := services.GetDataProvider(); err != nil {
+		checks["data_provider"] = err.Error()
+	} else if err := provider.CheckHealth(); err != nil {
+		checks["data_provider"] = err.Error()
+	} else {
+		checks["data_provider"] = "ok"
+	}
+
+	queue := services.GetQueueStats()
+	if queue.Overloaded && status == "healthy" {
+		status = "degraded"
+	}
+
+	// LISTEN down for long: jobs still run via polling, just slower
+	listener := services.GetListenerHealth()
+	if listener.Degraded && status == "healthy" {
+		status = "degraded"
+	}
+
+	c.JSON(httpStatus, gin.H{
+		"status":   status,
+		"time":     time.Now().Format(time.RFC3339),
+		"service":  "clivy-wa-support",
+		"checks":   checks,
+		"queue":    queue,
+		"listener": listener,
+	})
+}
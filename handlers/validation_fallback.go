@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/models"
+)
+
+// degradedValidationWindow returns GATEWAY_DEGRADED_VALIDATION_SECONDS: how long a successful
+// token + subscription check may stand in for a failed one while the transactional DB is
+// unreachable (default 0 = off: strict deployments reject every request the DB can't confirm)
+func degradedValidationWindow() time.Duration {
+	secs := config.GetEnvInt("GATEWAY_DEGRADED_VALIDATION_SECONDS", 0)
+	if secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// validatedToken is the last successful validation of a token
+type validatedToken struct {
+	userID         string
+	subscriptionTo time.Time // subscription expiry - never served past it
+	validatedAt    time.Time
+}
+
+var (
+	validatedTokensMu sync.Mutex
+	validatedTokens   = make(map[string]validatedToken)
+)
+
+// rememberValidation records a successful validation (only while the fallback is enabled)
+func rememberValidation(token, userID string, subscriptionTo time.Time) {
+	if degradedValidationWindow() == 0 {
+		return
+	}
+	validatedTokensMu.Lock()
+	validatedTokens[token] = validatedToken{userID: userID, subscriptionTo: subscriptionTo, validatedAt: time.Now()}
+	validatedTokensMu.Unlock()
+}
+
+// forgetValidation drops a token the DB definitively rejected (invalid, no user, no/expired subscription)
+func forgetValidation(token string) {
+	validatedTokensMu.Lock()
+	delete(validatedTokens, token)
+	validatedTokensMu.Unlock()
+}
+
+// degradedValidation returns the token's last known good user when the validation failed only
+// because the DB could not be queried, the result is within GATEWAY_DEGRADED_VALIDATION_SECONDS
+// and the subscription has not expired since
+func degradedValidation(token string, cause error) (string, bool) {
+	var gwErr *gatewayError
+	if !errors.As(cause, &gwErr) || gwErr.code != models.GatewayCodeInternal {
+		return "", false
+	}
+	window := degradedValidationWindow()
+	if window == 0 {
+		return "", false
+	}
+
+	validatedTokensMu.Lock()
+	cached, ok := validatedTokens[token]
+	validatedTokensMu.Unlock()
+	now := time.Now()
+	if !ok || now.Sub(cached.validatedAt) > window || !now.Before(cached.subscriptionTo) {
+		return "", false
+	}
+
+	log.Printf("⚠️  Gateway serving DEGRADED: transactional DB check failed (%v), using token validation from %s ago",
+		cause, now.Sub(cached.validatedAt).Round(time.Second))
+	return cached.userID, true
+}
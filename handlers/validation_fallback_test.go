@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"genfity-wa-support/models"
+)
+
+// stubTokenCheck replaces the transactional DB lookup of validateTokenAndSubscription
+func stubTokenCheck(t *testing.T, check func(token string) (string, time.Time, error)) {
+	previous := checkTokenAndSubscription
+	t.Cleanup(func() {
+		checkTokenAndSubscription = previous
+		validatedTokensMu.Lock()
+		validatedTokens = make(map[string]validatedToken)
+		validatedTokensMu.Unlock()
+	})
+	checkTokenAndSubscription = check
+}
+
+func dbUp(token string) (string, time.Time, error) {
+	return "user-1", time.Now().Add(24 * time.Hour), nil
+}
+
+func dbDown(token string) (string, time.Time, error) {
+	return "", time.Time{}, newGatewayError(models.GatewayCodeInternal, "database error: connection refused")
+}
+
+func TestValidateTokenDegradedFallback(t *testing.T) {
+	stubTokenCheck(t, dbUp)
+	t.Setenv("GATEWAY_DEGRADED_VALIDATION_SECONDS", "60")
+
+	if _, err := validateTokenAndSubscription("tok", "/chat/send/text"); err != nil {
+		t.Fatalf("live validation: %v", err)
+	}
+
+	checkTokenAndSubscription = dbDown
+	userID, err := validateTokenAndSubscription("tok", "/chat/send/text")
+	if err != nil || userID != "user-1" {
+		t.Errorf("DB down after a recent validation = %q, %v; want the last known user", userID, err)
+	}
+	if _, err := validateTokenAndSubscription("other", "/chat/send/text"); gatewayErrorCode(err) != models.GatewayCodeInternal {
+		t.Errorf("never-validated token while DB down: err = %v, want INTERNAL_ERROR", err)
+	}
+}
+
+func TestValidateTokenDegradedFallbackLimits(t *testing.T) {
+	stubTokenCheck(t, dbUp)
+
+	// Off by default: nothing is served from memory
+	validateTokenAndSubscription("tok", "/chat/send/text")
+	checkTokenAndSubscription = dbDown
+	if _, err := validateTokenAndSubscription("tok", "/chat/send/text"); err == nil {
+		t.Error("fallback served with GATEWAY_DEGRADED_VALIDATION_SECONDS unset")
+	}
+
+	t.Setenv("GATEWAY_DEGRADED_VALIDATION_SECONDS", "60")
+
+	// Older than the window
+	rememberValidation("old", "user-1", time.Now().Add(time.Hour))
+	validatedTokensMu.Lock()
+	entry := validatedTokens["old"]
+	entry.validatedAt = time.Now().Add(-2 * time.Minute)
+	validatedTokens["old"] = entry
+	validatedTokensMu.Unlock()
+	if _, err := validateTokenAndSubscription("old", "/chat/send/text"); err == nil {
+		t.Error("fallback served past the window")
+	}
+
+	// Subscription expired since the last check
+	rememberValidation("lapsed", "user-1", time.Now().Add(-time.Second))
+	if _, err := validateTokenAndSubscription("lapsed", "/chat/send/text"); err == nil {
+		t.Error("fallback served past the subscription expiry")
+	}
+
+	// A definitive rejection drops the token
+	rememberValidation("revoked", "user-1", time.Now().Add(time.Hour))
+	checkTokenAndSubscription = func(token string) (string, time.Time, error) {
+		return "", time.Time{}, newGatewayError(models.GatewayCodeTokenInvalid, "invalid token")
+	}
+	validateTokenAndSubscription("revoked", "/chat/send/text")
+	checkTokenAndSubscription = dbDown
+	if _, err := validateTokenAndSubscription("revoked", "/chat/send/text"); err == nil {
+		t.Error("fallback served for a token the DB rejected")
+	}
+}
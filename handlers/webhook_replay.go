@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"genfity-wa-support/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayWebhook re-runs a stored raw webhook payload through HandleAIWebhook
+// POST /admin/webhook/replay/:messageId?force=true
+// Without force the message is usually reported as "Duplicate message" because it was
+// already saved; force removes the stored ai_chat_messages row first so it is processed again.
+func ReplayWebhook(c *gin.Context) {
+	messageID := c.Param("messageId")
+
+	raw, err := services.GetRawWebhook(messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if raw == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"success": false,
+			"message": "No stored webhook payload for message " + messageID,
+		})
+		return
+	}
+
+	if c.Query("force") == "true" {
+		if err := services.DeleteAIChatMessage(messageID); err != nil {
+			log.Printf("⚠️  [Replay] Failed to remove stored message %s: %v", messageID, err)
+		}
+	}
+
+	status, response := replayWebhookPayload([]byte(raw.Payload))
+	log.Printf("🔁 [Replay] Message %s replayed → %d", messageID, status)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"success": true,
+		"message": "Webhook replayed",
+		"data": gin.H{
+			"message_id":     messageID,
+			"stored_at":      raw.CreatedAt,
+			"webhook_status": status,
+			"webhook_result": response,
+		},
+	})
+}
+
+// replayWebhookPayload runs HandleAIWebhook on payload and returns its status and JSON response
+func replayWebhookPayload(payload []byte) (int, interface{}) {
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request, _ = http.NewRequest(http.MethodPost, "/webhook/ai", io.NopCloser(bytes.NewReader(payload)))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Set(webhookReplayKey, true)
+
+	HandleAIWebhook(ctx)
+
+	var response interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		response = rec.Body.String()
+	}
+	return rec.Code, response
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupHandlerTestDB points target (&database.DB or &database.TransactionalDB) at TEST_DATABASE_DSN
+// with tables migrated (skips the test when unset)
+func setupHandlerTestDB(t *testing.T, target **gorm.DB, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set - skipping database test")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("failed to migrate test tables: %v", err)
+	}
+
+	previous := *target
+	*target = db
+	t.Cleanup(func() { *target = previous })
+	return db
+}
+
+func TestRawWebhookStorageAndReplay(t *testing.T) {
+	db := setupHandlerTestDB(t, &database.DB, &models.RawWebhook{})
+	t.Setenv("AI_STORE_RAW_WEBHOOKS", "true")
+
+	messageID := fmt.Sprintf("TESTRAW%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Where("message_id = ?", messageID).Delete(&models.RawWebhook{}) })
+
+	// An own message is stored and then skipped without touching other services
+	payload := []byte(fmt.Sprintf(`{"instanceName":"test-session","event":{"Info":{"ID":%q,"Sender":"6281200000000@s.whatsapp.net","Chat":"6281200000000@s.whatsapp.net","Type":"text","Timestamp":%q,"IsFromMe":true},"Message":{"conversation":"halo"}}}`,
+		messageID, time.Now().Format(time.RFC3339)))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/webhook/ai", HandleAIWebhook)
+	router.POST("/admin/webhook/replay/:messageId", ReplayWebhook)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook/ai", bytes.NewReader(payload)))
+	var original map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &original); err != nil {
+		t.Fatalf("invalid webhook response: %s", rec.Body.String())
+	}
+
+	var stored []models.RawWebhook
+	db.Where("message_id = ?", messageID).Find(&stored)
+	if len(stored) != 1 || stored[0].Payload != string(payload) || stored[0].SessionTok != "test-session" {
+		t.Fatalf("expected the raw payload stored once, got %+v", stored)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/webhook/replay/"+messageID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("replay status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var replay struct {
+		Data struct {
+			WebhookStatus int                    `json:"webhook_status"`
+			WebhookResult map[string]interface{} `json:"webhook_result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &replay); err != nil {
+		t.Fatalf("invalid replay response: %s", rec.Body.String())
+	}
+	if replay.Data.WebhookStatus != http.StatusOK || replay.Data.WebhookResult["message"] != original["message"] {
+		t.Errorf("replay outcome %v differs from original %v", replay.Data.WebhookResult, original)
+	}
+
+	// Replays are not stored again
+	var count int64
+	db.Model(&models.RawWebhook{}).Where("message_id = ?", messageID).Count(&count)
+	if count != 1 {
+		t.Errorf("replay must not store the payload again, got %d rows", count)
+	}
+}
+
+func TestReplayWebhookPayloadMatchesDirectCall(t *testing.T) {
+	// Invalid JSON needs no database: both paths must reject it the same way
+	payload := []byte(`{"instanceName":`)
+
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/webhook/ai", bytes.NewReader(payload))
+	HandleAIWebhook(ctx)
+
+	status, response := replayWebhookPayload(payload)
+	if status != rec.Code {
+		t.Errorf("replay status %d, direct status %d", status, rec.Code)
+	}
+	var direct interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &direct)
+	if fmt.Sprint(direct) != fmt.Sprint(response) {
+		t.Errorf("replay response %v, direct response %v", response, direct)
+	}
+}
@@ -0,0 +1,96 @@
+// Package logger sets up structured logging (log/slog) and carries a request/correlation ID
+// through context.Context, so one customer message can be followed from webhook to job to reply.
+package logger
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"genfity-wa-support/config"
+
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID is the header a request ID is read from and echoed in
+const HeaderRequestID = "X-Request-ID"
+
+// Log formats (LOG_FORMAT)
+const (
+	FormatConsole = "console" // human-readable, the standard log output (local dev)
+	FormatJSON    = "json"    // one JSON object per line for log aggregators
+)
+
+type requestIDKey struct{}
+
+var jsonOutput bool
+
+// Init configures logging from LOG_FORMAT (console | json, default console) and LOG_LEVEL
+// (debug | info | warn | error, default info). In json mode the existing log.Printf calls are
+// written as JSON lines too (level INFO), so nothing is lost while call sites migrate.
+func Init() {
+	level := parseLevel(config.GetEnvString("LOG_LEVEL", "info"))
+	slog.SetLogLoggerLevel(level)
+
+	jsonOutput = strings.EqualFold(config.GetEnvString("LOG_FORMAT", FormatConsole), FormatJSON)
+	if !jsonOutput {
+		return
+	}
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(handler))
+	log.SetFlags(0) // timestamps come from the JSON handler
+}
+
+// JSON reports whether LOG_FORMAT=json is active
+func JSON() bool {
+	return jsonOutput
+}
+
+func parseLevel(raw string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// NewRequestID generates a correlation ID
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// WithRequestID returns ctx carrying the request ID (an empty ID leaves ctx unchanged)
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFrom returns the request ID carried by ctx ("" when none)
+func RequestIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger tagged with the context's request ID
+func FromContext(ctx context.Context) *slog.Logger {
+	return ForRequest(RequestIDFrom(ctx))
+}
+
+// ForRequest returns the default logger tagged with request_id (untagged when requestID is empty)
+func ForRequest(requestID string) *slog.Logger {
+	if requestID == "" {
+		return slog.Default()
+	}
+	return slog.Default().With("request_id", requestID)
+}
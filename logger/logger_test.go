@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestRequestIDContext(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-123")
+	if got := RequestIDFrom(ctx); got != "req-123" {
+		t.Errorf("RequestIDFrom = %q, want req-123", got)
+	}
+	if got := RequestIDFrom(WithRequestID(context.Background(), "")); got != "" {
+		t.Errorf("empty request ID stored as %q", got)
+	}
+	if got := RequestIDFrom(context.Background()); got != "" {
+		t.Errorf("RequestIDFrom(no ID) = %q", got)
+	}
+}
+
+func TestForRequestTagsJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	FromContext(WithRequestID(context.Background(), "req-123")).Info("ai job queued", "job_id", 7)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("not a JSON line: %q", buf.String())
+	}
+	if line["request_id"] != "req-123" || line["msg"] != "ai job queued" || line["job_id"] != float64(7) {
+		t.Errorf("line = %v", line)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"debug": slog.LevelDebug, "WARN": slog.LevelWarn, "error": slog.LevelError,
+		"info": slog.LevelInfo, "": slog.LevelInfo, "verbose": slog.LevelInfo,
+	}
+	for in, want := range cases {
+		if got := parseLevel(in); got != want {
+			t.Errorf("parseLevel(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
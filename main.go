@@ -9,8 +9,10 @@ import (
 	"syscall"
 	"time"
 
+	"genfity-wa-support/config"
 	"genfity-wa-support/database"
 	"genfity-wa-support/handlers"
+	"genfity-wa-support/logger"
 	"genfity-wa-support/middleware"
 	"genfity-wa-support/services"
 	"genfity-wa-support/worker"
@@ -27,16 +29,22 @@ func main() {
 		log.Println("✅ .env file loaded successfully")
 	}
 
+	// Structured logging (LOG_FORMAT=json for log aggregators, console for local dev)
+	logger.Init()
+
 	// Debug: Print critical environment variables
 	log.Printf("🔧 DATA_ACCESS_MODE: %s", os.Getenv("DATA_ACCESS_MODE"))
 	log.Printf("🔧 TRANSACTIONAL_API_URL: %s", os.Getenv("TRANSACTIONAL_API_URL"))
-	log.Printf("🔧 INTERNAL_API_KEY: %s", func() string {
-		key := os.Getenv("INTERNAL_API_KEY")
-		if len(key) > 10 {
-			return key[:10] + "..."
-		}
-		return key
-	}())
+	log.Printf("🔧 INTERNAL_API_KEY: %s", services.PreviewText(os.Getenv("INTERNAL_API_KEY"), 10))
+	if previous := config.PreviousInternalAPIKey(); previous != "" {
+		log.Printf("🔧 INTERNAL_API_KEY_PREVIOUS: %s (key rotation in progress)", services.PreviewText(previous, 10))
+	}
+
+	// Gateway, typing, read receipts, contacts and campaigns all call the WA server - refuse to
+	// start half-working
+	if err := services.ValidateWAServerConfig(); err != nil {
+		log.Fatalf("❌ Invalid WA server configuration: %v", err)
+	}
 
 	// Initialize database
 	database.InitDatabase()
@@ -62,27 +70,22 @@ func main() {
 	}()
 
 	// Setup Gin router
-	router := gin.Default()
-
-	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, token") // Added token header for WhatsApp session
+	router := gin.New()
+	router.Use(gin.Recovery())
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	// Correlation ID (X-Request-ID) for every request + access log in the configured LOG_FORMAT
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.AccessLogMiddleware())
 
-		c.Next()
-	})
+	// Add CORS middleware (allowed origins/methods/headers configurable via CORS_* env)
+	router.Use(middleware.CORSMiddleware())
 
 	// Home page
 	router.GET("/", handlers.HomePage)
 
 	// Health check
 	router.GET("/health", handlers.HealthCheck)
+	router.GET("/health/deep", handlers.DeepHealthCheck)
 
 	// WhatsApp Gateway routes - All WA API requests go through this gateway with /wa prefix
 	// Admin routes bypass subscription checks, other routes validate subscription
@@ -121,10 +124,65 @@ func main() {
 	// This is the new architecture: WA Service → /webhook/ai → AI Worker
 	router.POST("/webhook/ai", handlers.HandleAIWebhook)
 
+	// AI conversation context - inspect / forget what the bot remembers (token = session token)
+	ai := router.Group("/ai")
+	ai.Use(handlers.SessionMiddleware())
+	{
+		ai.GET("/context", handlers.GetAIContext)
+		ai.DELETE("/context", handlers.ClearAIContext)
+		// Archived incoming media of a message (MEDIA_STORE_BACKEND)
+		ai.GET("/media/:messageId", handlers.GetArchivedMedia)
+		// Chat room list for the UI (archived rooms hidden unless ?archived=archived|all)
+		ai.GET("/rooms", handlers.ListChatRooms)
+		ai.POST("/rooms/:id/archive", handlers.ArchiveChatRoom)
+		ai.POST("/rooms/:id/unarchive", handlers.UnarchiveChatRoom)
+		ai.DELETE("/rooms/:id", handlers.DeleteChatRoom)
+	}
+
 	// Legacy webhook routes DIHAPUS - tidak dipakai lagi di arsitektur AI bot
 	// Semua event handling sekarang dilakukan via /webhook/ai
 	// Note: Jika masih ada service lain yang kirim ke /webhook/ai, perlu diubah ke /webhook/ai
 
+	// Internal admin endpoints (x-api-key = INTERNAL_API_KEY)
+	admin := router.Group("/admin")
+	admin.Use(middleware.AdminMiddleware())
+	{
+		// Bot persona test harness - replays scripted turns, no WhatsApp / DB writes
+		admin.POST("/bot/:userId/simulate", handlers.SimulateBotConversation)
+		// Single-message dry-run of the bot prompt + knowledge base
+		admin.POST("/bot/:userId/preview", handlers.PreviewBotReply)
+		// AI pipeline metrics (queue depth / backpressure)
+		admin.GET("/metrics", handlers.GetMetrics)
+		// Circuit breakers - inspect / force-close after a provider recovers
+		admin.GET("/circuit", handlers.ListCircuitBreakers)
+		admin.POST("/circuit/:name/reset", handlers.ResetCircuitBreaker)
+		// Re-run a stored raw webhook payload (AI_STORE_RAW_WEBHOOKS=true)
+		admin.POST("/webhook/replay/:messageId", handlers.ReplayWebhook)
+		// Make the bot answer a contact on demand (manual webhook injection for support)
+		admin.POST("/ai/enqueue", handlers.EnqueueAIReply)
+		// WA server connectivity + auth check (admin token, or ?token= for a session)
+		admin.GET("/wa/ping", handlers.PingWAServer)
+		// Drop cached session lookups (call after a bot toggle / subscription change)
+		admin.DELETE("/session-cache", handlers.InvalidateSessionCache)
+		// Contacts that replied STOP / BERHENTI - list, or clear to resume sending
+		admin.GET("/opt-outs", handlers.ListOptOuts)
+		admin.DELETE("/opt-outs", handlers.ClearOptOuts)
+		// Conversations handed off to a human agent - list, or return them to the bot
+		admin.GET("/handoffs", handlers.ListHandoffs)
+		admin.DELETE("/handoffs", handlers.EndHandoff)
+		// AI replies held for review (AI_REPLY_APPROVAL=true) - list, send or discard/regenerate
+		admin.GET("/approvals", handlers.ListReplyApprovals)
+		admin.POST("/approvals/:id/approve", handlers.ApproveReply)
+		admin.POST("/approvals/:id/reject", handlers.RejectReply)
+		// WhatsApp calls recorded instead of sent while DRY_RUN=true
+		admin.GET("/dry-run/sends", handlers.ListDryRunSends)
+		// AI usage per day (tokens, latency, error rate, estimated cost) - ?userId=&from=&to=
+		admin.GET("/usage", handlers.GetUsageSummary)
+		// Per-bot allow/deny list of contacts that get AI replies
+		admin.GET("/bot/:userId/contact-filter", handlers.GetBotContactFilter)
+		admin.PUT("/bot/:userId/contact-filter", handlers.UpdateBotContactFilter)
+	}
+
 	// Public cron job endpoint (no authentication required)
 	router.GET("/bulk/cron/process", handlers.BulkCampaignCronJob)
 
@@ -148,13 +206,23 @@ func main() {
 			campaign.DELETE("/:id", handlers.DeleteCampaign)
 		}
 
-		// Bulk campaign execution endpoints
-		bulk.POST("/campaign/execute", handlers.CreateBulkCampaign)
+		// Bulk campaign execution endpoints (executing needs a package with bulk messaging)
+		bulk.POST("/campaign/execute", middleware.RequireBulkEntitlement(), handlers.CreateBulkCampaign)
 		bulk.GET("/campaigns", handlers.GetBulkCampaigns)
 		bulk.GET("/campaigns/:id", handlers.GetBulkCampaign)
 		bulk.DELETE("/campaigns/:id", handlers.DeleteBulkCampaign)
 	}
 
+	// Knowledge base documents of the JWT user's bot (direct DB: written to AIDocument)
+	kb := router.Group("/bot/kb")
+	kb.Use(middleware.JWTMiddleware())
+	{
+		kb.GET("", handlers.ListKnowledgeDocuments)
+		kb.POST("", handlers.CreateKnowledgeDocument)
+		kb.PUT("/:id", handlers.UpdateKnowledgeDocument)
+		kb.DELETE("/:id", handlers.DeleteKnowledgeDocument)
+	}
+
 	// Get port from environment or default to 8070
 	port := os.Getenv("PORT")
 	if port == "" {
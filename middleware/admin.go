@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"genfity-wa-support/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware protects internal admin endpoints with the shared INTERNAL_API_KEY (x-api-key header).
+// During a key rotation INTERNAL_API_KEY_PREVIOUS is accepted as well.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := config.InternalAPIKey()
+		if expected == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"code":    503,
+				"success": false,
+				"message": "Admin endpoints disabled: INTERNAL_API_KEY not configured",
+			})
+			c.Abort()
+			return
+		}
+
+		apiKey := c.GetHeader("x-api-key")
+		previous := config.PreviousInternalAPIKey()
+		usedPrevious := apiKey != "" && previous != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(previous)) == 1
+		if usedPrevious {
+			log.Printf("🔑 %s %s authenticated with INTERNAL_API_KEY_PREVIOUS - switch the caller to the new key", c.Request.Method, c.Request.URL.Path)
+		}
+		if !usedPrevious && (apiKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(expected)) != 1) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"success": false,
+				"message": "Invalid or missing x-api-key",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminMiddlewareAcceptsCurrentAndPreviousKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/ping", AdminMiddleware(), func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	status := func(apiKey string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/ping", nil)
+		if apiKey != "" {
+			req.Header.Set("x-api-key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Setenv("INTERNAL_API_KEY", "new-key")
+	t.Setenv("INTERNAL_API_KEY_PREVIOUS", "old-key")
+	for key, want := range map[string]int{
+		"new-key":   http.StatusOK,
+		"old-key":   http.StatusOK,
+		"other-key": http.StatusUnauthorized,
+		"":          http.StatusUnauthorized,
+	} {
+		if got := status(key); got != want {
+			t.Errorf("x-api-key %q: status %d, want %d", key, got, want)
+		}
+	}
+
+	// Rotation finished: the old key is refused again
+	t.Setenv("INTERNAL_API_KEY_PREVIOUS", "")
+	if got := status("old-key"); got != http.StatusUnauthorized {
+		t.Errorf("old key after rotation: status %d, want 401", got)
+	}
+
+	// No current key: admin endpoints stay disabled even if a previous key is set
+	t.Setenv("INTERNAL_API_KEY", "")
+	t.Setenv("INTERNAL_API_KEY_PREVIOUS", "old-key")
+	if got := status("old-key"); got != http.StatusServiceUnavailable {
+		t.Errorf("no INTERNAL_API_KEY: status %d, want 503", got)
+	}
+}
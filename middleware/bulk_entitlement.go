@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNoActiveSubscription - the user has no active, unexpired WhatsApp subscription
+var errNoActiveSubscription = errors.New("no active subscription")
+
+// lookupUserPackage returns the package of the user's active subscription (stubbed in tests)
+var lookupUserPackage = func(userID string) (*models.WhatsappApiPackage, error) {
+	if database.TransactionalDB == nil {
+		return nil, fmt.Errorf("transactional database not initialized")
+	}
+
+	var subscription models.ServicesWhatsappCustomers
+	err := database.TransactionalDB.
+		Where("\"customerId\" = ? AND status = ?", userID, "active").
+		First(&subscription).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errNoActiveSubscription
+	}
+	if err != nil {
+		return nil, fmt.Errorf("subscription check failed: %w", err)
+	}
+	if time.Now().After(subscription.ExpiredAt) {
+		return nil, errNoActiveSubscription
+	}
+
+	var pkg models.WhatsappApiPackage
+	if err := database.TransactionalDB.Where("id = ?", subscription.PackageID).First(&pkg).Error; err != nil {
+		return nil, fmt.Errorf("package lookup failed: %w", err)
+	}
+	return &pkg, nil
+}
+
+// bulkEnabledPackages returns BULK_ENABLED_PACKAGES (comma-separated package IDs or names, case-insensitive)
+func bulkEnabledPackages() []string {
+	var packages []string
+	for _, p := range strings.Split(config.GetEnvString("BULK_ENABLED_PACKAGES", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+// packageAllowsBulk decides whether a package includes bulk messaging. The package's own
+// bulkMessaging flag wins; without it the package must be listed in BULK_ENABLED_PACKAGES.
+// When neither is configured every package keeps bulk access (the behaviour before entitlements).
+func packageAllowsBulk(pkg *models.WhatsappApiPackage, enabled []string) bool {
+	if pkg.BulkMessaging != nil {
+		return *pkg.BulkMessaging
+	}
+	if len(enabled) == 0 {
+		return true
+	}
+	for _, p := range enabled {
+		if strings.EqualFold(p, pkg.ID) || strings.EqualFold(p, pkg.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireBulkEntitlement only lets users whose subscription package includes bulk messaging
+// through (403 otherwise). Must run after JWTMiddleware, which sets user_id.
+func RequireBulkEntitlement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User ID not found",
+			})
+			c.Abort()
+			return
+		}
+
+		pkg, err := lookupUserPackage(userID)
+		if errors.Is(err, errNoActiveSubscription) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "An active subscription is required for bulk messaging",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Bulk entitlement check failed for user %s: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check bulk messaging entitlement",
+			})
+			c.Abort()
+			return
+		}
+
+		if !packageAllowsBulk(pkg, bulkEnabledPackages()) {
+			log.Printf("🚫 User %s denied bulk campaign: package %s (%s) has no bulk messaging", userID, pkg.Name, pkg.ID)
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Your package does not include bulk messaging",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireBulkEntitlement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled, disabled := true, false
+	packages := map[string]*models.WhatsappApiPackage{
+		"user-bulk":    {ID: "pkg-pro", Name: "Pro", BulkMessaging: &enabled},
+		"user-nobulk":  {ID: "pkg-basic", Name: "Basic", BulkMessaging: &disabled},
+		"user-listed":  {ID: "pkg-business", Name: "Business"},
+		"user-missing": {ID: "pkg-starter", Name: "Starter"},
+	}
+	original := lookupUserPackage
+	lookupUserPackage = func(userID string) (*models.WhatsappApiPackage, error) {
+		if pkg, ok := packages[userID]; ok {
+			return pkg, nil
+		}
+		return nil, errNoActiveSubscription
+	}
+	t.Cleanup(func() { lookupUserPackage = original })
+
+	status := func(userID string) int {
+		router := gin.New()
+		router.POST("/bulk/campaign/execute", func(c *gin.Context) {
+			if userID != "" {
+				c.Set("user_id", userID)
+			}
+		}, RequireBulkEntitlement(), func(c *gin.Context) { c.Status(http.StatusOK) })
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/bulk/campaign/execute", nil))
+		return rec.Code
+	}
+
+	t.Setenv("BULK_ENABLED_PACKAGES", "business")
+	for userID, want := range map[string]int{
+		"user-bulk":    http.StatusOK,
+		"user-nobulk":  http.StatusForbidden,
+		"user-listed":  http.StatusOK,
+		"user-missing": http.StatusForbidden,
+		"user-nosub":   http.StatusForbidden,
+		"":             http.StatusUnauthorized,
+	} {
+		if got := status(userID); got != want {
+			t.Errorf("user %q: status %d, want %d", userID, got, want)
+		}
+	}
+
+	// Nothing configured: packages without the flag keep bulk access, explicit false still denies
+	t.Setenv("BULK_ENABLED_PACKAGES", "")
+	if got := status("user-missing"); got != http.StatusOK {
+		t.Errorf("unconfigured package: status %d, want 200", got)
+	}
+	if got := status("user-nobulk"); got != http.StatusForbidden {
+		t.Errorf("bulkMessaging=false: status %d, want 403", got)
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCORSMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	defaultCORSHeaders = "Content-Type, Authorization, token, x-api-key, Idempotency-Key, X-Request-ID, X-Signature"
+)
+
+// CORSMiddleware sets CORS headers and answers OPTIONS preflight requests.
+//
+// Config (env):
+//   - CORS_ALLOWED_ORIGINS: comma separated origins, default "*"
+//   - CORS_ALLOWED_METHODS: comma separated methods, default "GET, POST, PUT, DELETE, OPTIONS"
+//   - CORS_ALLOWED_HEADERS: comma separated headers; "*" reflects the preflight's Access-Control-Request-Headers
+func CORSMiddleware() gin.HandlerFunc {
+	origins := splitCSV(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	allowAllOrigins := len(origins) == 0 || (len(origins) == 1 && origins[0] == "*")
+
+	methods := os.Getenv("CORS_ALLOWED_METHODS")
+	if methods == "" {
+		methods = defaultCORSMethods
+	}
+
+	headers := os.Getenv("CORS_ALLOWED_HEADERS")
+	if headers == "" {
+		headers = defaultCORSHeaders
+	}
+	reflectHeaders := strings.TrimSpace(headers) == "*"
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if allowAllOrigins {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else if origin != "" && containsFold(origins, origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+		}
+
+		c.Header("Access-Control-Allow-Methods", methods)
+
+		if reflectHeaders {
+			// Reflect whatever the browser asks for in preflight
+			if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+				c.Header("Access-Control-Allow-Headers", requested)
+				c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+		} else {
+			c.Header("Access-Control-Allow-Headers", headers)
+		}
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// splitCSV splits a comma separated env value into trimmed non-empty items
+func splitCSV(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsFold reports whether list contains value (case-insensitive)
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
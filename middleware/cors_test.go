@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCORSRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	router.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return router
+}
+
+func preflight(router *gin.Engine, origin, requestHeaders string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/ping", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	if requestHeaders != "" {
+		req.Header.Set("Access-Control-Request-Headers", requestHeaders)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSDefaultHeadersIncludeCustomHeaders(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("CORS_ALLOWED_HEADERS", "")
+
+	rec := preflight(newCORSRouter(), "https://app.example.com", "Idempotency-Key")
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", rec.Code)
+	}
+	allowed := rec.Header().Get("Access-Control-Allow-Headers")
+	for _, header := range []string{"Idempotency-Key", "X-Request-ID", "X-Signature", "token"} {
+		if !strings.Contains(allowed, header) {
+			t.Errorf("default allowed headers %q missing %s", allowed, header)
+		}
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("allow origin = %q, want *", got)
+	}
+}
+
+func TestCORSWildcardReflectsRequestedHeaders(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("CORS_ALLOWED_HEADERS", "*")
+
+	rec := preflight(newCORSRouter(), "https://app.example.com", "X-Custom-Trace, Idempotency-Key")
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "X-Custom-Trace, Idempotency-Key" {
+		t.Errorf("allow headers = %q, want reflected request headers", got)
+	}
+}
+
+func TestCORSOriginAllowList(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	t.Setenv("CORS_ALLOWED_HEADERS", "")
+	router := newCORSRouter()
+
+	allowed := preflight(router, "https://app.example.com", "")
+	if got := allowed.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("allowed origin header = %q", got)
+	}
+
+	denied := preflight(router, "https://evil.example.com", "")
+	if got := denied.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("disallowed origin got Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestCORSNonPreflightPassesThrough(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("CORS_ALLOWED_HEADERS", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	rec := httptest.NewRecorder()
+	newCORSRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "pong" {
+		t.Errorf("GET /ping = %d %q, want 200 pong", rec.Code, rec.Body.String())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"genfity-wa-support/config"
 	"genfity-wa-support/database"
 	"genfity-wa-support/models"
 
@@ -16,6 +17,16 @@ import (
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
+// jwtAudience returns the expected "aud" claim (JWT_AUDIENCE); empty means tokens carry no audience
+func jwtAudience() string {
+	return config.GetEnvString("JWT_AUDIENCE", "")
+}
+
+// looksLikeJWT reports whether a bearer token is a JWT (header.payload.signature) rather than an opaque session token
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
 // GenerateJWT creates a new JWT token for a user
 func GenerateJWT(userID string, email string, role string, sessionID string) (string, error) {
 	if len(jwtSecret) == 0 {
@@ -32,23 +43,32 @@ func GenerateJWT(userID string, email string, role string, sessionID string) (st
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if aud := jwtAudience(); aud != "" {
+		claims.Audience = jwt.ClaimStrings{aud}
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
-// ValidateJWT parses and validates a JWT token
+// ValidateJWT parses and validates a JWT token. The exp claim is required, and when JWT_AUDIENCE
+// is set the aud claim must contain it.
 func ValidateJWT(tokenString string) (*models.JWTClaims, error) {
 	if len(jwtSecret) == 0 {
 		return nil, fmt.Errorf("JWT_SECRET environment variable not set")
 	}
 
+	options := []jwt.ParserOption{jwt.WithExpirationRequired(), jwt.WithIssuedAt()}
+	if aud := jwtAudience(); aud != "" {
+		options = append(options, jwt.WithAudience(aud))
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &models.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return jwtSecret, nil
-	})
+	}, options...)
 
 	if err != nil {
 		return nil, err
@@ -84,15 +104,34 @@ func JWTMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := tokenParts[1]
+		var err error
+
+		// JWTs are checked on their own claims (signature, exp, aud) before the session lookup;
+		// opaque session tokens only have the UserSession row
+		var claims *models.JWTClaims
+		if looksLikeJWT(tokenString) && len(jwtSecret) > 0 {
+			claims, err = ValidateJWT(tokenString)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": fmt.Sprintf("Invalid token: %v", err),
+				})
+				c.Abort()
+				return
+			}
+		}
 
 		// Simple token validation: match with UserSession table
 		var userSession models.UserSession
-		err := database.TransactionalDB.Where(`token = ? AND "expiresAt" > ? AND "isActive" = ?`,
+		err = database.TransactionalDB.Where(`token = ? AND "expiresAt" > ? AND "isActive" = ?`,
 			tokenString, time.Now(), true).First(&userSession).Error
 		if err != nil {
 			// Debug logging
 			fmt.Printf("Token lookup failed: %v\n", err)
-			fmt.Printf("Looking for token: %s\n", tokenString[:50]+"...")
+			tokenPreview := tokenString
+			if len(tokenPreview) > 50 {
+				tokenPreview = tokenPreview[:50] + "..." // tokens are ASCII; short ones used to panic here
+			}
+			fmt.Printf("Looking for token: %s\n", tokenPreview)
 			fmt.Printf("Current time: %s\n", time.Now().Format("2006-01-02 15:04:05"))
 
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -102,6 +141,14 @@ func JWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if claims != nil && claims.UserID != "" && claims.UserID != userSession.UserID {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Token does not belong to this session",
+			})
+			c.Abort()
+			return
+		}
+
 		// Get user details from user_id
 		var user models.User
 		err = database.TransactionalDB.Where("id = ?", userSession.UserID).First(&user).Error
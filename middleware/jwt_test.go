@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"genfity-wa-support/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signTestJWT(t *testing.T, registered jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &models.JWTClaims{
+		UserID:           "user-1",
+		RegisteredClaims: registered,
+	}).SignedString(jwtSecret)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func TestValidateJWTChecksExpiryAndAudience(t *testing.T) {
+	original := jwtSecret
+	jwtSecret = []byte("test-secret")
+	t.Cleanup(func() { jwtSecret = original })
+	t.Setenv("JWT_AUDIENCE", "genfity-wa")
+
+	future := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	cases := map[string]struct {
+		claims jwt.RegisteredClaims
+		valid  bool
+	}{
+		"valid":          {jwt.RegisteredClaims{ExpiresAt: future, Audience: jwt.ClaimStrings{"genfity-wa"}}, true},
+		"expired":        {jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)), Audience: jwt.ClaimStrings{"genfity-wa"}}, false},
+		"no expiry":      {jwt.RegisteredClaims{Audience: jwt.ClaimStrings{"genfity-wa"}}, false},
+		"wrong audience": {jwt.RegisteredClaims{ExpiresAt: future, Audience: jwt.ClaimStrings{"other-app"}}, false},
+		"no audience":    {jwt.RegisteredClaims{ExpiresAt: future}, false},
+	}
+	for name, tc := range cases {
+		_, err := ValidateJWT(signTestJWT(t, tc.claims))
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: err = %v, want valid=%v", name, err, tc.valid)
+		}
+	}
+
+	// Without JWT_AUDIENCE the aud claim is not checked
+	t.Setenv("JWT_AUDIENCE", "")
+	if _, err := ValidateJWT(signTestJWT(t, jwt.RegisteredClaims{ExpiresAt: future})); err != nil {
+		t.Errorf("no audience configured: %v", err)
+	}
+
+	// Issued tokens carry the configured audience and pass validation
+	t.Setenv("JWT_AUDIENCE", "genfity-wa")
+	token, err := GenerateJWT("user-1", "user@example.com", "customer", "session_1")
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	if _, err := ValidateJWT(token); err != nil {
+		t.Errorf("generated token rejected: %v", err)
+	}
+}
+
+func TestJWTMiddlewareRejectsExpiredJWTBeforeSessionLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	original := jwtSecret
+	jwtSecret = []byte("test-secret")
+	t.Cleanup(func() { jwtSecret = original })
+
+	router := gin.New()
+	router.GET("/bulk/contact", JWTMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	expired := signTestJWT(t, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))})
+	req := httptest.NewRequest(http.MethodGet, "/bulk/contact", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expired JWT: status %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"genfity-wa-support/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRequestIDLength bounds a caller-supplied X-Request-ID (longer values are replaced)
+const maxRequestIDLength = 64
+
+// RequestIDMiddleware reuses the caller's X-Request-ID (or generates one), stores it in the
+// request context for handlers and echoes it in the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(logger.HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = logger.NewRequestID()
+		}
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Header(logger.HeaderRequestID, requestID)
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs made of letters, digits and - _ . : only
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogMiddleware logs each request: gin's console logger, or a JSON line with the
+// request ID when LOG_FORMAT=json
+func AccessLogMiddleware() gin.HandlerFunc {
+	if !logger.JSON() {
+		return gin.Logger()
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.FromContext(c.Request.Context()).Info("http request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
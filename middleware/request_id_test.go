@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"genfity-wa-support/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRequestIDRouter(seen *string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/ping", func(c *gin.Context) {
+		*seen = logger.RequestIDFrom(c.Request.Context())
+		c.String(http.StatusOK, "pong")
+	})
+	return router
+}
+
+func TestRequestIDMiddlewareReusesCallerID(t *testing.T) {
+	var seen string
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set(logger.HeaderRequestID, "wa-server-abc123")
+	rec := httptest.NewRecorder()
+	newRequestIDRouter(&seen).ServeHTTP(rec, req)
+
+	if seen != "wa-server-abc123" || rec.Header().Get(logger.HeaderRequestID) != "wa-server-abc123" {
+		t.Errorf("handler saw %q, response header %q", seen, rec.Header().Get(logger.HeaderRequestID))
+	}
+}
+
+func TestRequestIDMiddlewareGeneratesID(t *testing.T) {
+	for _, incoming := range []string{"", "bad id with spaces", strings.Repeat("x", 65)} {
+		var seen string
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		if incoming != "" {
+			req.Header.Set(logger.HeaderRequestID, incoming)
+		}
+		rec := httptest.NewRecorder()
+		newRequestIDRouter(&seen).ServeHTTP(rec, req)
+
+		if seen == "" || seen == incoming || rec.Header().Get(logger.HeaderRequestID) != seen {
+			t.Errorf("incoming %q: handler saw %q, response header %q", incoming, seen, rec.Header().Get(logger.HeaderRequestID))
+		}
+	}
+}
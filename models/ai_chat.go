@@ -5,19 +5,26 @@ import "time"
 // AIChatMessage: simpan semua pesan masuk/keluar untuk AI context
 // Berbeda dengan ChatMessage yang sudah ada, ini khusus untuk AI processing
 type AIChatMessage struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	MessageID  string    `gorm:"uniqueIndex;not null" json:"message_id"` // dari WA (Info.ID)
-	SessionTok string    `gorm:"index;not null" json:"session_tok"`      // instanceName
-	From       string    `gorm:"index;not null" json:"from"`             // nomor pengirim
-	To         string    `gorm:"index;not null" json:"to"`               // nomor penerima
-	FromMe     bool      `gorm:"default:false" json:"from_me"`           // aku yang kirim?
-	MsgType    string    `gorm:"index;not null" json:"msg_type"`         // "text"
-	Body       string    `gorm:"type:text" json:"body"`
-	PushName   string    `json:"push_name"`
-	IsRead     bool      `gorm:"default:false;index" json:"is_read"` // sudah di-read atau belum
-	Timestamp  time.Time `gorm:"index" json:"timestamp"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	MessageID  string `gorm:"uniqueIndex;not null" json:"message_id"` // dari WA (Info.ID)
+	SessionTok string `gorm:"index;not null" json:"session_tok"`      // instanceName
+	From       string `gorm:"index;not null" json:"from"`             // nomor pengirim
+	To         string `gorm:"index;not null" json:"to"`               // nomor penerima
+	FromMe     bool   `gorm:"default:false" json:"from_me"`           // aku yang kirim?
+	MsgType    string `gorm:"index;not null" json:"msg_type"`         // "text" | "reaction"
+	Body       string `gorm:"type:text" json:"body"`
+	PushName   string `json:"push_name"`
+	IsRead     bool   `gorm:"default:false;index" json:"is_read"` // sudah di-read atau belum
+	// Read receipt dikonfirmasi WA Server; false + IsRead = markread gagal, dicoba ulang oleh reconciler
+	ReadConfirmed bool      `gorm:"default:false;index" json:"read_confirmed"`
+	ReadAttempts  int       `gorm:"default:0" json:"read_attempts"` // percobaan ulang markread oleh reconciler
+	Timestamp     time.Time `gorm:"index" json:"timestamp"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Reply/reaction context: pesan yang di-quote atau di-react oleh customer
+	QuotedMessageID string `json:"quoted_message_id"`
+	QuotedBody      string `gorm:"type:text" json:"quoted_body"`
 }
 
 // TableName override untuk tabel ai_chat_messages
@@ -27,30 +34,38 @@ func (AIChatMessage) TableName() string {
 
 // MessageSendLog: hasil kirim balasan AI
 type MessageSendLog struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	SessionTok string    `gorm:"index;not null" json:"session_tok"`
-	To         string    `gorm:"index;not null" json:"to"`
-	Body       string    `gorm:"type:text" json:"body"`
-	Status     string    `gorm:"index;default:'sent'" json:"status"` // sent|failed
-	ErrorMsg   string    `gorm:"type:text" json:"error_msg"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	SendKey     *string   `gorm:"uniqueIndex" json:"send_key"` // pesan logis, mis. "job:12" / "job:12:img:0"; null = tanpa dedupe
+	JobID       uint      `gorm:"index" json:"job_id"`
+	SessionTok  string    `gorm:"index;not null" json:"session_tok"`
+	To          string    `gorm:"index;not null" json:"to"`
+	Body        string    `gorm:"type:text" json:"body"`
+	Status      string    `gorm:"index;default:'sent'" json:"status"` // sent|failed
+	ErrorMsg    string    `gorm:"type:text" json:"error_msg"`
+	WAMessageID string    `json:"wa_message_id"`
+	Attempts    int       `gorm:"default:1" json:"attempts"` // percobaan kirim untuk SendKey yang sama
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // AIJob: queue tanpa Redis
 type AIJob struct {
-	ID         uint       `gorm:"primaryKey" json:"id"`
-	Status     string     `gorm:"index;default:'pending'" json:"status"` // pending|processing|done|failed
-	Priority   int        `gorm:"default:5" json:"priority"`
-	SessionTok string     `gorm:"index;not null" json:"session_tok"`
-	MessageID  string     `gorm:"index;not null" json:"message_id"`
-	UserID     string     `gorm:"index;not null" json:"user_id"`
-	InputJSON  string     `gorm:"type:text" json:"input_json"`  // payload ringkas (prompt, context keys)
-	OutputJSON string     `gorm:"type:text" json:"output_json"` // jawaban LLM
-	ErrorMsg   string     `gorm:"type:text" json:"error_msg"`
-	Attempts   int        `gorm:"default:0" json:"attempts"`
-	NextRunAt  *time.Time `gorm:"index" json:"next_run_at"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Status           string     `gorm:"index;default:'pending'" json:"status"` // pending|processing|done|failed
+	Priority         int        `gorm:"default:5" json:"priority"`
+	SessionTok       string     `gorm:"index;not null" json:"session_tok"`
+	MessageID        string     `gorm:"index;not null" json:"message_id"`    // pesan terakhir yang dijawab
+	MergedMessageIDs string     `gorm:"type:text" json:"merged_message_ids"` // pesan sebelumnya yang digabung (debounce), dipisah koma
+	UserID           string     `gorm:"index;not null" json:"user_id"`
+	Contact          string     `gorm:"index;default:''" json:"contact"`    // nomor pengirim; satu job per (session, contact) diproses sekaligus
+	RequestID        string     `gorm:"index;default:''" json:"request_id"` // correlation ID dari webhook (X-Request-ID), dipakai di log worker
+	InputJSON        string     `gorm:"type:text" json:"input_json"`        // payload ringkas (prompt, context keys)
+	OutputJSON       string     `gorm:"type:text" json:"output_json"`       // jawaban LLM
+	ErrorMsg         string     `gorm:"type:text" json:"error_msg"`
+	Attempts         int        `gorm:"default:0" json:"attempts"`
+	NextRunAt        *time.Time `gorm:"index" json:"next_run_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
 // AIJobAttempt: retry log
@@ -72,3 +87,121 @@ type RateLimit struct {
 	WindowStart time.Time `gorm:"index" json:"window_start"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// RawWebhook: payload JSON mentah dari WA Service (debug / replay), dihapus setelah retention
+type RawWebhook struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	MessageID  string    `gorm:"index" json:"message_id"`
+	SessionTok string    `gorm:"index" json:"session_tok"`
+	Payload    string    `gorm:"type:text" json:"payload"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// AIPromptDebug: prompt lengkap (system + user) yang dikirim ke LLM per job (DEBUG_DUMP_PROMPT), dihapus setelah retention
+type AIPromptDebug struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	JobID        uint      `gorm:"index" json:"job_id"`
+	SessionTok   string    `gorm:"index" json:"session_tok"`
+	MessageID    string    `gorm:"index" json:"message_id"`
+	MaxMessages  int       `json:"max_messages"` // history window used (differs on context-length retry)
+	SystemPrompt string    `gorm:"type:text" json:"system_prompt"`
+	UserMessage  string    `gorm:"type:text" json:"user_message"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName override untuk tabel ai_prompt_debug
+func (AIPromptDebug) TableName() string {
+	return "ai_prompt_debug"
+}
+
+// AIContextDecision: keputusan context builder per job (dokumen + skor, history, token) sebagai JSON
+// (DEBUG_CONTEXT_DECISIONS), dihapus bersama prompt dump setelah retention
+type AIContextDecision struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	JobID       uint      `gorm:"index" json:"job_id"`
+	SessionTok  string    `gorm:"index" json:"session_tok"`
+	MessageID   string    `gorm:"index" json:"message_id"`
+	MaxMessages int       `json:"max_messages"` // history window used (differs on context-length retry)
+	Decision    string    `gorm:"type:text" json:"decision"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName override untuk tabel ai_context_decisions
+func (AIContextDecision) TableName() string {
+	return "ai_context_decisions"
+}
+
+// ContactOptOut: kontak yang membalas kata kunci berhenti (STOP/BERHENTI) - tidak dibalas AI dan tidak dikirimi bulk campaign
+type ContactOptOut struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SessionTok string    `gorm:"uniqueIndex:idx_opt_out_session_contact;not null" json:"session_tok"`
+	Contact    string    `gorm:"uniqueIndex:idx_opt_out_session_contact;not null" json:"contact"` // phone digits
+	Keyword    string    `json:"keyword"`                                                         // keyword that triggered the opt-out
+	MessageID  string    `json:"message_id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// ContactHandoff: percakapan yang diambil alih CS manusia - pesan tetap disimpan, tapi tidak dibalas AI
+// sampai dikembalikan ke bot (DELETE /admin/handoffs)
+type ContactHandoff struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SessionTok string    `gorm:"uniqueIndex:idx_handoff_session_contact;not null" json:"session_tok"`
+	Contact    string    `gorm:"uniqueIndex:idx_handoff_session_contact;not null" json:"contact"` // phone digits
+	Reason     string    `json:"reason"`                                                          // "keyword:<kw>" | "llm" | "message_type:<type>"
+	MessageID  string    `json:"message_id"`                                                      // pesan yang memicu handoff
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// ContactReplyCounter: jumlah balasan AI per session + kontak per hari (cap harian, cegah loop antar bot)
+type ContactReplyCounter struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SessionTok string    `gorm:"uniqueIndex:idx_reply_counter_day;not null" json:"session_tok"`
+	Contact    string    `gorm:"uniqueIndex:idx_reply_counter_day;not null" json:"contact"`     // phone digits
+	Day        string    `gorm:"uniqueIndex:idx_reply_counter_day;size:10;not null" json:"day"` // YYYY-MM-DD (server time)
+	Count      int       `gorm:"default:0" json:"count"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ContactGreeting: kontak yang sudah menerima greeting bot (greeting hanya dikirim sekali per session + kontak)
+type ContactGreeting struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	SessionTok string     `gorm:"uniqueIndex:idx_greeting_session_contact;not null" json:"session_tok"`
+	Contact    string     `gorm:"uniqueIndex:idx_greeting_session_contact;not null" json:"contact"` // phone digits
+	MessageID  string     `json:"message_id"`                                                       // first message of the contact
+	Greeting   string     `gorm:"type:text" json:"greeting"`                                        // text to send, fixed at claim time
+	SentAt     *time.Time `json:"sent_at"`                                                          // set by the job that sends it
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Status AIReplyApproval
+const (
+	ReplyApprovalPending  = "pending_approval" // menunggu review
+	ReplyApprovalSending  = "sending"          // disetujui, sedang dikirim
+	ReplyApprovalSent     = "sent"
+	ReplyApprovalRejected = "rejected"
+	ReplyApprovalFailed   = "failed" // kirim gagal, boleh di-approve ulang
+)
+
+// AIReplyApproval: balasan AI yang menunggu persetujuan manusia sebelum dikirim (AI_REPLY_APPROVAL=true)
+type AIReplyApproval struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	JobID         uint       `gorm:"index" json:"job_id"`
+	SessionTok    string     `gorm:"index;not null" json:"session_tok"`
+	MessageID     string     `gorm:"index" json:"message_id"` // pesan customer yang dijawab
+	BotJID        string     `json:"bot_jid"`
+	ContactJID    string     `gorm:"index;not null" json:"contact_jid"`
+	Reply         string     `gorm:"type:text" json:"reply"`      // teks (format WhatsApp) yang akan dikirim
+	ImageURLs     string     `gorm:"type:text" json:"image_urls"` // [SEND_IMAGE] yang lolos validasi, satu per baris
+	Cards         string     `gorm:"type:text" json:"cards"`      // [SEND_LOCATION] / [SEND_CONTACT] yang lolos validasi, satu per baris
+	Status        string     `gorm:"index;default:'pending_approval'" json:"status"`
+	Reason        string     `gorm:"type:text" json:"reason"` // alasan reject / error kirim
+	SentMessageID string     `json:"sent_message_id"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName override untuk tabel ai_reply_approvals
+func (AIReplyApproval) TableName() string {
+	return "ai_reply_approvals"
+}
@@ -105,16 +105,19 @@ func (BulkCampaign) TableName() string {
 
 // BulkCampaignItem represents individual message item in a bulk campaign
 type BulkCampaignItem struct {
-	ID             uint                   `json:"id" gorm:"primaryKey"`
-	BulkCampaignID uint                   `json:"bulk_campaign_id" gorm:"column:bulk_campaign_id;not null;index"`
-	Phone          string                 `json:"phone" gorm:"column:phone;not null"`
-	Status         BulkCampaignItemStatus `json:"status" gorm:"column:status;default:'pending'"`
-	MessageID      string                 `json:"message_id" gorm:"column:message_id"`
-	ErrorMessage   string                 `json:"error_message" gorm:"column:error_message;type:text"`
-	SentAt         *time.Time             `json:"sent_at" gorm:"column:sent_at"`
-	CreatedAt      time.Time              `json:"created_at" gorm:"column:created_at"`
-	UpdatedAt      time.Time              `json:"updated_at" gorm:"column:updated_at"`
-	DeletedAt      gorm.DeletedAt         `json:"deleted_at" gorm:"column:deleted_at;index"`
+	ID              uint                   `json:"id" gorm:"primaryKey"`
+	BulkCampaignID  uint                   `json:"bulk_campaign_id" gorm:"column:bulk_campaign_id;not null;index"`
+	Phone           string                 `json:"phone" gorm:"column:phone;not null"`
+	Status          BulkCampaignItemStatus `json:"status" gorm:"column:status;default:'pending'"`
+	Attempts        int                    `json:"attempts" gorm:"column:attempts;default:0"` // send attempts, capped by BULK_CAMPAIGN_MAX_ATTEMPTS
+	MessageID       string                 `json:"message_id" gorm:"column:message_id"`
+	Variables       JSONB                  `json:"variables,omitempty" gorm:"column:variables;type:jsonb"`              // template variables of this recipient
+	ResolvedMessage string                 `json:"resolved_message,omitempty" gorm:"column:resolved_message;type:text"` // text actually sent (template resolved), for audit
+	ErrorMessage    string                 `json:"error_message" gorm:"column:error_message;type:text"`
+	SentAt          *time.Time             `json:"sent_at" gorm:"column:sent_at"`
+	CreatedAt       time.Time              `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt       time.Time              `json:"updated_at" gorm:"column:updated_at"`
+	DeletedAt       gorm.DeletedAt         `json:"deleted_at" gorm:"column:deleted_at;index"`
 
 	// Relations
 	BulkCampaign *BulkCampaign `json:"bulk_campaign,omitempty" gorm:"foreignKey:BulkCampaignID"`
@@ -129,9 +132,10 @@ func (BulkCampaignItem) TableName() string {
 type BulkCampaignItemStatus string
 
 const (
-	BulkCampaignItemStatusPending BulkCampaignItemStatus = "pending"
-	BulkCampaignItemStatusSent    BulkCampaignItemStatus = "sent"
-	BulkCampaignItemStatusFailed  BulkCampaignItemStatus = "failed"
+	BulkCampaignItemStatusPending  BulkCampaignItemStatus = "pending"
+	BulkCampaignItemStatusSent     BulkCampaignItemStatus = "sent"
+	BulkCampaignItemStatusFailed   BulkCampaignItemStatus = "failed"
+	BulkCampaignItemStatusOptedOut BulkCampaignItemStatus = "opted_out" // recipient replied STOP, not sent
 )
 
 // Request/Response structs for campaign APIs
@@ -163,6 +167,11 @@ type CreateBulkCampaignRequest struct {
 	Phone      []string `json:"phone" binding:"required,min=1"`
 	SendSync   string   `json:"send_sync" binding:"required"`
 	Timezone   string   `json:"timezone"` // Required for scheduled campaigns (e.g., "Asia/Jakarta", "America/New_York")
+
+	// Personalization: MessageTemplate (optional, replaces the campaign's message body / image caption)
+	// may use {name} placeholders, filled per recipient from Variables (keyed by phone as in Phone)
+	MessageTemplate string                       `json:"message_template"`
+	Variables       map[string]map[string]string `json:"variables"`
 }
 
 // CampaignResponse represents response for campaign operations
@@ -175,9 +184,19 @@ type CampaignResponse struct {
 
 // CampaignListResponse represents response for campaign list
 type CampaignListResponse struct {
-	Code    int        `json:"code"`
-	Success bool       `json:"success"`
-	Data    []Campaign `json:"data"`
+	Code       int         `json:"code"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       []Campaign  `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes one page of a list response
+type Pagination struct {
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"` // rows matching the filters, across all pages
+	TotalPages int   `json:"total_pages"`
 }
 
 // BulkCampaignResponse represents response for bulk campaign creation
@@ -196,9 +215,11 @@ type BulkCampaignResponse struct {
 
 // BulkCampaignListResponse represents response for bulk campaign list
 type BulkCampaignListResponse struct {
-	Code    int            `json:"code"`
-	Success bool           `json:"success"`
-	Data    []BulkCampaign `json:"data"`
+	Code       int            `json:"code"`
+	Success    bool           `json:"success"`
+	Message    string         `json:"message,omitempty"`
+	Data       []BulkCampaign `json:"data"`
+	Pagination *Pagination    `json:"pagination,omitempty"`
 }
 
 // BulkCampaignDetailResponse represents response for bulk campaign detail
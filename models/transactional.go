@@ -6,25 +6,30 @@ import (
 
 // WhatsappSession model - sesuai dengan skema Prisma
 type WhatsappSession struct {
-	ID               string    `json:"id" gorm:"primaryKey;type:varchar(30);column:id"`
-	SessionID        string    `json:"sessionId" gorm:"unique;column:sessionId"`
-	SessionName      string    `json:"sessionName" gorm:"column:sessionName"`
-	Token            string    `json:"token" gorm:"unique;column:token"`
-	UserID           *string   `json:"userId" gorm:"column:userId;index"`
-	Webhook          *string   `json:"webhook" gorm:"column:webhook"`
-	Events           *string   `json:"events" gorm:"column:events"`
-	Expiration       int       `json:"expiration" gorm:"default:0;column:expiration"`
-	Connected        bool      `json:"connected" gorm:"default:false;column:connected"`
-	LoggedIn         bool      `json:"loggedIn" gorm:"default:false;column:loggedIn"`
-	JID              *string   `json:"jid" gorm:"column:jid"`
-	QRCode           *string   `json:"qrcode" gorm:"type:text;column:qrcode"`
-	Status           string    `json:"status" gorm:"default:disconnected;column:status"`
-	Message          *string   `json:"message" gorm:"column:message"`
-	AutoReadMessages bool      `json:"autoReadMessages" gorm:"default:false;column:autoReadMessages"`
-	TypingIndicator  bool      `json:"typingIndicator" gorm:"default:false;column:typingIndicator"`
-	IsSystemSession  bool      `json:"isSystemSession" gorm:"default:false;column:isSystemSession"`
-	CreatedAt        time.Time `json:"createdAt" gorm:"autoCreateTime;column:createdAt"`
-	UpdatedAt        time.Time `json:"updatedAt" gorm:"autoUpdateTime;column:updatedAt"`
+	ID               string  `json:"id" gorm:"primaryKey;type:varchar(30);column:id"`
+	SessionID        string  `json:"sessionId" gorm:"unique;column:sessionId"`
+	SessionName      string  `json:"sessionName" gorm:"column:sessionName"`
+	Token            string  `json:"token" gorm:"unique;column:token"`
+	UserID           *string `json:"userId" gorm:"column:userId;index"`
+	Webhook          *string `json:"webhook" gorm:"column:webhook"`
+	Events           *string `json:"events" gorm:"column:events"`
+	Expiration       int     `json:"expiration" gorm:"default:0;column:expiration"`
+	Connected        bool    `json:"connected" gorm:"default:false;column:connected"`
+	LoggedIn         bool    `json:"loggedIn" gorm:"default:false;column:loggedIn"`
+	JID              *string `json:"jid" gorm:"column:jid"`
+	QRCode           *string `json:"qrcode" gorm:"type:text;column:qrcode"`
+	Status           string  `json:"status" gorm:"default:disconnected;column:status"`
+	Message          *string `json:"message" gorm:"column:message"`
+	AutoReadMessages bool    `json:"autoReadMessages" gorm:"default:false;column:autoReadMessages"`
+	TypingIndicator  bool    `json:"typingIndicator" gorm:"default:false;column:typingIndicator"`
+	IsSystemSession  bool    `json:"isSystemSession" gorm:"default:false;column:isSystemSession"`
+	// WAServerURL is the WA server instance hosting the session (sharding); NULL = WA_SERVER_URL
+	WAServerURL *string `json:"waServerUrl" gorm:"column:waServerUrl"`
+	// ForwardWebhookURL gets a copy of every incoming message, HMAC-signed with ForwardWebhookSecret
+	ForwardWebhookURL    *string   `json:"forwardWebhookUrl" gorm:"column:forwardWebhookUrl"`
+	ForwardWebhookSecret *string   `json:"-" gorm:"column:forwardWebhookSecret"`
+	CreatedAt            time.Time `json:"createdAt" gorm:"autoCreateTime;column:createdAt"`
+	UpdatedAt            time.Time `json:"updatedAt" gorm:"autoUpdateTime;column:updatedAt"`
 }
 
 // TableName specifies the table name for GORM
@@ -32,16 +37,46 @@ func (WhatsappSession) TableName() string {
 	return "WhatsAppSession"
 }
 
+// WhatsAppSession column names (Prisma camelCase - must be quoted in raw SQL, e.g. "userId").
+// Use these in map conditions (GORM quotes them) instead of hand-written SQL strings.
+const (
+	WhatsappSessionColID               = "id"
+	WhatsappSessionColSessionID        = "sessionId"
+	WhatsappSessionColToken            = "token"
+	WhatsappSessionColUserID           = "userId"
+	WhatsappSessionColConnected        = "connected"
+	WhatsappSessionColJID              = "jid"
+	WhatsappSessionColAutoReadMessages = "autoReadMessages"
+	WhatsappSessionColTypingIndicator  = "typingIndicator"
+	WhatsappSessionColUpdatedAt        = "updatedAt"
+	WhatsappSessionColWAServerURL      = "waServerUrl"
+
+	WhatsappSessionColForwardWebhookURL    = "forwardWebhookUrl"
+	WhatsappSessionColForwardWebhookSecret = "forwardWebhookSecret"
+)
+
+// WhatsappSessionOptionalColumns are columns added by newer Prisma migrations. The service runs
+// without them (the feature stays off until the schema is migrated), so the startup check doesn't
+// require them and queries must not select them explicitly without checking.
+var WhatsappSessionOptionalColumns = []string{
+	WhatsappSessionColWAServerURL,
+	WhatsappSessionColForwardWebhookURL,
+	WhatsappSessionColForwardWebhookSecret,
+}
+
 // WhatsappApiPackage model - sesuai dengan skema Prisma
 type WhatsappApiPackage struct {
-	ID          string    `json:"id" gorm:"primaryKey;type:varchar(30);column:id"`
-	Name        string    `json:"name" gorm:"column:name"`
-	Description *string   `json:"description" gorm:"column:description"`
-	PriceMonth  int       `json:"priceMonth" gorm:"column:priceMonth"`
-	PriceYear   int       `json:"priceYear" gorm:"column:priceYear"`
-	MaxSession  int       `json:"maxSession" gorm:"column:maxSession"`
-	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime;column:createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime;column:updatedAt"`
+	ID          string  `json:"id" gorm:"primaryKey;type:varchar(30);column:id"`
+	Name        string  `json:"name" gorm:"column:name"`
+	Description *string `json:"description" gorm:"column:description"`
+	PriceMonth  int     `json:"priceMonth" gorm:"column:priceMonth"`
+	PriceYear   int     `json:"priceYear" gorm:"column:priceYear"`
+	MaxSession  int     `json:"maxSession" gorm:"column:maxSession"`
+	// BulkMessaging enables bulk campaigns for the package. Nullable and optional in the schema:
+	// when the column is missing or NULL, BULK_ENABLED_PACKAGES decides (see middleware.RequireBulkEntitlement)
+	BulkMessaging *bool     `json:"bulkMessaging,omitempty" gorm:"column:bulkMessaging"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime;column:createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"autoUpdateTime;column:updatedAt"`
 }
 
 // TableName specifies the table name for GORM
@@ -110,7 +145,28 @@ func (WhatsAppMessageStats) TableName() string {
 // Gateway Response Types
 type GatewayResponse struct {
 	Status  int         `json:"status"`
-	Message string      `json:"message"`
+	Code    string      `json:"code,omitempty"` // machine-readable, one of the GatewayCode* values below
+	Message string      `json:"message"`        // human-readable, may change - clients should switch on Code
 	Data    interface{} `json:"data,omitempty"`
 	Error   *string     `json:"error,omitempty"`
 }
+
+// GatewayResponse.Code values (stable - frontends map them to localized messages)
+const (
+	GatewayCodeTokenRequired        = "TOKEN_REQUIRED"         // 401: no token / Authorization header
+	GatewayCodeTokenInvalid         = "TOKEN_INVALID"          // 403: no session with this token
+	GatewayCodeSessionNoUser        = "SESSION_NO_USER"        // 403: session not linked to a user
+	GatewayCodeSubscriptionNotFound = "SUBSCRIPTION_NOT_FOUND" // 403: user has no active subscription
+	GatewayCodeSubscriptionExpired  = "SUBSCRIPTION_EXPIRED"   // 403: subscription past its expiry date
+	GatewayCodePackageNotFound      = "PACKAGE_NOT_FOUND"      // 403: subscription's package is missing
+	GatewayCodeSessionLimit         = "SESSION_LIMIT"          // 403: connect would exceed the package's maxSession
+	GatewayCodeSessionConnected     = "SESSION_CONNECTED"      // 409: QR requested for a session that is already connected
+	GatewayCodeInvalidRequest       = "INVALID_REQUEST"        // 400: body could not be processed
+	GatewayCodeRouteNotFound        = "ROUTE_NOT_FOUND"        // 404: not a WA server endpoint (GATEWAY_ROUTE_MODE=strict)
+	GatewayCodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"     // 405: endpoint exists but not with this method
+	GatewayCodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"      // 413: image body or downloaded image over the limit
+	GatewayCodeSendRateLimited      = "SEND_RATE_LIMITED"      // 429: session's send queue longer than WA_SEND_MAX_WAIT_MS
+	GatewayCodeWAServerUnavailable  = "WA_SERVER_UNAVAILABLE"  // 502/500: WA server unreachable or not configured
+	GatewayCodeWAServerTimeout      = "WA_SERVER_TIMEOUT"      // 504: WA server did not answer within GATEWAY_TIMEOUT_*_SECONDS
+	GatewayCodeInternal             = "INTERNAL_ERROR"         // database or gateway failure
+)
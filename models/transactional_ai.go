@@ -8,14 +8,45 @@ import "time"
 
 // WhatsAppAIBot matches Prisma model WhatsAppAIBot
 type WhatsAppAIBot struct {
-	ID           string    `gorm:"column:id;primaryKey" json:"id"`
-	UserID       string    `gorm:"column:userId;not null" json:"userId"`
-	Name         string    `gorm:"column:name;not null;default:'Default Bot'" json:"name"`
-	IsActive     bool      `gorm:"column:isActive;not null;default:false" json:"isActive"`
-	SystemPrompt *string   `gorm:"column:systemPrompt;type:text" json:"systemPrompt"`
-	FallbackText *string   `gorm:"column:fallbackText;type:text" json:"fallbackText"`
-	CreatedAt    time.Time `gorm:"column:createdAt;not null;default:now()" json:"createdAt"`
-	UpdatedAt    time.Time `gorm:"column:updatedAt;not null" json:"updatedAt"`
+	ID           string  `gorm:"column:id;primaryKey" json:"id"`
+	UserID       string  `gorm:"column:userId;not null" json:"userId"`
+	Name         string  `gorm:"column:name;not null;default:'Default Bot'" json:"name"`
+	IsActive     bool    `gorm:"column:isActive;not null;default:false" json:"isActive"`
+	SystemPrompt *string `gorm:"column:systemPrompt;type:text" json:"systemPrompt"`
+	FallbackText *string `gorm:"column:fallbackText;type:text" json:"fallbackText"`
+	MaxDocuments *int    `gorm:"column:maxDocuments" json:"maxDocuments"` // null or <= 0 = global AI_MAX_DOCUMENTS
+	// false = no knowledge base in the prompt (documents aren't even fetched); null = enabled
+	UseKnowledgeBase *bool `gorm:"column:useKnowledgeBase" json:"useKnowledgeBase"`
+	// JSON object inbound type -> route, e.g. {"text":"reply","image":"handoff"}
+	MessageTypeHandling *string `gorm:"column:messageTypeHandling;type:jsonb" json:"messageTypeHandling"`
+	// Opt-in: the bot may answer with [SEND_IMAGE:url] for image URLs in its knowledge base
+	AllowImageSend *bool `gorm:"column:allowImageSend" json:"allowImageSend"`
+	// Opt-in: [SEND_LOCATION:lat,lng,name] / [SEND_CONTACT:name,phone] cards from the knowledge base
+	AllowLocationSend *bool `gorm:"column:allowLocationSend" json:"allowLocationSend"`
+	AllowContactSend  *bool `gorm:"column:allowContactSend" json:"allowContactSend"`
+	// false = the bot never marks customer messages as read (no blue ticks); null = auto-read
+	AutoRead *bool `gorm:"column:autoRead" json:"autoRead"`
+	// JSON weekly schedule, e.g. {"timezone":"Asia/Jakarta","hours":{"mon":"09:00-17:00"}}; null = always on
+	BusinessHours     *string `gorm:"column:businessHours;type:jsonb" json:"businessHours"`
+	AfterHoursMessage *string `gorm:"column:afterHoursMessage;type:text" json:"afterHoursMessage"`
+	// Sent once to a contact's very first message, before the AI reply; null/empty = no greeting
+	GreetingMessage   *string `gorm:"column:greetingMessage;type:text" json:"greetingMessage"`
+	ContactFilterMode *string `gorm:"column:contactFilterMode" json:"contactFilterMode"`        // "allowlist" | "denylist" | null = everyone
+	AllowedContacts   *string `gorm:"column:allowedContacts;type:jsonb" json:"allowedContacts"` // JSON array of phone numbers
+	BlockedContacts   *string `gorm:"column:blockedContacts;type:jsonb" json:"blockedContacts"`
+	// JSON array of agents notified on handoff, e.g. [{"name":"CS","phone":"628...","webhookUrl":"https://..."}]
+	EscalationContacts *string `gorm:"column:escalationContacts;type:jsonb" json:"escalationContacts"`
+	// Max AI replies to one contact per day (loop guard); null or <= 0 = AI_MAX_DAILY_REPLIES_PER_CONTACT
+	MaxDailyRepliesPerContact *int `gorm:"column:maxDailyRepliesPerContact" json:"maxDailyRepliesPerContact"`
+	// Bring your own key: "openrouter" | "gemini" (null = AI_PROVIDER), model (null = provider default) and
+	// the customer's API key, AES-GCM encrypted with AI_CREDENTIALS_KEY (null = our global key)
+	AIProvider *string `gorm:"column:aiProvider" json:"aiProvider"`
+	AIModel    *string `gorm:"column:aiModel" json:"aiModel"`
+	AIAPIKey   *string `gorm:"column:aiApiKey;type:text" json:"-"`
+	// Reply language "id" | "en" (instruction + detect/retry), "auto" = never enforced; null = AI_RESPONSE_LANGUAGE
+	ResponseLanguage *string   `gorm:"column:responseLanguage" json:"responseLanguage"`
+	CreatedAt        time.Time `gorm:"column:createdAt;not null;default:now()" json:"createdAt"`
+	UpdatedAt        time.Time `gorm:"column:updatedAt;not null" json:"updatedAt"`
 }
 
 func (WhatsAppAIBot) TableName() string {
@@ -50,6 +81,7 @@ type AIUsageLog struct {
 	LatencyMs    int       `gorm:"column:latencyMs;not null;default:0" json:"latencyMs"`
 	Status       string    `gorm:"column:status;not null;default:'ok'" json:"status"`
 	ErrorReason  *string   `gorm:"column:errorReason;type:text" json:"errorReason"`
+	DedupeKey    *string   `gorm:"column:dedupeKey;uniqueIndex" json:"dedupeKey"` // job ID + attempt: a retried write is stored once
 	CreatedAt    time.Time `gorm:"column:createdAt;not null;default:now()" json:"createdAt"`
 }
 
@@ -225,8 +225,14 @@ type ChatRoom struct {
 	LastSender   string    `json:"last_sender"` // 'user' or 'contact'
 	LastActivity time.Time `json:"last_activity" gorm:"autoUpdateTime"`
 	UnreadCount  int       `json:"unread_count" gorm:"default:0"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	// Archived rooms keep their messages but are hidden from the room list until a new incoming message
+	IsArchived bool       `json:"is_archived" gorm:"default:false;index"`
+	ArchivedAt *time.Time `json:"archived_at"`
+	// Deleted rooms lose their chat_messages; the row stays (chat_id is unique) and is revived by the next message
+	IsDeleted bool       `json:"is_deleted" gorm:"default:false;index"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 // ChatMessage represents individual messages in chat rooms with status tracking
@@ -243,7 +249,7 @@ type ChatMessage struct {
 	Caption          string     `json:"caption" gorm:"type:text"`
 	MediaData        JSONB      `json:"media_data" gorm:"type:jsonb"`
 	QuotedMessageID  string     `json:"quoted_message_id"`
-	Status           string     `json:"status" gorm:"default:'sent'"` // sent, delivered, read
+	Status           string     `json:"status" gorm:"default:'sent'"` // sent, delivered, read, revoked
 	MessageTimestamp time.Time  `json:"message_timestamp" gorm:"not null;index"`
 	DeliveredAt      *time.Time `json:"delivered_at"`
 	ReadAt           *time.Time `json:"read_at"`
@@ -253,3 +259,22 @@ type ChatMessage struct {
 	// Relations
 	ChatRoom *ChatRoom `json:"chat_room,omitempty" gorm:"foreignKey:ChatRoomID"`
 }
+
+// MediaArchive is an incoming media file downloaded from the WA server and kept for the agent UI
+// (MEDIA_STORE_BACKEND). The file itself lives on local disk or S3 under StorageKey.
+type MediaArchive struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	SessionTok    string    `json:"-" gorm:"uniqueIndex:idx_media_archive_message;not null"`
+	MessageID     string    `json:"message_id" gorm:"uniqueIndex:idx_media_archive_message;not null"` // WhatsApp message ID
+	ChatMessageID uint      `json:"chat_message_id" gorm:"index"`                                     // chat_messages row showing it
+	MediaType     string    `json:"media_type"`                                                       // image | video | audio | document | sticker
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	Backend       string    `json:"backend"` // local | s3
+	StorageKey    string    `json:"storage_key" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (MediaArchive) TableName() string {
+	return "media_archives"
+}
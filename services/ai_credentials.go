@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"genfity-wa-support/config"
+)
+
+// errCredentialsKeyMissing - a customer API key has to be encrypted/decrypted but AI_CREDENTIALS_KEY is unset
+var errCredentialsKeyMissing = errors.New("AI_CREDENTIALS_KEY not set")
+
+// credentialsCipher returns the AES-256-GCM cipher for customer API keys. The AES key is the
+// SHA-256 of AI_CREDENTIALS_KEY (the app encrypting the keys must use the same secret).
+func credentialsCipher() (cipher.AEAD, error) {
+	secret := config.GetEnvString("AI_CREDENTIALS_KEY", "")
+	if secret == "" {
+		return nil, errCredentialsKeyMissing
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptAPIKey encrypts a customer API key for storage: base64(12-byte nonce || AES-GCM ciphertext)
+func EncryptAPIKey(apiKey string) (string, error) {
+	gcm, err := credentialsCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(apiKey), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptAPIKey decrypts a key stored by EncryptAPIKey. Errors never contain the key itself.
+func DecryptAPIKey(encrypted string) (string, error) {
+	gcm, err := credentialsCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("encrypted API key is not valid base64")
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted API key is too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("encrypted API key could not be decrypted (wrong AI_CREDENTIALS_KEY?)")
+	}
+	return string(plain), nil
+}
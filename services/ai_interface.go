@@ -1,6 +1,11 @@
 package services
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"genfity-wa-support/config"
+)
 
 // AIProvider is the interface that all AI providers must implement
 type AIProvider interface {
@@ -14,3 +19,38 @@ type AIProvider interface {
 	// GetModelName returns the model name being used
 	GetModelName() string
 }
+
+// modelOverrideKey is the context key for a per-request model override
+type modelOverrideKey struct{}
+
+// WithModelOverride makes AskLLM calls with ctx use model instead of the provider's configured one
+// (e.g. a larger-context model for a prompt the default model can't fit)
+func WithModelOverride(ctx context.Context, model string) context.Context {
+	if model == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, modelOverrideKey{}, model)
+}
+
+// requestModel returns the model an AskLLM call should use: the ctx override, else configured
+func requestModel(ctx context.Context, configured string) string {
+	if model, ok := ctx.Value(modelOverrideKey{}).(string); ok && model != "" {
+		return model
+	}
+	return configured
+}
+
+// ContextFallbackModel returns AI_CONTEXT_FALLBACK_MODEL, the larger-context model tried when the
+// prompt doesn't fit the configured model even with fewer messages ("" = no model fallback)
+func ContextFallbackModel() string {
+	return config.GetEnvString("AI_CONTEXT_FALLBACK_MODEL", "")
+}
+
+// AITimeout returns the per-call LLM timeout from AI_TIMEOUT_MS (default 120s)
+func AITimeout() time.Duration {
+	timeoutMs := config.GetEnvInt("AI_TIMEOUT_MS", 120000)
+	if timeoutMs <= 0 {
+		timeoutMs = 120000
+	}
+	return time.Duration(timeoutMs) * time.Millisecond
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// defaultAIProviderCacheSize is the default max customer clients kept (AI_PROVIDER_CACHE_SIZE)
+const defaultAIProviderCacheSize = 100
+
+// BotLLM is the provider a bot's jobs call, with the circuit breaker guarding it
+type BotLLM struct {
+	Provider AIProvider
+	Breaker  *CircuitBreaker
+}
+
+// newCustomerProvider builds a client from a customer's decrypted credentials (a var so tests
+// can count constructions without calling the real APIs)
+var newCustomerProvider = func(providerName, apiKey, model string) (AIProvider, error) {
+	switch providerName {
+	case "openrouter":
+		return newOpenRouterClient(apiKey, model)
+	case "gemini":
+		return newGeminiClient(apiKey, model)
+	default:
+		return nil, fmt.Errorf("unsupported AI provider %q (valid options: openrouter, gemini)", providerName)
+	}
+}
+
+// aiProviderCache keeps customer clients per credential so they aren't rebuilt for every message
+var aiProviderCache = struct {
+	sync.Mutex
+	entries map[string]BotLLM
+}{entries: make(map[string]BotLLM)}
+
+// aiProviderCacheSize returns AI_PROVIDER_CACHE_SIZE (max customer clients kept in memory)
+func aiProviderCacheSize() int {
+	size := config.GetEnvInt("AI_PROVIDER_CACHE_SIZE", defaultAIProviderCacheSize)
+	if size <= 0 {
+		return defaultAIProviderCacheSize
+	}
+	return size
+}
+
+// botProviderName returns the bot's provider, defaulting to AI_PROVIDER (then openrouter)
+func botProviderName(settings *BotSettings) string {
+	name := strings.ToLower(strings.TrimSpace(settings.AIProvider))
+	if name == "" {
+		name = strings.ToLower(os.Getenv("AI_PROVIDER"))
+	}
+	if name == "" {
+		name = "openrouter"
+	}
+	return name
+}
+
+// credentialFingerprint identifies a credential in the cache and in breaker names without exposing it
+func credentialFingerprint(providerName, model, encryptedKey string) string {
+	sum := sha256.Sum256([]byte(providerName + "\x00" + model + "\x00" + encryptedKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// ResolveBotLLM returns the provider for a bot's jobs. A bot with its own (encrypted) API key gets
+// a cached client built from it, with a breaker of its own so a customer's bad key can't open the
+// global one; a bot with only a model gets the global client asking that model. Anything else -
+// or credentials that can't be used - falls back to global.
+func ResolveBotLLM(settings *BotSettings, global BotLLM) BotLLM {
+	if settings == nil {
+		return global
+	}
+	if settings.AIAPIKey == "" {
+		if settings.AIModel != "" {
+			return BotLLM{Provider: &modelOverrideProvider{AIProvider: global.Provider, model: settings.AIModel}, Breaker: global.Breaker}
+		}
+		return global
+	}
+
+	providerName := botProviderName(settings)
+	fingerprint := credentialFingerprint(providerName, settings.AIModel, settings.AIAPIKey)
+
+	aiProviderCache.Lock()
+	defer aiProviderCache.Unlock()
+	if cached, ok := aiProviderCache.entries[fingerprint]; ok {
+		return cached
+	}
+
+	apiKey, err := DecryptAPIKey(settings.AIAPIKey)
+	if err != nil {
+		log.Printf("⚠️  Customer AI key unusable (%s, fingerprint %s): %v - using the global AI provider", providerName, fingerprint[:12], err)
+		return global
+	}
+	provider, err := newCustomerProvider(providerName, apiKey, settings.AIModel)
+	if err != nil {
+		log.Printf("⚠️  Customer AI provider failed (%s, fingerprint %s): %v - using the global AI provider", providerName, fingerprint[:12], err)
+		return global
+	}
+
+	// Bounded: drop an arbitrary client when full, it is rebuilt on its next message
+	if len(aiProviderCache.entries) >= aiProviderCacheSize() {
+		for key := range aiProviderCache.entries {
+			delete(aiProviderCache.entries, key)
+			break
+		}
+	}
+	entry := BotLLM{
+		Provider: provider,
+		Breaker:  NewCircuitBreaker("ai_provider:"+fingerprint[:12], 5, 60*time.Second),
+	}
+	aiProviderCache.entries[fingerprint] = entry
+	log.Printf("🔑 Customer AI provider ready (%s, model %s, fingerprint %s)", providerName, provider.GetModelName(), fingerprint[:12])
+	return entry
+}
+
+// modelOverrideProvider asks the wrapped provider a bot's own model. A model already chosen
+// on the request (context-length fallback) still wins.
+type modelOverrideProvider struct {
+	AIProvider
+	model string
+}
+
+func (m *modelOverrideProvider) AskLLM(ctx context.Context, systemPrompt, userPrompt string) (string, int, int, error) {
+	if override, ok := ctx.Value(modelOverrideKey{}).(string); !ok || override == "" {
+		ctx = WithModelOverride(ctx, m.model)
+	}
+	return m.AIProvider.AskLLM(ctx, systemPrompt, userPrompt)
+}
+
+func (m *modelOverrideProvider) GetModelName() string {
+	return m.model
+}
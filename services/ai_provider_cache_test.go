@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// modelRecordingProvider records the model each AskLLM call was asked to use
+type modelRecordingProvider struct {
+	name   string
+	models []string
+}
+
+func (p *modelRecordingProvider) AskLLM(ctx context.Context, systemPrompt, userPrompt string) (string, int, int, error) {
+	p.models = append(p.models, requestModel(ctx, "configured-model"))
+	return "ok", 1, 1, nil
+}
+
+func (p *modelRecordingProvider) GetProviderName() string { return p.name }
+func (p *modelRecordingProvider) GetModelName() string    { return "configured-model" }
+
+func TestEncryptDecryptAPIKey(t *testing.T) {
+	t.Setenv("AI_CREDENTIALS_KEY", "test-secret")
+
+	encrypted, err := EncryptAPIKey("sk-or-v1-customer")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if strings.Contains(encrypted, "sk-or-v1-customer") {
+		t.Fatal("encrypted value contains the plain key")
+	}
+	plain, err := DecryptAPIKey(encrypted)
+	if err != nil || plain != "sk-or-v1-customer" {
+		t.Fatalf("decrypt = %q, %v", plain, err)
+	}
+
+	t.Setenv("AI_CREDENTIALS_KEY", "another-secret")
+	if _, err := DecryptAPIKey(encrypted); err == nil {
+		t.Fatal("expected an error with the wrong AI_CREDENTIALS_KEY")
+	}
+
+	t.Setenv("AI_CREDENTIALS_KEY", "")
+	if _, err := DecryptAPIKey(encrypted); !errors.Is(err, errCredentialsKeyMissing) {
+		t.Fatalf("err = %v, want errCredentialsKeyMissing", err)
+	}
+}
+
+func TestResolveBotLLMCachesCustomerProvider(t *testing.T) {
+	t.Setenv("AI_CREDENTIALS_KEY", "test-secret")
+	aiProviderCache.entries = make(map[string]BotLLM)
+
+	var built []string
+	original := newCustomerProvider
+	newCustomerProvider = func(providerName, apiKey, model string) (AIProvider, error) {
+		built = append(built, providerName+"|"+apiKey+"|"+model)
+		return &modelRecordingProvider{name: providerName}, nil
+	}
+	defer func() { newCustomerProvider = original }()
+
+	global := BotLLM{Provider: &modelRecordingProvider{name: "global"}, Breaker: NewCircuitBreaker("test_global", 5, 0)}
+	encrypted, _ := EncryptAPIKey("customer-key")
+	settings := &BotSettings{AIProvider: "Gemini", AIModel: "gemini-2.5-pro", AIAPIKey: encrypted}
+
+	first := ResolveBotLLM(settings, global)
+	second := ResolveBotLLM(settings, global)
+	if first.Provider == global.Provider || first.Breaker == global.Breaker {
+		t.Fatal("customer credentials should get their own provider and breaker")
+	}
+	if first.Provider != second.Provider {
+		t.Error("second job rebuilt the provider instead of using the cache")
+	}
+	if len(built) != 1 || built[0] != "gemini|customer-key|gemini-2.5-pro" {
+		t.Errorf("built = %v, want one gemini client with the decrypted key", built)
+	}
+
+	// A different key is a different client
+	otherKey, _ := EncryptAPIKey("other-key")
+	ResolveBotLLM(&BotSettings{AIProvider: "gemini", AIModel: "gemini-2.5-pro", AIAPIKey: otherKey}, global)
+	if len(built) != 2 {
+		t.Errorf("built %d clients, want 2", len(built))
+	}
+}
+
+func TestResolveBotLLMFallsBackToGlobal(t *testing.T) {
+	t.Setenv("AI_CREDENTIALS_KEY", "test-secret")
+	aiProviderCache.entries = make(map[string]BotLLM)
+	global := BotLLM{Provider: &modelRecordingProvider{name: "global"}}
+
+	if got := ResolveBotLLM(nil, global); got.Provider != global.Provider {
+		t.Error("nil settings should use the global provider")
+	}
+	if got := ResolveBotLLM(&BotSettings{}, global); got.Provider != global.Provider {
+		t.Error("bot without credentials should use the global provider")
+	}
+	if got := ResolveBotLLM(&BotSettings{AIAPIKey: "not-encrypted"}, global); got.Provider != global.Provider {
+		t.Error("undecryptable key should fall back to the global provider")
+	}
+	encrypted, _ := EncryptAPIKey("customer-key")
+	if got := ResolveBotLLM(&BotSettings{AIProvider: "unknown", AIAPIKey: encrypted}, global); got.Provider != global.Provider {
+		t.Error("unsupported provider should fall back to the global provider")
+	}
+}
+
+func TestResolveBotLLMModelOnly(t *testing.T) {
+	recorder := &modelRecordingProvider{name: "global"}
+	global := BotLLM{Provider: recorder}
+
+	llm := ResolveBotLLM(&BotSettings{AIModel: "openai/gpt-4o"}, global)
+	if llm.Provider.GetModelName() != "openai/gpt-4o" {
+		t.Errorf("model name = %q", llm.Provider.GetModelName())
+	}
+
+	llm.Provider.AskLLM(context.Background(), "system", "halo")
+	// A context-length fallback model chosen on the request still wins
+	llm.Provider.AskLLM(WithModelOverride(context.Background(), "big-context-model"), "system", "halo")
+
+	want := []string{"openai/gpt-4o", "big-context-model"}
+	if len(recorder.models) != 2 || recorder.models[0] != want[0] || recorder.models[1] != want[1] {
+		t.Errorf("models asked = %v, want %v", recorder.models, want)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Alert is the JSON body POSTed to ALERT_WEBHOOK_URL
+type Alert struct {
+	Event   string                 `json:"event"` // e.g. "credits_low", "session_disconnected"
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	Time    time.Time              `json:"time"`
+}
+
+// Swappable in tests
+var postAlert = postAlertJSON
+
+// SendAlert notifies operators through ALERT_WEBHOOK_URL (Slack-compatible or any JSON receiver).
+// No URL = log only. Delivery runs in the background and failures are only logged.
+func SendAlert(event, message string, data map[string]interface{}) {
+	webhookURL := config.GetEnvString("ALERT_WEBHOOK_URL", "")
+	if webhookURL == "" {
+		return
+	}
+	alert := Alert{Event: event, Message: message, Data: data, Time: time.Now()}
+	go func() {
+		if err := postAlert(webhookURL, alert); err != nil {
+			log.Printf("⚠️  Failed to send %s alert: %v", event, err)
+		}
+	}()
+}
+
+// postAlertJSON POSTs the alert; "text" is included so Slack incoming webhooks show the message
+func postAlertJSON(webhookURL string, alert Alert) error {
+	payload := map[string]interface{}{
+		"text":    fmt.Sprintf("[%s] %s", alert.Event, alert.Message),
+		"event":   alert.Event,
+		"message": alert.Message,
+		"data":    alert.Data,
+		"time":    alert.Time,
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	resp, err := NewHTTPClient(10*time.Second).Post(webhookURL, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostAlertJSON(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	alert := Alert{Event: "session_disconnected", Message: "WhatsApp session abc: Disconnected", Time: time.Now()}
+	if err := postAlertJSON(server.URL, alert); err != nil {
+		t.Fatalf("postAlertJSON: %v", err)
+	}
+	if got["event"] != "session_disconnected" || got["text"] != "[session_disconnected] WhatsApp session abc: Disconnected" {
+		t.Errorf("payload = %v", got)
+	}
+}
+
+func TestSendAlertWithoutWebhookURL(t *testing.T) {
+	t.Setenv("ALERT_WEBHOOK_URL", "")
+	previous := postAlert
+	postAlert = func(string, Alert) error {
+		t.Error("alert posted without ALERT_WEBHOOK_URL")
+		return nil
+	}
+	t.Cleanup(func() { postAlert = previous })
+
+	SendAlert("credits_low", "low", nil)
+	time.Sleep(10 * time.Millisecond)
+}
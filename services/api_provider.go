@@ -9,13 +9,18 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"genfity-wa-support/config"
 )
 
 // APIProvider implements DataProvider via HTTP API calls to Next.js
 type APIProvider struct {
-	baseURL string
-	apiKey  string
-	client  *http.Client
+	baseURL      string
+	apiKey       string
+	previousKey  string // INTERNAL_API_KEY_PREVIOUS, tried once when the API rejects apiKey
+	client       *http.Client
+	maxRetries   int           // extra attempts on 5xx / connection errors
+	retryBackoff time.Duration // wait before the first retry, doubled per retry
 }
 
 // NewAPIProvider creates new API-based data provider
@@ -25,39 +30,106 @@ func NewAPIProvider() *APIProvider {
 		transactionalURL = "http://localhost:8090/api"
 	}
 
-	apiKey := os.Getenv("INTERNAL_API_KEY")
+	apiKey := config.InternalAPIKey()
+
+	timeoutMs := config.GetEnvInt("TRANSACTIONAL_API_TIMEOUT_MS", 5000)
+	if timeoutMs <= 0 {
+		timeoutMs = 5000
+	}
+	maxRetries := config.GetEnvInt("TRANSACTIONAL_API_RETRIES", 2)
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	backoffMs := config.GetEnvInt("TRANSACTIONAL_API_RETRY_BACKOFF_MS", 200)
+	if backoffMs < 0 {
+		backoffMs = 0
+	}
 
 	return &APIProvider{
-		baseURL: transactionalURL,
-		apiKey:  apiKey,
-		client: &http.Client{
-			Timeout: 5 * time.Second,
-		},
+		baseURL:      transactionalURL,
+		apiKey:       apiKey,
+		previousKey:  config.PreviousInternalAPIKey(),
+		client:       NewHTTPClient(time.Duration(timeoutMs) * time.Millisecond),
+		maxRetries:   maxRetries,
+		retryBackoff: time.Duration(backoffMs) * time.Millisecond,
 	}
 }
 
-// ResolveSession resolves WhatsApp session token to user info via API
-func (p *APIProvider) ResolveSession(instanceName string) (*SessionInfo, error) {
-	url := fmt.Sprintf("%s/whatsapp/session/resolve?token=%s", p.baseURL, instanceName)
+// doWithRetry sends the request built by newReq, retrying on connection errors and 5xx
+// with exponential backoff. newReq is called per attempt so POST bodies are fresh.
+func (p *APIProvider) doWithRetry(newReq func() (*http.Request, error)) (*http.Response, error) {
+	backoff := p.retryBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := p.client.Do(req)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && p.previousKey != "" {
+			resp, err = p.retryWithPreviousKey(req, resp, newReq)
+		}
+		retryable := err != nil || resp.StatusCode >= 500
+		if !retryable || attempt >= p.maxRetries {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Printf("⚠️  [API] %s %s failed (attempt %d/%d): %v - retrying in %v",
+				req.Method, req.URL.Path, attempt+1, p.maxRetries+1, err, backoff)
+		} else {
+			log.Printf("⚠️  [API] %s %s returned %d (attempt %d/%d) - retrying in %v",
+				req.Method, req.URL.Path, resp.StatusCode, attempt+1, p.maxRetries+1, backoff)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryWithPreviousKey repeats a request the API rejected with INTERNAL_API_KEY_PREVIOUS - during a
+// rotation the transactional API may not have the new key yet
+func (p *APIProvider) retryWithPreviousKey(rejected *http.Request, resp *http.Response, newReq func() (*http.Request, error)) (*http.Response, error) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newReq()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("x-api-key", p.previousKey)
+	resp, err = p.client.Do(req)
+	if err == nil && resp.StatusCode != http.StatusUnauthorized {
+		log.Printf("⚠️  [API] %s %s rejected INTERNAL_API_KEY but accepted INTERNAL_API_KEY_PREVIOUS - finish the key rotation on the transactional API",
+			rejected.Method, rejected.URL.Path)
+	}
+	return resp, err
+}
+
+// ResolveSession resolves WhatsApp session token to user info via API
+func (p *APIProvider) ResolveSession(instanceName string) (*SessionInfo, error) {
+	url := fmt.Sprintf("%s/whatsapp/session/resolve?token=%s", p.baseURL, instanceName)
 
 	// Debug logging
 	log.Printf("🔑 Making request to: %s", url)
 	log.Printf("🔑 API Key configured: %v (length: %d)", p.apiKey != "", len(p.apiKey))
 	if p.apiKey != "" {
-		log.Printf("🔑 API Key preview: %s...", p.apiKey[:10])
+		log.Printf("🔑 API Key preview: %s", PreviewText(p.apiKey, 10))
 	}
 
-	// Add internal API key if configured
-	if p.apiKey != "" {
-		req.Header.Set("x-api-key", p.apiKey)
-	}
-
-	resp, err := p.client.Do(req)
+	resp, err := p.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Add internal API key if configured
+		if p.apiKey != "" {
+			req.Header.Set("x-api-key", p.apiKey)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call session resolve API: %w", err)
 	}
@@ -89,17 +161,17 @@ func (p *APIProvider) GetBotSettings(userID, sessionToken string) (*BotSettings,
 	url := fmt.Sprintf("%s/whatsapp/bot/settings?userId=%s&sessionToken=%s",
 		p.baseURL, userID, sessionToken)
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Add internal API key if configured
-	if p.apiKey != "" {
-		req.Header.Set("x-api-key", p.apiKey)
-	}
-
-	resp, err := p.client.Do(req)
+	resp, err := p.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Add internal API key if configured
+		if p.apiKey != "" {
+			req.Header.Set("x-api-key", p.apiKey)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call bot settings API: %w", err)
 	}
@@ -139,21 +211,27 @@ func (p *APIProvider) LogUsage(log *UsageLogRequest) error {
 		"latencyMs":    log.LatencyMs,
 		"status":       log.Status,
 		"errorReason":  log.ErrorReason,
+		"dedupeKey":    log.DedupeKey,
 	}
 
 	jsonData, _ := json.Marshal(payload)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	if p.apiKey != "" {
-		req.Header.Set("x-api-key", p.apiKey)
-	}
-
-	resp, err := p.client.Do(req)
+	// Retried too - a lost usage log is lost billing data
+	resp, err := p.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		// Same key on every retry: the API stores the usage once even if a response got lost
+		if log.DedupeKey != "" {
+			req.Header.Set("Idempotency-Key", log.DedupeKey)
+		}
+		if p.apiKey != "" {
+			req.Header.Set("x-api-key", p.apiKey)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to call usage log API: %w", err)
 	}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestAPIProvider points an APIProvider at server with fast retries
+func newTestAPIProvider(t *testing.T, server *httptest.Server, retries string) *APIProvider {
+	t.Helper()
+	t.Setenv("TRANSACTIONAL_API_URL", server.URL)
+	t.Setenv("INTERNAL_API_KEY", "test-internal-key")
+	t.Setenv("TRANSACTIONAL_API_RETRIES", retries)
+	t.Setenv("TRANSACTIONAL_API_RETRY_BACKOFF_MS", "1")
+	return NewAPIProvider()
+}
+
+func TestAPIProviderResolveSessionRetriesOn503(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"success":true,"data":{"userId":"u1","botActive":true,"subscriptionActive":true,"sessionToken":"s1"}}`)
+	}))
+	defer server.Close()
+
+	session, err := newTestAPIProvider(t, server, "2").ResolveSession("s1")
+	if err != nil {
+		t.Fatalf("ResolveSession after one 503: %v", err)
+	}
+	if session.UserID != "u1" {
+		t.Errorf("userId = %q, want u1", session.UserID)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestAPIProviderLogUsageRetriesWithBody(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 {
+			t.Errorf("attempt %d sent an empty body", atomic.LoadInt32(&calls)+1)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	err := newTestAPIProvider(t, server, "2").LogUsage(&UsageLogRequest{UserID: "u1", TotalTokens: 10, Status: "success"})
+	if err != nil {
+		t.Fatalf("LogUsage after one 502: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestAPIProviderLogUsageSendsIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	req := &UsageLogRequest{UserID: "u1", TotalTokens: 10, Status: "ok", DedupeKey: UsageDedupeKey(7, 2)}
+	if err := newTestAPIProvider(t, server, "2").LogUsage(req); err != nil {
+		t.Fatalf("LogUsage: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "job:7:2" || keys[1] != "job:7:2" {
+		t.Errorf("Idempotency-Key per attempt = %q, want job:7:2 on both", keys)
+	}
+}
+
+func TestAPIProviderGivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := newTestAPIProvider(t, server, "2").GetBotSettings("u1", "s1"); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3 (1 + 2 retries)", calls)
+	}
+}
+
+func TestAPIProviderDoesNotRetry4xx(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if _, err := newTestAPIProvider(t, server, "2").ResolveSession("missing"); err == nil {
+		t.Fatal("expected error for 404")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (4xx is not retried)", calls)
+	}
+}
+
+func TestAPIProviderFallsBackToPreviousKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("x-api-key"))
+		if r.Header.Get("x-api-key") != "old-key" {
+			w.WriteHeader(http.StatusUnauthorized) // API not rotated yet
+			return
+		}
+		io.WriteString(w, `{"success":true,"data":{"userId":"u1","botActive":true,"subscriptionActive":true,"sessionToken":"s1"}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("INTERNAL_API_KEY_PREVIOUS", "old-key")
+	session, err := newTestAPIProvider(t, server, "0").ResolveSession("s1")
+	if err != nil {
+		t.Fatalf("ResolveSession with previous key: %v", err)
+	}
+	if session.UserID != "u1" {
+		t.Errorf("userId = %q, want u1", session.UserID)
+	}
+	if len(keys) != 2 || keys[0] != "test-internal-key" || keys[1] != "old-key" {
+		t.Errorf("keys sent = %v, want [test-internal-key old-key]", keys)
+	}
+}
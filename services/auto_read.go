@@ -0,0 +1,14 @@
+package services
+
+// AutoReadContactMessages marks every unread incoming message from the contact as read, on the WA
+// Server (blue ticks for the sender) and in the DB. This also covers every message merged into a
+// debounced job. Bots with autoRead: false skip it entirely - the messages stay unread in the DB
+// too, so the read reconciler never sends the receipt later either.
+func AutoReadContactMessages(botSettings *BotSettings, sessionToken, senderPhone string) {
+	if !AutoReadEnabled(botSettings) {
+		return
+	}
+
+	// Coalesced with the gateway's auto-read for the same chat (see MarkChatRead)
+	MarkChatRead(sessionToken, senderPhone)
+}
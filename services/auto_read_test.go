@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestAutoReadContactMessagesRespectsBotFlag(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+
+	contact := "6281234567890@s.whatsapp.net"
+	msg := models.AIChatMessage{MessageID: sessionTok + "_in_1", SessionTok: sessionTok, From: contact,
+		To: "bot@s.whatsapp.net", MsgType: "text", Body: "halo", Timestamp: time.Now()}
+	if err := db.Create(&msg).Error; err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+
+	previous := markReadOnServer
+	t.Cleanup(func() { markReadOnServer = previous })
+	var calls [][]string
+	markReadOnServer = func(sessionToken string, messageIDs []string, chatPhone string) error {
+		calls = append(calls, messageIDs)
+		return nil
+	}
+
+	disabled := false
+	AutoReadContactMessages(&BotSettings{AutoRead: &disabled}, sessionTok, contact)
+	if len(calls) != 0 {
+		t.Fatalf("markread issued for a bot with autoRead: false: %v", calls)
+	}
+	var stored models.AIChatMessage
+	db.Where("message_id = ?", msg.MessageID).First(&stored)
+	if stored.IsRead {
+		t.Error("message marked read in the DB (the read reconciler would send the receipt later)")
+	}
+
+	// Unset flag keeps the old behavior
+	AutoReadContactMessages(&BotSettings{}, sessionTok, contact)
+	if len(calls) != 1 || len(calls[0]) != 1 || calls[0][0] != msg.MessageID {
+		t.Fatalf("markread calls = %v, want one for %s", calls, msg.MessageID)
+	}
+	db.Where("message_id = ?", msg.MessageID).First(&stored)
+	if !stored.IsRead || !stored.ReadConfirmed {
+		t.Errorf("message not marked read+confirmed: read=%v confirmed=%v", stored.IsRead, stored.ReadConfirmed)
+	}
+}
+
+func TestAutoReadEnabled(t *testing.T) {
+	off, on := false, true
+	if !AutoReadEnabled(nil) || !AutoReadEnabled(&BotSettings{}) || !AutoReadEnabled(&BotSettings{AutoRead: &on}) {
+		t.Error("auto-read should default to enabled")
+	}
+	if AutoReadEnabled(&BotSettings{AutoRead: &off}) {
+		t.Error("autoRead: false not respected")
+	}
+}
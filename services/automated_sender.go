@@ -0,0 +1,81 @@
+package services
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"genfity-wa-support/config"
+)
+
+// defaultAutomatedSenderPatterns match WhatsApp's own system/broadcast JIDs
+const defaultAutomatedSenderPatterns = `^0@;@broadcast$;@newsletter$`
+
+// defaultAutomatedMessagePatterns match OTP / verification-code notifications
+const defaultAutomatedMessagePatterns = `(?i)\b(kode otp|otp code|kode verifikasi|verification code|one[- ]time password)\b;` +
+	`(?i)jangan (berikan|bagikan|beritahukan) kode;` +
+	`(?i)do not share (this|your) code`
+
+// compiledPatterns caches compiled pattern lists by their raw env value
+var compiledPatterns = struct {
+	sync.Mutex
+	byRaw map[string][]*regexp.Regexp
+}{byRaw: make(map[string][]*regexp.Regexp)}
+
+// automatedPatterns returns the compiled patterns of an env var: semicolon-separated regular
+// expressions, empty = defaults, "off" = none. Invalid patterns are logged once and skipped.
+func automatedPatterns(key, defaults string) []*regexp.Regexp {
+	raw := config.GetEnvString(key, "")
+	if strings.EqualFold(raw, "off") {
+		return nil
+	}
+	if raw == "" {
+		raw = defaults
+	}
+
+	compiledPatterns.Lock()
+	defer compiledPatterns.Unlock()
+	if patterns, ok := compiledPatterns.byRaw[raw]; ok {
+		return patterns
+	}
+
+	var patterns []*regexp.Regexp
+	for _, expr := range strings.Split(raw, ";") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("⚠️  %s: invalid pattern %q ignored: %v", key, expr, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	compiledPatterns.byRaw[raw] = patterns
+	return patterns
+}
+
+// MatchAutomatedSender reports whether a message comes from an automated sender (WhatsApp system
+// messages, OTP / notification services) that the bot must not answer. The sender and chat JIDs
+// are matched against AI_AUTOMATED_SENDER_PATTERNS (a status update has a normal sender but chat
+// status@broadcast); body is matched against AI_AUTOMATED_MESSAGE_PATTERNS.
+// Returns the reason ("sender:<pattern>" / "message:<pattern>") for logging.
+func MatchAutomatedSender(senderJID, chatJID, body string) (string, bool) {
+	for _, re := range automatedPatterns("AI_AUTOMATED_SENDER_PATTERNS", defaultAutomatedSenderPatterns) {
+		for _, jid := range []string{senderJID, chatJID} {
+			if jid = strings.ToLower(strings.TrimSpace(jid)); jid != "" && re.MatchString(jid) {
+				return "sender:" + re.String(), true
+			}
+		}
+	}
+	if strings.TrimSpace(body) == "" {
+		return "", false
+	}
+	for _, re := range automatedPatterns("AI_AUTOMATED_MESSAGE_PATTERNS", defaultAutomatedMessagePatterns) {
+		if re.MatchString(body) {
+			return "message:" + re.String(), true
+		}
+	}
+	return "", false
+}
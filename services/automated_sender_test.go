@@ -0,0 +1,62 @@
+package services
+
+import "testing"
+
+func TestMatchAutomatedSenderDefaults(t *testing.T) {
+	t.Setenv("AI_AUTOMATED_SENDER_PATTERNS", "")
+	t.Setenv("AI_AUTOMATED_MESSAGE_PATTERNS", "")
+
+	automated := []struct{ sender, chat, body string }{
+		{"0@s.whatsapp.net", "0@s.whatsapp.net", "Your security code changed"},
+		{"6281200000001@s.whatsapp.net", "status@broadcast", "lihat status saya"},
+		{"120363000000000000@newsletter", "120363000000000000@newsletter", "Info terbaru"},
+		{"6281200000002@s.whatsapp.net", "", "Kode OTP Anda 123456. Jangan berikan kode ini kepada siapa pun."},
+		{"6281200000003@s.whatsapp.net", "", "123456 is your verification code"},
+		{"6281200000004@s.whatsapp.net", "", "Do not share this code with anyone"},
+	}
+	for _, msg := range automated {
+		if reason, ok := MatchAutomatedSender(msg.sender, msg.chat, msg.body); !ok {
+			t.Errorf("%s / %q should be filtered as automated", msg.sender, msg.body)
+		} else if reason == "" {
+			t.Errorf("%s / %q matched without a reason", msg.sender, msg.body)
+		}
+	}
+
+	human := []struct{ sender, body string }{
+		{"6281200000005@s.whatsapp.net", "Halo, mau tanya harga paket"},
+		{"6281200000006@s.whatsapp.net", "kode promo apa yang berlaku?"},
+		{"6281200000007@s.whatsapp.net", ""},
+	}
+	for _, msg := range human {
+		if reason, ok := MatchAutomatedSender(msg.sender, msg.sender, msg.body); ok {
+			t.Errorf("%s / %q wrongly filtered (%s)", msg.sender, msg.body, reason)
+		}
+	}
+}
+
+func TestMatchAutomatedSenderCustomPatterns(t *testing.T) {
+	t.Setenv("AI_AUTOMATED_SENDER_PATTERNS", `^62800;^1500\d+@`)
+	t.Setenv("AI_AUTOMATED_MESSAGE_PATTERNS", "off")
+
+	if _, ok := MatchAutomatedSender("6280012345@s.whatsapp.net", "", "Tagihan Anda"); !ok {
+		t.Error("custom sender prefix should be filtered")
+	}
+	if _, ok := MatchAutomatedSender("15001234@s.whatsapp.net", "", "Promo"); !ok {
+		t.Error("custom short-code sender should be filtered")
+	}
+	if _, ok := MatchAutomatedSender("0@s.whatsapp.net", "", "system"); ok {
+		t.Error("custom patterns replace the defaults")
+	}
+	if _, ok := MatchAutomatedSender("6281200000001@s.whatsapp.net", "", "Kode OTP Anda 123456"); ok {
+		t.Error("message patterns are off")
+	}
+}
+
+func TestMatchAutomatedSenderSkipsInvalidPattern(t *testing.T) {
+	t.Setenv("AI_AUTOMATED_SENDER_PATTERNS", `([;^0@`)
+	t.Setenv("AI_AUTOMATED_MESSAGE_PATTERNS", "off")
+
+	if _, ok := MatchAutomatedSender("0@s.whatsapp.net", "", "system"); !ok {
+		t.Error("valid pattern after an invalid one should still apply")
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// cachedBotSettings is the last successfully fetched settings of a bot
+type cachedBotSettings struct {
+	settings  *BotSettings
+	fetchedAt time.Time
+}
+
+// botSettingsCache keeps last-known-good settings per user+session
+var botSettingsCache = struct {
+	sync.RWMutex
+	entries map[string]cachedBotSettings
+}{entries: make(map[string]cachedBotSettings)}
+
+// botSettingsTimeout bounds a single GetBotSettings call (AI_BOT_SETTINGS_TIMEOUT_MS, default 5000)
+func botSettingsTimeout() time.Duration {
+	ms := config.GetEnvInt("AI_BOT_SETTINGS_TIMEOUT_MS", 5000)
+	if ms <= 0 {
+		ms = 5000
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// botSettingsMaxStale is how old a cached fallback may be (AI_BOT_SETTINGS_MAX_STALE_MINUTES, default 60)
+func botSettingsMaxStale() time.Duration {
+	minutes := config.GetEnvInt("AI_BOT_SETTINGS_MAX_STALE_MINUTES", 60)
+	if minutes <= 0 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetBotSettingsWithFallback fetches bot settings with a timeout. When the provider is slow or
+// failing, the last known good settings (if recent enough) are returned instead of an error.
+func GetBotSettingsWithFallback(provider DataProvider, userID, sessionToken string) (*BotSettings, error) {
+	key := userID + "|" + sessionToken
+
+	type fetchResult struct {
+		settings *BotSettings
+		err      error
+	}
+	done := make(chan fetchResult, 1) // buffered: a late result must not block the goroutine
+	go func() {
+		settings, err := provider.GetBotSettings(userID, sessionToken)
+		done <- fetchResult{settings, err}
+	}()
+
+	timeout := botSettingsTimeout()
+	var err error
+	select {
+	case res := <-done:
+		if res.err == nil {
+			botSettingsCache.Lock()
+			botSettingsCache.entries[key] = cachedBotSettings{settings: res.settings, fetchedAt: time.Now()}
+			botSettingsCache.Unlock()
+			return res.settings, nil
+		}
+		err = res.err
+	case <-time.After(timeout):
+		err = fmt.Errorf("bot settings request timed out after %v", timeout)
+	}
+
+	botSettingsCache.RLock()
+	cached, ok := botSettingsCache.entries[key]
+	botSettingsCache.RUnlock()
+
+	if ok && time.Since(cached.fetchedAt) <= botSettingsMaxStale() {
+		log.Printf("⚠️  GetBotSettings failed (%v) - using cached settings from %s ago",
+			err, time.Since(cached.fetchedAt).Round(time.Second))
+		return cached.settings, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowDataProvider returns settings after delay, or err when set
+type slowDataProvider struct {
+	settings *BotSettings
+	delay    time.Duration
+	err      error
+}
+
+func (p *slowDataProvider) ResolveSession(string) (*SessionInfo, error) { return nil, nil }
+func (p *slowDataProvider) LogUsage(*UsageLogRequest) error             { return nil }
+func (p *slowDataProvider) CheckHealth() error                          { return nil }
+
+func (p *slowDataProvider) GetBotSettings(userID, sessionToken string) (*BotSettings, error) {
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.settings, nil
+}
+
+func TestGetBotSettingsWithFallbackUsesCacheOnTimeout(t *testing.T) {
+	t.Setenv("AI_BOT_SETTINGS_TIMEOUT_MS", "50")
+	fresh := &BotSettings{SystemPrompt: "v1"}
+
+	// Successful fetch populates the cache
+	got, err := GetBotSettingsWithFallback(&slowDataProvider{settings: fresh}, "user-timeout", "tok")
+	if err != nil || got.SystemPrompt != "v1" {
+		t.Fatalf("first fetch = %+v, %v", got, err)
+	}
+
+	// Provider hangs past the timeout -> cached settings
+	start := time.Now()
+	got, err = GetBotSettingsWithFallback(&slowDataProvider{settings: &BotSettings{SystemPrompt: "v2"}, delay: time.Second}, "user-timeout", "tok")
+	if err != nil {
+		t.Fatalf("expected cached fallback, got error: %v", err)
+	}
+	if got.SystemPrompt != "v1" {
+		t.Errorf("fallback prompt = %q, want cached v1", got.SystemPrompt)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("fallback waited %v, expected to return right after the timeout", elapsed)
+	}
+}
+
+func TestGetBotSettingsWithFallbackUsesCacheOnError(t *testing.T) {
+	t.Setenv("AI_BOT_SETTINGS_TIMEOUT_MS", "")
+
+	if _, err := GetBotSettingsWithFallback(&slowDataProvider{settings: &BotSettings{SystemPrompt: "v1"}}, "user-error", "tok"); err != nil {
+		t.Fatalf("first fetch failed: %v", err)
+	}
+
+	got, err := GetBotSettingsWithFallback(&slowDataProvider{err: errors.New("API returned 502")}, "user-error", "tok")
+	if err != nil || got.SystemPrompt != "v1" {
+		t.Errorf("got %+v, %v - want cached v1", got, err)
+	}
+}
+
+func TestGetBotSettingsWithFallbackWithoutCacheFails(t *testing.T) {
+	t.Setenv("AI_BOT_SETTINGS_TIMEOUT_MS", "20")
+
+	_, err := GetBotSettingsWithFallback(&slowDataProvider{delay: 200 * time.Millisecond}, "user-uncached", "tok")
+	if err == nil {
+		t.Fatalf("expected timeout error when nothing is cached")
+	}
+}
+
+func TestGetBotSettingsWithFallbackIgnoresStaleCache(t *testing.T) {
+	t.Setenv("AI_BOT_SETTINGS_MAX_STALE_MINUTES", "1")
+	botSettingsCache.Lock()
+	botSettingsCache.entries["user-stale|tok"] = cachedBotSettings{
+		settings:  &BotSettings{SystemPrompt: "old"},
+		fetchedAt: time.Now().Add(-2 * time.Minute),
+	}
+	botSettingsCache.Unlock()
+
+	if _, err := GetBotSettingsWithFallback(&slowDataProvider{err: errors.New("down")}, "user-stale", "tok"); err == nil {
+		t.Errorf("expected error when the cached settings are older than the max stale age")
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/models"
+)
+
+// SimulationTurn holds one scripted user turn and the bot reply for it
+type SimulationTurn struct {
+	UserMessage  string `json:"user_message"`
+	Reply        string `json:"reply"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	LatencyMs    int64  `json:"latency_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// SimulationResult holds the outcome of a scripted conversation run
+type SimulationResult struct {
+	Provider          string           `json:"provider"`
+	Model             string           `json:"model"`
+	Turns             []SimulationTurn `json:"turns"`
+	TotalInputTokens  int              `json:"total_input_tokens"`
+	TotalOutputTokens int              `json:"total_output_tokens"`
+}
+
+// SimulateConversation runs scripted user turns through the real context builder and LLM
+// using an in-memory history. Nothing is sent to WhatsApp or written to the database.
+func SimulateConversation(aiProvider AIProvider, botSettings *BotSettings, turns []string, maxMessages int) *SimulationResult {
+	result := &SimulationResult{
+		Provider: aiProvider.GetProviderName(),
+		Model:    aiProvider.GetModelName(),
+		Turns:    make([]SimulationTurn, 0, len(turns)),
+	}
+
+	var history []models.AIChatMessage
+	for i, userMessage := range turns {
+		// Same order as the real pipeline: incoming message is saved before the job runs
+		history = append(history, models.AIChatMessage{
+			MessageID: fmt.Sprintf("sim_in_%d", i),
+			Body:      userMessage,
+			FromMe:    false,
+			Timestamp: time.Now(),
+		})
+
+		window := history
+		if len(window) > maxMessages {
+			window = window[len(window)-maxMessages:]
+		}
+		ctxData := AssembleContext(botSettings, window, userMessage)
+
+		start := time.Now()
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), AITimeout())
+		response, inTok, outTok, err := aiProvider.AskLLM(timeoutCtx, ctxData.SystemPrompt, ctxData.UserMessage)
+		cancel()
+
+		turn := SimulationTurn{
+			UserMessage:  userMessage,
+			InputTokens:  inTok,
+			OutputTokens: outTok,
+			LatencyMs:    time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			// Stop here - later turns would be built on a missing reply
+			log.Printf("⚠️  [Simulator] Turn %d failed: %v", i+1, err)
+			turn.Error = err.Error()
+			result.Turns = append(result.Turns, turn)
+			break
+		}
+
+		turn.Reply = FormatForWhatsApp(SanitizeResponse(response))
+		result.Turns = append(result.Turns, turn)
+		result.TotalInputTokens += inTok
+		result.TotalOutputTokens += outTok
+
+		history = append(history, models.AIChatMessage{
+			MessageID: fmt.Sprintf("sim_out_%d", i),
+			Body:      turn.Reply,
+			FromMe:    true,
+			IsRead:    true,
+			Timestamp: time.Now(),
+		})
+	}
+
+	return result
+}
+
+// PreviewResult holds a single dry-run reply with the assembled prompt
+type PreviewResult struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Reply        string `json:"reply"`
+	RawReply     string `json:"raw_reply"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	LatencyMs    int64  `json:"latency_ms"`
+	SystemPrompt string `json:"system_prompt"`
+	UserMessage  string `json:"user_message"`
+}
+
+// PreviewBotReply is a dry-run of processJob for one message: builds context and calls the LLM
+// without chat history, WhatsApp, or job creation.
+//
+// Unlike a live reply (BuildContextWithLimit), there is no stored conversation: the prompt only
+// contains the bot settings, knowledge base and this message. The message still goes through the
+// same user-message composition, so an optional quotedMessage previews reply-quote handling.
+func PreviewBotReply(aiProvider AIProvider, botSettings *BotSettings, message, quotedMessage string) (*PreviewResult, error) {
+	msg := models.AIChatMessage{
+		MessageID:  "preview",
+		MsgType:    "text",
+		Body:       message,
+		QuotedBody: quotedMessage,
+		Timestamp:  time.Now(),
+	}
+	ctxData := AssembleContext(botSettings, []models.AIChatMessage{msg}, composeUserMessage(msg))
+
+	start := time.Now()
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), AITimeout())
+	defer cancel()
+
+	response, inTok, outTok, err := aiProvider.AskLLM(timeoutCtx, ctxData.SystemPrompt, ctxData.UserMessage)
+	if err != nil {
+		return nil, fmt.Errorf("LLM call failed: %w", err)
+	}
+
+	return &PreviewResult{
+		Provider:     aiProvider.GetProviderName(),
+		Model:        aiProvider.GetModelName(),
+		Reply:        FormatForWhatsApp(SanitizeResponse(response)),
+		RawReply:     response,
+		InputTokens:  inTok,
+		OutputTokens: outTok,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		SystemPrompt: ctxData.SystemPrompt,
+		UserMessage:  ctxData.UserMessage,
+	}, nil
+}
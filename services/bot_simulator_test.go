@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeAIProvider records every prompt it receives and returns scripted replies
+type fakeAIProvider struct {
+	systemPrompts []string
+	userPrompts   []string
+	failOnCall    int // 1-based call number that returns an error (0 = never)
+}
+
+func (f *fakeAIProvider) AskLLM(ctx context.Context, systemPrompt string, userPrompt string) (string, int, int, error) {
+	f.systemPrompts = append(f.systemPrompts, systemPrompt)
+	f.userPrompts = append(f.userPrompts, userPrompt)
+	call := len(f.userPrompts)
+	if call == f.failOnCall {
+		return "", 0, 0, errors.New("provider unavailable")
+	}
+	return fmt.Sprintf("reply %d", call), 10 * call, call, nil
+}
+
+func (f *fakeAIProvider) GetProviderName() string { return "fake" }
+func (f *fakeAIProvider) GetModelName() string    { return "fake-model" }
+
+func TestSimulateConversationThreeTurns(t *testing.T) {
+	provider := &fakeAIProvider{}
+	settings := &BotSettings{SystemPrompt: "Test bot"}
+	turns := []string{"halo", "berapa harga paket?", "oke terima kasih"}
+
+	result := SimulateConversation(provider, settings, turns, 10)
+
+	if len(result.Turns) != 3 {
+		t.Fatalf("expected 3 turns, got %d", len(result.Turns))
+	}
+	for i, turn := range result.Turns {
+		if turn.Error != "" {
+			t.Fatalf("turn %d unexpected error: %s", i+1, turn.Error)
+		}
+		if want := fmt.Sprintf("reply %d", i+1); turn.Reply != want {
+			t.Errorf("turn %d reply = %q, want %q", i+1, turn.Reply, want)
+		}
+		if turn.UserMessage != turns[i] {
+			t.Errorf("turn %d user message = %q, want %q", i+1, turn.UserMessage, turns[i])
+		}
+	}
+
+	if result.TotalInputTokens != 10+20+30 {
+		t.Errorf("total input tokens = %d, want 60", result.TotalInputTokens)
+	}
+	if result.TotalOutputTokens != 1+2+3 {
+		t.Errorf("total output tokens = %d, want 6", result.TotalOutputTokens)
+	}
+	if result.Provider != "fake" || result.Model != "fake-model" {
+		t.Errorf("unexpected provider/model: %s/%s", result.Provider, result.Model)
+	}
+
+	// Turn 3 must see the earlier exchanges in its in-memory history
+	third := provider.systemPrompts[2]
+	for _, want := range []string{
+		"Customer: halo",
+		"Assistant: reply 1",
+		"Customer: berapa harga paket?",
+		"Assistant: reply 2",
+		"Customer: oke terima kasih",
+	} {
+		if !strings.Contains(third, want) {
+			t.Errorf("turn 3 system prompt missing %q", want)
+		}
+	}
+	if provider.userPrompts[2] != "oke terima kasih" {
+		t.Errorf("turn 3 user prompt = %q", provider.userPrompts[2])
+	}
+}
+
+func TestSimulateConversationStopsOnError(t *testing.T) {
+	provider := &fakeAIProvider{failOnCall: 2}
+	settings := &BotSettings{SystemPrompt: "Test bot"}
+
+	result := SimulateConversation(provider, settings, []string{"satu", "dua", "tiga"}, 10)
+
+	if len(result.Turns) != 2 {
+		t.Fatalf("expected run to stop after failing turn 2, got %d turns", len(result.Turns))
+	}
+	if result.Turns[1].Error == "" {
+		t.Errorf("expected error on turn 2")
+	}
+	if len(provider.userPrompts) != 2 {
+		t.Errorf("expected provider to be called twice, got %d", len(provider.userPrompts))
+	}
+	if result.TotalInputTokens != 10 || result.TotalOutputTokens != 1 {
+		t.Errorf("failed turn must not count tokens: in=%d out=%d", result.TotalInputTokens, result.TotalOutputTokens)
+	}
+}
+
+func TestPreviewBotReplyComposesQuotedMessage(t *testing.T) {
+	provider := &fakeAIProvider{}
+	settings := &BotSettings{SystemPrompt: "Test bot"}
+
+	result, err := PreviewBotReply(provider, settings, "yang ini berapa?", "Paket Business")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "(Customer membalas pesan: \"Paket Business\")\nyang ini berapa?"
+	if result.UserMessage != want || provider.userPrompts[0] != want {
+		t.Errorf("user message = %q, want %q", result.UserMessage, want)
+	}
+	if result.Reply != "reply 1" || result.InputTokens != 10 || result.OutputTokens != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
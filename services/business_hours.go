@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+const defaultAfterHoursMessage = "Terima kasih sudah menghubungi kami 🙏 Saat ini di luar jam operasional, pesan Anda akan kami balas pada jam kerja berikutnya."
+
+// BusinessHours is a bot's weekly schedule (WhatsAppAIBot.businessHours), e.g.
+// {"timezone":"Asia/Jakarta","hours":{"mon":"09:00-17:00","sat":"09:00-12:00,13:00-15:00"}}.
+// Days without an entry are closed; a range ending at or before its start runs past midnight
+// ("22:00-02:00"), and "24:00" means end of day.
+type BusinessHours struct {
+	Timezone string            `json:"timezone"` // IANA name, e.g. Asia/Jakarta
+	Hours    map[string]string `json:"hours"`    // mon..sun -> "HH:MM-HH:MM[,HH:MM-HH:MM...]"
+}
+
+// minuteRange is [start, end) in minutes since local midnight; end <= start crosses midnight
+type minuteRange struct {
+	start, end int
+}
+
+var businessDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseBusinessHours decodes and validates a businessHours JSON value
+func ParseBusinessHours(raw string) (*BusinessHours, error) {
+	var hours BusinessHours
+	if err := json.Unmarshal([]byte(raw), &hours); err != nil {
+		return nil, fmt.Errorf("invalid business hours JSON: %w", err)
+	}
+	if _, _, err := hours.compile(); err != nil {
+		return nil, err
+	}
+	return &hours, nil
+}
+
+// compile resolves the timezone and parses the ranges per weekday
+func (b *BusinessHours) compile() (*time.Location, map[time.Weekday][]minuteRange, error) {
+	if strings.TrimSpace(b.Timezone) == "" {
+		return nil, nil, fmt.Errorf("business hours need an IANA timezone")
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(b.Timezone))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid business hours timezone %q: %w", b.Timezone, err)
+	}
+
+	schedule := make(map[time.Weekday][]minuteRange)
+	for day, spec := range b.Hours {
+		weekday, ok := businessDays[TruncateRunes(strings.ToLower(strings.TrimSpace(day)), 3)] // "monday" -> "mon"
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid business hours day %q", day)
+		}
+		for _, part := range strings.Split(spec, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			r, err := parseMinuteRange(part)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid business hours for %s: %w", day, err)
+			}
+			schedule[weekday] = append(schedule[weekday], r)
+		}
+	}
+	return loc, schedule, nil
+}
+
+// parseMinuteRange parses "HH:MM-HH:MM"
+func parseMinuteRange(spec string) (minuteRange, error) {
+	bounds := strings.SplitN(strings.TrimSpace(spec), "-", 2)
+	if len(bounds) != 2 {
+		return minuteRange{}, fmt.Errorf("range %q is not HH:MM-HH:MM", spec)
+	}
+	start, err := parseClock(bounds[0])
+	if err != nil {
+		return minuteRange{}, err
+	}
+	end, err := parseClock(bounds[1])
+	if err != nil {
+		return minuteRange{}, err
+	}
+	if start == 24*60 {
+		return minuteRange{}, fmt.Errorf("range %q cannot start at 24:00", spec)
+	}
+	return minuteRange{start: start, end: end}, nil
+}
+
+// parseClock parses "HH:MM" (00:00-24:00) into minutes since midnight
+func parseClock(value string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d:%d", &h, &m); err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return h*60 + m, nil
+}
+
+// IsOpen reports whether t falls within the schedule, evaluated in the schedule's timezone.
+// An invalid schedule returns an error; callers treat that as open (never silence the bot by mistake).
+func (b *BusinessHours) IsOpen(t time.Time) (bool, error) {
+	loc, schedule, err := b.compile()
+	if err != nil {
+		return true, err
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	for _, r := range schedule[local.Weekday()] {
+		if r.end > r.start {
+			if minute >= r.start && minute < r.end {
+				return true, nil
+			}
+		} else if minute >= r.start { // overnight range, today's part
+			return true, nil
+		}
+	}
+	// Overnight ranges that started yesterday
+	for _, r := range schedule[(local.Weekday()+6)%7] {
+		if r.end <= r.start && minute < r.end {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AfterHoursMessage returns the bot's after-hours reply, or AI_AFTER_HOURS_MESSAGE / the built-in default
+func AfterHoursMessage(botSettings *BotSettings) string {
+	if botSettings != nil && strings.TrimSpace(botSettings.AfterHoursMessage) != "" {
+		return botSettings.AfterHoursMessage
+	}
+	if msg := config.GetEnvString("AI_AFTER_HOURS_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultAfterHoursMessage
+}
+
+// IsWithinBusinessHours reports whether the bot should answer at now (no schedule = always)
+func IsWithinBusinessHours(botSettings *BotSettings, now time.Time) bool {
+	if botSettings == nil || botSettings.BusinessHours == nil {
+		return true
+	}
+	open, err := botSettings.BusinessHours.IsOpen(now)
+	if err != nil {
+		log.Printf("⚠️  Ignoring invalid business hours: %v", err)
+	}
+	return open
+}
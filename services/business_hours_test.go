@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusinessHoursIsOpen(t *testing.T) {
+	hours, err := ParseBusinessHours(`{"timezone":"Asia/Jakarta","hours":{
+		"mon":"09:00-17:00","tuesday":"09:00-12:00, 13:00-17:00","fri":"22:00-02:00","sun":"00:00-24:00"}}`)
+	if err != nil {
+		t.Fatalf("ParseBusinessHours: %v", err)
+	}
+
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+	at := func(day, hour, minute int) time.Time {
+		// October 2026: the 5th is a Monday
+		return time.Date(2026, 10, day, hour, minute, 0, 0, jakarta)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"monday opening minute", at(5, 9, 0), true},
+		{"monday just before opening", at(5, 8, 59), false},
+		{"monday last open minute", at(5, 16, 59), true},
+		{"monday closing time is closed", at(5, 17, 0), false},
+		{"tuesday lunch break", at(6, 12, 30), false},
+		{"tuesday after lunch", at(6, 13, 0), true},
+		{"wednesday has no hours", at(7, 10, 0), false},
+		{"friday overnight start", at(9, 22, 0), true},
+		{"friday before overnight start", at(9, 21, 59), false},
+		{"saturday early, friday's overnight range", at(10, 1, 59), true},
+		{"saturday overnight range ended", at(10, 2, 0), false},
+		{"sunday 24:00 end covers late night", at(11, 23, 59), true},
+		// Same instants expressed in UTC: 02:00 UTC is 09:00 WIB
+		{"utc input converted to schedule timezone", time.Date(2026, 10, 5, 2, 0, 0, 0, time.UTC), true},
+		{"utc input just before opening", time.Date(2026, 10, 5, 1, 59, 0, 0, time.UTC), false},
+		// 23:30 UTC on Sunday is already Monday 06:30 in Jakarta
+		{"utc date differs from local date", time.Date(2026, 10, 4, 23, 30, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hours.IsOpen(tt.t)
+			if err != nil {
+				t.Fatalf("IsOpen: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsOpen(%s) = %v, want %v", tt.t.In(jakarta).Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBusinessHoursRejectsInvalid(t *testing.T) {
+	for name, raw := range map[string]string{
+		"no timezone":       `{"hours":{"mon":"09:00-17:00"}}`,
+		"unknown timezone":  `{"timezone":"Mars/Olympus","hours":{"mon":"09:00-17:00"}}`,
+		"unknown day":       `{"timezone":"UTC","hours":{"funday":"09:00-17:00"}}`,
+		"bad range":         `{"timezone":"UTC","hours":{"mon":"9-5"}}`,
+		"hour out of range": `{"timezone":"UTC","hours":{"mon":"09:00-25:00"}}`,
+		"not json":          `jam kerja`,
+	} {
+		if _, err := ParseBusinessHours(raw); err == nil {
+			t.Errorf("%s: expected an error for %s", name, raw)
+		}
+	}
+}
+
+func TestIsWithinBusinessHours(t *testing.T) {
+	now := time.Date(2026, 10, 7, 3, 0, 0, 0, time.UTC) // Wednesday
+	if !IsWithinBusinessHours(nil, now) || !IsWithinBusinessHours(&BotSettings{}, now) {
+		t.Errorf("bots without a schedule must always answer")
+	}
+
+	closed := &BotSettings{BusinessHours: &BusinessHours{Timezone: "UTC", Hours: map[string]string{"mon": "09:00-17:00"}}}
+	if IsWithinBusinessHours(closed, now) {
+		t.Errorf("wednesday should be outside monday-only hours")
+	}
+
+	// A broken schedule never silences the bot
+	broken := &BotSettings{BusinessHours: &BusinessHours{Timezone: "Nowhere/City"}}
+	if !IsWithinBusinessHours(broken, now) {
+		t.Errorf("invalid schedule should fail open")
+	}
+}
+
+func TestAfterHoursMessage(t *testing.T) {
+	t.Setenv("AI_AFTER_HOURS_MESSAGE", "")
+	if got := AfterHoursMessage(nil); got != defaultAfterHoursMessage {
+		t.Errorf("default = %q", got)
+	}
+	t.Setenv("AI_AFTER_HOURS_MESSAGE", "Kami buka jam 9 pagi")
+	if got := AfterHoursMessage(&BotSettings{}); got != "Kami buka jam 9 pagi" {
+		t.Errorf("env message = %q", got)
+	}
+	if got := AfterHoursMessage(&BotSettings{AfterHoursMessage: "Tutup dulu ya"}); got != "Tutup dulu ya" {
+		t.Errorf("per-bot message = %q", got)
+	}
+}
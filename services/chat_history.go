@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"genfity-wa-support/config"
 	"genfity-wa-support/database"
 	"genfity-wa-support/models"
 
@@ -16,6 +17,17 @@ const MaxMessagesPerContact = 20
 // SaveToChatHistory saves incoming/outgoing messages to permanent chat history
 // This is separate from ai_chat_messages which is temporary for AI context
 func SaveToChatHistory(sessionToken, senderJID, recipientJID, body, pushName string, timestamp time.Time, fromMe bool) error {
+	_, err := saveChatHistoryMessage(sessionToken, senderJID, recipientJID, body, pushName, "text", timestamp, fromMe)
+	return err
+}
+
+// SaveMediaToChatHistory saves an incoming media message (msgType image, document, ...) to the
+// permanent chat history and returns the stored row, so the archived file can be linked to it
+func SaveMediaToChatHistory(sessionToken, senderJID, recipientJID, body, pushName, msgType string, timestamp time.Time) (*models.ChatMessage, error) {
+	return saveChatHistoryMessage(sessionToken, senderJID, recipientJID, body, pushName, msgType, timestamp, false)
+}
+
+func saveChatHistoryMessage(sessionToken, senderJID, recipientJID, body, pushName, msgType string, timestamp time.Time, fromMe bool) (*models.ChatMessage, error) {
 	db := database.GetDB()
 
 	// Determine chat participants
@@ -29,6 +41,12 @@ func SaveToChatHistory(sessionToken, senderJID, recipientJID, body, pushName str
 	// Create unique chat ID (session + contact)
 	chatID := fmt.Sprintf("%s_%s", sessionToken, contactJID)
 
+	// Serialize saves for the same chat (reply bursts) so find-or-create doesn't race itself
+	if chatHistoryLockEnabled() {
+		unlock := chatHistoryLocks.lock(chatID)
+		defer unlock()
+	}
+
 	// 1. Find or create ChatRoom
 	var chatRoom models.ChatRoom
 	err := db.Where("chat_id = ?", chatID).First(&chatRoom).Error
@@ -52,13 +70,13 @@ func SaveToChatHistory(sessionToken, senderJID, recipientJID, body, pushName str
 
 		if err := db.Create(&chatRoom).Error; err != nil {
 			log.Printf("❌ Failed to create chat room: %v", err)
-			return fmt.Errorf("failed to create chat room: %w", err)
+			return nil, fmt.Errorf("failed to create chat room: %w", err)
 		}
 
 		log.Printf("✅ Created new chat room: %s (contact: %s)", chatID, contactJID)
 	} else if err != nil {
 		log.Printf("❌ Failed to find chat room: %v", err)
-		return fmt.Errorf("failed to find chat room: %w", err)
+		return nil, fmt.Errorf("failed to find chat room: %w", err)
 	} else {
 		// Update existing chat room
 		updates := map[string]interface{}{
@@ -73,9 +91,20 @@ func SaveToChatHistory(sessionToken, senderJID, recipientJID, body, pushName str
 			updates["unread_count"] = gorm.Expr("unread_count + ?", 1)
 		}
 
+		// A new incoming message brings an archived chat back to the room list, any message revives a deleted one
+		if chatRoom.IsDeleted {
+			updates["is_deleted"] = false
+			updates["deleted_at"] = nil
+			updates["unread_count"] = getUnreadIncrement(fromMe)
+		}
+		if chatRoom.IsArchived && !fromMe {
+			updates["is_archived"] = false
+			updates["archived_at"] = nil
+		}
+
 		if err := db.Model(&chatRoom).Updates(updates).Error; err != nil {
 			log.Printf("❌ Failed to update chat room: %v", err)
-			return fmt.Errorf("failed to update chat room: %w", err)
+			return nil, fmt.Errorf("failed to update chat room: %w", err)
 		}
 
 		log.Printf("✅ Updated chat room: %s (last_message: %.30s...)", chatID, body)
@@ -92,7 +121,7 @@ func SaveToChatHistory(sessionToken, senderJID, recipientJID, body, pushName str
 		UserToken:        sessionToken,
 		SenderJID:        senderJID,
 		SenderType:       getSenderType(fromMe),
-		MessageType:      "text",
+		MessageType:      msgType,
 		Content:          body,
 		Status:           "sent",
 		MessageTimestamp: timestamp,
@@ -102,26 +131,29 @@ func SaveToChatHistory(sessionToken, senderJID, recipientJID, body, pushName str
 
 	if err := db.Create(&chatMessage).Error; err != nil {
 		log.Printf("❌ Failed to save chat message: %v", err)
-		return fmt.Errorf("failed to save chat message: %w", err)
+		return nil, fmt.Errorf("failed to save chat message: %w", err)
 	}
 
 	log.Printf("✅ Saved chat message to history: ID=%s, sender=%s, type=%s",
 		messageID, getSenderType(fromMe), chatMessage.MessageType)
 
-	return nil
+	return &chatMessage, nil
 }
 
-// SaveAIResponseToHistory saves AI bot response to permanent chat history
+// SaveAIResponseToHistory saves AI bot response to permanent chat history (failed writes are
+// retried in the background, see SaveToChatHistoryWithRetry)
 func SaveAIResponseToHistory(sessionToken, recipientJID, response string) error {
 	// AI bot sends message, so fromMe = true
-	return SaveToChatHistory(
+	return SaveToChatHistoryWithRetry(
 		sessionToken,
 		sessionToken, // senderJID = session (bot)
 		recipientJID, // recipientJID = contact
 		response,     // body
 		"AI Bot",     // pushName
+		"text",       // msgType
 		time.Now(),   // timestamp
 		true,         // fromMe = true (bot is sending)
+		nil,
 	)
 }
 
@@ -145,27 +177,55 @@ func getUnreadIncrement(fromMe bool) int {
 
 // SaveIncomingMessageToAIChat menyimpan pesan masuk ke ai_chat_messages dengan auto-cleanup
 func SaveIncomingMessageToAIChat(sessionTok, messageID, from, to, body, pushName string, timestamp time.Time) error {
-	db := database.GetDB()
-
-	msg := models.AIChatMessage{
+	return SaveIncomingAIChatMessage(&models.AIChatMessage{
 		MessageID:  messageID,
 		SessionTok: sessionTok,
 		From:       from,
 		To:         to,
-		FromMe:     false,
 		MsgType:    "text",
 		Body:       body,
 		PushName:   pushName,
-		IsRead:     false,
 		Timestamp:  timestamp,
+	})
+}
+
+// SaveIncomingAIChatMessage menyimpan pesan masuk (text, reaction, reply) ke ai_chat_messages dengan auto-cleanup
+func SaveIncomingAIChatMessage(msg *models.AIChatMessage) error {
+	db := database.GetDB()
+
+	msg.FromMe = false
+	msg.IsRead = false
+	if msg.MsgType == "" {
+		msg.MsgType = "text"
 	}
 
-	if err := db.Create(&msg).Error; err != nil {
+	if err := db.Create(msg).Error; err != nil {
 		return fmt.Errorf("failed to save incoming message: %w", err)
 	}
 
 	// Cleanup: hapus pesan lama, keep only last 20 per SessionTok+From
-	return CleanupOldAIChatMessages(sessionTok, from)
+	return CleanupOldAIChatMessages(msg.SessionTok, msg.From)
+}
+
+// ShouldReplyToReactions reports whether emoji-only reactions trigger an AI reply (AI_REPLY_TO_REACTIONS, default false)
+func ShouldReplyToReactions() bool {
+	return config.GetEnvBool("AI_REPLY_TO_REACTIONS", false)
+}
+
+// GetAIChatMessageBody returns the body of a stored AI chat message ("" if not found)
+func GetAIChatMessageBody(sessionTok, messageID string) string {
+	if messageID == "" {
+		return ""
+	}
+
+	var msg models.AIChatMessage
+	err := database.GetDB().
+		Where("session_tok = ? AND message_id = ?", sessionTok, messageID).
+		First(&msg).Error
+	if err != nil {
+		return ""
+	}
+	return msg.Body
 }
 
 // SaveOutgoingMessageToAIChat menyimpan pesan keluar ke ai_chat_messages dengan auto-cleanup
@@ -253,8 +313,9 @@ func GetUnreadIncomingMessages(sessionTok, fromPhone string) ([]models.AIChatMes
 	return messages, nil
 }
 
-// MarkMessagesAsReadInDB update IsRead = true untuk message IDs tertentu
-func MarkMessagesAsReadInDB(messageIDs []string) error {
+// MarkMessagesAsReadInDB update IsRead = true untuk message IDs tertentu.
+// confirmed = WA Server menerima markread; kalau false, read reconciler mencoba ulang.
+func MarkMessagesAsReadInDB(messageIDs []string, confirmed bool) error {
 	if len(messageIDs) == 0 {
 		return nil
 	}
@@ -262,7 +323,7 @@ func MarkMessagesAsReadInDB(messageIDs []string) error {
 	db := database.GetDB()
 	err := db.Model(&models.AIChatMessage{}).
 		Where("message_id IN ?", messageIDs).
-		Update("is_read", true).Error
+		Updates(map[string]interface{}{"is_read": true, "read_confirmed": confirmed}).Error
 
 	if err != nil {
 		return fmt.Errorf("failed to mark messages as read in DB: %w", err)
@@ -272,6 +333,81 @@ func MarkMessagesAsReadInDB(messageIDs []string) error {
 	return nil
 }
 
+// ApplyOutgoingEdit update isi pesan yang sudah dikirim lalu di-edit (by WA message ID) di
+// ai_chat_messages dan chat_messages, supaya AI context & UI menampilkan versi terbaru
+func ApplyOutgoingEdit(sessionTok, messageID, body string) error {
+	if messageID == "" || body == "" {
+		return fmt.Errorf("edit needs a message ID and text")
+	}
+
+	db := database.GetDB()
+	aiResult := db.Model(&models.AIChatMessage{}).
+		Where("session_tok = ? AND message_id = ?", sessionTok, messageID).
+		Updates(map[string]interface{}{"body": body, "updated_at": time.Now()})
+	if aiResult.Error != nil {
+		return fmt.Errorf("failed to update edited AI chat message: %w", aiResult.Error)
+	}
+	historyResult := db.Model(&models.ChatMessage{}).
+		Where("user_token = ? AND message_id = ?", sessionTok, messageID).
+		Updates(map[string]interface{}{"content": body, "updated_at": time.Now()})
+	if historyResult.Error != nil {
+		return fmt.Errorf("failed to update edited chat message: %w", historyResult.Error)
+	}
+
+	if aiResult.RowsAffected+historyResult.RowsAffected == 0 {
+		log.Printf("⚠️  Edited message %s not found in history (sent before its ID was tracked?)", messageID)
+	} else {
+		log.Printf("✏️  Edited message %s updated in history", messageID)
+	}
+	return nil
+}
+
+// ApplyOutgoingRevoke: pesan yang ditarik (revoke) dihapus dari AI context - bot tidak boleh
+// merujuk pesan yang sudah dihapus - dan ditandai "revoked" di chat_messages (UI)
+func ApplyOutgoingRevoke(sessionTok, messageID string) error {
+	if messageID == "" {
+		return fmt.Errorf("revoke needs a message ID")
+	}
+
+	db := database.GetDB()
+	if err := db.Where("session_tok = ? AND message_id = ?", sessionTok, messageID).
+		Delete(&models.AIChatMessage{}).Error; err != nil {
+		return fmt.Errorf("failed to remove revoked AI chat message: %w", err)
+	}
+	if err := db.Model(&models.ChatMessage{}).
+		Where("user_token = ? AND message_id = ?", sessionTok, messageID).
+		Updates(map[string]interface{}{"status": "revoked", "updated_at": time.Now()}).Error; err != nil {
+		return fmt.Errorf("failed to mark chat message revoked: %w", err)
+	}
+
+	log.Printf("🗑️  Revoked message %s removed from AI context", messageID)
+	return nil
+}
+
+// DeleteAIChatMessage hapus satu pesan dari ai_chat_messages (dipakai saat replay webhook)
+func DeleteAIChatMessage(messageID string) error {
+	if err := database.GetDB().Where("message_id = ?", messageID).Delete(&models.AIChatMessage{}).Error; err != nil {
+		return fmt.Errorf("failed to delete AI chat message: %w", err)
+	}
+	return nil
+}
+
+// ClearAIChatContext hapus semua ai_chat_messages untuk contact tertentu ("forget this conversation").
+// Permanent chat history (chat_messages) tidak ikut dihapus.
+func ClearAIChatContext(sessionTok, contactPhone string) (int64, error) {
+	db := database.GetDB()
+
+	result := db.
+		Where("session_tok = ? AND (\"from\" = ? OR \"to\" = ?)", sessionTok, contactPhone, contactPhone).
+		Delete(&models.AIChatMessage{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to clear AI chat context: %w", result.Error)
+	}
+
+	log.Printf("🧹 Cleared %d AI context messages for session %s, contact %s", result.RowsAffected, sessionTok, contactPhone)
+	return result.RowsAffected, nil
+}
+
 // GetChatHistoryForAI ambil riwayat chat untuk AI context (compatibility dengan AI worker)
 // Fungsi ini tetap digunakan oleh AI worker untuk build context
 func GetChatHistoryForAI(sessionTok, contactPhone string, limit int) ([]models.AIChatMessage, error) {
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/models"
+)
+
+// Defaults for retrying failed permanent chat history writes (override via .env)
+const (
+	defaultHistoryRetryAttempts  = 5
+	defaultHistoryRetryBackoffMs = 2000
+	defaultHistoryRetryQueueSize = 1000
+	maxHistoryRetryBackoff       = 5 * time.Minute
+)
+
+// saveChatHistoryFn writes one history message (a var so tests can simulate DB failures)
+var saveChatHistoryFn = saveChatHistoryMessage
+
+// historyRetryPending counts writes waiting for a retry, bounded by AI_HISTORY_RETRY_QUEUE_SIZE
+var historyRetryPending int64
+
+// chatHistoryWrite is one permanent history write, kept until it succeeds or runs out of retries
+type chatHistoryWrite struct {
+	sessionToken, senderJID, recipientJID string
+	body, pushName, msgType               string
+	timestamp                             time.Time
+	fromMe                                bool
+	onSaved                               func(*models.ChatMessage)
+}
+
+func (w chatHistoryWrite) save() error {
+	chatMessage, err := saveChatHistoryFn(w.sessionToken, w.senderJID, w.recipientJID, w.body, w.pushName, w.msgType, w.timestamp, w.fromMe)
+	if err != nil {
+		return err
+	}
+	if w.onSaved != nil {
+		w.onSaved(chatMessage)
+	}
+	return nil
+}
+
+// historyRetryAttempts returns AI_HISTORY_RETRY_ATTEMPTS (0 = failed writes are dropped as before)
+func historyRetryAttempts() int {
+	attempts := config.GetEnvInt("AI_HISTORY_RETRY_ATTEMPTS", defaultHistoryRetryAttempts)
+	if attempts < 0 {
+		return 0
+	}
+	return attempts
+}
+
+// historyRetryBackoff returns the wait before retry n (1-based): AI_HISTORY_RETRY_BACKOFF_MS, doubled
+// per retry and capped at 5 minutes
+func historyRetryBackoff(retry int) time.Duration {
+	base := config.GetEnvInt("AI_HISTORY_RETRY_BACKOFF_MS", defaultHistoryRetryBackoffMs)
+	if base <= 0 {
+		base = defaultHistoryRetryBackoffMs
+	}
+	delay := time.Duration(base) * time.Millisecond
+	for i := 1; i < retry && delay < maxHistoryRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxHistoryRetryBackoff {
+		return maxHistoryRetryBackoff
+	}
+	return delay
+}
+
+// historyRetryQueueSize returns AI_HISTORY_RETRY_QUEUE_SIZE (max writes waiting for a retry)
+func historyRetryQueueSize() int64 {
+	size := config.GetEnvInt("AI_HISTORY_RETRY_QUEUE_SIZE", defaultHistoryRetryQueueSize)
+	if size <= 0 {
+		return defaultHistoryRetryQueueSize
+	}
+	return int64(size)
+}
+
+// SaveToChatHistoryWithRetry saves a message to the permanent chat history like SaveToChatHistory
+// (msgType "text") / SaveMediaToChatHistory. A failed write is retried in the background with
+// backoff instead of being lost to a DB hiccup; onSaved (optional) runs once a write succeeds.
+// Returns an error only when the message is given up: retries disabled or the retry queue full.
+// Pending retries are in memory - a restart drops them.
+func SaveToChatHistoryWithRetry(sessionToken, senderJID, recipientJID, body, pushName, msgType string, timestamp time.Time, fromMe bool, onSaved func(*models.ChatMessage)) error {
+	write := chatHistoryWrite{
+		sessionToken: sessionToken,
+		senderJID:    senderJID,
+		recipientJID: recipientJID,
+		body:         body,
+		pushName:     pushName,
+		msgType:      msgType,
+		timestamp:    timestamp,
+		fromMe:       fromMe,
+		onSaved:      onSaved,
+	}
+	if err := write.save(); err != nil {
+		return scheduleHistoryRetry(write, 1, err)
+	}
+	return nil
+}
+
+// scheduleHistoryRetry queues retry n of a failed write, or returns why the write is given up
+func scheduleHistoryRetry(write chatHistoryWrite, retry int, lastErr error) error {
+	maxRetries := historyRetryAttempts()
+	if retry > maxRetries {
+		return fmt.Errorf("chat history write given up after %d retries: %w", maxRetries, lastErr)
+	}
+	if atomic.AddInt64(&historyRetryPending, 1) > historyRetryQueueSize() {
+		atomic.AddInt64(&historyRetryPending, -1)
+		return fmt.Errorf("chat history retry queue full, message dropped: %w", lastErr)
+	}
+
+	delay := historyRetryBackoff(retry)
+	log.Printf("🔁 Chat history write failed (%v) - retry %d/%d in %s", lastErr, retry, maxRetries, delay)
+	time.AfterFunc(delay, func() {
+		atomic.AddInt64(&historyRetryPending, -1)
+		err := write.save()
+		if err == nil {
+			log.Printf("✅ Chat history write succeeded on retry %d", retry)
+			return
+		}
+		if err := scheduleHistoryRetry(write, retry+1, err); err != nil {
+			log.Printf("❌ Lost chat history message for %s: %v", write.sessionToken, err)
+		}
+	})
+	return nil
+}
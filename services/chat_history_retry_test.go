@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"genfity-wa-support/models"
+)
+
+// stubChatHistorySave makes the first failures writes fail, then succeed; returns the call counter
+func stubChatHistorySave(t *testing.T, failures int32) *int32 {
+	t.Helper()
+	var calls int32
+	original := saveChatHistoryFn
+	saveChatHistoryFn = func(sessionToken, senderJID, recipientJID, body, pushName, msgType string, timestamp time.Time, fromMe bool) (*models.ChatMessage, error) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			return nil, errors.New("connection reset")
+		}
+		return &models.ChatMessage{Content: body, MessageType: msgType}, nil
+	}
+	t.Cleanup(func() { saveChatHistoryFn = original })
+	return &calls
+}
+
+func TestSaveToChatHistoryWithRetryRecovers(t *testing.T) {
+	t.Setenv("AI_HISTORY_RETRY_ATTEMPTS", "3")
+	t.Setenv("AI_HISTORY_RETRY_BACKOFF_MS", "1")
+	calls := stubChatHistorySave(t, 2)
+
+	saved := make(chan *models.ChatMessage, 1)
+	err := SaveToChatHistoryWithRetry("tok", "628111@s.whatsapp.net", "bot@s.whatsapp.net", "halo", "Budi", "image",
+		time.Now(), false, func(m *models.ChatMessage) { saved <- m })
+	if err != nil {
+		t.Fatalf("queued write returned %v", err)
+	}
+
+	select {
+	case m := <-saved:
+		if m.Content != "halo" || m.MessageType != "image" {
+			t.Errorf("saved %q (%s)", m.Content, m.MessageType)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("write was not retried")
+	}
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Errorf("save called %d times, want 3 (1 + 2 retries)", got)
+	}
+}
+
+func TestSaveToChatHistoryWithRetryGivesUp(t *testing.T) {
+	// Retries disabled: the first failure is reported and not retried
+	t.Setenv("AI_HISTORY_RETRY_ATTEMPTS", "0")
+	calls := stubChatHistorySave(t, 100)
+	if err := SaveAIResponseToHistory("tok", "628111@s.whatsapp.net", "halo"); err == nil {
+		t.Error("failed write with retries disabled returned nil")
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("save called %d times, want 1", got)
+	}
+
+	// Queue full: writes beyond AI_HISTORY_RETRY_QUEUE_SIZE are dropped with an error
+	t.Setenv("AI_HISTORY_RETRY_ATTEMPTS", "1")
+	t.Setenv("AI_HISTORY_RETRY_BACKOFF_MS", "200")
+	t.Setenv("AI_HISTORY_RETRY_QUEUE_SIZE", "1")
+	var wg sync.WaitGroup
+	var dropped int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := SaveAIResponseToHistory("tok", "628111@s.whatsapp.net", "halo"); err != nil {
+				atomic.AddInt32(&dropped, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if dropped != 2 {
+		t.Errorf("%d writes dropped, want 2 (queue size 1)", dropped)
+	}
+	time.Sleep(300 * time.Millisecond) // let the queued retry run before the stub is restored
+}
+
+func TestHistoryRetryBackoff(t *testing.T) {
+	t.Setenv("AI_HISTORY_RETRY_BACKOFF_MS", "1000")
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: maxHistoryRetryBackoff} {
+		if got := historyRetryBackoff(retry); got != want {
+			t.Errorf("retry %d: backoff %s, want %s", retry, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestClearAIChatContext(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	contact := "6281200000001@s.whatsapp.net"
+	other := "6281200000002@s.whatsapp.net"
+
+	seed := []struct{ id, from, to string }{
+		{"ctx_in_1", contact, "bot@s.whatsapp.net"},
+		{"ctx_out_1", "bot@s.whatsapp.net", contact},
+		{"ctx_other_1", other, "bot@s.whatsapp.net"},
+	}
+	for _, m := range seed {
+		if err := SaveOutgoingMessageToAIChat(sessionTok, m.id, m.from, m.to, "halo", time.Now()); err != nil {
+			t.Fatalf("failed to seed message %s: %v", m.id, err)
+		}
+	}
+
+	history, err := GetChatHistoryForAI(sessionTok, contact, 10)
+	if err != nil {
+		t.Fatalf("GetChatHistoryForAI: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 context messages before clearing, got %d", len(history))
+	}
+
+	deleted, err := ClearAIChatContext(sessionTok, contact)
+	if err != nil {
+		t.Fatalf("ClearAIChatContext: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted = %d, want 2", deleted)
+	}
+
+	history, _ = GetChatHistoryForAI(sessionTok, contact, 10)
+	if len(history) != 0 {
+		t.Errorf("expected empty context after clearing, got %d messages", len(history))
+	}
+	otherHistory, _ := GetChatHistoryForAI(sessionTok, other, 10)
+	if len(otherHistory) != 1 {
+		t.Errorf("other contact's context must be kept, got %d messages", len(otherHistory))
+	}
+}
+
+func TestSaveToChatHistoryConcurrentSameChat(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ChatRoom{}, &models.ChatMessage{}); err != nil {
+		t.Fatalf("failed to migrate chat history tables: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+
+	contact := "6281200000003@s.whatsapp.net"
+	const saves = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, saves)
+	for i := 0; i < saves; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- SaveToChatHistory(sessionTok, contact, "bot@s.whatsapp.net", fmt.Sprintf("pesan %d", i), "Budi", time.Now(), false)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent save failed: %v", err)
+		}
+	}
+
+	var rooms, messages int64
+	db.Model(&models.ChatRoom{}).Where("user_token = ?", sessionTok).Count(&rooms)
+	db.Model(&models.ChatMessage{}).Where("user_token = ?", sessionTok).Count(&messages)
+	if rooms != 1 {
+		t.Errorf("chat rooms = %d, want 1", rooms)
+	}
+	if messages != saves {
+		t.Errorf("chat messages = %d, want %d", messages, saves)
+	}
+}
+
+func TestApplyOutgoingEditAndRevoke(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	if err := database.GetDB().AutoMigrate(&models.ChatRoom{}, &models.ChatMessage{}); err != nil {
+		t.Fatalf("failed to migrate chat history tables: %v", err)
+	}
+	contact := "6281200000001@s.whatsapp.net"
+
+	for _, id := range []string{"wa_sent_1", "wa_sent_2"} {
+		if err := SaveOutgoingMessageToAIChat(sessionTok, sessionTok+id, "bot@s.whatsapp.net", contact, "Harga Rp100.000", time.Now()); err != nil {
+			t.Fatalf("failed to seed message %s: %v", id, err)
+		}
+	}
+
+	if err := ApplyOutgoingEdit(sessionTok, sessionTok+"wa_sent_1", "Harga Rp150.000"); err != nil {
+		t.Fatalf("ApplyOutgoingEdit: %v", err)
+	}
+	if got := GetAIChatMessageBody(sessionTok, sessionTok+"wa_sent_1"); got != "Harga Rp150.000" {
+		t.Errorf("edited body = %q, want the new text", got)
+	}
+
+	if err := ApplyOutgoingRevoke(sessionTok, sessionTok+"wa_sent_2"); err != nil {
+		t.Fatalf("ApplyOutgoingRevoke: %v", err)
+	}
+	history, err := GetChatHistoryForAI(sessionTok, contact, 10)
+	if err != nil {
+		t.Fatalf("GetChatHistoryForAI: %v", err)
+	}
+	if len(history) != 1 || history[0].MessageID != sessionTok+"wa_sent_1" {
+		t.Errorf("AI context after revoke = %+v, want only the edited message", history)
+	}
+}
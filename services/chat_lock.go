@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sync"
+
+	"genfity-wa-support/config"
+)
+
+// chatLockEntry is a per-chat mutex plus the number of goroutines holding/waiting on it
+type chatLockEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// chatLocks serializes work on the same chat ID inside this process.
+// Entries are removed when the last holder unlocks, so the map only holds active chats.
+type chatLocks struct {
+	mu    sync.Mutex
+	locks map[string]*chatLockEntry
+}
+
+var chatHistoryLocks = &chatLocks{locks: make(map[string]*chatLockEntry)}
+
+// lock blocks until chatID is free and returns the matching unlock func
+func (l *chatLocks) lock(chatID string) func() {
+	l.mu.Lock()
+	entry, ok := l.locks[chatID]
+	if !ok {
+		entry = &chatLockEntry{}
+		l.locks[chatID] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+
+		l.mu.Lock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(l.locks, chatID)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// size returns the number of chats currently locked or waited on
+func (l *chatLocks) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.locks)
+}
+
+// chatHistoryLockEnabled - AI_CHAT_HISTORY_LOCK=false turns off the in-process per-chat guard
+func chatHistoryLockEnabled() bool {
+	return config.GetEnvBool("AI_CHAT_HISTORY_LOCK", true)
+}
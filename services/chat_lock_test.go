@@ -0,0 +1,61 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChatLocksSerializeSameChat(t *testing.T) {
+	locks := &chatLocks{locks: make(map[string]*chatLockEntry)}
+
+	var active, maxActive int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.lock("session_chat")
+			defer unlock()
+
+			n := atomic.AddInt32(&active, 1)
+			for {
+				m := atomic.LoadInt32(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxActive != 1 {
+		t.Errorf("max concurrent holders = %d, want 1", maxActive)
+	}
+	if n := locks.size(); n != 0 {
+		t.Errorf("lock map not cleaned up: %d entries", n)
+	}
+}
+
+func TestChatLocksDifferentChatsDoNotBlock(t *testing.T) {
+	locks := &chatLocks{locks: make(map[string]*chatLockEntry)}
+
+	unlockA := locks.lock("chat_a")
+	defer unlockA()
+
+	done := make(chan struct{})
+	go func() {
+		unlock := locks.lock("chat_b")
+		unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock on chat_b blocked behind chat_a")
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrChatRoomNotFound is returned when a room doesn't exist (or belongs to another session)
+var ErrChatRoomNotFound = errors.New("chat room not found")
+
+// Room list filters (?archived=)
+const (
+	ChatRoomFilterActive   = "active"   // not archived (default)
+	ChatRoomFilterArchived = "archived" // only archived
+	ChatRoomFilterAll      = "all"      // archived and not archived
+)
+
+// Room list paging
+const (
+	defaultChatRoomPageSize = 50
+	maxChatRoomPageSize     = 200
+)
+
+// chatRoomsQuery scopes the room list of a session: deleted rooms are never listed, archived ones
+// only when asked for
+func chatRoomsQuery(db *gorm.DB, userToken, filter string) *gorm.DB {
+	query := db.Model(&models.ChatRoom{}).Where("user_token = ? AND is_deleted = ?", userToken, false)
+	switch filter {
+	case ChatRoomFilterArchived:
+		query = query.Where("is_archived = ?", true)
+	case ChatRoomFilterAll:
+	default:
+		query = query.Where("is_archived = ?", false)
+	}
+	return query
+}
+
+// ListChatRooms returns a page of a session's rooms, most recent activity first, and the total
+func ListChatRooms(userToken, filter string, limit, offset int) ([]models.ChatRoom, int64, error) {
+	if limit <= 0 {
+		limit = defaultChatRoomPageSize
+	}
+	if limit > maxChatRoomPageSize {
+		limit = maxChatRoomPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	db := database.GetDB()
+	var total int64
+	if err := chatRoomsQuery(db, userToken, filter).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count chat rooms: %w", err)
+	}
+
+	var rooms []models.ChatRoom
+	err := chatRoomsQuery(db, userToken, filter).
+		Order("last_activity DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rooms).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list chat rooms: %w", err)
+	}
+	return rooms, total, nil
+}
+
+// SetChatRoomArchived archives or unarchives a room; its messages are kept either way
+func SetChatRoomArchived(userToken string, roomID uint, archived bool) error {
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+
+	result := database.GetDB().Model(&models.ChatRoom{}).
+		Where("id = ? AND user_token = ? AND is_deleted = ?", roomID, userToken, false).
+		Updates(map[string]interface{}{
+			"is_archived": archived,
+			"archived_at": archivedAt,
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update chat room: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrChatRoomNotFound
+	}
+
+	log.Printf("🗂️  Chat room %d archived=%v (session %s)", roomID, archived, userToken)
+	return nil
+}
+
+// DeleteChatRoom removes the room's chat_messages and marks the room deleted, in one transaction.
+// The row itself is kept so a later message from the contact reuses it (chat_id is unique).
+// AI context (ai_chat_messages) is not touched - use ClearAIChatContext for that.
+func DeleteChatRoom(userToken string, roomID uint) (int64, error) {
+	var deletedMessages int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var room models.ChatRoom
+		if err := tx.Where("id = ? AND user_token = ? AND is_deleted = ?", roomID, userToken, false).
+			First(&room).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrChatRoomNotFound
+			}
+			return fmt.Errorf("failed to find chat room: %w", err)
+		}
+
+		result := tx.Where("chat_room_id = ?", room.ID).Delete(&models.ChatMessage{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete chat messages: %w", result.Error)
+		}
+		deletedMessages = result.RowsAffected
+
+		now := time.Now()
+		return tx.Model(&room).Updates(map[string]interface{}{
+			"is_deleted":   true,
+			"deleted_at":   now,
+			"is_archived":  false,
+			"archived_at":  nil,
+			"last_message": "",
+			"unread_count": 0,
+			"updated_at":   now,
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("🗑️  Deleted chat room %d with %d messages (session %s)", roomID, deletedMessages, userToken)
+	return deletedMessages, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestChatRoomArchiveAndDelete(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ChatRoom{}, &models.ChatMessage{}); err != nil {
+		t.Fatalf("failed to migrate chat history tables: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+
+	bot := "6280000000000@s.whatsapp.net"
+	contacts := []string{"6281200000011@s.whatsapp.net", "6281200000012@s.whatsapp.net", "6281200000013@s.whatsapp.net"}
+	for _, contact := range contacts {
+		if err := SaveToChatHistory(sessionTok, contact, bot, "halo", "Kak", time.Now(), false); err != nil {
+			t.Fatalf("failed to seed chat %s: %v", contact, err)
+		}
+	}
+	roomOf := func(contact string) models.ChatRoom {
+		var room models.ChatRoom
+		if err := db.Where("user_token = ? AND contact_jid = ?", sessionTok, contact).First(&room).Error; err != nil {
+			t.Fatalf("room of %s: %v", contact, err)
+		}
+		return room
+	}
+	countRooms := func(filter string) int64 {
+		_, total, err := ListChatRooms(sessionTok, filter, 0, 0)
+		if err != nil {
+			t.Fatalf("ListChatRooms(%s): %v", filter, err)
+		}
+		return total
+	}
+
+	archived, deleted := roomOf(contacts[0]), roomOf(contacts[1])
+	if err := SetChatRoomArchived(sessionTok, archived.ID, true); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if _, err := DeleteChatRoom(sessionTok, deleted.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	for filter, want := range map[string]int64{
+		ChatRoomFilterActive:   1, // the untouched room only
+		"":                     1,
+		ChatRoomFilterArchived: 1,
+		ChatRoomFilterAll:      2, // never the deleted one
+	} {
+		if got := countRooms(filter); got != want {
+			t.Errorf("filter %q: %d rooms, want %d", filter, got, want)
+		}
+	}
+
+	var count int64
+	db.Model(&models.ChatMessage{}).Where("chat_room_id = ?", archived.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("archiving must keep the messages, %d left", count)
+	}
+	db.Model(&models.ChatMessage{}).Where("chat_room_id = ?", deleted.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("deleting must remove the messages, %d left", count)
+	}
+
+	if err := SetChatRoomArchived(sessionTok, deleted.ID, true); !errors.Is(err, ErrChatRoomNotFound) {
+		t.Errorf("archiving a deleted room: err = %v, want ErrChatRoomNotFound", err)
+	}
+	if err := SetChatRoomArchived("other_session", archived.ID, false); !errors.Is(err, ErrChatRoomNotFound) {
+		t.Errorf("another session's room: err = %v, want ErrChatRoomNotFound", err)
+	}
+
+	// New incoming messages bring both rooms back to the default list
+	for _, contact := range contacts[:2] {
+		if err := SaveToChatHistory(sessionTok, contact, bot, "lagi", "Kak", time.Now(), false); err != nil {
+			t.Fatalf("failed to save message: %v", err)
+		}
+	}
+	if got := countRooms(ChatRoomFilterActive); got != 3 {
+		t.Errorf("%d active rooms after new messages, want 3", got)
+	}
+	if room := roomOf(contacts[1]); room.UnreadCount != 1 {
+		t.Errorf("revived room unread = %d, want 1", room.UnreadCount)
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 )
@@ -18,22 +19,94 @@ type CircuitBreaker struct {
 	mu          sync.RWMutex
 }
 
-// NewCircuitBreaker creates a new circuit breaker
+// CircuitBreakerState is a snapshot of a breaker for admin inspection
+type CircuitBreakerState struct {
+	Name          string     `json:"name"`
+	IsOpen        bool       `json:"is_open"`
+	Failures      int        `json:"failures"`
+	MaxFailures   int        `json:"max_failures"`
+	CooldownMs    int64      `json:"cooldown_ms"`
+	LastFailure   *time.Time `json:"last_failure,omitempty"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+}
+
+// circuitBreakers registers every breaker by name so admin endpoints can find it
+var circuitBreakers = struct {
+	sync.RWMutex
+	byName map[string]*CircuitBreaker
+}{byName: make(map[string]*CircuitBreaker)}
+
+// NewCircuitBreaker creates a new circuit breaker and registers it by name
+// (a later breaker with the same name replaces the earlier one in the registry)
 func NewCircuitBreaker(name string, maxFailures int, cooldown time.Duration) *CircuitBreaker {
-	return &CircuitBreaker{
+	cb := &CircuitBreaker{
 		name:        name,
 		maxFailures: maxFailures,
 		cooldown:    cooldown,
 		failures:    0,
 		isOpen:      false,
 	}
+
+	circuitBreakers.Lock()
+	circuitBreakers.byName[name] = cb
+	circuitBreakers.Unlock()
+
+	return cb
+}
+
+// GetCircuitBreaker returns the registered breaker with the given name
+func GetCircuitBreaker(name string) (*CircuitBreaker, bool) {
+	circuitBreakers.RLock()
+	defer circuitBreakers.RUnlock()
+	cb, ok := circuitBreakers.byName[name]
+	return cb, ok
+}
+
+// ListCircuitBreakers returns a snapshot of all registered breakers, sorted by name
+func ListCircuitBreakers() []CircuitBreakerState {
+	circuitBreakers.RLock()
+	breakers := make([]*CircuitBreaker, 0, len(circuitBreakers.byName))
+	for _, cb := range circuitBreakers.byName {
+		breakers = append(breakers, cb)
+	}
+	circuitBreakers.RUnlock()
+
+	states := make([]CircuitBreakerState, 0, len(breakers))
+	for _, cb := range breakers {
+		states = append(states, cb.State())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// State returns a snapshot of the breaker
+func (cb *CircuitBreaker) State() CircuitBreakerState {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	state := CircuitBreakerState{
+		Name:        cb.name,
+		IsOpen:      cb.isOpen,
+		Failures:    cb.failures,
+		MaxFailures: cb.maxFailures,
+		CooldownMs:  cb.cooldown.Milliseconds(),
+	}
+	if !cb.lastFailure.IsZero() {
+		lastFailure := cb.lastFailure
+		state.LastFailure = &lastFailure
+		if cb.isOpen {
+			until := cb.lastFailure.Add(cb.cooldown)
+			state.CooldownUntil = &until
+		}
+	}
+	return state
 }
 
-// Call executes the given function with circuit breaker protection
+// Call executes the given function with circuit breaker protection.
+// The lock is only held to check and record state, never while fn runs,
+// so concurrent callers (AI worker pool) are not serialized.
 func (cb *CircuitBreaker) Call(fn func() error) error {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
 	// Check if circuit is open
 	if cb.isOpen {
 		if time.Since(cb.lastFailure) > cb.cooldown {
@@ -42,18 +115,24 @@ func (cb *CircuitBreaker) Call(fn func() error) error {
 			cb.failures = 0
 			log.Printf("[CircuitBreaker:%s] Attempting half-open state", cb.name)
 		} else {
+			cooldownUntil := cb.lastFailure.Add(cb.cooldown)
+			cb.mu.Unlock()
 			return fmt.Errorf("circuit breaker %s is open (cooldown until %v)",
-				cb.name, cb.lastFailure.Add(cb.cooldown))
+				cb.name, cooldownUntil)
 		}
 	}
+	cb.mu.Unlock()
 
 	err := fn()
 
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
 	if err != nil {
 		cb.failures++
 		cb.lastFailure = time.Now()
 
-		if cb.failures >= cb.maxFailures {
+		if cb.failures >= cb.maxFailures && !cb.isOpen {
 			cb.isOpen = true
 			log.Printf("🔴 [CircuitBreaker:%s] OPENED after %d failures (cooldown: %v)",
 				cb.name, cb.failures, cb.cooldown)
@@ -67,6 +146,7 @@ func (cb *CircuitBreaker) Call(fn func() error) error {
 		log.Printf("✅ [CircuitBreaker:%s] Closed (recovered after %d failures)", cb.name, cb.failures)
 	}
 	cb.failures = 0
+	cb.isOpen = false
 	return nil
 }
 
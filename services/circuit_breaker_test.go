@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerDoesNotSerializeCalls(t *testing.T) {
+	cb := NewCircuitBreaker("test_parallel", 5, time.Minute)
+
+	const callers = 4
+	const delay = 150 * time.Millisecond
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = cb.Call(func() error {
+				time.Sleep(delay)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed >= 2*delay {
+		t.Errorf("%d concurrent calls took %v - breaker must not hold its lock while fn runs", callers, elapsed)
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	cb := NewCircuitBreaker("test_open", 2, 50*time.Millisecond)
+	failing := func() error { return errors.New("boom") }
+
+	_ = cb.Call(failing)
+	_ = cb.Call(failing)
+	if !cb.IsOpen() {
+		t.Fatalf("breaker should open after 2 failures")
+	}
+
+	called := false
+	if err := cb.Call(func() error { called = true; return nil }); err == nil || called {
+		t.Errorf("open breaker must reject calls without running fn")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := cb.Call(func() error { return nil }); err != nil {
+		t.Errorf("call after cooldown should pass: %v", err)
+	}
+	if cb.IsOpen() {
+		t.Errorf("breaker should be closed after a successful half-open call")
+	}
+}
+
+func TestCircuitBreakerRegistry(t *testing.T) {
+	cb := NewCircuitBreaker("test_registry", 1, time.Minute)
+	_ = cb.Call(func() error { return errors.New("boom") })
+
+	found, ok := GetCircuitBreaker("test_registry")
+	if !ok || found != cb {
+		t.Fatalf("breaker not found in registry")
+	}
+	if _, ok := GetCircuitBreaker("does_not_exist"); ok {
+		t.Errorf("unknown breaker must not be found")
+	}
+
+	var state *CircuitBreakerState
+	for _, s := range ListCircuitBreakers() {
+		if s.Name == "test_registry" {
+			s := s
+			state = &s
+		}
+	}
+	if state == nil {
+		t.Fatalf("breaker missing from ListCircuitBreakers")
+	}
+	if !state.IsOpen || state.Failures != 1 || state.CooldownUntil == nil {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	found.Reset()
+	if cb.IsOpen() || cb.State().Failures != 0 {
+		t.Errorf("Reset must force-close the breaker")
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"genfity-wa-support/config"
+)
+
+// waServerURLEnv is the canonical WA server variable; waServerURLLegacyEnvs are older names
+// still read (in this order) when it is unset
+const waServerURLEnv = "WA_SERVER_URL"
+
+var waServerURLLegacyEnvs = []string{"WHATSAPP_SERVER_API", "WHATSAPP_SERVER_URL"}
+
+// lookupWAServerURL returns the configured WA server base URL (no trailing slash) and the
+// variable it came from; both empty when none is set
+func lookupWAServerURL() (string, string) {
+	for _, key := range append([]string{waServerURLEnv}, waServerURLLegacyEnvs...) {
+		if value := config.GetEnvString(key, ""); value != "" {
+			return strings.TrimRight(value, "/"), key
+		}
+	}
+	return "", ""
+}
+
+// WAServerURL returns the WA server base URL: WA_SERVER_URL, else the legacy WHATSAPP_SERVER_API /
+// WHATSAPP_SERVER_URL. Sessions without a WA server of their own use it (see WAServerURLForSession).
+func WAServerURL() string {
+	baseURL, _ := lookupWAServerURL()
+	return baseURL
+}
+
+// ValidateWAServerConfig checks the WA server URL at startup: it must be set (under the canonical
+// or a legacy name) and be an absolute http(s) URL. Legacy names and conflicting values are logged.
+func ValidateWAServerConfig() error {
+	baseURL, source := lookupWAServerURL()
+	if baseURL == "" {
+		return fmt.Errorf("%s is not set (legacy names %s are accepted too)", waServerURLEnv, strings.Join(waServerURLLegacyEnvs, ", "))
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s=%q is not an http(s) URL", source, baseURL)
+	}
+
+	if source != waServerURLEnv {
+		log.Printf("⚠️  Warning: %s is deprecated, rename it to %s", source, waServerURLEnv)
+	}
+	for _, key := range append([]string{waServerURLEnv}, waServerURLLegacyEnvs...) {
+		if value := strings.TrimRight(config.GetEnvString(key, ""), "/"); key != source && value != "" && value != baseURL {
+			log.Printf("⚠️  Warning: %s=%s ignored - using %s=%s", key, value, source, baseURL)
+		}
+	}
+	log.Printf("🔧 WA server: %s (from %s)", baseURL, source)
+	return nil
+}
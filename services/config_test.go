@@ -0,0 +1,53 @@
+package services
+
+import "testing"
+
+func TestWAServerURLPrefersCanonicalName(t *testing.T) {
+	tests := []struct {
+		name                string
+		canonical, api, url string
+		want                string
+	}{
+		{"canonical", "http://wa:8080/", "", "", "http://wa:8080"},
+		{"legacy WHATSAPP_SERVER_API", "", "http://wa-api:8080", "", "http://wa-api:8080"},
+		{"legacy WHATSAPP_SERVER_URL", "", "", "https://wa.example.com", "https://wa.example.com"},
+		{"canonical wins over legacy", "http://wa:8080", "http://old:8080", "http://older:8080", "http://wa:8080"},
+		{"none", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WA_SERVER_URL", tt.canonical)
+			t.Setenv("WHATSAPP_SERVER_API", tt.api)
+			t.Setenv("WHATSAPP_SERVER_URL", tt.url)
+			if got := WAServerURL(); got != tt.want {
+				t.Errorf("WAServerURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWAServerConfig(t *testing.T) {
+	t.Setenv("WHATSAPP_SERVER_API", "")
+	t.Setenv("WHATSAPP_SERVER_URL", "")
+
+	for value, wantErr := range map[string]bool{
+		"":                       true,
+		"localhost:8080":         true, // no scheme
+		"ftp://wa.example.com":   true,
+		"http://":                true,
+		"http://localhost:8080":  false,
+		"https://wa.example.com": false,
+	} {
+		t.Setenv("WA_SERVER_URL", value)
+		if err := ValidateWAServerConfig(); (err != nil) != wantErr {
+			t.Errorf("WA_SERVER_URL=%q: err = %v, want error %v", value, err, wantErr)
+		}
+	}
+
+	// Only a legacy name set is accepted
+	t.Setenv("WA_SERVER_URL", "")
+	t.Setenv("WHATSAPP_SERVER_API", "http://localhost:8080")
+	if err := ValidateWAServerConfig(); err != nil {
+		t.Errorf("legacy WHATSAPP_SERVER_API: %v", err)
+	}
+}
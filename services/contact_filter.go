@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrBotNotFound - the user has no active bot to attach a contact filter to
+var ErrBotNotFound = errors.New("bot not found or inactive")
+
+// Contact filter modes (WhatsAppAIBot.contactFilterMode)
+const (
+	ContactFilterOff       = ""
+	ContactFilterAllowlist = "allowlist" // only listed numbers get AI replies (staged rollout)
+	ContactFilterDenylist  = "denylist"  // listed numbers never get AI replies (staff, known spammers)
+)
+
+// ContactFilter is a bot's allow/deny list of phone numbers. Numbers are compared by digits only,
+// so "+62 812-3456", "628123456" and "628123456@s.whatsapp.net" are the same contact.
+type ContactFilter struct {
+	Mode    string   `json:"mode"`
+	Allowed []string `json:"allowed,omitempty"`
+	Blocked []string `json:"blocked,omitempty"`
+}
+
+// ValidContactFilterMode reports whether mode is off, allowlist or denylist
+func ValidContactFilterMode(mode string) bool {
+	switch mode {
+	case ContactFilterOff, ContactFilterAllowlist, ContactFilterDenylist:
+		return true
+	}
+	return false
+}
+
+// ParseContactList decodes a JSON array of phone numbers (allowedContacts / blockedContacts)
+func ParseContactList(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var numbers []string
+	if err := json.Unmarshal([]byte(raw), &numbers); err != nil {
+		return nil, fmt.Errorf("invalid contact list: %w", err)
+	}
+	return NormalizeContactList(numbers), nil
+}
+
+// NormalizeContactList reduces numbers to digits, dropping blanks and duplicates
+func NormalizeContactList(numbers []string) []string {
+	seen := make(map[string]bool, len(numbers))
+	normalized := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		key := optOutContactKey(n)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, key)
+	}
+	return normalized
+}
+
+// Permits reports whether the contact may get an AI reply under this filter
+func (f *ContactFilter) Permits(contact string) bool {
+	if f == nil {
+		return true
+	}
+	key := optOutContactKey(contact)
+	switch f.Mode {
+	case ContactFilterAllowlist:
+		return containsContact(f.Allowed, key)
+	case ContactFilterDenylist:
+		return !containsContact(f.Blocked, key)
+	}
+	return true
+}
+
+func containsContact(list []string, key string) bool {
+	for _, n := range list {
+		if optOutContactKey(n) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// IsContactPermitted checks the bot's contact filter (no settings or no filter = everyone)
+func IsContactPermitted(botSettings *BotSettings, contact string) bool {
+	if botSettings == nil {
+		return true
+	}
+	return botSettings.ContactFilter.Permits(contact)
+}
+
+// activeBotForUser loads the user's active bot from the transactional DB
+func activeBotForUser(userID string) (*models.WhatsAppAIBot, error) {
+	db := database.GetTransactionalDB()
+	if db == nil {
+		return nil, fmt.Errorf("transactional database not initialized")
+	}
+	var bot models.WhatsAppAIBot
+	if err := db.Where(`"userId" = ? AND "isActive" = ?`, userID, true).First(&bot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBotNotFound
+		}
+		return nil, fmt.Errorf("failed to load bot: %w", err)
+	}
+	return &bot, nil
+}
+
+// GetBotContactFilter returns the contact filter of the user's active bot (Mode "" = off)
+func GetBotContactFilter(userID string) (*ContactFilter, error) {
+	bot, err := activeBotForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := contactFilterFromBot(bot)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = &ContactFilter{Mode: ContactFilterOff}
+	}
+	return filter, nil
+}
+
+// SetBotContactFilter replaces the contact filter of the user's active bot. Both lists are kept
+// whatever the mode, so switching between allowlist and denylist doesn't lose either list.
+func SetBotContactFilter(userID string, filter ContactFilter) (*ContactFilter, error) {
+	if !ValidContactFilterMode(filter.Mode) {
+		return nil, fmt.Errorf("invalid mode %q (use allowlist, denylist or empty)", filter.Mode)
+	}
+	bot, err := activeBotForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter.Allowed = NormalizeContactList(filter.Allowed)
+	filter.Blocked = NormalizeContactList(filter.Blocked)
+	allowed, err := json.Marshal(filter.Allowed)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := json.Marshal(filter.Blocked)
+	if err != nil {
+		return nil, err
+	}
+
+	var mode interface{}
+	if filter.Mode != ContactFilterOff {
+		mode = filter.Mode
+	}
+	err = database.GetTransactionalDB().Model(&models.WhatsAppAIBot{}).
+		Where("id = ?", bot.ID).
+		Updates(map[string]interface{}{
+			"contactFilterMode": mode,
+			"allowedContacts":   string(allowed),
+			"blockedContacts":   string(blocked),
+			"updatedAt":         time.Now(),
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to update contact filter: %w", err)
+	}
+	return &filter, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"genfity-wa-support/models"
+)
+
+func TestContactFilterAllowlist(t *testing.T) {
+	filter := &ContactFilter{Mode: ContactFilterAllowlist, Allowed: []string{"+62 812-0000-0001"}}
+	settings := &BotSettings{ContactFilter: filter}
+
+	if !IsContactPermitted(settings, "6281200000001@s.whatsapp.net") {
+		t.Error("listed contact should be permitted in allowlist mode")
+	}
+	if IsContactPermitted(settings, "6281200000002@s.whatsapp.net") {
+		t.Error("unlisted contact should not be permitted in allowlist mode")
+	}
+	// Blocked entries don't matter in allowlist mode
+	filter.Blocked = []string{"6281200000001"}
+	if !IsContactPermitted(settings, "6281200000001") {
+		t.Error("allowlist mode should ignore the denylist")
+	}
+}
+
+func TestContactFilterDenylist(t *testing.T) {
+	settings := &BotSettings{ContactFilter: &ContactFilter{
+		Mode:    ContactFilterDenylist,
+		Allowed: []string{"6281200000002"},
+		Blocked: []string{"6281200000001"},
+	}}
+
+	if IsContactPermitted(settings, "6281200000001@s.whatsapp.net") {
+		t.Error("blocked contact should not be permitted in denylist mode")
+	}
+	if !IsContactPermitted(settings, "6281200000003@s.whatsapp.net") {
+		t.Error("unlisted contact should be permitted in denylist mode")
+	}
+}
+
+func TestContactFilterOffPermitsEveryone(t *testing.T) {
+	for _, settings := range []*BotSettings{nil, {}, {ContactFilter: &ContactFilter{Blocked: []string{"6281200000001"}}}} {
+		if !IsContactPermitted(settings, "6281200000001") {
+			t.Errorf("settings %+v should permit everyone", settings)
+		}
+	}
+}
+
+func TestContactFilterFromBot(t *testing.T) {
+	mode := ContactFilterAllowlist
+	allowed := `["+62 812-0000-0001", "6281200000001", ""]`
+	filter, err := contactFilterFromBot(&models.WhatsAppAIBot{ContactFilterMode: &mode, AllowedContacts: &allowed})
+	if err != nil {
+		t.Fatalf("contactFilterFromBot: %v", err)
+	}
+	if filter.Mode != ContactFilterAllowlist || !reflect.DeepEqual(filter.Allowed, []string{"6281200000001"}) {
+		t.Errorf("filter = %+v", filter)
+	}
+
+	if filter, err := contactFilterFromBot(&models.WhatsAppAIBot{}); err != nil || filter != nil {
+		t.Errorf("no mode = %+v, %v; want nil filter", filter, err)
+	}
+
+	unknown := "whitelist"
+	if _, err := contactFilterFromBot(&models.WhatsAppAIBot{ContactFilterMode: &unknown}); err == nil {
+		t.Error("unknown mode should fail")
+	}
+	broken := `{"not":"a list"}`
+	if _, err := contactFilterFromBot(&models.WhatsAppAIBot{ContactFilterMode: &mode, AllowedContacts: &broken}); err == nil {
+		t.Error("invalid list JSON should fail")
+	}
+}
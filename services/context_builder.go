@@ -3,8 +3,12 @@ package services
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"genfity-wa-support/config"
 	"genfity-wa-support/database"
 	"genfity-wa-support/models"
 )
@@ -13,6 +17,8 @@ import (
 type ContextData struct {
 	SystemPrompt string
 	UserMessage  string
+	Settings     *BotSettings     // bot settings the prompt was built from
+	Decision     *ContextDecision // how the prompt was assembled; nil unless DEBUG_CONTEXT_DECISIONS
 }
 
 // Document represents knowledge base document
@@ -27,6 +33,84 @@ type BotSettings struct {
 	SystemPrompt string     `json:"systemPrompt"`
 	FallbackText string     `json:"fallbackText"`
 	Documents    []Document `json:"documents"`
+	MaxDocuments *int       `json:"maxDocuments,omitempty"` // per-bot override of AI_MAX_DOCUMENTS; nil or <= 0 uses the global
+
+	// UseKnowledgeBase = false skips fetching and injecting documents entirely (pure conversational
+	// bots save the tokens); nil = enabled
+	UseKnowledgeBase *bool `json:"useKnowledgeBase,omitempty"`
+
+	// MessageTypeHandling maps inbound type ("text", "image", "reaction", "*", ...) to a route
+	// (reply | fallback | handoff | ignore); unset types use ResolveMessageRoute defaults
+	MessageTypeHandling map[string]string `json:"messageTypeHandling,omitempty"`
+
+	// AllowImageSend lets the bot answer with [SEND_IMAGE:url] (knowledge base URLs only)
+	AllowImageSend bool `json:"allowImageSend,omitempty"`
+
+	// AllowLocationSend / AllowContactSend let the bot answer with [SEND_LOCATION:lat,lng,name] /
+	// [SEND_CONTACT:name,phone] cards (coordinates and numbers from the knowledge base only)
+	AllowLocationSend bool `json:"allowLocationSend,omitempty"`
+	AllowContactSend  bool `json:"allowContactSend,omitempty"`
+
+	// BusinessHours limits AI replies to a weekly schedule; nil = always answer.
+	// Outside hours the AfterHoursMessage is sent instead of calling the LLM.
+	BusinessHours     *BusinessHours `json:"businessHours,omitempty"`
+	AfterHoursMessage string         `json:"afterHoursMessage,omitempty"`
+
+	// AutoRead = false stops the AI worker from marking the contact's messages as read (no blue
+	// ticks for the sender); nil = auto-read, as before
+	AutoRead *bool `json:"autoRead,omitempty"`
+
+	// GreetingMessage is sent once to a brand-new contact before the AI reply; empty = no greeting
+	GreetingMessage string `json:"greetingMessage,omitempty"`
+
+	// ContactFilter restricts AI replies to an allowlist or excludes a denylist; nil = everyone
+	ContactFilter *ContactFilter `json:"contactFilter,omitempty"`
+
+	// EscalationContacts are notified (WhatsApp and/or webhook) when a conversation is handed off
+	EscalationContacts []EscalationContact `json:"escalationContacts,omitempty"`
+
+	// MaxDailyRepliesPerContact caps AI replies to one contact per day (bot-to-bot loop guard);
+	// nil or <= 0 uses AI_MAX_DAILY_REPLIES_PER_CONTACT
+	MaxDailyRepliesPerContact *int `json:"maxDailyRepliesPerContact,omitempty"`
+
+	// AIProvider / AIModel / AIAPIKey are the customer's own LLM credentials (see ResolveBotLLM).
+	// AIAPIKey stays encrypted here and is only decrypted when the client is built; empty = global client
+	AIProvider string `json:"aiProvider,omitempty"`
+	AIModel    string `json:"aiModel,omitempty"`
+	AIAPIKey   string `json:"aiApiKey,omitempty"`
+
+	// ResponseLanguage enforces the reply language ("id" | "en") for this bot, "auto" disables
+	// enforcement (multilingual bots); empty = AI_RESPONSE_LANGUAGE (see BotResponseLanguage)
+	ResponseLanguage string `json:"responseLanguage,omitempty"`
+}
+
+// defaultKnowledgeLimit is the global max KB documents in context (AI_MAX_DOCUMENTS, default 10)
+func defaultKnowledgeLimit() int {
+	limit := config.GetEnvInt("AI_MAX_DOCUMENTS", 10)
+	if limit <= 0 {
+		return 10
+	}
+	return limit
+}
+
+// KnowledgeBaseEnabled reports whether the bot's documents go into the prompt (default true)
+func KnowledgeBaseEnabled(botSettings *BotSettings) bool {
+	return botSettings == nil || botSettings.UseKnowledgeBase == nil || *botSettings.UseKnowledgeBase
+}
+
+// AutoReadEnabled reports whether the AI worker marks the contact's messages as read (default true)
+func AutoReadEnabled(botSettings *BotSettings) bool {
+	return botSettings == nil || botSettings.AutoRead == nil || *botSettings.AutoRead
+}
+
+// knowledgeLimitFor returns the bot's own document limit if set, otherwise the global default.
+// A per-bot value of 0 (or negative) means "not configured", not "no documents" -
+// bots that shouldn't use the knowledge base simply have no documents bound.
+func knowledgeLimitFor(botSettings *BotSettings) int {
+	if botSettings.MaxDocuments != nil && *botSettings.MaxDocuments > 0 {
+		return *botSettings.MaxDocuments
+	}
+	return defaultKnowledgeLimit()
 }
 
 // BuildContext fetches bot settings and builds context for LLM with default limit (10 messages)
@@ -36,23 +120,34 @@ func BuildContext(userID, sessionToken, messageID string) (*ContextData, error)
 
 // BuildContextWithLimit builds context with dynamic message limit
 func BuildContextWithLimit(userID, sessionToken, messageID string, maxMessages int) (*ContextData, error) {
+	return BuildContextForMessages(userID, sessionToken, []string{messageID}, maxMessages)
+}
+
+// BuildContextForMessages builds context for a job answering one or more messages (oldest first);
+// debounced messages are combined into one user turn, one message per line
+func BuildContextForMessages(userID, sessionToken string, messageIDs []string, maxMessages int) (*ContextData, error) {
 	// 1. Fetch bot settings using data provider (respects DATA_ACCESS_MODE env)
 	provider, err := GetDataProvider()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data provider: %w", err)
 	}
 
-	botSettings, err := provider.GetBotSettings(userID, sessionToken)
+	// Bounded by AI_BOT_SETTINGS_TIMEOUT_MS; falls back to the last known good settings
+	botSettings, err := GetBotSettingsWithFallback(provider, userID, sessionToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch bot settings: %w", err)
 	}
 
-	// 2. Get current message first (needed for smart doc filtering)
+	// 2. Get current message(s) first (needed for smart doc filtering)
 	db := database.GetDB()
-	var currentMsg models.AIChatMessage
-	err = db.Where("message_id = ?", messageID).First(&currentMsg).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch current message: %w", err)
+	userTurn := make([]string, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		var currentMsg models.AIChatMessage
+		err = db.Where("message_id = ?", messageID).First(&currentMsg).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch current message: %w", err)
+		}
+		userTurn = append(userTurn, composeUserMessage(currentMsg))
 	}
 
 	// 3. Fetch chat history with dynamic limit
@@ -65,16 +160,101 @@ func BuildContextWithLimit(userID, sessionToken, messageID string, maxMessages i
 		return nil, fmt.Errorf("failed to fetch chat history: %w", err)
 	}
 
+	// Reverse order (oldest first)
+	history := make([]models.AIChatMessage, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		history = append(history, messages[i])
+	}
+
+	return AssembleContext(botSettings, history, strings.Join(userTurn, "\n")), nil
+}
+
+// historyLineMaxChars is the max length of one history line (AI_HISTORY_LINE_MAX_CHARS, default 200)
+func historyLineMaxChars() int {
+	limit := config.GetEnvInt("AI_HISTORY_LINE_MAX_CHARS", 200)
+	if limit <= 0 {
+		return 200
+	}
+	return limit
+}
+
+// truncateHistoryLine shortens body to at most limit characters (runes), preferring to cut at
+// the last whitespace so words stay whole, and appends suffix. Returns the line and the
+// number of characters dropped.
+func truncateHistoryLine(body string, limit int, suffix string) (string, int) {
+	runes := []rune(body)
+	if len(runes) <= limit {
+		return body, 0
+	}
+
+	cut := limit
+	// Break on whitespace unless that would throw away more than a third of the allowed length
+	for i := limit; i >= limit*2/3; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+
+	kept := strings.TrimRightFunc(TruncateRunes(body, cut), unicode.IsSpace)
+	return kept + suffix, len(runes) - utf8.RuneCountInString(kept)
+}
+
+// composeUserMessage adds reply/reaction context to the customer's message
+// so the LLM knows which earlier message the customer is referring to
+func composeUserMessage(msg models.AIChatMessage) string {
+	quoted := truncateQuoted(msg.QuotedBody)
+
+	if msg.MsgType == "reaction" {
+		if quoted != "" {
+			return fmt.Sprintf("[Customer memberi reaksi %s pada pesan: \"%s\"]", msg.Body, quoted)
+		}
+		return fmt.Sprintf("[Customer memberi reaksi %s]", msg.Body)
+	}
+
+	body := msg.Body
+	if label, ok := mediaLabels[msg.MsgType]; ok {
+		body = strings.TrimSpace(fmt.Sprintf("[Customer mengirim %s]\n%s", label, msg.Body))
+	}
+
+	if quoted != "" {
+		return fmt.Sprintf("(Customer membalas pesan: \"%s\")\n%s", quoted, body)
+	}
+	return body
+}
+
+// mediaLabels names inbound media types in the prompt (body holds the caption, if any)
+var mediaLabels = map[string]string{
+	"image":    "gambar",
+	"video":    "video",
+	"audio":    "pesan suara",
+	"document": "dokumen",
+	"sticker":  "stiker",
+	"media":    "media",
+}
+
+// truncateQuoted keeps quoted context short so it doesn't dominate the prompt
+func truncateQuoted(text string) string {
+	const maxQuotedChars = 300
+	text = strings.TrimSpace(text)
+	if truncated := TruncateRunes(text, maxQuotedChars); truncated != text {
+		return truncated + "..."
+	}
+	return text
+}
+
+// AssembleContext builds the LLM prompt from bot settings, chat history (oldest first) and the current user message.
+// It does not touch any storage, so it can also be used with an in-memory history.
+func AssembleContext(botSettings *BotSettings, history []models.AIChatMessage, userMessage string) *ContextData {
 	// 4. Build system prompt
 	systemPrompt := botSettings.SystemPrompt
 	if systemPrompt == "" {
 		systemPrompt = "Anda adalah customer service yang ramah dan profesional."
 		log.Printf("⚠️  Using default system prompt (no custom prompt found)")
 	} else {
-		log.Printf("✅ Using custom system prompt: %d chars", len(systemPrompt))
-		// Show first 300 chars of system prompt for debugging
-		previewLen := min(300, len(systemPrompt))
-		log.Printf("📝 System prompt preview: %s...", systemPrompt[:previewLen])
+		log.Printf("✅ Using custom system prompt: %d chars", utf8.RuneCountInString(systemPrompt))
+		// Show first 300 chars of system prompt for debugging (rune-safe: prompts are full of emoji)
+		log.Printf("📝 System prompt preview: %s", PreviewText(systemPrompt, 300))
 	}
 
 	// Add WhatsApp formatting instructions to system prompt
@@ -117,95 +297,294 @@ Contoh BAIK (ikuti ini):
 "Untuk website e-commerce dengan fitur yang Anda sebutkan (landing page + order + payment), estimasi biaya sekitar Rp 8-12 juta tergantung kompleksitas payment gateway. Sudah termasuk desain UI/UX dan integrasi API. Mau saya buatkan breakdown detailnya?"
 `
 
+	// AI_INJECTION_GUARD: delimit the customer's message and tell the model it is data, not instructions.
+	// query keeps the raw text for knowledge base relevance.
+	query := userMessage
+	if InjectionGuardEnabled() {
+		systemPrompt += injectionGuardInstructions
+		userMessage = guardCustomerMessage(userMessage)
+	}
+
+	// Token budget: the model's context window minus the reply reserve. The bot's own prompt, the
+	// user turn and the closing reminder always go in; knowledge base and then history fill what's
+	// left, dropping the least relevant documents and the oldest messages first.
+	budget := newContextBudget(configuredModelName())
+	var decision *ContextDecision
+	if ContextDecisionsEnabled() {
+		decision = &ContextDecision{DocumentsAvailable: len(botSettings.Documents), HistoryMessages: len(history)}
+	}
+	budget.reserve(systemPrompt)
+	budget.reserve(userMessage)
+	budget.reserve(contextReminder)
+	if botSettings.AllowImageSend {
+		budget.reserve(imageSendInstructions)
+	}
+	if botSettings.AllowLocationSend {
+		budget.reserve(locationSendInstructions)
+	}
+	if botSettings.AllowContactSend {
+		budget.reserve(contactSendInstructions)
+	}
+	responseLanguage := BotResponseLanguage(botSettings)
+	if responseLanguage != "" {
+		budget.reserve(responseLanguageInstructions(responseLanguage))
+	}
+	if LLMHandoffEnabled() {
+		budget.reserve(handoffInstructions)
+	}
+
 	// Add knowledge base with smart selection based on user query
 	// For better context relevance, we can filter docs based on keywords in the current message
 	relevantDocs := botSettings.Documents
+	if !KnowledgeBaseEnabled(botSettings) {
+		relevantDocs = nil // useKnowledgeBase: false
+		decision.truncation("knowledge base disabled for this bot")
+	}
+
+	// Limit to top documents to avoid context overflow (per-bot override or global)
+	knowledgeLimit := knowledgeLimitFor(botSettings)
+	if decision != nil {
+		decision.DocumentLimit = knowledgeLimit
+	}
 
 	// If there are many documents, try to prioritize relevant ones
-	if len(botSettings.Documents) > 10 {
-		log.Printf("📚 Large knowledge base detected (%d docs), applying smart filtering...", len(botSettings.Documents))
-		relevantDocs = filterRelevantDocuments(botSettings.Documents, currentMsg.Body)
+	ranked := false
+	if len(relevantDocs) > knowledgeLimit {
+		log.Printf("📚 Large knowledge base detected (%d docs), applying smart filtering...", len(relevantDocs))
+		relevantDocs = filterRelevantDocuments(relevantDocs, query, decision)
+		ranked = true
 		log.Printf("✅ Filtered to %d relevant documents", len(relevantDocs))
 	}
 
-	// Limit to top documents to avoid context overflow
-	knowledgeLimit := 10 // Increased from 5 to 10
+	var overLimit []Document
 	if len(relevantDocs) > knowledgeLimit {
 		log.Printf("⚠️  Limiting knowledge base to %d docs (total: %d)",
 			knowledgeLimit, len(relevantDocs))
+		overLimit = relevantDocs[knowledgeLimit:]
 		relevantDocs = relevantDocs[:knowledgeLimit]
 	}
 
 	if len(relevantDocs) > 0 {
-		systemPrompt += "\n\n=== Knowledge Base - WAJIB DIGUNAKAN ===\n"
-		systemPrompt += "ATURAN PENTING:\n"
-		systemPrompt += "1. SELALU gunakan informasi dari knowledge base untuk menjawab pertanyaan tentang harga, layanan, dan fitur\n"
-		systemPrompt += "2. Jangan membuat estimasi harga sendiri - gunakan HARGA PASTI dari knowledge base\n"
-		systemPrompt += "3. Jika user tanya harga, sebutkan paket yang relevan dengan ANGKA PASTI\n"
-		systemPrompt += "4. Jika knowledge base tidak memiliki info yang ditanya, baru boleh minta detail atau tawarkan konsultasi\n\n"
-
-		for _, doc := range relevantDocs {
-			// Dynamic limit based on document type
-			maxLength := 5000 // Increased from 3000 for pricing docs
-			if doc.Kind == "pricing" || doc.Kind == "price" {
-				maxLength = 8000 // Even higher for pricing - most important!
-			}
+		systemPrompt += knowledgeBaseSection(relevantDocs, query, ranked, budget, decision)
+	}
+	for _, doc := range overLimit {
+		decision.addDocument(doc, 0, false, docReasonDocumentLimit)
+	}
 
-			content := doc.Content
-			originalLength := len(content)
-			if len(content) > maxLength {
-				content = content[:maxLength] + "..."
-				log.Printf("⚠️  Document '%s' truncated to %d chars (original: %d)", doc.Title, maxLength, originalLength)
-			}
-			systemPrompt += fmt.Sprintf("\n[%s - %s]\n%s\n", doc.Kind, doc.Title, content)
-		}
-		systemPrompt += "\n--- End of Knowledge Base ---\n"
+	if botSettings.AllowImageSend {
+		systemPrompt += imageSendInstructions
+	}
+	if botSettings.AllowLocationSend {
+		systemPrompt += locationSendInstructions
+	}
+	if botSettings.AllowContactSend {
+		systemPrompt += contactSendInstructions
+	}
+	if responseLanguage != "" {
+		systemPrompt += responseLanguageInstructions(responseLanguage)
+	}
+	if LLMHandoffEnabled() {
+		systemPrompt += handoffInstructions
 	}
 
 	// Add chat history
-	if len(messages) > 0 {
-		systemPrompt += "\n\n=== Conversation History ===\n"
-		systemPrompt += "PENTING: Gunakan percakapan di bawah untuk memahami konteks dan JANGAN ulangi informasi yang sudah diberikan.\n\n"
-		// Reverse order (oldest first)
-		for i := len(messages) - 1; i >= 0; i-- {
-			msg := messages[i]
-			role := "Customer"
-			if msg.FromMe {
-				role = "Assistant"
-			}
-			// Limit message body to 200 characters
-			body := msg.Body
-			if len(body) > 200 {
-				body = body[:200] + "..."
-			}
-			systemPrompt += fmt.Sprintf("%s: %s\n", role, body)
-		}
-		systemPrompt += "\n--- End of History ---\n"
-		systemPrompt += "Sekarang lanjutkan percakapan dengan natural berdasarkan context di atas. Jangan reset atau ulangi info yang sudah dijelaskan.\n"
+	if len(history) > 0 {
+		systemPrompt += historySection(history, budget, decision)
 	}
 
 	// Add final reminder about knowledge base
-	systemPrompt += "\n\n=== REMINDER SEBELUM MENJAWAB ===\n"
-	systemPrompt += "Sebelum menjawab pertanyaan user:\n"
-	systemPrompt += "1. CEK KNOWLEDGE BASE terlebih dahulu - terutama untuk pertanyaan harga/layanan\n"
-	systemPrompt += "2. Gunakan HARGA PASTI dari knowledge base, jangan buat estimasi sendiri\n"
-	systemPrompt += "3. Sebutkan nama paket yang sesuai (Starter/Business/Prime/Enterprise)\n"
-	systemPrompt += "4. Jika knowledge base tidak cukup, baru tawarkan konsultasi detail\n"
-
-	// Estimate token count (rough: 1 token ≈ 4 chars)
-	estimatedTokens := (len(systemPrompt) + len(currentMsg.Body)) / 4
-	log.Printf("📊 Context size: ~%d tokens (system: %d chars, user: %d chars, messages: %d)",
-		estimatedTokens, len(systemPrompt), len(currentMsg.Body), maxMessages)
+	systemPrompt += contextReminder
+
+	estimatedTokens := EstimateTokens(systemPrompt) + EstimateTokens(userMessage)
+	log.Printf("📊 Context size: ~%d tokens (system: %d chars, user: %d chars, messages: %d, budget left: %d)",
+		estimatedTokens, len(systemPrompt), len(userMessage), len(history), budget.remaining)
+	if decision != nil {
+		decision.EstimatedTokens = estimatedTokens
+		decision.BudgetLeft = budget.remaining
+	}
 
 	return &ContextData{
 		SystemPrompt: systemPrompt,
-		UserMessage:  currentMsg.Body,
-	}, nil
+		UserMessage:  userMessage,
+		Settings:     botSettings,
+		Decision:     decision,
+	}
+}
+
+const knowledgeBaseHeader = "\n\n=== Knowledge Base - WAJIB DIGUNAKAN ===\n" +
+	"ATURAN PENTING:\n" +
+	"1. SELALU gunakan informasi dari knowledge base untuk menjawab pertanyaan tentang harga, layanan, dan fitur\n" +
+	"2. Jangan membuat estimasi harga sendiri - gunakan HARGA PASTI dari knowledge base\n" +
+	"3. Jika user tanya harga, sebutkan paket yang relevan dengan ANGKA PASTI\n" +
+	"4. Jika knowledge base tidak memiliki info yang ditanya, baru boleh minta detail atau tawarkan konsultasi\n\n"
+
+const knowledgeBaseFooter = "\n--- End of Knowledge Base ---\n"
+
+const contextReminder = "\n\n=== REMINDER SEBELUM MENJAWAB ===\n" +
+	"Sebelum menjawab pertanyaan user:\n" +
+	"1. CEK KNOWLEDGE BASE terlebih dahulu - terutama untuk pertanyaan harga/layanan\n" +
+	"2. Gunakan HARGA PASTI dari knowledge base, jangan buat estimasi sendiri\n" +
+	"3. Sebutkan nama paket yang sesuai (Starter/Business/Prime/Enterprise)\n" +
+	"4. Jika knowledge base tidak cukup, baru tawarkan konsultasi detail\n"
+
+// minBudgetDocRunes - a document the token budget would cut shorter than this is left out instead
+const minBudgetDocRunes = 200
+
+// knowledgeBaseSection renders the documents that fit the token budget ("" if none do). When they
+// don't all fit, unranked docs are first sorted by relevance so the least relevant are dropped.
+// Each document's outcome goes into decision (may be nil).
+func knowledgeBaseSection(docs []Document, userMessage string, ranked bool, budget *contextBudget, decision *ContextDecision) string {
+	// Per-document limits plus the combined KB budget (AI_MAX_KB_CHARS)
+	limits := allocateKnowledgeBudget(docs, knowledgeBudgetChars(), knowledgeBudgetStrategy())
+	contents := make([]string, len(docs))
+	total := EstimateTokens(knowledgeBaseHeader + knowledgeBaseFooter)
+	for i, doc := range docs {
+		if limits[i] == 0 && doc.Content != "" {
+			log.Printf("⚠️  Document '%s' left out: knowledge base budget used up", doc.Title)
+			continue
+		}
+
+		content := doc.Content
+		if truncated := TruncateRunes(content, limits[i]); truncated != content {
+			log.Printf("⚠️  Document '%s' truncated to %d chars (original: %d)",
+				doc.Title, limits[i], utf8.RuneCountInString(content))
+			content = truncated + "..."
+		}
+		contents[i] = content
+		total += EstimateTokens(documentEntry(doc, content))
+	}
+
+	if total > budget.remaining && !ranked && len(docs) > 1 {
+		log.Printf("📏 Knowledge base (~%d tokens) exceeds the context budget (%d left), ranking by relevance", total, budget.remaining)
+		decision.truncation("knowledge base (~%d tokens) exceeded the context budget (%d left), ranked by relevance", total, budget.remaining)
+		return knowledgeBaseSection(filterRelevantDocuments(docs, userMessage, decision), userMessage, true, budget, decision)
+	}
+	if decision != nil {
+		decision.Ranked = ranked
+	}
+
+	if !budget.take(knowledgeBaseHeader + knowledgeBaseFooter) {
+		log.Printf("⚠️  Knowledge base left out: no context budget left")
+		decision.truncation("knowledge base left out: no context budget left")
+		for _, doc := range docs {
+			decision.addDocument(doc, 0, false, docReasonTokenBudget)
+		}
+		return ""
+	}
+	section := knowledgeBaseHeader
+	added := 0
+	for i, doc := range docs {
+		if limits[i] == 0 && doc.Content != "" {
+			decision.addDocument(doc, 0, false, docReasonKBBudget)
+			continue
+		}
+		chars := utf8.RuneCountInString(contents[i])
+		truncated := contents[i] != doc.Content
+		entry := documentEntry(doc, contents[i])
+		if !budget.take(entry) {
+			// Shorten to what's left before giving up on the document
+			budget.reserve(documentEntry(doc, "..."))
+			keep := budget.fitRunes(contents[i], utf8.RuneCountInString(contents[i]))
+			if keep < minBudgetDocRunes {
+				budget.release(documentEntry(doc, "..."))
+				log.Printf("⚠️  Document '%s' left out: context token budget used up", doc.Title)
+				decision.addDocument(doc, 0, false, docReasonTokenBudget)
+				continue
+			}
+			log.Printf("⚠️  Document '%s' cut to %d chars to fit the context token budget", doc.Title, keep)
+			entry = documentEntry(doc, TruncateRunes(contents[i], keep)+"...")
+			budget.reserve(TruncateRunes(contents[i], keep))
+			chars, truncated = keep, true
+		}
+		decision.addDocument(doc, chars, truncated, "")
+		section += entry
+		added++
+	}
+	if added == 0 {
+		budget.release(knowledgeBaseHeader + knowledgeBaseFooter)
+		return ""
+	}
+	return section + knowledgeBaseFooter
+}
+
+func documentEntry(doc Document, content string) string {
+	return fmt.Sprintf("\n[%s - %s]\n%s\n", doc.Kind, doc.Title, content)
 }
 
+// historySection renders the chat history (oldest first) keeping the newest lines that fit the token
+// budget; how many made it goes into decision (may be nil)
+func historySection(history []models.AIChatMessage, budget *contextBudget, decision *ContextDecision) string {
+	historyLineLimit := historyLineMaxChars()
+	historyTruncateSuffix, ok := os.LookupEnv("AI_HISTORY_TRUNCATE_SUFFIX") // not trimmed: may start with a space
+	if !ok || historyTruncateSuffix == "" {
+		historyTruncateSuffix = "..."
+	}
+	guardInjection := InjectionGuardEnabled()
+
+	header := "\n\n=== Conversation History ===\n" +
+		"PENTING: Gunakan percakapan di bawah untuk memahami konteks dan JANGAN ulangi informasi yang sudah diberikan.\n\n"
+	footer := "\n--- End of History ---\n" +
+		"Sekarang lanjutkan percakapan dengan natural berdasarkan context di atas. Jangan reset atau ulangi info yang sudah dijelaskan.\n"
+	if !budget.take(header + footer) {
+		log.Printf("⚠️  Conversation history left out: no context budget left")
+		decision.truncation("conversation history left out: no context budget left")
+		return ""
+	}
+
+	lines := make([]string, len(history))
+	for i, msg := range history {
+		role := "Customer"
+		if msg.FromMe {
+			role = "Assistant"
+		}
+		body := msg.Body
+		if msg.MsgType == "reaction" {
+			body = fmt.Sprintf("[memberi reaksi %s]", msg.Body)
+		} else if label, ok := mediaLabels[msg.MsgType]; ok {
+			body = strings.TrimSpace(fmt.Sprintf("[mengirim %s] %s", label, msg.Body))
+		}
+		if guardInjection && !msg.FromMe {
+			body = guardHistoryLine(body)
+		}
+		// Limit message body (AI_HISTORY_LINE_MAX_CHARS, cut on a word boundary)
+		truncated, dropped := truncateHistoryLine(body, historyLineLimit, historyTruncateSuffix)
+		if dropped*2 >= utf8.RuneCountInString(body) {
+			log.Printf("⚠️  History line truncated to %d chars, %d of %d chars dropped (message %s)",
+				historyLineLimit, dropped, utf8.RuneCountInString(body), msg.MessageID)
+		}
+		if dropped > 0 && decision != nil {
+			decision.HistoryTruncated++
+		}
+		lines[i] = fmt.Sprintf("%s: %s\n", role, truncated)
+	}
+
+	// Newest first: the oldest messages are the ones that don't make it
+	first := len(lines)
+	for first > 0 && budget.take(lines[first-1]) {
+		first--
+	}
+	if first == len(lines) {
+		budget.release(header + footer)
+		log.Printf("⚠️  Conversation history left out: no context budget left")
+		decision.truncation("conversation history left out: no context budget left")
+		return ""
+	}
+	if first > 0 {
+		log.Printf("⚠️  Conversation history: %d oldest of %d messages left out to fit the context token budget", first, len(lines))
+		decision.truncation("%d oldest of %d history messages left out to fit the context token budget", first, len(lines))
+	}
+	if decision != nil {
+		decision.HistoryIncluded = len(lines) - first
+	}
+	return header + strings.Join(lines[first:], "") + footer
+}
+
+// pricingKeywords mark a price question (doc scoring, IsPricingQuery)
+var pricingKeywords = []string{"harga", "biaya", "price", "cost", "berapa", "paket", "rp", "rupiah", "juta", "ribu"}
+
 // filterRelevantDocuments filters documents based on keyword relevance to user query
-// Returns documents sorted by relevance score (highest first)
-func filterRelevantDocuments(docs []Document, userQuery string) []Document {
+// Returns documents sorted by relevance score (highest first); the scores go into decision (may be nil)
+func filterRelevantDocuments(docs []Document, userQuery string, decision *ContextDecision) []Document {
 	if len(docs) == 0 {
 		return docs
 	}
@@ -215,7 +594,7 @@ func filterRelevantDocuments(docs []Document, userQuery string) []Document {
 
 	// Define keyword categories and their weights
 	keywords := map[string][]string{
-		"pricing":  {"harga", "biaya", "price", "cost", "berapa", "paket", "rp", "rupiah", "juta", "ribu"},
+		"pricing":  pricingKeywords,
 		"whatsapp": {"whatsapp", "wa", "api", "chat", "pesan", "message"},
 		"website":  {"website", "web", "landing", "page", "situs", "company profile", "ecommerce", "e-commerce"},
 		"seo":      {"seo", "search", "google", "optimization", "ranking"},
@@ -290,6 +669,7 @@ func filterRelevantDocuments(docs []Document, userQuery string) []Document {
 	result := make([]Document, len(scored))
 	for i, sd := range scored {
 		result[i] = sd.doc
+		decision.setScore(sd.doc, sd.score)
 	}
 
 	// Log top 3 documents for debugging
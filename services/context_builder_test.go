@@ -0,0 +1,237 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"genfity-wa-support/models"
+)
+
+func TestComposeUserMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  models.AIChatMessage
+		want string
+	}{
+		{
+			name: "plain text",
+			msg:  models.AIChatMessage{MsgType: "text", Body: "halo"},
+			want: "halo",
+		},
+		{
+			name: "quoted reply",
+			msg:  models.AIChatMessage{MsgType: "text", Body: "yang ini berapa?", QuotedBody: "Paket Business Rp 500rb"},
+			want: "(Customer membalas pesan: \"Paket Business Rp 500rb\")\nyang ini berapa?",
+		},
+		{
+			name: "reaction with known message",
+			msg:  models.AIChatMessage{MsgType: "reaction", Body: "👍", QuotedBody: "Pesanan sudah dikirim"},
+			want: "[Customer memberi reaksi 👍 pada pesan: \"Pesanan sudah dikirim\"]",
+		},
+		{
+			name: "reaction without known message",
+			msg:  models.AIChatMessage{MsgType: "reaction", Body: "❤️"},
+			want: "[Customer memberi reaksi ❤️]",
+		},
+		{
+			name: "image with caption",
+			msg:  models.AIChatMessage{MsgType: "image", Body: "ini bukti transfer"},
+			want: "[Customer mengirim gambar]\nini bukti transfer",
+		},
+		{
+			name: "document without caption",
+			msg:  models.AIChatMessage{MsgType: "document"},
+			want: "[Customer mengirim dokumen]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := composeUserMessage(tt.msg); got != tt.want {
+				t.Errorf("composeUserMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestKnowledgeLimitFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		maxDocuments *int
+		want         int
+	}{
+		{name: "unset bot, unset env", envValue: "", maxDocuments: nil, want: 10},
+		{name: "unset bot, env set", envValue: "4", maxDocuments: nil, want: 4},
+		{name: "bot overrides default", envValue: "", maxDocuments: intPtr(3), want: 3},
+		{name: "bot overrides env", envValue: "4", maxDocuments: intPtr(3), want: 3},
+		{name: "bot zero falls back to env", envValue: "4", maxDocuments: intPtr(0), want: 4},
+		{name: "bot zero falls back to default", envValue: "", maxDocuments: intPtr(0), want: 10},
+		{name: "invalid env uses default", envValue: "abc", maxDocuments: nil, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_MAX_DOCUMENTS", tt.envValue)
+			got := knowledgeLimitFor(&BotSettings{MaxDocuments: tt.maxDocuments})
+			if got != tt.want {
+				t.Errorf("knowledgeLimitFor() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembleContextRespectsPerBotDocumentLimit(t *testing.T) {
+	docs := make([]Document, 5)
+	for i := range docs {
+		docs[i] = Document{Title: fmt.Sprintf("Doc %d", i+1), Content: "isi dokumen", Kind: "faq"}
+	}
+
+	t.Setenv("AI_MAX_DOCUMENTS", "")
+
+	withOverride := AssembleContext(&BotSettings{Documents: docs, MaxDocuments: intPtr(3)}, nil, "halo")
+	if got := strings.Count(withOverride.SystemPrompt, "[faq - Doc "); got != 3 {
+		t.Errorf("per-bot limit 3: got %d documents in prompt", got)
+	}
+
+	withGlobal := AssembleContext(&BotSettings{Documents: docs}, nil, "halo")
+	if got := strings.Count(withGlobal.SystemPrompt, "[faq - Doc "); got != 5 {
+		t.Errorf("global default: got %d documents in prompt, want all 5", got)
+	}
+
+	t.Setenv("AI_MAX_DOCUMENTS", "2")
+	withEnv := AssembleContext(&BotSettings{Documents: docs}, nil, "halo")
+	if got := strings.Count(withEnv.SystemPrompt, "[faq - Doc "); got != 2 {
+		t.Errorf("AI_MAX_DOCUMENTS=2: got %d documents in prompt", got)
+	}
+}
+
+func TestAssembleContextKnowledgeBaseDisabled(t *testing.T) {
+	docs := []Document{{Title: "Harga", Content: "Paket A Rp100.000", Kind: "faq"}}
+	disabled, enabled := false, true
+
+	off := AssembleContext(&BotSettings{Documents: docs, UseKnowledgeBase: &disabled}, nil, "berapa harganya?")
+	if strings.Contains(off.SystemPrompt, "Knowledge Base") || strings.Contains(off.SystemPrompt, "Paket A") {
+		t.Errorf("useKnowledgeBase=false still injected documents:\n%s", off.SystemPrompt)
+	}
+
+	for _, bs := range []*BotSettings{{Documents: docs}, {Documents: docs, UseKnowledgeBase: &enabled}} {
+		if on := AssembleContext(bs, nil, "berapa harganya?"); !strings.Contains(on.SystemPrompt, "Paket A") {
+			t.Errorf("useKnowledgeBase=%v: documents missing from prompt", bs.UseKnowledgeBase)
+		}
+	}
+}
+
+func TestTruncateHistoryLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		limit       int
+		want        string
+		wantDropped int
+	}{
+		{"short line untouched", "halo kak", 20, "halo kak", 0},
+		{"exact length untouched", "halo", 4, "halo", 0},
+		{"cuts on word boundary", "saya mau pesan paket business", 18, "saya mau pesan...", 15},
+		{"hard cut without nearby space", "abcdefghijklmnopqrstuvwxyz", 10, "abcdefghij...", 16},
+		{"rune safe", "ééééééééé ééé", 5, "ééééé...", 8},
+		{"space right at limit", "halo kak apa kabar", 8, "halo kak...", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := truncateHistoryLine(tt.body, tt.limit, "...")
+			if got != tt.want || dropped != tt.wantDropped {
+				t.Errorf("truncateHistoryLine(%q, %d) = %q (%d dropped), want %q (%d dropped)",
+					tt.body, tt.limit, got, dropped, tt.want, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestAssembleContextHistoryLineLimit(t *testing.T) {
+	t.Setenv("AI_HISTORY_LINE_MAX_CHARS", "12")
+	t.Setenv("AI_HISTORY_TRUNCATE_SUFFIX", " [dipotong]")
+
+	history := []models.AIChatMessage{
+		{MessageID: "m1", Body: "saya mau tanya soal pengiriman ke Surabaya"},
+	}
+	ctx := AssembleContext(&BotSettings{SystemPrompt: "bot"}, history, "halo")
+
+	if !strings.Contains(ctx.SystemPrompt, "Customer: saya mau [dipotong]\n") {
+		t.Errorf("history line not truncated with configured limit/suffix:\n%s", ctx.SystemPrompt)
+	}
+
+	t.Setenv("AI_HISTORY_LINE_MAX_CHARS", "")
+	ctx = AssembleContext(&BotSettings{SystemPrompt: "bot"}, history, "halo")
+	if !strings.Contains(ctx.SystemPrompt, "Customer: saya mau tanya soal pengiriman ke Surabaya\n") {
+		t.Errorf("default limit (200) must keep the whole line:\n%s", ctx.SystemPrompt)
+	}
+}
+
+func TestAssembleContextRecordsDecision(t *testing.T) {
+	t.Setenv("AI_MAX_DOCUMENTS", "2")
+	t.Setenv("AI_HISTORY_LINE_MAX_CHARS", "10")
+	settings := &BotSettings{SystemPrompt: "bot", Documents: []Document{
+		{Title: "FAQ", Content: "jam buka toko", Kind: "faq"},
+		{Title: "Harga Paket", Content: "harga paket starter Rp 1 juta", Kind: "pricing"},
+		{Title: "Tentang", Content: "profil perusahaan", Kind: "about"},
+	}}
+	history := []models.AIChatMessage{
+		{MessageID: "m1", Body: "pesan yang cukup panjang untuk dipotong"},
+		{MessageID: "m2", Body: "ok", FromMe: true},
+	}
+
+	if ctx := AssembleContext(settings, history, "berapa harga paket?"); ctx.Decision != nil {
+		t.Fatal("decision recorded with DEBUG_CONTEXT_DECISIONS unset")
+	}
+
+	t.Setenv("DEBUG_CONTEXT_DECISIONS", "true")
+	d := AssembleContext(settings, history, "berapa harga paket?").Decision
+	if d == nil {
+		t.Fatal("no decision recorded with DEBUG_CONTEXT_DECISIONS=true")
+	}
+	if d.DocumentsAvailable != 3 || d.DocumentLimit != 2 || !d.Ranked || len(d.Documents) != 3 {
+		t.Fatalf("decision = %+v, want 3 ranked documents with limit 2", d)
+	}
+	top := d.Documents[0]
+	if top.Title != "Harga Paket" || !top.Included || top.Score == nil || *top.Score == 0 || top.Chars == 0 {
+		t.Errorf("top document = %+v, want the scored pricing doc included", top)
+	}
+	included := 0
+	for _, doc := range d.Documents {
+		if doc.Included {
+			included++
+		} else if doc.Reason != docReasonDocumentLimit {
+			t.Errorf("document %q left out for %q, want %q", doc.Title, doc.Reason, docReasonDocumentLimit)
+		}
+	}
+	if included != 2 {
+		t.Errorf("%d documents included, want 2", included)
+	}
+	if d.HistoryMessages != 2 || d.HistoryIncluded != 2 || d.HistoryTruncated != 1 {
+		t.Errorf("history = %d/%d (truncated %d), want 2/2 with 1 truncated", d.HistoryIncluded, d.HistoryMessages, d.HistoryTruncated)
+	}
+	if d.EstimatedTokens == 0 || d.BudgetLeft == 0 {
+		t.Errorf("tokens = %d, budget left = %d, want both set", d.EstimatedTokens, d.BudgetLeft)
+	}
+}
+
+func TestAssembleContextDecisionTokenBudget(t *testing.T) {
+	t.Setenv("DEBUG_CONTEXT_DECISIONS", "true")
+	t.Setenv("AI_CONTEXT_WINDOW_DEFAULT", "1500")
+	t.Setenv("AI_MAX_KB_CHARS", "100000")
+	settings := &BotSettings{SystemPrompt: "bot", Documents: []Document{
+		{Title: "Besar", Content: strings.Repeat("isi dokumen panjang ", 2000), Kind: "faq"},
+	}}
+
+	d := AssembleContext(settings, nil, "halo").Decision
+	if len(d.Documents) != 1 {
+		t.Fatalf("documents = %+v, want 1", d.Documents)
+	}
+	if doc := d.Documents[0]; doc.Included && !doc.Truncated || !doc.Included && doc.Reason != docReasonTokenBudget {
+		t.Errorf("oversized document = %+v, want it cut or left out for the token budget", doc)
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// ContextDecisionsEnabled reports whether the context builder's choices are recorded per job
+// (DEBUG_CONTEXT_DECISIONS, default false)
+func ContextDecisionsEnabled() bool {
+	return config.GetEnvBool("DEBUG_CONTEXT_DECISIONS", false)
+}
+
+// ContextDecision explains how one prompt was assembled: which documents made it in (and why the
+// others didn't), how much history was kept and how close the prompt came to the token budget
+type ContextDecision struct {
+	DocumentsAvailable int                  `json:"documents_available"`
+	DocumentLimit      int                  `json:"document_limit"`
+	Ranked             bool                 `json:"ranked"` // documents sorted by relevance score
+	Documents          []ContextDocDecision `json:"documents"`
+	HistoryMessages    int                  `json:"history_messages"`
+	HistoryIncluded    int                  `json:"history_included"`
+	HistoryTruncated   int                  `json:"history_lines_truncated"` // lines cut at AI_HISTORY_LINE_MAX_CHARS
+	EstimatedTokens    int                  `json:"estimated_tokens"`
+	BudgetLeft         int                  `json:"budget_left"`
+	Truncations        []string             `json:"truncations,omitempty"`
+
+	scores map[string]int // relevance score per document (title + kind), set when ranked
+}
+
+// ContextDocDecision is one knowledge base document considered for the prompt
+type ContextDocDecision struct {
+	Title     string `json:"title"`
+	Kind      string `json:"kind"`
+	Score     *int   `json:"score,omitempty"`
+	Included  bool   `json:"included"`
+	Chars     int    `json:"chars"` // content characters in the prompt
+	Truncated bool   `json:"truncated,omitempty"`
+	Reason    string `json:"reason,omitempty"` // why it was left out
+}
+
+// Reasons a document is left out of the prompt
+const (
+	docReasonDocumentLimit = "document_limit" // beyond AI_MAX_DOCUMENTS / maxDocuments
+	docReasonKBBudget      = "kb_char_budget" // AI_MAX_KB_CHARS used up
+	docReasonTokenBudget   = "token_budget"   // model context window used up
+)
+
+func scoreKey(doc Document) string {
+	return doc.Kind + "\x00" + doc.Title
+}
+
+// The recording helpers are no-ops on a nil decision (DEBUG_CONTEXT_DECISIONS off)
+
+func (d *ContextDecision) setScore(doc Document, score int) {
+	if d == nil {
+		return
+	}
+	if d.scores == nil {
+		d.scores = make(map[string]int)
+	}
+	d.scores[scoreKey(doc)] = score
+}
+
+func (d *ContextDecision) addDocument(doc Document, chars int, truncated bool, reason string) {
+	if d == nil {
+		return
+	}
+	entry := ContextDocDecision{Title: doc.Title, Kind: doc.Kind, Included: reason == "", Chars: chars, Truncated: truncated, Reason: reason}
+	if score, ok := d.scores[scoreKey(doc)]; ok {
+		entry.Score = &score
+	}
+	d.Documents = append(d.Documents, entry)
+}
+
+func (d *ContextDecision) truncation(format string, args ...interface{}) {
+	if d == nil {
+		return
+	}
+	d.Truncations = append(d.Truncations, fmt.Sprintf(format, args...))
+}
+
+// RecordContextDecision stores the context decision of a job's prompt in ai_context_decisions.
+// No-op unless DEBUG_CONTEXT_DECISIONS=true; failures are logged, never fail the job.
+func RecordContextDecision(jobID uint, sessionTok, messageID string, maxMessages int, ctxData *ContextData) {
+	if ctxData == nil || ctxData.Decision == nil {
+		return
+	}
+
+	payload, err := json.Marshal(ctxData.Decision)
+	if err != nil {
+		log.Printf("⚠️  [ContextDecision] Failed to encode decision for job #%d: %v", jobID, err)
+		return
+	}
+
+	record := models.AIContextDecision{
+		JobID:       jobID,
+		SessionTok:  sessionTok,
+		MessageID:   messageID,
+		MaxMessages: maxMessages,
+		Decision:    string(payload),
+		CreatedAt:   time.Now(),
+	}
+	if err := database.GetDB().Create(&record).Error; err != nil {
+		log.Printf("⚠️  [ContextDecision] Failed to record decision for job #%d: %v", jobID, err)
+	}
+}
+
+// PurgeExpiredContextDecisions deletes decisions older than the prompt dump retention
+// (DEBUG_DUMP_PROMPT_RETENTION_HOURS)
+func PurgeExpiredContextDecisions() (int64, error) {
+	result := database.GetDB().Where("created_at < ?", time.Now().Add(-promptDumpRetention())).Delete(&models.AIContextDecision{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge context decisions: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🧹 Purged %d context decisions older than %v", result.RowsAffected, promptDumpRetention())
+	}
+	return result.RowsAffected, nil
+}
@@ -33,8 +33,7 @@ func CheckCredits() (*CreditInfo, error) {
 	req, _ := http.NewRequest("GET", "https://openrouter.ai/api/v1/auth/key", nil)
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := DoHTTP(req, 10*time.Second)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +79,8 @@ func MonitorCredits() {
 			remaining := *info.Data.LimitRemaining
 			if remaining < 1.0 {
 				log.Printf("🔴 [CreditMonitor] CRITICAL: Low credits! Remaining: $%.2f", remaining)
-				// TODO: Send alert (email, Slack, webhook, etc.)
+				SendAlert("credits_low", fmt.Sprintf("OpenRouter credits critically low: $%.2f remaining", remaining),
+					map[string]interface{}{"remaining": remaining})
 			} else if remaining < 5.0 {
 				log.Printf("🟡 [CreditMonitor] WARNING: Credits running low. Remaining: $%.2f", remaining)
 			}
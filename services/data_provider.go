@@ -1,6 +1,7 @@
 package services
 
 import (
+	"fmt"
 	"log"
 	"os"
 )
@@ -31,6 +32,13 @@ type UsageLogRequest struct {
 	LatencyMs    int
 	Status       string
 	ErrorReason  string
+	DedupeKey    string // see UsageDedupeKey; empty = no dedupe
+}
+
+// UsageDedupeKey identifies the usage of one job attempt, so a retried log write or a job
+// processed twice for the same attempt is billed once
+func UsageDedupeKey(jobID uint, attempt int) string {
+	return fmt.Sprintf("job:%d:%d", jobID, attempt)
 }
 
 // GetDataProvider returns appropriate data provider based on env config
@@ -47,6 +55,11 @@ func GetDataProvider() (DataProvider, error) {
 		return NewDBProvider()
 	}
 
+	if mode == "file" {
+		log.Println("📁 Using FILE data mode (local development)")
+		return NewFileProvider()
+	}
+
 	// Default to API mode
 	log.Println("🌐 Using API access mode")
 	return NewAPIProvider(), nil
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+)
+
+// Defaults for age-based retention of the support DB tables (override via .env, 0 = keep forever)
+const (
+	defaultAIHistoryMaxAgeDays  = 30 // AI_HISTORY_MAX_AGE_DAYS: ai_chat_messages
+	defaultSendLogRetentionDays = 90 // AI_SEND_LOG_RETENTION_DAYS: message_send_logs
+	defaultJobRetentionDays     = 30 // AI_JOB_RETENTION_DAYS: done/failed ai_jobs with their attempts
+)
+
+// retentionDays returns the retention of key in days; 0 disables it, a negative value uses fallback
+func retentionDays(key string, fallback int) int {
+	days := config.GetEnvInt(key, fallback)
+	if days < 0 {
+		return fallback
+	}
+	return days
+}
+
+// cutoffFor returns the time before which rows expire, or false when retention is disabled
+func cutoffFor(days int) (time.Time, bool) {
+	if days == 0 {
+		return time.Time{}, false
+	}
+	return time.Now().AddDate(0, 0, -days), true
+}
+
+// PurgeExpiredAIChatMessages deletes AI context messages older than AI_HISTORY_MAX_AGE_DAYS. The
+// per-contact cap (MaxMessagesPerContact) only runs when a contact writes, so without this an
+// inactive contact's messages stay forever and come back as stale context if they return.
+func PurgeExpiredAIChatMessages() (int64, error) {
+	days := retentionDays("AI_HISTORY_MAX_AGE_DAYS", defaultAIHistoryMaxAgeDays)
+	cutoff, ok := cutoffFor(days)
+	if !ok {
+		return 0, nil
+	}
+	result := database.GetDB().Where("timestamp < ?", cutoff).Delete(&models.AIChatMessage{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge AI chat messages: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🧹 Purged %d AI chat messages older than %d days", result.RowsAffected, days)
+	}
+	return result.RowsAffected, nil
+}
+
+// PurgeExpiredSendLogs deletes send log entries older than AI_SEND_LOG_RETENTION_DAYS
+func PurgeExpiredSendLogs() (int64, error) {
+	days := retentionDays("AI_SEND_LOG_RETENTION_DAYS", defaultSendLogRetentionDays)
+	cutoff, ok := cutoffFor(days)
+	if !ok {
+		return 0, nil
+	}
+	result := database.GetDB().Where("created_at < ?", cutoff).Delete(&models.MessageSendLog{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge send logs: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🧹 Purged %d send log entries older than %d days", result.RowsAffected, days)
+	}
+	return result.RowsAffected, nil
+}
+
+// PurgeExpiredJobs deletes done and failed AI jobs last updated before AI_JOB_RETENTION_DAYS,
+// together with their attempts. Pending and processing jobs are never touched.
+func PurgeExpiredJobs() (int64, error) {
+	days := retentionDays("AI_JOB_RETENTION_DAYS", defaultJobRetentionDays)
+	cutoff, ok := cutoffFor(days)
+	if !ok {
+		return 0, nil
+	}
+
+	var purged int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&models.AIJob{}).Select("id").
+			Where("status IN ? AND updated_at < ?", []string{"done", "failed"}, cutoff)
+		if err := tx.Where("job_id IN (?)", expired).Delete(&models.AIJobAttempt{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("status IN ? AND updated_at < ?", []string{"done", "failed"}, cutoff).Delete(&models.AIJob{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge AI jobs: %w", err)
+	}
+	if purged > 0 {
+		log.Printf("🧹 Purged %d finished AI jobs older than %d days", purged, days)
+	}
+	return purged, nil
+}
+
+// RunDataRetention applies the age-based retention of AI chat messages, send logs and finished
+// jobs every hour until stop is closed
+func RunDataRetention(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		for _, purge := range []func() (int64, error){PurgeExpiredAIChatMessages, PurgeExpiredSendLogs, PurgeExpiredJobs} {
+			if _, err := purge(); err != nil {
+				log.Printf("⚠️  %v", err)
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestRetentionDays(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 30},
+		{"7", 7},
+		{"0", 0}, // disabled
+		{"-3", 30},
+		{"abc", 30},
+	}
+	for _, tt := range tests {
+		t.Setenv("AI_HISTORY_MAX_AGE_DAYS", tt.env)
+		if got := retentionDays("AI_HISTORY_MAX_AGE_DAYS", defaultAIHistoryMaxAgeDays); got != tt.want {
+			t.Errorf("AI_HISTORY_MAX_AGE_DAYS=%q: days = %d, want %d", tt.env, got, tt.want)
+		}
+	}
+	if _, ok := cutoffFor(0); ok {
+		t.Error("0 days must disable the retention")
+	}
+}
+
+func TestPurgeExpiredSupportData(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.MessageSendLog{}, &models.AIJob{}, &models.AIJobAttempt{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.MessageSendLog{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIJob{})
+	})
+
+	old := time.Now().AddDate(0, 0, -40)
+	contact := "6281234567890@s.whatsapp.net"
+	messages := []models.AIChatMessage{
+		{MessageID: sessionTok + "_old", SessionTok: sessionTok, From: contact, To: "bot", Body: "lama", Timestamp: old},
+		{MessageID: sessionTok + "_new", SessionTok: sessionTok, From: contact, To: "bot", Body: "baru", Timestamp: time.Now()},
+	}
+	if err := db.Create(&messages).Error; err != nil {
+		t.Fatalf("failed to create messages: %v", err)
+	}
+	sendLogs := []models.MessageSendLog{
+		{SessionTok: sessionTok, To: contact, CreatedAt: time.Now().AddDate(0, 0, -100)},
+		{SessionTok: sessionTok, To: contact},
+	}
+	if err := db.Create(&sendLogs).Error; err != nil {
+		t.Fatalf("failed to create send logs: %v", err)
+	}
+	jobs := []models.AIJob{
+		{Status: "done", SessionTok: sessionTok, MessageID: "m1", UserID: "u"},
+		{Status: "failed", SessionTok: sessionTok, MessageID: "m2", UserID: "u"},
+		{Status: "pending", SessionTok: sessionTok, MessageID: "m3", UserID: "u"},
+		{Status: "done", SessionTok: sessionTok, MessageID: "m4", UserID: "u"},
+	}
+	if err := db.Create(&jobs).Error; err != nil {
+		t.Fatalf("failed to create jobs: %v", err)
+	}
+	// The first three are stale (UpdateColumn keeps updated_at as set)
+	db.Model(&models.AIJob{}).Where("id IN ?", []uint{jobs[0].ID, jobs[1].ID, jobs[2].ID}).UpdateColumn("updated_at", old)
+	if err := db.Create(&models.AIJobAttempt{JobID: jobs[0].ID, Status: "ok"}).Error; err != nil {
+		t.Fatalf("failed to create attempt: %v", err)
+	}
+
+	for _, purge := range []func() (int64, error){PurgeExpiredAIChatMessages, PurgeExpiredSendLogs, PurgeExpiredJobs} {
+		if _, err := purge(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int64
+	db.Model(&models.AIChatMessage{}).Where("session_tok = ?", sessionTok).Count(&count)
+	if count != 1 {
+		t.Errorf("%d AI chat messages left, want only the recent one", count)
+	}
+	db.Model(&models.MessageSendLog{}).Where("session_tok = ?", sessionTok).Count(&count)
+	if count != 1 {
+		t.Errorf("%d send logs left, want only the recent one", count)
+	}
+	var remaining []models.AIJob
+	db.Where("session_tok = ?", sessionTok).Order("id").Find(&remaining)
+	if len(remaining) != 2 || remaining[0].MessageID != "m3" || remaining[1].MessageID != "m4" {
+		t.Errorf("remaining jobs = %+v, want the stale pending job and the recent done one", remaining)
+	}
+	db.Model(&models.AIJobAttempt{}).Where("job_id = ?", jobs[0].ID).Count(&count)
+	if count != 0 {
+		t.Errorf("attempts of a purged job were kept (%d)", count)
+	}
+}
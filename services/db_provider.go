@@ -1,14 +1,18 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"genfity-wa-support/database"
 	"genfity-wa-support/models"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // DBProvider implements DataProvider via direct DB access
@@ -33,35 +37,128 @@ func NewDBProvider() (*DBProvider, error) {
 	return provider, nil
 }
 
-// verifyTablesExist checks if all required Prisma tables exist
-// Does NOT create tables - that's Prisma's job!
+// requiredTables are the Prisma tables direct DB mode reads and writes
+var requiredTables = []string{
+	"User",
+	"WhatsAppSession",
+	"ServicesWhatsappCustomers",
+	"WhatsAppAIBot",
+	"AIDocument",
+	"AIUsageLog",
+	"AIBotSessionBinding",
+	"BotKnowledgeBinding",
+}
+
+// criticalColumns are columns the provider's queries name explicitly - a Prisma schema that
+// drifted from them only fails later, on the first message. WhatsAppSession is checked against
+// its whole model.
+var criticalColumns = map[string][]string{
+	"ServicesWhatsappCustomers": {"customerId", "packageId", "status", "expiredAt"},
+	"WhatsAppAIBot":             {"id", "userId", "isActive"},
+	"AIDocument":                {"id", "userId", "title", "kind", "content", "isActive"},
+	"AIUsageLog":                {"userId", "inputTokens", "outputTokens", "latencyMs", "status", "dedupeKey", "createdAt"},
+	"AIBotSessionBinding":       {"sessionId", "botId", "isActive"},
+	"BotKnowledgeBinding":       {"botId", "documentId", "isActive"},
+}
+
+// schemaInspector is the part of the DB the startup check needs (faked in tests)
+type schemaInspector interface {
+	HasTable(table string) bool
+	MissingColumns(table string, columns []string) ([]string, error)
+}
+
+type gormSchemaInspector struct {
+	db *gorm.DB
+}
+
+func (i gormSchemaInspector) HasTable(table string) bool {
+	return i.db.Migrator().HasTable(table)
+}
+
+func (i gormSchemaInspector) MissingColumns(table string, columns []string) ([]string, error) {
+	return database.MissingColumns(i.db, table, columns)
+}
+
+// verifyTablesExist checks every required Prisma table and critical column and reports all
+// problems in one error, so a half-migrated database fails at startup instead of on first use
 func (p *DBProvider) verifyTablesExist() error {
 	db := database.GetTransactionalDB()
 	if db == nil {
 		return fmt.Errorf("transactional DB connection not initialized")
 	}
 
-	requiredTables := []string{
-		"User",
-		"WhatsAppSession",
-		"ServicesWhatsappCustomers",
-		"WhatsAppAIBot",
-		"AIDocument",
-		"AIUsageLog",
-		"AIBotSessionBinding",
-		"BotKnowledgeBinding",
+	// Session lookups go through WhatsAppSession - its column tags must match Prisma exactly
+	sessionColumns, err := database.ModelColumns(db, &models.WhatsappSession{})
+	if err != nil {
+		return fmt.Errorf("failed to read WhatsAppSession model columns: %w", err)
+	}
+	requiredSessionColumns, _ := database.SplitOptionalColumns(sessionColumns, models.WhatsappSessionOptionalColumns)
+	columns := map[string][]string{"WhatsAppSession": requiredSessionColumns}
+	for table, cols := range criticalColumns {
+		columns[table] = cols
+	}
+
+	inspector := gormSchemaInspector{db: db}
+	if err := verifySchema(inspector, requiredTables, columns); err != nil {
+		return err
+	}
+	warnMissingOptionalColumns(inspector, "WhatsAppSession", models.WhatsappSessionOptionalColumns)
+	return nil
+}
+
+// warnMissingOptionalColumns logs optional columns the schema doesn't have yet (their features are off)
+func warnMissingOptionalColumns(inspector schemaInspector, table string, columns []string) {
+	missing, err := inspector.MissingColumns(table, columns)
+	if err != nil {
+		log.Printf("⚠️  Could not check optional %s columns: %v", table, err)
+		return
+	}
+	for _, column := range missing {
+		log.Printf("⚠️  Optional column %s.%s not found - its feature is off until the Prisma schema is migrated", table, column)
 	}
+}
 
-	for _, table := range requiredTables {
-		if !db.Migrator().HasTable(table) {
-			return fmt.Errorf(
-				"table '%s' not found. Please run Prisma migration first: npx prisma migrate deploy",
-				table,
-			)
+// verifySchema checks tables and their columns, logging every problem and returning them
+// together with the migration command to run
+func verifySchema(inspector schemaInspector, tables []string, columns map[string][]string) error {
+	var missingTables, missingColumns []string
+	for _, table := range tables {
+		if !inspector.HasTable(table) {
+			missingTables = append(missingTables, table)
+			continue
+		}
+		if len(columns[table]) == 0 {
+			continue
+		}
+		missing, err := inspector.MissingColumns(table, columns[table])
+		if err != nil {
+			return fmt.Errorf("failed to verify %s columns: %w", table, err)
+		}
+		for _, column := range missing {
+			missingColumns = append(missingColumns, table+"."+column)
 		}
 	}
+	if len(missingTables) == 0 && len(missingColumns) == 0 {
+		return nil
+	}
 
-	return nil
+	var problems []string
+	if len(missingTables) > 0 {
+		problems = append(problems, "missing tables: "+strings.Join(missingTables, ", "))
+	}
+	if len(missingColumns) > 0 {
+		problems = append(problems, "missing columns: "+strings.Join(missingColumns, ", "))
+	}
+	log.Printf("❌ Direct DB schema check failed:")
+	for _, table := range missingTables {
+		log.Printf("   - table %s not found", table)
+	}
+	for _, column := range missingColumns {
+		log.Printf("   - column %s not found", column)
+	}
+	log.Printf("👉 Run the Prisma migrations against the transactional database: npx prisma migrate deploy")
+
+	return fmt.Errorf("database schema out of date (%s) - run: npx prisma migrate deploy", strings.Join(problems, "; "))
 }
 
 // ResolveSession resolves WhatsApp session token to user info via direct DB
@@ -116,9 +213,16 @@ func (p *DBProvider) ResolveSession(instanceName string) (*SessionInfo, error) {
 	err = db.Where(`"customerId" = ? AND "status" = ? AND "expiredAt" > ?`,
 		*session.UserID, "active", time.Now()).
 		First(&subscription).Error
+	packageName := ""
 	if err == nil {
 		subscriptionActive = true
 		log.Printf("✓ Subscription active: expires=%s", subscription.ExpiredAt)
+
+		// Package name decides AI job priority (missing package = default priority)
+		var pkg models.WhatsappApiPackage
+		if err := db.Where(`"id" = ?`, subscription.PackageID).First(&pkg).Error; err == nil {
+			packageName = pkg.Name
+		}
 	} else {
 		log.Printf("❌ No active subscription found: %v", err)
 	}
@@ -128,6 +232,9 @@ func (p *DBProvider) ResolveSession(instanceName string) (*SessionInfo, error) {
 		BotActive:          botActive,
 		SubscriptionActive: subscriptionActive,
 		SessionToken:       session.Token,
+		PackageName:        packageName,
+		ForwardURL:         derefString(session.ForwardWebhookURL),
+		ForwardSecret:      derefString(session.ForwardWebhookSecret),
 	}, nil
 }
 
@@ -145,14 +252,17 @@ func (p *DBProvider) GetBotSettings(userID, sessionToken string) (*BotSettings,
 		return nil, fmt.Errorf("bot not found or inactive: %w", err)
 	}
 
-	// Get ONLY documents bound to this bot via BotKnowledgeBinding (many-to-many)
+	// Get ONLY documents bound to this bot via BotKnowledgeBinding (many-to-many).
+	// Bots with useKnowledgeBase = false never touch AIDocument.
 	var dbDocs []models.AIDocument
 	query := `
 		SELECT d.* FROM "AIDocument" d
 		INNER JOIN "BotKnowledgeBinding" b ON d.id = b."documentId"
 		WHERE b."botId" = ? AND b."isActive" = true AND d."isActive" = true
 	`
-	if err := db.Raw(query, bot.ID).Scan(&dbDocs).Error; err != nil {
+	if bot.UseKnowledgeBase != nil && !*bot.UseKnowledgeBase {
+		dbDocs = []models.AIDocument{}
+	} else if err := db.Raw(query, bot.ID).Scan(&dbDocs).Error; err != nil {
 		log.Printf("⚠️  Failed to fetch bound documents for bot %s: %v", bot.ID, err)
 		// Return empty documents instead of error (bot might not have knowledge yet)
 		dbDocs = []models.AIDocument{}
@@ -182,13 +292,101 @@ func (p *DBProvider) GetBotSettings(userID, sessionToken string) (*BotSettings,
 		fallbackText = *bot.FallbackText
 	}
 
+	var typeHandling map[string]string
+	if bot.MessageTypeHandling != nil && *bot.MessageTypeHandling != "" {
+		if err := json.Unmarshal([]byte(*bot.MessageTypeHandling), &typeHandling); err != nil {
+			log.Printf("⚠️  Invalid messageTypeHandling for bot %s, using defaults: %v", bot.ID, err)
+		}
+	}
+
+	var businessHours *BusinessHours
+	if bot.BusinessHours != nil && *bot.BusinessHours != "" {
+		parsed, err := ParseBusinessHours(*bot.BusinessHours)
+		if err != nil {
+			log.Printf("⚠️  Invalid businessHours for bot %s, answering around the clock: %v", bot.ID, err)
+		} else {
+			businessHours = parsed
+		}
+	}
+	afterHoursMessage := ""
+	if bot.AfterHoursMessage != nil {
+		afterHoursMessage = *bot.AfterHoursMessage
+	}
+
+	greetingMessage := ""
+	if bot.GreetingMessage != nil {
+		greetingMessage = *bot.GreetingMessage
+	}
+
+	contactFilter, err := contactFilterFromBot(&bot)
+	if err != nil {
+		log.Printf("⚠️  Invalid contact filter for bot %s, answering everyone: %v", bot.ID, err)
+	}
+
+	var escalationContacts []EscalationContact
+	if bot.EscalationContacts != nil {
+		escalationContacts, err = ParseEscalationContacts(*bot.EscalationContacts)
+		if err != nil {
+			log.Printf("⚠️  Invalid escalationContacts for bot %s, handoffs won't notify anyone: %v", bot.ID, err)
+		}
+	}
+
 	return &BotSettings{
-		SystemPrompt: systemPrompt,
-		FallbackText: fallbackText,
-		Documents:    documents,
+		SystemPrompt:              systemPrompt,
+		FallbackText:              fallbackText,
+		Documents:                 documents,
+		MaxDocuments:              bot.MaxDocuments,
+		UseKnowledgeBase:          bot.UseKnowledgeBase,
+		MessageTypeHandling:       typeHandling,
+		AllowImageSend:            bot.AllowImageSend != nil && *bot.AllowImageSend,
+		AllowLocationSend:         bot.AllowLocationSend != nil && *bot.AllowLocationSend,
+		AllowContactSend:          bot.AllowContactSend != nil && *bot.AllowContactSend,
+		BusinessHours:             businessHours,
+		AfterHoursMessage:         afterHoursMessage,
+		GreetingMessage:           greetingMessage,
+		AutoRead:                  bot.AutoRead,
+		ContactFilter:             contactFilter,
+		EscalationContacts:        escalationContacts,
+		MaxDailyRepliesPerContact: bot.MaxDailyRepliesPerContact,
+		AIProvider:                derefString(bot.AIProvider),
+		AIModel:                   derefString(bot.AIModel),
+		AIAPIKey:                  derefString(bot.AIAPIKey),
+		ResponseLanguage:          derefString(bot.ResponseLanguage),
 	}, nil
 }
 
+// derefString returns the value of an optional column ("" when null)
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// contactFilterFromBot builds the bot's ContactFilter (nil when no mode is set)
+func contactFilterFromBot(bot *models.WhatsAppAIBot) (*ContactFilter, error) {
+	if bot.ContactFilterMode == nil || *bot.ContactFilterMode == ContactFilterOff {
+		return nil, nil
+	}
+	mode := *bot.ContactFilterMode
+	if !ValidContactFilterMode(mode) {
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
+	filter := &ContactFilter{Mode: mode}
+	var err error
+	if bot.AllowedContacts != nil {
+		if filter.Allowed, err = ParseContactList(*bot.AllowedContacts); err != nil {
+			return nil, err
+		}
+	}
+	if bot.BlockedContacts != nil {
+		if filter.Blocked, err = ParseContactList(*bot.BlockedContacts); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
 // LogUsage saves AI usage metrics via direct DB
 func (p *DBProvider) LogUsage(logReq *UsageLogRequest) error {
 	if !p.tablesVerified {
@@ -219,8 +417,17 @@ func (p *DBProvider) LogUsage(logReq *UsageLogRequest) error {
 		usageLog.ErrorReason = &logReq.ErrorReason
 	}
 
-	if err := db.Create(&usageLog).Error; err != nil {
-		return fmt.Errorf("failed to save usage log: %w", err)
+	if logReq.DedupeKey != "" {
+		usageLog.DedupeKey = &logReq.DedupeKey
+	}
+
+	// Unique dedupeKey: a second write for the same job attempt is a no-op
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&usageLog)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save usage log: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		log.Printf("♻️  Usage for %s already logged, skipped", logReq.DedupeKey)
 	}
 
 	return nil
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type fakeSchema map[string][]string // table -> columns
+
+func (f fakeSchema) HasTable(table string) bool {
+	_, ok := f[table]
+	return ok
+}
+
+func (f fakeSchema) MissingColumns(table string, columns []string) ([]string, error) {
+	have := make(map[string]bool)
+	for _, c := range f[table] {
+		have[c] = true
+	}
+	var missing []string
+	for _, c := range columns {
+		if !have[c] {
+			missing = append(missing, c)
+		}
+	}
+	return missing, nil
+}
+
+func TestVerifySchemaReportsEveryProblem(t *testing.T) {
+	tables := []string{"WhatsAppSession", "ServicesWhatsappCustomers", "AIDocument", "AIUsageLog"}
+	columns := map[string][]string{
+		"WhatsAppSession":           {"id", "token"},
+		"ServicesWhatsappCustomers": {"customerId", "status"},
+	}
+
+	complete := fakeSchema{
+		"WhatsAppSession":           {"id", "token", "userId"},
+		"ServicesWhatsappCustomers": {"id", "customerId", "status"},
+		"AIDocument":                {},
+		"AIUsageLog":                {},
+	}
+	if err := verifySchema(complete, tables, columns); err != nil {
+		t.Fatalf("complete schema: %v", err)
+	}
+
+	drifted := fakeSchema{
+		"WhatsAppSession":           {"id"},
+		"ServicesWhatsappCustomers": {"id", "customerid", "status"}, // unquoted DDL lowercases
+	}
+	err := verifySchema(drifted, tables, columns)
+	if err == nil {
+		t.Fatal("drifted schema accepted")
+	}
+	for _, want := range []string{"AIDocument", "AIUsageLog", "WhatsAppSession.token", "ServicesWhatsappCustomers.customerId", "npx prisma migrate deploy"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestCriticalColumnsCoverRequiredTables(t *testing.T) {
+	required := make(map[string]bool)
+	for _, table := range requiredTables {
+		required[table] = true
+	}
+	for table := range criticalColumns {
+		if !required[table] {
+			t.Errorf("criticalColumns lists %s, which is not a required table (never checked)", table)
+		}
+	}
+}
+
+func TestDBProviderLogUsageIsIdempotent(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set - skipping database test")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AIUsageLog{}); err != nil {
+		t.Fatalf("failed to migrate test tables: %v", err)
+	}
+	previous := database.TransactionalDB
+	database.TransactionalDB = db
+	t.Cleanup(func() { database.TransactionalDB = previous })
+
+	userID := fmt.Sprintf("usage-user-%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Where(`"userId" = ?`, userID).Delete(&models.AIUsageLog{}) })
+
+	provider := &DBProvider{tablesVerified: true}
+	req := &UsageLogRequest{UserID: userID, InputTokens: 100, OutputTokens: 20, TotalTokens: 120, Status: "ok", DedupeKey: UsageDedupeKey(42, 1)}
+	for i := 0; i < 2; i++ {
+		if err := provider.LogUsage(req); err != nil {
+			t.Fatalf("LogUsage #%d: %v", i+1, err)
+		}
+	}
+	var count int64
+	db.Model(&models.AIUsageLog{}).Where(`"userId" = ?`, userID).Count(&count)
+	if count != 1 {
+		t.Fatalf("same job attempt logged %d times, want 1", count)
+	}
+
+	// The job's next attempt is billed separately
+	req.DedupeKey = UsageDedupeKey(42, 2)
+	if err := provider.LogUsage(req); err != nil {
+		t.Fatalf("LogUsage for attempt 2: %v", err)
+	}
+	db.Model(&models.AIUsageLog{}).Where(`"userId" = ?`, userID).Count(&count)
+	if count != 2 {
+		t.Errorf("two attempts logged as %d rows, want 2", count)
+	}
+}
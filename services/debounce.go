@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DebounceWindow returns AI_DEBOUNCE_MS: how long a new job waits for more messages from the
+// same contact before it is processed (0 = off, every message is answered on its own)
+func DebounceWindow() time.Duration {
+	ms := config.GetEnvInt("AI_DEBOUNCE_MS", 0)
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// debounceMaxWait returns AI_DEBOUNCE_MAX_MS (default 4x the window): a contact who keeps typing
+// still gets an answer this long after their first message
+func debounceMaxWait(window time.Duration) time.Duration {
+	ms := config.GetEnvInt("AI_DEBOUNCE_MAX_MS", 0)
+	if ms <= 0 {
+		return 4 * window
+	}
+	return max(time.Duration(ms)*time.Millisecond, window)
+}
+
+// JobMessageIDs returns every message a job answers, oldest first: the messages merged into it
+// during the debounce window, then job.MessageID (the latest)
+func JobMessageIDs(job *models.AIJob) []string {
+	var ids []string
+	for _, id := range strings.Split(job.MergedMessageIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return append(ids, job.MessageID)
+}
+
+// MergeIntoPendingJob folds a new message into the contact's job that is still waiting out its
+// debounce window, so a thought sent as several short messages gets one combined reply. The job
+// then points at the new message and waits another window (capped by AI_DEBOUNCE_MAX_MS from its
+// creation). Returns false when there is no such job - the caller enqueues a new one.
+func MergeIntoPendingJob(sessionToken, contact, messageID, body string, priority int, window time.Duration) (*models.AIJob, bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, false, fmt.Errorf("database not initialized")
+	}
+
+	now := time.Now()
+	var job models.AIJob
+	merged := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED: a job the worker is claiming right now is left alone (the message gets its own job)
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("session_tok = ? AND contact = ? AND status = ? AND next_run_at > ? AND created_at > ?",
+				sessionToken, contact, "pending", now, now.Add(-debounceMaxWait(window))).
+			Order("id DESC").
+			First(&job).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		nextRun := now.Add(window)
+		if deadline := job.CreatedAt.Add(debounceMaxWait(window)); nextRun.After(deadline) {
+			nextRun = deadline
+		}
+		mergedIDs := strings.Join(JobMessageIDs(&job), ",")
+		updates := map[string]interface{}{
+			"message_id":         messageID,
+			"merged_message_ids": mergedIDs,
+			"input_json":         job.InputJSON + "\n" + body,
+			"priority":           min(job.Priority, priority),
+			"next_run_at":        nextRun,
+			"updated_at":         now,
+		}
+		if err := tx.Model(&job).Updates(updates).Error; err != nil {
+			return err
+		}
+		merged = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to merge message into pending job: %w", err)
+	}
+	if !merged {
+		return nil, false, nil
+	}
+	return &job, true, nil
+}
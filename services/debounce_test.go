@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestJobMessageIDs(t *testing.T) {
+	job := &models.AIJob{MessageID: "m3", MergedMessageIDs: "m1, m2"}
+	if got := fmt.Sprint(JobMessageIDs(job)); got != "[m1 m2 m3]" {
+		t.Errorf("JobMessageIDs = %s, want [m1 m2 m3]", got)
+	}
+	if got := fmt.Sprint(JobMessageIDs(&models.AIJob{MessageID: "m1"})); got != "[m1]" {
+		t.Errorf("JobMessageIDs without merges = %s, want [m1]", got)
+	}
+}
+
+func TestDebounceWindow(t *testing.T) {
+	t.Setenv("AI_DEBOUNCE_MS", "")
+	if got := DebounceWindow(); got != 0 {
+		t.Errorf("default window = %v, want 0 (off)", got)
+	}
+	t.Setenv("AI_DEBOUNCE_MS", "1500")
+	if got := DebounceWindow(); got != 1500*time.Millisecond {
+		t.Errorf("window = %v, want 1.5s", got)
+	}
+	t.Setenv("AI_DEBOUNCE_MAX_MS", "")
+	if got := debounceMaxWait(DebounceWindow()); got != 6*time.Second {
+		t.Errorf("max wait = %v, want 4x window", got)
+	}
+}
+
+func TestMergeIntoPendingJob(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.AIJob{}); err != nil {
+		t.Fatalf("failed to migrate ai_jobs: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.AIJob{}) })
+
+	window := 2 * time.Second
+	runAt := time.Now().Add(window)
+	job := models.AIJob{Status: "pending", Priority: 5, SessionTok: sessionTok, MessageID: "m1",
+		UserID: "test-user", Contact: "628111", InputJSON: "hi", NextRunAt: &runAt}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+
+	for i, body := range []string{"I want", "a website, urgent"} {
+		priority := 5
+		if i == 1 {
+			priority = 1
+		}
+		merged, ok, err := MergeIntoPendingJob(sessionTok, "628111", fmt.Sprintf("m%d", i+2), body, priority, window)
+		if err != nil || !ok {
+			t.Fatalf("merge %d: ok=%v err=%v", i, ok, err)
+		}
+		if merged.ID != job.ID {
+			t.Fatalf("merged into job #%d, want #%d", merged.ID, job.ID)
+		}
+	}
+
+	var result models.AIJob
+	db.First(&result, job.ID)
+	if got := strings.Join(JobMessageIDs(&result), ","); got != "m1,m2,m3" {
+		t.Errorf("job messages = %s, want m1,m2,m3", got)
+	}
+	if result.InputJSON != "hi\nI want\na website, urgent" {
+		t.Errorf("input = %q", result.InputJSON)
+	}
+	if result.Priority != 1 {
+		t.Errorf("priority = %d, want 1 (most urgent merged message)", result.Priority)
+	}
+
+	// Another contact's message is never merged
+	if _, ok, _ := MergeIntoPendingJob(sessionTok, "628222", "x1", "halo", 5, window); ok {
+		t.Errorf("message from another contact was merged")
+	}
+
+	// Once the window has passed the job may be claimed any moment - no more merging
+	past := time.Now().Add(-time.Second)
+	db.Model(&models.AIJob{}).Where("id = ?", job.ID).Update("next_run_at", past)
+	if _, ok, _ := MergeIntoPendingJob(sessionTok, "628111", "m4", "how much?", 5, window); ok {
+		t.Errorf("message merged into a job whose window has passed")
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// DryRun reports whether outgoing WhatsApp calls are recorded instead of sent (DRY_RUN, default
+// false) - for integration tests and demos without a live WA server
+func DryRun() bool {
+	return config.GetEnvBool("DRY_RUN", false)
+}
+
+// RecordDryRunSend logs and stores a call that DRY_RUN kept from reaching WhatsApp as a
+// MessageSendLog with status "dryrun". action is the WA server call ("chat/send/text",
+// "chat/presence", ...); body what would have been sent. Returns a fake message ID.
+func RecordDryRunSend(sessionTok, to, action, body string) string {
+	messageID := fmt.Sprintf("dryrun_%d", time.Now().UnixNano())
+	log.Printf("🧪 [DRY_RUN] %s to %s: %s", action, to, PreviewText(body, 120))
+
+	db := database.GetDB()
+	if db == nil {
+		return messageID
+	}
+	entry := models.MessageSendLog{
+		SessionTok:  sessionTok,
+		To:          to,
+		Body:        "[" + action + "] " + body,
+		Status:      SendLogDryRun,
+		WAMessageID: messageID,
+	}
+	if err := RecordSendLog("", &entry); err != nil {
+		log.Printf("⚠️  [DRY_RUN] Failed to record %s: %v", action, err)
+	}
+	return messageID
+}
+
+// ListDryRunSends returns recorded dry-run calls, newest first, optionally for one session
+func ListDryRunSends(sessionTok string, limit int) ([]models.MessageSendLog, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	query := db.Where("status = ?", SendLogDryRun)
+	if sessionTok = strings.TrimSpace(sessionTok); sessionTok != "" {
+		query = query.Where("session_tok = ?", sessionTok)
+	}
+	var sends []models.MessageSendLog
+	if err := query.Order("created_at DESC").Limit(limit).Find(&sends).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dry-run sends: %w", err)
+	}
+	return sends, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDryRunRecordsInsteadOfSending(t *testing.T) {
+	sessionTok := setupSendLogTestDB(t)
+	waServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run reached the WA server: %s %s", r.Method, r.URL.Path)
+	}))
+	defer waServer.Close()
+	t.Setenv("WA_SERVER_URL", waServer.URL)
+	t.Setenv("DRY_RUN", "true")
+
+	messageID, err := SendWAText(sessionTok, "6281200000001@s.whatsapp.net", "Halo kak")
+	if err != nil || !strings.HasPrefix(messageID, "dryrun_") {
+		t.Fatalf("SendWAText = %q, %v; want a dryrun_ ID", messageID, err)
+	}
+	if err := SetTypingState(sessionTok, "6281200000001", "composing"); err != nil {
+		t.Errorf("SetTypingState: %v", err)
+	}
+	if err := MarkMessagesAsRead(sessionTok, []string{"3EB0A", "3EB0B"}, "6281200000001"); err != nil {
+		t.Errorf("MarkMessagesAsRead: %v", err)
+	}
+
+	sends, err := ListDryRunSends(sessionTok, 10)
+	if err != nil {
+		t.Fatalf("ListDryRunSends: %v", err)
+	}
+	if len(sends) != 3 {
+		t.Fatalf("recorded %d dry-run sends, want 3", len(sends))
+	}
+	// Newest first
+	if !strings.HasPrefix(sends[2].Body, "[chat/send/text] Halo kak") || !strings.HasPrefix(sends[0].Body, "[chat/markread] 3EB0A,3EB0B") {
+		t.Errorf("recorded bodies = %q, %q", sends[2].Body, sends[0].Body)
+	}
+	for _, s := range sends {
+		if s.Status != SendLogDryRun {
+			t.Errorf("status = %q, want %q", s.Status, SendLogDryRun)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+)
+
+// EscalationContact is an agent notified when a conversation is handed off to a human:
+// a WhatsApp number (messaged from the bot's own session) and/or a webhook URL
+type EscalationContact struct {
+	Name       string `json:"name,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
+
+// Escalation describes a handed-off conversation; it is also the webhook payload
+type Escalation struct {
+	SessionToken string    `json:"sessionToken"`
+	Contact      string    `json:"contact"` // customer's phone number (digits)
+	ContactName  string    `json:"contactName,omitempty"`
+	Reason       string    `json:"reason"` // e.g. "message_type:image"
+	Message      string    `json:"message"`
+	Summary      []string  `json:"summary,omitempty"` // recent conversation, oldest first
+	ChatURL      string    `json:"chatUrl,omitempty"`
+	EscalatedAt  time.Time `json:"escalatedAt"`
+}
+
+// Swappable in tests
+var (
+	sendEscalationWA      = SendWAText
+	postEscalationWebhook = postEscalationJSON
+)
+
+// ParseEscalationContacts decodes WhatsAppAIBot.escalationContacts, a JSON array of
+// {"name","phone","webhookUrl"}; every entry needs a phone number or an http(s) webhook URL
+func ParseEscalationContacts(raw string) ([]EscalationContact, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var contacts []EscalationContact
+	if err := json.Unmarshal([]byte(raw), &contacts); err != nil {
+		return nil, fmt.Errorf("invalid escalation contacts: %w", err)
+	}
+	for i, contact := range contacts {
+		contacts[i].Phone = optOutContactKey(contact.Phone)
+		if contacts[i].Phone == "" && contact.WebhookURL == "" {
+			return nil, fmt.Errorf("escalation contact %d has no phone or webhookUrl", i)
+		}
+		if contact.WebhookURL != "" {
+			u, err := url.Parse(contact.WebhookURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("escalation contact %d has an invalid webhookUrl %q", i, contact.WebhookURL)
+			}
+		}
+	}
+	return contacts, nil
+}
+
+// escalationSummaryMessages is how many recent messages go into the summary (AI_ESCALATION_SUMMARY_MESSAGES, default 5)
+func escalationSummaryMessages() int {
+	n := config.GetEnvInt("AI_ESCALATION_SUMMARY_MESSAGES", 5)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// escalationChatURL fills AI_ESCALATION_CHAT_URL, e.g. https://app.example.com/chat/{contact}
+func escalationChatURL(contact string) string {
+	template := config.GetEnvString("AI_ESCALATION_CHAT_URL", "")
+	if template == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, "{contact}", url.PathEscape(contact))
+}
+
+// BuildEscalation describes a conversation being handed off, with a short summary of the recent
+// messages (best effort - a missing history only leaves the summary empty)
+func BuildEscalation(sessionToken, contactJID, contactName, reason, message string) Escalation {
+	contact := optOutContactKey(contactJID)
+	esc := Escalation{
+		SessionToken: sessionToken,
+		Contact:      contact,
+		ContactName:  contactName,
+		Reason:       reason,
+		Message:      message,
+		ChatURL:      escalationChatURL(contact),
+		EscalatedAt:  time.Now(),
+	}
+
+	limit := escalationSummaryMessages()
+	if limit == 0 || database.GetDB() == nil {
+		return esc
+	}
+	history, err := GetChatHistoryForAI(sessionToken, contactJID, limit)
+	if err != nil {
+		log.Printf("⚠️  Escalation summary unavailable for %s: %v", contact, err)
+		return esc
+	}
+	for _, msg := range history {
+		speaker := "Customer"
+		if msg.FromMe {
+			speaker = "Bot"
+		}
+		line, _ := truncateHistoryLine(msg.Body, historyLineMaxChars(), "…")
+		esc.Summary = append(esc.Summary, speaker+": "+line)
+	}
+	return esc
+}
+
+// escalationText renders the WhatsApp notification sent to agents
+func escalationText(esc Escalation) string {
+	var b strings.Builder
+	b.WriteString("🙋 Percakapan perlu ditangani admin\n")
+	if esc.ContactName != "" {
+		fmt.Fprintf(&b, "Dari: %s (+%s)\n", esc.ContactName, esc.Contact)
+	} else {
+		fmt.Fprintf(&b, "Dari: +%s\n", esc.Contact)
+	}
+	if esc.Reason != "" {
+		fmt.Fprintf(&b, "Alasan: %s\n", esc.Reason)
+	}
+	if esc.Message != "" {
+		fmt.Fprintf(&b, "Pesan: %s\n", esc.Message)
+	}
+	if len(esc.Summary) > 0 {
+		b.WriteString("\nRingkasan:\n")
+		for _, line := range esc.Summary {
+			b.WriteString("- " + line + "\n")
+		}
+	}
+	if esc.ChatURL != "" {
+		fmt.Fprintf(&b, "\nBuka chat: %s\n", esc.ChatURL)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// NotifyEscalation notifies every configured agent contact about a handoff. A failing contact
+// doesn't stop the others; all failures are returned together.
+func NotifyEscalation(contacts []EscalationContact, esc Escalation) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	text := escalationText(esc)
+	var errs []error
+	for _, contact := range contacts {
+		if contact.Phone != "" {
+			if _, err := sendEscalationWA(esc.SessionToken, contact.Phone, text); err != nil {
+				errs = append(errs, fmt.Errorf("whatsapp %s: %w", contact.Phone, err))
+			}
+		}
+		if contact.WebhookURL != "" {
+			if err := postEscalationWebhook(contact.WebhookURL, esc); err != nil {
+				errs = append(errs, fmt.Errorf("webhook %s: %w", contact.WebhookURL, err))
+			}
+		}
+	}
+	if len(errs) == 0 {
+		log.Printf("🙋 Escalation for %s sent to %d contact(s)", esc.Contact, len(contacts))
+	}
+	return errors.Join(errs...)
+}
+
+// postEscalationJSON POSTs the escalation to an agent webhook (any 2xx = delivered)
+func postEscalationJSON(webhookURL string, esc Escalation) error {
+	jsonData, err := json.Marshal(esc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal escalation: %w", err)
+	}
+
+	resp, err := NewHTTPClient(10*time.Second).Post(webhookURL, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func stubEscalationSenders(t *testing.T) (*[]string, *[]string) {
+	t.Helper()
+	var waTo, hooks []string
+	prevWA, prevHook := sendEscalationWA, postEscalationWebhook
+	sendEscalationWA = func(sessionToken, to, text string) (string, error) {
+		if !strings.Contains(text, "Ringkasan:") || !strings.Contains(text, "Customer: mau bicara dengan admin") {
+			t.Errorf("notification text missing summary:\n%s", text)
+		}
+		waTo = append(waTo, to)
+		return "msg-1", nil
+	}
+	postEscalationWebhook = func(webhookURL string, esc Escalation) error {
+		if esc.Contact != "6281200000001" || len(esc.Summary) != 2 {
+			t.Errorf("webhook payload = %+v", esc)
+		}
+		hooks = append(hooks, webhookURL)
+		return nil
+	}
+	t.Cleanup(func() { sendEscalationWA, postEscalationWebhook = prevWA, prevHook })
+	return &waTo, &hooks
+}
+
+func testEscalation() Escalation {
+	return Escalation{
+		SessionToken: "session-1",
+		Contact:      "6281200000001",
+		ContactName:  "Budi",
+		Reason:       "message_type:image",
+		Message:      "[image]",
+		Summary:      []string{"Customer: mau bicara dengan admin", "Bot: Baik, mohon tunggu"},
+	}
+}
+
+func TestNotifyEscalationSendsToConfiguredContacts(t *testing.T) {
+	waTo, hooks := stubEscalationSenders(t)
+
+	contacts := []EscalationContact{
+		{Name: "CS", Phone: "6281299999991"},
+		{Name: "Helpdesk", WebhookURL: "https://example.com/hook"},
+		{Name: "Lead", Phone: "6281299999992", WebhookURL: "https://example.com/lead"},
+	}
+	if err := NotifyEscalation(contacts, testEscalation()); err != nil {
+		t.Fatalf("NotifyEscalation: %v", err)
+	}
+	if got := strings.Join(*waTo, ","); got != "6281299999991,6281299999992" {
+		t.Errorf("WhatsApp notifications to %q", got)
+	}
+	if got := strings.Join(*hooks, ","); got != "https://example.com/hook,https://example.com/lead" {
+		t.Errorf("webhook notifications to %q", got)
+	}
+}
+
+func TestNotifyEscalationContinuesAfterFailure(t *testing.T) {
+	waTo, _ := stubEscalationSenders(t)
+	postEscalationWebhook = func(string, Escalation) error { return errors.New("connection refused") }
+
+	contacts := []EscalationContact{
+		{WebhookURL: "https://example.com/down"},
+		{Phone: "6281299999991"},
+	}
+	err := NotifyEscalation(contacts, testEscalation())
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("err = %v, want webhook failure", err)
+	}
+	if len(*waTo) != 1 {
+		t.Errorf("WhatsApp contact not notified after webhook failure: %v", *waTo)
+	}
+}
+
+func TestNotifyEscalationWithoutContacts(t *testing.T) {
+	waTo, hooks := stubEscalationSenders(t)
+	if err := NotifyEscalation(nil, testEscalation()); err != nil {
+		t.Fatalf("NotifyEscalation: %v", err)
+	}
+	if len(*waTo) != 0 || len(*hooks) != 0 {
+		t.Errorf("notified %v / %v with no contacts configured", *waTo, *hooks)
+	}
+}
+
+func TestParseEscalationContacts(t *testing.T) {
+	contacts, err := ParseEscalationContacts(`[{"name":"CS","phone":"+62 812-9999-9991"},{"webhookUrl":"https://example.com/hook"}]`)
+	if err != nil {
+		t.Fatalf("ParseEscalationContacts: %v", err)
+	}
+	if len(contacts) != 2 || contacts[0].Phone != "6281299999991" {
+		t.Errorf("contacts = %+v", contacts)
+	}
+
+	for _, raw := range []string{
+		`{"phone":"628"}`,
+		`[{"name":"nobody"}]`,
+		`[{"webhookUrl":"ftp://example.com/hook"}]`,
+	} {
+		if _, err := ParseEscalationContacts(raw); err == nil {
+			t.Errorf("ParseEscalationContacts(%s) should fail", raw)
+		}
+	}
+}
+
+func TestEscalationChatURL(t *testing.T) {
+	t.Setenv("AI_ESCALATION_CHAT_URL", "https://app.example.com/chat/{contact}")
+	esc := BuildEscalation("session-1", "6281200000001@s.whatsapp.net", "Budi", "message_type:image", "[image]")
+	if esc.ChatURL != "https://app.example.com/chat/6281200000001" {
+		t.Errorf("ChatURL = %q", esc.ChatURL)
+	}
+	if !strings.Contains(escalationText(esc), "Buka chat: https://app.example.com/chat/6281200000001") {
+		t.Errorf("text missing chat link:\n%s", escalationText(esc))
+	}
+}
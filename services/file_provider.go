@@ -0,0 +1,147 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileProvider implements DataProvider from a local YAML/JSON file (DATA_ACCESS_MODE=file).
+// Meant for local development without the transactional DB/API - see dev-data.example.yaml.
+type FileProvider struct {
+	path string
+
+	mu      sync.RWMutex
+	data    *fileProviderData
+	modTime time.Time
+}
+
+// fileProviderData is the file layout. Field names match the API JSON (userId, systemPrompt, ...)
+type fileProviderData struct {
+	Sessions []SessionInfo `json:"sessions"`
+	// Bots keyed by session token or user ID (session token wins)
+	Bots map[string]BotSettings `json:"bots"`
+}
+
+// NewFileProvider loads the data file at DATA_FILE_PATH (default dev-data.yaml)
+func NewFileProvider() (*FileProvider, error) {
+	return newFileProvider(config.GetEnvString("DATA_FILE_PATH", "dev-data.yaml"))
+}
+
+func newFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	log.Printf("📁 Loaded %d sessions / %d bots from %s", len(p.data.Sessions), len(p.data.Bots), path)
+	return p, nil
+}
+
+// reload re-reads the file when it changed since the last load (edits apply without restart)
+func (p *FileProvider) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("data file not found: %w", err)
+	}
+
+	p.mu.RLock()
+	fresh := p.data != nil && info.ModTime().Equal(p.modTime)
+	p.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	raw, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read data file: %w", err)
+	}
+	data, err := parseFileProviderData(p.path, raw)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.data = data
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	return nil
+}
+
+// parseFileProviderData decodes JSON, or YAML (.yaml/.yml) converted to JSON so both formats share the json tags
+func parseFileProviderData(path string, raw []byte) (*fileProviderData, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		var doc interface{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML data file: %w", err)
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert YAML data file: %w", err)
+		}
+		raw = converted
+	}
+
+	var data fileProviderData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse data file: %w", err)
+	}
+	return &data, nil
+}
+
+// snapshot returns the current file data, reloading it if the file changed
+func (p *FileProvider) snapshot() *fileProviderData {
+	if err := p.reload(); err != nil {
+		log.Printf("⚠️  [FileProvider] Reload failed, using last loaded data: %v", err)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.data
+}
+
+// ResolveSession looks the session token up in the file
+func (p *FileProvider) ResolveSession(instanceName string) (*SessionInfo, error) {
+	for _, session := range p.snapshot().Sessions {
+		if session.SessionToken == instanceName {
+			info := session
+			return &info, nil
+		}
+	}
+	return nil, fmt.Errorf("session not found in data file: %s", instanceName)
+}
+
+// GetBotSettings returns the bot for the session token, or else for the user ID
+func (p *FileProvider) GetBotSettings(userID, sessionToken string) (*BotSettings, error) {
+	bots := p.snapshot().Bots
+	for _, key := range []string{sessionToken, userID} {
+		if key == "" {
+			continue
+		}
+		if bot, ok := bots[key]; ok {
+			settings := bot
+			return &settings, nil
+		}
+	}
+	return nil, fmt.Errorf("no bot configured in data file for user %s / session %s", userID, sessionToken)
+}
+
+// LogUsage only logs - there is no transactional DB in file mode
+func (p *FileProvider) LogUsage(req *UsageLogRequest) error {
+	log.Printf("📁 [FileProvider] Usage: user=%s session=%s tokens=%d/%d latency=%dms status=%s",
+		req.UserID, req.SessionID, req.InputTokens, req.OutputTokens, req.LatencyMs, req.Status)
+	return nil
+}
+
+// CheckHealth verifies the data file is still readable and valid
+func (p *FileProvider) CheckHealth() error {
+	return p.reload()
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileProviderLoadsExampleYAML(t *testing.T) {
+	provider, err := newFileProvider(filepath.Join("..", "dev-data.example.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load example data file: %v", err)
+	}
+
+	session, err := provider.ResolveSession("dev-session")
+	if err != nil {
+		t.Fatalf("ResolveSession: %v", err)
+	}
+	if session.UserID != "dev-user" || !session.BotActive || !session.SubscriptionActive || session.PackageName != "Business" {
+		t.Errorf("unexpected session: %+v", session)
+	}
+
+	bot, err := provider.GetBotSettings(session.UserID, session.SessionToken)
+	if err != nil {
+		t.Fatalf("GetBotSettings: %v", err)
+	}
+	if bot.SystemPrompt == "" || bot.FallbackText == "" {
+		t.Errorf("bot prompt/fallback not loaded: %+v", bot)
+	}
+	if bot.MaxDocuments == nil || *bot.MaxDocuments != 5 {
+		t.Errorf("maxDocuments = %v, want 5", bot.MaxDocuments)
+	}
+	if len(bot.Documents) != 1 || bot.Documents[0].Title != "Jam operasional" {
+		t.Errorf("documents = %+v", bot.Documents)
+	}
+	if bot.MessageTypeHandling["image"] != RouteFallback {
+		t.Errorf("messageTypeHandling = %v", bot.MessageTypeHandling)
+	}
+
+	if _, err := provider.ResolveSession("unknown"); err == nil {
+		t.Error("expected error for unknown session")
+	}
+	if err := provider.CheckHealth(); err != nil {
+		t.Errorf("CheckHealth: %v", err)
+	}
+}
+
+func TestFileProviderJSONAndSessionOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	writeFile := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(`{
+		"sessions": [{"sessionToken": "s1", "userId": "u1", "botActive": true, "subscriptionActive": false}],
+		"bots": {
+			"u1": {"systemPrompt": "user bot"},
+			"s1": {"systemPrompt": "session bot"}
+		}
+	}`)
+
+	provider, err := newFileProvider(path)
+	if err != nil {
+		t.Fatalf("failed to load JSON data file: %v", err)
+	}
+
+	session, err := provider.ResolveSession("s1")
+	if err != nil || session.SubscriptionActive {
+		t.Fatalf("unexpected session %+v (err %v)", session, err)
+	}
+	if bot, _ := provider.GetBotSettings("u1", "s1"); bot == nil || bot.SystemPrompt != "session bot" {
+		t.Errorf("session-keyed bot must win, got %+v", bot)
+	}
+	if bot, _ := provider.GetBotSettings("u1", "other"); bot == nil || bot.SystemPrompt != "user bot" {
+		t.Errorf("expected user-keyed bot, got %+v", bot)
+	}
+	if _, err := provider.GetBotSettings("u2", "s2"); err == nil {
+		t.Error("expected error when no bot is configured")
+	}
+
+	// Edits are picked up on the next call
+	writeFile(`{"sessions": [{"sessionToken": "s2", "userId": "u2"}], "bots": {}}`)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if _, err := provider.ResolveSession("s2"); err != nil {
+		t.Errorf("expected reloaded session s2: %v", err)
+	}
+}
+
+func TestFileProviderInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.yaml")
+	os.WriteFile(path, []byte("sessions: [unclosed"), 0o644)
+
+	if _, err := newFileProvider(path); err == nil {
+		t.Error("expected parse error for invalid YAML")
+	}
+	if _, err := newFileProvider(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
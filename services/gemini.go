@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"time"
 
 	"google.golang.org/genai"
@@ -24,19 +23,21 @@ func NewGeminiClient() (*GeminiClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY not set in environment")
 	}
+	return newGeminiClient(apiKey, os.Getenv("GEMINI_MODEL"))
+}
 
-	model := os.Getenv("GEMINI_MODEL")
+// newGeminiClient creates a Gemini client for apiKey (the global one or a customer's);
+// an empty model uses GEMINI_MODEL / the default. The key is never logged.
+func newGeminiClient(apiKey, model string) (*GeminiClient, error) {
 	if model == "" {
-		model = "gemini-2.5-flash" // default model
+		model = os.Getenv("GEMINI_MODEL")
 	}
-
-	timeoutMs := 120000 // default 120 seconds
-	if t := os.Getenv("AI_TIMEOUT_MS"); t != "" {
-		if parsed, err := strconv.Atoi(t); err == nil {
-			timeoutMs = parsed
-		}
+	if model == "" {
+		model = defaultGeminiModel
 	}
 
+	timeout := AITimeout()
+
 	ctx := context.Background()
 
 	// Initialize Gemini client with API key
@@ -48,34 +49,32 @@ func NewGeminiClient() (*GeminiClient, error) {
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 
-	log.Printf("[GeminiClient] Initialized with model=%s, timeout=%dms", model, timeoutMs)
+	log.Printf("[GeminiClient] Initialized with model=%s, timeout=%s", model, timeout)
 
 	return &GeminiClient{
 		client:  client,
 		model:   model,
-		timeout: time.Duration(timeoutMs) * time.Millisecond,
+		timeout: timeout,
 	}, nil
 }
 
 // AskLLM sends a prompt to Gemini and returns the response with token usage
 func (gc *GeminiClient) AskLLM(ctx context.Context, systemPrompt string, userPrompt string) (string, int, int, error) {
+	// Global call budget (AI_LLM_RATE_PER_SECOND), shared with every other job
+	if err := WaitLLMSlot(ctx); err != nil {
+		return "", 0, 0, fmt.Errorf("Gemini API error: %w", err)
+	}
+
 	// Create context with timeout
 	timeoutCtx, cancel := context.WithTimeout(ctx, gc.timeout)
 	defer cancel()
 
-	// Combine system and user prompts
-	// Gemini doesn't have explicit system role, so we prepend it to the user message
-	fullPrompt := systemPrompt + "\n\n" + userPrompt
-
 	startTime := time.Now()
+	model := requestModel(ctx, gc.model)
+	contents, genConfig := buildGeminiRequest(model, systemPrompt, userPrompt)
 
 	// Generate content
-	result, err := gc.client.Models.GenerateContent(
-		timeoutCtx,
-		gc.model,
-		genai.Text(fullPrompt),
-		nil,
-	)
+	result, err := gc.client.Models.GenerateContent(timeoutCtx, model, contents, genConfig)
 	if err != nil {
 		return "", 0, 0, fmt.Errorf("Gemini API error: %w", err)
 	}
@@ -102,11 +101,22 @@ func (gc *GeminiClient) AskLLM(ctx context.Context, systemPrompt string, userPro
 	}
 
 	log.Printf("[GeminiClient] Success | model=%s | latency=%dms | in=%d | out=%d | total=%d",
-		gc.model, latency, inputTokens, outputTokens, inputTokens+outputTokens)
+		model, latency, inputTokens, outputTokens, inputTokens+outputTokens)
 
 	return responseText, inputTokens, outputTokens, nil
 }
 
+// buildGeminiRequest puts the system prompt in SystemInstruction; models without system
+// instruction support (UseNativeSystemPrompt) get it prepended to the user message instead
+func buildGeminiRequest(model, systemPrompt, userPrompt string) ([]*genai.Content, *genai.GenerateContentConfig) {
+	if systemPrompt == "" || !UseNativeSystemPrompt("gemini", model) {
+		return genai.Text(mergeSystemPrompt(systemPrompt, userPrompt)), nil
+	}
+	return genai.Text(userPrompt), &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(systemPrompt, genai.RoleUser),
+	}
+}
+
 // GetProviderName returns the provider name for logging
 func (gc *GeminiClient) GetProviderName() string {
 	return "gemini"
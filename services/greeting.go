@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GreetingMessage returns the bot's first-contact greeting ("" = the bot doesn't greet)
+func GreetingMessage(botSettings *BotSettings) string {
+	if botSettings == nil {
+		return ""
+	}
+	return strings.TrimSpace(botSettings.GreetingMessage)
+}
+
+// hasEarlierMessages reports whether the session has any AI chat message with the contact other
+// than messageID (the message that just came in)
+func hasEarlierMessages(sessionTok, contactJID, messageID string) (bool, error) {
+	var count int64
+	err := database.GetDB().Model(&models.AIChatMessage{}).
+		Where(`session_tok = ? AND ("from" = ? OR "to" = ?) AND message_id <> ?`, sessionTok, contactJID, contactJID, messageID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check chat history: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ClaimFirstContactGreeting reports whether messageID is the contact's first message ever and
+// records the greeting, so exactly one webhook greets a contact even when messages race. The
+// greeting is sent by the AI job of messageID (SendPendingGreeting), right before its reply.
+// Contacts with earlier history (from before greetings were enabled) are never greeted, and
+// errors count as "don't greet" - a missed greeting is better than a repeated one.
+func ClaimFirstContactGreeting(sessionTok, contactJID, messageID, greeting string) bool {
+	db := database.GetDB()
+	key := optOutContactKey(contactJID)
+	if db == nil || key == "" {
+		return false
+	}
+
+	earlier, err := hasEarlierMessages(sessionTok, contactJID, messageID)
+	if err != nil {
+		log.Printf("⚠️  Greeting skipped for %s: %v", key, err)
+		return false
+	}
+	if earlier {
+		return false
+	}
+
+	claim := models.ContactGreeting{
+		SessionTok: sessionTok,
+		Contact:    key,
+		MessageID:  messageID,
+		Greeting:   greeting,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&claim)
+	if result.Error != nil {
+		log.Printf("⚠️  Greeting skipped for %s: failed to record greeting: %v", key, result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// SendPendingGreeting sends the greeting claimed for one of messageIDs (the messages of an AI job)
+// if it hasn't gone out yet. Called by the worker before it builds the context, so the greeting
+// arrives first and the LLM continues from it. Marked sent before sending: a retried job never
+// greets twice.
+func SendPendingGreeting(sessionTok, botJID, contactJID string, messageIDs []string) error {
+	db := database.GetDB()
+	key := optOutContactKey(contactJID)
+	if db == nil || key == "" || len(messageIDs) == 0 {
+		return nil
+	}
+
+	var claim models.ContactGreeting
+	err := db.Where("session_tok = ? AND contact = ? AND message_id IN ? AND sent_at IS NULL", sessionTok, key, messageIDs).
+		First(&claim).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up pending greeting: %w", err)
+	}
+
+	result := db.Model(&models.ContactGreeting{}).
+		Where("id = ? AND sent_at IS NULL", claim.ID).
+		Update("sent_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark greeting sent: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || claim.Greeting == "" {
+		return nil
+	}
+
+	log.Printf("👋 First message from %s - sending greeting", key)
+	return SendGreeting(sessionTok, botJID, contactJID, claim.Greeting)
+}
+
+// SendGreeting sends the greeting to the contact and stores it like an AI reply, so the LLM sees
+// it in the history and continues from it instead of greeting again
+func SendGreeting(sessionToken, botJID, contactJID, greeting string) error {
+	if _, err := sendAndStoreReply(sessionToken, botJID, contactJID, greeting, "greeting"); err != nil {
+		return fmt.Errorf("failed to send greeting: %w", err)
+	}
+	return nil
+}
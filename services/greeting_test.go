@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestGreetingMessage(t *testing.T) {
+	if got := GreetingMessage(nil); got != "" {
+		t.Errorf("nil settings: got %q", got)
+	}
+	if got := GreetingMessage(&BotSettings{GreetingMessage: "  \n"}); got != "" {
+		t.Errorf("blank greeting: got %q", got)
+	}
+	if got := GreetingMessage(&BotSettings{GreetingMessage: " Halo, selamat datang! "}); got != "Halo, selamat datang!" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestClaimFirstContactGreeting(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ContactGreeting{}); err != nil {
+		t.Fatalf("failed to migrate contact_greetings: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.ContactGreeting{}) })
+
+	newContact := "6281200000001@s.whatsapp.net"
+	first := models.AIChatMessage{MessageID: sessionTok + "_in_1", SessionTok: sessionTok, From: newContact, To: "bot@s.whatsapp.net", MsgType: "text", Body: "halo", Timestamp: time.Now()}
+	if err := db.Create(&first).Error; err != nil {
+		t.Fatalf("failed to seed message: %v", err)
+	}
+	if !ClaimFirstContactGreeting(sessionTok, newContact, first.MessageID, "Halo!") {
+		t.Fatal("first message of a new contact was not greeted")
+	}
+	// A retried webhook for the same message, or a second message racing it, must not greet again
+	if ClaimFirstContactGreeting(sessionTok, newContact, first.MessageID, "Halo!") {
+		t.Error("greeting claimed twice for the same contact")
+	}
+
+	// A contact we already talked to (before greetings were enabled) is not greeted
+	known := "6281200000002@s.whatsapp.net"
+	if err := SaveOutgoingMessageToAIChat(sessionTok, sessionTok+"_out_1", "bot@s.whatsapp.net", known, "Terima kasih kak", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to seed outgoing message: %v", err)
+	}
+	if ClaimFirstContactGreeting(sessionTok, known, sessionTok+"_in_2", "Halo!") {
+		t.Error("contact with earlier history was greeted")
+	}
+}
+
+func TestSendPendingGreeting(t *testing.T) {
+	sessionTok := setupSendLogTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ContactGreeting{}, &models.ChatRoom{}, &models.ChatMessage{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.ContactGreeting{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+	t.Setenv("DRY_RUN", "true")
+
+	contact := "6281200000003@s.whatsapp.net"
+	first := models.AIChatMessage{MessageID: sessionTok + "_in_1", SessionTok: sessionTok, From: contact, To: "bot@s.whatsapp.net", MsgType: "text", Body: "halo", Timestamp: time.Now()}
+	if err := db.Create(&first).Error; err != nil {
+		t.Fatalf("failed to seed message: %v", err)
+	}
+	if !ClaimFirstContactGreeting(sessionTok, contact, first.MessageID, "Selamat datang!") {
+		t.Fatal("greeting not claimed")
+	}
+	greetingsSent := func() int64 {
+		var count int64
+		db.Model(&models.MessageSendLog{}).Where("session_tok = ? AND body LIKE ?", sessionTok, "%Selamat datang!").Count(&count)
+		return count
+	}
+
+	// A job for other messages doesn't send it
+	if err := SendPendingGreeting(sessionTok, "bot@s.whatsapp.net", contact, []string{sessionTok + "_other"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := greetingsSent(); n != 0 {
+		t.Fatalf("greeting sent by an unrelated job (%d)", n)
+	}
+
+	// The job of the first message sends it once, also when retried
+	for i := 0; i < 2; i++ {
+		if err := SendPendingGreeting(sessionTok, "bot@s.whatsapp.net", contact, []string{first.MessageID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := greetingsSent(); n != 1 {
+		t.Errorf("greeting sent %d times, want 1", n)
+	}
+	history, _ := GetChatHistoryForAI(sessionTok, contact, 10)
+	if len(history) != 2 {
+		t.Errorf("greeting not in the AI context: %d messages", len(history))
+	}
+}
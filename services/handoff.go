@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// defaultHandoffKeywords - a message consisting of just one of these hands the chat to a human
+const defaultHandoffKeywords = "AGENT,MANUSIA,HUMAN,OPERATOR,CS,ADMIN,BICARA DENGAN CS"
+
+const defaultHandoffMessage = "Baik, percakapan ini kami teruskan ke tim CS kami. Mohon tunggu sebentar ya 🙏"
+
+// Handoff reasons stored in contact_handoffs.reason
+const (
+	HandoffReasonKeyword     = "keyword"
+	HandoffReasonLLM         = "llm"
+	HandoffReasonMessageType = "message_type" // bot routes the message type to handoff (messageTypeHandling)
+)
+
+// handoffSentinelPattern matches the [HANDOFF] sentinel the LLM puts in its reply to ask for a human
+var handoffSentinelPattern = regexp.MustCompile(`(?i)\[HANDOFF\]`)
+
+// handoffInstructions is appended to the system prompt when AI_HANDOFF_LLM is on
+const handoffInstructions = `
+
+=== SERAHKAN KE CS ===
+Jika kamu tidak bisa membantu (pertanyaan di luar knowledge base, keluhan, permintaan refund, atau
+customer minta bicara dengan manusia), tulis [HANDOFF] di baris terpisah di akhir jawaban.
+Percakapan lalu diteruskan ke CS manusia dan kamu tidak membalas lagi.
+`
+
+// handoffKeywords returns AI_HANDOFF_KEYWORDS (comma-separated, case-insensitive; "off" disables)
+func handoffKeywords() []string {
+	raw := config.GetEnvString("AI_HANDOFF_KEYWORDS", "")
+	if strings.EqualFold(raw, "off") {
+		return nil
+	}
+	if raw == "" {
+		raw = defaultHandoffKeywords
+	}
+
+	var keywords []string
+	for _, keyword := range strings.Split(raw, ",") {
+		if keyword = normalizeOptOutText(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// MatchHandoffKeyword reports whether body asks for a human agent and returns the matched keyword.
+// Like opt-out keywords, the whole message must be the keyword ("agent!" yes, "agent properti" no).
+func MatchHandoffKeyword(body string) (string, bool) {
+	normalized := normalizeOptOutText(body)
+	if normalized == "" {
+		return "", false
+	}
+	for _, keyword := range handoffKeywords() {
+		if normalized == keyword {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// LLMHandoffEnabled reports whether the LLM may hand a chat to a human with [HANDOFF] (AI_HANDOFF_LLM, default false)
+func LLMHandoffEnabled() bool {
+	return config.GetEnvBool("AI_HANDOFF_LLM", false)
+}
+
+// ExtractHandoffRequest removes [HANDOFF] sentinels from response and reports whether there was one
+func ExtractHandoffRequest(response string) (string, bool) {
+	if !handoffSentinelPattern.MatchString(response) {
+		return response, false
+	}
+	text := handoffSentinelPattern.ReplaceAllString(response, "")
+	return strings.TrimSpace(text), true
+}
+
+// HandoffMessage returns the reply telling the customer a human takes over (AI_HANDOFF_MESSAGE)
+func HandoffMessage() string {
+	if msg := config.GetEnvString("AI_HANDOFF_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultHandoffMessage
+}
+
+// StartHandoff puts the session + contact in handoff mode: messages are still stored but get no AI
+// reply. Returns false when the conversation already was in handoff (the first record is kept).
+func StartHandoff(sessionTok, contact, reason, messageID string) (bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+
+	handoff := models.ContactHandoff{
+		SessionTok: sessionTok,
+		Contact:    optOutContactKey(contact),
+		Reason:     reason,
+		MessageID:  messageID,
+	}
+	if handoff.Contact == "" {
+		return false, fmt.Errorf("invalid contact: %q", contact)
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&handoff)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record handoff: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		log.Printf("🙋 Conversation with %s handed off to a human (%s)", handoff.Contact, reason)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// IsInHandoff reports whether a human agent has the conversation. Lookup errors count as not in
+// handoff, so a DB hiccup never silences the bot for everyone.
+func IsInHandoff(sessionTok, contact string) bool {
+	db := database.GetDB()
+	key := optOutContactKey(contact)
+	if db == nil || key == "" {
+		return false
+	}
+
+	var count int64
+	if err := db.Model(&models.ContactHandoff{}).
+		Where("session_tok = ? AND contact = ?", sessionTok, key).
+		Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// ListHandoffs returns conversations in handoff, newest first, optionally filtered by session
+func ListHandoffs(sessionTok string, limit int) ([]models.ContactHandoff, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	query := db.Model(&models.ContactHandoff{})
+	if sessionTok != "" {
+		query = query.Where("session_tok = ?", sessionTok)
+	}
+
+	var handoffs []models.ContactHandoff
+	if err := query.Order("created_at DESC").Limit(limit).Find(&handoffs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list handoffs: %w", err)
+	}
+	return handoffs, nil
+}
+
+// EndHandoff returns conversations of a session to the bot (all contacts when contact is empty)
+func EndHandoff(sessionTok, contact string) (int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	query := db.Where("session_tok = ?", sessionTok)
+	if contact != "" {
+		query = query.Where("contact = ?", optOutContactKey(contact))
+	}
+	result := query.Delete(&models.ContactHandoff{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to end handoff: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// NotifyHandoff tells the bot's escalation contacts (if any) that a conversation needs a human
+func NotifyHandoff(botSettings *BotSettings, sessionToken, contactJID, contactName, reason, message string) {
+	if botSettings == nil || len(botSettings.EscalationContacts) == 0 {
+		return
+	}
+	esc := BuildEscalation(sessionToken, contactJID, contactName, "handoff:"+reason, message)
+	if err := NotifyEscalation(botSettings.EscalationContacts, esc); err != nil {
+		log.Printf("⚠️  Failed to notify escalation contacts: %v", err)
+	}
+}
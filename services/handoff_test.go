@@ -0,0 +1,89 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestMatchHandoffKeyword(t *testing.T) {
+	t.Setenv("AI_HANDOFF_KEYWORDS", "")
+	for _, body := range []string{"agent", "Manusia!", " bicara dengan  CS ", "HUMAN 🙏"} {
+		if _, ok := MatchHandoffKeyword(body); !ok {
+			t.Errorf("%q not a handoff request", body)
+		}
+	}
+	for _, body := range []string{"agent properti di mana?", "saya bukan manusia biasa", ""} {
+		if kw, ok := MatchHandoffKeyword(body); ok {
+			t.Errorf("%q matched %q", body, kw)
+		}
+	}
+
+	t.Setenv("AI_HANDOFF_KEYWORDS", "off")
+	if _, ok := MatchHandoffKeyword("agent"); ok {
+		t.Error("keywords off but still matched")
+	}
+}
+
+func TestExtractHandoffRequest(t *testing.T) {
+	text, requested := ExtractHandoffRequest("Maaf, untuk refund saya teruskan ke tim CS ya.\n[HANDOFF]")
+	if !requested || text != "Maaf, untuk refund saya teruskan ke tim CS ya." {
+		t.Errorf("got %q, %v", text, requested)
+	}
+	if text, requested := ExtractHandoffRequest("[handoff]"); !requested || text != "" {
+		t.Errorf("sentinel only: got %q, %v", text, requested)
+	}
+	if text, requested := ExtractHandoffRequest("Harga paket Basic Rp150.000"); requested || text != "Harga paket Basic Rp150.000" {
+		t.Errorf("plain reply: got %q, %v", text, requested)
+	}
+}
+
+func TestAssembleContextHandoffInstructions(t *testing.T) {
+	t.Setenv("AI_HANDOFF_LLM", "false")
+	if ctx := AssembleContext(&BotSettings{SystemPrompt: "CS toko"}, nil, "halo"); strings.Contains(ctx.SystemPrompt, "[HANDOFF]") {
+		t.Error("handoff instructions added while AI_HANDOFF_LLM is off")
+	}
+	t.Setenv("AI_HANDOFF_LLM", "true")
+	if ctx := AssembleContext(&BotSettings{SystemPrompt: "CS toko"}, nil, "halo"); !strings.Contains(ctx.SystemPrompt, "[HANDOFF]") {
+		t.Error("handoff instructions missing")
+	}
+}
+
+func TestHandoffLifecycle(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ContactHandoff{}); err != nil {
+		t.Fatalf("failed to migrate contact_handoffs: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.ContactHandoff{}) })
+
+	contact := "6281200000001@s.whatsapp.net"
+	if IsInHandoff(sessionTok, contact) {
+		t.Fatal("new conversation already in handoff")
+	}
+	started, err := StartHandoff(sessionTok, contact, HandoffReasonKeyword+":agent", "MSG1")
+	if err != nil || !started {
+		t.Fatalf("start: %v %v", started, err)
+	}
+	// A second trigger (LLM, retried webhook) keeps the first record
+	if started, err := StartHandoff(sessionTok, "6281200000001", HandoffReasonLLM, "MSG2"); err != nil || started {
+		t.Errorf("second start: %v %v", started, err)
+	}
+	if !IsInHandoff(sessionTok, "6281200000001") || IsInHandoff(sessionTok+"_other", contact) {
+		t.Error("handoff state not scoped to session + contact")
+	}
+
+	handoffs, err := ListHandoffs(sessionTok, 10)
+	if err != nil || len(handoffs) != 1 || handoffs[0].Reason != "keyword:agent" || handoffs[0].Contact != "6281200000001" {
+		t.Fatalf("list = %+v, %v", handoffs, err)
+	}
+
+	if returned, err := EndHandoff(sessionTok, contact); err != nil || returned != 1 {
+		t.Errorf("end: %d %v", returned, err)
+	}
+	if IsInHandoff(sessionTok, contact) {
+		t.Error("still in handoff after it ended")
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for the shared outbound HTTP transport (override via .env)
+const (
+	defaultHTTPMaxIdleConns        = 100
+	defaultHTTPMaxIdleConnsPerHost = 20 // almost every call goes to the gateway or the WA server
+	defaultHTTPIdleConnTimeoutSecs = 90
+)
+
+// SharedTransport pools connections for every outbound call (WA server, gateway, transactional
+// API, LLM, webhooks). http.DefaultTransport keeps only 2 idle connections per host, so under
+// campaign load most calls would open a new connection.
+var SharedTransport = newSharedTransport()
+
+// sharedClient has no Timeout of its own: DoHTTP bounds each call
+var sharedClient = &http.Client{Transport: SharedTransport}
+
+func newSharedTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = positiveEnvInt("HTTP_MAX_IDLE_CONNS", defaultHTTPMaxIdleConns)
+	transport.MaxIdleConnsPerHost = positiveEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", defaultHTTPMaxIdleConnsPerHost)
+	transport.IdleConnTimeout = time.Duration(positiveEnvInt("HTTP_IDLE_CONN_TIMEOUT_SECONDS", defaultHTTPIdleConnTimeoutSecs)) * time.Second
+	return transport
+}
+
+// positiveEnvInt reads key, using fallback when unset or not positive
+func positiveEnvInt(key string, fallback int) int {
+	if v := config.GetEnvInt(key, fallback); v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// NewHTTPClient returns a client on the shared transport for long-lived callers that keep their
+// own client (one timeout for every request)
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: SharedTransport, Timeout: timeout}
+}
+
+// DoHTTP sends req over the shared transport, bounded by timeout (on top of any deadline the
+// request's context already has). The timeout covers reading the body too; it is released when
+// the body is closed, so callers keep their usual defer resp.Body.Close().
+func DoHTTP(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := sharedClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request's timeout context once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
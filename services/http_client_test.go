@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewSharedTransport(t *testing.T) {
+	t.Setenv("HTTP_MAX_IDLE_CONNS_PER_HOST", "50")
+	t.Setenv("HTTP_IDLE_CONN_TIMEOUT_SECONDS", "-1")
+	transport := newSharedTransport()
+	if transport.MaxIdleConnsPerHost != 50 || transport.MaxIdleConns != defaultHTTPMaxIdleConns {
+		t.Errorf("idle conns = %d per host / %d total, want 50 / %d", transport.MaxIdleConnsPerHost, transport.MaxIdleConns, defaultHTTPMaxIdleConns)
+	}
+	if transport.IdleConnTimeout != defaultHTTPIdleConnTimeoutSecs*time.Second {
+		t.Errorf("invalid idle timeout should use the default, got %v", transport.IdleConnTimeout)
+	}
+}
+
+func TestDoHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The body stays readable after DoHTTP returns - the timeout is released on Close
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/fast", nil)
+	resp, err := DoHTTP(req, time.Second)
+	if err != nil {
+		t.Fatalf("DoHTTP: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "ok" {
+		t.Errorf("body = %q, %v", body, err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/slow", nil)
+	_, err = DoHTTP(req, 50*time.Millisecond)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("slow server err = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sendImagePattern matches the [SEND_IMAGE:url] sentinel the LLM may put in its reply
+var sendImagePattern = regexp.MustCompile(`\[SEND_IMAGE:\s*([^\]\s]+)\s*\]`)
+
+// imageSendInstructions is appended to the system prompt of bots with allowImageSend
+const imageSendInstructions = `
+
+=== KIRIM GAMBAR ===
+Jika jawaban terbaik adalah gambar dari knowledge base (misalnya daftar harga atau katalog),
+tulis [SEND_IMAGE:URL] di baris terpisah dengan URL gambar PERSIS seperti di knowledge base.
+Hanya gunakan URL yang ada di knowledge base - JANGAN membuat URL sendiri.
+`
+
+// ExtractImageSends removes [SEND_IMAGE:url] sentinels from response and returns the
+// remaining text plus the requested image URLs (in order, without duplicates)
+func ExtractImageSends(response string) (string, []string) {
+	matches := sendImagePattern.FindAllStringSubmatch(response, -1)
+	if len(matches) == 0 {
+		return response, nil
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			urls = append(urls, m[1])
+		}
+	}
+
+	return removeSentinels(response, sendImagePattern), urls
+}
+
+// removeSentinels removes every match of pattern from response and collapses the blank lines
+// left where the sentinels were
+func removeSentinels(response string, pattern *regexp.Regexp) string {
+	text := pattern.ReplaceAllString(response, "")
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" && len(kept) > 0 && kept[len(kept)-1] == "" {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// ValidateImageURL checks an LLM-requested image URL before the gateway downloads it:
+// http(s) only, no local/private hosts, and it must appear in the bot's knowledge base
+// or system prompt (the LLM may not invent URLs)
+func ValidateImageURL(rawURL string, botSettings *BotSettings) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("not an http(s) URL: %s", rawURL)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") {
+		return fmt.Errorf("local host not allowed: %s", host)
+	}
+	if ip := net.ParseIP(host); ip != nil &&
+		(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+		return fmt.Errorf("private address not allowed: %s", host)
+	}
+
+	if botSettings == nil {
+		return fmt.Errorf("no bot settings to check URL against")
+	}
+	if strings.Contains(botSettings.SystemPrompt, rawURL) {
+		return nil
+	}
+	for _, doc := range botSettings.Documents {
+		if strings.Contains(doc.Content, rawURL) {
+			return nil
+		}
+	}
+	return fmt.Errorf("URL not found in knowledge base: %s", rawURL)
+}
+
+// SendImageRequest payload for the gateway image endpoint (URL is converted to base64 there)
+type SendImageRequest struct {
+	Phone   string `json:"Phone"`
+	Image   string `json:"Image"`
+	Caption string `json:"Caption"`
+}
+
+// SendWAImage sends an image by URL via the internal gateway (/wa/chat/send/image downloads
+// and encodes it) and returns the WhatsApp message ID ("" if not reported)
+func SendWAImage(sessionToken, to, imageURL, caption string) (string, error) {
+	if DryRun() {
+		return RecordDryRunSend(sessionToken, to, "chat/send/image", strings.TrimSpace(imageURL+" "+caption)), nil
+	}
+
+	endpoint := "http://localhost:8070/wa/chat/send/image"
+
+	payload := SendImageRequest{
+		Phone:   NormalizePhone(to),
+		Image:   imageURL,
+		Caption: caption,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("token", sessionToken)
+
+	// Gateway downloads the image first - same timeout as its image proxy
+	resp, err := DoHTTP(req, 60*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to send WA image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	result := ParseWASendResponse(resp.StatusCode, body)
+	if !result.OK {
+		return "", fmt.Errorf("gateway image send failed: %s", result.Error)
+	}
+
+	return result.MessageID, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractImageSends(t *testing.T) {
+	cases := []struct {
+		name     string
+		response string
+		wantText string
+		wantURLs []string
+	}{
+		{
+			name:     "plain text unchanged",
+			response: "Harga paket Business Rp 150rb/bulan.\n\nAda lagi kak?",
+			wantText: "Harga paket Business Rp 150rb/bulan.\n\nAda lagi kak?",
+		},
+		{
+			name:     "text with image",
+			response: "Berikut daftar harga kami:\n\n[SEND_IMAGE:https://cdn.example.com/harga.png]\n\nSilakan dicek ya kak",
+			wantText: "Berikut daftar harga kami:\n\nSilakan dicek ya kak",
+			wantURLs: []string{"https://cdn.example.com/harga.png"},
+		},
+		{
+			name:     "image only, duplicates removed",
+			response: "[SEND_IMAGE: https://cdn.example.com/a.jpg ]\n[SEND_IMAGE:https://cdn.example.com/b.jpg]\n[SEND_IMAGE:https://cdn.example.com/a.jpg]",
+			wantText: "",
+			wantURLs: []string{"https://cdn.example.com/a.jpg", "https://cdn.example.com/b.jpg"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			text, urls := ExtractImageSends(tc.response)
+			if text != tc.wantText {
+				t.Errorf("text = %q, want %q", text, tc.wantText)
+			}
+			if !reflect.DeepEqual(urls, tc.wantURLs) {
+				t.Errorf("urls = %v, want %v", urls, tc.wantURLs)
+			}
+		})
+	}
+}
+
+func TestValidateImageURL(t *testing.T) {
+	settings := &BotSettings{
+		SystemPrompt: "Katalog: https://cdn.example.com/katalog.jpg",
+		Documents: []Document{
+			{Title: "Harga", Content: "Daftar harga (gambar): https://cdn.example.com/harga.png"},
+			{Title: "Internal", Content: "http://192.168.1.10/harga.png http://localhost/x.png"},
+		},
+	}
+
+	valid := []string{"https://cdn.example.com/harga.png", "https://cdn.example.com/katalog.jpg"}
+	for _, u := range valid {
+		if err := ValidateImageURL(u, settings); err != nil {
+			t.Errorf("ValidateImageURL(%q) = %v, want nil", u, err)
+		}
+	}
+
+	invalid := []string{
+		"https://cdn.example.com/invented.png", // not in knowledge base
+		"ftp://cdn.example.com/harga.png",      // scheme
+		"http://192.168.1.10/harga.png",        // private address even though it is in the KB
+		"http://localhost/x.png",               // local host
+		"not a url",
+	}
+	for _, u := range invalid {
+		if err := ValidateImageURL(u, settings); err == nil {
+			t.Errorf("ValidateImageURL(%q) = nil, want error", u)
+		}
+	}
+
+	if err := ValidateImageURL("https://cdn.example.com/harga.png", nil); err == nil {
+		t.Error("expected error without bot settings")
+	}
+}
+
+func TestAssembleContextImageSendInstructionsOptIn(t *testing.T) {
+	off := AssembleContext(&BotSettings{SystemPrompt: "Bot"}, nil, "halo")
+	if strings.Contains(off.SystemPrompt, "SEND_IMAGE") {
+		t.Error("image instructions must not be added unless allowImageSend is set")
+	}
+
+	settings := &BotSettings{SystemPrompt: "Bot", AllowImageSend: true}
+	on := AssembleContext(settings, nil, "halo")
+	if !strings.Contains(on.SystemPrompt, "[SEND_IMAGE:URL]") {
+		t.Error("expected image instructions for allowImageSend bot")
+	}
+	if on.Settings != settings {
+		t.Error("ContextData.Settings should point at the bot settings used")
+	}
+}
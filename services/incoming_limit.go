@@ -0,0 +1,65 @@
+package services
+
+import (
+	"strings"
+
+	"genfity-wa-support/config"
+)
+
+// Overflow modes for incoming messages longer than MAX_INCOMING_CHARS
+const (
+	IncomingOverflowTruncate = "truncate" // keep the first MAX_INCOMING_CHARS characters and process
+	IncomingOverflowReject   = "reject"   // drop the message, no AI job
+)
+
+const defaultMaxIncomingChars = 4000
+
+const defaultIncomingTooLongMessage = "Maaf, pesan Anda terlalu panjang. Mohon kirim pesan yang lebih singkat ya 🙏"
+
+// incomingTruncateSuffix marks a body that was cut before being stored / sent to the LLM
+const incomingTruncateSuffix = " ...[pesan dipotong]"
+
+// MaxIncomingChars returns the max characters (runes) of an incoming message body (0 = no limit)
+func MaxIncomingChars() int {
+	limit := config.GetEnvInt("MAX_INCOMING_CHARS", defaultMaxIncomingChars)
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// IncomingOverflowMode returns AI_INCOMING_OVERFLOW_MODE (truncate | reject, default truncate)
+func IncomingOverflowMode() string {
+	if strings.EqualFold(config.GetEnvString("AI_INCOMING_OVERFLOW_MODE", ""), IncomingOverflowReject) {
+		return IncomingOverflowReject
+	}
+	return IncomingOverflowTruncate
+}
+
+// ShouldReplyIncomingTooLong - AI_INCOMING_TOO_LONG_REPLY=true tells the customer their message was too long
+func ShouldReplyIncomingTooLong() bool {
+	return config.GetEnvBool("AI_INCOMING_TOO_LONG_REPLY", false)
+}
+
+// IncomingTooLongMessage returns the reply sent for an oversized message
+func IncomingTooLongMessage() string {
+	if msg := config.GetEnvString("AI_INCOMING_TOO_LONG_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultIncomingTooLongMessage
+}
+
+// LimitIncomingBody applies MAX_INCOMING_CHARS to body.
+// Returns the (possibly truncated) body and whether it was over the limit;
+// in reject mode the body is returned unchanged and the caller drops the message.
+func LimitIncomingBody(body string) (string, bool) {
+	limit := MaxIncomingChars()
+	if limit == 0 || len(body) <= limit || len([]rune(body)) <= limit {
+		return body, false
+	}
+	if IncomingOverflowMode() == IncomingOverflowReject {
+		return body, true
+	}
+	truncated, _ := truncateHistoryLine(body, limit, incomingTruncateSuffix)
+	return truncated, true
+}
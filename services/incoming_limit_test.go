@@ -0,0 +1,64 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLimitIncomingBodyTruncate(t *testing.T) {
+	t.Setenv("MAX_INCOMING_CHARS", "20")
+	t.Setenv("AI_INCOMING_OVERFLOW_MODE", "")
+
+	body, over := LimitIncomingBody("halo kak")
+	if over || body != "halo kak" {
+		t.Errorf("short body changed: %q over=%v", body, over)
+	}
+
+	long := "saya mau tanya tentang paket enterprise dan harganya"
+	body, over = LimitIncomingBody(long)
+	if !over {
+		t.Fatal("expected body over the limit")
+	}
+	if !strings.HasSuffix(body, incomingTruncateSuffix) {
+		t.Errorf("truncated body missing marker: %q", body)
+	}
+	if kept := strings.TrimSuffix(body, incomingTruncateSuffix); utf8.RuneCountInString(kept) > 20 || !strings.HasPrefix(long, kept) {
+		t.Errorf("kept part %q is not a prefix of at most 20 chars", kept)
+	}
+}
+
+func TestLimitIncomingBodyRuneSafe(t *testing.T) {
+	t.Setenv("MAX_INCOMING_CHARS", "5")
+	t.Setenv("AI_INCOMING_OVERFLOW_MODE", "truncate")
+
+	// 5 emoji = 20 bytes but only 5 characters - within the limit
+	if body, over := LimitIncomingBody("😀😀😀😀😀"); over || body != "😀😀😀😀😀" {
+		t.Errorf("multi-byte body within limit changed: %q over=%v", body, over)
+	}
+
+	body, over := LimitIncomingBody("😀😀😀😀😀😀😀")
+	if !over || !utf8.ValidString(body) {
+		t.Errorf("expected valid truncated body, got %q over=%v", body, over)
+	}
+}
+
+func TestLimitIncomingBodyReject(t *testing.T) {
+	t.Setenv("MAX_INCOMING_CHARS", "10")
+	t.Setenv("AI_INCOMING_OVERFLOW_MODE", "REJECT")
+
+	long := "pesan yang jauh lebih panjang dari batas"
+	body, over := LimitIncomingBody(long)
+	if !over || body != long {
+		t.Errorf("reject mode must flag without modifying: %q over=%v", body, over)
+	}
+}
+
+func TestLimitIncomingBodyDisabled(t *testing.T) {
+	t.Setenv("MAX_INCOMING_CHARS", "0")
+
+	long := strings.Repeat("a", 50000)
+	if body, over := LimitIncomingBody(long); over || body != long {
+		t.Errorf("limit 0 must disable the guard (over=%v, len=%d)", over, len(body))
+	}
+}
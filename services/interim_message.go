@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+const (
+	defaultInterimMessageText    = "Sebentar ya, saya sedang cek..."
+	defaultInterimMessageAfterMs = 10000
+)
+
+// InterimMessagePart is the send log part of the interim message ("job:<id>:interim")
+const InterimMessagePart = "interim"
+
+// InterimMessageEnabled reports whether slow LLM calls get an interim text (AI_INTERIM_MESSAGE, default false)
+func InterimMessageEnabled() bool {
+	return config.GetEnvBool("AI_INTERIM_MESSAGE", false)
+}
+
+// InterimMessageDelay returns how long the LLM may take before the interim text is sent
+// (AI_INTERIM_MESSAGE_AFTER_MS, default 10s); 0 when the interim message is off
+func InterimMessageDelay() time.Duration {
+	if !InterimMessageEnabled() {
+		return 0
+	}
+	ms := config.GetEnvInt("AI_INTERIM_MESSAGE_AFTER_MS", defaultInterimMessageAfterMs)
+	if ms <= 0 {
+		ms = defaultInterimMessageAfterMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// InterimMessageText returns the interim text (AI_INTERIM_MESSAGE_TEXT)
+func InterimMessageText() string {
+	if text := config.GetEnvString("AI_INTERIM_MESSAGE_TEXT", ""); text != "" {
+		return text
+	}
+	return defaultInterimMessageText
+}
+
+// SendInterimMessage sends the interim text and returns its WA message ID. It goes to the permanent
+// chat history (the agent UI shows what the customer saw) but not to ai_chat_messages: it is not
+// the reply, so it stays out of the LLM context, reply dedupe and the loop guard.
+func SendInterimMessage(sessionToken, contactJID, text string) (string, error) {
+	waMessageID, err := SendWAText(sessionToken, contactJID, text)
+	if err != nil {
+		return "", fmt.Errorf("failed to send interim message: %w", err)
+	}
+	if err := SaveAIResponseToHistory(sessionToken, contactJID, text); err != nil {
+		log.Printf("⚠️  Failed to save interim message to permanent chat history: %v", err)
+	}
+	return waMessageID, nil
+}
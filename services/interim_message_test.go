@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterimMessageDelay(t *testing.T) {
+	t.Setenv("AI_INTERIM_MESSAGE_AFTER_MS", "2500")
+	t.Setenv("AI_INTERIM_MESSAGE", "false")
+	if got := InterimMessageDelay(); got != 0 {
+		t.Errorf("disabled: delay %v, want 0 (never fires)", got)
+	}
+
+	t.Setenv("AI_INTERIM_MESSAGE", "true")
+	if got := InterimMessageDelay(); got != 2500*time.Millisecond {
+		t.Errorf("delay %v, want 2.5s", got)
+	}
+	t.Setenv("AI_INTERIM_MESSAGE_AFTER_MS", "0")
+	if got := InterimMessageDelay(); got != 10*time.Second {
+		t.Errorf("invalid threshold: delay %v, want the 10s default", got)
+	}
+
+	t.Setenv("AI_INTERIM_MESSAGE_TEXT", "")
+	if InterimMessageText() != defaultInterimMessageText {
+		t.Errorf("default text = %q", InterimMessageText())
+	}
+	t.Setenv("AI_INTERIM_MESSAGE_TEXT", "Tunggu ya kak")
+	if InterimMessageText() != "Tunggu ya kak" {
+		t.Errorf("custom text = %q", InterimMessageText())
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"genfity-wa-support/config"
+)
+
+// defaultPriorityTiers maps subscription package names to AI job priority (lower = processed first)
+const defaultPriorityTiers = "enterprise=1,business=3,starter=5"
+
+// defaultPriorityKeywords bumps urgent messages ahead of the package tier
+const defaultPriorityKeywords = "urgent=1,mendesak=1,darurat=1,complaint=2,komplain=2,cancel=2,batal=2"
+
+// JobPriority returns the AI job priority for a message: the package tier, bumped up when the
+// message body contains an urgent keyword (AI_PRIORITY_KEYWORDS). Keywords never lower priority.
+func JobPriority(packageName, body string) int {
+	priority := JobPriorityForPackage(packageName)
+	if keywordPriority, ok := JobPriorityForMessage(body); ok && keywordPriority < priority {
+		return keywordPriority
+	}
+	return priority
+}
+
+// JobPriorityForMessage returns the best priority of the AI_PRIORITY_KEYWORDS ("keyword=priority,...")
+// found in body. Keywords match case-insensitively at the start of a word, so "cancel" also
+// matches "cancelled"; AI_PRIORITY_KEYWORDS=off disables keyword priority.
+func JobPriorityForMessage(body string) (int, bool) {
+	if strings.TrimSpace(body) == "" {
+		return 0, false
+	}
+
+	raw := os.Getenv("AI_PRIORITY_KEYWORDS")
+	if strings.EqualFold(strings.TrimSpace(raw), "off") {
+		return 0, false
+	}
+	if strings.TrimSpace(raw) == "" {
+		raw = defaultPriorityKeywords
+	}
+
+	best := 0
+	matched := false
+	for keyword, priority := range parsePriorityMap("AI_PRIORITY_KEYWORDS", raw) {
+		if matched && priority >= best {
+			continue
+		}
+		if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(keyword)).MatchString(body) {
+			best = priority
+			matched = true
+		}
+	}
+	return best, matched
+}
+
+// JobPriorityForPackage returns the AI job priority for a subscription package.
+// Tiers come from AI_PRIORITY_TIERS ("name=priority,..."); a tier matches when its name
+// appears in the package name (case-insensitive), and the best matching priority wins.
+// Packages without a matching tier get AI_PRIORITY_DEFAULT (default 5).
+func JobPriorityForPackage(packageName string) int {
+	fallback := config.GetEnvInt("AI_PRIORITY_DEFAULT", 5)
+
+	name := strings.ToLower(strings.TrimSpace(packageName))
+	if name == "" {
+		return fallback
+	}
+
+	best := 0
+	matched := false
+	for tier, priority := range priorityTiers() {
+		if strings.Contains(name, tier) && (!matched || priority < best) {
+			best = priority
+			matched = true
+		}
+	}
+	if !matched {
+		return fallback
+	}
+	return best
+}
+
+// priorityTiers parses AI_PRIORITY_TIERS, skipping malformed entries
+func priorityTiers() map[string]int {
+	raw := os.Getenv("AI_PRIORITY_TIERS")
+	if strings.TrimSpace(raw) == "" {
+		raw = defaultPriorityTiers
+	}
+	return parsePriorityMap("AI_PRIORITY_TIERS", raw)
+}
+
+// parsePriorityMap parses "name=priority,..." (names lowercased), skipping malformed entries
+func parsePriorityMap(envKey, raw string) map[string]int {
+	entries := make(map[string]int)
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		priority, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if name == "" || err != nil {
+			log.Printf("⚠️  Warning: Invalid %s entry %q ignored", envKey, entry)
+			continue
+		}
+		entries[name] = priority
+	}
+	return entries
+}
@@ -0,0 +1,63 @@
+package services
+
+import "testing"
+
+func TestJobPriorityForPackage(t *testing.T) {
+	tests := []struct {
+		name     string
+		tiers    string
+		fallback string
+		pkg      string
+		want     int
+	}{
+		{"default enterprise", "", "", "Enterprise", 1},
+		{"default business substring", "", "", "Business Plan (Yearly)", 3},
+		{"default starter", "", "", "starter", 5},
+		{"unknown package", "", "", "Free Trial", 5},
+		{"no package", "", "", "", 5},
+		{"custom default", "", "7", "Free Trial", 7},
+		{"custom tiers", "pro=2,basic=8", "", "Pro Monthly", 2},
+		{"best match wins", "pro=2,pro max=1", "", "Pro Max", 1},
+		{"malformed entries skipped", "pro=x,gold,vip=1", "", "VIP Gold", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_PRIORITY_TIERS", tt.tiers)
+			t.Setenv("AI_PRIORITY_DEFAULT", tt.fallback)
+			if got := JobPriorityForPackage(tt.pkg); got != tt.want {
+				t.Errorf("JobPriorityForPackage(%q) = %d, want %d", tt.pkg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobPriorityKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords string
+		pkg      string
+		body     string
+		want     int
+	}{
+		{"no keyword keeps tier", "", "Starter", "halo, mau tanya harga", 5},
+		{"urgent bumps starter", "", "Starter", "URGENT: pesanan saya belum sampai", 1},
+		{"complaint bumps starter", "", "Starter", "saya mau komplain", 2},
+		{"word prefix matches", "", "Starter", "order sudah di-cancelled?", 2},
+		{"mid-word does not match", "", "Starter", "ini uncancelable", 5},
+		{"best keyword wins", "", "Starter", "cancel order, urgent!", 1},
+		{"keywords never demote", "", "Enterprise", "mau cancel", 1},
+		{"custom keywords", "refund=2", "Starter", "minta refund", 2},
+		{"custom keywords replace defaults", "refund=2", "Starter", "urgent", 5},
+		{"disabled", "off", "Starter", "urgent", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_PRIORITY_TIERS", "")
+			t.Setenv("AI_PRIORITY_DEFAULT", "")
+			t.Setenv("AI_PRIORITY_KEYWORDS", tt.keywords)
+			if got := JobPriority(tt.pkg, tt.body); got != tt.want {
+				t.Errorf("JobPriority(%q, %q) = %d, want %d", tt.pkg, tt.body, got, tt.want)
+			}
+		})
+	}
+}
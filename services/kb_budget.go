@@ -0,0 +1,91 @@
+package services
+
+import (
+	"strings"
+
+	"genfity-wa-support/config"
+)
+
+// Strategies for splitting AI_MAX_KB_CHARS across the selected documents
+const (
+	KBBudgetPricingFirst = "pricing-first" // pricing docs get their full length first, the rest share what's left
+	KBBudgetProportional = "proportional"  // every doc gets the same fraction of its length
+)
+
+const defaultKnowledgeBudgetChars = 24000 // ~6000 tokens (1 token ≈ 4 chars)
+
+// knowledgeBudgetChars returns the combined max characters of all KB documents in the prompt
+// (AI_MAX_KB_CHARS, default 24000; 0 = only the per-document limits apply)
+func knowledgeBudgetChars() int {
+	budget := config.GetEnvInt("AI_MAX_KB_CHARS", defaultKnowledgeBudgetChars)
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// knowledgeBudgetStrategy returns AI_KB_BUDGET_STRATEGY (pricing-first | proportional, default pricing-first)
+func knowledgeBudgetStrategy() string {
+	if strings.EqualFold(config.GetEnvString("AI_KB_BUDGET_STRATEGY", ""), KBBudgetProportional) {
+		return KBBudgetProportional
+	}
+	return KBBudgetPricingFirst
+}
+
+func isPricingDocument(doc Document) bool {
+	return doc.Kind == "pricing" || doc.Kind == "price"
+}
+
+// documentMaxChars is the per-document limit (pricing docs get more room - most important!)
+func documentMaxChars(doc Document) int {
+	if isPricingDocument(doc) {
+		return 8000
+	}
+	return 5000
+}
+
+// allocateKnowledgeBudget returns how many characters (runes) of each document go into the prompt.
+// Each doc is first capped by documentMaxChars; if the total still exceeds budget it is split per
+// strategy; a doc allocated 0 characters is left out of the prompt.
+func allocateKnowledgeBudget(docs []Document, budget int, strategy string) []int {
+	wants := make([]int, len(docs))
+	total := 0
+	for i, doc := range docs {
+		wants[i] = min(len([]rune(doc.Content)), documentMaxChars(doc))
+		total += wants[i]
+	}
+	if budget <= 0 || total <= budget {
+		return wants
+	}
+
+	limits := make([]int, len(docs))
+	remaining := budget
+	var shared []int
+	for i, doc := range docs {
+		if strategy == KBBudgetPricingFirst && isPricingDocument(doc) {
+			limits[i] = min(wants[i], remaining)
+			remaining -= limits[i]
+		} else {
+			shared = append(shared, i)
+		}
+	}
+
+	// Proportional to each doc's length among the rest
+	sharedTotal := 0
+	for _, i := range shared {
+		sharedTotal += wants[i]
+	}
+	if sharedTotal == 0 || remaining == 0 {
+		return limits
+	}
+	if sharedTotal <= remaining {
+		for _, i := range shared {
+			limits[i] = wants[i]
+		}
+		return limits
+	}
+	for _, i := range shared {
+		limits[i] = wants[i] * remaining / sharedTotal
+	}
+	return limits
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAllocateKnowledgeBudget(t *testing.T) {
+	docs := []Document{
+		{Title: "FAQ", Kind: "faq", Content: strings.Repeat("a", 3000)},
+		{Title: "Harga", Kind: "pricing", Content: strings.Repeat("b", 10000)}, // per-doc cap 8000
+		{Title: "Layanan", Kind: "service", Content: strings.Repeat("c", 1000)},
+	}
+
+	tests := []struct {
+		name     string
+		budget   int
+		strategy string
+		want     []int
+	}{
+		{"no budget keeps per-doc caps", 0, KBBudgetPricingFirst, []int{3000, 8000, 1000}},
+		{"budget not reached", 12000, KBBudgetPricingFirst, []int{3000, 8000, 1000}},
+		{"pricing first, rest shares leftover", 10000, KBBudgetPricingFirst, []int{1500, 8000, 500}},
+		{"pricing alone exhausts budget", 6000, KBBudgetPricingFirst, []int{0, 6000, 0}},
+		{"proportional", 6000, KBBudgetProportional, []int{1500, 4000, 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocateKnowledgeBudget(docs, tt.budget, tt.strategy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("allocateKnowledgeBudget = %v, want %v", got, tt.want)
+			}
+			sum := 0
+			for _, n := range got {
+				sum += n
+			}
+			if tt.budget > 0 && sum > tt.budget {
+				t.Errorf("allocated %d chars, budget %d", sum, tt.budget)
+			}
+		})
+	}
+}
+
+func TestAssembleContextRespectsKnowledgeBudget(t *testing.T) {
+	t.Setenv("AI_MAX_DOCUMENTS", "")
+	t.Setenv("AI_MAX_KB_CHARS", "4000")
+	t.Setenv("AI_KB_BUDGET_STRATEGY", "proportional")
+
+	docs := make([]Document, 4)
+	for i := range docs {
+		docs[i] = Document{Title: fmt.Sprintf("Doc %d", i+1), Kind: "faq", Content: strings.Repeat("é", 3000)}
+	}
+	ctx := AssembleContext(&BotSettings{Documents: docs}, nil, "halo")
+
+	kbStart := strings.Index(ctx.SystemPrompt, "=== Knowledge Base")
+	kbEnd := strings.Index(ctx.SystemPrompt, "--- End of Knowledge Base ---")
+	if kbStart < 0 || kbEnd < 0 {
+		t.Fatalf("knowledge base section missing from prompt")
+	}
+	kb := ctx.SystemPrompt[kbStart:kbEnd]
+
+	if got := strings.Count(kb, "[faq - Doc "); got != 4 {
+		t.Errorf("got %d documents in prompt, want all 4 (each shortened)", got)
+	}
+	if got := strings.Count(kb, "é"); got != 4000 {
+		t.Errorf("knowledge base holds %d document chars, want the 4000 budget", got)
+	}
+
+	t.Setenv("AI_MAX_KB_CHARS", "0")
+	ctx = AssembleContext(&BotSettings{Documents: docs}, nil, "halo")
+	if got := strings.Count(ctx.SystemPrompt, "é"); got != 12000 {
+		t.Errorf("AI_MAX_KB_CHARS=0: prompt holds %d document chars, want all 12000", got)
+	}
+}
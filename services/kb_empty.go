@@ -0,0 +1,89 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"genfity-wa-support/config"
+)
+
+// What the worker does when a bot has no usable knowledge base documents (AI_KB_EMPTY_MODE)
+const (
+	KBEmptyLenient = "lenient" // answer anyway, only log a warning
+	KBEmptyStrict  = "strict"  // pricing questions get the fallback reply instead of a guessed price
+)
+
+const defaultEmptyKBReply = "Mohon maaf, informasi harga belum tersedia saat ini. Tim kami akan segera membantu Anda 🙏"
+
+// emptyKBWarnInterval - the empty-KB warning is logged at most once per bot owner per interval
+const emptyKBWarnInterval = 10 * time.Minute
+
+var emptyKBWarned sync.Map // userID -> time.Time of last warning
+
+// EmptyKBMode returns AI_KB_EMPTY_MODE (lenient | strict, default lenient)
+func EmptyKBMode() string {
+	if strings.EqualFold(config.GetEnvString("AI_KB_EMPTY_MODE", ""), KBEmptyStrict) {
+		return KBEmptyStrict
+	}
+	return KBEmptyLenient
+}
+
+// KnowledgeBaseEmpty reports whether the bot has no document with content
+// (none bound, all inactive, or only blank ones)
+func KnowledgeBaseEmpty(botSettings *BotSettings) bool {
+	if botSettings == nil {
+		return true
+	}
+	for _, doc := range botSettings.Documents {
+		if strings.TrimSpace(doc.Content) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPricingQuery reports whether the message asks about prices. Keywords match at word start
+// ("harganya", "Rp50.000") so "terpercaya" doesn't count as "rp".
+func IsPricingQuery(text string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		for _, keyword := range pricingKeywords {
+			if strings.HasPrefix(word, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ShouldDeclineWithoutKB reports whether the reply must be the fallback instead of an LLM answer:
+// strict mode, an empty knowledge base and a pricing question
+func ShouldDeclineWithoutKB(botSettings *BotSettings, message string) bool {
+	return EmptyKBMode() == KBEmptyStrict && KnowledgeBaseEmpty(botSettings) && IsPricingQuery(message)
+}
+
+// EmptyKBReply returns the bot's fallback text, or AI_KB_EMPTY_MESSAGE / the built-in default
+func EmptyKBReply(botSettings *BotSettings) string {
+	if botSettings != nil && strings.TrimSpace(botSettings.FallbackText) != "" {
+		return botSettings.FallbackText
+	}
+	if msg := config.GetEnvString("AI_KB_EMPTY_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultEmptyKBReply
+}
+
+// WarnEmptyKnowledgeBase logs that a bot is answering without documents (throttled per user)
+func WarnEmptyKnowledgeBase(userID string) {
+	now := time.Now()
+	if last, ok := emptyKBWarned.Load(userID); ok && now.Sub(last.(time.Time)) < emptyKBWarnInterval {
+		return
+	}
+	emptyKBWarned.Store(userID, now)
+	log.Printf("⚠️  Bot of user %s has no active knowledge base documents - answers are not grounded (AI_KB_EMPTY_MODE=%s)", userID, EmptyKBMode())
+}
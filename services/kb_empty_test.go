@@ -0,0 +1,71 @@
+package services
+
+import "testing"
+
+func TestIsPricingQuery(t *testing.T) {
+	cases := map[string]bool{
+		"Berapa harganya kak?":          true,
+		"paket bisnis Rp500rb ada?":     true,
+		"PRICE LIST please":             true,
+		"layanan kalian terpercaya ga?": false, // "rp" only at word start
+		"halo, jam buka kapan?":         false,
+	}
+	for message, want := range cases {
+		if got := IsPricingQuery(message); got != want {
+			t.Errorf("IsPricingQuery(%q) = %v, want %v", message, got, want)
+		}
+	}
+}
+
+func TestKnowledgeBaseEmpty(t *testing.T) {
+	if !KnowledgeBaseEmpty(nil) {
+		t.Errorf("nil settings should count as empty")
+	}
+	blank := &BotSettings{Documents: []Document{{Title: "Harga", Content: "  ", Kind: "pricing"}}}
+	if !KnowledgeBaseEmpty(blank) {
+		t.Errorf("documents without content should count as empty")
+	}
+	filled := &BotSettings{Documents: []Document{{Title: "Harga", Content: "Paket A: Rp100.000", Kind: "pricing"}}}
+	if KnowledgeBaseEmpty(filled) {
+		t.Errorf("bot with a filled document reported empty")
+	}
+}
+
+func TestShouldDeclineWithoutKB(t *testing.T) {
+	empty := &BotSettings{FallbackText: "Silakan hubungi admin kami."}
+	withKB := &BotSettings{Documents: []Document{{Title: "Harga", Content: "Paket A: Rp100.000", Kind: "pricing"}}}
+
+	t.Run("lenient", func(t *testing.T) {
+		t.Setenv("AI_KB_EMPTY_MODE", "")
+		if ShouldDeclineWithoutKB(empty, "berapa harga paket A?") {
+			t.Errorf("lenient mode declined a pricing question")
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		t.Setenv("AI_KB_EMPTY_MODE", "strict")
+		if !ShouldDeclineWithoutKB(empty, "berapa harga paket A?") {
+			t.Errorf("strict mode answered a pricing question without KB")
+		}
+		if ShouldDeclineWithoutKB(empty, "halo, ini dengan siapa?") {
+			t.Errorf("strict mode declined a non-pricing question")
+		}
+		if ShouldDeclineWithoutKB(withKB, "berapa harga paket A?") {
+			t.Errorf("strict mode declined although the KB has documents")
+		}
+	})
+}
+
+func TestEmptyKBReply(t *testing.T) {
+	t.Setenv("AI_KB_EMPTY_MESSAGE", "")
+	if got := EmptyKBReply(&BotSettings{FallbackText: "Hubungi admin"}); got != "Hubungi admin" {
+		t.Errorf("reply = %q, want the bot's fallback text", got)
+	}
+	if got := EmptyKBReply(&BotSettings{}); got != defaultEmptyKBReply {
+		t.Errorf("reply = %q, want the default", got)
+	}
+	t.Setenv("AI_KB_EMPTY_MESSAGE", "Harga menyusul")
+	if got := EmptyKBReply(nil); got != "Harga menyusul" {
+		t.Errorf("reply = %q, want AI_KB_EMPTY_MESSAGE", got)
+	}
+}
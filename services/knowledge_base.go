@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KnowledgeKinds are the accepted AIDocument.kind values ("pricing" docs get priority in the prompt)
+var KnowledgeKinds = []string{"faq", "pricing", "service", "product", "policy", "general"}
+
+const maxKnowledgeTitleChars = 200
+
+var (
+	// ErrDocumentNotFound - no document with that ID for the user
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrInvalidDocument wraps validation failures of a document create/update
+	ErrInvalidDocument = errors.New("invalid document")
+)
+
+// KnowledgeDocumentInput is a document create/update; nil fields are left unchanged on update
+type KnowledgeDocumentInput struct {
+	Title    *string `json:"title"`
+	Kind     *string `json:"kind"`
+	Content  *string `json:"content"`
+	IsActive *bool   `json:"isActive"`
+}
+
+// ValidKnowledgeKind reports whether kind is one of KnowledgeKinds
+func ValidKnowledgeKind(kind string) bool {
+	for _, k := range KnowledgeKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize trims the input and checks it; create requires title and content (kind defaults to faq)
+func (in *KnowledgeDocumentInput) normalize(create bool) error {
+	for _, field := range []*string{in.Title, in.Kind, in.Content} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+	if in.Kind != nil {
+		*in.Kind = strings.ToLower(*in.Kind)
+	}
+	if create && in.Kind == nil {
+		kind := "faq"
+		in.Kind = &kind
+	}
+
+	switch {
+	case (create || in.Title != nil) && (in.Title == nil || *in.Title == ""):
+		return fmt.Errorf("%w: title is required", ErrInvalidDocument)
+	case in.Title != nil && utf8.RuneCountInString(*in.Title) > maxKnowledgeTitleChars:
+		return fmt.Errorf("%w: title is longer than %d characters", ErrInvalidDocument, maxKnowledgeTitleChars)
+	case (create || in.Content != nil) && (in.Content == nil || *in.Content == ""):
+		return fmt.Errorf("%w: content is required", ErrInvalidDocument)
+	case in.Kind != nil && !ValidKnowledgeKind(*in.Kind):
+		return fmt.Errorf("%w: kind must be one of %s", ErrInvalidDocument, strings.Join(KnowledgeKinds, ", "))
+	}
+	return nil
+}
+
+// ListKnowledgeDocuments returns the user's documents, newest first (inactive ones only if asked)
+func ListKnowledgeDocuments(userID string, includeInactive bool) ([]models.AIDocument, error) {
+	db := database.GetTransactionalDB()
+	if db == nil {
+		return nil, fmt.Errorf("transactional database not initialized")
+	}
+	query := db.Where(`"userId" = ?`, userID)
+	if !includeInactive {
+		query = query.Where(`"isActive" = ?`, true)
+	}
+	var docs []models.AIDocument
+	if err := query.Order(`"createdAt" DESC`).Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	return docs, nil
+}
+
+// CreateKnowledgeDocument stores a document and binds it to the user's active bot (if any) so the
+// bot starts using it right away. Returns whether a binding was created.
+func CreateKnowledgeDocument(userID string, in KnowledgeDocumentInput) (*models.AIDocument, bool, error) {
+	if err := in.normalize(true); err != nil {
+		return nil, false, err
+	}
+	db := database.GetTransactionalDB()
+	if db == nil {
+		return nil, false, fmt.Errorf("transactional database not initialized")
+	}
+
+	now := time.Now()
+	doc := models.AIDocument{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Title:     *in.Title,
+		Kind:      *in.Kind,
+		Content:   *in.Content,
+		IsActive:  in.IsActive == nil || *in.IsActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	bound := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&doc).Error; err != nil {
+			return fmt.Errorf("failed to create document: %w", err)
+		}
+
+		var bot models.WhatsAppAIBot
+		err := tx.Where(`"userId" = ? AND "isActive" = ?`, userID, true).First(&bot).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // no bot yet - the document is bound once one is set up in the app
+		} else if err != nil {
+			return fmt.Errorf("failed to load bot: %w", err)
+		}
+		binding := models.BotKnowledgeBinding{
+			ID:         uuid.New().String(),
+			BotID:      bot.ID,
+			DocumentID: doc.ID,
+			IsActive:   true,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := tx.Create(&binding).Error; err != nil {
+			return fmt.Errorf("failed to bind document to bot: %w", err)
+		}
+		bound = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &doc, bound, nil
+}
+
+// UpdateKnowledgeDocument edits the fields set in the input
+func UpdateKnowledgeDocument(userID, documentID string, in KnowledgeDocumentInput) (*models.AIDocument, error) {
+	if err := in.normalize(false); err != nil {
+		return nil, err
+	}
+	doc, err := knowledgeDocumentForUser(userID, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"updatedAt": time.Now()}
+	if in.Title != nil {
+		updates["title"] = *in.Title
+	}
+	if in.Kind != nil {
+		updates["kind"] = *in.Kind
+	}
+	if in.Content != nil {
+		updates["content"] = *in.Content
+	}
+	if in.IsActive != nil {
+		updates["isActive"] = *in.IsActive
+	}
+	if err := database.GetTransactionalDB().Model(doc).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update document: %w", err)
+	}
+	return knowledgeDocumentForUser(userID, documentID)
+}
+
+// DeactivateKnowledgeDocument takes a document out of every bot's knowledge base (the row is kept)
+func DeactivateKnowledgeDocument(userID, documentID string) error {
+	doc, err := knowledgeDocumentForUser(userID, documentID)
+	if err != nil {
+		return err
+	}
+	err = database.GetTransactionalDB().Model(doc).
+		Updates(map[string]interface{}{"isActive": false, "updatedAt": time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("failed to deactivate document: %w", err)
+	}
+	return nil
+}
+
+func knowledgeDocumentForUser(userID, documentID string) (*models.AIDocument, error) {
+	db := database.GetTransactionalDB()
+	if db == nil {
+		return nil, fmt.Errorf("transactional database not initialized")
+	}
+	var doc models.AIDocument
+	if err := db.Where(`"id" = ? AND "userId" = ?`, documentID, userID).First(&doc).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDocumentNotFound
+		}
+		return nil, fmt.Errorf("failed to load document: %w", err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestKnowledgeDocumentInputValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      KnowledgeDocumentInput
+		create  bool
+		invalid bool
+	}{
+		{name: "create ok, kind defaults to faq", in: KnowledgeDocumentInput{Title: strPtr("Jam buka"), Content: strPtr("09-17")}, create: true},
+		{name: "create with kind", in: KnowledgeDocumentInput{Title: strPtr("Harga"), Kind: strPtr(" Pricing "), Content: strPtr("Rp100.000")}, create: true},
+		{name: "create without title", in: KnowledgeDocumentInput{Content: strPtr("isi")}, create: true, invalid: true},
+		{name: "create blank content", in: KnowledgeDocumentInput{Title: strPtr("x"), Content: strPtr("   ")}, create: true, invalid: true},
+		{name: "unknown kind", in: KnowledgeDocumentInput{Title: strPtr("x"), Kind: strPtr("blog"), Content: strPtr("isi")}, create: true, invalid: true},
+		{name: "title too long", in: KnowledgeDocumentInput{Title: strPtr(strings.Repeat("a", 201)), Content: strPtr("isi")}, create: true, invalid: true},
+		{name: "update content only", in: KnowledgeDocumentInput{Content: strPtr("baru")}},
+		{name: "update clears title", in: KnowledgeDocumentInput{Title: strPtr("")}, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.in.normalize(tt.create)
+			if tt.invalid != errors.Is(err, ErrInvalidDocument) {
+				t.Fatalf("normalize() = %v, invalid=%v", err, tt.invalid)
+			}
+			if !tt.invalid && tt.create && !ValidKnowledgeKind(*tt.in.Kind) {
+				t.Errorf("kind %q after normalize", *tt.in.Kind)
+			}
+		})
+	}
+}
+
+func TestKnowledgeDocumentCRUD(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set - skipping database test")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.WhatsAppAIBot{}, &models.AIDocument{}, &models.BotKnowledgeBinding{}); err != nil {
+		t.Fatalf("failed to migrate test tables: %v", err)
+	}
+	previous := database.TransactionalDB
+	database.TransactionalDB = db
+	t.Cleanup(func() { database.TransactionalDB = previous })
+
+	userID := fmt.Sprintf("kb-user-%d", time.Now().UnixNano())
+	bot := models.WhatsAppAIBot{ID: userID + "-bot", UserID: userID, Name: "Bot", IsActive: true, UpdatedAt: time.Now()}
+	if err := db.Create(&bot).Error; err != nil {
+		t.Fatalf("failed to seed bot: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where(`"botId" = ?`, bot.ID).Delete(&models.BotKnowledgeBinding{})
+		db.Where(`"userId" = ?`, userID).Delete(&models.AIDocument{})
+		db.Delete(&bot)
+	})
+
+	doc, bound, err := CreateKnowledgeDocument(userID, KnowledgeDocumentInput{Title: strPtr("Harga"), Kind: strPtr("pricing"), Content: strPtr("Rp100.000")})
+	if err != nil || !bound {
+		t.Fatalf("CreateKnowledgeDocument = %v, bound=%v", err, bound)
+	}
+	var bindings int64
+	db.Model(&models.BotKnowledgeBinding{}).Where(`"botId" = ? AND "documentId" = ?`, bot.ID, doc.ID).Count(&bindings)
+	if bindings != 1 {
+		t.Errorf("document bound %d times, want 1", bindings)
+	}
+
+	updated, err := UpdateKnowledgeDocument(userID, doc.ID, KnowledgeDocumentInput{Content: strPtr("Rp150.000")})
+	if err != nil || updated.Content != "Rp150.000" || updated.Title != "Harga" {
+		t.Fatalf("UpdateKnowledgeDocument = %+v, %v", updated, err)
+	}
+	if _, err := UpdateKnowledgeDocument("someone-else", doc.ID, KnowledgeDocumentInput{Content: strPtr("x")}); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("update by another user: err = %v, want ErrDocumentNotFound", err)
+	}
+
+	if err := DeactivateKnowledgeDocument(userID, doc.ID); err != nil {
+		t.Fatalf("DeactivateKnowledgeDocument: %v", err)
+	}
+	if active, _ := ListKnowledgeDocuments(userID, false); len(active) != 0 {
+		t.Errorf("deactivated document still listed: %+v", active)
+	}
+	if all, _ := ListKnowledgeDocuments(userID, true); len(all) != 1 || all[0].IsActive {
+		t.Errorf("includeInactive list = %+v", all)
+	}
+}
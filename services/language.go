@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"genfity-wa-support/config"
+)
+
+// languageStopwords are frequent function words used for a lightweight language guess
+var languageStopwords = map[string]map[string]bool{
+	"id": wordSet("yang", "dan", "di", "ke", "dari", "ini", "itu", "untuk", "dengan", "tidak", "ada", "kami", "anda", "kak",
+		"bisa", "akan", "sudah", "juga", "saya", "apa", "atau", "karena", "jika", "kalau", "silakan", "terima", "kasih", "mohon", "maaf", "dalam", "pada", "adalah"),
+	"en": wordSet("the", "and", "is", "are", "to", "of", "for", "with", "you", "your", "we", "our", "this", "that", "can",
+		"will", "have", "has", "not", "please", "thank", "thanks", "what", "in", "on", "it", "be", "if", "or", "sorry", "would", "an"),
+}
+
+// languageNames are used in the regenerate/translate instructions
+var languageNames = map[string]string{
+	"id": "Bahasa Indonesia",
+	"en": "English",
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// DetectLanguage guesses the language ("id" or "en") of text from stopword counts.
+// Returns "" when the text is too short or the signal is ambiguous.
+func DetectLanguage(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	scores := make(map[string]int)
+	for _, word := range words {
+		for lang, stopwords := range languageStopwords {
+			if stopwords[word] {
+				scores[lang]++
+			}
+		}
+	}
+
+	id, en := scores["id"], scores["en"]
+	switch {
+	case id+en < 3:
+		return "" // not enough signal (short replies, names, numbers)
+	case id >= 2*en && id > en:
+		return "id"
+	case en >= 2*id && en > id:
+		return "en"
+	default:
+		return ""
+	}
+}
+
+// ExpectedResponseLanguage returns the enforced reply language (AI_RESPONSE_LANGUAGE, "" = disabled)
+func ExpectedResponseLanguage() string {
+	lang := strings.ToLower(config.GetEnvString("AI_RESPONSE_LANGUAGE", ""))
+	if lang == "" {
+		return ""
+	}
+	if _, ok := languageNames[lang]; !ok {
+		log.Printf("⚠️  Warning: Unsupported AI_RESPONSE_LANGUAGE=%q (supported: id, en) - enforcement disabled", lang)
+		return ""
+	}
+	return lang
+}
+
+// LanguageAuto as a bot's responseLanguage turns enforcement off for that bot (multilingual bots
+// answer in the customer's language), even when AI_RESPONSE_LANGUAGE is set
+const LanguageAuto = "auto"
+
+// BotResponseLanguage returns the reply language enforced for a bot: its responseLanguage
+// ("id" | "en", "auto" = none), else AI_RESPONSE_LANGUAGE. An unsupported value falls back to the global.
+func BotResponseLanguage(botSettings *BotSettings) string {
+	if botSettings == nil || botSettings.ResponseLanguage == "" {
+		return ExpectedResponseLanguage()
+	}
+	lang := strings.ToLower(strings.TrimSpace(botSettings.ResponseLanguage))
+	if lang == LanguageAuto {
+		return ""
+	}
+	if _, ok := languageNames[lang]; !ok {
+		log.Printf("⚠️  Warning: Unsupported bot responseLanguage=%q (supported: id, en, auto) - using AI_RESPONSE_LANGUAGE", lang)
+		return ExpectedResponseLanguage()
+	}
+	return lang
+}
+
+// responseLanguageInstructions is appended to the system prompt when a reply language is enforced
+func responseLanguageInstructions(lang string) string {
+	name := languageNames[lang]
+	return fmt.Sprintf(`
+
+=== BAHASA ===
+Selalu balas dalam %s (Always respond in %s), apa pun bahasa yang dipakai customer atau knowledge base.
+`, name, name)
+}
+
+// IsLanguageMismatch reports whether reply is confidently detected as a language other than expected
+func IsLanguageMismatch(reply, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	detected := DetectLanguage(reply)
+	return detected != "" && detected != expected
+}
+
+// EnforceResponseLanguage checks the reply language and, on a mismatch, regenerates the reply once
+// with an explicit instruction (AI_LANGUAGE_ENFORCE_MODE=regenerate, default) or translates it
+// (translate). Returns the reply to send plus the extra tokens used; on any failure the original
+// reply is kept.
+func EnforceResponseLanguage(ctx context.Context, aiProvider AIProvider, systemPrompt, userMessage, reply, expected string) (string, int, int) {
+	if !IsLanguageMismatch(reply, expected) {
+		return reply, 0, 0
+	}
+
+	languageName := languageNames[expected]
+	mode := strings.ToLower(config.GetEnvString("AI_LANGUAGE_ENFORCE_MODE", "regenerate"))
+	log.Printf("🌐 Reply language mismatch (expected %s, detected %s) - %s", expected, DetectLanguage(reply), mode)
+
+	var newReply string
+	var inTok, outTok int
+	var err error
+	if mode == "translate" {
+		instruction := fmt.Sprintf("Translate the following WhatsApp message into %s. Keep the meaning, tone and formatting. Output only the translation.", languageName)
+		newReply, inTok, outTok, err = aiProvider.AskLLM(ctx, instruction, reply)
+	} else {
+		instruction := fmt.Sprintf("%s\n\nPENTING: Balas HANYA dalam %s, apa pun bahasa yang dipakai sebelumnya.", systemPrompt, languageName)
+		newReply, inTok, outTok, err = aiProvider.AskLLM(ctx, instruction, userMessage)
+	}
+
+	if err != nil || strings.TrimSpace(newReply) == "" {
+		log.Printf("⚠️  Language enforcement failed, keeping original reply: %v", err)
+		return reply, inTok, outTok
+	}
+	return newReply, inTok, outTok
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Terima kasih kak, pesanan anda sudah kami proses dan akan dikirim hari ini.", "id"},
+		{"Thank you, your order has been processed and will be shipped today.", "en"},
+		{"Oke", ""},
+		{"Rp 150.000", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsLanguageMismatch(t *testing.T) {
+	english := "Thank you for your message, we will get back to you shortly."
+	indonesian := "Terima kasih atas pesan anda, kami akan segera membalas."
+
+	if !IsLanguageMismatch(english, "id") {
+		t.Errorf("English reply should mismatch expected id")
+	}
+	if IsLanguageMismatch(indonesian, "id") {
+		t.Errorf("Indonesian reply should match expected id")
+	}
+	if IsLanguageMismatch("Ok 👍", "id") {
+		t.Errorf("undetectable reply must not count as a mismatch")
+	}
+	if IsLanguageMismatch(english, "") {
+		t.Errorf("enforcement disabled must never mismatch")
+	}
+}
+
+func TestExpectedResponseLanguage(t *testing.T) {
+	for env, want := range map[string]string{"": "", "ID": "id", "en": "en", "fr": ""} {
+		t.Setenv("AI_RESPONSE_LANGUAGE", env)
+		if got := ExpectedResponseLanguage(); got != want {
+			t.Errorf("AI_RESPONSE_LANGUAGE=%q: got %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestBotResponseLanguage(t *testing.T) {
+	t.Setenv("AI_RESPONSE_LANGUAGE", "id")
+	tests := []struct {
+		name     string
+		settings *BotSettings
+		want     string
+	}{
+		{"no settings", nil, "id"},
+		{"unset uses the global", &BotSettings{}, "id"},
+		{"bot override", &BotSettings{ResponseLanguage: "EN"}, "en"},
+		{"multilingual bot", &BotSettings{ResponseLanguage: "auto"}, ""},
+		{"unsupported falls back", &BotSettings{ResponseLanguage: "fr"}, "id"},
+	}
+	for _, tt := range tests {
+		if got := BotResponseLanguage(tt.settings); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	t.Setenv("AI_RESPONSE_LANGUAGE", "")
+	if got := BotResponseLanguage(&BotSettings{ResponseLanguage: "id"}); got != "id" {
+		t.Errorf("per-bot language without a global: got %q, want id", got)
+	}
+}
+
+func TestAssembleContextAddsResponseLanguageInstruction(t *testing.T) {
+	t.Setenv("AI_RESPONSE_LANGUAGE", "")
+	ctx := AssembleContext(&BotSettings{SystemPrompt: "Kamu adalah CS toko.", ResponseLanguage: "en"}, nil, "halo")
+	if !strings.Contains(ctx.SystemPrompt, "Always respond in English") {
+		t.Errorf("system prompt lacks the language instruction: %q", ctx.SystemPrompt)
+	}
+
+	ctx = AssembleContext(&BotSettings{SystemPrompt: "Kamu adalah CS toko.", ResponseLanguage: "auto"}, nil, "halo")
+	if strings.Contains(ctx.SystemPrompt, "=== BAHASA ===") {
+		t.Errorf("multilingual bot must not get a language instruction: %q", ctx.SystemPrompt)
+	}
+}
+
+func TestPerBotLanguageMismatchRetriesOnce(t *testing.T) {
+	t.Setenv("AI_RESPONSE_LANGUAGE", "")
+	t.Setenv("AI_LANGUAGE_ENFORCE_MODE", "")
+	// The model keeps answering in English: one retry, then the result is used as is
+	provider := &scriptedAIProvider{reply: englishReply}
+	expected := BotResponseLanguage(&BotSettings{ResponseLanguage: "id"})
+
+	reply, _, _ := EnforceResponseLanguage(context.Background(), provider, "Kamu adalah CS toko.", "halo", englishReply, expected)
+
+	if len(provider.systemPrompts) != 1 {
+		t.Fatalf("expected exactly one retry, got %d LLM calls", len(provider.systemPrompts))
+	}
+	if reply != englishReply {
+		t.Errorf("got %q, want the retried reply", reply)
+	}
+}
+
+// scriptedAIProvider returns a fixed reply (or error) and records prompts
+type scriptedAIProvider struct {
+	reply         string
+	err           error
+	systemPrompts []string
+	userPrompts   []string
+}
+
+func (p *scriptedAIProvider) AskLLM(ctx context.Context, systemPrompt, userPrompt string) (string, int, int, error) {
+	p.systemPrompts = append(p.systemPrompts, systemPrompt)
+	p.userPrompts = append(p.userPrompts, userPrompt)
+	return p.reply, 7, 3, p.err
+}
+func (p *scriptedAIProvider) GetProviderName() string { return "scripted" }
+func (p *scriptedAIProvider) GetModelName() string    { return "scripted-model" }
+
+const (
+	englishReply    = "Thank you for your message, we will get back to you shortly."
+	indonesianReply = "Terima kasih atas pesan anda, kami akan segera membalas."
+)
+
+func TestEnforceResponseLanguageRegenerates(t *testing.T) {
+	t.Setenv("AI_LANGUAGE_ENFORCE_MODE", "")
+	provider := &scriptedAIProvider{reply: indonesianReply}
+
+	reply, inTok, outTok := EnforceResponseLanguage(context.Background(), provider, "Kamu adalah CS toko.", "halo", englishReply, "id")
+
+	if reply != indonesianReply || inTok != 7 || outTok != 3 {
+		t.Errorf("got %q (%d/%d), want regenerated reply with its tokens", reply, inTok, outTok)
+	}
+	if len(provider.systemPrompts) != 1 {
+		t.Fatalf("expected exactly one regeneration, got %d", len(provider.systemPrompts))
+	}
+	if !strings.Contains(provider.systemPrompts[0], "Kamu adalah CS toko.") || !strings.Contains(provider.systemPrompts[0], "Bahasa Indonesia") {
+		t.Errorf("regeneration prompt must keep the system prompt and name the language: %q", provider.systemPrompts[0])
+	}
+	if provider.userPrompts[0] != "halo" {
+		t.Errorf("regeneration must resend the user message, got %q", provider.userPrompts[0])
+	}
+}
+
+func TestEnforceResponseLanguageTranslates(t *testing.T) {
+	t.Setenv("AI_LANGUAGE_ENFORCE_MODE", "translate")
+	provider := &scriptedAIProvider{reply: indonesianReply}
+
+	reply, _, _ := EnforceResponseLanguage(context.Background(), provider, "Kamu adalah CS toko.", "halo", englishReply, "id")
+
+	if reply != indonesianReply {
+		t.Errorf("got %q, want translated reply", reply)
+	}
+	if provider.userPrompts[0] != englishReply || !strings.Contains(provider.systemPrompts[0], "Translate") {
+		t.Errorf("translate must send the original reply with a translation instruction")
+	}
+}
+
+func TestEnforceResponseLanguageKeepsMatchingReply(t *testing.T) {
+	provider := &scriptedAIProvider{reply: "should not be called"}
+
+	reply, inTok, outTok := EnforceResponseLanguage(context.Background(), provider, "sys", "halo", indonesianReply, "id")
+
+	if reply != indonesianReply || inTok != 0 || outTok != 0 || len(provider.userPrompts) != 0 {
+		t.Errorf("matching reply must be returned unchanged without an extra LLM call")
+	}
+}
+
+func TestEnforceResponseLanguageKeepsOriginalOnError(t *testing.T) {
+	t.Setenv("AI_LANGUAGE_ENFORCE_MODE", "")
+	provider := &scriptedAIProvider{err: errors.New("rate limited")}
+
+	reply, _, _ := EnforceResponseLanguage(context.Background(), provider, "sys", "halo", englishReply, "id")
+
+	if reply != englishReply {
+		t.Errorf("got %q, want original reply when regeneration fails", reply)
+	}
+}
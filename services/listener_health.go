@@ -0,0 +1,127 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// defaultListenerMaxDisconnected is how long LISTEN may be down before health reports it degraded
+const defaultListenerMaxDisconnected = 5 * time.Minute
+
+// ListenerHealth is the state of the AI worker's LISTEN connection. Jobs are still picked up by
+// polling while it is down - Degraded only means they wait up to AI_POLL_INTERVAL_MS longer.
+type ListenerHealth struct {
+	Connected           bool       `json:"connected"`
+	StartedAt           *time.Time `json:"started_at,omitempty"`
+	LastConnectedAt     *time.Time `json:"last_connected_at,omitempty"`
+	LastDisconnectedAt  *time.Time `json:"last_disconnected_at,omitempty"`
+	LastNotificationAt  *time.Time `json:"last_notification_at,omitempty"`
+	Reconnects          int64      `json:"reconnects"`
+	DisconnectedSeconds int64      `json:"disconnected_seconds,omitempty"`
+	Degraded            bool       `json:"degraded"` // down longer than AI_LISTEN_MAX_DISCONNECTED_SECONDS
+	Warning             string     `json:"warning,omitempty"`
+}
+
+// listenerMonitor tracks the LISTEN connection, fed by the AI worker's pq.Listener events
+type listenerMonitor struct {
+	mu             sync.Mutex
+	state          ListenerHealth
+	warnedDegraded bool
+}
+
+var jobListener = &listenerMonitor{}
+
+// listenerMaxDisconnected returns AI_LISTEN_MAX_DISCONNECTED_SECONDS (default 300)
+func listenerMaxDisconnected() time.Duration {
+	seconds := config.GetEnvInt("AI_LISTEN_MAX_DISCONNECTED_SECONDS", int(defaultListenerMaxDisconnected/time.Second))
+	if seconds <= 0 {
+		return defaultListenerMaxDisconnected
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// MarkListenerStarted records that the worker started listening (not connected yet)
+func MarkListenerStarted() {
+	jobListener.mu.Lock()
+	defer jobListener.mu.Unlock()
+	now := time.Now()
+	jobListener.state = ListenerHealth{StartedAt: &now}
+	jobListener.warnedDegraded = false
+}
+
+// MarkListenerConnected records a (re)established LISTEN connection
+func MarkListenerConnected() {
+	jobListener.mu.Lock()
+	defer jobListener.mu.Unlock()
+	now := time.Now()
+	if jobListener.state.LastConnectedAt != nil {
+		jobListener.state.Reconnects++
+	}
+	jobListener.state.Connected = true
+	jobListener.state.LastConnectedAt = &now
+	if jobListener.warnedDegraded {
+		log.Printf("✅ [LISTEN] Instant notifications restored")
+		jobListener.warnedDegraded = false
+	}
+}
+
+// MarkListenerDisconnected records a lost LISTEN connection
+func MarkListenerDisconnected() {
+	jobListener.mu.Lock()
+	defer jobListener.mu.Unlock()
+	if !jobListener.state.Connected {
+		return // still (or never) up - keep the time it went down
+	}
+	now := time.Now()
+	jobListener.state.Connected = false
+	jobListener.state.LastDisconnectedAt = &now
+}
+
+// MarkListenerNotification records a received NOTIFY
+func MarkListenerNotification() {
+	jobListener.mu.Lock()
+	defer jobListener.mu.Unlock()
+	now := time.Now()
+	jobListener.state.LastNotificationAt = &now
+}
+
+// GetListenerHealth returns the LISTEN state, flagging it degraded when it has been down (or never
+// connected) for longer than AI_LISTEN_MAX_DISCONNECTED_SECONDS
+func GetListenerHealth() ListenerHealth {
+	jobListener.mu.Lock()
+	defer jobListener.mu.Unlock()
+	return jobListener.state.at(time.Now(), listenerMaxDisconnected())
+}
+
+// at fills the derived fields of h as of now
+func (h ListenerHealth) at(now time.Time, maxDisconnected time.Duration) ListenerHealth {
+	if h.Connected || h.StartedAt == nil {
+		return h
+	}
+	down := *h.StartedAt
+	if h.LastDisconnectedAt != nil {
+		down = *h.LastDisconnectedAt
+	}
+	h.DisconnectedSeconds = int64(now.Sub(down) / time.Second)
+	if now.Sub(down) > maxDisconnected {
+		h.Degraded = true
+		h.Warning = "LISTEN connection down for " + now.Sub(down).Round(time.Second).String() + " - jobs are only picked up by polling"
+	}
+	return h
+}
+
+// CheckListenerHealth logs a warning once when LISTEN has been down past the threshold
+// (called periodically by the worker); returns the current health
+func CheckListenerHealth() ListenerHealth {
+	health := GetListenerHealth()
+	jobListener.mu.Lock()
+	defer jobListener.mu.Unlock()
+	if health.Degraded && !jobListener.warnedDegraded {
+		log.Printf("⚠️  [LISTEN] %s", health.Warning)
+		jobListener.warnedDegraded = true
+	}
+	return health
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListenerHealthDegradesAfterThreshold(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	down := start.Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		state        ListenerHealth
+		now          time.Time
+		wantDegraded bool
+		wantSeconds  int64
+	}{
+		{"connected", ListenerHealth{Connected: true, StartedAt: &start, LastConnectedAt: &start}, start.Add(24 * time.Hour), false, 0},
+		{"not started", ListenerHealth{}, start, false, 0},
+		{"never connected, within threshold", ListenerHealth{StartedAt: &start}, start.Add(time.Minute), false, 60},
+		{"never connected, past threshold", ListenerHealth{StartedAt: &start}, start.Add(10 * time.Minute), true, 600},
+		{"disconnected briefly", ListenerHealth{StartedAt: &start, LastConnectedAt: &start, LastDisconnectedAt: &down}, down.Add(30 * time.Second), false, 30},
+		{"disconnected for days", ListenerHealth{StartedAt: &start, LastConnectedAt: &start, LastDisconnectedAt: &down}, down.Add(48 * time.Hour), true, 172800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.state.at(tt.now, 5*time.Minute)
+			if got.Degraded != tt.wantDegraded || got.DisconnectedSeconds != tt.wantSeconds {
+				t.Errorf("degraded = %v, disconnected = %ds; want %v, %ds", got.Degraded, got.DisconnectedSeconds, tt.wantDegraded, tt.wantSeconds)
+			}
+			if got.Degraded && got.Warning == "" {
+				t.Error("degraded without a warning")
+			}
+		})
+	}
+}
+
+func TestListenerEventsTrackConnection(t *testing.T) {
+	MarkListenerStarted()
+	t.Cleanup(MarkListenerStarted)
+
+	MarkListenerDisconnected() // before the first connect: still "down since start"
+	if h := GetListenerHealth(); h.Connected || h.LastDisconnectedAt != nil {
+		t.Fatalf("after start: %+v", h)
+	}
+
+	MarkListenerConnected()
+	MarkListenerNotification()
+	MarkListenerDisconnected()
+	first := *GetListenerHealth().LastDisconnectedAt
+	MarkListenerDisconnected() // repeated event keeps the original time
+	MarkListenerConnected()
+
+	h := GetListenerHealth()
+	if !h.Connected || h.Reconnects != 1 || h.LastNotificationAt == nil {
+		t.Errorf("health = %+v, want connected with 1 reconnect and a notification", h)
+	}
+	if !h.LastDisconnectedAt.Equal(first) {
+		t.Errorf("last disconnect moved from %v to %v", first, h.LastDisconnectedAt)
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for the global LLM call limiter (override via .env)
+const (
+	defaultLLMRatePerSecond = 10.0
+	defaultLLMBurst         = 10
+)
+
+// llmRatePerSecond returns AI_LLM_RATE_PER_SECOND (outbound LLM calls per second for the whole
+// process, 0 = unlimited). Fractions are allowed.
+func llmRatePerSecond() float64 {
+	raw := config.GetEnvString("AI_LLM_RATE_PER_SECOND", "")
+	if raw == "" {
+		return defaultLLMRatePerSecond
+	}
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil || rate < 0 {
+		return defaultLLMRatePerSecond
+	}
+	return rate
+}
+
+// llmBurst returns AI_LLM_BURST (calls that may start at once after a quiet period)
+func llmBurst() int {
+	burst := config.GetEnvInt("AI_LLM_BURST", defaultLLMBurst)
+	if burst < 1 {
+		return 1
+	}
+	return burst
+}
+
+// llmLimiter is one token bucket shared by every worker goroutine. tokens may go negative:
+// calls already queued for a future slot.
+type llmLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+var globalLLMLimiter = &llmLimiter{}
+
+// reserve books the next call slot and returns how long to wait for it. A wait longer than
+// maxWait books nothing and returns ok=false.
+func (l *llmLimiter) reserve(now time.Time, rate float64, burst int, maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(burst)
+	if l.last.IsZero() {
+		l.tokens, l.last = capacity, now
+	}
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+		if l.tokens > capacity {
+			l.tokens = capacity
+		}
+		l.last = now
+	}
+
+	var wait time.Duration
+	if l.tokens < 1 {
+		wait = time.Duration((1 - l.tokens) / rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+// release returns a booked slot the caller gave up on
+func (l *llmLimiter) release() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// WaitLLMSlot blocks until the process may start its next LLM call (token bucket of
+// AI_LLM_RATE_PER_SECOND with bursts of AI_LLM_BURST). It complements the circuit breaker:
+// during an incident, concurrent jobs cannot fire doomed requests faster than the limit.
+// It only errors when ctx (the job's AI timeout) would end before a slot is free.
+func WaitLLMSlot(ctx context.Context) error {
+	rate := llmRatePerSecond()
+	if rate == 0 {
+		return nil
+	}
+
+	maxWait := time.Duration(1<<63 - 1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+	wait, ok := globalLLMLimiter.reserve(time.Now(), rate, llmBurst(), maxWait)
+	if !ok {
+		return fmt.Errorf("LLM rate limit: next slot in %s is past the request deadline", wait.Round(time.Millisecond))
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if wait >= time.Second {
+		log.Printf("🚦 LLM rate limit: waiting %s for a call slot", wait.Round(time.Millisecond))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		globalLLMLimiter.release()
+		return ctx.Err()
+	}
+}
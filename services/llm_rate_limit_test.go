@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLLMLimiterReserve(t *testing.T) {
+	l := &llmLimiter{}
+	now := time.Unix(1700000000, 0)
+
+	// A burst of 2 goes out at once, the third call waits for the refill (rate 4/s = 250ms)
+	for i := 0; i < 2; i++ {
+		if wait, ok := l.reserve(now, 4, 2, time.Second); !ok || wait != 0 {
+			t.Fatalf("call %d: wait = %v, ok = %v, want immediate", i, wait, ok)
+		}
+	}
+	if wait, ok := l.reserve(now, 4, 2, time.Second); !ok || wait != 250*time.Millisecond {
+		t.Fatalf("third call: wait = %v, ok = %v, want 250ms", wait, ok)
+	}
+
+	// A slot past maxWait books nothing
+	if _, ok := l.reserve(now, 4, 2, 100*time.Millisecond); ok {
+		t.Fatal("expected the call to be refused past maxWait")
+	}
+	if wait, _ := l.reserve(now, 4, 2, time.Second); wait != 500*time.Millisecond {
+		t.Errorf("fourth call: wait = %v, want 500ms (the refused call booked nothing)", wait)
+	}
+}
+
+func TestWaitLLMSlotBoundsAggregateRate(t *testing.T) {
+	globalLLMLimiter = &llmLimiter{}
+	t.Cleanup(func() { globalLLMLimiter = &llmLimiter{} })
+	t.Setenv("AI_LLM_RATE_PER_SECOND", "50")
+	t.Setenv("AI_LLM_BURST", "5")
+
+	const calls = 20
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := WaitLLMSlot(context.Background()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 5 calls go out at once, the other 15 at 50/s: at least 300ms in total
+	minElapsed := time.Duration(calls-5) * time.Second / 50
+	if elapsed := time.Since(start); elapsed < minElapsed-20*time.Millisecond {
+		t.Errorf("%d calls took %v, want at least %v", calls, elapsed, minElapsed)
+	}
+}
+
+func TestWaitLLMSlotRespectsDeadline(t *testing.T) {
+	globalLLMLimiter = &llmLimiter{}
+	t.Cleanup(func() { globalLLMLimiter = &llmLimiter{} })
+	t.Setenv("AI_LLM_RATE_PER_SECOND", "1")
+	t.Setenv("AI_LLM_BURST", "1")
+
+	if err := WaitLLMSlot(context.Background()); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := WaitLLMSlot(ctx); err == nil {
+		t.Fatal("expected an error when the next slot is past the deadline")
+	}
+	if time.Since(start) > 40*time.Millisecond {
+		t.Error("a call that cannot make its deadline should fail without waiting")
+	}
+
+	t.Setenv("AI_LLM_RATE_PER_SECOND", "0")
+	if err := WaitLLMSlot(ctx); err != nil {
+		t.Errorf("rate 0 disables the limiter, got %v", err)
+	}
+}
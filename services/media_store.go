@@ -0,0 +1,376 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// waMediaDownloadPath is the WA server endpoint that returns the media of a received message
+const waMediaDownloadPath = "/chat/downloadmedia"
+
+// mediaDownloadTimeout bounds one media download from the WA server
+const mediaDownloadTimeout = 60 * time.Second
+
+// defaultMediaMaxBytes is the largest file archived when MEDIA_MAX_BYTES is unset (WhatsApp's own
+// limit for images/video is 16 MB)
+const defaultMediaMaxBytes = 16 << 20
+
+var (
+	ErrMediaNotFound       = errors.New("media not found")
+	errMediaTooLarge       = errors.New("media exceeds MEDIA_MAX_BYTES")
+	errMediaTypeNotAllowed = errors.New("media content type not allowed")
+)
+
+// allowedMediaTypes are the content types (exact, or prefix when ending in "/" or ".") that get archived
+var allowedMediaTypes = []string{
+	"image/", "video/", "audio/", "text/plain", "text/csv",
+	"application/pdf", "application/zip", "application/msword", "application/rtf",
+	"application/vnd.ms-", "application/vnd.openxmlformats-officedocument.", "application/vnd.oasis.opendocument.",
+}
+
+// mediaExtensions picks the file extension for common types (mime.ExtensionsByType depends on the host)
+var mediaExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"video/mp4":       ".mp4",
+	"video/3gpp":      ".3gp",
+	"audio/ogg":       ".ogg",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// mediaBackend stores archived media files under a key
+type mediaBackend interface {
+	Name() string
+	Put(key, contentType string, data []byte) error
+	Get(key string) ([]byte, error)
+}
+
+// MediaStoreBackend returns MEDIA_STORE_BACKEND: "local", "s3", or "" (incoming media not archived)
+func MediaStoreBackend() string {
+	backend := strings.ToLower(config.GetEnvString("MEDIA_STORE_BACKEND", ""))
+	if backend == "none" || backend == "off" {
+		return ""
+	}
+	return backend
+}
+
+// MediaStoreEnabled reports whether incoming media is downloaded and archived
+func MediaStoreEnabled() bool {
+	return MediaStoreBackend() != ""
+}
+
+// IsMediaMessageType reports whether msgType (as stored in ai_chat_messages) is a media message
+func IsMediaMessageType(msgType string) bool {
+	_, ok := mediaLabels[msgType]
+	return ok
+}
+
+// mediaMaxBytes returns MEDIA_MAX_BYTES (default 16 MB)
+func mediaMaxBytes() int64 {
+	limit := config.GetEnvInt("MEDIA_MAX_BYTES", defaultMediaMaxBytes)
+	if limit <= 0 {
+		return defaultMediaMaxBytes
+	}
+	return int64(limit)
+}
+
+// newMediaBackend builds the configured backend (nil when archiving is off)
+func newMediaBackend(name string) (mediaBackend, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "local":
+		return localMediaBackend{dir: config.GetEnvString("MEDIA_LOCAL_DIR", "data/media")}, nil
+	case "s3":
+		return newS3MediaBackend()
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORE_BACKEND %q (use local or s3)", name)
+	}
+}
+
+// localMediaBackend keeps files on disk under dir (MEDIA_LOCAL_DIR)
+type localMediaBackend struct {
+	dir string
+}
+
+func (b localMediaBackend) Name() string { return "local" }
+
+func (b localMediaBackend) path(key string) (string, error) {
+	path := filepath.Join(b.dir, filepath.FromSlash(key))
+	if rel, err := filepath.Rel(b.dir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	return path, nil
+}
+
+func (b localMediaBackend) Put(key, contentType string, data []byte) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create media directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write media file: %w", err)
+	}
+	return nil
+}
+
+func (b localMediaBackend) Get(key string) ([]byte, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMediaNotFound
+	}
+	return data, err
+}
+
+// mediaDownloadResponse is the JSON shape of the WA server download endpoint (data URI in Data)
+type mediaDownloadResponse struct {
+	Data struct {
+		Data     string `json:"Data"`
+		Mimetype string `json:"Mimetype"`
+	} `json:"data"`
+}
+
+// downloadMedia fetches a received message's media from the WA server (a var so tests can stub it)
+var downloadMedia = downloadMediaFromWAServer
+
+// downloadMediaFromWAServer calls the WA server download endpoint with the message ID. The server
+// answers either with the raw file or with JSON holding a base64 data URI; both are accepted.
+func downloadMediaFromWAServer(sessionToken, messageID string, maxBytes int64) ([]byte, string, error) {
+	waServerURL := WAServerURLForSession(sessionToken)
+	if waServerURL == "" {
+		return nil, "", fmt.Errorf("WA_SERVER_URL not configured")
+	}
+
+	payload, err := json.Marshal(map[string]string{"MessageID": messageID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequest("POST", waServerURL+waMediaDownloadPath, bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("token", sessionToken)
+
+	resp, err := DoHTTP(req, mediaDownloadTimeout)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, "", fmt.Errorf("WA Server returned status %d: %s", resp.StatusCode, string(body))
+	}
+	if resp.ContentLength > 0 && resp.ContentLength > maxBytes*2 {
+		return nil, "", errMediaTooLarge // even base64-encoded it can't be under the limit
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read media: %w", err)
+		}
+		if int64(len(data)) > maxBytes {
+			return nil, "", errMediaTooLarge
+		}
+		return data, contentType, nil
+	}
+
+	// base64 is 4/3 of the file, plus the JSON around it
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, (maxBytes+2)/3*4+4096))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read media: %w", err)
+	}
+	var parsed mediaDownloadResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, "", fmt.Errorf("failed to parse media response (over MEDIA_MAX_BYTES?): %w", err)
+	}
+	return decodeMediaDataURI(parsed.Data.Data, parsed.Data.Mimetype, maxBytes)
+}
+
+// decodeMediaDataURI decodes "data:<type>;base64,<data>" (or bare base64 with fallbackType)
+func decodeMediaDataURI(dataURI, fallbackType string, maxBytes int64) ([]byte, string, error) {
+	contentType, encoded := fallbackType, dataURI
+	if strings.HasPrefix(dataURI, "data:") {
+		header, rest, ok := strings.Cut(strings.TrimPrefix(dataURI, "data:"), ",")
+		if !ok {
+			return nil, "", fmt.Errorf("invalid media data URI")
+		}
+		if mediaType := strings.TrimSuffix(header, ";base64"); mediaType != "" {
+			contentType = mediaType
+		}
+		encoded = rest
+	}
+	if encoded == "" {
+		return nil, "", fmt.Errorf("WA Server returned no media data")
+	}
+	if int64(base64.StdEncoding.DecodedLen(len(encoded))) > maxBytes+2 {
+		return nil, "", errMediaTooLarge
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode media: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", errMediaTooLarge
+	}
+	return data, contentType, nil
+}
+
+// normalizeMediaContentType strips parameters and sniffs the content when the server sent none
+func normalizeMediaContentType(contentType string, data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	return strings.ToLower(mediaType)
+}
+
+// mediaTypeAllowed reports whether contentType is in allowedMediaTypes
+func mediaTypeAllowed(contentType string) bool {
+	for _, allowed := range allowedMediaTypes {
+		if contentType == allowed || (strings.HasSuffix(allowed, "/") || strings.HasSuffix(allowed, ".")) && strings.HasPrefix(contentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// mediaStorageKey is where a message's media is stored: a hash of the session token (tokens are
+// credentials and must not show up in bucket listings) plus the message ID
+func mediaStorageKey(sessionToken, messageID, contentType string) string {
+	sum := sha256.Sum256([]byte(sessionToken))
+	return hex.EncodeToString(sum[:8]) + "/" + unsafeKeyChars.ReplaceAllString(messageID, "_") + mediaExtensions[contentType]
+}
+
+// MediaURL is the path the agent UI fetches an archived message's media from (GET /ai/media/:messageId)
+func MediaURL(messageID string) string {
+	return "/ai/media/" + messageID
+}
+
+// ArchiveIncomingMedia downloads a received message's media from the WA server, stores it in the
+// MEDIA_STORE_BACKEND and records its URL in the chat history row (media_data). Files over
+// MEDIA_MAX_BYTES or of a type outside allowedMediaTypes are not archived.
+func ArchiveIncomingMedia(sessionToken, messageID, mediaType string, chatMessage *models.ChatMessage) error {
+	backend, err := newMediaBackend(MediaStoreBackend())
+	if err != nil || backend == nil {
+		return err
+	}
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	maxBytes := mediaMaxBytes()
+	data, contentType, err := downloadMedia(sessionToken, messageID, maxBytes)
+	if err != nil {
+		return fmt.Errorf("media %s not archived: %w", messageID, err)
+	}
+	contentType = normalizeMediaContentType(contentType, data)
+	if !mediaTypeAllowed(contentType) {
+		return fmt.Errorf("media %s not archived: %w (%s)", messageID, errMediaTypeNotAllowed, contentType)
+	}
+
+	key := mediaStorageKey(sessionToken, messageID, contentType)
+	if err := backend.Put(key, contentType, data); err != nil {
+		return fmt.Errorf("media %s not archived: %w", messageID, err)
+	}
+
+	archive := models.MediaArchive{
+		SessionTok:  sessionToken,
+		MessageID:   messageID,
+		MediaType:   mediaType,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Backend:     backend.Name(),
+		StorageKey:  key,
+	}
+	if chatMessage != nil {
+		archive.ChatMessageID = chatMessage.ID
+	}
+	// Webhook retries archive the same message again: the file is overwritten, the row kept
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&archive).Error; err != nil {
+		return fmt.Errorf("failed to record archived media: %w", err)
+	}
+
+	if chatMessage != nil {
+		mediaData := models.JSONB{
+			"url":          MediaURL(messageID),
+			"content_type": contentType,
+			"size":         len(data),
+			"message_id":   messageID,
+		}
+		if err := db.Model(chatMessage).Update("media_data", mediaData).Error; err != nil {
+			return fmt.Errorf("failed to link archived media to chat message: %w", err)
+		}
+	}
+
+	log.Printf("📎 Archived %s media %s (%s, %d bytes, %s)", mediaType, messageID, contentType, len(data), backend.Name())
+	return nil
+}
+
+// GetArchivedMedia returns an archived media file of the session; ErrMediaNotFound when the
+// message has no archived media (or belongs to another session)
+func GetArchivedMedia(sessionToken, messageID string) (*models.MediaArchive, []byte, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, nil, fmt.Errorf("database not initialized")
+	}
+
+	var archive models.MediaArchive
+	err := db.Where("session_tok = ? AND message_id = ?", sessionToken, messageID).First(&archive).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load archived media: %w", err)
+	}
+
+	// Read from the backend the file was stored with, even if MEDIA_STORE_BACKEND changed since
+	backend, err := newMediaBackend(archive.Backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := backend.Get(archive.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &archive, data, nil
+}
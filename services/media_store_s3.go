@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// s3MediaBackend stores media in an S3 bucket (or an S3-compatible store via MEDIA_S3_ENDPOINT),
+// signing requests with AWS Signature V4 - no SDK needed for plain PUT / GET
+type s3MediaBackend struct {
+	endpoint  string // scheme + host, path-style bucket addressing
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// newS3MediaBackend reads MEDIA_S3_BUCKET, MEDIA_S3_REGION, MEDIA_S3_ENDPOINT,
+// MEDIA_S3_ACCESS_KEY_ID and MEDIA_S3_SECRET_ACCESS_KEY
+func newS3MediaBackend() (mediaBackend, error) {
+	b := &s3MediaBackend{
+		bucket:    config.GetEnvString("MEDIA_S3_BUCKET", ""),
+		region:    config.GetEnvString("MEDIA_S3_REGION", "us-east-1"),
+		accessKey: config.GetEnvString("MEDIA_S3_ACCESS_KEY_ID", ""),
+		secretKey: config.GetEnvString("MEDIA_S3_SECRET_ACCESS_KEY", ""),
+		client:    NewHTTPClient(mediaDownloadTimeout),
+	}
+	b.endpoint = strings.TrimRight(config.GetEnvString("MEDIA_S3_ENDPOINT", "https://s3."+b.region+".amazonaws.com"), "/")
+	if b.bucket == "" || b.accessKey == "" || b.secretKey == "" {
+		return nil, fmt.Errorf("MEDIA_STORE_BACKEND=s3 needs MEDIA_S3_BUCKET, MEDIA_S3_ACCESS_KEY_ID and MEDIA_S3_SECRET_ACCESS_KEY")
+	}
+	return b, nil
+}
+
+func (b *s3MediaBackend) Name() string { return "s3" }
+
+func (b *s3MediaBackend) Put(key, contentType string, data []byte) error {
+	req, err := http.NewRequest("PUT", b.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	b.sign(req, data, time.Now().UTC())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload media to S3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 upload returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (b *s3MediaBackend) Get(key string) ([]byte, error) {
+	req, err := http.NewRequest("GET", b.objectURL(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	b.sign(req, nil, time.Now().UTC())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media from S3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrMediaNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("S3 fetch returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// objectURL builds the path-style object URL (keys only hold [A-Za-z0-9_./-], see mediaStorageKey)
+func (b *s3MediaBackend) objectURL(key string) string {
+	return b.endpoint + "/" + url.PathEscape(b.bucket) + "/" + key
+}
+
+// sign adds the AWS Signature V4 headers for an unsigned-query request with the given payload
+func (b *s3MediaBackend) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.secretKey), date)
+	signingKey = hmacSHA256(signingKey, b.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
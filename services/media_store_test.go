@@ -0,0 +1,181 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestDownloadMediaFromWAServer(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n fake image bytes")
+	var gotToken, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken = r.Header.Get("token")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		switch r.URL.Path {
+		case waMediaDownloadPath:
+			if strings.Contains(gotBody, "json_msg") {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"code":200,"data":{"Data":"data:image/png;base64,` + base64.StdEncoding.EncodeToString(png) + `","Mimetype":"image/png"}}`))
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("WA_SERVER_URL", srv.URL)
+
+	data, contentType, err := downloadMediaFromWAServer("sess-token", "raw_msg", 1024)
+	if err != nil || string(data) != string(png) || contentType != "image/png" {
+		t.Fatalf("raw download: %q %q %v", data, contentType, err)
+	}
+	if gotToken != "sess-token" || !strings.Contains(gotBody, `"MessageID":"raw_msg"`) {
+		t.Errorf("request token=%q body=%s", gotToken, gotBody)
+	}
+
+	data, contentType, err = downloadMediaFromWAServer("sess-token", "json_msg", 1024)
+	if err != nil || string(data) != string(png) || contentType != "image/png" {
+		t.Fatalf("data URI download: %q %q %v", data, contentType, err)
+	}
+
+	for _, id := range []string{"raw_msg", "json_msg"} {
+		if _, _, err := downloadMediaFromWAServer("sess-token", id, 8); !errors.Is(err, errMediaTooLarge) {
+			t.Errorf("%s over the size limit: err = %v", id, err)
+		}
+	}
+}
+
+func TestMediaContentTypeChecks(t *testing.T) {
+	if got := normalizeMediaContentType("application/pdf; charset=binary", nil); got != "application/pdf" {
+		t.Errorf("params not stripped: %q", got)
+	}
+	if got := normalizeMediaContentType("application/octet-stream", []byte("%PDF-1.7 ...")); got != "application/pdf" {
+		t.Errorf("octet-stream not sniffed: %q", got)
+	}
+	for _, allowed := range []string{"image/jpeg", "application/pdf", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "audio/ogg"} {
+		if !mediaTypeAllowed(allowed) {
+			t.Errorf("%s rejected", allowed)
+		}
+	}
+	for _, blocked := range []string{"application/x-msdownload", "text/html", "application/javascript", "application/vnd.ms"} {
+		if mediaTypeAllowed(blocked) {
+			t.Errorf("%s allowed", blocked)
+		}
+	}
+}
+
+func TestMediaStorageKeyHidesSessionToken(t *testing.T) {
+	key := mediaStorageKey("secret-session-token", "3EB0../../etc", "image/jpeg")
+	if strings.Contains(key, "secret") || strings.Contains(key, "..") || !strings.HasSuffix(key, ".jpg") {
+		t.Errorf("key = %q", key)
+	}
+	if _, err := (localMediaBackend{dir: t.TempDir()}).path("../outside"); err == nil {
+		t.Error("local backend accepted a key outside its directory")
+	}
+}
+
+func TestS3MediaBackendSignsRequests(t *testing.T) {
+	objects := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+			r.Header.Get("x-amz-date") == "" || r.Header.Get("x-amz-content-sha256") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case "GET":
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("MEDIA_S3_BUCKET", "media")
+	t.Setenv("MEDIA_S3_REGION", "eu-west-1")
+	t.Setenv("MEDIA_S3_ENDPOINT", srv.URL)
+	t.Setenv("MEDIA_S3_ACCESS_KEY_ID", "AKID")
+	t.Setenv("MEDIA_S3_SECRET_ACCESS_KEY", "secret")
+
+	backend, err := newMediaBackend("s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Put("abc/msg1.pdf", "application/pdf", []byte("%PDF")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, ok := objects["/media/abc/msg1.pdf"]; !ok {
+		t.Fatalf("object not stored path-style: %v", objects)
+	}
+	if data, err := backend.Get("abc/msg1.pdf"); err != nil || string(data) != "%PDF" {
+		t.Errorf("get: %q %v", data, err)
+	}
+	if _, err := backend.Get("abc/missing.pdf"); !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("missing object: err = %v", err)
+	}
+}
+
+func TestArchiveIncomingMedia(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.MediaArchive{}, &models.ChatRoom{}, &models.ChatMessage{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.MediaArchive{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatMessage{})
+		db.Where("user_token = ?", sessionTok).Delete(&models.ChatRoom{})
+	})
+	t.Setenv("MEDIA_STORE_BACKEND", "local")
+	t.Setenv("MEDIA_LOCAL_DIR", t.TempDir())
+
+	previous := downloadMedia
+	t.Cleanup(func() { downloadMedia = previous })
+	files := map[string]string{"msg_pdf": "%PDF-1.7 invoice", "msg_exe": "MZ\x90\x00 program"}
+	downloadMedia = func(sessionToken, messageID string, maxBytes int64) ([]byte, string, error) {
+		return []byte(files[messageID]), "application/octet-stream", nil
+	}
+
+	chatMessage, err := SaveMediaToChatHistory(sessionTok, "628111@s.whatsapp.net", "bot@s.whatsapp.net", "invoice", "Budi", "document", time.Now())
+	if err != nil {
+		t.Fatalf("failed to save chat message: %v", err)
+	}
+	if err := ArchiveIncomingMedia(sessionTok, "msg_pdf", "document", chatMessage); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if err := ArchiveIncomingMedia(sessionTok, "msg_exe", "document", nil); !errors.Is(err, errMediaTypeNotAllowed) {
+		t.Errorf("executable archived: err = %v", err)
+	}
+
+	var stored models.ChatMessage
+	db.First(&stored, chatMessage.ID)
+	if stored.MessageType != "document" || stored.MediaData["url"] != MediaURL("msg_pdf") {
+		t.Errorf("chat message type=%q media_data=%v", stored.MessageType, stored.MediaData)
+	}
+
+	archive, data, err := GetArchivedMedia(sessionTok, "msg_pdf")
+	if err != nil || string(data) != files["msg_pdf"] || archive.ContentType != "application/pdf" {
+		t.Fatalf("get: %+v %q %v", archive, data, err)
+	}
+	// Another session can't read it
+	if _, _, err := GetArchivedMedia(sessionTok+"_other", "msg_pdf"); !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("other session: err = %v", err)
+	}
+}
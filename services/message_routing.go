@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Inbound message routes a bot can be configured with (BotSettings.MessageTypeHandling)
+const (
+	RouteReply    = "reply"    // enqueue an AI job
+	RouteFallback = "fallback" // send the bot's fallback text, no LLM call
+	RouteHandoff  = "handoff"  // no auto-reply, the conversation is handed off to a human (StartHandoff)
+	RouteIgnore   = "ignore"   // no reply, stored for context and history only
+)
+
+// ResolveMessageRoute decides how an inbound message type is handled.
+// Per-bot configuration wins; otherwise text is answered, reactions follow
+// AI_REPLY_TO_REACTIONS and everything else (media) is ignored.
+func ResolveMessageRoute(botSettings *BotSettings, msgType string) string {
+	msgType = strings.ToLower(strings.TrimSpace(msgType))
+
+	if botSettings != nil {
+		if route := lookupRoute(botSettings.MessageTypeHandling, msgType); route != "" {
+			return route
+		}
+		if route := lookupRoute(botSettings.MessageTypeHandling, "*"); route != "" {
+			return route
+		}
+	}
+
+	switch msgType {
+	case "text":
+		return RouteReply
+	case "reaction":
+		if ShouldReplyToReactions() {
+			return RouteReply
+		}
+		return RouteIgnore
+	default:
+		return RouteIgnore
+	}
+}
+
+// lookupRoute finds the configured route for msgType (keys are case-insensitive);
+// unknown route values return "" so the defaults apply
+func lookupRoute(handling map[string]string, msgType string) string {
+	for key, route := range handling {
+		if !strings.EqualFold(strings.TrimSpace(key), msgType) {
+			continue
+		}
+		route = strings.ToLower(strings.TrimSpace(route))
+		switch route {
+		case RouteReply, RouteFallback, RouteHandoff, RouteIgnore:
+			return route
+		}
+		return ""
+	}
+	return ""
+}
+
+// SendFallbackReply sends the bot's fallback text without calling the LLM and stores it like an AI reply
+func SendFallbackReply(sessionToken, botJID, contactJID, fallbackText string) error {
+	if strings.TrimSpace(fallbackText) == "" {
+		return fmt.Errorf("bot has no fallback text configured")
+	}
+
+	if _, err := sendAndStoreReply(sessionToken, botJID, contactJID, fallbackText, "fallback"); err != nil {
+		return fmt.Errorf("failed to send fallback reply: %w", err)
+	}
+	return nil
+}
+
+// sendAndStoreReply sends text to the contact and stores it in the AI context and permanent chat
+// history like an AI reply. idPrefix names the generated ID used when the WA Server reports none.
+func sendAndStoreReply(sessionToken, botJID, contactJID, text, idPrefix string) (string, error) {
+	waMessageID, err := SendWAText(sessionToken, contactJID, text)
+	if err != nil {
+		return "", err
+	}
+
+	if waMessageID == "" {
+		waMessageID = fmt.Sprintf("%s_%s_%d", idPrefix, sessionToken, time.Now().UnixNano())
+	}
+	if err := SaveOutgoingMessageToAIChat(sessionToken, waMessageID, botJID, contactJID, text, time.Now()); err != nil {
+		log.Printf("⚠️  Failed to save %s reply to AI chat messages: %v", idPrefix, err)
+	}
+	if err := SaveAIResponseToHistory(sessionToken, contactJID, text); err != nil {
+		log.Printf("⚠️  Failed to save %s reply to permanent chat history: %v", idPrefix, err)
+	}
+	return waMessageID, nil
+}
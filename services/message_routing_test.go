@@ -0,0 +1,45 @@
+package services
+
+import "testing"
+
+func TestResolveMessageRoute(t *testing.T) {
+	configured := &BotSettings{MessageTypeHandling: map[string]string{
+		"text":     "reply",
+		"Image":    "handoff",
+		"document": "fallback",
+		"audio":    "bogus", // unknown route falls back to the default
+	}}
+	withWildcard := &BotSettings{MessageTypeHandling: map[string]string{
+		"text": "reply",
+		"*":    "Handoff",
+	}}
+
+	tests := []struct {
+		name      string
+		settings  *BotSettings
+		msgType   string
+		reactions string
+		want      string
+	}{
+		{"no settings text", nil, "text", "", RouteReply},
+		{"no settings image", nil, "image", "", RouteIgnore},
+		{"default reaction", &BotSettings{}, "reaction", "", RouteIgnore},
+		{"default reaction enabled", &BotSettings{}, "reaction", "true", RouteReply},
+		{"configured text", configured, "text", "", RouteReply},
+		{"configured image", configured, "image", "", RouteHandoff},
+		{"configured document", configured, "document", "", RouteFallback},
+		{"type is case-insensitive", configured, "IMAGE", "", RouteHandoff},
+		{"unknown route uses default", configured, "audio", "", RouteIgnore},
+		{"unconfigured type uses default", configured, "video", "", RouteIgnore},
+		{"wildcard", withWildcard, "sticker", "", RouteHandoff},
+		{"explicit beats wildcard", withWildcard, "text", "", RouteReply},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_REPLY_TO_REACTIONS", tt.reactions)
+			if got := ResolveMessageRoute(tt.settings, tt.msgType); got != tt.want {
+				t.Errorf("ResolveMessageRoute(%q) = %q, want %q", tt.msgType, got, tt.want)
+			}
+		})
+	}
+}
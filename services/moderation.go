@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for the moderation pre-check (override via .env)
+const (
+	defaultModerationURL       = "https://api.openai.com/v1/moderations"
+	defaultModerationModel     = "omni-moderation-latest"
+	defaultModerationTimeoutMs = 5000
+	defaultModerationReply     = "Mohon maaf, pesan Anda tidak dapat kami proses. Silakan sampaikan pertanyaan Anda kembali dengan bahasa yang sopan 🙏"
+)
+
+// ModerationResult is the verdict of the moderation endpoint for one message
+type ModerationResult struct {
+	Flagged    bool
+	Categories []string // flagged categories, sorted (e.g. "harassment", "violence")
+}
+
+// ModerationEnabled reports whether incoming messages are moderated before the LLM call (AI_MODERATION, default false)
+func ModerationEnabled() bool {
+	return config.GetEnvBool("AI_MODERATION", false)
+}
+
+// ModerationReply returns AI_MODERATION_MESSAGE, the canned reply to a flagged message
+func ModerationReply() string {
+	return config.GetEnvString("AI_MODERATION_MESSAGE", defaultModerationReply)
+}
+
+// moderationTimeout returns AI_MODERATION_TIMEOUT_MS
+func moderationTimeout() time.Duration {
+	ms := config.GetEnvInt("AI_MODERATION_TIMEOUT_MS", defaultModerationTimeoutMs)
+	if ms <= 0 {
+		ms = defaultModerationTimeoutMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// CheckModeration runs text through the OpenAI-compatible moderation endpoint (AI_MODERATION_URL,
+// AI_MODERATION_API_KEY, AI_MODERATION_MODEL). Callers fail open on an error: a moderation outage
+// must not stop the bot from answering.
+func CheckModeration(ctx context.Context, text string) (*ModerationResult, error) {
+	apiKey := config.GetEnvString("AI_MODERATION_API_KEY", "")
+	if apiKey == "" {
+		return nil, fmt.Errorf("AI_MODERATION_API_KEY not set")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"model": config.GetEnvString("AI_MODERATION_MODEL", defaultModerationModel),
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, moderationTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.GetEnvString("AI_MODERATION_URL", defaultModerationURL), bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create moderation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := sharedClient.Do(req) // bounded by ctx (moderationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("moderation endpoint returned %d: %s", resp.StatusCode, TruncateRunes(string(body), 200))
+	}
+
+	var parsed struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid moderation response: %w", err)
+	}
+	if len(parsed.Results) == 0 {
+		return nil, fmt.Errorf("moderation response has no results")
+	}
+
+	result := &ModerationResult{Flagged: parsed.Results[0].Flagged}
+	for category, flagged := range parsed.Results[0].Categories {
+		if flagged {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	sort.Strings(result.Categories)
+	return result, nil
+}
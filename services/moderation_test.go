@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeModerationServer flags any input containing "kasar" as harassment
+func fakeModerationServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mod-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+			Input string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad moderation request: %v", err)
+		}
+		flagged := strings.Contains(req.Input, "kasar")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]interface{}{{
+				"flagged": flagged,
+				"categories": map[string]bool{
+					"harassment": flagged,
+					"violence":   false,
+					"hate":       flagged,
+				},
+			}},
+		})
+	}))
+}
+
+func TestCheckModeration(t *testing.T) {
+	server := fakeModerationServer(t)
+	defer server.Close()
+	t.Setenv("AI_MODERATION_URL", server.URL)
+	t.Setenv("AI_MODERATION_API_KEY", "mod-key")
+
+	result, err := CheckModeration(context.Background(), "dasar kasar kamu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Flagged || strings.Join(result.Categories, ",") != "harassment,hate" {
+		t.Errorf("result = %+v, want flagged for harassment,hate", result)
+	}
+
+	result, err = CheckModeration(context.Background(), "berapa harga paket premium?")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Flagged || len(result.Categories) != 0 {
+		t.Errorf("result = %+v, want not flagged", result)
+	}
+}
+
+func TestCheckModerationErrors(t *testing.T) {
+	server := fakeModerationServer(t)
+	defer server.Close()
+	t.Setenv("AI_MODERATION_URL", server.URL)
+
+	t.Setenv("AI_MODERATION_API_KEY", "")
+	if _, err := CheckModeration(context.Background(), "halo"); err == nil {
+		t.Error("expected an error without AI_MODERATION_API_KEY")
+	}
+
+	t.Setenv("AI_MODERATION_API_KEY", "wrong-key")
+	if _, err := CheckModeration(context.Background(), "halo"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want the endpoint's 401", err)
+	}
+}
+
+func TestModerationReply(t *testing.T) {
+	t.Setenv("AI_MODERATION_MESSAGE", "")
+	if ModerationReply() != defaultModerationReply {
+		t.Errorf("default reply = %q", ModerationReply())
+	}
+	t.Setenv("AI_MODERATION_MESSAGE", "Pesan tidak dapat diproses.")
+	if ModerationReply() != "Pesan tidak dapat diproses." {
+		t.Errorf("custom reply = %q", ModerationReply())
+	}
+}
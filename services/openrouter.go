@@ -6,7 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"sync"
 	"time"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -17,6 +17,9 @@ type OpenRouterClient struct {
 	client  *openai.Client
 	model   string
 	timeout time.Duration
+
+	apiKey     string
+	modelCheck sync.Once // the first "invalid model" error looks the model up once
 }
 
 // NewOpenRouterClient creates OpenAI-compatible client for OpenRouter
@@ -25,21 +28,25 @@ func NewOpenRouterClient() (*OpenRouterClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("OPENROUTER_API_KEY not set in environment")
 	}
+	return newOpenRouterClient(apiKey, os.Getenv("OPENROUTER_MODEL"))
+}
 
-	model := os.Getenv("OPENROUTER_MODEL")
+// newOpenRouterClient creates an OpenRouter client for apiKey (the global one or a customer's);
+// an empty model uses OPENROUTER_MODEL / the default. The key is never logged.
+func newOpenRouterClient(apiKey, model string) (*OpenRouterClient, error) {
 	if model == "" {
-		model = "openai/gpt-4o-mini" // default model
+		model = os.Getenv("OPENROUTER_MODEL")
 	}
-
-	timeoutMs := 120000 // default 120 seconds
-	if t := os.Getenv("AI_TIMEOUT_MS"); t != "" {
-		if parsed, err := strconv.Atoi(t); err == nil {
-			timeoutMs = parsed
-		}
+	if model == "" {
+		model = defaultOpenRouterModel
 	}
+	// Opt-in (OPENROUTER_VALIDATE_MODEL): a typo'd model fails loudly here instead of in every job
+	model = resolveOpenRouterModel(apiKey, model)
+
+	timeout := AITimeout()
 
 	cfg := openai.DefaultConfig(apiKey)
-	cfg.BaseURL = "https://openrouter.ai/api/v1"
+	cfg.BaseURL = openRouterBaseURL
 
 	// Add custom headers for OpenRouter
 	referer := os.Getenv("OPENROUTER_HTTP_REFERER")
@@ -54,7 +61,7 @@ func NewOpenRouterClient() (*OpenRouterClient, error) {
 
 	cfg.HTTPClient = &http.Client{
 		Transport: &openRouterTransport{
-			base:    http.DefaultTransport,
+			base:    SharedTransport,
 			referer: referer,
 			title:   title,
 		},
@@ -62,12 +69,13 @@ func NewOpenRouterClient() (*OpenRouterClient, error) {
 
 	client := openai.NewClientWithConfig(cfg)
 
-	log.Printf("[OpenRouterClient] Initialized with model=%s, timeout=%dms", model, timeoutMs)
+	log.Printf("[OpenRouterClient] Initialized with model=%s, timeout=%s", model, timeout)
 
 	return &OpenRouterClient{
 		client:  client,
 		model:   model,
-		timeout: time.Duration(timeoutMs) * time.Millisecond,
+		timeout: timeout,
+		apiKey:  apiKey,
 	}, nil
 }
 
@@ -81,28 +89,51 @@ type openRouterTransport struct {
 func (t *openRouterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.Header.Set("HTTP-Referer", t.referer)
 	req.Header.Set("X-Title", t.title)
-	return t.base.RoundTrip(req)
+	resp, err := t.base.RoundTrip(req)
+
+	// The SDK drops response headers - keep rate-limit ones for AskLLM's error
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		if capture, ok := req.Context().Value(rateLimitCaptureKey{}).(*http.Header); ok {
+			*capture = resp.Header.Clone()
+		}
+	}
+	return resp, err
 }
 
+// rateLimitCaptureKey is the request context key for captured 429 headers
+type rateLimitCaptureKey struct{}
+
 // AskLLM sends prompt to LLM and returns response with token counts
 func (orc *OpenRouterClient) AskLLM(ctx context.Context, systemPrompt, userMessage string) (string, int, int, error) {
+	// Global call budget (AI_LLM_RATE_PER_SECOND), shared with every other job
+	if err := WaitLLMSlot(ctx); err != nil {
+		return "", 0, 0, fmt.Errorf("OpenRouter API error: %w", err)
+	}
+
 	// Create context with timeout
 	timeoutCtx, cancel := context.WithTimeout(ctx, orc.timeout)
 	defer cancel()
 
 	startTime := time.Now()
+	model := requestModel(ctx, orc.model)
 
 	req := openai.ChatCompletionRequest{
-		Model: orc.model,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-			{Role: openai.ChatMessageRoleUser, Content: userMessage},
-		},
+		Model:       model,
+		Messages:    buildOpenRouterMessages(model, systemPrompt, userMessage),
 		Temperature: 0.3,
 	}
 
+	var rateLimitHeaders http.Header
+	timeoutCtx = context.WithValue(timeoutCtx, rateLimitCaptureKey{}, &rateLimitHeaders)
+
 	resp, err := orc.client.CreateChatCompletion(timeoutCtx, req)
 	if err != nil {
+		if rateLimitHeaders != nil {
+			err = &rateLimitedError{err: err, headers: rateLimitHeaders}
+		}
+		if ParseSDKError(err).IsInvalidModelError() {
+			orc.modelCheck.Do(func() { go checkOpenRouterModel(orc.apiKey, orc.model) })
+		}
 		return "", 0, 0, fmt.Errorf("OpenRouter API error: %w", err)
 	}
 
@@ -117,11 +148,25 @@ func (orc *OpenRouterClient) AskLLM(ctx context.Context, systemPrompt, userMessa
 	outputTokens := resp.Usage.CompletionTokens
 
 	log.Printf("[OpenRouterClient] Success | model=%s | latency=%dms | in=%d | out=%d | total=%d",
-		orc.model, latency, inputTokens, outputTokens, inputTokens+outputTokens)
+		model, latency, inputTokens, outputTokens, inputTokens+outputTokens)
 
 	return output, inputTokens, outputTokens, nil
 }
 
+// buildOpenRouterMessages sends the system prompt as a system message; models that reject the
+// system role (UseNativeSystemPrompt) get a single user message with it prepended
+func buildOpenRouterMessages(model, systemPrompt, userMessage string) []openai.ChatCompletionMessage {
+	if systemPrompt == "" || !UseNativeSystemPrompt("openrouter", model) {
+		return []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: mergeSystemPrompt(systemPrompt, userMessage)},
+		}
+	}
+	return []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: userMessage},
+	}
+}
+
 // GetProviderName returns the provider name for logging
 func (orc *OpenRouterClient) GetProviderName() string {
 	return "openrouter"
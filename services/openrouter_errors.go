@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -61,6 +64,122 @@ func (e *OpenRouterError) IsContextLengthError() bool {
 			strings.Contains(msgLower, "too long"))
 }
 
+// IsInvalidModelError returns true if the requested model doesn't exist (typo'd OPENROUTER_MODEL)
+func (e *OpenRouterError) IsInvalidModelError() bool {
+	if e.StatusCode != 400 && e.StatusCode != 404 {
+		return false
+	}
+
+	msgLower := strings.ToLower(e.Message)
+	return strings.Contains(msgLower, "model") &&
+		(strings.Contains(msgLower, "not a valid") ||
+			strings.Contains(msgLower, "invalid") ||
+			strings.Contains(msgLower, "not found") ||
+			strings.Contains(msgLower, "does not exist") ||
+			strings.Contains(msgLower, "no endpoints"))
+}
+
+// Metadata keys for rate-limit info taken from 429 responses
+const (
+	MetadataRetryAfter     = "retry_after"     // Retry-After header (seconds or HTTP date)
+	MetadataRateLimitReset = "ratelimit_reset" // X-RateLimit-Reset header (unix timestamp, ms or s)
+)
+
+// RetryAfter returns how long to wait before retrying, from Retry-After or X-RateLimit-Reset
+// (0 when the response carried no usable rate-limit info)
+func (e *OpenRouterError) RetryAfter() time.Duration {
+	return e.retryAfterAt(time.Now())
+}
+
+// retryAfterAt is RetryAfter relative to now (testable)
+func (e *OpenRouterError) retryAfterAt(now time.Time) time.Duration {
+	if value := e.metadataString(MetadataRetryAfter); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if at, err := http.ParseTime(value); err == nil && at.After(now) {
+			return at.Sub(now)
+		}
+	}
+	if reset := e.rateLimitResetAt(); !reset.IsZero() && reset.After(now) {
+		return reset.Sub(now)
+	}
+	return 0
+}
+
+// RateLimitReset returns when the rate-limit window resets (zero time if unknown)
+func (e *OpenRouterError) RateLimitReset() time.Time {
+	return e.rateLimitResetAt()
+}
+
+func (e *OpenRouterError) rateLimitResetAt() time.Time {
+	value := e.metadataString(MetadataRateLimitReset)
+	if value == "" {
+		return time.Time{}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	if n > 1e12 { // OpenRouter sends milliseconds
+		return time.UnixMilli(int64(n))
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// metadataString reads a metadata value as string (numbers are formatted)
+func (e *OpenRouterError) metadataString(key string) string {
+	if e.Metadata == nil {
+		return ""
+	}
+	switch v := e.Metadata[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// applyRateLimitHeaders copies Retry-After / X-RateLimit-Reset into Metadata.
+// Upstream-provider limits reported inside the body (metadata.headers) are used as a fallback.
+func (e *OpenRouterError) applyRateLimitHeaders(headers http.Header) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]interface{})
+	}
+
+	bodyHeaders, _ := e.Metadata["headers"].(map[string]interface{})
+	lookup := func(name string) string {
+		if v := headers.Get(name); v != "" {
+			return v
+		}
+		for k, v := range bodyHeaders {
+			if s, ok := v.(string); ok && strings.EqualFold(k, name) {
+				return s
+			}
+		}
+		return ""
+	}
+
+	if v := lookup("Retry-After"); v != "" {
+		e.Metadata[MetadataRetryAfter] = v
+	}
+	if v := lookup("X-RateLimit-Reset"); v != "" {
+		e.Metadata[MetadataRateLimitReset] = v
+	}
+}
+
+// rateLimitedError carries the response headers of a 429 returned through the SDK,
+// which itself does not expose them (see openRouterTransport)
+type rateLimitedError struct {
+	err     error
+	headers http.Header
+}
+
+func (e *rateLimitedError) Error() string { return e.err.Error() }
+func (e *rateLimitedError) Unwrap() error { return e.err }
+
 // ParseOpenRouterError parses HTTP response into OpenRouterError
 func ParseOpenRouterError(httpResp *http.Response) error {
 	if httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
@@ -86,20 +205,36 @@ func ParseOpenRouterError(httpResp *http.Response) error {
 		return fmt.Errorf("HTTP %d: %s", httpResp.StatusCode, string(body))
 	}
 
-	return &OpenRouterError{
+	orErr := &OpenRouterError{
 		StatusCode: httpResp.StatusCode,
 		Code:       errResp.Error.Code,
 		Message:    errResp.Error.Message,
 		Metadata:   errResp.Error.Metadata,
 	}
+	if httpResp.StatusCode == http.StatusTooManyRequests {
+		orErr.applyRateLimitHeaders(httpResp.Header)
+	}
+	return orErr
 }
 
 // ParseSDKError converts go-openai SDK error to OpenRouterError
+// (rate-limit headers captured by the transport are attached to 429s)
 func ParseSDKError(err error) *OpenRouterError {
 	if err == nil {
 		return nil
 	}
 
+	orErr := parseSDKError(err)
+	var rateLimited *rateLimitedError
+	if orErr.StatusCode == http.StatusTooManyRequests && errors.As(err, &rateLimited) {
+		orErr.applyRateLimitHeaders(rateLimited.headers)
+	}
+	return orErr
+}
+
+// parseSDKError classifies an SDK error by API status or message
+func parseSDKError(err error) *OpenRouterError {
+
 	// Try to unwrap as OpenAI API error
 	var apiErr *openai.APIError
 	if errors.As(err, &apiErr) {
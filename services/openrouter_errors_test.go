@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const rateLimitBody = `{"error":{"code":429,"message":"Rate limit exceeded: free-models-per-min"}}`
+
+func rateLimitResponse(body string, headers map[string]string) *http.Response {
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func parseRateLimit(t *testing.T, body string, headers map[string]string) *OpenRouterError {
+	t.Helper()
+	err := ParseOpenRouterError(rateLimitResponse(body, headers))
+	orErr, ok := err.(*OpenRouterError)
+	if !ok {
+		t.Fatalf("expected *OpenRouterError, got %T: %v", err, err)
+	}
+	if !orErr.IsRetryable() {
+		t.Fatalf("429 must be retryable")
+	}
+	return orErr
+}
+
+func TestParseOpenRouterErrorRetryAfterSeconds(t *testing.T) {
+	orErr := parseRateLimit(t, rateLimitBody, map[string]string{"Retry-After": "42"})
+
+	if got := orErr.Metadata[MetadataRetryAfter]; got != "42" {
+		t.Errorf("metadata retry_after = %v, want 42", got)
+	}
+	if got := orErr.RetryAfter(); got != 42*time.Second {
+		t.Errorf("RetryAfter() = %v, want 42s", got)
+	}
+}
+
+func TestParseOpenRouterErrorRetryAfterHTTPDate(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	date := now.Add(90 * time.Second).Format(http.TimeFormat)
+	orErr := parseRateLimit(t, rateLimitBody, map[string]string{"Retry-After": date})
+
+	if got := orErr.retryAfterAt(now); got != 90*time.Second {
+		t.Errorf("retryAfterAt() = %v, want 90s", got)
+	}
+}
+
+func TestParseOpenRouterErrorRateLimitReset(t *testing.T) {
+	now := time.Now()
+	resetMs := now.Add(20 * time.Second).UnixMilli()
+	orErr := parseRateLimit(t, rateLimitBody, map[string]string{"X-RateLimit-Reset": strconv.FormatInt(resetMs, 10)})
+
+	if got := orErr.RateLimitReset(); got.UnixMilli() != resetMs {
+		t.Errorf("RateLimitReset() = %v, want %d ms", got, resetMs)
+	}
+	if got := orErr.retryAfterAt(now); got < 19*time.Second || got > 20*time.Second {
+		t.Errorf("retryAfterAt() = %v, want ~20s", got)
+	}
+
+	// Epoch seconds are accepted too
+	resetSec := now.Add(30 * time.Second).Unix()
+	orErr = parseRateLimit(t, rateLimitBody, map[string]string{"X-RateLimit-Reset": strconv.FormatInt(resetSec, 10)})
+	if got := orErr.RateLimitReset(); got.Unix() != resetSec {
+		t.Errorf("RateLimitReset() = %v, want %d s", got, resetSec)
+	}
+}
+
+func TestParseOpenRouterErrorBodyMetadataHeaders(t *testing.T) {
+	// Upstream provider limits are reported in error.metadata.headers
+	body := `{"error":{"code":429,"message":"Provider rate limited","metadata":{"headers":{"Retry-After":"7"},"provider_name":"x"}}}`
+	orErr := parseRateLimit(t, body, nil)
+
+	if got := orErr.RetryAfter(); got != 7*time.Second {
+		t.Errorf("RetryAfter() = %v, want 7s", got)
+	}
+	if orErr.Metadata["provider_name"] != "x" {
+		t.Errorf("existing metadata must be kept: %v", orErr.Metadata)
+	}
+}
+
+func TestRetryAfterWithoutRateLimitInfo(t *testing.T) {
+	orErr := parseRateLimit(t, rateLimitBody, nil)
+	if got := orErr.RetryAfter(); got != 0 {
+		t.Errorf("RetryAfter() = %v, want 0", got)
+	}
+
+	// Reset already in the past -> no wait
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10)
+	orErr = parseRateLimit(t, rateLimitBody, map[string]string{"X-RateLimit-Reset": past})
+	if got := orErr.RetryAfter(); got != 0 {
+		t.Errorf("RetryAfter() with past reset = %v, want 0", got)
+	}
+}
+
+func TestAskLLMRateLimitHeadersReachParseSDKError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "12")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, rateLimitBody)
+	}))
+	defer server.Close()
+
+	cfg := openai.DefaultConfig("test-key")
+	cfg.BaseURL = server.URL
+	cfg.HTTPClient = &http.Client{Transport: &openRouterTransport{base: http.DefaultTransport, referer: "test", title: "test"}}
+	orc := &OpenRouterClient{client: openai.NewClientWithConfig(cfg), model: "test/model", timeout: 5 * time.Second}
+
+	_, _, _, err := orc.AskLLM(context.Background(), "system", "halo")
+	if err == nil {
+		t.Fatal("expected error from 429 response")
+	}
+
+	orErr := ParseSDKError(err)
+	if orErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", orErr.StatusCode)
+	}
+	if got := orErr.RetryAfter(); got != 12*time.Second {
+		t.Errorf("RetryAfter() = %v, want 12s", got)
+	}
+}
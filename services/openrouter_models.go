@@ -0,0 +1,146 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// openRouterBaseURL is the OpenRouter API root (var so tests can point it at a mock server)
+var openRouterBaseURL = "https://openrouter.ai/api/v1"
+
+// openRouterModelsTimeout bounds the /models lookup - startup must not hang on it
+const openRouterModelsTimeout = 5 * time.Second
+
+// maxModelSuggestions is how many similar model IDs an invalid-model error lists
+const maxModelSuggestions = 5
+
+// fetchOpenRouterModels returns the model IDs OpenRouter currently serves
+func fetchOpenRouterModels(apiKey string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, openRouterBaseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := DoHTTP(req, openRouterModelsTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OpenRouter models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenRouter /models returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenRouter models: %w", err)
+	}
+	ids := make([]string, 0, len(result.Data))
+	for _, m := range result.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// similarModels returns up to limit IDs closest to model (same provider first, then edit distance)
+func similarModels(model string, ids []string, limit int) []string {
+	model = strings.ToLower(model)
+	provider, _, _ := strings.Cut(model, "/")
+
+	type candidate struct {
+		id       string
+		provider bool
+		distance int
+	}
+	candidates := make([]candidate, 0, len(ids))
+	for _, id := range ids {
+		lower := strings.ToLower(id)
+		idProvider, _, _ := strings.Cut(lower, "/")
+		candidates = append(candidates, candidate{id: id, provider: idProvider == provider, distance: editDistance(model, lower)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].provider != candidates[j].provider {
+			return candidates[i].provider
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	similar := make([]string, 0, limit)
+	for _, c := range candidates {
+		if len(similar) == limit {
+			break
+		}
+		similar = append(similar, c.id)
+	}
+	return similar
+}
+
+// editDistance is the Levenshtein distance between a and b (bytes - model IDs are ASCII)
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// checkOpenRouterModel reports whether model is served by OpenRouter, logging similar IDs when it
+// isn't. A failed lookup counts as valid - the check must never take the bot down by itself.
+func checkOpenRouterModel(apiKey, model string) bool {
+	ids, err := fetchOpenRouterModels(apiKey)
+	if err != nil {
+		log.Printf("⚠️  [OpenRouterClient] Could not validate model %q: %v", model, err)
+		return true
+	}
+	for _, id := range ids {
+		if id == model {
+			log.Printf("[OpenRouterClient] ✓ Model %q is available", model)
+			return true
+		}
+	}
+	log.Printf("❌ [OpenRouterClient] OPENROUTER_MODEL %q is not an OpenRouter model - every AI job will fail. Did you mean: %s?",
+		model, strings.Join(similarModels(model, ids, maxModelSuggestions), ", "))
+	return false
+}
+
+// resolveOpenRouterModel validates model at startup when OPENROUTER_VALIDATE_MODEL=true and swaps
+// in OPENROUTER_MODEL_FALLBACK if it doesn't exist (without a fallback the model is kept)
+func resolveOpenRouterModel(apiKey, model string) string {
+	if !config.GetEnvBool("OPENROUTER_VALIDATE_MODEL", false) {
+		return model
+	}
+	if checkOpenRouterModel(apiKey, model) {
+		return model
+	}
+	fallback := config.GetEnvString("OPENROUTER_MODEL_FALLBACK", "")
+	if fallback == "" || fallback == model {
+		return model
+	}
+	log.Printf("⚠️  [OpenRouterClient] Falling back to OPENROUTER_MODEL_FALLBACK=%s", fallback)
+	return fallback
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newMockOpenRouterModels serves /models with a few IDs and points openRouterBaseURL at it
+func newMockOpenRouterModels(t *testing.T, status int) *int32 {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/models" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, `{"data":[{"id":"openai/gpt-4o-mini"},{"id":"openai/gpt-4o"},{"id":"anthropic/claude-3.5-haiku"},{"id":"google/gemini-2.5-flash"}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	previous := openRouterBaseURL
+	openRouterBaseURL = server.URL
+	t.Cleanup(func() { openRouterBaseURL = previous })
+	return &calls
+}
+
+func TestResolveOpenRouterModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate string
+		fallback string
+		status   int
+		model    string
+		want     string
+	}{
+		{name: "validation off", validate: "false", fallback: "openai/gpt-4o-mini", status: http.StatusOK, model: "openai/gpt-4o-mnii", want: "openai/gpt-4o-mnii"},
+		{name: "valid model", validate: "true", fallback: "openai/gpt-4o-mini", status: http.StatusOK, model: "openai/gpt-4o", want: "openai/gpt-4o"},
+		{name: "typo falls back", validate: "true", fallback: "openai/gpt-4o-mini", status: http.StatusOK, model: "openai/gpt-4o-mnii", want: "openai/gpt-4o-mini"},
+		{name: "typo without fallback is kept", validate: "true", fallback: "", status: http.StatusOK, model: "openai/gpt-4o-mnii", want: "openai/gpt-4o-mnii"},
+		{name: "lookup failure keeps model", validate: "true", fallback: "openai/gpt-4o-mini", status: http.StatusInternalServerError, model: "openai/gpt-4o-mnii", want: "openai/gpt-4o-mnii"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := newMockOpenRouterModels(t, tt.status)
+			t.Setenv("OPENROUTER_VALIDATE_MODEL", tt.validate)
+			t.Setenv("OPENROUTER_MODEL_FALLBACK", tt.fallback)
+
+			if got := resolveOpenRouterModel("key", tt.model); got != tt.want {
+				t.Errorf("resolveOpenRouterModel(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+			if tt.validate == "false" && atomic.LoadInt32(calls) != 0 {
+				t.Errorf("/models called with validation off")
+			}
+		})
+	}
+}
+
+func TestSimilarModels(t *testing.T) {
+	ids := []string{"anthropic/claude-3.5-haiku", "openai/gpt-4o", "google/gemini-2.5-flash", "openai/gpt-4o-mini"}
+	got := similarModels("openai/gpt-4o-mnii", ids, 3)
+	want := []string{"openai/gpt-4o-mini", "openai/gpt-4o", "google/gemini-2.5-flash"}
+	if len(got) != len(want) {
+		t.Fatalf("similarModels = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("similarModels = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestIsInvalidModelError(t *testing.T) {
+	tests := []struct {
+		err  OpenRouterError
+		want bool
+	}{
+		{OpenRouterError{StatusCode: 400, Message: "openai/gpt-4o-mnii is not a valid model ID"}, true},
+		{OpenRouterError{StatusCode: 404, Message: "No endpoints found for model foo/bar"}, true},
+		{OpenRouterError{StatusCode: 400, Message: "This model's maximum context length is 128000 tokens"}, false},
+		{OpenRouterError{StatusCode: 503, Message: "model not found"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.err.IsInvalidModelError(); got != tt.want {
+			t.Errorf("IsInvalidModelError(%d %q) = %v, want %v", tt.err.StatusCode, tt.err.Message, got, tt.want)
+		}
+	}
+}
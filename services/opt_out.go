@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// defaultOptOutKeywords - a message consisting of just one of these opts the contact out
+const defaultOptOutKeywords = "STOP,BERHENTI,UNSUBSCRIBE,STOP ALL"
+
+const defaultOptOutConfirmMessage = "Anda telah berhenti berlangganan dan tidak akan menerima pesan lagi dari kami. Terima kasih 🙏"
+
+// optOutKeywords returns AI_OPT_OUT_KEYWORDS (comma-separated, case-insensitive; "off" disables opt-out)
+func optOutKeywords() []string {
+	raw := config.GetEnvString("AI_OPT_OUT_KEYWORDS", "")
+	if strings.EqualFold(raw, "off") {
+		return nil
+	}
+	if raw == "" {
+		raw = defaultOptOutKeywords
+	}
+
+	var keywords []string
+	for _, keyword := range strings.Split(raw, ",") {
+		if keyword = normalizeOptOutText(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// normalizeOptOutText lowercases, drops surrounding punctuation/emoji and collapses whitespace,
+// so "Stop!" and " berhenti. " match but "jangan stop dulu" does not
+func normalizeOptOutText(text string) string {
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// MatchOptOutKeyword reports whether body is an opt-out request and returns the matched keyword.
+// The whole message must be the keyword - a sentence that merely contains "stop" is not an opt-out.
+func MatchOptOutKeyword(body string) (string, bool) {
+	normalized := normalizeOptOutText(body)
+	if normalized == "" {
+		return "", false
+	}
+	for _, keyword := range optOutKeywords() {
+		if normalized == keyword {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// ShouldConfirmOptOut - AI_OPT_OUT_CONFIRM_REPLY=false records the opt-out silently
+func ShouldConfirmOptOut() bool {
+	return config.GetEnvBool("AI_OPT_OUT_CONFIRM_REPLY", true)
+}
+
+// OptOutConfirmMessage returns the reply confirming the opt-out (AI_OPT_OUT_CONFIRM_MESSAGE)
+func OptOutConfirmMessage() string {
+	if msg := config.GetEnvString("AI_OPT_OUT_CONFIRM_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultOptOutConfirmMessage
+}
+
+// optOutContactKey reduces a JID or phone number to its digits ("62812...@s.whatsapp.net" -> "62812...")
+func optOutContactKey(contact string) string {
+	if at := strings.Index(contact, "@"); at >= 0 {
+		contact = contact[:at]
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, contact)
+}
+
+// RecordOptOut stores an opt-out for the session + contact (repeat opt-outs keep the first record)
+func RecordOptOut(sessionTok, contact, keyword, messageID string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	optOut := models.ContactOptOut{
+		SessionTok: sessionTok,
+		Contact:    optOutContactKey(contact),
+		Keyword:    keyword,
+		MessageID:  messageID,
+	}
+	if optOut.Contact == "" {
+		return fmt.Errorf("invalid contact: %q", contact)
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&optOut).Error; err != nil {
+		return fmt.Errorf("failed to record opt-out: %w", err)
+	}
+	return nil
+}
+
+// IsOptedOut reports whether the contact opted out of messages from this session.
+// Lookup errors count as not opted out, so a DB hiccup never blocks the pipeline.
+func IsOptedOut(sessionTok, contact string) bool {
+	db := database.GetDB()
+	key := optOutContactKey(contact)
+	if db == nil || key == "" {
+		return false
+	}
+
+	var count int64
+	if err := db.Model(&models.ContactOptOut{}).
+		Where("session_tok = ? AND contact = ?", sessionTok, key).
+		Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// ListOptOuts returns opt-outs, newest first, optionally filtered by session and/or contact
+func ListOptOuts(sessionTok, contact string, limit int) ([]models.ContactOptOut, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	query := db.Model(&models.ContactOptOut{})
+	if sessionTok != "" {
+		query = query.Where("session_tok = ?", sessionTok)
+	}
+	if contact != "" {
+		query = query.Where("contact = ?", optOutContactKey(contact))
+	}
+
+	var optOuts []models.ContactOptOut
+	if err := query.Order("created_at DESC").Limit(limit).Find(&optOuts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list opt-outs: %w", err)
+	}
+	return optOuts, nil
+}
+
+// ClearOptOuts removes opt-outs for a session (all contacts when contact is empty) so sends resume
+func ClearOptOuts(sessionTok, contact string) (int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	query := db.Where("session_tok = ?", sessionTok)
+	if contact != "" {
+		query = query.Where("contact = ?", optOutContactKey(contact))
+	}
+	result := query.Delete(&models.ContactOptOut{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to clear opt-outs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
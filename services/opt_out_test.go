@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestMatchOptOutKeyword(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords string
+		body     string
+		want     string
+		wantOK   bool
+	}{
+		{"stop", "", "STOP", "stop", true},
+		{"case and punctuation", "", "  Berhenti!! ", "berhenti", true},
+		{"multi-word keyword", "", "stop   all", "stop all", true},
+		{"sentence containing stop", "", "jangan stop layanan saya", "", false},
+		{"normal message", "", "halo kak", "", false},
+		{"custom keywords", "keluar, unreg", "UNREG", "unreg", true},
+		{"custom keywords replace defaults", "keluar", "stop", "", false},
+		{"disabled", "off", "stop", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_OPT_OUT_KEYWORDS", tt.keywords)
+			got, ok := MatchOptOutKeyword(tt.body)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("MatchOptOutKeyword(%q) = %q, %v; want %q, %v", tt.body, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOptOutContactKey(t *testing.T) {
+	for in, want := range map[string]string{
+		"6281234567890@s.whatsapp.net": "6281234567890",
+		"+62 812-3456-7890":            "6281234567890",
+		"6281234567890":                "6281234567890",
+		"":                             "",
+	} {
+		if got := optOutContactKey(in); got != want {
+			t.Errorf("optOutContactKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRecordAndClearOptOut(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ContactOptOut{}); err != nil {
+		t.Fatalf("failed to migrate contact_opt_outs: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.ContactOptOut{}) })
+
+	jid := "6281234567890@s.whatsapp.net"
+	if IsOptedOut(sessionTok, jid) {
+		t.Fatalf("contact opted out before any STOP")
+	}
+
+	// Recorded from the webhook JID, matched against the bulk campaign phone format; repeats are no-ops
+	for i := 0; i < 2; i++ {
+		if err := RecordOptOut(sessionTok, jid, "stop", "MSG1"); err != nil {
+			t.Fatalf("RecordOptOut: %v", err)
+		}
+	}
+	if !IsOptedOut(sessionTok, "6281234567890") {
+		t.Errorf("opt-out not found by phone number")
+	}
+	if IsOptedOut("other-session", jid) {
+		t.Errorf("opt-out leaked to another session")
+	}
+
+	optOuts, err := ListOptOuts(sessionTok, "", 10)
+	if err != nil || len(optOuts) != 1 || optOuts[0].Keyword != "stop" {
+		t.Fatalf("ListOptOuts = %+v, %v; want one stop record", optOuts, err)
+	}
+
+	removed, err := ClearOptOuts(sessionTok, jid)
+	if err != nil || removed != 1 {
+		t.Fatalf("ClearOptOuts = %d, %v; want 1", removed, err)
+	}
+	if IsOptedOut(sessionTok, jid) {
+		t.Errorf("contact still opted out after clear")
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPhone is returned by ValidatePhone for recipients the WA server can't deliver to
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+const (
+	userJIDServer  = "s.whatsapp.net"
+	groupJIDServer = "g.us"
+
+	// E.164 allows up to 15 digits; anything shorter than 7 is not a real subscriber number
+	minPhoneDigits = 7
+	maxPhoneDigits = 15
+)
+
+// NormalizeJID strips the device / agent suffix from a JID and keeps its server:
+// "6281233784490:24@s.whatsapp.net" → "6281233784490@s.whatsapp.net".
+// Values without "@" are returned trimmed but otherwise unchanged.
+func NormalizeJID(jid string) string {
+	jid = strings.TrimSpace(jid)
+	at := strings.LastIndex(jid, "@")
+	if at < 0 {
+		return jid
+	}
+	user, server := jid[:at], jid[at:]
+	if i := strings.IndexAny(user, ":_"); i >= 0 && server != "@"+groupJIDServer {
+		user = user[:i]
+	}
+	return user + server
+}
+
+// NormalizePhone turns a phone number or JID into the form the WA server expects as "Phone".
+// User JIDs and free-form numbers ("+62 812-3456-7890", "6281...:3@s.whatsapp.net") become bare
+// digits; group and other non-user JIDs ("1203...@g.us", "...@lid") keep their server so they
+// still route to the right chat.
+func NormalizePhone(phone string) string {
+	jid := NormalizeJID(phone)
+	if at := strings.LastIndex(jid, "@"); at >= 0 {
+		server := jid[at+1:]
+		if server != userJIDServer && server != "c.us" {
+			return jid
+		}
+		jid = jid[:at]
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, jid)
+	// International "00" prefix is the same as "+"
+	if strings.HasPrefix(jid, "00") {
+		digits = strings.TrimPrefix(digits, "00")
+	}
+	return digits
+}
+
+// ValidatePhone normalizes phone and rejects values that can't be a WhatsApp recipient:
+// letters or stray symbols, too few / too many digits, local numbers without a country code
+// ("0812...") and malformed group JIDs.
+func ValidatePhone(phone string) (string, error) {
+	raw := strings.TrimSpace(phone)
+	if raw == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidPhone)
+	}
+
+	normalized := NormalizePhone(raw)
+	if at := strings.LastIndex(normalized, "@"); at >= 0 {
+		user, server := normalized[:at], normalized[at+1:]
+		if server == groupJIDServer {
+			if user == "" || strings.Trim(user, "0123456789-") != "" {
+				return "", fmt.Errorf("%w: malformed group JID %q", ErrInvalidPhone, raw)
+			}
+			return normalized, nil
+		}
+		if user == "" || server == "" {
+			return "", fmt.Errorf("%w: malformed JID %q", ErrInvalidPhone, raw)
+		}
+		return normalized, nil
+	}
+
+	// Only formatting characters may be dropped - "62812abc" is a typo, not a number
+	number := NormalizeJID(raw)
+	if at := strings.LastIndex(number, "@"); at >= 0 {
+		number = number[:at]
+	}
+	if strings.Trim(number, "0123456789+-() .") != "" {
+		return "", fmt.Errorf("%w: %q contains non-digit characters", ErrInvalidPhone, raw)
+	}
+
+	switch {
+	case len(normalized) < minPhoneDigits || len(normalized) > maxPhoneDigits:
+		return "", fmt.Errorf("%w: %q must have %d-%d digits", ErrInvalidPhone, raw, minPhoneDigits, maxPhoneDigits)
+	case normalized[0] == '0':
+		return "", fmt.Errorf("%w: %q has no country code", ErrInvalidPhone, raw)
+	}
+	return normalized, nil
+}
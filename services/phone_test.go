@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeJID(t *testing.T) {
+	cases := map[string]string{
+		"6281233784490:24@s.whatsapp.net": "6281233784490@s.whatsapp.net",
+		"6281233784490@s.whatsapp.net":    "6281233784490@s.whatsapp.net",
+		"120363025246125888@g.us":         "120363025246125888@g.us",
+		" 6281233784490 ":                 "6281233784490",
+	}
+	for in, want := range cases {
+		if got := NormalizeJID(in); got != want {
+			t.Errorf("NormalizeJID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidatePhone(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		invalid bool
+	}{
+		{in: "6281233784490", want: "6281233784490"},
+		{in: "+62 812-3378-4490", want: "6281233784490"},
+		{in: "(62) 812 3378 4490", want: "6281233784490"},
+		{in: "006281233784490", want: "6281233784490"},
+		{in: "6281233784490@s.whatsapp.net", want: "6281233784490"},
+		{in: "6281233784490:24@s.whatsapp.net", want: "6281233784490"},
+		{in: "6281233784490@c.us", want: "6281233784490"},
+		{in: "120363025246125888@g.us", want: "120363025246125888@g.us"},
+		{in: "6281233784490-1612345678@g.us", want: "6281233784490-1612345678@g.us"},
+		{in: "", invalid: true},
+		{in: "   ", invalid: true},
+		{in: "12345", invalid: true},
+		{in: "62812337844901234567", invalid: true},
+		{in: "081233784490", invalid: true},
+		{in: "62812abc4490", invalid: true},
+		{in: "not-a-number", invalid: true},
+		{in: "@s.whatsapp.net", invalid: true},
+		{in: "group-name@g.us", invalid: true},
+		{in: "@g.us", invalid: true},
+	}
+	for _, tc := range cases {
+		got, err := ValidatePhone(tc.in)
+		if tc.invalid {
+			if !errors.Is(err, ErrInvalidPhone) {
+				t.Errorf("ValidatePhone(%q) = %q, %v; want ErrInvalidPhone", tc.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ValidatePhone(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
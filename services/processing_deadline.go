@@ -0,0 +1,96 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Actions when a job exceeds AI_PROCESSING_DEADLINE_MS
+const (
+	DeadlineActionContinue = "continue" // send the deferral message, keep waiting for the LLM reply
+	DeadlineActionAbandon  = "abandon"  // send the deferral message and cancel the job
+)
+
+const defaultProcessingDeadlineMessage = "Mohon maaf, pesan Anda butuh waktu sedikit lebih lama untuk kami proses. Kami akan segera membalas 🙏"
+
+// ProcessingDeadline returns how long a job may run before the customer gets a deferral message
+// (AI_PROCESSING_DEADLINE_MS, default 0 = disabled)
+func ProcessingDeadline() time.Duration {
+	ms := config.GetEnvInt("AI_PROCESSING_DEADLINE_MS", 0)
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ProcessingDeadlineAction returns AI_PROCESSING_DEADLINE_ACTION (continue | abandon, default continue)
+func ProcessingDeadlineAction() string {
+	if strings.EqualFold(config.GetEnvString("AI_PROCESSING_DEADLINE_ACTION", ""), DeadlineActionAbandon) {
+		return DeadlineActionAbandon
+	}
+	return DeadlineActionContinue
+}
+
+// ProcessingDeadlineMessage returns the deferral message (AI_PROCESSING_DEADLINE_MESSAGE)
+func ProcessingDeadlineMessage() string {
+	if msg := config.GetEnvString("AI_PROCESSING_DEADLINE_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultProcessingDeadlineMessage
+}
+
+// DeadlineWatcher runs onDeadline once if it isn't stopped within the deadline
+type DeadlineWatcher struct {
+	timer   *time.Timer
+	mu      sync.Mutex
+	fired   bool
+	stopped bool          // stopped before firing
+	done    chan struct{} // closed when onDeadline returned
+}
+
+// WatchProcessingDeadline calls onDeadline after deadline unless Stop is called first.
+// A deadline <= 0 never fires.
+func WatchProcessingDeadline(deadline time.Duration, onDeadline func()) *DeadlineWatcher {
+	w := &DeadlineWatcher{done: make(chan struct{})}
+	if deadline <= 0 {
+		return w
+	}
+	w.timer = time.AfterFunc(deadline, func() {
+		w.mu.Lock()
+		w.fired = true
+		w.mu.Unlock()
+
+		defer close(w.done)
+		onDeadline()
+	})
+	return w
+}
+
+// Stop cancels the deadline and reports whether it already fired.
+// If onDeadline is running, Stop waits for it so the deferral message is sent before the reply.
+func (w *DeadlineWatcher) Stop() bool {
+	if w.timer == nil {
+		return false
+	}
+
+	w.mu.Lock()
+	if w.stopped || w.timer.Stop() {
+		w.stopped = true
+		w.mu.Unlock()
+		return false
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	return true
+}
+
+// Fired reports whether the deadline has passed (onDeadline may still be running)
+func (w *DeadlineWatcher) Fired() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.fired
+}
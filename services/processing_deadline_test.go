@@ -0,0 +1,86 @@
+package services
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchProcessingDeadlineFiresOnSlowProcessing(t *testing.T) {
+	var calls int32
+	watcher := WatchProcessingDeadline(20*time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+
+	time.Sleep(60 * time.Millisecond) // slow "LLM call"
+
+	if !watcher.Fired() {
+		t.Error("expected deadline to have fired")
+	}
+	if !watcher.Stop() {
+		t.Error("Stop should report the deadline fired")
+	}
+	if calls != 1 {
+		t.Errorf("onDeadline called %d times, want 1", calls)
+	}
+}
+
+func TestWatchProcessingDeadlineFastProcessing(t *testing.T) {
+	var calls int32
+	watcher := WatchProcessingDeadline(200*time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+
+	if watcher.Stop() {
+		t.Error("Stop before the deadline must report not fired")
+	}
+	if watcher.Stop() {
+		t.Error("second Stop must not block or report fired")
+	}
+	time.Sleep(250 * time.Millisecond)
+	if calls != 0 || watcher.Fired() {
+		t.Errorf("onDeadline ran %d times after Stop", calls)
+	}
+}
+
+func TestWatchProcessingDeadlineStopWaitsForMessage(t *testing.T) {
+	var sent int32
+	watcher := WatchProcessingDeadline(10*time.Millisecond, func() {
+		time.Sleep(80 * time.Millisecond) // sending the deferral message
+		atomic.StoreInt32(&sent, 1)
+	})
+
+	time.Sleep(30 * time.Millisecond) // LLM returns while the message is being sent
+	if !watcher.Stop() {
+		t.Fatal("expected deadline to have fired")
+	}
+	if atomic.LoadInt32(&sent) != 1 {
+		t.Error("Stop returned before the deferral message was sent - reply could overtake it")
+	}
+}
+
+func TestWatchProcessingDeadlineDisabled(t *testing.T) {
+	t.Setenv("AI_PROCESSING_DEADLINE_MS", "0")
+	watcher := WatchProcessingDeadline(ProcessingDeadline(), func() { t.Error("disabled deadline fired") })
+	time.Sleep(10 * time.Millisecond)
+	if watcher.Stop() || watcher.Fired() {
+		t.Error("disabled deadline must never fire")
+	}
+}
+
+func TestProcessingDeadlineConfig(t *testing.T) {
+	t.Setenv("AI_PROCESSING_DEADLINE_MS", "15000")
+	t.Setenv("AI_PROCESSING_DEADLINE_ACTION", "Abandon")
+	t.Setenv("AI_PROCESSING_DEADLINE_MESSAGE", "Sebentar ya kak")
+
+	if got := ProcessingDeadline(); got != 15*time.Second {
+		t.Errorf("ProcessingDeadline() = %v, want 15s", got)
+	}
+	if got := ProcessingDeadlineAction(); got != DeadlineActionAbandon {
+		t.Errorf("ProcessingDeadlineAction() = %q, want abandon", got)
+	}
+	if got := ProcessingDeadlineMessage(); got != "Sebentar ya kak" {
+		t.Errorf("ProcessingDeadlineMessage() = %q", got)
+	}
+
+	t.Setenv("AI_PROCESSING_DEADLINE_ACTION", "bogus")
+	if got := ProcessingDeadlineAction(); got != DeadlineActionContinue {
+		t.Errorf("unknown action = %q, want continue", got)
+	}
+}
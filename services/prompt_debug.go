@@ -0,0 +1,152 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// Prompt dump sinks (DEBUG_DUMP_PROMPT_SINK)
+const (
+	PromptDumpSinkDB   = "db"   // ai_prompt_debug table (default)
+	PromptDumpSinkFile = "file" // one text file per job in DEBUG_DUMP_PROMPT_DIR
+)
+
+// ShouldDumpPrompts reports whether full LLM prompts are stored per job (DEBUG_DUMP_PROMPT, default false)
+func ShouldDumpPrompts() bool {
+	return config.GetEnvBool("DEBUG_DUMP_PROMPT", false)
+}
+
+func promptDumpSink() string {
+	if strings.EqualFold(config.GetEnvString("DEBUG_DUMP_PROMPT_SINK", ""), PromptDumpSinkFile) {
+		return PromptDumpSinkFile
+	}
+	return PromptDumpSinkDB
+}
+
+func promptDumpDir() string {
+	return config.GetEnvString("DEBUG_DUMP_PROMPT_DIR", "prompt-dumps")
+}
+
+// promptDumpRetention is how long dumps are kept (DEBUG_DUMP_PROMPT_RETENTION_HOURS, default 24)
+func promptDumpRetention() time.Duration {
+	hours := config.GetEnvInt("DEBUG_DUMP_PROMPT_RETENTION_HOURS", 24)
+	if hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// DumpPrompt stores the full system prompt + user message sent to the LLM for a job.
+// No-op unless DEBUG_DUMP_PROMPT=true; failures are logged, never fail the job.
+func DumpPrompt(jobID uint, sessionTok, messageID string, maxMessages int, ctxData *ContextData) {
+	if !ShouldDumpPrompts() || ctxData == nil {
+		return
+	}
+
+	dump := models.AIPromptDebug{
+		JobID:        jobID,
+		SessionTok:   sessionTok,
+		MessageID:    messageID,
+		MaxMessages:  maxMessages,
+		SystemPrompt: ctxData.SystemPrompt,
+		UserMessage:  ctxData.UserMessage,
+		CreatedAt:    time.Now(),
+	}
+
+	var err error
+	if promptDumpSink() == PromptDumpSinkFile {
+		err = writePromptDumpFile(promptDumpDir(), &dump)
+	} else {
+		err = database.GetDB().Create(&dump).Error
+	}
+	if err != nil {
+		log.Printf("⚠️  [PromptDebug] Failed to dump prompt for job #%d: %v", jobID, err)
+	}
+}
+
+// writePromptDumpFile writes dump as job_<id>_<unixnano>.txt in dir
+func writePromptDumpFile(dir string, dump *models.AIPromptDebug) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create dump dir: %w", err)
+	}
+
+	content := fmt.Sprintf("job: %d\nsession: %s\nmessage: %s\nmax_messages: %d\ncreated_at: %s\n\n=== SYSTEM PROMPT ===\n%s\n\n=== USER MESSAGE ===\n%s\n",
+		dump.JobID, dump.SessionTok, dump.MessageID, dump.MaxMessages, dump.CreatedAt.Format(time.RFC3339),
+		dump.SystemPrompt, dump.UserMessage)
+
+	name := fmt.Sprintf("job_%d_%d.txt", dump.JobID, dump.CreatedAt.UnixNano())
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write dump file: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpiredPromptDumps deletes dumps (table rows and files) older than the retention period
+func PurgeExpiredPromptDumps() (int64, error) {
+	cutoff := time.Now().Add(-promptDumpRetention())
+
+	result := database.GetDB().Where("created_at < ?", cutoff).Delete(&models.AIPromptDebug{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge prompt dumps: %w", result.Error)
+	}
+
+	files, err := purgePromptDumpFiles(promptDumpDir(), cutoff)
+	if err != nil {
+		return result.RowsAffected, err
+	}
+
+	purged := result.RowsAffected + files
+	if purged > 0 {
+		log.Printf("🧹 Purged %d prompt dumps older than %v", purged, promptDumpRetention())
+	}
+	return purged, nil
+}
+
+// purgePromptDumpFiles removes job_*.txt dumps in dir modified before cutoff
+func purgePromptDumpFiles(dir string, cutoff time.Time) (int64, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "job_*.txt"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list prompt dump files: %w", err)
+	}
+
+	var removed int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// RunPromptDumpRetention purges expired prompt dumps and context decisions every hour until stop is closed
+func RunPromptDumpRetention(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if _, err := PurgeExpiredPromptDumps(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+		if _, err := PurgeExpiredContextDecisions(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
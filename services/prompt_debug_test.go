@@ -0,0 +1,107 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestDumpPromptFileSink(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEBUG_DUMP_PROMPT", "true")
+	t.Setenv("DEBUG_DUMP_PROMPT_SINK", "file")
+	t.Setenv("DEBUG_DUMP_PROMPT_DIR", dir)
+
+	DumpPrompt(42, "sess", "wa_msg_1", 10, &ContextData{SystemPrompt: "full system prompt", UserMessage: "halo"})
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "job_42_*.txt"))
+	if len(paths) != 1 {
+		t.Fatalf("expected 1 dump file, got %v", paths)
+	}
+	content, _ := os.ReadFile(paths[0])
+	for _, want := range []string{"job: 42", "message: wa_msg_1", "max_messages: 10", "full system prompt", "halo"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("dump missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestDumpPromptDisabled(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEBUG_DUMP_PROMPT", "false")
+	t.Setenv("DEBUG_DUMP_PROMPT_SINK", "file")
+	t.Setenv("DEBUG_DUMP_PROMPT_DIR", dir)
+
+	DumpPrompt(1, "sess", "wa_msg_1", 10, &ContextData{SystemPrompt: "x"})
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no dump when disabled, got %d files", len(entries))
+	}
+}
+
+func TestPurgePromptDumpFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "job_1_1.txt")
+	newFile := filepath.Join(dir, "job_2_2.txt")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{oldFile, newFile, other} {
+		os.WriteFile(path, []byte("x"), 0o600)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(oldFile, past, past)
+	os.Chtimes(other, past, past)
+
+	removed, err := purgePromptDumpFiles(dir, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("purgePromptDumpFiles: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed = %d, want 1", removed)
+	}
+	if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+		t.Error("expired dump should be removed")
+	}
+	for _, path := range []string{newFile, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should be kept: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestDumpPromptDBSinkAndRetention(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.AIPromptDebug{}); err != nil {
+		t.Fatalf("failed to migrate ai_prompt_debug: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.AIPromptDebug{}) })
+
+	t.Setenv("DEBUG_DUMP_PROMPT", "true")
+	t.Setenv("DEBUG_DUMP_PROMPT_SINK", "db")
+	t.Setenv("DEBUG_DUMP_PROMPT_DIR", t.TempDir())
+	DumpPrompt(7, sessionTok, "wa_msg_7", 5, &ContextData{SystemPrompt: "prompt", UserMessage: "pesan"})
+
+	var dump models.AIPromptDebug
+	if err := db.Where("session_tok = ? AND job_id = ?", sessionTok, 7).First(&dump).Error; err != nil {
+		t.Fatalf("dump not stored: %v", err)
+	}
+	if dump.SystemPrompt != "prompt" || dump.UserMessage != "pesan" || dump.MaxMessages != 5 {
+		t.Errorf("unexpected dump: %+v", dump)
+	}
+
+	// Older than retention -> purged
+	db.Model(&dump).Update("created_at", time.Now().Add(-48*time.Hour))
+	if _, err := PurgeExpiredPromptDumps(); err != nil {
+		t.Fatalf("PurgeExpiredPromptDumps: %v", err)
+	}
+	var count int64
+	db.Model(&models.AIPromptDebug{}).Where("session_tok = ?", sessionTok).Count(&count)
+	if count != 0 {
+		t.Errorf("expired dump not purged (%d left)", count)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for the /wa/session/qr cache (override via .env)
+const (
+	defaultQRCacheTTLMs = 5000
+	// maxQRCacheTTL stays under WhatsApp's QR rotation (60s for the first code, 20s after), so a
+	// cached code has always been replaced by the WA server at least a few seconds before it expires
+	maxQRCacheTTL = 15 * time.Second
+)
+
+// cachedQR is a WA server /session/qr response and when it stops being served
+type cachedQR struct {
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// qrCache holds the last QR response per session token, so clients polling /wa/session/qr
+// while the user scans don't each hit the WA server
+var qrCache = struct {
+	sync.Mutex
+	entries map[string]cachedQR
+}{entries: make(map[string]cachedQR)}
+
+// qrCacheTTL - WA_QR_CACHE_TTL_MS (default 5000, 0 = no caching), capped at maxQRCacheTTL
+func qrCacheTTL() time.Duration {
+	ms := config.GetEnvInt("WA_QR_CACHE_TTL_MS", defaultQRCacheTTLMs)
+	if ms <= 0 {
+		return 0
+	}
+	if ttl := time.Duration(ms) * time.Millisecond; ttl < maxQRCacheTTL {
+		return ttl
+	}
+	return maxQRCacheTTL
+}
+
+// hasQRCode reports whether a WA server /session/qr body carries a code ({"data":{"QRCode":"..."}});
+// an empty one (session logging in, code not generated yet) is not worth caching
+func hasQRCode(body []byte) bool {
+	var parsed struct {
+		Data struct {
+			QRCode string `json:"QRCode"`
+		} `json:"data"`
+	}
+	return json.Unmarshal(body, &parsed) == nil && parsed.Data.QRCode != ""
+}
+
+// GetCachedQR returns the cached QR response of a session while it is still fresh.
+// Expired entries are dropped on the way.
+func GetCachedQR(sessionToken string) (body []byte, contentType string, ok bool) {
+	qrCache.Lock()
+	defer qrCache.Unlock()
+
+	now := time.Now()
+	for token, entry := range qrCache.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(qrCache.entries, token)
+		}
+	}
+	entry, ok := qrCache.entries[sessionToken]
+	if !ok {
+		return nil, "", false
+	}
+	return entry.body, entry.contentType, true
+}
+
+// StoreQR caches a successful WA server /session/qr response for qrCacheTTL. Returns false when
+// nothing was cached (caching off or no code in the body).
+func StoreQR(sessionToken, contentType string, body []byte) bool {
+	ttl := qrCacheTTL()
+	if ttl == 0 || !hasQRCode(body) {
+		return false
+	}
+
+	qrCache.Lock()
+	defer qrCache.Unlock()
+	qrCache.entries[sessionToken] = cachedQR{
+		contentType: contentType,
+		body:        append([]byte(nil), body...),
+		expiresAt:   time.Now().Add(ttl),
+	}
+	return true
+}
+
+// InvalidateQRCache drops a session's cached QR (connected, disconnected or logged out)
+func InvalidateQRCache(sessionToken string) {
+	qrCache.Lock()
+	delete(qrCache.entries, sessionToken)
+	qrCache.Unlock()
+}
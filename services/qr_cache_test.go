@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQRCacheTTL(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", 5 * time.Second},
+		{"2000", 2 * time.Second},
+		{"0", 0},
+		{"60000", maxQRCacheTTL},
+	}
+	for _, tt := range tests {
+		t.Setenv("WA_QR_CACHE_TTL_MS", tt.env)
+		if got := qrCacheTTL(); got != tt.want {
+			t.Errorf("WA_QR_CACHE_TTL_MS=%q: ttl = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestQRCacheStoreAndExpiry(t *testing.T) {
+	t.Cleanup(func() { InvalidateQRCache("s1") })
+	t.Setenv("WA_QR_CACHE_TTL_MS", "50")
+	qr := []byte(`{"code":200,"data":{"QRCode":"data:image/png;base64,AAA"},"success":true}`)
+
+	if StoreQR("s1", "application/json", []byte(`{"code":200,"data":{"QRCode":""},"success":true}`)) {
+		t.Error("a response without a QR code should not be cached")
+	}
+	if !StoreQR("s1", "application/json", qr) {
+		t.Fatal("expected the QR response to be cached")
+	}
+	body, contentType, ok := GetCachedQR("s1")
+	if !ok || string(body) != string(qr) || contentType != "application/json" {
+		t.Fatalf("cached = %q %q %v", body, contentType, ok)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, _, ok := GetCachedQR("s1"); ok {
+		t.Error("an expired QR must not be served")
+	}
+
+	t.Setenv("WA_QR_CACHE_TTL_MS", "0")
+	if StoreQR("s1", "application/json", qr) {
+		t.Error("WA_QR_CACHE_TTL_MS=0 disables caching")
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// defaultQueueBusyMessage is sent instead of queueing when the queue is overloaded
+const defaultQueueBusyMessage = "Mohon maaf, saat ini kami sedang menerima banyak pesan. Silakan kirim ulang pesan Anda beberapa saat lagi."
+
+// QueueStats is the last measured AI job queue depth
+type QueueStats struct {
+	Pending    int64     `json:"pending"`
+	Processing int64     `json:"processing"`
+	Threshold  int       `json:"threshold"` // AI_QUEUE_MAX_PENDING (0 = no limit)
+	Overloaded bool      `json:"overloaded"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// QueueMonitor tracks AI job queue depth for backpressure and health checks
+type QueueMonitor struct {
+	mu    sync.RWMutex
+	stats QueueStats
+}
+
+// queueMonitor is the process-wide monitor, fed by the AI worker
+var queueMonitor = &QueueMonitor{}
+
+// GetQueueStats returns the last measured queue depth
+func GetQueueStats() QueueStats {
+	queueMonitor.mu.RLock()
+	defer queueMonitor.mu.RUnlock()
+	return queueMonitor.stats
+}
+
+// IsQueueOverloaded reports whether new jobs should be rejected:
+// pending depth is above AI_QUEUE_MAX_PENDING and AI_QUEUE_REJECT_WHEN_OVERLOADED is enabled
+func IsQueueOverloaded() bool {
+	return GetQueueStats().Overloaded && config.GetEnvBool("AI_QUEUE_REJECT_WHEN_OVERLOADED", false)
+}
+
+// QueueBusyMessage is the reply sent when a message is rejected due to backpressure (AI_QUEUE_BUSY_MESSAGE)
+func QueueBusyMessage() string {
+	if msg := config.GetEnvString("AI_QUEUE_BUSY_MESSAGE", ""); msg != "" {
+		return msg
+	}
+	return defaultQueueBusyMessage
+}
+
+// update records a new measurement and returns the previous overloaded state
+func (m *QueueMonitor) update(pending, processing int64, threshold int) (wasOverloaded bool, stats QueueStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wasOverloaded = m.stats.Overloaded
+	m.stats = QueueStats{
+		Pending:    pending,
+		Processing: processing,
+		Threshold:  threshold,
+		Overloaded: threshold > 0 && pending > int64(threshold),
+		CheckedAt:  time.Now(),
+	}
+	return wasOverloaded, m.stats
+}
+
+// CheckQueueDepth counts pending/processing jobs, updates the monitor and logs threshold crossings
+func CheckQueueDepth() (QueueStats, error) {
+	db := database.GetDB()
+
+	var pending, processing int64
+	if err := db.Model(&models.AIJob{}).Where("status = ?", "pending").Count(&pending).Error; err != nil {
+		return GetQueueStats(), err
+	}
+	if err := db.Model(&models.AIJob{}).Where("status = ?", "processing").Count(&processing).Error; err != nil {
+		return GetQueueStats(), err
+	}
+
+	threshold := config.GetEnvInt("AI_QUEUE_MAX_PENDING", 500)
+	wasOverloaded, stats := queueMonitor.update(pending, processing, threshold)
+
+	switch {
+	case stats.Overloaded && !wasOverloaded:
+		log.Printf("🚨 [Queue] Backlog above threshold: %d pending jobs (max %d, processing %d)", pending, threshold, processing)
+	case stats.Overloaded:
+		log.Printf("⚠️  [Queue] Still overloaded: %d pending jobs (max %d)", pending, threshold)
+	case wasOverloaded:
+		log.Printf("✅ [Queue] Backlog recovered: %d pending jobs (max %d)", pending, threshold)
+	}
+
+	return stats, nil
+}
+
+// MonitorQueueDepth checks queue depth every AI_QUEUE_MONITOR_INTERVAL_MS (default 10s) until stop is closed
+func MonitorQueueDepth(stop <-chan struct{}) {
+	intervalMs := config.GetEnvInt("AI_QUEUE_MONITOR_INTERVAL_MS", 10000)
+	if intervalMs <= 0 {
+		intervalMs = 10000
+	}
+	ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if _, err := CheckQueueDepth(); err != nil {
+			log.Printf("⚠️  [Queue] Failed to check queue depth: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
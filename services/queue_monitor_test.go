@@ -0,0 +1,48 @@
+package services
+
+import "testing"
+
+func TestQueueMonitorUpdate(t *testing.T) {
+	m := &QueueMonitor{}
+
+	was, stats := m.update(100, 1, 500)
+	if was || stats.Overloaded {
+		t.Fatalf("100/500 pending must not be overloaded")
+	}
+
+	was, stats = m.update(501, 1, 500)
+	if was || !stats.Overloaded {
+		t.Fatalf("crossing the threshold: was=%v overloaded=%v, want false/true", was, stats.Overloaded)
+	}
+
+	was, stats = m.update(800, 1, 500)
+	if !was || !stats.Overloaded {
+		t.Errorf("still overloaded: was=%v overloaded=%v, want true/true", was, stats.Overloaded)
+	}
+
+	was, stats = m.update(20, 1, 500)
+	if !was || stats.Overloaded {
+		t.Errorf("recovered: was=%v overloaded=%v, want true/false", was, stats.Overloaded)
+	}
+
+	if _, stats = m.update(100000, 1, 0); stats.Overloaded {
+		t.Errorf("threshold 0 disables backpressure")
+	}
+}
+
+func TestIsQueueOverloadedRequiresRejectFlag(t *testing.T) {
+	previous := GetQueueStats()
+	t.Cleanup(func() { queueMonitor.stats = previous })
+
+	queueMonitor.update(900, 0, 500)
+
+	t.Setenv("AI_QUEUE_REJECT_WHEN_OVERLOADED", "false")
+	if IsQueueOverloaded() {
+		t.Errorf("overloaded queue must only warn when rejecting is disabled")
+	}
+
+	t.Setenv("AI_QUEUE_REJECT_WHEN_OVERLOADED", "true")
+	if !IsQueueOverloaded() {
+		t.Errorf("overloaded queue with rejecting enabled should reject")
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// ShouldStoreRawWebhooks reports whether inbound webhook payloads are persisted (AI_STORE_RAW_WEBHOOKS, default false)
+func ShouldStoreRawWebhooks() bool {
+	return config.GetEnvBool("AI_STORE_RAW_WEBHOOKS", false)
+}
+
+// rawWebhookRetention is how long raw payloads are kept (AI_RAW_WEBHOOK_RETENTION_HOURS, default 72)
+func rawWebhookRetention() time.Duration {
+	hours := config.GetEnvInt("AI_RAW_WEBHOOK_RETENTION_HOURS", 72)
+	if hours <= 0 {
+		hours = 72
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// SaveRawWebhook stores the raw webhook JSON keyed by message ID
+func SaveRawWebhook(messageID, sessionTok string, payload []byte) error {
+	raw := models.RawWebhook{
+		MessageID:  messageID,
+		SessionTok: sessionTok,
+		Payload:    string(payload),
+		CreatedAt:  time.Now(),
+	}
+	if err := database.GetDB().Create(&raw).Error; err != nil {
+		return fmt.Errorf("failed to save raw webhook: %w", err)
+	}
+	return nil
+}
+
+// GetRawWebhook returns the latest stored payload for a message ID (nil if none)
+func GetRawWebhook(messageID string) (*models.RawWebhook, error) {
+	var raw models.RawWebhook
+	result := database.GetDB().
+		Where("message_id = ?", messageID).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&raw)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to load raw webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &raw, nil
+}
+
+// PurgeExpiredRawWebhooks deletes payloads older than the retention period
+func PurgeExpiredRawWebhooks() (int64, error) {
+	cutoff := time.Now().Add(-rawWebhookRetention())
+	result := database.GetDB().Where("created_at < ?", cutoff).Delete(&models.RawWebhook{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge raw webhooks: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🧹 Purged %d raw webhook payloads older than %v", result.RowsAffected, rawWebhookRetention())
+	}
+	return result.RowsAffected, nil
+}
+
+// RunRawWebhookRetention purges expired raw payloads every hour until stop is closed
+func RunRawWebhookRetention(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if _, err := PurgeExpiredRawWebhooks(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// markReadWindow returns AI_MARK_READ_WINDOW_MS: how long a chat's read-marking waits for more
+// triggers before running (default 0 = run at once; concurrent triggers are still coalesced)
+func markReadWindow() time.Duration {
+	ms := config.GetEnvInt("AI_MARK_READ_WINDOW_MS", 0)
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// chatReadKey identifies one chat of one session
+type chatReadKey struct{ session, contact string }
+
+// chatReadBatch is one read-marking run of a chat; callers wait for done
+type chatReadBatch struct {
+	started bool // unread messages already fetched - later triggers need the next batch
+	done    chan struct{}
+}
+
+// chatReadState is the running batch of a chat and the one queued behind it
+type chatReadState struct {
+	current, next *chatReadBatch
+}
+
+// readBatcher coalesces read-marking per chat: the gateway (before each send) and the AI worker
+// (per job) trigger it for the same contact, and a chatty contact triggers it many times
+type readBatcher struct {
+	mu    sync.Mutex
+	chats map[chatReadKey]*chatReadState
+}
+
+var chatReads = &readBatcher{chats: make(map[chatReadKey]*chatReadState)}
+
+// flushChatRead marks a chat's unread messages as read (a var so tests can count runs)
+var flushChatRead = markChatReadNow
+
+// MarkChatRead marks every unread incoming message of the contact as read: one WA server markread
+// call with all message IDs and one bulk DB update. Calls for a chat already being marked join that
+// run (or the single run queued behind it), so N triggers cost at most two WA server calls, and with
+// AI_MARK_READ_WINDOW_MS rapid triggers share one. Blocks until the messages are marked.
+func MarkChatRead(sessionToken, contact string) {
+	chatReads.mark(chatReadKey{sessionToken, contact}, markReadWindow())
+}
+
+func (b *readBatcher) mark(key chatReadKey, window time.Duration) {
+	b.mu.Lock()
+	if state, ok := b.chats[key]; ok {
+		batch := state.current
+		if batch.started {
+			if state.next == nil {
+				state.next = &chatReadBatch{done: make(chan struct{})}
+			}
+			batch = state.next
+		}
+		b.mu.Unlock()
+		<-batch.done
+		return
+	}
+	state := &chatReadState{current: &chatReadBatch{done: make(chan struct{})}}
+	b.chats[key] = state
+	b.mu.Unlock()
+
+	b.run(key, state, window)
+}
+
+// run executes the chat's current batch, then hands a queued batch to a new goroutine
+func (b *readBatcher) run(key chatReadKey, state *chatReadState, window time.Duration) {
+	if window > 0 {
+		time.Sleep(window)
+	}
+
+	b.mu.Lock()
+	batch := state.current
+	batch.started = true
+	b.mu.Unlock()
+
+	flushChatRead(key.session, key.contact)
+	close(batch.done)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state.next == nil {
+		delete(b.chats, key)
+		return
+	}
+	state.current, state.next = state.next, nil
+	go b.run(key, state, window)
+}
+
+// markChatReadNow fetches the contact's unread incoming messages, marks them read on the WA Server
+// in one call and in the DB in one update. A failed markread leaves them unconfirmed for the read
+// reconciler.
+func markChatReadNow(sessionToken, contact string) {
+	unreadMessages, err := GetUnreadIncomingMessages(sessionToken, contact)
+	if err != nil {
+		log.Printf("⚠️  Failed to get unread messages: %v", err)
+		return
+	}
+	if len(unreadMessages) == 0 {
+		return
+	}
+
+	messageIDs := make([]string, len(unreadMessages))
+	for i, msg := range unreadMessages {
+		messageIDs[i] = msg.MessageID
+	}
+
+	// Clean phone number (strip JID server / device suffix)
+	phoneNumber := NormalizePhone(contact)
+	log.Printf("📖 Auto-reading %d unread messages for contact %s", len(messageIDs), phoneNumber)
+
+	confirmed := true
+	if err := markReadOnServer(sessionToken, messageIDs, phoneNumber); err != nil {
+		log.Printf("⚠️  Failed to mark messages as read via WA Server: %v", err)
+		// Continue even if markread fails - the read reconciler retries unconfirmed receipts
+		confirmed = false
+	}
+
+	if err := MarkMessagesAsReadInDB(messageIDs, confirmed); err != nil {
+		log.Printf("⚠️  Failed to mark messages as read in DB: %v", err)
+	}
+}
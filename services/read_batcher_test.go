@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// stubFlushChatRead replaces the read-marking run; block (when set) holds every run until closed
+func stubFlushChatRead(t *testing.T, block chan struct{}) *int32 {
+	var runs int32
+	previous := flushChatRead
+	t.Cleanup(func() { flushChatRead = previous })
+	flushChatRead = func(sessionToken, contact string) {
+		atomic.AddInt32(&runs, 1)
+		if block != nil {
+			<-block
+		}
+	}
+	return &runs
+}
+
+func TestReadBatcherCoalescesConcurrentTriggers(t *testing.T) {
+	block := make(chan struct{})
+	runs := stubFlushChatRead(t, block)
+	b := &readBatcher{chats: make(map[chatReadKey]*chatReadState)}
+	key := chatReadKey{"s1", "6281234567890@s.whatsapp.net"}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { defer wg.Done(); b.mark(key, 0) }()
+	for atomic.LoadInt32(runs) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Ten sends while the first run is in flight: they share one follow-up run
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() { defer wg.Done(); b.mark(key, 0) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(block)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(runs); got != 2 {
+		t.Errorf("11 triggers ran %d times, want 2 (in flight + one queued)", got)
+	}
+	// Waiters return when their run is done, the runner drops the chat right after
+	deadline := time.Now().Add(time.Second)
+	for {
+		b.mu.Lock()
+		pending := len(b.chats)
+		b.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batcher kept %d chat(s) after the runs finished", pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReadBatcherWindowMergesRapidTriggers(t *testing.T) {
+	runs := stubFlushChatRead(t, nil)
+	b := &readBatcher{chats: make(map[chatReadKey]*chatReadState)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() { defer wg.Done(); b.mark(chatReadKey{"s1", "628111"}, 50*time.Millisecond) }()
+	}
+	// Another chat is marked separately
+	wg.Add(1)
+	go func() { defer wg.Done(); b.mark(chatReadKey{"s1", "628222"}, 50*time.Millisecond) }()
+	wg.Wait()
+
+	if got := atomic.LoadInt32(runs); got != 2 {
+		t.Errorf("runs = %d, want one per chat", got)
+	}
+}
+
+func TestMarkChatReadSendsOneCallForAllUnread(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	t.Setenv("AI_MARK_READ_WINDOW_MS", "50")
+
+	contact := "6281234567890@s.whatsapp.net"
+	const unread = 5
+	for i := 0; i < unread; i++ {
+		msg := models.AIChatMessage{MessageID: fmt.Sprintf("%s_in_%d", sessionTok, i), SessionTok: sessionTok, From: contact,
+			To: "bot@s.whatsapp.net", MsgType: "text", Body: "halo", Timestamp: time.Now()}
+		if err := db.Create(&msg).Error; err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+	}
+
+	previous := markReadOnServer
+	t.Cleanup(func() { markReadOnServer = previous })
+	var mu sync.Mutex
+	var calls [][]string
+	markReadOnServer = func(sessionToken string, messageIDs []string, chatPhone string) error {
+		mu.Lock()
+		calls = append(calls, messageIDs)
+		mu.Unlock()
+		return nil
+	}
+
+	// One trigger per incoming message, as a chatty contact produces
+	var wg sync.WaitGroup
+	for i := 0; i < unread; i++ {
+		wg.Add(1)
+		go func() { defer wg.Done(); MarkChatRead(sessionTok, contact) }()
+	}
+	wg.Wait()
+
+	if len(calls) != 1 || len(calls[0]) != unread {
+		t.Fatalf("markread calls = %v, want exactly one with %d IDs", calls, unread)
+	}
+	var stillUnread int64
+	db.Model(&models.AIChatMessage{}).Where("session_tok = ? AND is_read = ?", sessionTok, false).Count(&stillUnread)
+	if stillUnread != 0 {
+		t.Errorf("%d messages still unread in the DB", stillUnread)
+	}
+}
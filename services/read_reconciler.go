@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+)
+
+// readReconcileBatch is the max unconfirmed messages retried per run
+const readReconcileBatch = 500
+
+// markReadOnServer calls the WA Server markread API (a var so tests can stub it)
+var markReadOnServer = MarkMessagesAsRead
+
+// readReconcileInterval returns AI_READ_RECONCILE_INTERVAL_SECONDS (default 60; 0 = reconciler off)
+func readReconcileInterval() time.Duration {
+	seconds := config.GetEnvInt("AI_READ_RECONCILE_INTERVAL_SECONDS", 60)
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// readReconcileMaxAttempts returns AI_READ_RECONCILE_MAX_ATTEMPTS (retries per message, default 5)
+func readReconcileMaxAttempts() int {
+	attempts := config.GetEnvInt("AI_READ_RECONCILE_MAX_ATTEMPTS", 5)
+	if attempts < 1 {
+		return 1
+	}
+	return attempts
+}
+
+// readReconcileMaxAge returns AI_READ_RECONCILE_MAX_AGE_MINUTES: older messages are not retried
+// (a read receipt for a day-old message is pointless)
+func readReconcileMaxAge() time.Duration {
+	minutes := config.GetEnvInt("AI_READ_RECONCILE_MAX_AGE_MINUTES", 60)
+	if minutes <= 0 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// ReconcileReadReceipts retries markread for incoming messages that are read in the DB but were
+// never confirmed by the WA Server, one call per session + contact. Returns how many got confirmed.
+func ReconcileReadReceipts() (int, error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	var messages []models.AIChatMessage
+	err := db.Where("is_read = ? AND read_confirmed = ? AND from_me = ? AND read_attempts < ? AND timestamp > ?",
+		true, false, false, readReconcileMaxAttempts(), time.Now().Add(-readReconcileMaxAge())).
+		Order("id ASC").
+		Limit(readReconcileBatch).
+		Find(&messages).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to load unconfirmed read receipts: %w", err)
+	}
+
+	// Group by chat: markread takes the message IDs of one contact
+	type chatKey struct{ session, from string }
+	chats := make(map[chatKey][]string)
+	var order []chatKey
+	for _, msg := range messages {
+		key := chatKey{msg.SessionTok, msg.From}
+		if _, ok := chats[key]; !ok {
+			order = append(order, key)
+		}
+		chats[key] = append(chats[key], msg.MessageID)
+	}
+
+	confirmed := 0
+	for _, key := range order {
+		messageIDs := chats[key]
+		phoneNumber := NormalizePhone(key.from)
+		if err := markReadOnServer(key.session, messageIDs, phoneNumber); err != nil {
+			log.Printf("⚠️  [ReadReconciler] markread retry for %s failed: %v", phoneNumber, err)
+			db.Model(&models.AIChatMessage{}).Where("message_id IN ?", messageIDs).
+				UpdateColumn("read_attempts", gorm.Expr("read_attempts + 1"))
+			continue
+		}
+		db.Model(&models.AIChatMessage{}).Where("message_id IN ?", messageIDs).
+			UpdateColumn("read_confirmed", true)
+		confirmed += len(messageIDs)
+	}
+
+	if confirmed > 0 {
+		log.Printf("📖 [ReadReconciler] Confirmed %d read receipts on the WA Server", confirmed)
+	}
+	return confirmed, nil
+}
+
+// RunReadReconciler retries unconfirmed read receipts every AI_READ_RECONCILE_INTERVAL_SECONDS until stop is closed
+func RunReadReconciler(stop <-chan struct{}) {
+	interval := readReconcileInterval()
+	if interval == 0 {
+		log.Println("📖 Read receipt reconciler disabled (AI_READ_RECONCILE_INTERVAL_SECONDS=0)")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := ReconcileReadReceipts(); err != nil {
+			log.Printf("⚠️  [ReadReconciler] %v", err)
+		}
+	}
+}
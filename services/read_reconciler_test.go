@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestReconcileReadReceiptsRetriesUnconfirmed(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	t.Setenv("AI_READ_RECONCILE_MAX_ATTEMPTS", "2")
+	t.Setenv("AI_READ_RECONCILE_MAX_AGE_MINUTES", "60")
+
+	contact := "6281234567890@s.whatsapp.net"
+	newMsg := func(id string, read, confirmed bool, age time.Duration) models.AIChatMessage {
+		return models.AIChatMessage{MessageID: fmt.Sprintf("%s_%s", sessionTok, id), SessionTok: sessionTok,
+			From: contact, To: "bot@s.whatsapp.net", MsgType: "text", Body: id,
+			IsRead: read, ReadConfirmed: confirmed, Timestamp: time.Now().Add(-age)}
+	}
+	messages := []models.AIChatMessage{
+		newMsg("failed_1", true, false, time.Minute), // markread failed -> retried
+		newMsg("failed_2", true, false, time.Minute), // same chat, same call
+		newMsg("confirmed", true, true, time.Minute), // already confirmed
+		newMsg("unread", false, false, time.Minute),  // not read yet - not the reconciler's business
+		newMsg("old", true, false, 3*time.Hour),      // too old to bother
+	}
+	if err := db.Create(&messages).Error; err != nil {
+		t.Fatalf("failed to create messages: %v", err)
+	}
+
+	previous := markReadOnServer
+	t.Cleanup(func() { markReadOnServer = previous })
+	var calls [][]string
+	serverUp := false
+	markReadOnServer = func(sessionToken string, messageIDs []string, chatPhone string) error {
+		if sessionToken != sessionTok {
+			return nil // rows from other tests in a shared DB
+		}
+		if chatPhone != "6281234567890" {
+			t.Errorf("chat phone = %q, want the bare number", chatPhone)
+		}
+		calls = append(calls, messageIDs)
+		if !serverUp {
+			return fmt.Errorf("WA Server returned status 502")
+		}
+		return nil
+	}
+
+	// WA Server still down: one call for the chat, attempts counted, nothing confirmed
+	ReconcileReadReceipts()
+	if len(calls) != 1 || len(calls[0]) != 2 {
+		t.Fatalf("markread calls = %v, want one call with the 2 unconfirmed messages", calls)
+	}
+	var failed models.AIChatMessage
+	db.Where("message_id = ?", sessionTok+"_failed_1").First(&failed)
+	if failed.ReadConfirmed || failed.ReadAttempts != 1 {
+		t.Errorf("after failed retry: confirmed=%v attempts=%d, want false/1", failed.ReadConfirmed, failed.ReadAttempts)
+	}
+
+	// Back up: the retry is confirmed and not repeated
+	serverUp = true
+	ReconcileReadReceipts()
+	db.Where("message_id = ?", sessionTok+"_failed_1").First(&failed)
+	if !failed.ReadConfirmed {
+		t.Errorf("retry succeeded but message not confirmed")
+	}
+	ReconcileReadReceipts()
+	if len(calls) != 2 {
+		t.Errorf("markread calls = %d, want 2 (confirmed messages are not retried)", len(calls))
+	}
+}
+
+func TestReconcileReadReceiptsGivesUpAfterMaxAttempts(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	t.Setenv("AI_READ_RECONCILE_MAX_ATTEMPTS", "2")
+
+	msg := models.AIChatMessage{MessageID: sessionTok + "_stuck", SessionTok: sessionTok,
+		From: "6281234567890@s.whatsapp.net", To: "bot@s.whatsapp.net", MsgType: "text",
+		IsRead: true, Timestamp: time.Now()}
+	if err := db.Create(&msg).Error; err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+
+	previous := markReadOnServer
+	t.Cleanup(func() { markReadOnServer = previous })
+	calls := 0
+	markReadOnServer = func(sessionToken string, messageIDs []string, chatPhone string) error {
+		if sessionToken == sessionTok {
+			calls++
+		}
+		return fmt.Errorf("WA Server returned status 500")
+	}
+
+	for i := 0; i < 4; i++ {
+		ReconcileReadReceipts()
+	}
+	if calls != 2 {
+		t.Errorf("markread retried %d times, want 2 (AI_READ_RECONCILE_MAX_ATTEMPTS)", calls)
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// ErrApprovalNotPending - the approval was already sent/rejected or is being sent
+var ErrApprovalNotPending = errors.New("reply is not awaiting approval")
+
+// RequireReplyApproval - AI_REPLY_APPROVAL=true holds every AI reply for a human to approve or reject.
+// Static replies (fallback, after-hours, busy, opt-out confirmation) are still sent directly.
+func RequireReplyApproval() bool {
+	return config.GetEnvBool("AI_REPLY_APPROVAL", false)
+}
+
+// sendApprovedReply delivers an approved reply (a var so tests can stub WhatsApp)
+var sendApprovedReply = deliverApprovedReply
+
+// QueueReplyForApproval stores a generated reply instead of sending it
+func QueueReplyForApproval(approval *models.AIReplyApproval) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	approval.Status = models.ReplyApprovalPending
+	if err := db.Create(approval).Error; err != nil {
+		return fmt.Errorf("failed to queue reply for approval: %w", err)
+	}
+	return nil
+}
+
+// ListReplyApprovals returns approvals oldest first (review order), optionally filtered by session and status
+func ListReplyApprovals(sessionTok, status string, limit int) ([]models.AIReplyApproval, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	query := db.Model(&models.AIReplyApproval{})
+	if sessionTok != "" {
+		query = query.Where("session_tok = ?", sessionTok)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var approvals []models.AIReplyApproval
+	if err := query.Order("id ASC").Limit(limit).Find(&approvals).Error; err != nil {
+		return nil, fmt.Errorf("failed to list reply approvals: %w", err)
+	}
+	return approvals, nil
+}
+
+// ApproveReply sends a held reply, optionally with the reviewer's edited text.
+// Pending and failed approvals can be approved; the status change is atomic so a double
+// click never sends twice.
+func ApproveReply(id uint, editedReply string) (*models.AIReplyApproval, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	now := time.Now()
+	result := db.Model(&models.AIReplyApproval{}).
+		Where("id = ? AND status IN ?", id, []string{models.ReplyApprovalPending, models.ReplyApprovalFailed}).
+		Updates(map[string]interface{}{"status": models.ReplyApprovalSending, "reviewed_at": now, "updated_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to approve reply: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrApprovalNotPending
+	}
+
+	var approval models.AIReplyApproval
+	if err := db.First(&approval, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load reply approval: %w", err)
+	}
+	if strings.TrimSpace(editedReply) != "" {
+		approval.Reply = editedReply
+	}
+
+	sentID, err := sendApprovedReply(&approval)
+	updates := map[string]interface{}{"reply": approval.Reply, "updated_at": time.Now()}
+	if err != nil {
+		updates["status"] = models.ReplyApprovalFailed
+		updates["reason"] = err.Error()
+	} else {
+		updates["status"] = models.ReplyApprovalSent
+		updates["sent_message_id"] = sentID
+		updates["reason"] = ""
+	}
+	db.Model(&approval).Updates(updates)
+	if err := db.First(&approval, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load reply approval: %w", err)
+	}
+	if approval.Status == models.ReplyApprovalFailed {
+		return &approval, fmt.Errorf("failed to send approved reply: %s", approval.Reason)
+	}
+
+	log.Printf("✅ Approved reply #%d sent to %s", approval.ID, approval.ContactJID)
+	return &approval, nil
+}
+
+// RejectReply discards a held reply. With regenerate the original job is queued again,
+// so the bot drafts a new reply (which again waits for approval).
+func RejectReply(id uint, reason string, regenerate bool) (*models.AIReplyApproval, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	now := time.Now()
+	result := db.Model(&models.AIReplyApproval{}).
+		Where("id = ? AND status IN ?", id, []string{models.ReplyApprovalPending, models.ReplyApprovalFailed}).
+		Updates(map[string]interface{}{"status": models.ReplyApprovalRejected, "reason": reason, "reviewed_at": now, "updated_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to reject reply: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrApprovalNotPending
+	}
+
+	var approval models.AIReplyApproval
+	if err := db.First(&approval, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load reply approval: %w", err)
+	}
+
+	if regenerate && approval.JobID != 0 {
+		if err := db.Model(&models.AIJob{}).Where("id = ?", approval.JobID).Updates(map[string]interface{}{
+			"status":      "pending",
+			"next_run_at": nil,
+			"error_msg":   "",
+			"updated_at":  now,
+		}).Error; err != nil {
+			return &approval, fmt.Errorf("reply rejected but job not requeued: %w", err)
+		}
+		log.Printf("🔄 Rejected reply #%d - job #%d queued to regenerate", approval.ID, approval.JobID)
+	}
+	return &approval, nil
+}
+
+// approvalImageURLs splits AIReplyApproval.ImageURLs
+func approvalImageURLs(approval *models.AIReplyApproval) []string {
+	var urls []string
+	for _, u := range strings.Split(approval.ImageURLs, "\n") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// deliverApprovedReply sends the reply text, its images and cards (already validated when queued)
+// and records them like a regular AI reply. Returns the text message's WhatsApp ID.
+func deliverApprovedReply(approval *models.AIReplyApproval) (string, error) {
+	var sentID string
+	if strings.TrimSpace(approval.Reply) != "" {
+		id, err := sendAndStoreReply(approval.SessionTok, approval.BotJID, approval.ContactJID, approval.Reply, "approved")
+		if err != nil {
+			return "", err
+		}
+		sentID = id
+	}
+
+	for _, imageURL := range approvalImageURLs(approval) {
+		waMessageID, err := SendWAImage(approval.SessionTok, approval.ContactJID, imageURL, "")
+		if err != nil {
+			log.Printf("⚠️  Approved reply #%d: failed to send image %s: %v", approval.ID, imageURL, err)
+			continue
+		}
+		body := fmt.Sprintf("[Gambar: %s]", imageURL)
+		if waMessageID == "" {
+			waMessageID = fmt.Sprintf("approved_img_%s_%d", approval.SessionTok, time.Now().UnixNano())
+		}
+		if err := SaveOutgoingMessageToAIChat(approval.SessionTok, waMessageID, approval.BotJID, approval.ContactJID, body, time.Now()); err != nil {
+			log.Printf("⚠️  Failed to save sent image to AI chat messages: %v", err)
+		}
+		if err := SaveAIResponseToHistory(approval.SessionTok, approval.ContactJID, body); err != nil {
+			log.Printf("⚠️  Failed to save sent image to permanent chat history: %v", err)
+		}
+	}
+
+	_, cards := ExtractReplyCards(approval.Cards, true, true)
+	for _, card := range cards {
+		waMessageID, err := SendWACard(approval.SessionTok, approval.ContactJID, card)
+		if err != nil {
+			log.Printf("⚠️  Approved reply #%d: failed to send %s card: %v", approval.ID, card.Kind, err)
+			continue
+		}
+		body := card.HistoryBody()
+		if waMessageID == "" {
+			waMessageID = fmt.Sprintf("approved_card_%s_%d", approval.SessionTok, time.Now().UnixNano())
+		}
+		if err := SaveOutgoingMessageToAIChat(approval.SessionTok, waMessageID, approval.BotJID, approval.ContactJID, body, time.Now()); err != nil {
+			log.Printf("⚠️  Failed to save sent card to AI chat messages: %v", err)
+		}
+		if err := SaveAIResponseToHistory(approval.SessionTok, approval.ContactJID, body); err != nil {
+			log.Printf("⚠️  Failed to save sent card to permanent chat history: %v", err)
+		}
+	}
+	return sentID, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// setupApprovalTest migrates the approval + job tables and stubs WhatsApp delivery
+func setupApprovalTest(t *testing.T) (string, *[]string) {
+	t.Helper()
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.AIReplyApproval{}, &models.AIJob{}); err != nil {
+		t.Fatalf("failed to migrate test tables: %v", err)
+	}
+
+	sent := &[]string{}
+	previous := sendApprovedReply
+	sendApprovedReply = func(approval *models.AIReplyApproval) (string, error) {
+		if approval.Reply == "gateway down" {
+			return "", fmt.Errorf("gateway returned 502")
+		}
+		*sent = append(*sent, approval.Reply)
+		return "WA-" + approval.Reply, nil
+	}
+	t.Cleanup(func() {
+		sendApprovedReply = previous
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIReplyApproval{})
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIJob{})
+	})
+	return sessionTok, sent
+}
+
+func queueTestApproval(t *testing.T, sessionTok, reply string, jobID uint) models.AIReplyApproval {
+	t.Helper()
+	approval := models.AIReplyApproval{JobID: jobID, SessionTok: sessionTok, MessageID: "m1",
+		BotJID: "bot@s.whatsapp.net", ContactJID: "6281200000001@s.whatsapp.net", Reply: reply}
+	if err := QueueReplyForApproval(&approval); err != nil {
+		t.Fatalf("failed to queue approval: %v", err)
+	}
+	return approval
+}
+
+func TestApproveReplySendsOnce(t *testing.T) {
+	sessionTok, sent := setupApprovalTest(t)
+	approval := queueTestApproval(t, sessionTok, "Harga paket A Rp100.000", 0)
+
+	pending, err := ListReplyApprovals(sessionTok, models.ReplyApprovalPending, 10)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending approvals = %d (%v), want 1", len(pending), err)
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("reply sent before approval: %v", *sent)
+	}
+
+	// Reviewer fixes the price before sending
+	result, err := ApproveReply(approval.ID, "Harga paket A Rp150.000")
+	if err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	if result.Status != models.ReplyApprovalSent || result.SentMessageID != "WA-Harga paket A Rp150.000" {
+		t.Errorf("approval = status %s sent id %q", result.Status, result.SentMessageID)
+	}
+	if fmt.Sprint(*sent) != "[Harga paket A Rp150.000]" {
+		t.Errorf("sent = %v, want the edited reply once", *sent)
+	}
+
+	// Double click: nothing is sent twice, rejecting is no longer possible either
+	if _, err := ApproveReply(approval.ID, ""); !errors.Is(err, ErrApprovalNotPending) {
+		t.Errorf("second approve err = %v, want ErrApprovalNotPending", err)
+	}
+	if _, err := RejectReply(approval.ID, "late", false); !errors.Is(err, ErrApprovalNotPending) {
+		t.Errorf("reject after send err = %v, want ErrApprovalNotPending", err)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("reply sent %d times, want 1", len(*sent))
+	}
+}
+
+func TestApproveReplyFailedSendCanBeRetried(t *testing.T) {
+	sessionTok, sent := setupApprovalTest(t)
+	approval := queueTestApproval(t, sessionTok, "gateway down", 0)
+
+	result, err := ApproveReply(approval.ID, "")
+	if err == nil || result == nil || result.Status != models.ReplyApprovalFailed || result.Reason == "" {
+		t.Fatalf("failed send: err=%v approval=%+v, want status failed with reason", err, result)
+	}
+
+	if result, err = ApproveReply(approval.ID, "Halo kak, ada yang bisa dibantu?"); err != nil || result.Status != models.ReplyApprovalSent {
+		t.Fatalf("retry: err=%v status=%v, want sent", err, result)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("sent = %v, want 1 message", *sent)
+	}
+}
+
+func TestRejectReply(t *testing.T) {
+	sessionTok, sent := setupApprovalTest(t)
+	db := database.GetDB()
+
+	job := models.AIJob{Status: "done", SessionTok: sessionTok, MessageID: "m1", UserID: "test-user", Contact: "6281200000001"}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	// Discard: the job stays done
+	discarded := queueTestApproval(t, sessionTok, "jawaban ngawur", job.ID)
+	result, err := RejectReply(discarded.ID, "wrong price", false)
+	if err != nil || result.Status != models.ReplyApprovalRejected || result.Reason != "wrong price" {
+		t.Fatalf("reject: err=%v approval=%+v", err, result)
+	}
+	var reloaded models.AIJob
+	db.First(&reloaded, job.ID)
+	if reloaded.Status != "done" {
+		t.Errorf("discarded reply requeued the job (status %s)", reloaded.Status)
+	}
+
+	// Regenerate: the job goes back to the queue for a new draft
+	regenerated := queueTestApproval(t, sessionTok, "jawaban kurang lengkap", job.ID)
+	if _, err := RejectReply(regenerated.ID, "", true); err != nil {
+		t.Fatalf("reject with regenerate: %v", err)
+	}
+	db.First(&reloaded, job.ID)
+	if reloaded.Status != "pending" || reloaded.NextRunAt != nil {
+		t.Errorf("job after regenerate = status %s next_run_at %v, want pending now", reloaded.Status, reloaded.NextRunAt)
+	}
+
+	if len(*sent) != 0 {
+		t.Errorf("rejected replies were sent: %v", *sent)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DailyReplyCap returns the max AI replies to one contact per day: the bot's
+// maxDailyRepliesPerContact when set (> 0), otherwise AI_MAX_DAILY_REPLIES_PER_CONTACT
+// (default 100; 0 = unlimited)
+func DailyReplyCap(botSettings *BotSettings) int {
+	if botSettings != nil && botSettings.MaxDailyRepliesPerContact != nil && *botSettings.MaxDailyRepliesPerContact > 0 {
+		return *botSettings.MaxDailyRepliesPerContact
+	}
+	limit := config.GetEnvInt("AI_MAX_DAILY_REPLIES_PER_CONTACT", 100)
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// ReserveDailyReply counts one more AI reply to the contact today and reports whether it is
+// within the cap (0 = unlimited, nothing is counted). The increment is a single upsert, so
+// concurrent webhooks for the same contact can't both slip under the cap.
+func ReserveDailyReply(sessionTok, contact string, limit int) (int, bool, error) {
+	if limit <= 0 {
+		return 0, true, nil
+	}
+	db := database.GetDB()
+	if db == nil {
+		return 0, true, fmt.Errorf("database not initialized")
+	}
+
+	counter := models.ContactReplyCounter{
+		SessionTok: sessionTok,
+		Contact:    optOutContactKey(contact),
+		Day:        time.Now().Format("2006-01-02"),
+		Count:      1,
+		UpdatedAt:  time.Now(),
+	}
+	err := db.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "session_tok"}, {Name: "contact"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":      gorm.Expr("contact_reply_counters.count + 1"),
+				"updated_at": time.Now(),
+			}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "count"}}},
+	).Create(&counter).Error
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to count daily reply: %w", err)
+	}
+
+	if counter.Count == limit+1 {
+		// Logged once per contact per day, on the first reply over the cap
+		log.Printf("🛑 Daily reply cap of %d reached for %s (session %s) - no more AI replies today",
+			limit, counter.Contact, sessionTok)
+	}
+	return counter.Count, counter.Count <= limit, nil
+}
+
+// IsEchoOfLastReply reports whether an incoming message repeats the last message we sent to the
+// contact within the dedupe window - the other side is an auto-responder echoing us, and answering
+// would start a bot-to-bot loop. AI_LOOP_DETECTION=false disables the check.
+func IsEchoOfLastReply(sessionTok, contactJID, body string) bool {
+	if !config.GetEnvBool("AI_LOOP_DETECTION", true) || normalizeReply(body, true) == "" {
+		return false
+	}
+
+	last, err := getLastOutgoingMessageSince(sessionTok, contactJID, time.Now().Add(-dedupeWindow()))
+	if err != nil {
+		log.Printf("⚠️  Failed to load last outgoing message for loop detection: %v", err)
+		return false
+	}
+	if last == nil {
+		return false
+	}
+	return normalizeReply(last.Body, true) == normalizeReply(body, true)
+}
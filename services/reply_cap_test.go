@@ -0,0 +1,112 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func TestDailyReplyCap(t *testing.T) {
+	t.Setenv("AI_MAX_DAILY_REPLIES_PER_CONTACT", "")
+	if got := DailyReplyCap(nil); got != 100 {
+		t.Errorf("default cap = %d, want 100", got)
+	}
+
+	t.Setenv("AI_MAX_DAILY_REPLIES_PER_CONTACT", "0")
+	if got := DailyReplyCap(&BotSettings{}); got != 0 {
+		t.Errorf("AI_MAX_DAILY_REPLIES_PER_CONTACT=0 cap = %d, want 0 (unlimited)", got)
+	}
+
+	t.Setenv("AI_MAX_DAILY_REPLIES_PER_CONTACT", "40")
+	perBot, unset := 5, 0
+	if got := DailyReplyCap(&BotSettings{MaxDailyRepliesPerContact: &perBot}); got != 5 {
+		t.Errorf("per-bot cap = %d, want 5", got)
+	}
+	if got := DailyReplyCap(&BotSettings{MaxDailyRepliesPerContact: &unset}); got != 40 {
+		t.Errorf("per-bot 0 cap = %d, want global 40", got)
+	}
+}
+
+func setupReplyCapTestDB(t *testing.T) string {
+	t.Helper()
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.ContactReplyCounter{}); err != nil {
+		t.Fatalf("failed to migrate contact_reply_counters: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.ContactReplyCounter{}) })
+	return sessionTok
+}
+
+func TestReserveDailyReplyStopsAtCap(t *testing.T) {
+	sessionTok := setupReplyCapTestDB(t)
+	contact := "6281200000001@s.whatsapp.net"
+
+	for i := 1; i <= 3; i++ {
+		count, allowed, err := ReserveDailyReply(sessionTok, contact, 3)
+		if err != nil || !allowed || count != i {
+			t.Fatalf("reply %d: count=%d allowed=%v err=%v", i, count, allowed, err)
+		}
+	}
+	if count, allowed, err := ReserveDailyReply(sessionTok, contact, 3); err != nil || allowed || count != 4 {
+		t.Errorf("reply over cap: count=%d allowed=%v err=%v, want blocked", count, allowed, err)
+	}
+
+	// Other contacts keep their own counter
+	if _, allowed, err := ReserveDailyReply(sessionTok, "6281200000002", 3); err != nil || !allowed {
+		t.Errorf("other contact blocked: allowed=%v err=%v", allowed, err)
+	}
+	// No cap = nothing counted
+	if _, allowed, _ := ReserveDailyReply(sessionTok, contact, 0); !allowed {
+		t.Error("cap 0 should be unlimited")
+	}
+}
+
+func TestReserveDailyReplyConcurrent(t *testing.T) {
+	sessionTok := setupReplyCapTestDB(t)
+
+	var mu sync.Mutex
+	allowedCount := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, allowed, err := ReserveDailyReply(sessionTok, "6281200000003", 4); err == nil && allowed {
+				mu.Lock()
+				allowedCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowedCount != 4 {
+		t.Errorf("%d concurrent replies allowed, want 4", allowedCount)
+	}
+}
+
+func TestIsEchoOfLastReply(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	contact := "6281200000004@s.whatsapp.net"
+	if err := SaveOutgoingMessageToAIChat(sessionTok, sessionTok+"_out", "bot@s.whatsapp.net", contact,
+		"Terima kasih telah menghubungi kami!", time.Now()); err != nil {
+		t.Fatalf("failed to seed outgoing message: %v", err)
+	}
+
+	t.Setenv("AI_LOOP_DETECTION", "true")
+	t.Setenv("AI_DEDUPE_WINDOW_SECONDS", "")
+	if !IsEchoOfLastReply(sessionTok, contact, "  terima kasih telah menghubungi KAMI! ") {
+		t.Error("echo of our last reply should be detected")
+	}
+	if IsEchoOfLastReply(sessionTok, contact, "Terima kasih, saya mau order") {
+		t.Error("a different message is not an echo")
+	}
+
+	t.Setenv("AI_LOOP_DETECTION", "false")
+	if IsEchoOfLastReply(sessionTok, contact, "Terima kasih telah menghubungi kami!") {
+		t.Error("AI_LOOP_DETECTION=false should disable the check")
+	}
+}
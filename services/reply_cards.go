@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of ReplyCard
+const (
+	ReplyCardLocation = "location"
+	ReplyCardContact  = "contact"
+)
+
+// Sentinels the LLM may put in its reply: [SEND_LOCATION:lat,lng,name] and [SEND_CONTACT:name,phone]
+var (
+	sendLocationPattern = regexp.MustCompile(`\[SEND_LOCATION:\s*([^\]]*?)\s*\]`)
+	sendContactPattern  = regexp.MustCompile(`\[SEND_CONTACT:\s*([^\]]*?)\s*\]`)
+)
+
+// locationSendInstructions is appended to the system prompt of bots with allowLocationSend
+const locationSendInstructions = `
+
+=== KIRIM LOKASI ===
+Jika customer menanyakan alamat / lokasi, selain menjawab dengan teks tulis
+[SEND_LOCATION:latitude,longitude,nama tempat] di baris terpisah, dengan koordinat PERSIS seperti di knowledge base.
+JANGAN mengarang koordinat - tanpa koordinat di knowledge base, jawab dengan teks saja.
+`
+
+// contactSendInstructions is appended to the system prompt of bots with allowContactSend
+const contactSendInstructions = `
+
+=== KIRIM KONTAK ===
+Jika customer meminta nomor yang bisa dihubungi, tulis [SEND_CONTACT:nama,nomor] di baris terpisah,
+dengan nomor dari knowledge base dalam format internasional (contoh: 6281234567890).
+JANGAN mengarang nomor - tanpa nomor di knowledge base, jawab dengan teks saja.
+`
+
+// minGroundedPhoneDigits - a contact number counts as taken from the knowledge base when its last
+// digits appear there (so "0812-3456-7890" in a document grounds 6281234567890)
+const minGroundedPhoneDigits = 9
+
+// ReplyCard is a location or contact card the bot shares after its text reply
+type ReplyCard struct {
+	Kind      string  `json:"kind"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	Name      string  `json:"name"`
+	Phone     string  `json:"phone,omitempty"` // contact: digits with country code
+}
+
+// formatCoordinate prints a coordinate without trailing zeros (always a prefix of how it was written)
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Sentinel returns the card as the sentinel the LLM writes (stored with approvals)
+func (c ReplyCard) Sentinel() string {
+	if c.Kind == ReplyCardLocation {
+		return fmt.Sprintf("[SEND_LOCATION:%s,%s,%s]", formatCoordinate(c.Latitude), formatCoordinate(c.Longitude), c.Name)
+	}
+	return fmt.Sprintf("[SEND_CONTACT:%s,%s]", c.Name, c.Phone)
+}
+
+// HistoryBody is how the card appears in chat history, so the LLM knows it was already sent
+func (c ReplyCard) HistoryBody() string {
+	if c.Kind == ReplyCardLocation {
+		return strings.TrimSpace(fmt.Sprintf("[Lokasi: %s (%s, %s)]", c.Name, formatCoordinate(c.Latitude), formatCoordinate(c.Longitude)))
+	}
+	return fmt.Sprintf("[Kontak: %s, %s]", c.Name, c.Phone)
+}
+
+// ParseLocationCard parses "lat,lng[,name]" (the name may contain commas)
+func ParseLocationCard(args string) (ReplyCard, error) {
+	parts := strings.SplitN(args, ",", 3)
+	if len(parts) < 2 {
+		return ReplyCard{}, fmt.Errorf("location needs latitude,longitude: %q", args)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return ReplyCard{}, fmt.Errorf("invalid latitude %q", strings.TrimSpace(parts[0]))
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return ReplyCard{}, fmt.Errorf("invalid longitude %q", strings.TrimSpace(parts[1]))
+	}
+	card := ReplyCard{Kind: ReplyCardLocation, Latitude: lat, Longitude: lng}
+	if len(parts) == 3 {
+		card.Name = strings.TrimSpace(parts[2])
+	}
+	return card, nil
+}
+
+// ParseContactCard parses "name,phone". The phone must be a person's number with a country code.
+func ParseContactCard(args string) (ReplyCard, error) {
+	i := strings.LastIndex(args, ",")
+	if i < 0 {
+		return ReplyCard{}, fmt.Errorf("contact needs name,phone: %q", args)
+	}
+	name := strings.TrimSpace(args[:i])
+	if name == "" {
+		return ReplyCard{}, fmt.Errorf("contact needs a name: %q", args)
+	}
+	phone, err := ValidatePhone(args[i+1:])
+	if err != nil {
+		return ReplyCard{}, err
+	}
+	if strings.Contains(phone, "@") {
+		return ReplyCard{}, fmt.Errorf("%w: contact card needs a phone number, got %q", ErrInvalidPhone, phone)
+	}
+	return ReplyCard{Kind: ReplyCardContact, Name: name, Phone: phone}, nil
+}
+
+// ExtractReplyCards removes the [SEND_LOCATION] / [SEND_CONTACT] sentinels of the allowed kinds
+// from response and returns the remaining text plus the parsed cards (duplicates once).
+// Malformed sentinels are dropped with a log line; sentinels of kinds not allowed stay as text.
+func ExtractReplyCards(response string, allowLocation, allowContact bool) (string, []ReplyCard) {
+	var cards []ReplyCard
+	seen := make(map[string]bool)
+	collect := func(pattern *regexp.Regexp, parse func(string) (ReplyCard, error)) {
+		for _, m := range pattern.FindAllStringSubmatch(response, -1) {
+			card, err := parse(m[1])
+			if err != nil {
+				log.Printf("🚫 Dropped %s: %v", m[0], err)
+				continue
+			}
+			if key := card.Sentinel(); !seen[key] {
+				seen[key] = true
+				cards = append(cards, card)
+			}
+		}
+		response = removeSentinels(response, pattern)
+	}
+
+	if allowLocation && sendLocationPattern.MatchString(response) {
+		collect(sendLocationPattern, ParseLocationCard)
+	}
+	if allowContact && sendContactPattern.MatchString(response) {
+		collect(sendContactPattern, ParseContactCard)
+	}
+	return response, cards
+}
+
+// botKnowledge returns the texts a card must be grounded in: system prompt and documents
+func botKnowledge(botSettings *BotSettings) []string {
+	texts := []string{botSettings.SystemPrompt}
+	for _, doc := range botSettings.Documents {
+		texts = append(texts, doc.Content)
+	}
+	return texts
+}
+
+// ValidateReplyCard checks that the card's coordinates / number appear in the bot's knowledge
+// base or system prompt - the LLM may not invent an address or a phone number
+func ValidateReplyCard(card ReplyCard, botSettings *BotSettings) error {
+	if botSettings == nil {
+		return fmt.Errorf("no bot settings to check the %s card against", card.Kind)
+	}
+
+	if card.Kind == ReplyCardLocation {
+		lat, lng := formatCoordinate(card.Latitude), formatCoordinate(card.Longitude)
+		for _, text := range botKnowledge(botSettings) {
+			if strings.Contains(text, lat) && strings.Contains(text, lng) {
+				return nil
+			}
+		}
+		return fmt.Errorf("coordinates %s,%s not found in knowledge base", lat, lng)
+	}
+
+	suffix := card.Phone
+	if len(suffix) > minGroundedPhoneDigits {
+		suffix = suffix[len(suffix)-minGroundedPhoneDigits:]
+	}
+	for _, text := range botKnowledge(botSettings) {
+		compact := strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(text)
+		if strings.Contains(compact, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("phone %s not found in knowledge base", card.Phone)
+}
+
+// SendWACard sends a location or contact card via the internal gateway (/wa/chat/send/location
+// or /wa/chat/send/contact) and returns the WhatsApp message ID ("" if not reported)
+func SendWACard(sessionToken, to string, card ReplyCard) (string, error) {
+	path := "chat/send/" + card.Kind
+	payload := map[string]interface{}{"to": NormalizePhone(to)}
+	if card.Kind == ReplyCardLocation {
+		payload["latitude"] = card.Latitude
+		payload["longitude"] = card.Longitude
+		payload["name"] = card.Name
+	} else {
+		payload["contactName"] = card.Name
+		payload["contactPhone"] = card.Phone
+	}
+
+	if DryRun() {
+		return RecordDryRunSend(sessionToken, to, path, card.Sentinel()), nil
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequest("POST", "http://localhost:8070/wa/"+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("token", sessionToken)
+
+	resp, err := DoHTTP(req, 10*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to send WA %s: %w", card.Kind, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	result := ParseWASendResponse(resp.StatusCode, body)
+	if !result.OK {
+		return "", fmt.Errorf("gateway %s send failed: %s", card.Kind, result.Error)
+	}
+	return result.MessageID, nil
+}
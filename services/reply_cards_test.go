@@ -0,0 +1,114 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLocationCard(t *testing.T) {
+	card, err := ParseLocationCard("-6.2088, 106.8456, Kantor Pusat, Lt. 3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if card.Latitude != -6.2088 || card.Longitude != 106.8456 || card.Name != "Kantor Pusat, Lt. 3" {
+		t.Errorf("card = %+v", card)
+	}
+
+	for _, args := range []string{"-6.2088", "abc,106.8", "91,106.8", "-6.2,181", ""} {
+		if _, err := ParseLocationCard(args); err == nil {
+			t.Errorf("ParseLocationCard(%q) should fail", args)
+		}
+	}
+}
+
+func TestParseContactCard(t *testing.T) {
+	card, err := ParseContactCard("CS Toko, Cabang Bandung, +62 812-3456-7890")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if card.Name != "CS Toko, Cabang Bandung" || card.Phone != "6281234567890" {
+		t.Errorf("card = %+v", card)
+	}
+
+	for _, args := range []string{"6281234567890", ",6281234567890", "CS,081234567890", "CS,0812abc", "CS,120363025246125486@g.us"} {
+		if _, err := ParseContactCard(args); err == nil {
+			t.Errorf("ParseContactCard(%q) should fail", args)
+		}
+	}
+}
+
+func TestExtractReplyCards(t *testing.T) {
+	response := "Alamat kami di Jl. Sudirman.\n\n[SEND_LOCATION:-6.2088,106.8456,Kantor Pusat]\n\n[SEND_CONTACT:CS,6281234567890]\n[SEND_LOCATION:-6.2088,106.8456,Kantor Pusat]\n[SEND_LOCATION:999,1,Salah]"
+
+	text, cards := ExtractReplyCards(response, true, true)
+	if text != "Alamat kami di Jl. Sudirman." {
+		t.Errorf("text = %q", text)
+	}
+	if len(cards) != 2 || cards[0].Kind != ReplyCardLocation || cards[1].Kind != ReplyCardContact {
+		t.Fatalf("cards = %+v, want one location and one contact", cards)
+	}
+
+	// Kinds not allowed for the bot stay as text
+	text, cards = ExtractReplyCards(response, false, true)
+	if len(cards) != 1 || !strings.Contains(text, "[SEND_LOCATION:") || strings.Contains(text, "[SEND_CONTACT:") {
+		t.Errorf("text = %q, cards = %+v", text, cards)
+	}
+
+	plain := "Terima kasih, pesanan Anda sedang diproses."
+	if text, cards := ExtractReplyCards(plain, true, true); text != plain || len(cards) != 0 {
+		t.Errorf("plain text changed: %q, %+v", text, cards)
+	}
+}
+
+func TestReplyCardSentinelRoundTrip(t *testing.T) {
+	cards := []ReplyCard{
+		{Kind: ReplyCardLocation, Latitude: -6.2, Longitude: 106.8456, Name: "Gudang, Blok B"},
+		{Kind: ReplyCardContact, Name: "Admin", Phone: "6281234567890"},
+	}
+	var sentinels []string
+	for _, card := range cards {
+		sentinels = append(sentinels, card.Sentinel())
+	}
+
+	_, parsed := ExtractReplyCards(strings.Join(sentinels, "\n"), true, true)
+	if len(parsed) != len(cards) {
+		t.Fatalf("parsed = %+v", parsed)
+	}
+	for i := range cards {
+		if parsed[i] != cards[i] {
+			t.Errorf("card %d = %+v, want %+v", i, parsed[i], cards[i])
+		}
+	}
+}
+
+func TestValidateReplyCard(t *testing.T) {
+	settings := &BotSettings{
+		SystemPrompt: "Kamu adalah CS Toko Maju.",
+		Documents: []Document{
+			{Title: "Alamat", Content: "Kantor pusat: Jl. Sudirman 1 (-6.2088, 106.8456). WA admin: 0812-3456-7890"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		card ReplyCard
+		ok   bool
+	}{
+		{"known location", ReplyCard{Kind: ReplyCardLocation, Latitude: -6.2088, Longitude: 106.8456}, true},
+		{"invented location", ReplyCard{Kind: ReplyCardLocation, Latitude: -6.9175, Longitude: 107.6191}, false},
+		{"known phone", ReplyCard{Kind: ReplyCardContact, Name: "Admin", Phone: "6281234567890"}, true},
+		{"invented phone", ReplyCard{Kind: ReplyCardContact, Name: "Admin", Phone: "6289999999999"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReplyCard(tt.card, settings)
+			if (err == nil) != tt.ok {
+				t.Errorf("ValidateReplyCard() err = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+
+	if err := ValidateReplyCard(tests[0].card, nil); err == nil {
+		t.Error("expected an error without bot settings")
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// GetLastOutgoingMessage returns the most recent message we sent to a contact (nil if none)
+func GetLastOutgoingMessage(sessionTok, contactJID string) (*models.AIChatMessage, error) {
+	return getLastOutgoingMessageSince(sessionTok, contactJID, time.Time{})
+}
+
+// getLastOutgoingMessageSince is GetLastOutgoingMessage restricted to messages sent at or after since
+func getLastOutgoingMessageSince(sessionTok, contactJID string, since time.Time) (*models.AIChatMessage, error) {
+	var msg models.AIChatMessage
+	query := database.GetDB().
+		Where("session_tok = ? AND \"to\" = ? AND from_me = ?", sessionTok, contactJID, true)
+	if !since.IsZero() {
+		query = query.Where("timestamp >= ?", since)
+	}
+	result := query.
+		Order("timestamp DESC").
+		Limit(1).
+		Find(&msg)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &msg, nil
+}
+
+// dedupeWindow is how far back IsDuplicateReply looks (AI_DEDUPE_WINDOW_SECONDS, default 600)
+func dedupeWindow() time.Duration {
+	seconds := config.GetEnvInt("AI_DEDUPE_WINDOW_SECONDS", 600)
+	if seconds <= 0 {
+		seconds = 600
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// IsDuplicateReply reports whether reply equals the last message sent to the contact within
+// the dedupe window, so legitimately repeated answers hours apart are still sent.
+// Enabled by AI_DEDUPE_REPLIES (default true); AI_DEDUPE_CASE_INSENSITIVE also ignores case.
+func IsDuplicateReply(sessionTok, contactJID, reply string) bool {
+	if !config.GetEnvBool("AI_DEDUPE_REPLIES", true) {
+		return false
+	}
+
+	last, err := getLastOutgoingMessageSince(sessionTok, contactJID, time.Now().Add(-dedupeWindow()))
+	if err != nil {
+		log.Printf("⚠️  Failed to load last outgoing message for dedupe: %v", err)
+		return false
+	}
+	if last == nil {
+		return false
+	}
+
+	caseInsensitive := config.GetEnvBool("AI_DEDUPE_CASE_INSENSITIVE", false)
+	return normalizeReply(last.Body, caseInsensitive) == normalizeReply(reply, caseInsensitive)
+}
+
+// normalizeReply trims and collapses whitespace (optionally lowercases) for comparison
+func normalizeReply(text string, caseInsensitive bool) string {
+	normalized := strings.Join(strings.Fields(text), " ")
+	if caseInsensitive {
+		normalized = strings.ToLower(normalized)
+	}
+	return normalized
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestNormalizeReply(t *testing.T) {
+	tests := []struct {
+		name            string
+		text            string
+		caseInsensitive bool
+		want            string
+	}{
+		{"plain", "Halo kak", false, "Halo kak"},
+		{"trims edges", "  Halo kak \n", false, "Halo kak"},
+		{"collapses inner whitespace", "Halo\n\n  kak\tada yang\nbisa dibantu?", false, "Halo kak ada yang bisa dibantu?"},
+		{"keeps case by default", "HALO Kak", false, "HALO Kak"},
+		{"lowercases when enabled", "HALO Kak", true, "halo kak"},
+		{"empty", "   ", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeReply(tt.text, tt.caseInsensitive); got != tt.want {
+				t.Errorf("normalizeReply(%q, %v) = %q, want %q", tt.text, tt.caseInsensitive, got, tt.want)
+			}
+		})
+	}
+}
+
+// setupTestDB points database.DB at TEST_DATABASE_DSN (skips the test when unset)
+// and returns a unique session token whose ai_chat_messages are removed after the test
+func setupTestDB(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set - skipping database test")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AIChatMessage{}); err != nil {
+		t.Fatalf("failed to migrate ai_chat_messages: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	sessionTok := fmt.Sprintf("test_session_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIChatMessage{})
+		database.DB = previous
+	})
+	return sessionTok
+}
+
+func TestIsDuplicateReply(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	contact := "6281234567890@s.whatsapp.net"
+
+	if err := SaveOutgoingMessageToAIChat(sessionTok, "wa_out_1", "bot@s.whatsapp.net", contact,
+		"Halo kak, ada yang bisa dibantu?", time.Now()); err != nil {
+		t.Fatalf("failed to seed outgoing message: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		reply           string
+		caseInsensitive string
+		want            bool
+	}{
+		{"identical", "Halo kak, ada yang bisa dibantu?", "false", true},
+		{"whitespace differs", "  Halo kak,\nada yang   bisa dibantu? ", "false", true},
+		{"case differs, flag off", "HALO KAK, ADA YANG BISA DIBANTU?", "false", false},
+		{"case differs, flag on", "HALO KAK, ADA YANG BISA DIBANTU?", "true", true},
+		{"different body", "Baik kak, pesanan sudah kami proses.", "false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_DEDUPE_REPLIES", "true")
+			t.Setenv("AI_DEDUPE_CASE_INSENSITIVE", tt.caseInsensitive)
+			t.Setenv("AI_DEDUPE_WINDOW_SECONDS", "")
+			if got := IsDuplicateReply(sessionTok, contact, tt.reply); got != tt.want {
+				t.Errorf("IsDuplicateReply(%q) = %v, want %v", tt.reply, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("AI_DEDUPE_REPLIES", "false")
+		if IsDuplicateReply(sessionTok, contact, "Halo kak, ada yang bisa dibantu?") {
+			t.Errorf("expected no duplicate when AI_DEDUPE_REPLIES=false")
+		}
+	})
+}
+
+func TestIsDuplicateReplyOutsideWindow(t *testing.T) {
+	sessionTok := setupTestDB(t)
+	contact := "6281234567891@s.whatsapp.net"
+
+	if err := SaveOutgoingMessageToAIChat(sessionTok, "wa_out_old", "bot@s.whatsapp.net", contact,
+		"Terima kasih kak!", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatalf("failed to seed outgoing message: %v", err)
+	}
+
+	t.Setenv("AI_DEDUPE_REPLIES", "true")
+	t.Setenv("AI_DEDUPE_WINDOW_SECONDS", "600")
+	if IsDuplicateReply(sessionTok, contact, "Terima kasih kak!") {
+		t.Errorf("reply older than the dedupe window must not be treated as duplicate")
+	}
+
+	t.Setenv("AI_DEDUPE_WINDOW_SECONDS", "10800")
+	if !IsDuplicateReply(sessionTok, contact, "Terima kasih kak!") {
+		t.Errorf("reply inside a 3h window should be treated as duplicate")
+	}
+}
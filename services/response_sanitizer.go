@@ -0,0 +1,151 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"genfity-wa-support/config"
+)
+
+// SanitizerRule removes unwanted text from LLM replies. Pattern is a regular expression whose
+// matches are removed; Contains (case-insensitive) drops every line containing it.
+type SanitizerRule struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern,omitempty"`
+	Contains string `json:"contains,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// defaultSanitizerRules only touch text no customer should ever see: a disclaimer sentence at the
+// very start, a role label the model wrote itself and echoed prompt section markers
+var defaultSanitizerRules = []SanitizerRule{
+	{Name: "role_prefix", Pattern: `(?i)^\s*(?:assistant|asisten|bot|ai)\s*:\s*`},
+	{Name: "ai_disclaimer_en", Pattern: `(?i)^\s*as an ai(?: language model| assistant| model)?\b[^.!?\n]*[.!?]\s*`},
+	{Name: "ai_disclaimer_id", Pattern: `(?i)^\s*sebagai (?:sebuah )?(?:ai|asisten ai|asisten virtual|model bahasa(?: ai)?)\b[^.!?\n]*[.!?]\s*`},
+	{Name: "prompt_echo_reminder", Contains: "=== REMINDER SEBELUM MENJAWAB ==="},
+	{Name: "prompt_echo_kb_header", Contains: "=== Knowledge Base - WAJIB DIGUNAKAN ==="},
+	{Name: "prompt_echo_kb_footer", Contains: "--- End of Knowledge Base ---"},
+	{Name: "prompt_echo_customer_delimiter", Pattern: `[ \t]*<<<(?:AKHIR_)?PESAN_CUSTOMER>>>[ \t]*\n?`},
+}
+
+var (
+	sanitizerRulesOnce sync.Once
+	sanitizerRules     []SanitizerRule
+)
+
+// compileSanitizerRules checks the rules and compiles their patterns; a rule with neither
+// pattern nor contains, or with an invalid pattern, is an error
+func compileSanitizerRules(rules []SanitizerRule) ([]SanitizerRule, error) {
+	compiled := make([]SanitizerRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule_%d", i+1)
+		}
+		switch {
+		case rule.Pattern != "" && rule.Contains != "":
+			return nil, fmt.Errorf("rule %s: set either pattern or contains, not both", rule.Name)
+		case rule.Pattern != "":
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid pattern: %w", rule.Name, err)
+			}
+			rule.re = re
+		case strings.TrimSpace(rule.Contains) == "":
+			return nil, fmt.Errorf("rule %s: pattern or contains is required", rule.Name)
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// loadSanitizerRules returns the rules of AI_SANITIZER_RULES_FILE (a JSON array of rules that
+// replaces the defaults), falling back to the defaults when it is unset or unusable
+func loadSanitizerRules() []SanitizerRule {
+	defaults, _ := compileSanitizerRules(defaultSanitizerRules)
+
+	path := config.GetEnvString("AI_SANITIZER_RULES_FILE", "")
+	if path == "" {
+		return defaults
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("⚠️  Warning: Failed to read AI_SANITIZER_RULES_FILE %s: %v - using default rules", path, err)
+		return defaults
+	}
+	var rules []SanitizerRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		log.Printf("⚠️  Warning: Invalid AI_SANITIZER_RULES_FILE %s: %v - using default rules", path, err)
+		return defaults
+	}
+	compiled, err := compileSanitizerRules(rules)
+	if err != nil {
+		log.Printf("⚠️  Warning: Invalid AI_SANITIZER_RULES_FILE %s: %v - using default rules", path, err)
+		return defaults
+	}
+	log.Printf("🧹 Loaded %d response sanitizer rules from %s", len(compiled), path)
+	return compiled
+}
+
+// SanitizeResponse strips disclaimers and echoed prompt text from an LLM reply
+// (AI_SANITIZE_REPLIES, default true). Every removal is logged.
+func SanitizeResponse(text string) string {
+	if !config.GetEnvBool("AI_SANITIZE_REPLIES", true) {
+		return text
+	}
+	sanitizerRulesOnce.Do(func() {
+		sanitizerRules = loadSanitizerRules()
+	})
+	return sanitizeWith(sanitizerRules, text)
+}
+
+// sanitizeWith applies rules in order. A reply the rules would empty completely is sent
+// unchanged - a disclaimer is better than no answer.
+func sanitizeWith(rules []SanitizerRule, text string) string {
+	sanitized := text
+	for _, rule := range rules {
+		var removed []string
+		if rule.re != nil {
+			removed = rule.re.FindAllString(sanitized, -1)
+			if len(removed) > 0 {
+				sanitized = rule.re.ReplaceAllString(sanitized, "")
+			}
+		} else {
+			sanitized, removed = dropLinesContaining(sanitized, rule.Contains)
+		}
+		for _, r := range removed {
+			log.Printf("🧹 Sanitizer rule %s stripped: %q", rule.Name, PreviewText(strings.TrimSpace(r), 120))
+		}
+	}
+
+	sanitized = strings.TrimSpace(sanitized)
+	if sanitized == "" && strings.TrimSpace(text) != "" {
+		log.Printf("⚠️  Sanitizer would remove the whole reply - sending it unchanged")
+		return text
+	}
+	return sanitized
+}
+
+// dropLinesContaining removes the lines containing substr (case-insensitive)
+func dropLinesContaining(text, substr string) (string, []string) {
+	needle := strings.ToLower(substr)
+	if !strings.Contains(strings.ToLower(text), needle) {
+		return text, nil
+	}
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	var removed []string
+	for _, line := range lines {
+		if strings.Contains(strings.ToLower(line), needle) {
+			removed = append(removed, line)
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n"), removed
+}
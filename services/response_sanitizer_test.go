@@ -0,0 +1,68 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeWithDefaultRules(t *testing.T) {
+	rules, err := compileSanitizerRules(defaultSanitizerRules)
+	if err != nil {
+		t.Fatalf("default rules: %v", err)
+	}
+
+	cases := []struct {
+		name, in, want string
+	}{
+		{"clean reply", "Harga paket Basic Rp150.000/bulan.", "Harga paket Basic Rp150.000/bulan."},
+		{"english disclaimer", "As an AI language model, I cannot visit your office. Silakan datang ke kantor kami.", "Silakan datang ke kantor kami."},
+		{"indonesian disclaimer", "Sebagai asisten AI, saya tidak punya pendapat pribadi. Paket Pro cocok untuk tim.", "Paket Pro cocok untuk tim."},
+		{"role prefix", "Assistant: Halo kak, ada yang bisa dibantu?", "Halo kak, ada yang bisa dibantu?"},
+		{"disclaimer mid reply kept", "Paket Pro cocok. As an AI, I recommend it.", "Paket Pro cocok. As an AI, I recommend it."},
+		{"echoed prompt marker", "Halo kak!\n=== REMINDER SEBELUM MENJAWAB ===\nHarga Rp150.000", "Halo kak!\nHarga Rp150.000"},
+		{"reply would be empty", "As an AI language model, I cannot help with that.", "As an AI language model, I cannot help with that."},
+	}
+	for _, tc := range cases {
+		if got := sanitizeWith(rules, tc.in); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompileSanitizerRulesRejectsInvalid(t *testing.T) {
+	invalid := [][]SanitizerRule{
+		{{Name: "empty"}},
+		{{Name: "bad regex", Pattern: "(unclosed"}},
+		{{Name: "both", Pattern: "a", Contains: "b"}},
+	}
+	for _, rules := range invalid {
+		if _, err := compileSanitizerRules(rules); err == nil {
+			t.Errorf("rules %+v accepted", rules)
+		}
+	}
+}
+
+func TestLoadSanitizerRulesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`[{"name":"signature","pattern":"(?i)\\s*-- ?bot$"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AI_SANITIZER_RULES_FILE", path)
+
+	rules := loadSanitizerRules()
+	if len(rules) != 1 || rules[0].Name != "signature" {
+		t.Fatalf("rules = %+v, want only the file's rule", rules)
+	}
+	if got := sanitizeWith(rules, "Terima kasih kak -- bot"); got != "Terima kasih kak" {
+		t.Errorf("got %q", got)
+	}
+
+	// An unusable file keeps the defaults
+	if err := os.WriteFile(path, []byte(`[{"name":"broken","pattern":"("}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rules := loadSanitizerRules(); len(rules) != len(defaultSanitizerRules) {
+		t.Errorf("invalid file: got %d rules, want the %d defaults", len(rules), len(defaultSanitizerRules))
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Send log statuses
+const (
+	SendLogSent   = "sent"
+	SendLogFailed = "failed"
+	SendLogDryRun = "dryrun" // DRY_RUN=true: recorded, never sent
+)
+
+// SendLogDedupe - AI_SEND_LOG_DEDUPE=false restores one row per send attempt
+func SendLogDedupe() bool {
+	return config.GetEnvBool("AI_SEND_LOG_DEDUPE", true)
+}
+
+// JobSendKey identifies the logical message a job sends: "job:<id>" for the text reply,
+// "job:<id>:<part>" for extra parts such as images ("img:0")
+func JobSendKey(jobID uint, part string) string {
+	if part == "" {
+		return fmt.Sprintf("job:%d", jobID)
+	}
+	return fmt.Sprintf("job:%d:%s", jobID, part)
+}
+
+// RecordSendLog stores the outcome of a send. With dedupe on, attempts for the same sendKey
+// (a retried job) update one row: the status moves to the latest outcome - except that a "sent"
+// row is never turned back into "failed" - and attempts is incremented.
+func RecordSendLog(sendKey string, entry *models.MessageSendLog) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	now := time.Now()
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	if entry.Attempts == 0 {
+		entry.Attempts = 1
+	}
+	if sendKey == "" || !SendLogDedupe() {
+		entry.SendKey = nil
+		return db.Create(entry).Error
+	}
+
+	entry.SendKey = &sendKey
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "send_key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":        gorm.Expr("CASE WHEN message_send_logs.status = ? THEN message_send_logs.status ELSE excluded.status END", SendLogSent),
+			"error_msg":     gorm.Expr("CASE WHEN message_send_logs.status = ? THEN message_send_logs.error_msg ELSE excluded.error_msg END", SendLogSent),
+			"body":          gorm.Expr("excluded.body"),
+			"wa_message_id": gorm.Expr("COALESCE(NULLIF(excluded.wa_message_id, ''), message_send_logs.wa_message_id)"),
+			"attempts":      gorm.Expr("message_send_logs.attempts + 1"),
+			"updated_at":    now,
+		}),
+	}).Create(entry).Error
+}
+
+// JobPartSent reports whether an earlier attempt of the job already sent part (send log status "sent")
+func JobPartSent(jobID uint, part string) bool {
+	db := database.GetDB()
+	if db == nil {
+		return false
+	}
+	var count int64
+	db.Model(&models.MessageSendLog{}).
+		Where("send_key = ? AND status = ?", JobSendKey(jobID, part), SendLogSent).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+func setupSendLogTestDB(t *testing.T) string {
+	t.Helper()
+	sessionTok := setupTestDB(t)
+	db := database.GetDB()
+	if err := db.AutoMigrate(&models.MessageSendLog{}); err != nil {
+		t.Fatalf("failed to migrate message_send_logs: %v", err)
+	}
+	t.Cleanup(func() { db.Where("session_tok = ?", sessionTok).Delete(&models.MessageSendLog{}) })
+	return sessionTok
+}
+
+func TestRecordSendLogRetryUpdatesOneRow(t *testing.T) {
+	sessionTok := setupSendLogTestDB(t)
+	key := JobSendKey(987654, "") + ":" + sessionTok
+
+	failed := models.MessageSendLog{SessionTok: sessionTok, To: "6281200000001", Body: "Halo", Status: SendLogFailed, ErrorMsg: "gateway returned 502"}
+	if err := RecordSendLog(key, &failed); err != nil {
+		t.Fatalf("RecordSendLog(failed): %v", err)
+	}
+	sent := models.MessageSendLog{SessionTok: sessionTok, To: "6281200000001", Body: "Halo", Status: SendLogSent, WAMessageID: "wa-1"}
+	if err := RecordSendLog(key, &sent); err != nil {
+		t.Fatalf("RecordSendLog(sent): %v", err)
+	}
+
+	var rows []models.MessageSendLog
+	database.GetDB().Where("session_tok = ?", sessionTok).Find(&rows)
+	if len(rows) != 1 {
+		t.Fatalf("got %d send log rows, want 1", len(rows))
+	}
+	if got := rows[0]; got.Status != SendLogSent || got.Attempts != 2 || got.WAMessageID != "wa-1" || got.ErrorMsg != "" {
+		t.Errorf("row = %+v, want sent after 2 attempts", got)
+	}
+
+	// A late failure (duplicate retry) must not turn a delivered message back into failed
+	late := models.MessageSendLog{SessionTok: sessionTok, To: "6281200000001", Body: "Halo", Status: SendLogFailed, ErrorMsg: "timeout"}
+	if err := RecordSendLog(key, &late); err != nil {
+		t.Fatalf("RecordSendLog(late failure): %v", err)
+	}
+	var row models.MessageSendLog
+	database.GetDB().Where("session_tok = ?", sessionTok).First(&row)
+	if row.Status != SendLogSent || row.Attempts != 3 || row.WAMessageID != "wa-1" {
+		t.Errorf("row after late failure = %+v", row)
+	}
+}
+
+func TestRecordSendLogDedupeDisabled(t *testing.T) {
+	sessionTok := setupSendLogTestDB(t)
+	t.Setenv("AI_SEND_LOG_DEDUPE", "false")
+	key := JobSendKey(987655, "") + ":" + sessionTok
+
+	for i := 0; i < 2; i++ {
+		entry := models.MessageSendLog{SessionTok: sessionTok, To: "6281200000001", Body: "Halo", Status: SendLogSent}
+		if err := RecordSendLog(key, &entry); err != nil {
+			t.Fatalf("RecordSendLog: %v", err)
+		}
+	}
+	var count int64
+	database.GetDB().Model(&models.MessageSendLog{}).Where("session_tok = ?", sessionTok).Count(&count)
+	if count != 2 {
+		t.Errorf("got %d rows with dedupe off, want 2", count)
+	}
+}
+
+func TestJobSendKey(t *testing.T) {
+	if got := JobSendKey(12, ""); got != "job:12" {
+		t.Errorf("JobSendKey(12) = %q", got)
+	}
+	if got := JobSendKey(12, "img:0"); got != "job:12:img:0" {
+		t.Errorf("JobSendKey(12, img:0) = %q", got)
+	}
+}
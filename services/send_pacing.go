@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for per-session send pacing (override via .env)
+const (
+	defaultSendRatePerSecond = 1.0
+	defaultSendBurst         = 3
+	defaultSendMaxWaitMs     = 30000
+)
+
+// ErrSendQueueFull - the session's send queue is longer than WA_SEND_MAX_WAIT_MS
+var ErrSendQueueFull = errors.New("send queue full for this session")
+
+// sendRatePerSecond returns WA_SEND_RATE_PER_SECOND (messages per second per session, 0 = no pacing).
+// Fractions are allowed, e.g. 0.5 = one message every 2 seconds.
+func sendRatePerSecond() float64 {
+	raw := config.GetEnvString("WA_SEND_RATE_PER_SECOND", "")
+	if raw == "" {
+		return defaultSendRatePerSecond
+	}
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil || rate < 0 {
+		return defaultSendRatePerSecond
+	}
+	return rate
+}
+
+// sendBurst returns WA_SEND_BURST (messages a quiet session may send at once)
+func sendBurst() int {
+	burst := config.GetEnvInt("WA_SEND_BURST", defaultSendBurst)
+	if burst < 1 {
+		return 1
+	}
+	return burst
+}
+
+// sendMaxWait returns WA_SEND_MAX_WAIT_MS (longest a send may queue before it is refused)
+func sendMaxWait() time.Duration {
+	ms := config.GetEnvInt("WA_SEND_MAX_WAIT_MS", defaultSendMaxWaitMs)
+	if ms < 0 {
+		ms = defaultSendMaxWaitMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// sendBucket is the token bucket of one session
+type sendBucket struct {
+	tokens float64 // may go negative: sends already queued for a future slot
+	last   time.Time
+}
+
+// sendPacer paces outgoing messages per WhatsApp session token. In memory per process -
+// a session is served by one instance.
+type sendPacer struct {
+	mu      sync.Mutex
+	buckets map[string]*sendBucket
+}
+
+var waSendPacer = &sendPacer{buckets: make(map[string]*sendBucket)}
+
+// reserve books a send slot for token and returns how long to wait for it. A wait longer
+// than maxWait books nothing and returns ErrSendQueueFull.
+func (p *sendPacer) reserve(token string, now time.Time, rate float64, burst int, maxWait time.Duration) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	capacity := float64(burst)
+	// Drop sessions whose bucket has refilled, so the map only holds sessions that are sending
+	for t, b := range p.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= capacity {
+			delete(p.buckets, t)
+		}
+	}
+
+	b, ok := p.buckets[token]
+	if !ok {
+		b = &sendBucket{tokens: capacity, last: now}
+		p.buckets[token] = b
+	}
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > capacity {
+			b.tokens = capacity
+		}
+		b.last = now
+	}
+
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, ErrSendQueueFull
+	}
+	b.tokens--
+	return wait, nil
+}
+
+// WaitSendSlot blocks until the session may send its next message (token bucket of
+// WA_SEND_RATE_PER_SECOND with bursts of WA_SEND_BURST), so AI replies, agent sends and
+// campaigns on one number never fire all at once. Returns ErrSendQueueFull instead of
+// queueing longer than WA_SEND_MAX_WAIT_MS.
+func WaitSendSlot(sessionToken string) error {
+	rate := sendRatePerSecond()
+	if rate == 0 || sessionToken == "" {
+		return nil
+	}
+
+	wait, err := waSendPacer.reserve(sessionToken, time.Now(), rate, sendBurst(), sendMaxWait())
+	if err != nil {
+		return fmt.Errorf("%w (next slot in %s)", err, wait.Round(time.Millisecond))
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
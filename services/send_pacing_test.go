@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendPacerReserve(t *testing.T) {
+	p := &sendPacer{buckets: make(map[string]*sendBucket)}
+	now := time.Now()
+
+	// Burst of 2 at 2/s: two sends go at once, then one every 500ms
+	want := []time.Duration{0, 0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond}
+	for i, w := range want {
+		wait, err := p.reserve("s1", now, 2, 2, 10*time.Second)
+		if err != nil {
+			t.Fatalf("send %d: unexpected error %v", i, err)
+		}
+		if diff := wait - w; diff < -time.Millisecond || diff > time.Millisecond {
+			t.Errorf("send %d: wait = %s, want %s", i, wait, w)
+		}
+	}
+
+	// Another session has its own bucket
+	if wait, _ := p.reserve("s2", now, 2, 2, 10*time.Second); wait != 0 {
+		t.Errorf("other session waited %s, want 0", wait)
+	}
+
+	// A queue longer than maxWait is refused and books nothing
+	if _, err := p.reserve("s1", now, 2, 2, time.Second); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("err = %v, want ErrSendQueueFull", err)
+	}
+	if wait, _ := p.reserve("s1", now, 2, 2, 10*time.Second); wait != 2*time.Second {
+		t.Errorf("after refusal wait = %s, want 2s (refused send must not hold a slot)", wait)
+	}
+
+	// Once idle long enough the bucket is full again
+	if wait, _ := p.reserve("s1", now.Add(time.Minute), 2, 2, 10*time.Second); wait != 0 {
+		t.Errorf("after idle wait = %s, want 0", wait)
+	}
+}
+
+func TestWaitSendSlotPacesBurst(t *testing.T) {
+	t.Setenv("WA_SEND_RATE_PER_SECOND", "20")
+	t.Setenv("WA_SEND_BURST", "2")
+	t.Setenv("WA_SEND_MAX_WAIT_MS", "5000")
+
+	const sends = 6
+	token := "burst-test-session"
+	start := time.Now()
+	done := make([]time.Duration, sends)
+
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := WaitSendSlot(token); err != nil {
+				t.Errorf("send %d: %v", i, err)
+			}
+			done[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+
+	// 2 go immediately, the other 4 are spaced 50ms apart: the last one leaves after ~200ms
+	var immediate int
+	var last time.Duration
+	for _, d := range done {
+		if d < 25*time.Millisecond {
+			immediate++
+		}
+		if d > last {
+			last = d
+		}
+	}
+	if immediate > 2 {
+		t.Errorf("%d sends fired at once, want at most the burst of 2", immediate)
+	}
+	if last < 190*time.Millisecond || last > 2*time.Second {
+		t.Errorf("burst finished after %s, want about 200ms", last)
+	}
+}
+
+func TestWaitSendSlotDisabled(t *testing.T) {
+	t.Setenv("WA_SEND_RATE_PER_SECOND", "0")
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if err := WaitSendSlot("disabled-session"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("disabled pacing took %s", elapsed)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// cachedSession is a resolved session and when it was fetched
+type cachedSession struct {
+	info      SessionInfo
+	fetchedAt time.Time
+}
+
+// sessionCache holds ResolveSession results per session token for a short TTL,
+// so a burst from one contact doesn't hit the transactional layer per message
+var sessionCache = struct {
+	sync.RWMutex
+	entries map[string]cachedSession
+}{entries: make(map[string]cachedSession)}
+
+// sessionCacheTTL - AI_SESSION_CACHE_TTL_SECONDS (default 30, 0 = no caching).
+// Kept short: a subscription can expire or the bot be switched off mid-window.
+func sessionCacheTTL() time.Duration {
+	seconds := config.GetEnvInt("AI_SESSION_CACHE_TTL_SECONDS", 30)
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// getCachedSession returns a copy of a cached session that is still within the TTL
+func getCachedSession(sessionToken string, ttl time.Duration) (*SessionInfo, bool) {
+	sessionCache.RLock()
+	cached, ok := sessionCache.entries[sessionToken]
+	sessionCache.RUnlock()
+
+	if !ok || time.Since(cached.fetchedAt) > ttl {
+		return nil, false
+	}
+	info := cached.info
+	return &info, true
+}
+
+// storeCachedSession caches a successful ResolveSession result.
+// A changed bot/subscription state compared to the previous entry is logged.
+func storeCachedSession(sessionToken string, info *SessionInfo) {
+	sessionCache.Lock()
+	defer sessionCache.Unlock()
+
+	if prev, ok := sessionCache.entries[sessionToken]; ok && prev.info.UserID != info.UserID {
+		log.Printf("🚨 Session %s moved from user %s to user %s", sessionToken, prev.info.UserID, info.UserID)
+	} else if ok &&
+		(prev.info.BotActive != info.BotActive || prev.info.SubscriptionActive != info.SubscriptionActive) {
+		log.Printf("🔄 Session %s changed: botActive %v -> %v, subscriptionActive %v -> %v", sessionToken,
+			prev.info.BotActive, info.BotActive, prev.info.SubscriptionActive, info.SubscriptionActive)
+	}
+	sessionCache.entries[sessionToken] = cachedSession{info: *info, fetchedAt: time.Now()}
+}
+
+// InvalidateSessionCache drops the cached session (e.g. bot toggled or subscription expired),
+// or every cached session when sessionToken is empty. Returns the number of entries removed.
+func InvalidateSessionCache(sessionToken string) int {
+	sessionCache.Lock()
+	defer sessionCache.Unlock()
+
+	if sessionToken == "" {
+		n := len(sessionCache.entries)
+		sessionCache.entries = make(map[string]cachedSession)
+		return n
+	}
+	if _, ok := sessionCache.entries[sessionToken]; !ok {
+		return 0
+	}
+	delete(sessionCache.entries, sessionToken)
+	return 1
+}
+
+// VerifySessionOwner checks, right before work is billed to userID, that the session still belongs
+// to that user: a cache refresh since the session was resolved may have remapped the token
+func VerifySessionOwner(sessionToken, userID string) error {
+	if strings.TrimSpace(userID) == "" {
+		return fmt.Errorf("%w: no user for session %s", ErrSessionUserMismatch, sessionToken)
+	}
+	sessionCache.RLock()
+	cached, ok := sessionCache.entries[sessionToken]
+	sessionCache.RUnlock()
+	if ok && cached.info.UserID != userID {
+		return fmt.Errorf("%w: session %s resolved for user %s, now belongs to user %s", ErrSessionUserMismatch, sessionToken, userID, cached.info.UserID)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingSessionProvider counts ResolveSession calls and returns info (or err)
+type countingSessionProvider struct {
+	slowDataProvider
+	info  SessionInfo
+	err   error
+	calls int
+}
+
+func (p *countingSessionProvider) ResolveSession(token string) (*SessionInfo, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	info := p.info
+	info.SessionToken = token
+	return &info, nil
+}
+
+// useSessionProvider swaps the global data provider and clears the session cache for the test
+func useSessionProvider(t *testing.T, provider DataProvider) {
+	t.Helper()
+	previous := dataProvider
+	dataProvider = provider
+	InvalidateSessionCache("")
+	t.Cleanup(func() {
+		dataProvider = previous
+		InvalidateSessionCache("")
+	})
+}
+
+func TestResolveSessionCachesWithinTTL(t *testing.T) {
+	t.Setenv("AI_SESSION_CACHE_TTL_SECONDS", "30")
+	provider := &countingSessionProvider{info: SessionInfo{UserID: "u1", BotActive: true, SubscriptionActive: true}}
+	useSessionProvider(t, provider)
+
+	for i := 0; i < 5; i++ {
+		info, err := ResolveSession("tok-burst")
+		if err != nil || info.UserID != "u1" {
+			t.Fatalf("ResolveSession = %+v, %v", info, err)
+		}
+		info.BotActive = false // callers get a copy, not the cached entry
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider calls = %d, want 1 for a burst within the TTL", provider.calls)
+	}
+	if info, _ := ResolveSession("tok-burst"); !info.BotActive {
+		t.Error("cached entry was modified through a returned copy")
+	}
+
+	if _, err := ResolveSession("tok-other"); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("different token must not share the cache entry (calls = %d)", provider.calls)
+	}
+}
+
+func TestResolveSessionCacheExpiresAndInvalidates(t *testing.T) {
+	t.Setenv("AI_SESSION_CACHE_TTL_SECONDS", "30")
+	provider := &countingSessionProvider{info: SessionInfo{UserID: "u1", BotActive: true, SubscriptionActive: true}}
+	useSessionProvider(t, provider)
+
+	ResolveSession("tok")
+
+	// Bot switched off -> invalidation makes the next lookup see it
+	provider.info.BotActive = false
+	if removed := InvalidateSessionCache("tok"); removed != 1 {
+		t.Errorf("InvalidateSessionCache removed %d, want 1", removed)
+	}
+	if info, _ := ResolveSession("tok"); info.BotActive {
+		t.Error("expected fresh lookup after invalidation")
+	}
+
+	// Entry older than the TTL is refetched
+	sessionCache.Lock()
+	entry := sessionCache.entries["tok"]
+	entry.fetchedAt = time.Now().Add(-time.Minute)
+	sessionCache.entries["tok"] = entry
+	sessionCache.Unlock()
+
+	ResolveSession("tok")
+	if provider.calls != 3 {
+		t.Errorf("provider calls = %d, want 3", provider.calls)
+	}
+}
+
+func TestResolveSessionDoesNotCacheErrorsOrWhenDisabled(t *testing.T) {
+	t.Setenv("AI_SESSION_CACHE_TTL_SECONDS", "30")
+	provider := &countingSessionProvider{err: errors.New("transactional API down")}
+	useSessionProvider(t, provider)
+
+	ResolveSession("tok")
+	ResolveSession("tok")
+	if provider.calls != 2 {
+		t.Errorf("errors must not be cached (calls = %d)", provider.calls)
+	}
+
+	t.Setenv("AI_SESSION_CACHE_TTL_SECONDS", "0")
+	provider.err = nil
+	ResolveSession("tok2")
+	ResolveSession("tok2")
+	if provider.calls != 4 {
+		t.Errorf("TTL 0 must disable caching (calls = %d)", provider.calls)
+	}
+}
+
+// crossedSessionProvider answers every token with another session's mapping
+type crossedSessionProvider struct {
+	slowDataProvider
+	info SessionInfo
+}
+
+func (p *crossedSessionProvider) ResolveSession(string) (*SessionInfo, error) {
+	info := p.info
+	return &info, nil
+}
+
+func TestResolveSessionRejectsUserMismatch(t *testing.T) {
+	t.Setenv("AI_SESSION_CACHE_TTL_SECONDS", "30")
+
+	useSessionProvider(t, &countingSessionProvider{info: SessionInfo{UserID: "", BotActive: true, SubscriptionActive: true}})
+	if _, err := ResolveSession("tok-no-user"); !errors.Is(err, ErrSessionUserMismatch) {
+		t.Errorf("empty userID: err = %v, want ErrSessionUserMismatch", err)
+	}
+
+	useSessionProvider(t, &crossedSessionProvider{info: SessionInfo{UserID: "u2", SessionToken: "tok-other", BotActive: true}})
+	if _, err := ResolveSession("tok-mine"); !errors.Is(err, ErrSessionUserMismatch) {
+		t.Errorf("other token: err = %v, want ErrSessionUserMismatch", err)
+	}
+	if InvalidateSessionCache("tok-mine") != 0 {
+		t.Error("a mismatched session must not be cached")
+	}
+}
+
+func TestVerifySessionOwner(t *testing.T) {
+	t.Setenv("AI_SESSION_CACHE_TTL_SECONDS", "30")
+	provider := &countingSessionProvider{info: SessionInfo{UserID: "u1", BotActive: true, SubscriptionActive: true}}
+	useSessionProvider(t, provider)
+
+	info, err := ResolveSession("tok-owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySessionOwner("tok-owner", info.UserID); err != nil {
+		t.Errorf("unchanged mapping: %v", err)
+	}
+	if err := VerifySessionOwner("tok-owner", ""); !errors.Is(err, ErrSessionUserMismatch) {
+		t.Errorf("empty user: err = %v, want ErrSessionUserMismatch", err)
+	}
+
+	// A refresh in between remaps the session to another user
+	storeCachedSession("tok-owner", &SessionInfo{UserID: "u2", SessionToken: "tok-owner"})
+	if err := VerifySessionOwner("tok-owner", info.UserID); !errors.Is(err, ErrSessionUserMismatch) {
+		t.Errorf("remapped session: err = %v, want ErrSessionUserMismatch", err)
+	}
+}
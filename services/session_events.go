@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+)
+
+// Connection events sent by the WA Service to /webhook/ai ("type" field of the payload)
+const (
+	SessionEventConnected    = "Connected"
+	SessionEventDisconnected = "Disconnected"
+	SessionEventLoggedOut    = "LoggedOut"
+)
+
+// ErrUnknownSession - a connection event for a token that has no WhatsAppSession row
+var ErrUnknownSession = errors.New("session not found")
+
+// IsSessionEvent reports whether a webhook type is a connection event
+func IsSessionEvent(eventType string) bool {
+	switch eventType {
+	case SessionEventConnected, SessionEventDisconnected, SessionEventLoggedOut:
+		return true
+	}
+	return false
+}
+
+// ApplySessionEvent updates WhatsAppSession.connected / loggedIn / status (and updatedAt) from a
+// connection event, so the connected-session count used by the session limit stays accurate.
+// A disconnect or logout raises an alert unless AI_ALERT_SESSION_DISCONNECT=false.
+func ApplySessionEvent(sessionToken, eventType string) error {
+	db := database.GetTransactionalDB()
+	if db == nil {
+		return fmt.Errorf("transactional database not initialized")
+	}
+
+	updates := map[string]interface{}{models.WhatsappSessionColUpdatedAt: time.Now()}
+	switch eventType {
+	case SessionEventConnected:
+		updates[models.WhatsappSessionColConnected] = true
+		updates["loggedIn"] = true
+		updates["status"] = "connected"
+	case SessionEventDisconnected:
+		updates[models.WhatsappSessionColConnected] = false
+		updates["status"] = "disconnected"
+	case SessionEventLoggedOut:
+		updates[models.WhatsappSessionColConnected] = false
+		updates["loggedIn"] = false
+		updates["status"] = "logged_out"
+	default:
+		return fmt.Errorf("unknown session event %q", eventType)
+	}
+
+	result := db.Model(&models.WhatsappSession{}).
+		Where(map[string]interface{}{models.WhatsappSessionColToken: sessionToken}).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update session state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUnknownSession
+	}
+
+	// Cached session lookups may carry the old state; a cached QR is useless once the state changed
+	InvalidateSessionCache(sessionToken)
+	InvalidateQRCache(sessionToken)
+
+	if eventType != SessionEventConnected && config.GetEnvBool("AI_ALERT_SESSION_DISCONNECT", true) {
+		alertEvent := "session_disconnected"
+		if eventType == SessionEventLoggedOut {
+			alertEvent = "session_logged_out"
+		}
+		SendAlert(alertEvent, fmt.Sprintf("WhatsApp session %s: %s", PreviewText(sessionToken, 10), eventType),
+			map[string]interface{}{"event": eventType})
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package services
+
+import "testing"
+
+func TestIsSessionEvent(t *testing.T) {
+	for _, event := range []string{SessionEventConnected, SessionEventDisconnected, SessionEventLoggedOut} {
+		if !IsSessionEvent(event) {
+			t.Errorf("%s should be a session event", event)
+		}
+	}
+	for _, event := range []string{"", "Message", "ReadReceipt"} {
+		if IsSessionEvent(event) {
+			t.Errorf("%q should not be a session event", event)
+		}
+	}
+}
@@ -1,15 +1,28 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 )
 
+// ErrSessionUserMismatch - a resolved session has no user, or belongs to another session token /
+// user than expected (stale or crossed mapping). Nothing may be billed on it.
+var ErrSessionUserMismatch = errors.New("session/user mismatch")
+
 // SessionInfo holds user and bot configuration from transactional DB
 type SessionInfo struct {
 	UserID             string `json:"userId"`
 	BotActive          bool   `json:"botActive"`
 	SubscriptionActive bool   `json:"subscriptionActive"`
 	SessionToken       string `json:"sessionToken"`
+	PackageName        string `json:"packageName,omitempty"` // active subscription package (used for job priority)
+
+	// ForwardURL receives a copy of every incoming message (the customer's CRM), signed with
+	// ForwardSecret when set; empty = no forwarding (see ForwardIncomingMessage)
+	ForwardURL    string `json:"forwardUrl,omitempty"`
+	ForwardSecret string `json:"forwardSecret,omitempty"`
 }
 
 // Global data provider instance
@@ -27,7 +40,19 @@ func InitDataProvider() error {
 }
 
 // ResolveSession calls appropriate data provider to get user and bot info
+// (cached per session token for AI_SESSION_CACHE_TTL_SECONDS)
 func ResolveSession(sessionToken string) (*SessionInfo, error) {
+	ttl := sessionCacheTTL()
+	if ttl > 0 {
+		if info, ok := getCachedSession(sessionToken, ttl); ok {
+			if err := checkSessionInfo(sessionToken, info); err != nil {
+				InvalidateSessionCache(sessionToken)
+				return nil, err
+			}
+			return info, nil
+		}
+	}
+
 	if dataProvider == nil {
 		// Fallback: initialize if not done yet
 		if err := InitDataProvider(); err != nil {
@@ -35,5 +60,27 @@ func ResolveSession(sessionToken string) (*SessionInfo, error) {
 		}
 	}
 
-	return dataProvider.ResolveSession(sessionToken)
+	info, err := dataProvider.ResolveSession(sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSessionInfo(sessionToken, info); err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		storeCachedSession(sessionToken, info)
+	}
+	return info, nil
+}
+
+// checkSessionInfo rejects a resolved session that can't be billed to the right user: no user ID
+// (a bot can be "active" on a session without one), or the provider answered for another token
+func checkSessionInfo(sessionToken string, info *SessionInfo) error {
+	if strings.TrimSpace(info.UserID) == "" {
+		return fmt.Errorf("%w: session %s resolved without a user", ErrSessionUserMismatch, sessionToken)
+	}
+	if info.SessionToken != "" && info.SessionToken != sessionToken {
+		return fmt.Errorf("%w: session %s resolved as session %s (user %s)", ErrSessionUserMismatch, sessionToken, info.SessionToken, info.UserID)
+	}
+	return nil
 }
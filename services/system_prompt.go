@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+
+	"genfity-wa-support/config"
+)
+
+// System prompt modes (AI_SYSTEM_PROMPT_MODE)
+const (
+	SystemPromptModeAuto   = "auto"   // per provider/model capability (default)
+	SystemPromptModeMerged = "merged" // always prepend the system prompt to the user message (pre-native behavior)
+)
+
+// ProviderCapabilities describes what a provider's API accepts
+type ProviderCapabilities struct {
+	// NativeSystemRole: the system prompt goes in its own field (OpenAI system message, Gemini
+	// SystemInstruction) instead of being merged into the user message
+	NativeSystemRole bool
+}
+
+// providerCapabilities by GetProviderName()
+var providerCapabilities = map[string]ProviderCapabilities{
+	"openrouter": {NativeSystemRole: true},
+	"gemini":     {NativeSystemRole: true},
+}
+
+// defaultMergedSystemPromptModels are model name fragments whose API rejects a separate system
+// instruction (Gemma on the Gemini API / most OpenRouter hosts)
+const defaultMergedSystemPromptModels = "gemma"
+
+// mergedSystemPromptModels returns AI_MERGE_SYSTEM_PROMPT_MODELS (comma-separated, case-insensitive
+// fragments of model names that need the merged prompt); "none" clears the default list
+func mergedSystemPromptModels() []string {
+	raw := config.GetEnvString("AI_MERGE_SYSTEM_PROMPT_MODELS", defaultMergedSystemPromptModels)
+	if strings.EqualFold(strings.TrimSpace(raw), "none") {
+		return nil
+	}
+	var fragments []string
+	for _, fragment := range strings.Split(raw, ",") {
+		if fragment = strings.ToLower(strings.TrimSpace(fragment)); fragment != "" {
+			fragments = append(fragments, fragment)
+		}
+	}
+	return fragments
+}
+
+// UseNativeSystemPrompt reports whether a call to model on provider should send the system
+// prompt separately. The model matters too: a per-request override may switch to one without
+// system-role support.
+func UseNativeSystemPrompt(provider, model string) bool {
+	if strings.EqualFold(config.GetEnvString("AI_SYSTEM_PROMPT_MODE", SystemPromptModeAuto), SystemPromptModeMerged) {
+		return false
+	}
+	if !providerCapabilities[provider].NativeSystemRole {
+		return false
+	}
+	lower := strings.ToLower(model)
+	for _, fragment := range mergedSystemPromptModels() {
+		if strings.Contains(lower, fragment) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeSystemPrompt prepends the system prompt to the user message for models without a system role
+func mergeSystemPrompt(systemPrompt, userPrompt string) string {
+	if systemPrompt == "" {
+		return userPrompt
+	}
+	return systemPrompt + "\n\n" + userPrompt
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+func TestUseNativeSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name, mode, mergedModels, provider, model string
+		want                                      bool
+	}{
+		{"gemini", "", "", "gemini", "gemini-2.5-flash", true},
+		{"openrouter", "", "", "openrouter", "openai/gpt-4o-mini", true},
+		{"gemma default", "", "", "openrouter", "google/gemma-3-27b-it", false},
+		{"gemma on gemini", "", "", "gemini", "gemma-3-12b-it", false},
+		{"unknown provider", "", "", "scripted", "x", false},
+		{"forced merged", "merged", "", "gemini", "gemini-2.5-flash", false},
+		{"custom list", "", "mistral, Llama", "openrouter", "meta-llama/llama-3-8b", false},
+		{"custom list replaces default", "", "mistral", "openrouter", "google/gemma-3-27b-it", true},
+		{"list cleared", "", "none", "gemini", "gemma-3-12b-it", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AI_SYSTEM_PROMPT_MODE", tt.mode)
+			t.Setenv("AI_MERGE_SYSTEM_PROMPT_MODELS", tt.mergedModels)
+			if got := UseNativeSystemPrompt(tt.provider, tt.model); got != tt.want {
+				t.Errorf("UseNativeSystemPrompt(%q, %q) = %v, want %v", tt.provider, tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildGeminiRequest(t *testing.T) {
+	contents, cfg := buildGeminiRequest("gemini-2.5-flash", "be brief", "halo")
+	if cfg == nil || cfg.SystemInstruction == nil || cfg.SystemInstruction.Parts[0].Text != "be brief" {
+		t.Fatalf("system prompt must go in SystemInstruction, config = %+v", cfg)
+	}
+	if len(contents) != 1 || contents[0].Role != genai.RoleUser || contents[0].Parts[0].Text != "halo" {
+		t.Errorf("contents must be the user prompt only, got %+v", contents[0])
+	}
+
+	contents, cfg = buildGeminiRequest("gemma-3-12b-it", "be brief", "halo")
+	if cfg != nil {
+		t.Errorf("merged request must not set a config, got %+v", cfg)
+	}
+	if got := contents[0].Parts[0].Text; got != "be brief\n\nhalo" {
+		t.Errorf("merged prompt = %q", got)
+	}
+
+	contents, cfg = buildGeminiRequest("gemini-2.5-flash", "", "halo")
+	if cfg != nil || contents[0].Parts[0].Text != "halo" {
+		t.Errorf("empty system prompt: config = %+v, text = %q", cfg, contents[0].Parts[0].Text)
+	}
+}
+
+func TestBuildOpenRouterMessages(t *testing.T) {
+	messages := buildOpenRouterMessages("openai/gpt-4o-mini", "be brief", "halo")
+	if len(messages) != 2 ||
+		messages[0].Role != openai.ChatMessageRoleSystem || messages[0].Content != "be brief" ||
+		messages[1].Role != openai.ChatMessageRoleUser || messages[1].Content != "halo" {
+		t.Errorf("native request = %+v, want system then user message", messages)
+	}
+
+	messages = buildOpenRouterMessages("google/gemma-3-27b-it", "be brief", "halo")
+	if len(messages) != 1 || messages[0].Role != openai.ChatMessageRoleUser || messages[0].Content != "be brief\n\nhalo" {
+		t.Errorf("merged request = %+v, want one user message", messages)
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const zeroWidthJoiner = '\u200d'
+
+// TruncateRunes returns at most max characters (runes) of s. The result is always valid UTF-8
+// (invalid bytes are replaced with U+FFFD), and the cut never separates a character from the
+// combining marks, variation selectors, skin tones or zero-width joiners attached to it -
+// it backs off to before that character instead, so the result may be slightly shorter.
+func TruncateRunes(s string, max int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if max <= 0 {
+		return ""
+	}
+	if len(s) <= max {
+		return s // max bytes can't hold more than max runes
+	}
+
+	cut := 0
+	for i := 0; i < max && cut < len(s); i++ {
+		_, size := utf8.DecodeRuneInString(s[cut:])
+		cut += size
+	}
+	if cut == len(s) {
+		return s
+	}
+
+	for cut > 0 {
+		next, _ := utf8.DecodeRuneInString(s[cut:])
+		prev, size := utf8.DecodeLastRuneInString(s[:cut])
+		if !extendsPrevious(next) && prev != zeroWidthJoiner {
+			break
+		}
+		cut -= size
+	}
+	return s[:cut]
+}
+
+// extendsPrevious reports whether r renders as part of the character before it
+func extendsPrevious(r rune) bool {
+	return r == zeroWidthJoiner ||
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) || // combining marks, incl. variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) // emoji skin tone modifiers
+}
+
+// PreviewText returns at most maxRunes characters of s for logging (see TruncateRunes),
+// with "..." appended when something was cut
+func PreviewText(s string, maxRunes int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	truncated := TruncateRunes(s, maxRunes)
+	if maxRunes > 0 && truncated != s {
+		return truncated + "..."
+	}
+	return truncated
+}
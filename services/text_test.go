@@ -0,0 +1,115 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	family := "\U0001F468\u200d\U0001F469\u200d\U0001F467" // 👨‍👩‍👧 as one ZWJ sequence
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"short untouched", "halo", 10, "halo"},
+		{"ascii cut", "halo kak", 4, "halo"},
+		{"emoji counts as one rune", "ok😀😀", 3, "ok😀"},
+		{"precomposed accent", "café", 3, "caf"},
+		{"combining accent stays with its letter", "cafe\u0301 enak", 4, "caf"},
+		{"combining accent fully kept", "cafe\u0301 enak", 5, "cafe\u0301"},
+		{"variation selector kept with heart", "a❤\ufe0fb", 2, "a"},
+		{"skin tone kept with hand", "hi👋\U0001F3FD", 3, "hi"},
+		{"zwj sequence not split after joiner", "a" + family, 3, "a"},
+		{"zwj sequence not split before joiner", "a" + family, 2, "a"},
+		{"whole zwj sequence fits", "a" + family + "b", 6, "a" + family},
+		{"invalid utf8 replaced", "ab\xffcd", 3, "ab\uFFFD"},
+		{"zero max", "halo", 0, ""},
+		{"negative max", "halo", -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateRunes(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("TruncateRunes(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateRunes(%q, %d) returned invalid UTF-8", tt.in, tt.max)
+			}
+			if tt.max > 0 && utf8.RuneCountInString(got) > tt.max {
+				t.Errorf("TruncateRunes(%q, %d) kept %d runes", tt.in, tt.max, utf8.RuneCountInString(got))
+			}
+		})
+	}
+}
+
+func TestTruncateQuotedAndDocumentsRuneSafe(t *testing.T) {
+	quoted := truncateQuoted("x" + strings.Repeat("e\u0301", 200)) // rune 300 is an "e" whose accent follows
+	if !utf8.ValidString(quoted) || strings.HasPrefix(strings.TrimSuffix(quoted, "..."), "\u0301") ||
+		strings.HasSuffix(strings.TrimSuffix(quoted, "..."), "e") {
+		t.Errorf("quoted text split a combining pair: %q", quoted[len(quoted)-10:])
+	}
+
+	t.Setenv("AI_MAX_KB_CHARS", "0")
+	doc := Document{Title: "Promo", Kind: "faq", Content: "x" + strings.Repeat("👍\U0001F3FB", 3000)} // the 5000-rune cap lands between 👍 and its tone
+	ctx := AssembleContext(&BotSettings{Documents: []Document{doc}}, nil, "halo")
+	if !utf8.ValidString(ctx.SystemPrompt) {
+		t.Fatalf("prompt is not valid UTF-8")
+	}
+	if strings.Contains(ctx.SystemPrompt, "👍...") {
+		t.Errorf("document cut between emoji and its skin tone")
+	}
+}
+
+func TestPreviewText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"short ascii untouched", "halo", 10, "halo"},
+		{"ascii cut", "halo kak", 4, "halo..."},
+		{"emoji at boundary kept whole", "ab😀😀", 3, "ab😀..."},
+		{"indonesian accents", "café kopi", 4, "café..."},
+		{"exact rune count untouched", "😀😀😀", 3, "😀😀😀"},
+		{"zero max", "halo", 0, ""},
+		{"invalid utf8 replaced", "ab\xffcd", 10, "ab�cd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PreviewText(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("PreviewText(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("PreviewText(%q, %d) returned invalid UTF-8", tt.in, tt.max)
+			}
+		})
+	}
+}
+
+func TestPreviewTextEveryBoundary(t *testing.T) {
+	// Each max lands at a different byte offset inside the 4-byte emoji / 2-byte é runs
+	text := strings.Repeat("é😀a", 50)
+	for max := 0; max <= utf8.RuneCountInString(text)+1; max++ {
+		got := PreviewText(text, max)
+		if !utf8.ValidString(got) {
+			t.Fatalf("max %d: invalid UTF-8 %q", max, got)
+		}
+		if n := utf8.RuneCountInString(strings.TrimSuffix(got, "...")); n > max {
+			t.Fatalf("max %d: preview has %d runes", max, n)
+		}
+	}
+}
+
+func TestAssembleContextMultibyteSystemPrompt(t *testing.T) {
+	// 300 bytes into this prompt is the middle of an emoji - the old byte slice cut it in half
+	prompt := strings.Repeat("Halo 👋 ", 60)
+	ctx := AssembleContext(&BotSettings{SystemPrompt: prompt}, nil, "halo")
+	if !strings.HasPrefix(ctx.SystemPrompt, prompt) || !utf8.ValidString(ctx.SystemPrompt) {
+		t.Errorf("system prompt altered or invalid UTF-8")
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"genfity-wa-support/config"
+)
+
+const (
+	defaultOpenRouterModel = "openai/gpt-4o-mini"
+	defaultGeminiModel     = "gemini-2.5-flash"
+
+	// defaultContextWindow is assumed for models missing from the table and AI_MODEL_CONTEXT_WINDOWS
+	defaultContextWindow = 8192
+	// defaultResponseReserve keeps room for the reply itself (AI_RESPONSE_RESERVE_TOKENS)
+	defaultResponseReserve = 1024
+)
+
+// knownContextWindows are context sizes (tokens) by model name prefix, provider prefix
+// ("openai/", "google/") stripped; the longest matching prefix wins
+var knownContextWindows = map[string]int{
+	"gpt-4o":         128000,
+	"gpt-4.1":        1047576,
+	"gpt-4-turbo":    128000,
+	"gpt-4":          8192,
+	"gpt-3.5-turbo":  16385,
+	"o1":             200000,
+	"o3":             200000,
+	"o4-mini":        200000,
+	"claude":         200000,
+	"gemini-1.5-pro": 2097152,
+	"gemini":         1048576,
+	"llama-3.1":      131072,
+	"llama-3.2":      131072,
+	"llama-3.3":      131072,
+	"llama-3":        8192,
+	"mistral":        32768,
+	"mixtral":        32768,
+	"qwen":           32768,
+	"deepseek":       65536,
+}
+
+// configuredModelName is the model the worker's AI provider calls (AI_PROVIDER + OPENROUTER_MODEL / GEMINI_MODEL)
+func configuredModelName() string {
+	if strings.EqualFold(os.Getenv("AI_PROVIDER"), "gemini") {
+		return config.GetEnvString("GEMINI_MODEL", defaultGeminiModel)
+	}
+	return config.GetEnvString("OPENROUTER_MODEL", defaultOpenRouterModel)
+}
+
+// ModelContextWindow returns the context size in tokens for model: AI_MODEL_CONTEXT_WINDOWS
+// ("model=tokens,..." - full name or prefix) first, then the built-in table, else
+// AI_CONTEXT_WINDOW_DEFAULT (default 8192)
+func ModelContextWindow(model string) int {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if tokens, ok := matchContextWindow(model, contextWindowOverrides()); ok {
+		return tokens
+	}
+	name := model
+	if _, withoutProvider, found := strings.Cut(model, "/"); found {
+		name = withoutProvider
+	}
+	if tokens, ok := matchContextWindow(name, knownContextWindows); ok {
+		return tokens
+	}
+
+	fallback := config.GetEnvInt("AI_CONTEXT_WINDOW_DEFAULT", defaultContextWindow)
+	if fallback <= 0 {
+		return defaultContextWindow
+	}
+	return fallback
+}
+
+// matchContextWindow returns the entry with the longest prefix of model
+func matchContextWindow(model string, windows map[string]int) (int, bool) {
+	best, tokens := -1, 0
+	for prefix, size := range windows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, tokens = len(prefix), size
+		}
+	}
+	return tokens, best >= 0
+}
+
+// contextWindowOverrides parses AI_MODEL_CONTEXT_WINDOWS, skipping malformed entries
+func contextWindowOverrides() map[string]int {
+	windows := make(map[string]int)
+	raw := os.Getenv("AI_MODEL_CONTEXT_WINDOWS")
+	if strings.TrimSpace(raw) == "" {
+		return windows
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		tokens, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || name == "" || err != nil || tokens <= 0 {
+			log.Printf("⚠️  Warning: Invalid AI_MODEL_CONTEXT_WINDOWS entry %q ignored", entry)
+			continue
+		}
+		windows[name] = tokens
+	}
+	return windows
+}
+
+// responseReserveTokens is the part of the context window kept free for the reply
+// (AI_RESPONSE_RESERVE_TOKENS, default 1024)
+func responseReserveTokens() int {
+	reserve := config.GetEnvInt("AI_RESPONSE_RESERVE_TOKENS", defaultResponseReserve)
+	if reserve < 0 {
+		return defaultResponseReserve
+	}
+	return reserve
+}
+
+// EstimateTokens approximates what a BPE tokenizer (cl100k / o200k style) makes of text without
+// shipping its vocabulary: ASCII words cost one token plus one per 4 letters after the third,
+// numbers one per 3 digits, punctuation runs one per 2 characters, newlines one each, other
+// scripts one per rune and emoji / symbols two. Spaces fold into the following word. It errs on
+// the high side for Indonesian, which BPE vocabularies split more than English.
+func EstimateTokens(text string) int {
+	tokens := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case r == '\n':
+			tokens++
+		case unicode.IsSpace(r):
+			// folded into the next token
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			for j < len(runes) && runes[j] < utf8.RuneSelf && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			tokens += 1 + max(j-i-3, 0)/4
+		case unicode.IsDigit(r):
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens += (j - i + 2) / 3
+		case r < utf8.RuneSelf:
+			for j < len(runes) && runes[j] == r {
+				j++
+			}
+			tokens += (j - i + 1) / 2
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			tokens++
+		default:
+			tokens += 2
+		}
+		i = j
+	}
+	return tokens
+}
+
+// contextBudget tracks the tokens left for the prompt while AssembleContext fills it
+type contextBudget struct {
+	remaining int
+}
+
+// newContextBudget starts from the model's context window minus the response reserve and a 5%
+// margin for estimation error
+func newContextBudget(model string) *contextBudget {
+	window := ModelContextWindow(model)
+	return &contextBudget{remaining: max(window-window/20-responseReserveTokens(), 0)}
+}
+
+// take reserves the tokens of text if they fit and reports whether they did
+func (b *contextBudget) take(text string) bool {
+	tokens := EstimateTokens(text)
+	if tokens > b.remaining {
+		return false
+	}
+	b.remaining -= tokens
+	return true
+}
+
+// reserve takes the tokens of text even if they don't fit (parts of the prompt that are never dropped)
+func (b *contextBudget) reserve(text string) {
+	b.remaining = max(b.remaining-EstimateTokens(text), 0)
+}
+
+// release gives back the tokens of text taken earlier
+func (b *contextBudget) release(text string) {
+	b.remaining += EstimateTokens(text)
+}
+
+// fitRunes returns the longest prefix of text (in runes, at most limit) whose estimate fits the
+// remaining budget - a document is shortened before it is dropped
+func (b *contextBudget) fitRunes(text string, limit int) int {
+	runes := []rune(text)
+	lo, hi := 0, min(limit, len(runes))
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if EstimateTokens(string(runes[:mid])) <= b.remaining {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
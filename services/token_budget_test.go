@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"genfity-wa-support/models"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"pengembangan", 3},
+		{"Rp150000", 3},
+		{"website", 2},
+		{"===", 2},
+		{"baris\nbaru", 3},
+		{"😀", 2},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+
+	// Same order of magnitude as chars/4 for ordinary prose
+	prose := strings.Repeat("Untuk website e-commerce dengan fitur pembayaran, estimasi biaya sekitar Rp 8-12 juta. ", 20)
+	if got, rough := EstimateTokens(prose), len(prose)/4; got < rough/2 || got > rough*2 {
+		t.Errorf("EstimateTokens(prose) = %d, chars/4 = %d", got, rough)
+	}
+}
+
+func TestModelContextWindow(t *testing.T) {
+	t.Setenv("AI_CONTEXT_WINDOW_DEFAULT", "")
+	t.Setenv("AI_MODEL_CONTEXT_WINDOWS", "")
+	tests := map[string]int{
+		"openai/gpt-4o-mini":      128000,
+		"gpt-4":                   8192,
+		"openai/gpt-4-turbo":      128000,
+		"google/gemini-2.5-flash": 1048576,
+		"meta-llama/llama-3-8b":   8192,
+		"meta-llama/llama-3.1-8b": 131072,
+		"some/unknown-model":      defaultContextWindow,
+	}
+	for model, want := range tests {
+		if got := ModelContextWindow(model); got != want {
+			t.Errorf("ModelContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+
+	t.Setenv("AI_MODEL_CONTEXT_WINDOWS", "some/unknown-model=32000, openai/gpt-4o=bad, ,openai/=1000")
+	t.Setenv("AI_CONTEXT_WINDOW_DEFAULT", "4096")
+	if got := ModelContextWindow("some/unknown-model"); got != 32000 {
+		t.Errorf("override: got %d, want 32000", got)
+	}
+	if got := ModelContextWindow("openai/gpt-4o-mini"); got != 1000 {
+		t.Errorf("prefix override: got %d, want 1000", got)
+	}
+	if got := ModelContextWindow("other/model"); got != 4096 {
+		t.Errorf("AI_CONTEXT_WINDOW_DEFAULT: got %d, want 4096", got)
+	}
+}
+
+func TestAssembleContextTokenBudget(t *testing.T) {
+	t.Setenv("AI_PROVIDER", "openrouter")
+	t.Setenv("OPENROUTER_MODEL", "test/tiny")
+	t.Setenv("AI_RESPONSE_RESERVE_TOKENS", "0")
+	t.Setenv("AI_MAX_DOCUMENTS", "")
+	t.Setenv("AI_MAX_KB_CHARS", "")
+	t.Setenv("AI_HISTORY_LINE_MAX_CHARS", "")
+
+	bot := &BotSettings{SystemPrompt: "bot"}
+	history := make([]models.AIChatMessage, 10)
+	for i := range history {
+		history[i] = models.AIChatMessage{MessageID: fmt.Sprintf("m%d", i), Body: fmt.Sprintf("pesan nomor %d tentang pesanan saya", i),
+			Timestamp: time.Unix(int64(i), 0)}
+	}
+
+	t.Setenv("AI_MODEL_CONTEXT_WINDOWS", "test/tiny=1000000")
+	base := EstimateTokens(AssembleContext(bot, nil, "halo").SystemPrompt)
+	full := EstimateTokens(AssembleContext(bot, history, "halo").SystemPrompt)
+	perLine := (full - base) / len(history)
+
+	// Room for part of the history only: the newest messages stay, the oldest go
+	window := (base + (full-base)/2 + 2) * 20 / 19
+	t.Setenv("AI_MODEL_CONTEXT_WINDOWS", fmt.Sprintf("test/tiny=%d", window))
+	prompt := AssembleContext(bot, history, "halo").SystemPrompt
+	if !strings.Contains(prompt, "pesan nomor 9 ") {
+		t.Errorf("newest message missing from trimmed history")
+	}
+	if strings.Contains(prompt, "pesan nomor 0 ") {
+		t.Errorf("oldest message kept although the budget is exceeded")
+	}
+	if got := EstimateTokens(prompt); got > window {
+		t.Errorf("prompt ~%d tokens exceeds the %d token window (per line ~%d)", got, window, perLine)
+	}
+
+	// Knowledge base: the relevant pricing doc stays, the unrelated one is dropped
+	docs := []Document{
+		{Title: "Sejarah", Kind: "faq", Content: strings.Repeat("cerita perusahaan kami ", 150)},
+		{Title: "Harga", Kind: "pricing", Content: "Paket Starter Rp100.000, Paket Business Rp250.000"},
+	}
+	kbBot := &BotSettings{SystemPrompt: "bot", Documents: docs}
+	t.Setenv("AI_MODEL_CONTEXT_WINDOWS", "test/tiny=1000000")
+	withHarga := EstimateTokens(AssembleContext(&BotSettings{SystemPrompt: "bot", Documents: docs[1:]}, nil, "berapa harga paket?").SystemPrompt)
+	t.Setenv("AI_MODEL_CONTEXT_WINDOWS", fmt.Sprintf("test/tiny=%d", (withHarga+20)*20/19))
+	prompt = AssembleContext(kbBot, nil, "berapa harga paket?").SystemPrompt
+	if !strings.Contains(prompt, "Paket Starter") {
+		t.Errorf("pricing document dropped although it is the most relevant")
+	}
+	if strings.Contains(prompt, "[faq - Sejarah]") {
+		t.Errorf("irrelevant document kept although the budget is exceeded")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 )
 
@@ -18,9 +17,14 @@ type TypingStateRequest struct {
 // SetTypingState sends typing indicator to WhatsApp Server
 // state can be "composing" (start typing) or "stop" (stop typing)
 func SetTypingState(sessionToken, phone, state string) error {
-	waServerAPI := os.Getenv("WHATSAPP_SERVER_API")
+	if DryRun() {
+		RecordDryRunSend(sessionToken, phone, "chat/presence", state)
+		return nil
+	}
+
+	waServerAPI := WAServerURLForSession(sessionToken)
 	if waServerAPI == "" {
-		return fmt.Errorf("WHATSAPP_SERVER_API not configured")
+		return fmt.Errorf("WA_SERVER_URL not configured")
 	}
 
 	url := fmt.Sprintf("%s/chat/presence", waServerAPI)
@@ -43,8 +47,7 @@ func SetTypingState(sessionToken, phone, state string) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("token", sessionToken)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := DoHTTP(req, 10*time.Second)
 	if err != nil {
 		return fmt.Errorf("failed to send typing state request: %w", err)
 	}
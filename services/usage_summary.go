@@ -0,0 +1,171 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"genfity-wa-support/database"
+)
+
+// Default prices (USD per 1M tokens) match the default model, openai/gpt-4o-mini
+const (
+	defaultCostPerMInputTokens  = 0.15
+	defaultCostPerMOutputTokens = 0.60
+)
+
+// UsageStats are aggregated AIUsageLog rows (one day, or the whole range for totals)
+type UsageStats struct {
+	Day              string  `json:"day,omitempty"` // YYYY-MM-DD (UTC); empty for totals
+	Messages         int64   `json:"messages"`
+	Errors           int64   `json:"errors"`
+	InputTokens      int64   `json:"inputTokens"`
+	OutputTokens     int64   `json:"outputTokens"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"` // successful calls only - failures log 0
+	ErrorRate        float64 `json:"errorRate"`    // errors / messages
+	EstimatedCostUSD float64 `json:"estimatedCostUsd"`
+}
+
+// UsageSummary is AI usage of one user (or everyone) between From and To, grouped by day
+type UsageSummary struct {
+	UserID string       `json:"userId,omitempty"`
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	Totals UsageStats   `json:"totals"`
+	Days   []UsageStats `json:"days"`
+}
+
+// UsageSummaryProvider is implemented by data providers that can read usage back
+// (direct DB and API mode; the file provider only logs)
+type UsageSummaryProvider interface {
+	GetUsageSummary(userID string, from, to time.Time) (*UsageSummary, error)
+}
+
+// usageCostRates returns USD per 1M input / output tokens
+// (AI_COST_PER_1M_INPUT_TOKENS / AI_COST_PER_1M_OUTPUT_TOKENS)
+func usageCostRates() (float64, float64) {
+	rate := func(key string, fallback float64) float64 {
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			return fallback
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 {
+			log.Printf("⚠️  Warning: Invalid %s value %q, using default %v", key, raw, fallback)
+			return fallback
+		}
+		return value
+	}
+	return rate("AI_COST_PER_1M_INPUT_TOKENS", defaultCostPerMInputTokens),
+		rate("AI_COST_PER_1M_OUTPUT_TOKENS", defaultCostPerMOutputTokens)
+}
+
+// summarizeUsage fills error rate and cost of each day and computes the totals
+func summarizeUsage(userID string, from, to time.Time, days []UsageStats) *UsageSummary {
+	inputRate, outputRate := usageCostRates()
+	summary := &UsageSummary{UserID: userID, From: from, To: to, Days: days}
+	if summary.Days == nil {
+		summary.Days = []UsageStats{}
+	}
+
+	var latencyWeighted float64
+	totals := &summary.Totals
+	for i := range summary.Days {
+		day := &summary.Days[i]
+		if day.Messages > 0 {
+			day.ErrorRate = float64(day.Errors) / float64(day.Messages)
+		}
+		day.EstimatedCostUSD = (float64(day.InputTokens)*inputRate + float64(day.OutputTokens)*outputRate) / 1e6
+
+		totals.Messages += day.Messages
+		totals.Errors += day.Errors
+		totals.InputTokens += day.InputTokens
+		totals.OutputTokens += day.OutputTokens
+		totals.EstimatedCostUSD += day.EstimatedCostUSD
+		latencyWeighted += day.AvgLatencyMs * float64(day.Messages-day.Errors)
+	}
+	if totals.Messages > 0 {
+		totals.ErrorRate = float64(totals.Errors) / float64(totals.Messages)
+	}
+	if ok := totals.Messages - totals.Errors; ok > 0 {
+		totals.AvgLatencyMs = latencyWeighted / float64(ok)
+	}
+	return summary
+}
+
+// GetUsageSummary aggregates AIUsageLog per day (UTC) in [from, to); empty userID = all users
+func (p *DBProvider) GetUsageSummary(userID string, from, to time.Time) (*UsageSummary, error) {
+	if !p.tablesVerified {
+		return nil, fmt.Errorf("tables not verified")
+	}
+	db := database.GetTransactionalDB()
+
+	query := db.Table(`"AIUsageLog"`).
+		Select(`to_char(date_trunc('day', "createdAt"), 'YYYY-MM-DD') AS day,
+			COUNT(*) AS messages,
+			COUNT(*) FILTER (WHERE "status" <> 'ok') AS errors,
+			COALESCE(SUM("inputTokens"), 0) AS input_tokens,
+			COALESCE(SUM("outputTokens"), 0) AS output_tokens,
+			COALESCE(AVG("latencyMs") FILTER (WHERE "status" = 'ok'), 0) AS avg_latency_ms`).
+		Where(`"createdAt" >= ? AND "createdAt" < ?`, from, to)
+	if userID != "" {
+		query = query.Where(`"userId" = ?`, userID)
+	}
+
+	var days []UsageStats
+	if err := query.Group("day").Order("day").Scan(&days).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	return summarizeUsage(userID, from, to, days), nil
+}
+
+// GetUsageSummary fetches the summary from the transactional API
+// (GET /customer/ai/usage/summary, same shape as the direct DB result)
+func (p *APIProvider) GetUsageSummary(userID string, from, to time.Time) (*UsageSummary, error) {
+	params := url.Values{}
+	if userID != "" {
+		params.Set("userId", userID)
+	}
+	params.Set("from", from.UTC().Format(time.RFC3339))
+	params.Set("to", to.UTC().Format(time.RFC3339))
+	endpoint := fmt.Sprintf("%s/customer/ai/usage/summary?%s", p.baseURL, params.Encode())
+
+	resp, err := p.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if p.apiKey != "" {
+			req.Header.Set("x-api-key", p.apiKey)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call usage summary API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("usage summary API returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool         `json:"success"`
+		Data    UsageSummary `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("API returned success=false")
+	}
+	return &result.Data, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSummarizeUsage(t *testing.T) {
+	t.Setenv("AI_COST_PER_1M_INPUT_TOKENS", "1")
+	t.Setenv("AI_COST_PER_1M_OUTPUT_TOKENS", "bad") // falls back to the default 0.60
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary := summarizeUsage("u1", from, from.AddDate(0, 0, 2), []UsageStats{
+		{Day: "2026-03-01", Messages: 4, Errors: 1, InputTokens: 1_000_000, OutputTokens: 500_000, AvgLatencyMs: 1000},
+		{Day: "2026-03-02", Messages: 2, Errors: 0, InputTokens: 0, OutputTokens: 1_000_000, AvgLatencyMs: 2500},
+	})
+
+	day := summary.Days[0]
+	if day.ErrorRate != 0.25 || !almostEqual(day.EstimatedCostUSD, 1.30) {
+		t.Errorf("day 1 = %+v, want error rate 0.25, cost 1.30", day)
+	}
+	totals := summary.Totals
+	if totals.Messages != 6 || totals.Errors != 1 || totals.InputTokens != 1_000_000 || totals.OutputTokens != 1_500_000 {
+		t.Errorf("totals = %+v", totals)
+	}
+	// (3 ok calls * 1000ms + 2 ok calls * 2500ms) / 5
+	if !almostEqual(totals.AvgLatencyMs, 1600) || !almostEqual(totals.EstimatedCostUSD, 1.90) {
+		t.Errorf("totals latency/cost = %v / %v, want 1600 / 1.90", totals.AvgLatencyMs, totals.EstimatedCostUSD)
+	}
+
+	if empty := summarizeUsage("", from, from, nil); empty.Days == nil || empty.Totals.ErrorRate != 0 {
+		t.Errorf("empty summary = %+v", empty)
+	}
+}
+
+func almostEqual(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestAPIProviderGetUsageSummary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/customer/ai/usage/summary" || r.URL.Query().Get("userId") != "u1" ||
+			r.URL.Query().Get("from") != "2026-03-01T00:00:00Z" || r.Header.Get("x-api-key") != "test-internal-key" {
+			t.Errorf("unexpected request %s (key %q)", r.URL, r.Header.Get("x-api-key"))
+		}
+		io.WriteString(w, `{"success":true,"data":{"userId":"u1","totals":{"messages":3,"inputTokens":120},"days":[{"day":"2026-03-01","messages":3}]}}`)
+	}))
+	defer server.Close()
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := newTestAPIProvider(t, server, "0").GetUsageSummary("u1", from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetUsageSummary: %v", err)
+	}
+	if summary.Totals.Messages != 3 || summary.Totals.InputTokens != 120 || len(summary.Days) != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 )
 
@@ -22,10 +22,14 @@ func MarkMessagesAsRead(sessionToken string, messageIDs []string, chatPhone stri
 	if len(messageIDs) == 0 {
 		return nil // Nothing to mark
 	}
+	if DryRun() {
+		RecordDryRunSend(sessionToken, chatPhone, "chat/markread", strings.Join(messageIDs, ","))
+		return nil
+	}
 
-	waServerURL := os.Getenv("WHATSAPP_SERVER_API")
+	waServerURL := WAServerURLForSession(sessionToken)
 	if waServerURL == "" {
-		return fmt.Errorf("WHATSAPP_SERVER_API not configured")
+		return fmt.Errorf("WA_SERVER_URL not configured")
 	}
 
 	endpoint := fmt.Sprintf("%s/chat/markread", waServerURL)
@@ -48,11 +52,7 @@ func MarkMessagesAsRead(sessionToken string, messageIDs []string, chatPhone stri
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("token", sessionToken)
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Do(req)
+	resp, err := DoHTTP(req, 10*time.Second)
 	if err != nil {
 		return fmt.Errorf("failed to send markread request: %w", err)
 	}
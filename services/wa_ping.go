@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// waPingTimeout bounds the connectivity check so a hanging WA server shows up as unreachable
+const waPingTimeout = 10 * time.Second
+
+// WAPingResult is the outcome of a WA server connectivity check
+type WAPingResult struct {
+	URL           string `json:"url"`
+	Check         string `json:"check"` // "admin" (WA_ADMIN_TOKEN) or "session" (a session token)
+	Configured    bool   `json:"configured"`
+	Reachable     bool   `json:"reachable"`
+	Authenticated bool   `json:"authenticated"`
+	StatusCode    int    `json:"status_code,omitempty"`
+	LatencyMs     int64  `json:"latency_ms"`
+	Error         string `json:"error,omitempty"`
+}
+
+// OK reports whether the WA server was reached and accepted the credentials
+func (r WAPingResult) OK() bool {
+	return r.Reachable && r.Authenticated
+}
+
+// PingWAServer makes a lightweight authenticated call to the WA server: GET /admin/users with
+// WA_ADMIN_TOKEN, or GET /session/status with sessionToken when one is given
+func PingWAServer(sessionToken string) WAPingResult {
+	baseURL := WAServerURLForSession(sessionToken)
+	result := WAPingResult{URL: baseURL, Check: "admin"}
+	if sessionToken != "" {
+		result.Check = "session"
+	}
+	if baseURL == "" {
+		result.Error = "WA_SERVER_URL is not configured"
+		return result
+	}
+
+	path, header, credential := "/admin/users", "Authorization", config.GetEnvString("WA_ADMIN_TOKEN", "")
+	if sessionToken != "" {
+		path, header, credential = "/session/status", "token", sessionToken
+	}
+	if credential == "" {
+		result.Error = "WA_ADMIN_TOKEN is not configured"
+		return result
+	}
+	result.Configured = true
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid WA_SERVER_URL: %v", err)
+		return result
+	}
+	req.Header.Set(header, credential)
+
+	start := time.Now()
+	resp, err := DoHTTP(req, waPingTimeout)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("WA server unreachable: %v", err)
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result.Reachable = true
+	result.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		result.Error = fmt.Sprintf("WA server rejected the %s credentials (%d)", result.Check, resp.StatusCode)
+	case resp.StatusCode >= 500:
+		result.Error = fmt.Sprintf("WA server error (%d)", resp.StatusCode)
+	case resp.StatusCode >= 400:
+		result.Error = fmt.Sprintf("unexpected WA server response (%d) - check WA_SERVER_URL", resp.StatusCode)
+	default:
+		result.Authenticated = true
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WASendResult is the real outcome of a send through the WA server. The WA server can answer
+// 200 with {"success":false,"error":"not on whatsapp"}, so the body decides, not only the status.
+type WASendResult struct {
+	OK        bool
+	MessageID string
+	Error     string // why the send failed ("" when OK)
+}
+
+// ParseWASendResponse reads the WA server (or gateway) response of a send. A JSON body with a
+// "success" flag, an "error" or a "code" >= 400 decides the outcome; bodies without any of
+// them (unknown or not JSON) fall back to the HTTP status.
+func ParseWASendResponse(statusCode int, body []byte) WASendResult {
+	statusOK := statusCode >= 200 && statusCode < 300
+
+	var parsed struct {
+		Success *bool           `json:"success"`
+		Code    json.Number     `json:"code"`
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+		Data    struct {
+			ID      string `json:"Id"`
+			Details string `json:"Details"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return statusResult(statusCode, statusOK, "")
+	}
+
+	result := WASendResult{MessageID: parsed.Data.ID}
+	errText := waErrorText(parsed.Error)
+	code, _ := parsed.Code.Int64()
+
+	switch {
+	case parsed.Success != nil:
+		result.OK = *parsed.Success && statusOK
+	case errText != "" || code >= 400:
+		result.OK = false
+	default:
+		return statusResult(statusCode, statusOK, parsed.Data.ID)
+	}
+	if result.OK {
+		return result
+	}
+
+	switch {
+	case errText != "":
+		result.Error = errText
+	case parsed.Message != "":
+		result.Error = parsed.Message
+	case code >= 400:
+		result.Error = fmt.Sprintf("WA server returned code %d", code)
+	case !statusOK:
+		result.Error = fmt.Sprintf("WA server returned %d", statusCode)
+	default:
+		result.Error = "WA server reported the send as failed"
+	}
+	return result
+}
+
+// statusResult is the outcome when the body says nothing about it
+func statusResult(statusCode int, statusOK bool, messageID string) WASendResult {
+	if statusOK {
+		return WASendResult{OK: true, MessageID: messageID}
+	}
+	return WASendResult{Error: fmt.Sprintf("WA server returned %d", statusCode)}
+}
+
+// waErrorText turns the "error" field (a string, or an object with a message) into text
+func waErrorText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+	var obj struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil && obj.Message != "" {
+		return obj.Message
+	}
+	if string(raw) == "false" {
+		return ""
+	}
+	return string(raw)
+}
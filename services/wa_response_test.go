@@ -0,0 +1,31 @@
+package services
+
+import "testing"
+
+func TestParseWASendResponse(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		ok      bool
+		id, err string
+	}{
+		{"sent", 200, `{"code":200,"success":true,"data":{"Id":"3EB0ABC","Details":"Sent"}}`, true, "3EB0ABC", ""},
+		{"200 but failed", 200, `{"code":500,"success":false,"error":"not on whatsapp"}`, false, "", "not on whatsapp"},
+		{"failed with message", 200, `{"success":false,"message":"session not ready"}`, false, "", "session not ready"},
+		{"failed without reason", 200, `{"success":false}`, false, "", "WA server reported the send as failed"},
+		{"error object", 200, `{"error":{"message":"invalid jid"}}`, false, "", "invalid jid"},
+		{"code only", 200, `{"code":400,"data":{}}`, false, "", "WA server returned code 400"},
+		{"success on 5xx", 502, `{"success":true}`, false, "", "WA server returned 502"},
+		{"unknown JSON on 200", 200, `{"status":"queued","data":{"Id":"X1"}}`, true, "X1", ""},
+		{"not JSON on 200", 200, `OK`, true, "", ""},
+		{"not JSON on 500", 500, `<html>Internal Server Error</html>`, false, "", "WA server returned 500"},
+		{"empty on 200", 200, ``, true, "", ""},
+	}
+	for _, tc := range cases {
+		got := ParseWASendResponse(tc.status, []byte(tc.body))
+		if got.OK != tc.ok || got.MessageID != tc.id || got.Error != tc.err {
+			t.Errorf("%s: got %+v, want ok=%v id=%q err=%q", tc.name, got, tc.ok, tc.id, tc.err)
+		}
+	}
+}
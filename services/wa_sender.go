@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -17,14 +18,19 @@ type SendTextRequest struct {
 }
 
 // SendWAText sends text message via internal Gateway (reuses existing validation & tracking)
+// and returns the WhatsApp message ID assigned by the WA Server ("" if not reported).
 // Gateway akan handle:
 // - Validasi token & subscription
 // - Track message stats ke DB Transactional
 // - Proxy ke WA Server (port 8080)
-func SendWAText(sessionToken, to, text string) error {
+func SendWAText(sessionToken, to, text string) (string, error) {
 	// Clean text: remove leading newlines to avoid double spacing in WhatsApp
 	text = strings.TrimLeft(text, "\n")
 
+	if DryRun() {
+		return RecordDryRunSend(sessionToken, to, "chat/send/text", text), nil
+	}
+
 	// Call internal gateway endpoint (localhost:8070/wa/chat/send/text)
 	// Gateway sudah handle semua validasi dan tracking
 	url := "http://localhost:8070/wa/chat/send/text"
@@ -37,28 +43,31 @@ func SendWAText(sessionToken, to, text string) error {
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers (gateway needs token for validation)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("token", sessionToken)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := DoHTTP(req, 10*time.Second)
 	if err != nil {
-		return fmt.Errorf("failed to send WA message: %w", err)
+		return "", fmt.Errorf("failed to send WA message: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("gateway returned %d", resp.StatusCode)
+	// 200 with {"success":false} is a failed send too. Message ID is optional - a send
+	// without it is still a successful send
+	body, _ := io.ReadAll(resp.Body)
+	result := ParseWASendResponse(resp.StatusCode, body)
+	if !result.OK {
+		return "", fmt.Errorf("gateway send failed: %s", result.Error)
 	}
 
-	return nil
+	return result.MessageID, nil
 }
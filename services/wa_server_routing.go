@@ -0,0 +1,112 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+
+	"gorm.io/gorm"
+)
+
+// waServerRouteTTL returns WA_SERVER_ROUTE_CACHE_TTL_SECONDS: how long a session's WA server is
+// cached before the transactional DB is asked again (default 60; <= 0 uses the default)
+func waServerRouteTTL() time.Duration {
+	secs := config.GetEnvInt("WA_SERVER_ROUTE_CACHE_TTL_SECONDS", 60)
+	if secs <= 0 {
+		secs = 60
+	}
+	return time.Duration(secs) * time.Second
+}
+
+type waServerRoute struct {
+	baseURL   string // "" = no mapping, the global WA server
+	expiresAt time.Time
+}
+
+var (
+	waServerRoutesMu sync.Mutex
+	waServerRoutes   = make(map[string]waServerRoute)
+)
+
+var (
+	waServerColumnMu      sync.Mutex
+	waServerColumnPresent bool
+	waServerColumnChecked time.Time
+)
+
+// waServerColumnAvailable reports whether WhatsAppSession has the optional waServerUrl column yet.
+// Re-checked every route TTL so a schema migrated while running is picked up; a failed check
+// counts as missing (global WA server) and is retried on the next lookup.
+func waServerColumnAvailable(db *gorm.DB) bool {
+	waServerColumnMu.Lock()
+	defer waServerColumnMu.Unlock()
+	if !waServerColumnChecked.IsZero() && time.Since(waServerColumnChecked) < waServerRouteTTL() {
+		return waServerColumnPresent
+	}
+
+	missing, err := database.MissingColumns(db, models.WhatsappSession{}.TableName(), []string{models.WhatsappSessionColWAServerURL})
+	if err != nil {
+		return false
+	}
+	waServerColumnPresent = len(missing) == 0
+	waServerColumnChecked = time.Now()
+	return waServerColumnPresent
+}
+
+// lookupSessionWAServer reads the session's WA server from WhatsAppSession.waServerUrl
+// ("" when unset or the column isn't migrated yet; a var so tests can stub it)
+var lookupSessionWAServer = func(sessionToken string) (string, error) {
+	db := database.GetTransactionalDB()
+	if db == nil || !waServerColumnAvailable(db) {
+		return "", nil
+	}
+	var session models.WhatsappSession
+	err := db.Select(models.WhatsappSessionColWAServerURL).
+		Where(map[string]interface{}{models.WhatsappSessionColToken: sessionToken}).
+		Limit(1).Find(&session).Error
+	if err != nil || session.WAServerURL == nil {
+		return "", err
+	}
+	return strings.TrimRight(strings.TrimSpace(*session.WAServerURL), "/"), nil
+}
+
+// WAServerURLForSession returns the base URL of the WA server hosting the session: its
+// WhatsAppSession.waServerUrl when set, else the global WAServerURL. Lookups are cached for
+// WA_SERVER_ROUTE_CACHE_TTL_SECONDS; a failed lookup falls back to the global URL uncached.
+func WAServerURLForSession(sessionToken string) string {
+	if sessionToken == "" {
+		return WAServerURL()
+	}
+
+	waServerRoutesMu.Lock()
+	route, ok := waServerRoutes[sessionToken]
+	waServerRoutesMu.Unlock()
+	if !ok || time.Now().After(route.expiresAt) {
+		baseURL, err := lookupSessionWAServer(sessionToken)
+		if err != nil {
+			log.Printf("⚠️  Failed to resolve WA server for session %s, using the default: %v", sessionToken, err)
+			return WAServerURL()
+		}
+		route = waServerRoute{baseURL: baseURL, expiresAt: time.Now().Add(waServerRouteTTL())}
+		waServerRoutesMu.Lock()
+		waServerRoutes[sessionToken] = route
+		waServerRoutesMu.Unlock()
+	}
+
+	if route.baseURL == "" {
+		return WAServerURL()
+	}
+	return route.baseURL
+}
+
+// InvalidateWAServerRoute drops the cached WA server of a session (e.g. after it moved)
+func InvalidateWAServerRoute(sessionToken string) {
+	waServerRoutesMu.Lock()
+	delete(waServerRoutes, sessionToken)
+	waServerRoutesMu.Unlock()
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+// stubWAServerLookup replaces the transactional DB lookup with routes and counts the calls
+func stubWAServerLookup(t *testing.T, routes map[string]string, err error) *int {
+	calls := 0
+	previous := lookupSessionWAServer
+	t.Cleanup(func() {
+		lookupSessionWAServer = previous
+		for token := range routes {
+			InvalidateWAServerRoute(token)
+		}
+	})
+	lookupSessionWAServer = func(sessionToken string) (string, error) {
+		calls++
+		return routes[sessionToken], err
+	}
+	return &calls
+}
+
+func TestWAServerURLForSession(t *testing.T) {
+	t.Setenv("WA_SERVER_URL", "http://wa-default:8080")
+	calls := stubWAServerLookup(t, map[string]string{"tok-a": "http://wa-2:8080", "tok-b": ""}, nil)
+
+	if got := WAServerURLForSession("tok-a"); got != "http://wa-2:8080" {
+		t.Errorf("mapped session = %q, want its own WA server", got)
+	}
+	if got := WAServerURLForSession("tok-b"); got != "http://wa-default:8080" {
+		t.Errorf("unmapped session = %q, want the global WA server", got)
+	}
+	if got := WAServerURLForSession(""); got != "http://wa-default:8080" {
+		t.Errorf("no session = %q, want the global WA server", got)
+	}
+
+	// Cached within the TTL, unmapped sessions included
+	WAServerURLForSession("tok-a")
+	WAServerURLForSession("tok-b")
+	if *calls != 2 {
+		t.Errorf("lookups = %d, want 2 (one per session)", *calls)
+	}
+
+	InvalidateWAServerRoute("tok-a")
+	WAServerURLForSession("tok-a")
+	if *calls != 3 {
+		t.Errorf("lookups after invalidation = %d, want 3", *calls)
+	}
+}
+
+func TestWAServerURLForSessionLookupError(t *testing.T) {
+	t.Setenv("WA_SERVER_URL", "http://wa-default:8080")
+	calls := stubWAServerLookup(t, map[string]string{"tok-c": "http://wa-3:8080"}, errors.New("db down"))
+
+	if got := WAServerURLForSession("tok-c"); got != "http://wa-default:8080" {
+		t.Errorf("failed lookup = %q, want the global WA server", got)
+	}
+	WAServerURLForSession("tok-c")
+	if *calls != 2 {
+		t.Errorf("lookups = %d, want 2 (failures are not cached)", *calls)
+	}
+}
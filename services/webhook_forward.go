@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"genfity-wa-support/config"
+)
+
+// Defaults for forwarding incoming messages to the customer's webhook (override via .env)
+const (
+	defaultForwardMaxAttempts    = 3
+	defaultForwardRetryBackoffMs = 1000
+	forwardTimeout               = 10 * time.Second
+	forwardDedupeWindow          = 10 * time.Minute // WA server redeliveries of a message are forwarded once
+)
+
+// Headers sent with every forwarded message
+const (
+	ForwardHeaderTimestamp = "X-Genfity-Timestamp" // unix seconds, part of the signed content
+	ForwardHeaderSignature = "X-Genfity-Signature" // "sha256=" + hex HMAC of "<timestamp>.<body>" with the forward secret
+	ForwardHeaderMessageID = "X-Genfity-Message-Id"
+)
+
+// ForwardedMessage is the normalized incoming message POSTed to the session's forward URL
+type ForwardedMessage struct {
+	Event           string    `json:"event"` // always "message"
+	MessageID       string    `json:"messageId"`
+	From            string    `json:"from"` // phone number
+	Chat            string    `json:"chat"`
+	PushName        string    `json:"pushName,omitempty"`
+	Type            string    `json:"type"` // text, image, reaction, ...
+	Body            string    `json:"body"`
+	QuotedMessageID string    `json:"quotedMessageId,omitempty"`
+	QuotedBody      string    `json:"quotedBody,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+var (
+	forwardedMu sync.Mutex
+	forwarded   = make(map[string]time.Time) // message ID -> first forwarded
+)
+
+// forwardOnce reports whether messageID was not forwarded within forwardDedupeWindow, and marks it
+func forwardOnce(messageID string) bool {
+	forwardedMu.Lock()
+	defer forwardedMu.Unlock()
+
+	now := time.Now()
+	for id, at := range forwarded {
+		if now.Sub(at) > forwardDedupeWindow {
+			delete(forwarded, id)
+		}
+	}
+	if _, seen := forwarded[messageID]; seen {
+		return false
+	}
+	forwarded[messageID] = now
+	return true
+}
+
+// SignForwardPayload returns the signature header value for body sent at timestamp
+func SignForwardPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ForwardIncomingMessage POSTs msg to the session's forward URL (SessionInfo.ForwardURL) in the
+// background, independent of AI processing: it never blocks or fails the webhook. Connection
+// errors, 429 and 5xx are retried with exponential backoff (WEBHOOK_FORWARD_MAX_ATTEMPTS /
+// WEBHOOK_FORWARD_RETRY_BACKOFF_MS); failures are only logged.
+func ForwardIncomingMessage(info *SessionInfo, msg ForwardedMessage) {
+	if info == nil || info.ForwardURL == "" || !forwardOnce(msg.MessageID) {
+		return
+	}
+	msg.Event = "message"
+	go func() {
+		if err := deliverForward(info.ForwardURL, info.ForwardSecret, msg); err != nil {
+			log.Printf("⚠️  Webhook forward of message %s failed: %v", msg.MessageID, err)
+		}
+	}()
+}
+
+// deliverForward sends the message with retries; returns the last error
+func deliverForward(url, secret string, msg ForwardedMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	maxAttempts := config.GetEnvInt("WEBHOOK_FORWARD_MAX_ATTEMPTS", defaultForwardMaxAttempts)
+	if maxAttempts <= 0 {
+		maxAttempts = defaultForwardMaxAttempts
+	}
+	backoffMs := config.GetEnvInt("WEBHOOK_FORWARD_RETRY_BACKOFF_MS", defaultForwardRetryBackoffMs)
+	if backoffMs < 0 {
+		backoffMs = defaultForwardRetryBackoffMs
+	}
+	backoff := time.Duration(backoffMs) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		retry, err := postForward(url, secret, msg.MessageID, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= maxAttempts {
+			return fmt.Errorf("attempt %d/%d: %w", attempt, maxAttempts, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postForward makes one signed POST and reports whether a failure is worth retrying
+func postForward(url, secret, messageID string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("invalid forward URL: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ForwardHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(ForwardHeaderMessageID, messageID)
+	if secret != "" {
+		req.Header.Set(ForwardHeaderSignature, SignForwardPayload(secret, timestamp, body))
+	}
+
+	resp, err := DoHTTP(req, forwardTimeout)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("forward URL returned %d", resp.StatusCode)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeliverForwardSignsAndRetries(t *testing.T) {
+	t.Setenv("WEBHOOK_FORWARD_RETRY_BACKOFF_MS", "1")
+	var calls int32
+	var got ForwardedMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(ForwardHeaderTimestamp), 10, 64)
+		if r.Header.Get(ForwardHeaderSignature) != SignForwardPayload("rahasia", timestamp, body) {
+			t.Errorf("signature %q does not verify", r.Header.Get(ForwardHeaderSignature))
+		}
+		if r.Header.Get(ForwardHeaderMessageID) != "3EB0A" {
+			t.Errorf("message ID header = %q", r.Header.Get(ForwardHeaderMessageID))
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer server.Close()
+
+	msg := ForwardedMessage{Event: "message", MessageID: "3EB0A", From: "6281234567890", Type: "text", Body: "halo", Timestamp: time.Now()}
+	if err := deliverForward(server.URL, "rahasia", msg); err != nil {
+		t.Fatalf("deliverForward: %v", err)
+	}
+	if calls != 2 || got.Body != "halo" || got.From != "6281234567890" {
+		t.Errorf("calls = %d, received %+v; want a retry after the 502 and the message delivered", calls, got)
+	}
+}
+
+func TestDeliverForwardClientErrorNotRetried(t *testing.T) {
+	t.Setenv("WEBHOOK_FORWARD_RETRY_BACKOFF_MS", "1")
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if err := deliverForward(server.URL, "", ForwardedMessage{MessageID: "3EB0B"}); err == nil {
+		t.Error("a 400 should fail the forward")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (4xx is not retried)", calls)
+	}
+}
+
+func TestForwardOnce(t *testing.T) {
+	if !forwardOnce("fwd-dedupe-1") {
+		t.Fatal("first delivery should be forwarded")
+	}
+	if forwardOnce("fwd-dedupe-1") {
+		t.Error("a redelivered message should not be forwarded again")
+	}
+}
@@ -3,14 +3,18 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"genfity-wa-support/config"
 	"genfity-wa-support/database"
+	"genfity-wa-support/logger"
 	"genfity-wa-support/models"
 	"genfity-wa-support/services"
 
@@ -21,13 +25,19 @@ import (
 // Global circuit breaker for AI providers
 var aiProviderCB = services.NewCircuitBreaker("ai_provider", 5, 60*time.Second)
 
-// AIWorker processes AI jobs from queue
+// AIWorker processes AI jobs from queue with a pool of concurrent workers
 type AIWorker struct {
-	aiProvider services.AIProvider
-	db         *gorm.DB
-	listener   *pq.Listener
-	shutdown   chan struct{}
-	wg         sync.WaitGroup
+	aiProvider  services.AIProvider
+	db          *gorm.DB
+	listener    *pq.Listener
+	shutdown    chan struct{}
+	wg          sync.WaitGroup
+	concurrency int           // number of job goroutines (AI_WORKER_CONCURRENCY)
+	wake        chan struct{} // poll tick / NOTIFY -> wake idle job goroutines
+
+	// claimJob / runJob default to claimNextJob / processJob (swappable in tests)
+	claimJob func() (*models.AIJob, bool)
+	runJob   func(*models.AIJob)
 }
 
 // NewAIWorker creates new AI worker instance
@@ -38,41 +48,121 @@ func NewAIWorker() (*AIWorker, error) {
 		return nil, fmt.Errorf("failed to initialize AI provider: %w", err)
 	}
 
-	return &AIWorker{
-		aiProvider: aiProvider,
-		db:         database.GetDB(),
-		shutdown:   make(chan struct{}),
-	}, nil
+	concurrency := config.GetEnvInt("AI_WORKER_CONCURRENCY", 1)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	w := &AIWorker{
+		aiProvider:  aiProvider,
+		db:          database.GetDB(),
+		shutdown:    make(chan struct{}),
+		concurrency: concurrency,
+		wake:        make(chan struct{}, concurrency),
+	}
+	w.claimJob = w.claimNextJob
+	w.runJob = w.processJob
+	return w, nil
 }
 
 // Start begins the AI worker loop
 func (w *AIWorker) Start() {
-	log.Println("🤖 AI Worker started")
+	log.Printf("🤖 AI Worker started (concurrency: %d)", w.concurrency)
+
+	// Job goroutines - each claims jobs with FOR UPDATE SKIP LOCKED
+	w.startJobWorkers()
 
 	// Setup LISTEN for real-time notifications
 	w.wg.Add(1)
 	go w.listenForJobs()
 
-	// Fallback polling (every 2 seconds if no notifications)
-	ticker := time.NewTicker(2 * time.Second)
+	// Track queue depth for backpressure + health checks
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		services.MonitorQueueDepth(w.shutdown)
+	}()
+
+	// Raw webhook payload retention (no-op table cleanup when storage is disabled)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		services.RunRawWebhookRetention(w.shutdown)
+	}()
+
+	// Prompt dump retention (DEBUG_DUMP_PROMPT)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		services.RunPromptDumpRetention(w.shutdown)
+	}()
+
+	// Age-based retention of AI chat messages, send logs and finished jobs
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		services.RunDataRetention(w.shutdown)
+	}()
+
+	// Retry read receipts the WA Server never confirmed
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		services.RunReadReconciler(w.shutdown)
+	}()
+
+	// Fallback polling (AI_POLL_INTERVAL_MS, default 2 seconds)
+	pollInterval := config.AIPollInterval()
+	log.Printf("⏱️  AI Worker polling every %v", pollInterval)
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-w.shutdown:
 			log.Println("🛑 AI Worker shutting down...")
-			w.wg.Wait() // Wait for listener to finish
+			w.wg.Wait() // Wait for listener + in-flight jobs to finish
 			log.Println("✅ AI Worker stopped")
 			return
 		case <-ticker.C:
-			w.processJobs()
+			w.wakeWorkers()
 		}
 	}
 }
 
-// Stop signals worker to shutdown gracefully
+// Stop signals worker to shutdown and waits until in-flight jobs are drained
 func (w *AIWorker) Stop() {
 	close(w.shutdown)
+	w.wg.Wait()
+}
+
+// startJobWorkers launches the pool of job goroutines
+func (w *AIWorker) startJobWorkers() {
+	for i := 0; i < w.concurrency; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for {
+				select {
+				case <-w.shutdown:
+					return
+				case <-w.wake:
+					w.processJobs()
+				}
+			}
+		}()
+	}
+}
+
+// wakeWorkers wakes up to `concurrency` idle job goroutines (non-blocking)
+func (w *AIWorker) wakeWorkers() {
+	for i := 0; i < w.concurrency; i++ {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+			return // all goroutines already have a pending wake-up
+		}
+	}
 }
 
 // listenForJobs sets up PostgreSQL LISTEN for job notifications with auto-reconnect
@@ -89,15 +179,19 @@ func (w *AIWorker) listenForJobs() {
 
 	// Callback untuk handle connection events (reconnection)
 	// Cloud PostgreSQL (Prisma Cloud) aggressively closes LISTEN connections
-	// This is expected behavior - polling fallback (2s) ensures jobs are processed
+	// This is expected behavior - polling fallback (AI_POLL_INTERVAL_MS) ensures jobs are processed
 	eventCallback := func(ev pq.ListenerEventType, err error) {
 		switch ev {
 		case pq.ListenerEventConnected:
+			services.MarkListenerConnected()
 			log.Println("✅ [LISTEN] Connected - instant notifications enabled")
 		case pq.ListenerEventDisconnected:
-			// Silent - cloud DB will disconnect frequently, polling handles it
+			// Silent - cloud DB will disconnect frequently, polling handles it.
+			// The health check flags it only when it stays down (AI_LISTEN_MAX_DISCONNECTED_SECONDS)
+			services.MarkListenerDisconnected()
 			log.Println("ℹ️  [LISTEN] Disconnected (polling fallback active)")
 		case pq.ListenerEventReconnected:
+			services.MarkListenerConnected()
 			log.Println("✅ [LISTEN] Reconnected")
 		case pq.ListenerEventConnectionAttemptFailed:
 			// Only log non-connection errors - connection failures are expected on cloud DB
@@ -108,83 +202,153 @@ func (w *AIWorker) listenForJobs() {
 		}
 	}
 
+	services.MarkListenerStarted()
+
 	// Create listener with auto-reconnect:
 	// - minReconnectInterval: 10s (wait 10s before first reconnect attempt)
 	// - maxReconnectInterval: 1min (max wait between reconnect attempts)
 	listener := pq.NewListener(connStr, 10*time.Second, time.Minute, eventCallback)
 
-	err := listener.Listen("ai_jobs_channel")
+	channel := config.AIJobsChannel()
+	err := listener.Listen(channel)
 	if err != nil {
-		log.Fatalf("Failed to listen on ai_jobs_channel: %v", err)
+		log.Fatalf("Failed to listen on %s: %v", channel, err)
 	}
 	defer listener.Close()
 
-	log.Println("👂 Listening for AI job notifications on ai_jobs_channel...")
+	log.Printf("👂 Listening for AI job notifications on %s...", channel)
 
 	// Keepalive ticker - ping every 60 seconds
 	keepaliveTicker := time.NewTicker(60 * time.Second)
 	defer keepaliveTicker.Stop()
 
+	w.dispatchNotifications(listener.Notify, keepaliveTicker.C, func() {
+		_ = listener.Ping() // Silent - ping failures are expected on cloud DB
+	})
+}
+
+// dispatchNotifications turns NOTIFY events into wake-ups until shutdown.
+// It never processes jobs itself: wakeWorkers is non-blocking and coalesces bursts into at most
+// one pending wake-up per job goroutine, so the listener keeps receiving notifications and
+// keepalives while a long drain runs. A goroutine that is busy draining picks up its pending
+// wake-up afterwards and re-checks the queue, so jobs inserted mid-drain are not missed.
+func (w *AIWorker) dispatchNotifications(notify <-chan *pq.Notification, keepalive <-chan time.Time, ping func()) {
 	for {
 		select {
 		case <-w.shutdown:
 			log.Println("🔕 Stopping job listener...")
 			return
 
-		case notification := <-listener.Notify:
+		case notification := <-notify:
 			if notification != nil {
+				services.MarkListenerNotification()
 				log.Println("⚡ [LISTEN] Instant notification - processing jobs")
-				w.processJobs()
+			} else {
+				// nil = connection was lost and re-established (pq.Listener reconnects automatically);
+				// NOTIFYs sent while disconnected are gone, so check the queue now instead of at the next poll
+				log.Println("🔄 [LISTEN] Reconnected - checking queue for missed jobs")
 			}
-			// notification == nil means connection was lost and reconnected
-			// pq.Listener will handle reconnection automatically
+			w.wakeWorkers()
 
-		case <-keepaliveTicker.C:
+		case <-keepalive:
 			// Send ping to keep connection alive (cloud DB will still disconnect)
-			go func() {
-				_ = listener.Ping() // Silent - ping failures are expected on cloud DB
-			}()
+			go ping()
+			services.CheckListenerHealth()
 		}
 	}
 }
 
-// processJobs fetches and processes pending jobs with row locking
+// processJobs fetches and processes pending jobs with row locking until the queue is empty
+// or shutdown is requested (the in-flight job always finishes)
 func (w *AIWorker) processJobs() {
 	for {
-		// Lock & fetch one job (FOR UPDATE SKIP LOCKED prevents race conditions)
-		var job models.AIJob
-		tx := w.db.Begin()
-
-		err := tx.Raw(`
-			SELECT * FROM ai_jobs
-			WHERE status = 'pending'
-			AND (next_run_at IS NULL OR next_run_at <= NOW())
-			ORDER BY priority ASC, id ASC
-			FOR UPDATE SKIP LOCKED
-			LIMIT 1
-		`).Scan(&job).Error
-
-		if err != nil || job.ID == 0 {
-			tx.Rollback()
-			return // No jobs available
+		select {
+		case <-w.shutdown:
+			return
+		default:
 		}
 
-		// Update status to processing
-		tx.Model(&job).Updates(map[string]interface{}{
-			"status":     "processing",
-			"attempts":   job.Attempts + 1,
-			"updated_at": time.Now(),
-		})
-		tx.Commit()
+		job, ok := w.claimJob()
+		if !ok {
+			return // No jobs available
+		}
 
 		// Process the job (blocking)
-		w.processJob(&job)
+		w.runJob(job)
+	}
+}
+
+// conversationLockTimeout returns AI_CONVERSATION_LOCK_TIMEOUT_SECONDS: a processing job older than this
+// (worker crashed mid-job) stops holding back the rest of its conversation
+func conversationLockTimeout() time.Duration {
+	seconds := config.GetEnvInt("AI_CONVERSATION_LOCK_TIMEOUT_SECONDS", 600)
+	if seconds <= 0 {
+		seconds = 600
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// conversationOrderClause keeps one job per (session, contact) in flight, oldest first:
+// a job is claimable only when its conversation has no processing job and no older pending one
+// (including an older job waiting for its retry). Status-based rather than a pg advisory lock -
+// an xact lock ends with the claim transaction and a session lock would pin a pooled connection
+// for the whole LLM call. Jobs without a contact (enqueued before the column existed) are not held back.
+const conversationOrderClause = `
+		AND (contact = '' OR (
+			NOT EXISTS (
+				SELECT 1 FROM ai_jobs p
+				WHERE p.session_tok = ai_jobs.session_tok AND p.contact = ai_jobs.contact
+				AND p.status = 'processing' AND p.updated_at > ?
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM ai_jobs e
+				WHERE e.session_tok = ai_jobs.session_tok AND e.contact = ai_jobs.contact
+				AND e.status = 'pending' AND e.id < ai_jobs.id
+			)
+		))`
+
+// claimNextJob locks the next due pending job (lowest priority number first) and marks it processing.
+// With AI_SERIALIZE_CONVERSATIONS (default true) a contact's messages are answered one at a time, in order.
+func (w *AIWorker) claimNextJob() (*models.AIJob, bool) {
+	query := `
+		SELECT * FROM ai_jobs
+		WHERE status = 'pending'
+		AND (next_run_at IS NULL OR next_run_at <= NOW())`
+	var args []interface{}
+	if config.GetEnvBool("AI_SERIALIZE_CONVERSATIONS", true) {
+		query += conversationOrderClause
+		args = append(args, time.Now().Add(-conversationLockTimeout()))
+	}
+	query += `
+		ORDER BY priority ASC, id ASC
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1`
+
+	// Lock & fetch one job (FOR UPDATE SKIP LOCKED prevents race conditions)
+	var job models.AIJob
+	tx := w.db.Begin()
+
+	err := tx.Raw(query, args...).Scan(&job).Error
+
+	if err != nil || job.ID == 0 {
+		tx.Rollback()
+		return nil, false
 	}
+
+	// Update status to processing
+	tx.Model(&job).Updates(map[string]interface{}{
+		"status":     "processing",
+		"attempts":   job.Attempts + 1,
+		"updated_at": time.Now(),
+	})
+	tx.Commit()
+
+	return &job, true
 }
 
 // processJob executes single AI job
 func (w *AIWorker) processJob(job *models.AIJob) {
-	log.Printf("⚙️  Processing job #%d (message: %s, attempt: %d)", job.ID, job.MessageID, job.Attempts)
+	jobLogger(job).Info("processing job", "attempt", job.Attempts)
 
 	start := time.Now()
 
@@ -204,77 +368,114 @@ func (w *AIWorker) processJob(job *models.AIJob) {
 		return
 	}
 
-	// ASYNC: Auto-read ALL unread messages for this contact (AI bot feature - always enabled)
-	go func(sessionToken, senderPhone string) {
-		// Get all unread incoming messages for this session+sender
-		unreadMessages, err := services.GetUnreadIncomingMessages(sessionToken, senderPhone)
-		if err != nil {
-			log.Printf("⚠️  [AI Worker] Failed to get unread messages: %v", err)
-			return
-		}
-
-		if len(unreadMessages) == 0 {
-			return // No unread messages
-		}
-
-		// Extract message IDs
-		messageIDs := make([]string, len(unreadMessages))
-		for i, msg := range unreadMessages {
-			messageIDs[i] = msg.MessageID
-		}
-
-		// Clean phone number (remove @s.whatsapp.net suffix)
-		phoneNumber := strings.TrimSuffix(senderPhone, "@s.whatsapp.net")
-
-		log.Printf("📖 [AI Bot] Auto-reading %d unread messages for contact %s", len(messageIDs), phoneNumber)
+	// A human agent took the conversation over after this job was queued - leave it to them
+	if services.IsInHandoff(job.SessionTok, chatMsg.From) {
+		log.Printf("🙋 Job #%d: conversation with %s handed off to a human - no AI reply", job.ID, chatMsg.From)
+		w.completeJob(job, &attempt, map[string]interface{}{"suppressed": "handoff"})
+		return
+	}
 
-		// Call WA Server to mark as read
-		if err := services.MarkMessagesAsRead(sessionToken, messageIDs, phoneNumber); err != nil {
-			log.Printf("⚠️  [AI Bot] Failed to mark messages as read via WA Server: %v", err)
-			// Continue even if markread fails
-		}
+	// Processing deadline: tell the customer we're still on it when the job is slow (AI_PROCESSING_DEADLINE_MS).
+	// In abandon mode the deadline also cancels jobCtx, which every LLM call below derives from.
+	jobCtx, cancelJob := context.WithCancel(logger.WithRequestID(context.Background(), job.RequestID))
+	defer cancelJob()
+	abandonOnDeadline := services.ProcessingDeadlineAction() == services.DeadlineActionAbandon
+	deadline := services.WatchProcessingDeadline(services.ProcessingDeadline(), func() {
+		w.onProcessingDeadline(job, &chatMsg, abandonOnDeadline, cancelJob)
+	})
+	defer deadline.Stop()
 
-		// Update DB
-		if err := services.MarkMessagesAsReadInDB(messageIDs); err != nil {
-			log.Printf("⚠️  [AI Bot] Failed to mark messages as read in DB: %v", err)
-		}
-	}(job.SessionTok, chatMsg.From)
+	// 0. First contact: the greeting claimed by the webhook goes out before the reply, so the
+	// context built below already contains it
+	if err := services.SendPendingGreeting(job.SessionTok, chatMsg.To, chatMsg.From, services.JobMessageIDs(job)); err != nil {
+		log.Printf("⚠️  Job #%d: %v", job.ID, err)
+	}
 
 	// 1. Build context (fetch bot settings + chat history)
 	maxMessages := 10
-	ctx, err := services.BuildContextWithLimit(job.UserID, job.SessionTok, job.MessageID, maxMessages)
+	ctx, err := services.BuildContextForMessages(job.UserID, job.SessionTok, services.JobMessageIDs(job), maxMessages)
 	if err != nil {
 		w.failJob(job, &attempt, fmt.Sprintf("Context build failed: %v", err))
 		return
 	}
 
+	// ASYNC: Auto-read ALL unread messages for this contact, unless the bot has autoRead: false
+	go services.AutoReadContactMessages(ctx.Settings, job.SessionTok, chatMsg.From)
+
+	// 1a. Empty knowledge base (misconfigured bot / inactive docs): in strict mode a pricing question
+	// gets the fallback reply instead of a price the LLM would have to make up.
+	// Bots with the knowledge base switched off are empty on purpose.
+	if services.KnowledgeBaseEnabled(ctx.Settings) && services.KnowledgeBaseEmpty(ctx.Settings) {
+		services.WarnEmptyKnowledgeBase(job.UserID)
+		if services.ShouldDeclineWithoutKB(ctx.Settings, ctx.UserMessage) {
+			log.Printf("🚫 Job #%d: pricing question without knowledge base - sending fallback (strict mode)", job.ID)
+			w.deliverReply(job, &attempt, &chatMsg, ctx.Settings, services.EmptyKBReply(ctx.Settings), 0, 0, start)
+			return
+		}
+	}
+
+	// 1b. Moderation pre-check (AI_MODERATION): a flagged message gets the canned reply without a
+	// chat completion. Fails open - a moderation outage doesn't stop the bot.
+	if services.ModerationEnabled() {
+		result, err := services.CheckModeration(jobCtx, ctx.UserMessage)
+		if err != nil {
+			log.Printf("⚠️  Job #%d: moderation check failed, answering anyway: %v", job.ID, err)
+		} else if result.Flagged {
+			jobLogger(job).Warn("message flagged by moderation", "categories", strings.Join(result.Categories, ","))
+			w.deliverReply(job, &attempt, &chatMsg, ctx.Settings, services.ModerationReply(), 0, 0, start)
+			return
+		}
+	}
+
 	// Log system prompt preview for debugging
-	log.Printf("🤖 System prompt to LLM (first 400 chars): %s...", ctx.SystemPrompt[:min(400, len(ctx.SystemPrompt))])
+	log.Printf("🤖 System prompt to LLM (first 400 chars): %s", services.PreviewText(ctx.SystemPrompt, 400))
 	log.Printf("💬 User message to LLM: %s", ctx.UserMessage)
+	services.DumpPrompt(job.ID, job.SessionTok, job.MessageID, maxMessages, ctx)
+	services.RecordContextDecision(job.ID, job.SessionTok, job.MessageID, maxMessages, ctx)
 
 	// AI BOT: Show typing indicator BEFORE calling LLM (always enabled for AI)
-	phoneNumber := strings.TrimSuffix(chatMsg.From, "@s.whatsapp.net")
+	phoneNumber := services.NormalizePhone(chatMsg.From)
 	if err := services.SetTypingState(job.SessionTok, phoneNumber, "composing"); err != nil {
 		log.Printf("⚠️  [AI Bot] Failed to set typing state to composing: %v", err)
 		// Continue even if typing indicator fails
 	}
 
+	// Slow LLM: optional interim text (AI_INTERIM_MESSAGE), not on top of a deferral message already sent
+	interim := services.WatchProcessingDeadline(services.InterimMessageDelay(), func() {
+		if !deadline.Fired() {
+			w.sendInterimMessage(job, &chatMsg)
+		}
+	})
+	defer interim.Stop()
+
 	// 2. Call LLM with timeout and circuit breaker
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	timeoutCtx, cancel := context.WithTimeout(jobCtx, services.AITimeout())
 	defer cancel()
 
 	var response string
 	var inTok, outTok int
 
-	// Log provider being used
-	log.Printf("🤖 Using AI provider: %s (model: %s)", w.aiProvider.GetProviderName(), w.aiProvider.GetModelName())
-
-	// Use circuit breaker to prevent cascading failures
-	cbErr := aiProviderCB.Call(func() error {
+	// Use circuit breaker to prevent cascading failures (the customer's own key has its own breaker)
+	llm := w.llmForBot(ctx.Settings)
+	llmStart := time.Now()
+	cbErr := llm.Breaker.Call(func() error {
 		var llmErr error
-		response, inTok, outTok, llmErr = w.aiProvider.AskLLM(timeoutCtx, ctx.SystemPrompt, ctx.UserMessage)
+		response, inTok, outTok, llmErr = llm.Provider.AskLLM(timeoutCtx, ctx.SystemPrompt, ctx.UserMessage)
 		return llmErr
 	})
+	jobLogger(job).Info("llm call finished",
+		"provider", llm.Provider.GetProviderName(), "model", llm.Provider.GetModelName(),
+		"duration_ms", time.Since(llmStart).Milliseconds(), "input_tokens", inTok, "output_tokens", outTok,
+		"ok", cbErr == nil)
+
+	// Wait for an interim / deferral message in flight so it goes out before the reply / typing stop
+	interim.Stop()
+	deadlinePassed := deadline.Stop()
+	if deadlinePassed && abandonOnDeadline {
+		services.SetTypingState(job.SessionTok, phoneNumber, "stop")
+		w.permanentFailJob(job, &attempt, fmt.Sprintf("Abandoned: processing deadline of %v exceeded", services.ProcessingDeadline()))
+		return
+	}
 
 	if cbErr != nil {
 		// Stop typing indicator on error
@@ -285,65 +486,357 @@ func (w *AIWorker) processJob(job *models.AIJob) {
 		return
 	}
 
-	// Format response for WhatsApp (convert markdown to WhatsApp formatting)
-	formattedResponse := services.FormatForWhatsApp(response)
-	log.Printf("✨ Formatted response for WhatsApp (%d -> %d chars)", len(response), len(formattedResponse))
+	// Optional: regenerate/translate once if the reply is in the wrong language (bot's responseLanguage
+	// or AI_RESPONSE_LANGUAGE)
+	if expected := services.BotResponseLanguage(ctx.Settings); expected != "" {
+		var extraIn, extraOut int
+		response, extraIn, extraOut = services.EnforceResponseLanguage(timeoutCtx, llm.Provider, ctx.SystemPrompt, ctx.UserMessage, response, expected)
+		inTok += extraIn
+		outTok += extraOut
+	}
 
 	// AI BOT: Stop typing indicator AFTER LLM responds, BEFORE sending message
 	if err := services.SetTypingState(job.SessionTok, phoneNumber, "stop"); err != nil {
 		log.Printf("⚠️  [AI Bot] Failed to set typing state to stop: %v", err)
 	}
 
-	latency := time.Since(start).Milliseconds()
+	w.deliverReply(job, &attempt, &chatMsg, ctx.Settings, response, inTok, outTok, start)
+}
+
+// onProcessingDeadline sends the deferral message once the job runs past AI_PROCESSING_DEADLINE_MS.
+// Typing is stopped while the message goes out, then shown again if we keep waiting for the LLM.
+func (w *AIWorker) onProcessingDeadline(job *models.AIJob, chatMsg *models.AIChatMessage, abandon bool, cancelJob context.CancelFunc) {
+	log.Printf("⏰ Job #%d passed processing deadline (%v, action=%s)", job.ID, services.ProcessingDeadline(), services.ProcessingDeadlineAction())
+	if abandon {
+		cancelJob()
+	}
+
+	phoneNumber := services.NormalizePhone(chatMsg.From)
+	services.SetTypingState(job.SessionTok, phoneNumber, "stop")
+
+	// Once per contact per dedupe window - a retried job must not apologize again
+	message := services.ProcessingDeadlineMessage()
+	if !services.IsDuplicateReply(job.SessionTok, chatMsg.From, message) {
+		if err := services.SendFallbackReply(job.SessionTok, chatMsg.To, chatMsg.From, message); err != nil {
+			log.Printf("⚠️  Job #%d: failed to send deadline message: %v", job.ID, err)
+		}
+	}
 
-	// 3. Sender info already fetched earlier (chatMsg variable)
+	if !abandon {
+		services.SetTypingState(job.SessionTok, phoneNumber, "composing")
+	}
+}
+
+// sendInterimMessage tells the customer the answer is on its way while the LLM is slow. Sent once
+// per job (a retried job doesn't repeat it); typing is shown again afterwards.
+func (w *AIWorker) sendInterimMessage(job *models.AIJob, chatMsg *models.AIChatMessage) {
+	if services.JobPartSent(job.ID, services.InterimMessagePart) {
+		return
+	}
+	log.Printf("⏳ Job #%d: LLM still busy after %v - sending interim message", job.ID, services.InterimMessageDelay())
 
-	// 4. Send reply via WA (using internal gateway)
-	err = services.SendWAText(job.SessionTok, chatMsg.From, formattedResponse)
+	text := services.InterimMessageText()
+	waMessageID, err := services.SendInterimMessage(job.SessionTok, chatMsg.From, text)
+	w.recordSendLog(job, services.InterimMessagePart, chatMsg.From, text, waMessageID, err)
 	if err != nil {
-		w.failJob(job, &attempt, fmt.Sprintf("Failed to send WA message: %v", err))
+		log.Printf("⚠️  Job #%d: %v", job.ID, err)
+	}
+	services.SetTypingState(job.SessionTok, services.NormalizePhone(chatMsg.From), "composing")
+}
+
+// deliverReply formats and sends the LLM response, saves it to history and marks the job done
+func (w *AIWorker) deliverReply(job *models.AIJob, attempt *models.AIJobAttempt, chatMsg *models.AIChatMessage, botSettings *services.BotSettings, response string, inTok, outTok int, start time.Time) {
+	// Strip disclaimers / echoed prompt text before anything else sees the reply
+	response = services.SanitizeResponse(response)
+
+	// AI_HANDOFF_LLM: [HANDOFF] hands the conversation to a human; the rest of the reply (or the
+	// handoff message) is still sent, later messages get no AI reply
+	if services.LLMHandoffEnabled() {
+		if text, requested := services.ExtractHandoffRequest(response); requested {
+			response = text
+			if response == "" {
+				response = services.HandoffMessage()
+			}
+			w.startLLMHandoff(job, chatMsg, botSettings)
+		}
+	}
+
+	// Opt-in per bot: [SEND_IMAGE:url] sentinels are taken out of the text and sent as images after it
+	textResponse := response
+	var imageURLs []string
+	if botSettings != nil && botSettings.AllowImageSend {
+		textResponse, imageURLs = services.ExtractImageSends(response)
+	}
+	// Same for [SEND_LOCATION:...] / [SEND_CONTACT:...] cards (opt-in per kind)
+	var cards []services.ReplyCard
+	if botSettings != nil && (botSettings.AllowLocationSend || botSettings.AllowContactSend) {
+		textResponse, cards = services.ExtractReplyCards(textResponse, botSettings.AllowLocationSend, botSettings.AllowContactSend)
+	}
+
+	// Format response for WhatsApp (convert markdown to WhatsApp formatting)
+	formattedResponse := services.FormatForWhatsApp(textResponse)
+	log.Printf("✨ Formatted response for WhatsApp (%d -> %d chars)", len(response), len(formattedResponse))
+
+	latency := time.Since(start).Milliseconds()
+
+	// Approval mode: hold the reply for a human instead of sending it
+	if services.RequireReplyApproval() {
+		w.queueReplyForApproval(job, attempt, chatMsg, botSettings, response, formattedResponse, imageURLs, cards, inTok, outTok, latency)
 		return
 	}
 
-	// 4b. Save AI response to AI chat history (for context builder) AND permanent chat history
-	go func(formattedResp string) {
-		// Save to ai_chat_messages (for AI context) with FromMe=true, IsRead=true
-		// We don't have the actual message ID from WA server, so use a generated one
-		aiMsgID := fmt.Sprintf("ai_%s_%d", job.SessionTok, time.Now().UnixNano())
-		if err := services.SaveOutgoingMessageToAIChat(
-			job.SessionTok,
-			aiMsgID,
-			chatMsg.To,    // from (bot's JID)
-			chatMsg.From,  // to (recipient)
-			formattedResp, // body (formatted for WhatsApp)
-			time.Now(),    // timestamp
-		); err != nil {
-			log.Printf("⚠️  Failed to save AI response to AI chat messages: %v", err)
+	// Image / card-only answer - no text message
+	if (len(imageURLs) > 0 || len(cards) > 0) && strings.TrimSpace(formattedResponse) == "" {
+		imagesSent := w.sendReplyImages(job, chatMsg, botSettings, imageURLs)
+		cardsSent := w.sendReplyCards(job, chatMsg, botSettings, cards)
+		if imagesSent+cardsSent == 0 {
+			w.failJob(job, attempt, fmt.Sprintf("None of the %d requested images / %d cards could be sent", len(imageURLs), len(cards)))
+			return
 		}
+		w.completeJob(job, attempt, map[string]interface{}{
+			"response":      response,
+			"input_tokens":  inTok,
+			"output_tokens": outTok,
+			"latency_ms":    latency,
+			"images_sent":   imagesSent,
+			"cards_sent":    cardsSent,
+		})
+		go w.logUsage(job, inTok, outTok, int(latency), "ok", "")
+		return
+	}
+
+	// Suppress an identical consecutive reply to the same contact (retry / near-duplicate trigger)
+	if services.IsDuplicateReply(job.SessionTok, chatMsg.From, formattedResponse) {
+		log.Printf("🔁 Job #%d: reply identical to last message sent to %s - suppressed", job.ID, chatMsg.From)
+		w.completeJob(job, attempt, map[string]interface{}{
+			"response":      response,
+			"input_tokens":  inTok,
+			"output_tokens": outTok,
+			"latency_ms":    latency,
+			"suppressed":    "duplicate_reply",
+		})
+		go w.logUsage(job, inTok, outTok, int(latency), "ok", "")
+		return
+	}
+
+	// Send reply via WA (using internal gateway)
+	waMessageID, err := services.SendWAText(job.SessionTok, chatMsg.From, formattedResponse)
+	if err != nil {
+		w.recordSendLog(job, "", chatMsg.From, formattedResponse, "", err)
+		w.failJob(job, attempt, fmt.Sprintf("Failed to send WA message: %v", err))
+		return
+	}
+
+	// Save AI response to ai_chat_messages (for AI context) with FromMe=true, IsRead=true.
+	// Done synchronously before the job is marked done so the next job's dedupe check sees it.
+	// Use the WA message ID so reactions/replies to this message can be resolved;
+	// fall back to a generated one if the WA Server didn't report it
+	aiMsgID := waMessageID
+	if aiMsgID == "" {
+		aiMsgID = fmt.Sprintf("ai_%s_%d", job.SessionTok, time.Now().UnixNano())
+	}
+	if err := services.SaveOutgoingMessageToAIChat(
+		job.SessionTok,
+		aiMsgID,
+		chatMsg.To,        // from (bot's JID)
+		chatMsg.From,      // to (recipient)
+		formattedResponse, // body (formatted for WhatsApp)
+		time.Now(),        // timestamp
+	); err != nil {
+		log.Printf("⚠️  Failed to save AI response to AI chat messages: %v", err)
+	}
 
-		// Save to permanent chat_messages (for UI)
-		if err := services.SaveAIResponseToHistory(job.SessionTok, chatMsg.From, formattedResp); err != nil {
+	// Save to permanent chat_messages (for UI) - async
+	go func(recipientJID, formattedResp string) {
+		if err := services.SaveAIResponseToHistory(job.SessionTok, recipientJID, formattedResp); err != nil {
 			log.Printf("⚠️  Failed to save AI response to permanent chat history: %v", err)
 		}
-	}(formattedResponse)
+	}(chatMsg.From, formattedResponse)
+
+	// Log sent message (one row per job - a retried send updates it, see services.RecordSendLog)
+	w.recordSendLog(job, "", chatMsg.From, formattedResponse, waMessageID, nil)
+
+	outputData := map[string]interface{}{
+		"response":      response,
+		"input_tokens":  inTok,
+		"output_tokens": outTok,
+		"latency_ms":    latency,
+	}
+	if len(imageURLs) > 0 {
+		outputData["images_sent"] = w.sendReplyImages(job, chatMsg, botSettings, imageURLs)
+	}
+	if len(cards) > 0 {
+		outputData["cards_sent"] = w.sendReplyCards(job, chatMsg, botSettings, cards)
+	}
+
+	// Save AI output & mark job as done
+	w.completeJob(job, attempt, outputData)
+
+	jobLogger(job).Info("reply sent", "wa_message_id", waMessageID, "latency_ms", latency,
+		"input_tokens", inTok, "output_tokens", outTok)
+
+	// Log to Transactional DB (AIUsageLog) - async, don't block on error
+	go w.logUsage(job, inTok, outTok, int(latency), "ok", "")
+}
+
+// startLLMHandoff records a handoff the LLM asked for and notifies the bot's escalation contacts
+func (w *AIWorker) startLLMHandoff(job *models.AIJob, chatMsg *models.AIChatMessage, botSettings *services.BotSettings) {
+	started, err := services.StartHandoff(job.SessionTok, chatMsg.From, services.HandoffReasonLLM, job.MessageID)
+	if err != nil {
+		log.Printf("⚠️  Job #%d: %v", job.ID, err)
+		return
+	}
+	if started {
+		go services.NotifyHandoff(botSettings, job.SessionTok, chatMsg.From, chatMsg.PushName, services.HandoffReasonLLM, chatMsg.Body)
+	}
+}
+
+// queueReplyForApproval stores the reply as pending_approval (AI_REPLY_APPROVAL) and completes the job.
+// Images are validated now, while the bot settings are at hand; only valid ones are kept.
+func (w *AIWorker) queueReplyForApproval(job *models.AIJob, attempt *models.AIJobAttempt, chatMsg *models.AIChatMessage, botSettings *services.BotSettings, response, formattedResponse string, imageURLs []string, cards []services.ReplyCard, inTok, outTok int, latency int64) {
+	var validImages []string
+	for _, imageURL := range imageURLs {
+		if err := services.ValidateImageURL(imageURL, botSettings); err != nil {
+			log.Printf("🚫 Job #%d: image dropped from approval: %v", job.ID, err)
+			continue
+		}
+		validImages = append(validImages, imageURL)
+	}
+	var validCards []string
+	for _, card := range cards {
+		if err := services.ValidateReplyCard(card, botSettings); err != nil {
+			log.Printf("🚫 Job #%d: %s card dropped from approval: %v", job.ID, card.Kind, err)
+			continue
+		}
+		validCards = append(validCards, card.Sentinel())
+	}
 
-	// 5. Log sent message
-	sendLog := models.MessageSendLog{
+	approval := models.AIReplyApproval{
+		JobID:      job.ID,
 		SessionTok: job.SessionTok,
-		To:         chatMsg.From,
-		Body:       formattedResponse,
-		Status:     "sent",
-		CreatedAt:  time.Now(),
+		MessageID:  job.MessageID,
+		BotJID:     chatMsg.To,
+		ContactJID: chatMsg.From,
+		Reply:      formattedResponse,
+		ImageURLs:  strings.Join(validImages, "\n"),
+		Cards:      strings.Join(validCards, "\n"),
+	}
+	if err := services.QueueReplyForApproval(&approval); err != nil {
+		w.failJob(job, attempt, err.Error())
+		return
 	}
-	w.db.Create(&sendLog)
 
-	// 6. Save AI output & mark job as done
-	outputData := map[string]interface{}{
+	log.Printf("📝 Job #%d: reply held for approval (#%d)", job.ID, approval.ID)
+	w.completeJob(job, attempt, map[string]interface{}{
 		"response":      response,
 		"input_tokens":  inTok,
 		"output_tokens": outTok,
 		"latency_ms":    latency,
+		"approval_id":   approval.ID,
+	})
+	go w.logUsage(job, inTok, outTok, int(latency), "ok", "")
+}
+
+// sendReplyImages validates and sends images requested with [SEND_IMAGE:url] and records them
+// in the AI context / chat history. Invalid or failed images are skipped; returns how many were sent.
+func (w *AIWorker) sendReplyImages(job *models.AIJob, chatMsg *models.AIChatMessage, botSettings *services.BotSettings, imageURLs []string) int {
+	sent := 0
+	for i, imageURL := range imageURLs {
+		if err := services.ValidateImageURL(imageURL, botSettings); err != nil {
+			log.Printf("🚫 Job #%d: image not sent: %v", job.ID, err)
+			continue
+		}
+
+		part := fmt.Sprintf("img:%d", i)
+		body := fmt.Sprintf("[Gambar: %s]", imageURL)
+		waMessageID, err := services.SendWAImage(job.SessionTok, chatMsg.From, imageURL, "")
+		if err != nil {
+			log.Printf("⚠️  Job #%d: failed to send image %s: %v", job.ID, imageURL, err)
+			w.recordSendLog(job, part, chatMsg.From, body, "", err)
+			continue
+		}
+		sent++
+		log.Printf("🖼️  Job #%d: image sent to %s (%s)", job.ID, chatMsg.From, imageURL)
+
+		// The LLM sees "[Gambar: url]" in later history so it knows the image was already sent
+		w.recordSendLog(job, part, chatMsg.From, body, waMessageID, nil)
+		if waMessageID == "" {
+			waMessageID = fmt.Sprintf("ai_img_%s_%d", job.SessionTok, time.Now().UnixNano())
+		}
+		if err := services.SaveOutgoingMessageToAIChat(job.SessionTok, waMessageID, chatMsg.To, chatMsg.From, body, time.Now()); err != nil {
+			log.Printf("⚠️  Failed to save sent image to AI chat messages: %v", err)
+		}
+		go func(recipientJID string) {
+			if err := services.SaveAIResponseToHistory(job.SessionTok, recipientJID, body); err != nil {
+				log.Printf("⚠️  Failed to save sent image to permanent chat history: %v", err)
+			}
+		}(chatMsg.From)
+	}
+	return sent
+}
+
+// sendReplyCards validates and sends location / contact cards requested with [SEND_LOCATION] /
+// [SEND_CONTACT] and records them like images. Returns how many were sent.
+func (w *AIWorker) sendReplyCards(job *models.AIJob, chatMsg *models.AIChatMessage, botSettings *services.BotSettings, cards []services.ReplyCard) int {
+	sent := 0
+	for i, card := range cards {
+		if err := services.ValidateReplyCard(card, botSettings); err != nil {
+			log.Printf("🚫 Job #%d: %s card not sent: %v", job.ID, card.Kind, err)
+			continue
+		}
+
+		part := fmt.Sprintf("card:%d", i)
+		body := card.HistoryBody()
+		waMessageID, err := services.SendWACard(job.SessionTok, chatMsg.From, card)
+		if err != nil {
+			log.Printf("⚠️  Job #%d: failed to send %s card: %v", job.ID, card.Kind, err)
+			w.recordSendLog(job, part, chatMsg.From, body, "", err)
+			continue
+		}
+		sent++
+		log.Printf("📍 Job #%d: %s card sent to %s", job.ID, card.Kind, chatMsg.From)
+
+		w.recordSendLog(job, part, chatMsg.From, body, waMessageID, nil)
+		if waMessageID == "" {
+			waMessageID = fmt.Sprintf("ai_card_%s_%d", job.SessionTok, time.Now().UnixNano())
+		}
+		if err := services.SaveOutgoingMessageToAIChat(job.SessionTok, waMessageID, chatMsg.To, chatMsg.From, body, time.Now()); err != nil {
+			log.Printf("⚠️  Failed to save sent card to AI chat messages: %v", err)
+		}
+		go func(recipientJID string) {
+			if err := services.SaveAIResponseToHistory(job.SessionTok, recipientJID, body); err != nil {
+				log.Printf("⚠️  Failed to save sent card to permanent chat history: %v", err)
+			}
+		}(chatMsg.From)
+	}
+	return sent
+}
+
+// recordSendLog logs a send outcome keyed by the job (part "" = the text reply), so retries of
+// the job update one row instead of adding another
+func (w *AIWorker) recordSendLog(job *models.AIJob, part, to, body, waMessageID string, sendErr error) {
+	if services.DryRun() && sendErr == nil {
+		return // the send itself was recorded as a dry run
+	}
+	entry := models.MessageSendLog{
+		JobID:       job.ID,
+		SessionTok:  job.SessionTok,
+		To:          to,
+		Body:        body,
+		Status:      services.SendLogSent,
+		WAMessageID: waMessageID,
+	}
+	if sendErr != nil {
+		entry.Status = services.SendLogFailed
+		entry.ErrorMsg = sendErr.Error()
 	}
+	if err := services.RecordSendLog(services.JobSendKey(job.ID, part), &entry); err != nil {
+		log.Printf("⚠️  Job #%d: failed to record send log: %v", job.ID, err)
+	}
+}
+
+// completeJob stores the job output and marks job + attempt as done
+func (w *AIWorker) completeJob(job *models.AIJob, attempt *models.AIJobAttempt, outputData map[string]interface{}) {
 	outputJSON, _ := json.Marshal(outputData)
 
 	now := time.Now()
@@ -354,16 +847,82 @@ func (w *AIWorker) processJob(job *models.AIJob) {
 	})
 
 	// Update attempt record
-	w.db.Model(&attempt).Updates(map[string]interface{}{
+	w.db.Model(attempt).Updates(map[string]interface{}{
 		"status":   "ok",
 		"ended_at": now,
 	})
+}
 
-	log.Printf("✅ Job #%d completed in %dms (tokens: %d in, %d out)",
-		job.ID, latency, inTok, outTok)
+// minContextMessages is the history size a context-length failure is retried with
+const minContextMessages = 5
 
-	// Log to Transactional DB (AIUsageLog) - async, don't block on error
-	go w.logUsage(job.UserID, job.SessionTok, inTok, outTok, int(latency), "ok", "")
+// errContextBuild marks a retry that failed building the context (not calling the LLM)
+var errContextBuild = errors.New("context build failed")
+
+// llmReply is an LLM answer together with the context it was asked with
+type llmReply struct {
+	ctx           *services.ContextData
+	response      string
+	inTok, outTok int
+}
+
+// llmForBot returns the provider for a bot's jobs: its own credentials when set, else the global client
+func (w *AIWorker) llmForBot(settings *services.BotSettings) services.BotLLM {
+	return services.ResolveBotLLM(settings, services.BotLLM{Provider: w.aiProvider, Breaker: aiProviderCB})
+}
+
+// askLLMForJob builds the job's context with maxMessages of history and asks the LLM, with model
+// overriding the configured one when set
+func (w *AIWorker) askLLMForJob(job *models.AIJob, maxMessages int, model string) (*llmReply, error) {
+	jobCtx, ctxErr := services.BuildContextForMessages(job.UserID, job.SessionTok, services.JobMessageIDs(job), maxMessages)
+	if ctxErr != nil {
+		return nil, fmt.Errorf("%w with %d messages: %v", errContextBuild, maxMessages, ctxErr)
+	}
+	services.DumpPrompt(job.ID, job.SessionTok, job.MessageID, maxMessages, jobCtx)
+	services.RecordContextDecision(job.ID, job.SessionTok, job.MessageID, maxMessages, jobCtx)
+
+	timeoutCtx, cancel := context.WithTimeout(services.WithModelOverride(context.Background(), model), services.AITimeout())
+	defer cancel()
+
+	reply := &llmReply{ctx: jobCtx}
+	llm := w.llmForBot(jobCtx.Settings)
+	err := llm.Breaker.Call(func() error {
+		var llmErr error
+		reply.response, reply.inTok, reply.outTok, llmErr = llm.Provider.AskLLM(timeoutCtx, jobCtx.SystemPrompt, jobCtx.UserMessage)
+		return llmErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// recoverContextOverflow retries a context-length failure: first with minContextMessages of history
+// (when more were used), then with fallbackModel (when set). Returns the reply and the strategy that
+// worked ("fewer_messages" | "larger_model"), or the last error.
+func recoverContextOverflow(currentMaxMessages int, fallbackModel string, ask func(maxMessages int, model string) (*llmReply, error)) (*llmReply, string, error) {
+	var lastErr error
+	if currentMaxMessages > minContextMessages {
+		log.Printf("📏 Context too long, retrying with %d messages instead of %d", minContextMessages, currentMaxMessages)
+		reply, err := ask(minContextMessages, "")
+		if err == nil {
+			return reply, "fewer_messages", nil
+		}
+		if !services.ParseSDKError(err).IsContextLengthError() {
+			return nil, "", err
+		}
+		lastErr = err
+	}
+
+	if fallbackModel == "" {
+		return nil, "", lastErr
+	}
+	log.Printf("📏 Context still too long, retrying with larger-context model %s", fallbackModel)
+	reply, err := ask(minContextMessages, fallbackModel)
+	if err != nil {
+		return nil, "", err
+	}
+	return reply, "larger_model", nil
 }
 
 // handleLLMError handles LLM errors with intelligent retry logic
@@ -373,108 +932,36 @@ func (w *AIWorker) handleLLMError(job *models.AIJob, attempt *models.AIJobAttemp
 	// Parse as OpenRouter error
 	orErr := services.ParseSDKError(err)
 
-	// Check if it's a context length error and we can retry with smaller context
-	if orErr.IsContextLengthError() && currentMaxMessages > 5 {
-		log.Printf("📏 Context too long, retrying job #%d with 5 messages instead of %d", job.ID, currentMaxMessages)
-
-		// Retry with smaller context
+	// Context length error: retry with fewer messages, then (AI_CONTEXT_FALLBACK_MODEL) with a
+	// larger-context model - fewer messages don't help when the system prompt + KB alone is too big
+	fallbackModel := services.ContextFallbackModel()
+	if orErr.IsContextLengthError() && (currentMaxMessages > minContextMessages || fallbackModel != "") {
 		start := time.Now()
-		smallerCtx, ctxErr := services.BuildContextWithLimit(job.UserID, job.SessionTok, job.MessageID, 5)
-		if ctxErr != nil {
-			w.permanentFailJob(job, attempt, fmt.Sprintf("Context build failed even with 5 messages: %v", ctxErr))
-			return
-		}
-
-		timeoutCtx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-
-		var response string
-		var inTok, outTok int
-
-		cbErr := aiProviderCB.Call(func() error {
-			var llmErr error
-			response, inTok, outTok, llmErr = w.aiProvider.AskLLM(timeoutCtx, smallerCtx.SystemPrompt, smallerCtx.UserMessage)
-			return llmErr
+		reply, strategy, retryErr := recoverContextOverflow(currentMaxMessages, fallbackModel, func(maxMessages int, model string) (*llmReply, error) {
+			return w.askLLMForJob(job, maxMessages, model)
 		})
-
-		if cbErr != nil {
-			// Still failed, handle normally
-			w.handleLLMError(job, attempt, cbErr, 5)
+		if retryErr == nil {
+			var chatMsg models.AIChatMessage
+			if err := w.db.Where("message_id = ?", job.MessageID).First(&chatMsg).Error; err != nil {
+				w.failJob(job, attempt, fmt.Sprintf("Failed to fetch chat message: %v", err))
+				return
+			}
+			log.Printf("📏 Job #%d recovered from context overflow via %s", job.ID, strategy)
+			w.deliverReply(job, attempt, &chatMsg, reply.ctx.Settings, reply.response, reply.inTok, reply.outTok, start)
 			return
 		}
-
-		// Success! Complete the job
-		latency := time.Since(start).Milliseconds()
-
-		var chatMsg models.AIChatMessage
-		if err := w.db.Where("message_id = ?", job.MessageID).First(&chatMsg).Error; err != nil {
-			w.failJob(job, attempt, fmt.Sprintf("Failed to fetch chat message: %v", err))
+		if errors.Is(retryErr, errContextBuild) {
+			w.permanentFailJob(job, attempt, retryErr.Error())
 			return
 		}
-
-		// Format response for WhatsApp
-		formattedResponse := services.FormatForWhatsApp(response)
-
-		if err := services.SendWAText(job.SessionTok, chatMsg.From, formattedResponse); err != nil {
-			w.failJob(job, attempt, fmt.Sprintf("Failed to send WA message: %v", err))
+		orErr = services.ParseSDKError(retryErr)
+		if orErr.IsContextLengthError() {
+			w.permanentFailJob(job, attempt, fmt.Sprintf("Context too long even with %d messages (fallback model %q): %v",
+				minContextMessages, fallbackModel, retryErr))
 			return
 		}
-
-		// Save AI response to ai_chat_messages AND permanent history
-		go func(sessionToken, recipientJID, responseText string) {
-			// Save to ai_chat_messages
-			aiMsgID := fmt.Sprintf("ai_%s_%d", sessionToken, time.Now().UnixNano())
-			if err := services.SaveOutgoingMessageToAIChat(
-				sessionToken,
-				aiMsgID,
-				chatMsg.To,
-				recipientJID,
-				responseText,
-				time.Now(),
-			); err != nil {
-				log.Printf("⚠️  Failed to save AI response to AI chat messages: %v", err)
-			}
-
-			// Save to permanent chat_messages
-			if err := services.SaveAIResponseToHistory(sessionToken, recipientJID, responseText); err != nil {
-				log.Printf("⚠️  Failed to save AI response to permanent chat history: %v", err)
-			}
-		}(job.SessionTok, chatMsg.From, formattedResponse)
-
-		sendLog := models.MessageSendLog{
-			SessionTok: job.SessionTok,
-			To:         chatMsg.From,
-			Body:       formattedResponse,
-			Status:     "sent",
-			CreatedAt:  time.Now(),
-		}
-		w.db.Create(&sendLog)
-
-		outputData := map[string]interface{}{
-			"response":      response,
-			"input_tokens":  inTok,
-			"output_tokens": outTok,
-			"latency_ms":    latency,
-		}
-		outputJSON, _ := json.Marshal(outputData)
-
-		now := time.Now()
-		w.db.Model(job).Updates(map[string]interface{}{
-			"status":      "done",
-			"output_json": string(outputJSON),
-			"updated_at":  now,
-		})
-
-		w.db.Model(attempt).Updates(map[string]interface{}{
-			"status":   "ok",
-			"ended_at": now,
-		})
-
-		log.Printf("✅ Job #%d completed with smaller context in %dms (tokens: %d in, %d out)",
-			job.ID, latency, inTok, outTok)
-
-		go w.logUsage(job.UserID, job.SessionTok, inTok, outTok, int(latency), "ok", "")
-		return
+		// Failed for another reason (rate limit, timeout, ...) - handled like any LLM error below
+		err = retryErr
 	}
 
 	// Check if error is permanent (non-retryable)
@@ -489,14 +976,17 @@ func (w *AIWorker) handleLLMError(job *models.AIJob, attempt *models.AIJobAttemp
 		return
 	}
 
-	// Retryable error - use normal retry logic
+	// Retryable error - retry when the provider says so (Retry-After / X-RateLimit-Reset), else 30s
 	errMsg := fmt.Sprintf("LLM call failed (%d): %s", orErr.Code, orErr.Message)
-	w.failJob(job, attempt, errMsg)
+	if wait := orErr.RetryAfter(); wait > 0 {
+		log.Printf("⏳ Job #%d rate limited, provider asks to retry in %v", job.ID, wait)
+	}
+	w.failJobWithDelay(job, attempt, errMsg, orErr.RetryAfter())
 }
 
 // permanentFailJob marks job as permanently failed (no retry)
 func (w *AIWorker) permanentFailJob(job *models.AIJob, attempt *models.AIJobAttempt, errMsg string) {
-	log.Printf("🚫 Job #%d permanently failed: %s", job.ID, errMsg)
+	jobLogger(job).Error("job permanently failed", "error", errMsg)
 
 	now := time.Now()
 
@@ -515,12 +1005,42 @@ func (w *AIWorker) permanentFailJob(job *models.AIJob, attempt *models.AIJobAtte
 	})
 
 	// Log to usage with error status
-	go w.logUsage(job.UserID, job.SessionTok, 0, 0, 0, "error", errMsg)
+	go w.logUsage(job, 0, 0, 0, "error", errMsg)
+}
+
+// jobLogger tags log lines with the job and the webhook's correlation ID, so a message can be
+// followed from the webhook through the job, LLM call and reply
+func jobLogger(job *models.AIJob) *slog.Logger {
+	return logger.ForRequest(job.RequestID).With("job_id", job.ID, "message_id", job.MessageID)
 }
 
 // failJob marks job as failed with retry logic
 func (w *AIWorker) failJob(job *models.AIJob, attempt *models.AIJobAttempt, errMsg string) {
-	log.Printf("❌ Job #%d failed: %s", job.ID, errMsg)
+	w.failJobWithDelay(job, attempt, errMsg, 0)
+}
+
+// defaultRetryDelay is the wait before retrying a failed job without rate-limit info
+const defaultRetryDelay = 30 * time.Second
+
+// maxRetryDelay caps a provider-requested retry delay
+const maxRetryDelay = 15 * time.Minute
+
+// retryDelay returns the provider's requested delay (clamped to 1s..15m) or the default 30s
+func retryDelay(requested time.Duration) time.Duration {
+	switch {
+	case requested <= 0:
+		return defaultRetryDelay
+	case requested < time.Second:
+		return time.Second
+	case requested > maxRetryDelay:
+		return maxRetryDelay
+	}
+	return requested
+}
+
+// failJobWithDelay is failJob with an explicit retry delay (e.g. from Retry-After; 0 = default)
+func (w *AIWorker) failJobWithDelay(job *models.AIJob, attempt *models.AIJobAttempt, errMsg string, delay time.Duration) {
+	jobLogger(job).Warn("job failed", "error", errMsg, "attempt", job.Attempts)
 
 	now := time.Now()
 
@@ -538,7 +1058,7 @@ func (w *AIWorker) failJob(job *models.AIJob, attempt *models.AIJobAttempt, errM
 
 	// Retry logic (max 3 attempts)
 	if job.Attempts < 3 {
-		nextRun := time.Now().Add(30 * time.Second)
+		nextRun := time.Now().Add(retryDelay(delay))
 		updates["status"] = "pending"
 		updates["next_run_at"] = nextRun
 		log.Printf("🔄 Job #%d will retry at %s (attempt %d/3)", job.ID, nextRun.Format(time.RFC3339), job.Attempts)
@@ -547,14 +1067,15 @@ func (w *AIWorker) failJob(job *models.AIJob, attempt *models.AIJobAttempt, errM
 		log.Printf("💀 Job #%d permanently failed after %d attempts", job.ID, job.Attempts)
 
 		// Log permanent failure to Transactional DB
-		go w.logUsage(job.UserID, job.SessionTok, 0, 0, 0, "error", errMsg)
+		go w.logUsage(job, 0, 0, 0, "error", errMsg)
 	}
 
 	w.db.Model(job).Updates(updates)
 }
 
-// logUsage logs AI usage to Transactional DB via data provider (async)
-func (w *AIWorker) logUsage(userID, sessionID string, inputTokens, outputTokens, latencyMs int, status, errorReason string) {
+// logUsage logs AI usage of the job's current attempt to Transactional DB via data provider (async).
+// Keyed on job ID + attempt, so it is billed once however often the write is repeated.
+func (w *AIWorker) logUsage(job *models.AIJob, inputTokens, outputTokens, latencyMs int, status, errorReason string) {
 	// Get data provider
 	provider, err := services.GetDataProvider()
 	if err != nil {
@@ -564,14 +1085,15 @@ func (w *AIWorker) logUsage(userID, sessionID string, inputTokens, outputTokens,
 
 	// Prepare usage log request
 	logReq := &services.UsageLogRequest{
-		UserID:       userID,
-		SessionID:    sessionID,
+		UserID:       job.UserID,
+		SessionID:    job.SessionTok,
 		InputTokens:  inputTokens,
 		OutputTokens: outputTokens,
 		TotalTokens:  inputTokens + outputTokens,
 		LatencyMs:    latencyMs,
 		Status:       status,
 		ErrorReason:  errorReason,
+		DedupeKey:    services.UsageDedupeKey(job.ID, job.Attempts),
 	}
 
 	// Log usage via provider (API or Direct DB)
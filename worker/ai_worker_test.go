@@ -0,0 +1,509 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"genfity-wa-support/database"
+	"genfity-wa-support/models"
+	"genfity-wa-support/services"
+
+	"github.com/lib/pq"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupWorkerTestDB returns an AIWorker on TEST_DATABASE_DSN (skips the test when unset)
+// and a unique session token whose jobs are removed after the test
+func setupWorkerTestDB(t *testing.T) (*AIWorker, string) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set - skipping database test")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AIJob{}); err != nil {
+		t.Fatalf("failed to migrate ai_jobs: %v", err)
+	}
+
+	sessionTok := fmt.Sprintf("test_worker_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Where("session_tok = ?", sessionTok).Delete(&models.AIJob{})
+	})
+	return &AIWorker{db: db, shutdown: make(chan struct{})}, sessionTok
+}
+
+func TestClaimNextJobOrdersByPriority(t *testing.T) {
+	w, sessionTok := setupWorkerTestDB(t)
+
+	// Enqueued in arrival order: starter, enterprise, business, starter, enterprise
+	priorities := []int{5, 1, 3, 5, 1}
+	for i, priority := range priorities {
+		job := models.AIJob{
+			Status:     "pending",
+			Priority:   priority,
+			SessionTok: sessionTok,
+			MessageID:  fmt.Sprintf("%s_msg_%d", sessionTok, i),
+			UserID:     "test-user",
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := w.db.Create(&job).Error; err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+	}
+
+	// Claim everything pending; other rows in a shared test DB are ignored
+	var claimed []string
+	for {
+		job, ok := w.claimNextJob()
+		if !ok {
+			break
+		}
+		if job.SessionTok == sessionTok {
+			claimed = append(claimed, job.MessageID)
+		}
+	}
+
+	want := []string{
+		sessionTok + "_msg_1", // priority 1, enqueued first
+		sessionTok + "_msg_4", // priority 1
+		sessionTok + "_msg_2", // priority 3
+		sessionTok + "_msg_0", // priority 5
+		sessionTok + "_msg_3", // priority 5
+	}
+	if len(claimed) != len(want) {
+		t.Fatalf("claimed %d jobs, want %d: %v", len(claimed), len(want), claimed)
+	}
+	for i := range want {
+		if claimed[i] != want[i] {
+			t.Errorf("processing order[%d] = %s, want %s (full order: %v)", i, claimed[i], want[i], claimed)
+		}
+	}
+
+	var processing int64
+	w.db.Model(&models.AIJob{}).Where("session_tok = ? AND status = ?", sessionTok, "processing").Count(&processing)
+	if processing != int64(len(want)) {
+		t.Errorf("expected all claimed jobs marked processing, got %d", processing)
+	}
+}
+
+func TestClaimNextJobUrgentKeywordFirst(t *testing.T) {
+	w, sessionTok := setupWorkerTestDB(t)
+	t.Setenv("AI_PRIORITY_TIERS", "")
+	t.Setenv("AI_PRIORITY_DEFAULT", "")
+	t.Setenv("AI_PRIORITY_KEYWORDS", "")
+
+	// Same package, the urgent message arrives last
+	bodies := []string{"halo kak", "mau tanya stok", "URGENT pesanan salah kirim"}
+	for i, body := range bodies {
+		job := models.AIJob{
+			Status:     "pending",
+			Priority:   services.JobPriority("Starter", body),
+			SessionTok: sessionTok,
+			MessageID:  fmt.Sprintf("%s_msg_%d", sessionTok, i),
+			UserID:     "test-user",
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := w.db.Create(&job).Error; err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+	}
+
+	var claimed []string
+	for {
+		job, ok := w.claimNextJob()
+		if !ok {
+			break
+		}
+		if job.SessionTok == sessionTok {
+			claimed = append(claimed, job.MessageID)
+		}
+	}
+
+	want := []string{sessionTok + "_msg_2", sessionTok + "_msg_0", sessionTok + "_msg_1"}
+	if fmt.Sprint(claimed) != fmt.Sprint(want) {
+		t.Errorf("processing order = %v, want %v", claimed, want)
+	}
+}
+
+// claimAvailable claims every job that can be claimed right now and returns this session's message IDs
+func claimAvailable(w *AIWorker, sessionTok string) []string {
+	var claimed []string
+	for {
+		job, ok := w.claimNextJob()
+		if !ok {
+			return claimed
+		}
+		if job.SessionTok == sessionTok {
+			claimed = append(claimed, job.MessageID)
+		}
+	}
+}
+
+func TestClaimNextJobSerializesPerContact(t *testing.T) {
+	w, sessionTok := setupWorkerTestDB(t)
+	t.Setenv("AI_SERIALIZE_CONVERSATIONS", "true")
+
+	// Two contacts, three messages each, interleaved; a3 is urgent but must still wait for a1/a2
+	type msg struct {
+		id, contact string
+		priority    int
+	}
+	msgs := []msg{
+		{"a1", "628111", 5}, {"b1", "628222", 5},
+		{"a2", "628111", 5}, {"b2", "628222", 5},
+		{"a3", "628111", 1}, {"b3", "628222", 5},
+	}
+	for _, m := range msgs {
+		job := models.AIJob{
+			Status:     "pending",
+			Priority:   m.priority,
+			SessionTok: sessionTok,
+			MessageID:  m.id,
+			UserID:     "test-user",
+			Contact:    m.contact,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := w.db.Create(&job).Error; err != nil {
+			t.Fatalf("failed to enqueue job: %v", err)
+		}
+	}
+	finish := func(messageID string) {
+		w.db.Model(&models.AIJob{}).
+			Where("session_tok = ? AND message_id = ?", sessionTok, messageID).
+			Update("status", "done")
+	}
+
+	// Both contacts start in parallel, but only their first message
+	if got := fmt.Sprint(claimAvailable(w, sessionTok)); got != "[a1 b1]" {
+		t.Fatalf("first claim = %v, want [a1 b1]", got)
+	}
+
+	// a1 done -> a2 (not the urgent a3); b is still busy with b1
+	finish("a1")
+	if got := fmt.Sprint(claimAvailable(w, sessionTok)); got != "[a2]" {
+		t.Fatalf("after a1 = %v, want [a2]", got)
+	}
+
+	finish("b1")
+	finish("a2")
+	if got := fmt.Sprint(claimAvailable(w, sessionTok)); got != "[a3 b2]" {
+		t.Fatalf("after b1, a2 = %v, want [a3 b2]", got)
+	}
+
+	finish("a3")
+	if got := claimAvailable(w, sessionTok); len(got) != 0 {
+		t.Fatalf("b3 claimed while b2 in flight: %v", got)
+	}
+	finish("b2")
+	if got := fmt.Sprint(claimAvailable(w, sessionTok)); got != "[b3]" {
+		t.Fatalf("after b2 = %v, want [b3]", got)
+	}
+}
+
+func TestClaimNextJobStaleProcessingReleasesContact(t *testing.T) {
+	w, sessionTok := setupWorkerTestDB(t)
+	t.Setenv("AI_SERIALIZE_CONVERSATIONS", "true")
+	t.Setenv("AI_CONVERSATION_LOCK_TIMEOUT_SECONDS", "60")
+
+	// A worker crashed mid-job 10 minutes ago
+	stale := time.Now().Add(-10 * time.Minute)
+	jobs := []models.AIJob{
+		{Status: "processing", SessionTok: sessionTok, MessageID: "crashed", UserID: "test-user", Contact: "628111", CreatedAt: stale, UpdatedAt: stale},
+		{Status: "pending", SessionTok: sessionTok, MessageID: "next", UserID: "test-user", Contact: "628111", CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+	if err := w.db.Create(&jobs).Error; err != nil {
+		t.Fatalf("failed to enqueue jobs: %v", err)
+	}
+	// GORM sets updated_at on create; put the crashed job back in the past
+	w.db.Model(&models.AIJob{}).Where("id = ?", jobs[0].ID).UpdateColumn("updated_at", stale)
+
+	if got := fmt.Sprint(claimAvailable(w, sessionTok)); got != "[next]" {
+		t.Errorf("claim = %v, want [next]", got)
+	}
+}
+
+// newTestPool builds a worker pool whose jobs come from queue and take delay each
+func newTestPool(concurrency int, queue chan *models.AIJob, delay time.Duration, done chan<- uint) *AIWorker {
+	w := &AIWorker{
+		shutdown:    make(chan struct{}),
+		concurrency: concurrency,
+		wake:        make(chan struct{}, concurrency),
+	}
+	w.claimJob = func() (*models.AIJob, bool) {
+		select {
+		case job := <-queue:
+			return job, true
+		default:
+			return nil, false
+		}
+	}
+	w.runJob = func(job *models.AIJob) {
+		time.Sleep(delay)
+		done <- job.ID
+	}
+	return w
+}
+
+func TestWorkerPoolProcessesSlowJobsInParallel(t *testing.T) {
+	const jobs = 4
+	const delay = 200 * time.Millisecond
+
+	queue := make(chan *models.AIJob, jobs)
+	for i := 1; i <= jobs; i++ {
+		queue <- &models.AIJob{ID: uint(i)}
+	}
+	done := make(chan uint, jobs)
+	w := newTestPool(jobs, queue, delay, done)
+
+	start := time.Now()
+	w.startJobWorkers()
+	w.wakeWorkers()
+
+	seen := make(map[uint]bool)
+	timeout := time.After(2 * time.Second)
+	for len(seen) < jobs {
+		select {
+		case id := <-done:
+			if seen[id] {
+				t.Fatalf("job #%d processed twice", id)
+			}
+			seen[id] = true
+		case <-timeout:
+			t.Fatalf("only %d/%d jobs processed", len(seen), jobs)
+		}
+	}
+	elapsed := time.Since(start)
+	w.Stop()
+
+	// Serial processing would take jobs*delay (800ms)
+	if elapsed >= 2*delay {
+		t.Errorf("%d jobs of %v took %v - expected them to run in parallel", jobs, delay, elapsed)
+	}
+}
+
+func TestWorkerPoolStopDrainsInFlightJobs(t *testing.T) {
+	queue := make(chan *models.AIJob, 2)
+	queue <- &models.AIJob{ID: 1}
+	queue <- &models.AIJob{ID: 2}
+	done := make(chan uint, 2)
+	w := newTestPool(2, queue, 150*time.Millisecond, done)
+
+	w.startJobWorkers()
+	w.wakeWorkers()
+	time.Sleep(20 * time.Millisecond) // let both jobs start
+
+	w.Stop() // must block until both in-flight jobs finished
+	if len(done) != 2 {
+		t.Errorf("Stop returned with %d/2 in-flight jobs finished", len(done))
+	}
+}
+
+func TestWorkerPoolSingleConcurrencyIsSerial(t *testing.T) {
+	queue := make(chan *models.AIJob, 3)
+	for i := 1; i <= 3; i++ {
+		queue <- &models.AIJob{ID: uint(i)}
+	}
+	done := make(chan uint, 3)
+	w := newTestPool(1, queue, 50*time.Millisecond, done)
+
+	start := time.Now()
+	w.startJobWorkers()
+	w.wakeWorkers()
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+	w.Stop()
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("concurrency 1 finished 3x50ms jobs in %v - expected serial processing", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		requested time.Duration
+		want      time.Duration
+	}{
+		{0, defaultRetryDelay},
+		{-time.Second, defaultRetryDelay},
+		{200 * time.Millisecond, time.Second},
+		{45 * time.Second, 45 * time.Second},
+		{time.Hour, maxRetryDelay},
+	}
+	for _, tc := range cases {
+		if got := retryDelay(tc.requested); got != tc.want {
+			t.Errorf("retryDelay(%v) = %v, want %v", tc.requested, got, tc.want)
+		}
+	}
+}
+
+func TestDispatchNotificationsNotBlockedByLongDrain(t *testing.T) {
+	queue := make(chan *models.AIJob, 10)
+	queue <- &models.AIJob{ID: 1}
+	done := make(chan uint, 10)
+	w := newTestPool(1, queue, 300*time.Millisecond, done)
+
+	notify := make(chan *pq.Notification) // unbuffered, like a listener waiting on us
+	keepalive := make(chan time.Time)
+	pings := make(chan struct{}, 1)
+	w.startJobWorkers()
+	go w.dispatchNotifications(notify, keepalive, func() { pings <- struct{}{} })
+	defer w.Stop()
+
+	notify <- &pq.Notification{Channel: "ai_jobs"}
+	time.Sleep(20 * time.Millisecond) // job #1 is now running (300ms drain)
+
+	// Burst of NOTIFYs + a keepalive during the drain must all be received promptly
+	start := time.Now()
+	for i := 2; i <= 6; i++ {
+		queue <- &models.AIJob{ID: uint(i)}
+		select {
+		case notify <- &pq.Notification{Channel: "ai_jobs"}:
+		case <-time.After(50 * time.Millisecond):
+			t.Fatalf("listener blocked on notification %d during drain", i)
+		}
+	}
+	select {
+	case keepalive <- time.Now():
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("listener blocked on keepalive during drain")
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("notifications took %v to be received during a drain", elapsed)
+	}
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Error("keepalive ping not sent")
+	}
+
+	// Every job inserted mid-drain is still processed
+	seen := make(map[uint]bool)
+	timeout := time.After(5 * time.Second)
+	for len(seen) < 6 {
+		select {
+		case id := <-done:
+			seen[id] = true
+		case <-timeout:
+			t.Fatalf("only %d/6 jobs processed - notifications during drain were missed", len(seen))
+		}
+	}
+}
+
+func TestDispatchNotificationsReconnectWakesWorkers(t *testing.T) {
+	queue := make(chan *models.AIJob, 1)
+	queue <- &models.AIJob{ID: 1}
+	done := make(chan uint, 1)
+	w := newTestPool(1, queue, 0, done)
+
+	notify := make(chan *pq.Notification)
+	w.startJobWorkers()
+	go w.dispatchNotifications(notify, nil, func() {})
+	defer w.Stop()
+
+	notify <- nil // reconnect event
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reconnect did not trigger a queue check")
+	}
+}
+
+func TestDeliverReplyHeldForApproval(t *testing.T) {
+	w, sessionTok := setupWorkerTestDB(t)
+	t.Setenv("AI_REPLY_APPROVAL", "true")
+	if err := w.db.AutoMigrate(&models.AIJobAttempt{}, &models.AIReplyApproval{}); err != nil {
+		t.Fatalf("failed to migrate test tables: %v", err)
+	}
+	previous := database.DB
+	database.DB = w.db
+	t.Cleanup(func() {
+		w.db.Where("session_tok = ?", sessionTok).Delete(&models.AIReplyApproval{})
+		database.DB = previous
+	})
+
+	job := models.AIJob{Status: "processing", SessionTok: sessionTok, MessageID: sessionTok + "_msg", UserID: "test-user"}
+	if err := w.db.Create(&job).Error; err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	attempt := models.AIJobAttempt{JobID: job.ID, StartedAt: time.Now(), Status: "processing"}
+	w.db.Create(&attempt)
+	t.Cleanup(func() { w.db.Delete(&attempt) })
+	chatMsg := &models.AIChatMessage{From: "6281200000001@s.whatsapp.net", To: "6281999999999@s.whatsapp.net"}
+
+	// No WA Server in tests: an attempted send would fail the job
+	w.deliverReply(&job, &attempt, chatMsg, &services.BotSettings{}, "**Paket A** Rp100.000", 10, 5, time.Now())
+
+	var result models.AIJob
+	w.db.First(&result, job.ID)
+	if result.Status != "done" {
+		t.Errorf("job status = %s, want done (reply held, not sent)", result.Status)
+	}
+	var approval models.AIReplyApproval
+	if err := w.db.Where("session_tok = ?", sessionTok).First(&approval).Error; err != nil {
+		t.Fatalf("no approval stored: %v", err)
+	}
+	if approval.Status != models.ReplyApprovalPending || approval.JobID != job.ID ||
+		approval.ContactJID != chatMsg.From || approval.Reply != "*Paket A* Rp100.000" {
+		t.Errorf("approval = %+v", approval)
+	}
+}
+
+func TestRecoverContextOverflowFallsBackToLargerModel(t *testing.T) {
+	overflow := errors.New("This model's maximum context length is 8192 tokens, context length exceeded")
+	type call struct {
+		maxMessages int
+		model       string
+	}
+	var calls []call
+	ask := func(maxMessages int, model string) (*llmReply, error) {
+		calls = append(calls, call{maxMessages, model})
+		if model != "openai/gpt-4o-mini" {
+			return nil, overflow // the system prompt + KB alone is too big for the default model
+		}
+		return &llmReply{response: "Harga paket Basic Rp150.000"}, nil
+	}
+
+	reply, strategy, err := recoverContextOverflow(10, "openai/gpt-4o-mini", ask)
+	if err != nil || strategy != "larger_model" || reply.response != "Harga paket Basic Rp150.000" {
+		t.Fatalf("got %+v, %q, %v", reply, strategy, err)
+	}
+	want := []call{{minContextMessages, ""}, {minContextMessages, "openai/gpt-4o-mini"}}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// Without a fallback model the overflow is returned as before
+	calls = nil
+	if _, _, err := recoverContextOverflow(10, "", ask); !errors.Is(err, overflow) || len(calls) != 1 {
+		t.Errorf("no fallback model: err = %v, calls = %v", err, calls)
+	}
+
+	// Already at the minimum history: straight to the larger model
+	calls = nil
+	if _, strategy, err := recoverContextOverflow(minContextMessages, "openai/gpt-4o-mini", ask); err != nil || strategy != "larger_model" || len(calls) != 1 {
+		t.Errorf("at minimum: strategy = %q, err = %v, calls = %v", strategy, err, calls)
+	}
+
+	// Fewer messages fixing it never touches the larger model
+	calls = nil
+	fits := func(maxMessages int, model string) (*llmReply, error) {
+		calls = append(calls, call{maxMessages, model})
+		return &llmReply{response: "ok"}, nil
+	}
+	if _, strategy, err := recoverContextOverflow(10, "openai/gpt-4o-mini", fits); err != nil || strategy != "fewer_messages" || len(calls) != 1 {
+		t.Errorf("fewer messages: strategy = %q, err = %v, calls = %v", strategy, err, calls)
+	}
+}